@@ -36,10 +36,12 @@ import (
 )
 
 type BaseOptions struct {
-	Debug    bool `help:"Show debug information"`
-	Version  bool `help:"Show version"`
-	Timeout  int  `default:"600" help:"Number of seconds to wait for a response"`
-	Insecure bool `default:"$YUNION_INSECURE|false" help:"Allow skip server cert verification if URL is https" short-token:"k"`
+	Debug    bool   `help:"Show debug information"`
+	Version  bool   `help:"Show version"`
+	Timeout  int    `default:"600" help:"Number of seconds to wait for a response"`
+	Retries  int    `default:"$CLIMC_RETRIES|0" help:"Number of retries of create and perform requests on transient errors, retries carry the same Idempotency-Key"`
+	IfMatch  string `help:"Only apply update and perform requests if the resource still matches the ETag returned by a previous GET"`
+	Insecure bool   `default:"$YUNION_INSECURE|false" help:"Allow skip server cert verification if URL is https" short-token:"k"`
 
 	CertFile string `default:"$YUNION_CERT_FILE" help:"certificate file"`
 	KeyFile  string `default:"$YUNION_KEY_FILE" help:"private key file"`
@@ -66,7 +68,10 @@ type BaseOptions struct {
 	OsEndpointType string `default:"$OS_ENDPOINT_TYPE|internalURL" help:"Defaults to env[OS_ENDPOINT_TYPE] or internalURL" choices:"publicURL|internalURL|adminURL"`
 	ApiVersion     string `default:"$API_VERSION" help:"override default modules service api version"`
 	OutputFormat   string `default:"$CLIMC_OUTPUT_FORMAT|table" choices:"table|kv|json|flatten-table|flatten-kv" help:"output format"`
-	SUBCOMMAND     string `help:"climc subcommand" subcommand:"true"`
+
+	BatchFromFilter bool `help:"Treat the id arguments of perform, update and delete commands as list filters, e.g. 'status=ready&zone=zone1', and apply the operation to all matched resources"`
+
+	SUBCOMMAND string `help:"climc subcommand" subcommand:"true"`
 }
 
 func getSubcommandsParser() (*structarg.ArgumentParser, error) {
@@ -144,6 +149,7 @@ func newClientSession(options *BaseOptions) (*mcclient.ClientSession, error) {
 		options.Insecure,
 		options.CertFile,
 		options.KeyFile)
+	client.SetRetries(options.Retries)
 
 	var cacheToken mcclient.TokenCredential
 	authUrlAlter := strings.Replace(options.OsAuthURL, "/", "", -1)
@@ -216,6 +222,9 @@ func newClientSession(options *BaseOptions) (*mcclient.ClientSession, error) {
 		options.OsEndpointType,
 		cacheToken,
 		options.ApiVersion)
+	if len(options.IfMatch) > 0 {
+		session.SetIfMatch(options.IfMatch)
+	}
 	return session, nil
 }
 
@@ -283,6 +292,7 @@ func ClimcMain() {
 	}
 
 	shell.OutputFormat(options.OutputFormat)
+	shell.BatchFromFilter(options.BatchFromFilter)
 	ensureSessionFactory := func() *mcclient.ClientSession {
 		session, err := newClientSession(options)
 		if err != nil {
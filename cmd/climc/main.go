@@ -36,6 +36,7 @@ import (
 	_ "yunion.io/x/onecloud/cmd/climc/shell/quota"
 	_ "yunion.io/x/onecloud/cmd/climc/shell/scheduledtask"
 	_ "yunion.io/x/onecloud/cmd/climc/shell/scheduler"
+	_ "yunion.io/x/onecloud/cmd/climc/shell/stack"
 	_ "yunion.io/x/onecloud/cmd/climc/shell/yunionconf"
 )
 
@@ -0,0 +1,88 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shell
+
+import (
+	"fmt"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/modulebase"
+)
+
+// batchFromFilter makes perform, update and delete commands treat their id
+// arguments as list filters, e.g. 'status=ready&zone=zone1', and apply the
+// operation to all matched resources through the bulk API
+var batchFromFilter = false
+
+func BatchFromFilter(b bool) {
+	batchFromFilter = b
+}
+
+type iBulkManager interface {
+	Bulk(session *mcclient.ClientSession, input *apis.BulkOperationInput) ([]modulebase.SubmitResult, error)
+}
+
+// parseBatchFilter merges the query strings given in place of ids into one
+// list filter, all conditions must be satisfied
+func parseBatchFilter(filters []string) (*jsonutils.JSONDict, error) {
+	ret := jsonutils.NewDict()
+	for _, f := range filters {
+		query, err := jsonutils.ParseQueryString(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid filter %q", f)
+		}
+		ret.Update(query)
+	}
+	if ret.Length() == 0 {
+		return nil, fmt.Errorf("empty filter is not allowed with --batch-from-filter")
+	}
+	return ret, nil
+}
+
+func (cmd ResourceCmd) bulk(s *mcclient.ClientSession, operation, action string, filters []string, params jsonutils.JSONObject) error {
+	man, ok := cmd.manager.(iBulkManager)
+	if !ok {
+		return fmt.Errorf("%s does not support --batch-from-filter", cmd.manager.GetKeyword())
+	}
+	filter, err := parseBatchFilter(filters)
+	if err != nil {
+		return err
+	}
+	ret, err := man.Bulk(s, &apis.BulkOperationInput{
+		Operation: operation,
+		Action:    action,
+		Filter:    filter,
+		Params:    params,
+	})
+	if err != nil {
+		return err
+	}
+	printBatchResults(ret, cmd.manager.GetColumns(s))
+	return nil
+}
+
+func mergeBatchParams(query, params jsonutils.JSONObject) jsonutils.JSONObject {
+	ret := jsonutils.NewDict()
+	for _, p := range []jsonutils.JSONObject{query, params} {
+		if dict, ok := p.(*jsonutils.JSONDict); ok {
+			ret.Update(dict)
+		}
+	}
+	return ret
+}
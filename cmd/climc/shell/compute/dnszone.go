@@ -34,4 +34,6 @@ func init() {
 	cmd.Perform("purge", &options.SDnsZoneIdOptions{})
 	cmd.Perform("add-vpcs", &options.DnsZoneAddVpcsOptions{})
 	cmd.Perform("remove-vpcs", &options.DnsZoneRemoveVpcsOptions{})
+	cmd.Perform("enable-dnssec", &options.SDnsZoneIdOptions{})
+	cmd.Perform("disable-dnssec", &options.SDnsZoneIdOptions{})
 }
@@ -92,6 +92,14 @@ func init() {
 		printObject(lbcert)
 		return nil
 	})
+	R(&options.LoadbalancerCertificateRenewOptions{}, "lbcert-renew", "Renew lbcert issued by ACME", func(s *mcclient.ClientSession, opts *options.LoadbalancerCertificateRenewOptions) error {
+		lbcert, err := modules.LoadbalancerCertificates.PerformAction(s, opts.ID, "renew", nil)
+		if err != nil {
+			return err
+		}
+		printObject(lbcert)
+		return nil
+	})
 	R(&options.LoadbalancerCertificatePrivateOptions{}, "lbcert-private", "Private lbcert", func(s *mcclient.ClientSession, opts *options.LoadbalancerCertificatePrivateOptions) error {
 		lbcert, err := modules.LoadbalancerCertificates.PerformAction(s, opts.ID, "private", nil)
 		if err != nil {
@@ -15,8 +15,6 @@
 package compute
 
 import (
-	"yunion.io/x/jsonutils"
-
 	"yunion.io/x/onecloud/pkg/mcclient"
 	modules "yunion.io/x/onecloud/pkg/mcclient/modules/compute"
 	"yunion.io/x/onecloud/pkg/mcclient/options"
@@ -24,7 +22,10 @@ import (
 
 func init() {
 	R(&options.LoadbalancerListenerRuleCreateOptions{}, "lblistenerrule-create", "Create lblistenerrule", func(s *mcclient.ClientSession, opts *options.LoadbalancerListenerRuleCreateOptions) error {
-		params := jsonutils.Marshal(opts)
+		params, err := opts.Params()
+		if err != nil {
+			return err
+		}
 		lblistenerrule, err := modules.LoadbalancerListenerRules.Create(s, params)
 		if err != nil {
 			return err
@@ -53,7 +54,10 @@ func init() {
 		return nil
 	})
 	R(&options.LoadbalancerListenerRuleUpdateOptions{}, "lblistenerrule-update", "Update lblistenerrule", func(s *mcclient.ClientSession, opts *options.LoadbalancerListenerRuleUpdateOptions) error {
-		params, err := options.StructToParams(opts)
+		params, err := opts.Params()
+		if err != nil {
+			return err
+		}
 		lblistenerrule, err := modules.LoadbalancerListenerRules.Update(s, opts.ID, params)
 		if err != nil {
 			return err
@@ -117,6 +117,7 @@ func init() {
 		Zone    string `help:"ID or Name of zone in which the network is created"`
 		NAME    string `help:"Name of new network"`
 		PREFIX  string `help:"Start of IPv4 address range"`
+		Prefix6 string `help:"IPv6 prefix of the network, e.g. fd00:1::/64"`
 		BgpType string `help:"Internet service provider name" positional:"false"`
 		Desc    string `help:"Description" metavar:"DESCRIPTION"`
 	}
@@ -124,6 +125,9 @@ func init() {
 		params := jsonutils.NewDict()
 		params.Add(jsonutils.NewString(args.NAME), "name")
 		params.Add(jsonutils.NewString(args.PREFIX), "guest_ip_prefix")
+		if len(args.Prefix6) > 0 {
+			params.Add(jsonutils.NewString(args.Prefix6), "guest_ip6_prefix")
+		}
 		if len(args.BgpType) > 0 {
 			params.Add(jsonutils.NewString(args.BgpType), "bgp_type")
 		}
@@ -66,6 +66,7 @@ func init() {
 	cmd.PrintObjectYAML().Perform("migrate-forecast", new(options.ServerMigrateForecastOptions))
 	cmd.Perform("migrate", new(options.ServerMigrateOptions))
 	cmd.Perform("live-migrate", new(options.ServerLiveMigrateOptions))
+	cmd.Perform("cancel-live-migrate", new(options.ServerIdOptions))
 	cmd.Perform("modify-src-check", new(options.ServerModifySrcCheckOptions))
 	cmd.Perform("set-secgroup", new(options.ServerSecGroupsOptions))
 	cmd.Perform("add-secgroup", new(options.ServerSecGroupsOptions))
@@ -321,6 +322,23 @@ func init() {
 		return nil
 	})
 
+	R(&options.ServerSerialLogOptions{}, "server-serial-log", "Show serial console log of server", func(s *mcclient.ClientSession, opts *options.ServerSerialLogOptions) error {
+		params, err := baseoptions.StructToParams(opts)
+		if err != nil {
+			return err
+		}
+		ret, err := modules.Servers.GetSpecific(s, opts.ID, "serial-log", params)
+		if err != nil {
+			return err
+		}
+		result, err := ret.GetString("log")
+		if err != nil {
+			return err
+		}
+		fmt.Print(result)
+		return nil
+	})
+
 	type ServerDiskSnapshotOptions struct {
 		SERVER       string `help:"server ID or Name"`
 		DISK         string `help:"create snapshot disk id"`
@@ -22,6 +22,7 @@ import (
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/pkg/utils"
 
+	"yunion.io/x/onecloud/pkg/apis"
 	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/mcclient/modulebase"
 	"yunion.io/x/onecloud/pkg/util/printutils"
@@ -352,6 +353,9 @@ func (cmd ResourceCmd) Delete(args IDeleteOpt) {
 		if err != nil {
 			return err
 		}
+		if batchFromFilter {
+			return cmd.bulk(s, apis.BULK_OPERATION_DELETE, "", []string{args.GetId()}, params)
+		}
 		ret, err := man.(modulebase.Manager).Delete(s, args.GetId(), params)
 		if err != nil {
 			return err
@@ -378,6 +382,9 @@ func (cmd ResourceCmd) DeleteWithParam(args IDeleteWithParamOpt) {
 		if err != nil {
 			return err
 		}
+		if batchFromFilter {
+			return cmd.bulk(s, apis.BULK_OPERATION_DELETE, "", []string{args.GetId()}, mergeBatchParams(queryParams, params))
+		}
 		ret, err := man.(modulebase.Manager).DeleteWithParam(s, args.GetId(), queryParams, params)
 		if err != nil {
 			return err
@@ -404,6 +411,9 @@ func (cmd ResourceCmd) PerformWithKeyword(keyword, action string, args IPerformO
 		if err != nil {
 			return err
 		}
+		if batchFromFilter {
+			return cmd.bulk(s, apis.BULK_OPERATION_PERFORM, action, []string{args.GetId()}, params)
+		}
 		ret, err := man.(modulebase.Manager).PerformAction(s, args.GetId(), action, params)
 		if err != nil {
 			return err
@@ -464,6 +474,9 @@ func (cmd ResourceCmd) BatchPerform(action string, args IBatchPerformOpt) {
 		if err != nil {
 			return err
 		}
+		if batchFromFilter {
+			return cmd.bulk(s, apis.BULK_OPERATION_PERFORM, action, args.GetIds(), params)
+		}
 		ret := man.(modulebase.Manager).BatchPerformAction(s, args.GetIds(), action, params)
 		printBatchResults(ret, man.GetColumns(s))
 		return nil
@@ -514,6 +527,9 @@ func (cmd ResourceCmd) UpdateWithKeyword(keyword string, args IUpdateOpt) {
 		if params.Length() == 0 {
 			return InvalidUpdateError()
 		}
+		if batchFromFilter {
+			return cmd.bulk(s, apis.BULK_OPERATION_UPDATE, "", []string{args.GetId()}, params)
+		}
 		ret, err := man.(modulebase.Manager).Update(s, args.GetId(), params)
 		if err != nil {
 			return err
@@ -562,6 +578,9 @@ func (cmd ResourceCmd) BatchDelete(args IBatchDeleteOpt) {
 		if err != nil {
 			return err
 		}
+		if batchFromFilter {
+			return cmd.bulk(s, apis.BULK_OPERATION_DELETE, "", args.GetIds(), params)
+		}
 		ret := man.(modulebase.Manager).BatchDelete(s, args.GetIds(), params)
 		printBatchResults(ret, man.GetColumns(s))
 		return nil
@@ -585,6 +604,9 @@ func (cmd ResourceCmd) BatchDeleteWithParam(args IBatchDeleteWithParamOpt) {
 		if err != nil {
 			return err
 		}
+		if batchFromFilter {
+			return cmd.bulk(s, apis.BULK_OPERATION_DELETE, "", args.GetIds(), mergeBatchParams(queryParams, params))
+		}
 		ret := man.(modulebase.Manager).BatchDeleteWithParam(s, args.GetIds(), queryParams, params)
 		printBatchResults(ret, man.GetColumns(s))
 		return nil
@@ -604,6 +626,9 @@ func (cmd ResourceCmd) BatchPut(args IBatchPutOpt) {
 		if err != nil {
 			return err
 		}
+		if batchFromFilter {
+			return cmd.bulk(s, apis.BULK_OPERATION_UPDATE, "", args.GetIds(), params)
+		}
 		ret := man.(modulebase.Manager).BatchPut(s, args.GetIds(), params)
 		printBatchResults(ret, man.GetColumns(s))
 		return nil
@@ -0,0 +1,45 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/mcclient"
+	modules "yunion.io/x/onecloud/pkg/mcclient/modules/logger"
+)
+
+func init() {
+	type LbAccessLogListOptions struct {
+		Loadbalancer string `help:"filter by loadbalancer id" json:"loadbalancer_id"`
+		Listener     string `help:"filter by listener id" json:"listener_id"`
+		ClientIp     string `help:"filter by client ip"`
+		Status       *int   `help:"filter by http status code"`
+		PagingMarker string `help:"marker for pagination"`
+		Limit        int    `help:"page limit, default 20" default:"20"`
+		Scope        string `help:"scope" choices:"project|domain|system"`
+		Since        string `help:"Show logs since specific date" metavar:"DATETIME"`
+		Until        string `help:"Show logs until specific date" metavar:"DATETIME"`
+	}
+	R(&LbAccessLogListOptions{}, "lb-access-log-list", "List loadbalancer listener access logs", func(s *mcclient.ClientSession, args *LbAccessLogListOptions) error {
+		params := jsonutils.Marshal(args)
+		result, err := modules.LbAccessLogs.List(s, params)
+		if err != nil {
+			return err
+		}
+		printList(result, modules.LbAccessLogs.GetColumns(s))
+		return nil
+	})
+}
@@ -0,0 +1,49 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/mcclient"
+	modules "yunion.io/x/onecloud/pkg/mcclient/modules/logger"
+)
+
+func init() {
+	type SecgroupFlowLogRecordListOptions struct {
+		FlowLog      string `help:"filter by secgroup flow log id" json:"flow_log_id"`
+		Vpc          string `help:"filter by vpc id" json:"vpc_id"`
+		Network      string `help:"filter by network id" json:"network_id"`
+		Guest        string `help:"filter by guest id" json:"guest_id"`
+		Rule         string `help:"filter by secgroup rule id" json:"rule_id"`
+		Action       string `help:"filter by action" choices:"allow|deny"`
+		SrcIp        string `help:"filter by source ip"`
+		DstIp        string `help:"filter by destination ip"`
+		PagingMarker string `help:"marker for pagination"`
+		Limit        int    `help:"page limit, default 20" default:"20"`
+		Scope        string `help:"scope" choices:"project|domain|system"`
+		Since        string `help:"Show logs since specific date" metavar:"DATETIME"`
+		Until        string `help:"Show logs until specific date" metavar:"DATETIME"`
+	}
+	R(&SecgroupFlowLogRecordListOptions{}, "secgroup-flow-log-record-list", "List sampled security group flow log records", func(s *mcclient.ClientSession, args *SecgroupFlowLogRecordListOptions) error {
+		params := jsonutils.Marshal(args)
+		result, err := modules.SecgroupFlowLogRecords.List(s, params)
+		if err != nil {
+			return err
+		}
+		printList(result, modules.SecgroupFlowLogRecords.GetColumns(s))
+		return nil
+	})
+}
@@ -82,4 +82,19 @@ func init() {
 		return nil
 	})
 
+	type TaskCancelOptions struct {
+		ID          string `help:"ID of the task"`
+		Reason      string `help:"reason to cancel the task"`
+		ServiceType string `choices:"image|cloudid|cloudevent|devtool|ansible|identity|notify|log|compute|compute_v2"`
+	}
+	R(&TaskCancelOptions{}, "task-cancel", "Cancel a task, the task fails on its current stage", func(s *mcclient.ClientSession, args *TaskCancelOptions) error {
+		man := compute.TasksManager{}
+		params := jsonutils.Marshal(args)
+		result, err := man.Cancel(s, args.ID, args.Reason, params)
+		if err != nil {
+			return err
+		}
+		printObject(result)
+		return nil
+	})
 }
@@ -0,0 +1,36 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"yunion.io/x/onecloud/cmd/climc/shell"
+	modules "yunion.io/x/onecloud/pkg/mcclient/modules/stack"
+	options "yunion.io/x/onecloud/pkg/mcclient/options/stack"
+)
+
+func init() {
+	cmd := shell.NewResourceCmd(&modules.Stacks)
+	cmd.List(new(options.StackListOptions))
+	cmd.Show(new(options.StackIdOptions))
+	cmd.Create(new(options.StackCreateOptions))
+	cmd.Delete(new(options.StackIdOptions))
+	cmd.Perform("preview", new(options.StackChangeOptions))
+	cmd.Perform("apply", new(options.StackChangeOptions))
+	cmd.Perform("detect-drift", new(options.StackIdOptions))
+
+	resCmd := shell.NewResourceCmd(&modules.StackResources)
+	resCmd.List(new(options.StackResourceListOptions))
+	resCmd.Show(new(options.StackResourceIdOptions))
+}
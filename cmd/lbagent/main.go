@@ -47,6 +47,7 @@ func main() {
 	var haproxyHelper *lbagent.HaproxyHelper
 	var apiHelper *lbagent.ApiHelper
 	var haStateWatcher *lbagent.HaStateWatcher
+	var accessLogHelper *lbagent.AccessLogHelper
 	var err error
 	{
 		haStateWatcher, err = lbagent.NewHaStateWatcher(opts)
@@ -54,11 +55,18 @@ func main() {
 			log.Fatalf("init ha state watcher failed: %s", err)
 		}
 	}
+	{
+		accessLogHelper, err = lbagent.NewAccessLogHelper(opts)
+		if err != nil {
+			log.Fatalf("init access log helper failed: %s", err)
+		}
+	}
 	{
 		haproxyHelper, err = lbagent.NewHaproxyHelper(opts)
 		if err != nil {
 			log.Fatalf("init haproxy helper failed: %s", err)
 		}
+		haproxyHelper.SetAccessLogProvider(accessLogHelper)
 	}
 	{
 		apiHelper, err = lbagent.NewApiHelper(opts)
@@ -74,8 +82,9 @@ func main() {
 		ctx, cancelFunc := context.WithCancel(context.Background())
 		ctx = context.WithValue(ctx, "wg", wg)
 		ctx = context.WithValue(ctx, "cmdChan", cmdChan)
-		wg.Add(3)
+		wg.Add(4)
 		go haStateWatcher.Run(ctx)
+		go accessLogHelper.Run(ctx)
 		go haproxyHelper.Run(ctx)
 		go apiHelper.Run(ctx)
 
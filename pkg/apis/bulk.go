@@ -0,0 +1,68 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apis
+
+import "yunion.io/x/jsonutils"
+
+const (
+	// 对每个资源执行同一个操作(perform action)
+	BULK_OPERATION_PERFORM = "perform"
+	// 以相同参数更新每个资源
+	BULK_OPERATION_UPDATE = "update"
+	// 删除每个资源
+	BULK_OPERATION_DELETE = "delete"
+)
+
+type BulkOperationInput struct {
+	// 批量操作类型
+	// enum: perform,update,delete
+	// required: true
+	Operation string `json:"operation"`
+
+	// 操作名称，operation为perform时必须指定，例如start, stop
+	Action string `json:"action"`
+
+	// 资源ID或名称列表，与filter二选一
+	Ids []string `json:"ids"`
+
+	// 列表过滤条件，与列表接口的查询参数一致，与ids二选一
+	Filter *jsonutils.JSONDict `json:"filter"`
+
+	// 操作参数，perform和delete时作为请求体，update时作为更新内容
+	Params jsonutils.JSONObject `json:"params"`
+
+	// 并发执行的操作数量，默认为8，最大为32
+	Concurrency int `json:"concurrency"`
+}
+
+type BulkOperationResult struct {
+	// 资源ID或名称
+	Id string `json:"id"`
+	// 执行结果的HTTP状态码，200表示成功
+	Status int `json:"status"`
+	// 成功时为操作返回的资源详情，失败时为错误信息
+	Data jsonutils.JSONObject `json:"data"`
+}
+
+type BulkOperationOutput struct {
+	// 操作的资源总数
+	Total int `json:"total"`
+	// 成功的数量
+	Succeeded int `json:"succeeded"`
+	// 失败的数量
+	Failed int `json:"failed"`
+	// 每个资源的执行结果，与请求中的资源顺序一致
+	Results []BulkOperationResult `json:"results"`
+}
@@ -55,11 +55,13 @@ type NetworkConfig struct {
 	// required: false
 	Address string `json:"address"`
 
-	// 子网内的IPv6地址
+	// 子网内的IPv6地址, 若子网启用了IPv6且不指定, 会按照子网的地址分配策略分配一个IPv6地址
 	// required: false
-	// swagger:ignore
 	Address6 string `json:"address6"`
 
+	// 是否必须分配IPv6地址, 若子网未启用IPv6则报错
+	RequireIPv6 bool `json:"require_ipv6"`
+
 	// 驱动方式
 	// 若指定镜像的网络驱动方式，此参数会被覆盖
 	Driver   string `json:"driver"`
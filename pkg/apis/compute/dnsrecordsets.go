@@ -27,6 +27,9 @@ import (
 
 const (
 	DNS_RECORDSET_STATUS_AVAILABLE = "available"
+
+	// 加权流量策略的最大权重
+	DNS_RECORDSET_MAX_WEIGHT = 255
 )
 
 type DnsRecordPolicy struct {
@@ -0,0 +1,77 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compute
+
+import (
+	"yunion.io/x/onecloud/pkg/apis"
+)
+
+const (
+	DNS_TSIG_KEY_STATUS_AVAILABLE = "available"
+
+	DNS_TSIG_ALGORITHM_HMAC_SHA1   = "hmac-sha1"
+	DNS_TSIG_ALGORITHM_HMAC_SHA256 = "hmac-sha256"
+	DNS_TSIG_ALGORITHM_HMAC_SHA512 = "hmac-sha512"
+)
+
+var DNS_TSIG_ALGORITHMS = []string{
+	DNS_TSIG_ALGORITHM_HMAC_SHA1,
+	DNS_TSIG_ALGORITHM_HMAC_SHA256,
+	DNS_TSIG_ALGORITHM_HMAC_SHA512,
+}
+
+type DnsTsigKeyCreateInput struct {
+	apis.VirtualResourceCreateInput
+
+	// 可动态更新的Dns Zone
+	DnsZoneId string `json:"dns_zone_id"`
+
+	// 签名算法
+	//
+	//
+	// | 算法			| 说明    |
+	// |----------		|---------|
+	// | hmac-sha1		|         |
+	// | hmac-sha256	| 默认    |
+	// | hmac-sha512	|         |
+	Algorithm string `json:"algorithm"`
+
+	// base64编码的密钥, 为空时自动生成
+	Secret string `json:"secret"`
+}
+
+type DnsTsigKeyUpdateInput struct {
+	apis.VirtualResourceBaseUpdateInput
+}
+
+type DnsTsigKeyListInput struct {
+	apis.VirtualResourceListInput
+
+	DnsZoneFilterListBase
+
+	// 签名算法
+	Algorithm []string `json:"algorithm"`
+}
+
+type DnsTsigKeyDetails struct {
+	apis.VirtualResourceDetails
+	SDnsTsigKey
+
+	// Dns Zone名称
+	DnsZone string `json:"dns_zone"`
+}
+
+type DnsTsigKeyRegenerateSecretInput struct {
+}
@@ -126,6 +126,8 @@ type DnsZoneDetails struct {
 	VpcCount int `json:"vpc_count"`
 	// Cache info
 	CloudCaches []jsonutils.JSONObject `json:"cloud_caches"`
+	// DNSSEC DS记录, 需添加至上级域名
+	DnssecDs string `json:"dnssec_ds"`
 }
 
 type DnsZoneListInput struct {
@@ -180,3 +182,9 @@ type DnsZoneSyncRecordSetsInput struct {
 
 type DnsZonePurgeInput struct {
 }
+
+type DnsZoneEnableDnssecInput struct {
+}
+
+type DnsZoneDisableDnssecInput struct {
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compute
+
+import (
+	"reflect"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/pkg/utils"
+
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+const (
+	LIVE_MIGRATE_COMPRESSION_ZSTD = "zstd"
+
+	// 热迁移被取消时宿主机上报的失败原因
+	LIVE_MIGRATE_CANCELLED = "live migrate cancelled"
+	// 热迁移任务参数, 标记迁移已被用户取消
+	LIVE_MIGRATE_CANCELLED_PARAM = "live_migrate_cancelled"
+
+	LIVE_MIGRATE_MAX_MULTIFD_CHANNELS = 255
+)
+
+var LIVE_MIGRATE_COMPRESSIONS = []string{LIVE_MIGRATE_COMPRESSION_ZSTD}
+
+// 热迁移传输参数, 未指定的参数使用源宿主机的默认配置
+type GuestLiveMigrateOptions struct {
+	// 并行传输通道数(multifd), 0表示不启用multifd
+	MultifdChannels *int `json:"multifd_channels"`
+	// 传输数据压缩算法, 需要启用multifd
+	// enum: zstd
+	Compression string `json:"compression"`
+	// 是否启用auto-converge, 内存脏页速率过高时自动降低虚拟机CPU频率
+	AutoConverge *bool `json:"auto_converge"`
+	// 迁移带宽上限, 单位MB/s, 0表示不限速
+	MaxBandwidthMb *int64 `json:"max_bandwidth_mb"`
+	// 允许的最大停机时间, 单位毫秒, 0表示使用qemu默认值
+	DowntimeLimitMs *int64 `json:"downtime_limit_ms"`
+}
+
+func (opts *GuestLiveMigrateOptions) Validate() error {
+	if opts.MultifdChannels != nil {
+		if *opts.MultifdChannels < 0 || *opts.MultifdChannels > LIVE_MIGRATE_MAX_MULTIFD_CHANNELS {
+			return httperrors.NewOutOfRangeError("multifd_channels should be in range 0-%d", LIVE_MIGRATE_MAX_MULTIFD_CHANNELS)
+		}
+	}
+	if len(opts.Compression) > 0 {
+		if !utils.IsInStringArray(opts.Compression, LIVE_MIGRATE_COMPRESSIONS) {
+			return httperrors.NewInputParameterError("unsupported compression %s, supported %v", opts.Compression, LIVE_MIGRATE_COMPRESSIONS)
+		}
+		if opts.MultifdChannels != nil && *opts.MultifdChannels == 0 {
+			return httperrors.NewInputParameterError("compression %s requires multifd", opts.Compression)
+		}
+	}
+	if opts.MaxBandwidthMb != nil && *opts.MaxBandwidthMb < 0 {
+		return httperrors.NewOutOfRangeError("max_bandwidth_mb should not be negative")
+	}
+	if opts.DowntimeLimitMs != nil && *opts.DowntimeLimitMs < 0 {
+		return httperrors.NewOutOfRangeError("downtime_limit_ms should not be negative")
+	}
+	return nil
+}
+
+func (opts GuestLiveMigrateOptions) IsZero() bool {
+	return opts.MultifdChannels == nil && len(opts.Compression) == 0 && opts.AutoConverge == nil &&
+		opts.MaxBandwidthMb == nil && opts.DowntimeLimitMs == nil
+}
+
+// 热迁移进度
+type SGuestMigrateProgress struct {
+	// qemu迁移状态
+	// example: active
+	Status string `json:"status"`
+	// 需要传输的数据总量(内存及本地盘), 单位字节
+	TotalBytes int64 `json:"total_bytes"`
+	// 已传输数据量, 单位字节
+	TransferredBytes int64 `json:"transferred_bytes"`
+	// 剩余数据量, 单位字节
+	RemainingBytes int64 `json:"remaining_bytes"`
+	// 内存脏页速率, 单位页/秒
+	DirtyPagesRate int64 `json:"dirty_pages_rate"`
+	// 传输速率, 单位Mbps
+	Mbps float64 `json:"mbps"`
+	// 预计停机时间, 单位毫秒
+	ExpectedDowntimeMs int64 `json:"expected_downtime_ms"`
+}
+
+func (p SGuestMigrateProgress) String() string {
+	return jsonutils.Marshal(p).String()
+}
+
+func (p SGuestMigrateProgress) IsZero() bool {
+	return p == SGuestMigrateProgress{}
+}
+
+func init() {
+	gotypes.RegisterSerializable(reflect.TypeOf(&SGuestMigrateProgress{}), func() gotypes.ISerializable {
+		return &SGuestMigrateProgress{}
+	})
+}
@@ -99,6 +99,11 @@ type GuestnetworkJsonDesc struct {
 	TeamWith   string               `json:"team_with"`
 	Manual     *bool                `json:"manual"`
 
+	Ip6      string `json:"ip6"`
+	Gateway6 string `json:"gateway6"`
+	Masklen6 int8   `json:"masklen6,omitzero"`
+	Dns6     string `json:"dns6"`
+
 	Vpc struct {
 		Id           string `json:"id"`
 		Provider     string `json:"provider"`
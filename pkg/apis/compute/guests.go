@@ -392,6 +392,8 @@ type GuestLiveMigrateInput struct {
 	SkipKernelCheck *bool `json:"skip_kernel_check"`
 	// 是否启用 tls
 	EnableTLS *bool `json:"enable_tls"`
+
+	GuestLiveMigrateOptions
 }
 
 type GuestSetSecgroupInput struct {
@@ -673,6 +675,8 @@ type ServerUpdateInput struct {
 
 	// swagger: ignore
 	ProgressMbps float32 `json:"progress_mbps"`
+	// swagger: ignore
+	MigrateProgress *SGuestMigrateProgress `json:"migrate_progress"`
 }
 
 type GuestJsonDesc struct {
@@ -833,3 +837,15 @@ type ServerGetCPUSetCoresResp struct {
 	HostCores     []int `json:"host_cores"`
 	HostUsedCores []int `json:"host_used_cores"`
 }
+
+type ServerSerialLogInput struct {
+	// 返回串口日志末尾的字节数, 0表示返回全部日志
+	Tail int64 `json:"tail"`
+}
+
+type ServerSerialLogOutput struct {
+	// 串口日志所在宿主机Id
+	HostId string `json:"host_id"`
+	// 串口日志内容
+	Log string `json:"log"`
+}
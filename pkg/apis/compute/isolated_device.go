@@ -59,6 +59,12 @@ type IsolatedDeviceListInput struct {
 
 	// 列出虚拟机上挂载的设备
 	GuestId string `json:"guest_id"`
+
+	// 只列出SR-IOV网卡VF所属的二层网络上的设备
+	WireId string `json:"wire_id"`
+
+	// 按mdev类型过滤, 例如 nvidia-63
+	MdevType []string `json:"mdev_type"`
 }
 
 type IsolatedDeviceCreateInput struct {
@@ -81,6 +87,12 @@ type IsolatedDeviceCreateInput struct {
 
 	// 设备VendorId
 	VendorDeviceId string `json:"vendor_device_id"`
+
+	// SR-IOV网卡VF所属的二层网络
+	WireId string `json:"wire_id"`
+
+	// mdev设备类型, 例如 nvidia-63
+	MdevType string `json:"mdev_type"`
 }
 
 type IsolatedDeviceReservedResourceInput struct {
@@ -106,4 +118,11 @@ type IsolatedDeviceJsonDesc struct {
 	Addr           string `json:"addr"`
 	VendorDeviceId string `json:"vendor_device_id"`
 	Vendor         string `json:"vendor"`
+
+	// SR-IOV VF
+	WireId       string `json:"wire_id"`
+	NetworkIndex *int8  `json:"network_index"`
+
+	// mediated device
+	MdevType string `json:"mdev_type"`
 }
@@ -19,15 +19,21 @@ const (
 	GPU_HPC_TYPE    = "GPU-HPC" // # for compute
 	GPU_VGA_TYPE    = "GPU-VGA" // # for display
 	USB_TYPE        = "USB"
-	NIC_TYPE        = "NIC"
+	NIC_TYPE        = "NIC"  // # SR-IOV virtual function
+	MDEV_TYPE       = "MDEV" // # mediated device, e.g. vGPU
 
 	NVIDIA_VENDOR_ID = "10de"
 	AMD_VENDOR_ID    = "1002"
 )
 
+const (
+	// guest nic backed by a passthrough SR-IOV virtual function
+	NETWORK_DRIVER_VFIO = "vfio-pci"
+)
+
 var VALID_GPU_TYPES = []string{GPU_HPC_TYPE, GPU_VGA_TYPE}
 
-var VALID_PASSTHROUGH_TYPES = []string{DIRECT_PCI_TYPE, USB_TYPE, NIC_TYPE, GPU_HPC_TYPE, GPU_VGA_TYPE}
+var VALID_PASSTHROUGH_TYPES = []string{DIRECT_PCI_TYPE, USB_TYPE, NIC_TYPE, MDEV_TYPE, GPU_HPC_TYPE, GPU_VGA_TYPE}
 
 var ID_VENDOR_MAP = map[string]string{
 	NVIDIA_VENDOR_ID: "NVIDIA",
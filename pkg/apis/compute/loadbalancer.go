@@ -113,6 +113,8 @@ type LoadbalancerCertificateListInput struct {
 
 	CommonName              []string `json:"common_name"`
 	SubjectAlternativeNames []string `json:"subject_alternative_names"`
+	// 证书类型
+	CertificateType []string `json:"certificate_type"`
 }
 
 type LoadbalancerBackendListInput struct {
@@ -152,6 +154,12 @@ type LoadbalancerClusterListInput struct {
 
 	ZonalFilterListInput
 	WireFilterListBase
+
+	// 数据面
+	DataPlane []string `json:"data_plane"`
+
+	// 高可用模式
+	HaMode []string `json:"ha_mode"`
 }
 
 type LoadbalancerAclListInput struct {
@@ -209,6 +209,36 @@ var LB_TLS_CERT_PUBKEY_ALGOS = choices.NewChoices(
 	LB_TLS_CERT_PUBKEY_ALGO_ECDSA,
 )
 
+const (
+	// 证书正在通过ACME签发
+	LB_CERT_STATUS_ISSUING      = "issuing"
+	LB_CERT_STATUS_ISSUE_FAILED = "issue_failed"
+	// 证书正在通过ACME续期
+	LB_CERT_STATUS_RENEWING = "renewing"
+
+	// 通过负载均衡监听的 /.well-known/acme-challenge/ 路径完成验证
+	LB_CERT_ACME_CHALLENGE_HTTP01 = "http-01"
+	// 通过Dns Zone中的 _acme-challenge TXT记录完成验证
+	LB_CERT_ACME_CHALLENGE_DNS01 = "dns-01"
+)
+
+var LB_CERT_ACME_CHALLENGES = choices.NewChoices(
+	LB_CERT_ACME_CHALLENGE_HTTP01,
+	LB_CERT_ACME_CHALLENGE_DNS01,
+)
+
+const (
+	// 服务端证书，包含私钥
+	LB_CERT_TYPE_SERVER = "server"
+	// CA证书包，用于校验客户端或后端服务器证书，不包含私钥
+	LB_CERT_TYPE_CA = "ca"
+)
+
+var LB_CERT_TYPES = choices.NewChoices(
+	LB_CERT_TYPE_SERVER,
+	LB_CERT_TYPE_CA,
+)
+
 // TODO may want extra for legacy apps
 const (
 	LB_TLS_CIPHER_POLICY_1_0        = "tls_cipher_policy_1_0"
@@ -226,6 +256,32 @@ var LB_TLS_CIPHER_POLICIES = choices.NewChoices(
 	LB_TLS_CIPHER_POLICY_deault,
 )
 
+const (
+	LB_TLS_VERSION_1_0 = "TLSv1.0"
+	LB_TLS_VERSION_1_1 = "TLSv1.1"
+	LB_TLS_VERSION_1_2 = "TLSv1.2"
+	LB_TLS_VERSION_1_3 = "TLSv1.3"
+)
+
+var LB_TLS_VERSIONS = choices.NewChoices(
+	LB_TLS_VERSION_1_0,
+	LB_TLS_VERSION_1_1,
+	LB_TLS_VERSION_1_2,
+	LB_TLS_VERSION_1_3,
+)
+
+const (
+	LB_CLIENT_VERIFY_OFF      = "off"
+	LB_CLIENT_VERIFY_OPTIONAL = "optional"
+	LB_CLIENT_VERIFY_REQUIRED = "required"
+)
+
+var LB_CLIENT_VERIFY_CHOICES = choices.NewChoices(
+	LB_CLIENT_VERIFY_OFF,
+	LB_CLIENT_VERIFY_OPTIONAL,
+	LB_CLIENT_VERIFY_REQUIRED,
+)
+
 const (
 	LB_STICKY_SESSION_TYPE_INSERT = "insert"
 	LB_STICKY_SESSION_TYPE_SERVER = "server"
@@ -311,6 +367,45 @@ var LB_REDIRECT_SCHEMES = choices.NewChoices(
 	LB_REDIRECT_SCHEME_HTTPS,
 )
 
+const (
+	LB_RULE_CONDITION_HTTP_HEADER         = "http-header"
+	LB_RULE_CONDITION_PATH_PATTERN        = "path-pattern"
+	LB_RULE_CONDITION_HTTP_REQUEST_METHOD = "http-request-method"
+	LB_RULE_CONDITION_HOST_HEADER         = "host-header"
+	LB_RULE_CONDITION_QUERY_STRING        = "query-string"
+	LB_RULE_CONDITION_SOURCE_IP           = "source-ip"
+)
+
+const (
+	LB_FIXED_RESPONSE_CODE_MIN = int64(200)
+	LB_FIXED_RESPONSE_CODE_MAX = int64(599)
+
+	LB_FIXED_RESPONSE_BODY_MAX_LEN = 1024
+)
+
+var LB_FIXED_RESPONSE_CONTENT_TYPES = choices.NewChoices(
+	"text/plain",
+	"text/css",
+	"text/html",
+	"application/javascript",
+	"application/json",
+)
+
+const (
+	LB_ACCESS_LOG_SAMPLE_RATE_MIN = int64(1)
+	LB_ACCESS_LOG_SAMPLE_RATE_MAX = int64(100)
+
+	LB_ACCESS_LOG_SINK_LOGGER = "logger"
+	LB_ACCESS_LOG_SINK_S3     = "s3"
+	LB_ACCESS_LOG_SINK_SYSLOG = "syslog"
+)
+
+var LB_ACCESS_LOG_SINKS = choices.NewChoices(
+	LB_ACCESS_LOG_SINK_LOGGER,
+	LB_ACCESS_LOG_SINK_S3,
+	LB_ACCESS_LOG_SINK_SYSLOG,
+)
+
 const (
 	LB_BOOL_ON  = "on"
 	LB_BOOL_OFF = "off"
@@ -425,6 +520,33 @@ var LB_HA_STATES = choices.NewChoices(
 	LB_HA_STATE_UNKNOWN,
 )
 
+const (
+	LB_DATA_PLANE_HAPROXY = "haproxy"
+	LB_DATA_PLANE_ENVOY   = "envoy"
+)
+
+var LB_DATA_PLANES = choices.NewChoices(
+	LB_DATA_PLANE_HAPROXY,
+	LB_DATA_PLANE_ENVOY,
+)
+
+const (
+	LB_HA_MODE_VRRP = "vrrp"
+	LB_HA_MODE_BGP  = "bgp"
+)
+
+var LB_HA_MODES = choices.NewChoices(
+	LB_HA_MODE_VRRP,
+	LB_HA_MODE_BGP,
+)
+
+const (
+	LB_BGP_ASN_MIN = 1
+	LB_BGP_ASN_MAX = 4294967295
+
+	LB_BGP_HOLD_TIME_DEFAULT = 9
+)
+
 const (
 	LBAGENT_QUERY_ORIG_KEY = "_orig"
 	LBAGENT_QUERY_ORIG_VAL = "lbagent"
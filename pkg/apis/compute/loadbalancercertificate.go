@@ -25,6 +25,9 @@ type LoadbalancerCertificateDetails struct {
 	IsComplete bool `json:"is_complete"`
 }
 
+type LoadbalancerCertificateRenewInput struct {
+}
+
 type LoadbalancerCertificateResourceInfo struct {
 	// 负载均衡证书名称
 	Certificate string `json:"certificate"`
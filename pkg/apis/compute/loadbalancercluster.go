@@ -14,7 +14,15 @@
 
 package compute
 
-import "yunion.io/x/onecloud/pkg/apis"
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/apis"
+)
 
 type LoadbalancerClusterDetails struct {
 	apis.StandaloneResourceDetails
@@ -57,3 +65,46 @@ type LoadbalancerClusterFilterListInput struct {
 	// 以负载均衡集群排序
 	OrderByCluster string `json:"order_by_cluster"`
 }
+
+// BGP对端
+type LoadbalancerClusterBgpPeer struct {
+	// 对端IP地址
+	Address string `json:"address"`
+
+	// 对端AS号
+	Asn int64 `json:"asn"`
+}
+
+// ParseLoadbalancerClusterBgpPeers parses bgp peers in the form of
+// "ip:asn,ip:asn"
+func ParseLoadbalancerClusterBgpPeers(s string) ([]LoadbalancerClusterBgpPeer, error) {
+	peers := []LoadbalancerClusterBgpPeer{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		i := strings.LastIndex(part, ":")
+		if i < 0 {
+			return nil, errors.Errorf("peer %q: want ip:asn", part)
+		}
+		addr, asnStr := part[:i], part[i+1:]
+		if ip := net.ParseIP(addr); ip == nil || ip.To4() == nil {
+			return nil, errors.Errorf("peer %q: invalid ipv4 address %q", part, addr)
+		}
+		asn, err := strconv.ParseInt(asnStr, 10, 64)
+		if err != nil || asn < LB_BGP_ASN_MIN || asn > LB_BGP_ASN_MAX {
+			return nil, errors.Errorf("peer %q: invalid asn %q", part, asnStr)
+		}
+		for _, peer := range peers {
+			if peer.Address == addr {
+				return nil, errors.Errorf("peer %q: duplicate address", part)
+			}
+		}
+		peers = append(peers, LoadbalancerClusterBgpPeer{
+			Address: addr,
+			Asn:     asn,
+		})
+	}
+	return peers, nil
+}
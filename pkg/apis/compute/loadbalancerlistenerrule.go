@@ -14,7 +14,12 @@
 
 package compute
 
-import "yunion.io/x/onecloud/pkg/apis"
+import (
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/apis"
+)
 
 type LoadbalancerListenerRuleDetails struct {
 	apis.VirtualResourceDetails
@@ -24,3 +29,54 @@ type LoadbalancerListenerRuleDetails struct {
 
 	BackendGroup string `json:"backend_group"`
 }
+
+type LoadbalancerListenerRuleConditionValues struct {
+	Values []string `json:"values"`
+}
+
+type LoadbalancerListenerRuleHttpHeaderConfig struct {
+	HttpHeaderName string   `json:"HttpHeaderName"`
+	Values         []string `json:"values"`
+}
+
+type LoadbalancerListenerRuleQueryString struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type LoadbalancerListenerRuleQueryStringConfig struct {
+	Values []LoadbalancerListenerRuleQueryString `json:"values"`
+}
+
+// 转发规则匹配条件，同一条件的多个值之间为或关系，多个条件之间为与关系
+// 值中可以使用通配符*和?
+type LoadbalancerListenerRuleCondition struct {
+	// 条件类型
+	// enum: http-header, path-pattern, http-request-method, host-header, query-string, source-ip
+	Field string `json:"field"`
+
+	HttpHeaderConfig        *LoadbalancerListenerRuleHttpHeaderConfig  `json:"httpHeaderConfig"`
+	PathPatternConfig       *LoadbalancerListenerRuleConditionValues   `json:"pathPatternConfig"`
+	HttpRequestMethodConfig *LoadbalancerListenerRuleConditionValues   `json:"httpRequestMethodConfig"`
+	HostHeaderConfig        *LoadbalancerListenerRuleConditionValues   `json:"hostHeaderConfig"`
+	QueryStringConfig       *LoadbalancerListenerRuleQueryStringConfig `json:"queryStringConfig"`
+	SourceIpConfig          *LoadbalancerListenerRuleConditionValues   `json:"sourceIpConfig"`
+}
+
+// ParseLoadbalancerListenerRuleConditions parses condition field of
+// listener rules.  Empty string means no condition
+func ParseLoadbalancerListenerRuleConditions(condition string) ([]LoadbalancerListenerRuleCondition, error) {
+	conds := []LoadbalancerListenerRuleCondition{}
+	if condition == "" {
+		return conds, nil
+	}
+	obj, err := jsonutils.ParseString(condition)
+	if err != nil {
+		return nil, errors.Wrap(err, "ParseString")
+	}
+	err = obj.Unmarshal(&conds)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unmarshal")
+	}
+	return conds, nil
+}
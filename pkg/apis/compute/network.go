@@ -188,6 +188,32 @@ type NetworkCreateInput struct {
 	// example: cn.pool.ntp.org,0.cn.pool.ntp.org
 	GuestNtp string `json:"guest_ntp"`
 
+	// description: ipv6 range of guest, if not set, the network is ipv4 only unless guest_ip6_start,guest_ip6_end and guest_ip6_mask are set
+	// example: fd00:10:168:222::/64
+	GuestIp6Prefix string `json:"guest_ip6_prefix"`
+
+	// description: ipv6 range of guest ip start, if set guest_ip6_prefix, this parameter will be useless
+	// example: fd00:10:168:222::10
+	GuestIp6Start string `json:"guest_ip6_start"`
+
+	// description: ipv6 range of guest ip end, if set guest_ip6_prefix, this parameter will be useless
+	// example: fd00:10:168:222::ffff
+	GuestIp6End string `json:"guest_ip6_end"`
+
+	// description: ipv6 prefix length, if set guest_ip6_prefix, this parameter will be useless
+	// example: 64
+	// maximum: 126
+	// minimum: 48
+	GuestIp6Mask int8 `json:"guest_ip6_mask"`
+
+	// description: ipv6 guest gateway
+	// example: fd00:10:168:222::1
+	GuestGateway6 string `json:"guest_gateway6"`
+
+	// description: ipv6 guest dns
+	// example: 2400:3200::1
+	GuestDns6 string `json:"guest_dns6"`
+
 	// swagger:ignore
 	WireId string `json:"wire_id"`
 
@@ -363,6 +389,17 @@ type NetworkUpdateInput struct {
 
 	GuestDomain string `json:"guest_domain"`
 
+	// IPv6起始地址
+	GuestIp6Start string `json:"guest_ip6_start"`
+	// IPv6结束地址
+	GuestIp6End string `json:"guest_ip6_end"`
+	// IPv6前缀长度
+	GuestIp6Mask *int8 `json:"guest_ip6_mask"`
+	// IPv6网关地址
+	GuestGateway6 string `json:"guest_gateway6"`
+	// IPv6 DNS
+	GuestDns6 string `json:"guest_dns6"`
+
 	VlanId *int `json:"vlan_id"`
 
 	// 分配策略
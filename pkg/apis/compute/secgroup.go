@@ -16,6 +16,7 @@ package compute
 
 import (
 	"fmt"
+	"net"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/pkg/errors"
@@ -129,7 +130,8 @@ func (input *SSecgroupRuleResource) Check() error {
 	}
 
 	if len(input.CIDR) > 0 {
-		if !regutils.MatchCIDR(input.CIDR) && !regutils.MatchIPAddr(input.CIDR) {
+		// both ipv4 and ipv6 cidr or address are allowed
+		if _, _, err := net.ParseCIDR(input.CIDR); err != nil && !regutils.MatchIPAddr(input.CIDR) {
 			return fmt.Errorf("invalid ip address: %s", input.CIDR)
 		}
 	} else {
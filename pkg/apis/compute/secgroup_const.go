@@ -21,3 +21,27 @@ const (
 
 	SECGROUP_DEFAULT_ID = "default"
 )
+
+const (
+	SECGROUP_FLOW_LOG_TRAFFIC_ALL   = "all"   // 记录全部流量
+	SECGROUP_FLOW_LOG_TRAFFIC_ALLOW = "allow" // 仅记录被允许的流量
+	SECGROUP_FLOW_LOG_TRAFFIC_DENY  = "deny"  // 仅记录被拒绝的流量
+
+	SECGROUP_FLOW_LOG_SINK_LOGGER = "logger" // 投递至日志服务
+	SECGROUP_FLOW_LOG_SINK_BUCKET = "bucket" // 投递至对象存储桶
+
+	SECGROUP_FLOW_LOG_SAMPLE_RATE_DEFAULT = 100
+	SECGROUP_FLOW_LOG_SAMPLE_RATE_MAX     = 10000
+)
+
+var (
+	SECGROUP_FLOW_LOG_TRAFFIC_TYPES = []string{
+		SECGROUP_FLOW_LOG_TRAFFIC_ALL,
+		SECGROUP_FLOW_LOG_TRAFFIC_ALLOW,
+		SECGROUP_FLOW_LOG_TRAFFIC_DENY,
+	}
+	SECGROUP_FLOW_LOG_SINKS = []string{
+		SECGROUP_FLOW_LOG_SINK_LOGGER,
+		SECGROUP_FLOW_LOG_SINK_BUCKET,
+	}
+)
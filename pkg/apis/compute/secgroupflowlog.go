@@ -0,0 +1,98 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compute
+
+import "yunion.io/x/onecloud/pkg/apis"
+
+type SecgroupFlowLogCreateInput struct {
+	apis.VirtualResourceCreateInput
+	apis.EnabledBaseResourceCreateInput
+
+	// 记录VPC下所有IP子网的流日志
+	VpcResourceInput
+
+	// 仅记录指定IP子网的流日志，指定后vpc_id可省略
+	NetworkResourceInput
+
+	// 记录的流量类型
+	// enum: all,allow,deny
+	// default: all
+	TrafficType string `json:"traffic_type"`
+
+	// 每台宿主机每秒最多记录的报文数
+	// default: 100
+	SampleRate int `json:"sample_rate"`
+
+	// 流日志投递目标
+	// enum: logger,bucket
+	// default: logger
+	Sink string `json:"sink"`
+
+	// 投递目标为bucket时的存储桶（ID或Name）
+	BucketId string `json:"bucket_id"`
+
+	// 存储桶内对象的前缀
+	Prefix string `json:"prefix"`
+}
+
+type SecgroupFlowLogUpdateInput struct {
+	apis.VirtualResourceBaseUpdateInput
+
+	// 记录的流量类型
+	// enum: all,allow,deny
+	TrafficType string `json:"traffic_type"`
+
+	// 每台宿主机每秒最多记录的报文数
+	SampleRate *int `json:"sample_rate"`
+
+	// 流日志投递目标
+	// enum: logger,bucket
+	Sink string `json:"sink"`
+
+	// 投递目标为bucket时的存储桶（ID或Name）
+	BucketId string `json:"bucket_id"`
+
+	// 存储桶内对象的前缀
+	Prefix *string `json:"prefix"`
+}
+
+type SecgroupFlowLogListInput struct {
+	apis.VirtualResourceListInput
+	apis.EnabledResourceBaseListInput
+
+	VpcFilterListInput
+
+	// 以IP子网过滤流日志
+	NetworkResourceInput
+
+	// 以记录的流量类型过滤
+	TrafficType []string `json:"traffic_type"`
+
+	// 以投递目标过滤
+	Sink []string `json:"sink"`
+}
+
+type SecgroupFlowLogDetails struct {
+	apis.VirtualResourceDetails
+	VpcResourceInfo
+
+	SSecgroupFlowLog
+
+	// IP子网名称
+	Network string `json:"network"`
+
+	// 存储桶名称
+	Bucket string `json:"bucket"`
+}
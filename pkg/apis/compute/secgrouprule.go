@@ -24,4 +24,29 @@ type SecgroupRuleDetails struct {
 
 	ProjectId    string `json:"tenant_id"`
 	PeerSecgroup string `json:"peer_secgroup"`
+
+	// 各宿主机上报的命中报文数之和
+	HitPackets int64 `json:"hit_packets"`
+	// 各宿主机上报的命中字节数之和
+	HitBytes int64 `json:"hit_bytes"`
+}
+
+// SecgroupRuleStat 单条安全组规则在一台宿主机上的命中计数
+type SecgroupRuleStat struct {
+	// 安全组规则ID
+	RuleId string `json:"rule_id"`
+
+	// 命中的报文数
+	Packets int64 `json:"packets"`
+
+	// 命中的字节数
+	Bytes int64 `json:"bytes"`
+}
+
+type SecgroupRuleReportStatsInput struct {
+	// 上报计数的宿主机ID
+	HostId string `json:"host_id"`
+
+	// 规则计数，未出现的规则视为该宿主机上无计数
+	Stats []SecgroupRuleStat `json:"stats"`
 }
@@ -37,10 +37,14 @@ type StorageCreateInput struct {
 	// | rbd 			| rbd_client_mount_timeout	| 否 		|	120		|单位: 秒	|
 	// | nfs 			| nfs_host					| 是 		|			|网络文件系统主机	|
 	// | nfs 			| nfs_shared_dir			| 是 		|			|网络文件系统共享目录	|
+	// | spdk 			| spdk_lvstore				| 是 		|			|SPDK lvstore名称	|
+	// | spdk 			| spdk_rpc_socket			| 否 		|/var/tmp/spdk.sock	|SPDK JSON-RPC socket	|
+	// | spdk 			| spdk_vhost_dir			| 否 		|/var/tmp	|SPDK vhost-user socket目录	|
 	// local: 本地存储
 	// rbd: ceph块存储, ceph存储创建时仅会检测是否重复创建，不会具体检测认证参数是否合法，只有挂载存储时
 	// 计算节点会验证参数，若挂载失败，宿主机和存储不会关联，可以通过查看存储日志查找挂载失败原因
-	// enum: local, rbd, nfs, gpfs
+	// spdk: 计算节点本地SPDK lvstore, 磁盘以vhost-user-blk方式挂载给虚拟机, 仅能关联一台宿主机
+	// enum: local, rbd, nfs, gpfs, spdk
 	// required: true
 	StorageType string `json:"storage_type"`
 
@@ -90,6 +94,18 @@ type StorageCreateInput struct {
 	// 网络文件系统共享目录, storage_type 为 nfs 时, 此参数必传
 	// example: /nfs_root/
 	NfsSharedDir string `json:"nfs_shared_dir"`
+
+	// SPDK lvstore名称, storage_type 为 spdk 时, 此参数必传
+	// example: lvs0
+	SpdkLvstore string `json:"spdk_lvstore"`
+
+	// SPDK JSON-RPC socket路径
+	// default: /var/tmp/spdk.sock
+	SpdkRpcSocket string `json:"spdk_rpc_socket"`
+
+	// SPDK vhost-user socket所在目录, 需和SPDK启动参数 -S 保持一致
+	// default: /var/tmp
+	SpdkVhostDir string `json:"spdk_vhost_dir"`
 }
 
 type RbdTimeoutInput struct {
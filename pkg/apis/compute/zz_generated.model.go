@@ -701,12 +701,29 @@ type SDnsTrafficPolicy struct {
 	Options     *jsonutils.JSONDict `json:"options"`
 }
 
+// SDnsTsigKey is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SDnsTsigKey.
+type SDnsTsigKey struct {
+	apis.SVirtualResourceBase
+	SDnsZoneResourceBase
+	// 签名算法
+	Algorithm string `json:"algorithm"`
+	// base64编码的密钥
+	Secret string `json:"secret"`
+}
+
 // SDnsZone is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SDnsZone.
 type SDnsZone struct {
 	apis.SEnabledStatusInfrasResourceBase
 	IsDirty  bool                `json:"is_dirty"`
 	ZoneType string              `json:"zone_type"`
 	Options  *jsonutils.JSONDict `json:"options"`
+	// SOA序列号, 解析记录变化时递增
+	Serial int64 `json:"serial"`
+	// 是否启用DNSSEC签名, 仅对region dns应答的私有区域生效
+	DnssecEnabled bool `json:"dnssec_enabled"`
+	// DNSSEC公钥(DNSKEY记录)
+	DnssecKey        string `json:"dnssec_key"`
+	DnssecPrivateKey string `json:"dnssec_private_key"`
 }
 
 // SDnsZoneCache is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SDnsZoneCache.
@@ -719,6 +736,15 @@ type SDnsZoneCache struct {
 	ProductType    string `json:"product_type"`
 }
 
+// SDnsZoneJournal is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SDnsZoneJournal.
+type SDnsZoneJournal struct {
+	apis.SResourceBase
+	SDnsZoneResourceBase
+	Id      int64                `json:"id"`
+	Serial  int64                `json:"serial"`
+	Records *jsonutils.JSONArray `json:"records"`
+}
+
 // SDnsZoneResourceBase is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SDnsZoneResourceBase.
 type SDnsZoneResourceBase struct {
 	DnsZoneId string `json:"dns_zone_id"`
@@ -1108,10 +1134,12 @@ type SGuest struct {
 	BackupHostId string `json:"backup_host_id"`
 	// 迁移或克隆的速度
 	ProgressMbps float64 `json:"progress_mbps"`
-	Vga          string  `json:"vga"`
-	Vdi          string  `json:"vdi"`
-	Machine      string  `json:"machine"`
-	Bios         string  `json:"bios"`
+	// 热迁移进度
+	MigrateProgress *SGuestMigrateProgress `json:"migrate_progress"`
+	Vga             string                 `json:"vga"`
+	Vdi             string                 `json:"vdi"`
+	Machine         string                 `json:"machine"`
+	Bios            string                 `json:"bios"`
 	// 操作系统类型
 	OsType   string `json:"os_type"`
 	FlavorId string `json:"flavor_id"`
@@ -1618,6 +1646,13 @@ type SLoadbalancer struct {
 	LBInfo jsonutils.JSONObject `json:"lb_info"`
 }
 
+// SLoadbalancerAccessLog is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SLoadbalancerAccessLog.
+type SLoadbalancerAccessLog struct {
+	AccessLog bool `json:"access_log"`
+	// 访问日志开启状态
+	AccessLogSampleRate int `json:"access_log_sample_rate"`
+}
+
 // SLoadbalancerAcl is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SLoadbalancerAcl.
 type SLoadbalancerAcl struct {
 	apis.SSharableVirtualResourceBase
@@ -1678,6 +1713,20 @@ type SLoadbalancerAgentParams struct {
 	TelegrafConfTmpl   string `json:"telegraf_conf_tmpl"`
 }
 
+// SLoadbalancerAgentParamsAccessLog is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SLoadbalancerAgentParamsAccessLog.
+type SLoadbalancerAgentParamsAccessLog struct {
+	Sink          string `json:"sink"`
+	BatchSize     int    `json:"batch_size"`
+	FlushInterval int    `json:"flush_interval"`
+	SyslogAddr    string `json:"syslog_addr"`
+	S3Endpoint    string `json:"s3_endpoint"`
+	S3AccessKey   string `json:"s3_access_key"`
+	S3SecretKey   string `json:"s3_secret_key"`
+	S3Bucket      string `json:"s3_bucket"`
+	S3Prefix      string `json:"s3_prefix"`
+	S3UseSsl      bool   `json:"s3_use_ssl"`
+}
+
 // SLoadbalancerAgentParamsHaproxy is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SLoadbalancerAgentParamsHaproxy.
 type SLoadbalancerAgentParamsHaproxy struct {
 	GlobalLog      string `json:"global_log"`
@@ -1744,6 +1793,17 @@ type SLoadbalancerCertificate struct {
 	apis.SSharableVirtualResourceBase
 	apis.SExternalizedResourceBase
 	apis.SCertificateResourceBase
+	// ACME验证方式, 为空表示用户上传的证书
+	// example: http-01
+	AcmeChallenge string `json:"acme_challenge"`
+	// 通过ACME签发证书的域名, 以逗号分隔
+	// example: www.example.com,*.example.com
+	AcmeDomains string `json:"acme_domains"`
+	// 待负载均衡代理响应的http-01验证, token到key authorization的映射
+	AcmeHttpTokens *jsonutils.JSONDict `json:"acme_http_tokens"`
+	// 证书类型, server: 服务端证书; ca: 用于校验客户端或后端服务器证书的CA证书包, 不含私钥
+	// example: server
+	CertificateType string `json:"certificate_type"`
 }
 
 // SLoadbalancerCertificateResourceBase is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SLoadbalancerCertificateResourceBase.
@@ -1757,6 +1817,22 @@ type SLoadbalancerCluster struct {
 	apis.SStandaloneResourceBase
 	SZoneResourceBase
 	SWireResourceBase
+	// 集群内负载均衡代理使用的数据面, haproxy|envoy
+	// example: haproxy
+	DataPlane string `json:"data_plane"`
+	// 集群高可用模式, vrrp|bgp
+	// vrrp模式下地址只在主节点生效; bgp模式下所有健康节点同时宣告地址, 由对端路由器ECMP分担流量
+	// example: vrrp
+	HaMode string `json:"ha_mode"`
+	// bgp模式下本端AS号
+	// example: 65001
+	BgpLocalAsn int64 `json:"bgp_local_asn"`
+	// bgp模式下的对端, 格式为ip:asn, 多个以逗号分隔
+	// example: 192.168.1.1:65000,192.168.1.2:65000
+	BgpPeers string `json:"bgp_peers"`
+	// bgp会话保持时间, 单位秒
+	// example: 9
+	BgpHoldTime int `json:"bgp_hold_time"`
 }
 
 // SLoadbalancerClusterResourceBase is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SLoadbalancerClusterResourceBase.
@@ -1765,6 +1841,15 @@ type SLoadbalancerClusterResourceBase struct {
 	ClusterId string `json:"cluster_id"`
 }
 
+// SLoadbalancerHTTPFixedResponse is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SLoadbalancerHTTPFixedResponse.
+type SLoadbalancerHTTPFixedResponse struct {
+	FixedResponseCode int `json:"fixed_response_code"`
+	// 固定响应HTTP code，0表示不使用固定响应
+	FixedResponseContentType string `json:"fixed_response_content_type"`
+	// 固定响应Content-Type
+	FixedResponseBody string `json:"fixed_response_body"`
+}
+
 // SLoadbalancerHTTPListener is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SLoadbalancerHTTPListener.
 type SLoadbalancerHTTPListener struct {
 	StickySession string `json:"sticky_session"`
@@ -1777,6 +1862,8 @@ type SLoadbalancerHTTPListener struct {
 	XForwardedFor              bool `json:"xforwarded_for"`
 	// 获取客户端真实IP
 	Gzip bool `json:"gzip"`
+	// Gzip数据压缩
+	BackendHttp2 bool `json:"backend_http2"`
 }
 
 // SLoadbalancerHTTPRateLimiter is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SLoadbalancerHTTPRateLimiter.
@@ -1799,12 +1886,33 @@ type SLoadbalancerHTTPRedirect struct {
 	RedirectPath string `json:"redirect_path"`
 }
 
+// SLoadbalancerHTTPRewrite is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SLoadbalancerHTTPRewrite.
+type SLoadbalancerHTTPRewrite struct {
+	RewriteHost string `json:"rewrite_host"`
+	// 转发时变更Host
+	RewritePath string `json:"rewrite_path"`
+}
+
 // SLoadbalancerHTTPSListener is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SLoadbalancerHTTPSListener.
 type SLoadbalancerHTTPSListener struct {
 	SLoadbalancerCertificateResourceBase
 	CachedCertificateId string `json:"cached_certificate_id"`
 	TLSCipherPolicy     string `json:"tls_cipher_policy"`
 	EnableHttp2         bool   `json:"enable_http2"`
+	// 最低TLS版本, 设置后覆盖TLSCipherPolicy中的版本要求
+	TLSMinVersion string `json:"tls_min_version"`
+	// 自定义加密套件, 以冒号分隔, 如 ECDHE-RSA-AES128-GCM-SHA256:ECDHE-RSA-AES256-GCM-SHA384
+	TLSCiphers string `json:"tls_ciphers"`
+	// 客户端证书校验 off|optional|required
+	ClientVerify string `json:"client_verify"`
+	// 校验客户端证书所用的CA证书
+	ClientCaCertificateId string `json:"client_ca_certificate_id"`
+	// 是否将客户端证书信息以X-SSL-Client-*请求头传给后端
+	ClientCertHeaders bool `json:"client_cert_headers"`
+	// 是否以https访问后端服务器
+	BackendSsl bool `json:"backend_ssl"`
+	// 校验后端服务器证书所用的CA证书, 为空则不校验
+	BackendCaCertificateId string `json:"backend_ca_certificate_id"`
 }
 
 // SLoadbalancerHealthCheck is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SLoadbalancerHealthCheck.
@@ -1859,6 +1967,7 @@ type SLoadbalancerListener struct {
 	SLoadbalancerHealthCheck
 	SLoadbalancerHTTPRateLimiter
 	SLoadbalancerHTTPRedirect
+	SLoadbalancerAccessLog
 }
 
 // SLoadbalancerListenerResourceBase is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SLoadbalancerListenerResourceBase.
@@ -1878,12 +1987,25 @@ type SLoadbalancerListenerRule struct {
 	Domain         string `json:"domain"`
 	Path           string `json:"path"`
 	Condition      string `json:"condition"`
+	// 按权重转发到多个后端服务器组，用于灰度发布，设置时BackendGroupId为其中第一个
+	BackendGroups *SLoadbalancerListenerRuleBackendGroups `json:"backend_groups"`
 	SLoadbalancerHealthCheck
 	// 目前只有腾讯云HTTP、HTTPS类型的健康检查是和规则绑定的。
 	SLoadbalancerHTTPRateLimiter
 	SLoadbalancerHTTPRedirect
+	SLoadbalancerHTTPRewrite
+	SLoadbalancerHTTPFixedResponse
+}
+
+// SLoadbalancerListenerRuleBackendGroup is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SLoadbalancerListenerRuleBackendGroup.
+type SLoadbalancerListenerRuleBackendGroup struct {
+	BackendGroupId string `json:"backend_group_id"`
+	Weight         int    `json:"weight"`
 }
 
+// SLoadbalancerListenerRuleBackendGroups is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SLoadbalancerListenerRuleBackendGroups.
+type SLoadbalancerListenerRuleBackendGroups []*SLoadbalancerListenerRuleBackendGroup
+
 // SLoadbalancerNetwork is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SLoadbalancerNetwork.
 type SLoadbalancerNetwork struct {
 	apis.SVirtualJointResourceBase
@@ -2420,6 +2542,24 @@ type SSchedtagResourceBase struct {
 	SchedtagId string `json:"schedtag_id"`
 }
 
+// SSecgroupFlowLog is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SSecgroupFlowLog.
+type SSecgroupFlowLog struct {
+	apis.SVirtualResourceBase
+	apis.SEnabledResourceBase
+	SVpcResourceBase
+	SNetworkResourceBase
+	// 记录的流量类型
+	TrafficType string `json:"traffic_type"`
+	// 每台宿主机每秒最多记录的报文数
+	SampleRate int `json:"sample_rate"`
+	// 流日志投递目标
+	Sink string `json:"sink"`
+	// 投递目标为bucket时的存储桶ID
+	BucketId string `json:"bucket_id"`
+	// 存储桶内对象的前缀
+	Prefix string `json:"prefix"`
+}
+
 // SSecurityGroup is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SSecurityGroup.
 type SSecurityGroup struct {
 	apis.SSharableVirtualResourceBase
@@ -2461,6 +2601,15 @@ type SSecurityGroupRule struct {
 	PeerSecgroupId string `json:"peer_secgroup_id"`
 }
 
+// SSecurityGroupRuleStat is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SSecurityGroupRuleStat.
+type SSecurityGroupRuleStat struct {
+	apis.SResourceBase
+	RuleId  string `json:"rule_id"`
+	HostId  string `json:"host_id"`
+	Packets int64  `json:"packets"`
+	Bytes   int64  `json:"bytes"`
+}
+
 // SServerSku is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SServerSku.
 type SServerSku struct {
 	apis.SEnabledStatusStandaloneResourceBase
@@ -38,6 +38,7 @@ const (
 	SERVICE_TYPE_INFLUXDB = "influxdb"
 
 	SERVICE_TYPE_SCHEDULEDTASK = "scheduledtask"
+	SERVICE_TYPE_STACK         = "stack"
 
 	STATUS_UPDATE_TAGS        = "update_tags"
 	STATUS_UPDATE_TAGS_FAILED = "update_tags_fail"
@@ -170,6 +170,14 @@ type ModelBaseListInput struct {
 	ExportKeys string `json:"export_keys" help:"Export field keys"`
 	// 返回结果携带delete_fail_reason和update_fail_reason字段
 	ShowFailReason *bool `json:"show_fail_reason"`
+
+	// 以Server-Sent Events流的方式持续返回满足过滤条件的资源的变更事件
+	Watch *bool `json:"watch"`
+	// 从指定的资源版本之后恢复事件流，也可以通过Last-Event-ID请求头指定
+	ResourceVersion *uint64 `json:"resource_version"`
+	// 事件流的最长持续时间，单位为秒
+	// default: 1800
+	TimeoutSeconds *int `json:"timeout_seconds"`
 }
 
 func (o ModelBaseListInput) GetExportKeys() string {
@@ -46,3 +46,45 @@ type ActionLogListInput struct {
 
 	Success *bool `json:"success"`
 }
+
+type LbAccessLogListInput struct {
+	apis.ModelBaseListInput
+
+	// since
+	Since time.Time `json:"since"`
+	// until
+	Until time.Time `json:"until"`
+	// 负载均衡实例ID
+	LoadbalancerId []string `json:"loadbalancer_id"`
+	// 负载均衡监听ID
+	ListenerId []string `json:"listener_id"`
+	// 客户端IP
+	ClientIp []string `json:"client_ip"`
+	// HTTP状态码
+	Status []int `json:"status"`
+}
+
+type SecgroupFlowLogRecordListInput struct {
+	apis.ModelBaseListInput
+
+	// since
+	Since time.Time `json:"since"`
+	// until
+	Until time.Time `json:"until"`
+	// 安全组流日志ID
+	FlowLogId []string `json:"flow_log_id"`
+	// VPC ID
+	VpcId []string `json:"vpc_id"`
+	// IP子网ID
+	NetworkId []string `json:"network_id"`
+	// 虚拟机ID
+	GuestId []string `json:"guest_id"`
+	// 安全组规则ID
+	RuleId []string `json:"rule_id"`
+	// 动作
+	Action []string `json:"action"`
+	// 源地址
+	SrcIp []string `json:"src_ip"`
+	// 目的地址
+	DstIp []string `json:"dst_ip"`
+}
@@ -0,0 +1,66 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"time"
+)
+
+// LbAccessLogEntry 负载均衡监听的单条访问日志
+type LbAccessLogEntry struct {
+	// 请求时间
+	Timestamp time.Time `json:"timestamp"`
+
+	// 负载均衡实例ID
+	LoadbalancerId string `json:"loadbalancer_id"`
+	// 负载均衡监听ID
+	ListenerId string `json:"listener_id"`
+	// 监听所属项目ID
+	OwnerTenantId string `json:"owner_tenant_id"`
+	// 监听所属域ID
+	OwnerDomainId string `json:"owner_domain_id"`
+
+	// 客户端IP
+	ClientIp string `json:"client_ip"`
+	// 客户端端口
+	ClientPort int `json:"client_port"`
+
+	// HTTP方法，TCP监听为空
+	Method string `json:"method"`
+	// HTTP Host头，TCP监听为空
+	Host string `json:"host"`
+	// HTTP请求URI，TCP监听为空
+	Uri string `json:"uri"`
+	// HTTP状态码，TCP监听为0
+	Status int `json:"status"`
+
+	// 客户端上传字节数
+	BytesIn int64 `json:"bytes_in"`
+	// 返回客户端字节数
+	BytesOut int64 `json:"bytes_out"`
+	// 请求总耗时，单位毫秒
+	Duration int64 `json:"duration"`
+
+	// 后端服务器组ID
+	BackendGroupId string `json:"backend_group_id"`
+	// 后端服务器ID
+	BackendId string `json:"backend_id"`
+	// 连接结束状态
+	TerminationState string `json:"termination_state"`
+}
+
+type LbAccessLogUploadInput struct {
+	Logs []LbAccessLogEntry `json:"logs"`
+}
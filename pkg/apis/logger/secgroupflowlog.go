@@ -0,0 +1,65 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"time"
+)
+
+// SecgroupFlowLogEntry 安全组流日志的单条记录，对应一个被采样的报文
+type SecgroupFlowLogEntry struct {
+	// 报文时间
+	Timestamp time.Time `json:"timestamp"`
+
+	// 安全组流日志ID
+	FlowLogId string `json:"flow_log_id"`
+	// 流日志所属项目ID
+	OwnerTenantId string `json:"owner_tenant_id"`
+	// 流日志所属域ID
+	OwnerDomainId string `json:"owner_domain_id"`
+
+	// VPC ID
+	VpcId string `json:"vpc_id"`
+	// IP子网ID
+	NetworkId string `json:"network_id"`
+	// 虚拟机ID
+	GuestId string `json:"guest_id"`
+	// 宿主机ID
+	HostId string `json:"host_id"`
+
+	// 命中的安全组规则ID，为空表示命中默认规则
+	RuleId string `json:"rule_id"`
+	// 方向
+	// enum: in,out
+	Direction string `json:"direction"`
+	// 动作
+	// enum: allow,deny
+	Action string `json:"action"`
+
+	// 协议，如tcp, udp, icmp
+	Protocol string `json:"protocol"`
+	// 源地址
+	SrcIp string `json:"src_ip"`
+	// 源端口
+	SrcPort int `json:"src_port"`
+	// 目的地址
+	DstIp string `json:"dst_ip"`
+	// 目的端口
+	DstPort int `json:"dst_port"`
+}
+
+type SecgroupFlowLogUploadInput struct {
+	Logs []SecgroupFlowLogEntry `json:"logs"`
+}
@@ -0,0 +1,49 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import "yunion.io/x/onecloud/pkg/apis"
+
+const (
+	SERVICE_TYPE = apis.SERVICE_TYPE_STACK
+
+	STACK_STATUS_INIT            = "init"
+	STACK_STATUS_APPLYING        = "applying"
+	STACK_STATUS_APPLY_FAILED    = "apply_failed"
+	STACK_STATUS_ROLLBACK_FAILED = "rollback_failed"
+	STACK_STATUS_READY           = "ready"
+	STACK_STATUS_DETECT_DRIFT    = "detect_drift"
+	STACK_STATUS_DELETING        = "deleting"
+	STACK_STATUS_DELETE_FAILED   = "delete_failed"
+
+	STACK_RESOURCE_STATUS_READY         = "ready"
+	STACK_RESOURCE_STATUS_DELETE_FAILED = "delete_failed"
+
+	STACK_CHANGE_ACTION_CREATE  = "create"
+	STACK_CHANGE_ACTION_UPDATE  = "update"
+	STACK_CHANGE_ACTION_REPLACE = "replace"
+	STACK_CHANGE_ACTION_DELETE  = "delete"
+	STACK_CHANGE_ACTION_NOOP    = "noop"
+
+	STACK_DRIFT_STATUS_UNKNOWN = "unknown"
+	STACK_DRIFT_STATUS_IN_SYNC = "in_sync"
+	STACK_DRIFT_STATUS_DRIFTED = "drifted"
+	STACK_DRIFT_STATUS_DELETED = "deleted"
+
+	// 模板中引用参数: ${param.<name>}
+	STACK_REF_PARAM = "param"
+	// 模板中引用其他资源属性: ${res.<name>.<attribute>}
+	STACK_REF_RESOURCE = "res"
+)
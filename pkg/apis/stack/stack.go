@@ -0,0 +1,159 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/apis"
+)
+
+// StackTemplate 资源栈模板, 支持 YAML 或 JSON 格式
+type StackTemplate struct {
+	// 模板描述
+	Description string `json:"description"`
+	// 模板参数
+	Parameters map[string]StackTemplateParameter `json:"parameters"`
+	// 模板资源, key 为资源在模板内的名称
+	Resources map[string]StackTemplateResource `json:"resources"`
+	// 模板输出, 可以引用参数及资源属性
+	Outputs map[string]jsonutils.JSONObject `json:"outputs"`
+}
+
+type StackTemplateParameter struct {
+	// 参数类型
+	// enum: string,number,boolean,json
+	Type string `json:"type"`
+	// 参数描述
+	Description string `json:"description"`
+	// 参数默认值, 未指定默认值的参数在创建时必须指定
+	Default jsonutils.JSONObject `json:"default"`
+	// 参数可选值
+	AllowedValues []string `json:"allowed_values"`
+}
+
+type StackTemplateResource struct {
+	// 资源类型, 即 climc 的资源复数名称
+	// example: servers
+	Type string `json:"type"`
+	// 资源创建参数, 字符串中可以通过 ${param.<name>} 引用参数, 通过 ${res.<name>.<attribute>} 引用其他资源的属性
+	Properties *jsonutils.JSONDict `json:"properties"`
+	// 除引用外额外依赖的资源
+	DependsOn []string `json:"depends_on"`
+	// 资源创建或更新后需要等待的状态, 未指定时使用资源类型的默认状态
+	WaitStatus []string `json:"wait_status"`
+}
+
+type StackTemplateInput struct {
+	// 模板内容
+	// required: true
+	Template string `json:"template"`
+	// 模板参数取值
+	Parameters *jsonutils.JSONDict `json:"parameters"`
+}
+
+type StackCreateInput struct {
+	apis.VirtualResourceCreateInput
+	StackTemplateInput
+
+	// 资源所在区域, 为空时使用默认区域
+	Region string `json:"region"`
+}
+
+type StackListInput struct {
+	apis.VirtualResourceListInput
+
+	// 以漂移状态过滤
+	DriftStatus []string `json:"drift_status"`
+}
+
+type StackDetails struct {
+	apis.VirtualResourceDetails
+	SStack
+
+	// 资源数量
+	ResourceCount int `json:"resource_count"`
+}
+
+type StackPreviewInput struct {
+	// 新的模板内容, 为空时使用当前模板
+	Template string `json:"template"`
+	// 新的参数取值, 为空时使用当前参数
+	Parameters *jsonutils.JSONDict `json:"parameters"`
+}
+
+type StackApplyInput StackPreviewInput
+
+type StackPropertyChange struct {
+	Key    string               `json:"key"`
+	Before jsonutils.JSONObject `json:"before"`
+	After  jsonutils.JSONObject `json:"after"`
+	// 取值依赖尚未创建的资源, 在执行时才能确定
+	Computed bool `json:"computed"`
+}
+
+type StackChange struct {
+	// 资源在模板内的名称
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// 变更动作
+	// enum: create,update,replace,delete,noop
+	Action     string `json:"action"`
+	ExternalId string `json:"external_id"`
+	// 发生变化的属性
+	Changes []StackPropertyChange `json:"changes"`
+}
+
+// StackChangeSet 执行前预览的变更集合, 按执行顺序排列
+type StackChangeSet struct {
+	Changes []StackChange `json:"changes"`
+}
+
+type StackDetectDriftInput struct {
+}
+
+type StackResourceDrift struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	ExternalId string `json:"external_id"`
+	// enum: in_sync,drifted,deleted,unknown
+	DriftStatus string `json:"drift_status"`
+	// 与实际状态不一致的属性, Before 为模板期望值, After 为实际值
+	Differences []StackPropertyChange `json:"differences"`
+	Reason      string                `json:"reason"`
+}
+
+type StackDriftOutput struct {
+	DriftStatus string               `json:"drift_status"`
+	Resources   []StackResourceDrift `json:"resources"`
+}
+
+type StackResourceListInput struct {
+	apis.VirtualResourceListInput
+
+	// 所属资源栈
+	Stack string `json:"stack"`
+	// 资源类型
+	ResourceType []string `json:"resource_type"`
+	// 以漂移状态过滤
+	DriftStatus []string `json:"drift_status"`
+}
+
+type StackResourceDetails struct {
+	apis.VirtualResourceDetails
+	SStackResource
+
+	Stack string `json:"stack"`
+}
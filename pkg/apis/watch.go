@@ -0,0 +1,44 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apis
+
+import "yunion.io/x/jsonutils"
+
+const (
+	// 资源被创建，或者变更后开始满足列表过滤条件
+	WATCH_EVENT_CREATE = "create"
+	// 资源被更新
+	WATCH_EVENT_UPDATE = "update"
+	// 资源被删除，或者变更后不再满足列表过滤条件
+	WATCH_EVENT_DELETE = "delete"
+	// 事件流已建立，resource_version为当前的资源版本
+	WATCH_EVENT_SYNC = "sync"
+	// 事件流异常中断，客户端应从最后收到的资源版本恢复
+	WATCH_EVENT_ERROR = "error"
+)
+
+type WatchEvent struct {
+	// 事件类型
+	// enum: create,update,delete,sync,error
+	Type string `json:"type"`
+	// 事件对应的资源版本，用于断线后恢复事件流
+	ResourceVersion uint64 `json:"resource_version"`
+	// 资源ID
+	Id string `json:"id"`
+	// 资源详情，删除事件只包含id和name
+	Object jsonutils.JSONObject `json:"object"`
+	// 错误信息
+	Error string `json:"error"`
+}
@@ -21,6 +21,7 @@ import (
 	"yunion.io/x/pkg/trace"
 
 	"yunion.io/x/onecloud/pkg/i18n"
+	"yunion.io/x/onecloud/pkg/util/tracing"
 )
 
 type AppContextKey string
@@ -178,10 +179,11 @@ type AppContextData struct {
 	TaskNotifyUrl string
 	ServiceName   string
 	Lang          string
+	TraceParent   string
 }
 
 func (self *AppContextData) IsZero() bool {
-	return len(self.TaskNotifyUrl) == 0 && len(self.TaskId) == 0 && len(self.ObjectId) == 0 && len(self.ObjectType) == 0 && len(self.RequestId) == 0 && self.Trace.IsZero() && len(self.ServiceName) == 0
+	return len(self.TaskNotifyUrl) == 0 && len(self.TaskId) == 0 && len(self.ObjectId) == 0 && len(self.ObjectType) == 0 && len(self.RequestId) == 0 && self.Trace.IsZero() && len(self.ServiceName) == 0 && len(self.TraceParent) == 0
 }
 
 func FetchAppContextData(ctx context.Context) AppContextData {
@@ -193,6 +195,7 @@ func FetchAppContextData(ctx context.Context) AppContextData {
 	taskNotifyUrl := AppContextTaskNotifyUrl(ctx)
 	serviceName := AppContextServiceName(ctx)
 	lang := AppContextLang(ctx)
+	traceParent := tracing.SpanContextFromContext(ctx).Traceparent()
 
 	var trace trace.STrace
 	if tracePtr != nil {
@@ -206,6 +209,7 @@ func FetchAppContextData(ctx context.Context) AppContextData {
 		TaskNotifyUrl: taskNotifyUrl,
 		ServiceName:   serviceName,
 		Lang:          lang,
+		TraceParent:   traceParent,
 	}
 }
 
@@ -235,5 +239,8 @@ func (self *AppContextData) GetContext() context.Context {
 	if len(self.Lang) > 0 {
 		ctx = i18n.WithLang(ctx, self.Lang)
 	}
+	if len(self.TraceParent) > 0 {
+		ctx = tracing.ContextWithTraceparent(ctx, self.TraceParent)
+	}
 	return ctx
 }
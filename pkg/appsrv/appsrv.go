@@ -41,6 +41,7 @@ import (
 	"yunion.io/x/onecloud/pkg/i18n"
 	"yunion.io/x/onecloud/pkg/proxy"
 	"yunion.io/x/onecloud/pkg/util/httputils"
+	"yunion.io/x/onecloud/pkg/util/tracing"
 )
 
 type Application struct {
@@ -227,6 +228,7 @@ func (app *Application) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	duration := float64(time.Since(start).Nanoseconds()) / 1000000
 	counter.hit += 1
 	counter.duration += duration
+	observeRequest(app, r.Method, hi, lrw.status, duration/1000)
 	skipLog := false
 	if params != nil {
 		if params.SkipLog {
@@ -293,6 +295,19 @@ func (t *appTask) Run() {
 				}
 			}()
 			t.ctx = context.WithValue(t.ctx, appctx.APP_CONTEXT_KEY_TRACE, span)
+			if !t.appParams.SkipTrace {
+				var otSpan *tracing.SSpan
+				t.ctx, otSpan = tracing.StartSpan(tracing.Extract(t.ctx, t.r.Header), fmt.Sprintf("%s %s", t.r.Method, t.appParams.Name), tracing.SPAN_KIND_SERVER)
+				otSpan.SetAttribute("http.method", t.r.Method)
+				otSpan.SetAttribute("http.target", t.r.URL.Path)
+				otSpan.SetAttribute("request_id", t.rid)
+				defer func() {
+					if t.fw.status > 0 {
+						otSpan.SetHttpStatus(t.fw.status)
+					}
+					otSpan.End()
+				}()
+			}
 			t.hand.handler(t.ctx, &t.fw, t.r)
 		}()
 	} // otherwise, the task has been timeout
@@ -344,7 +359,7 @@ func (app *Application) defaultHandle(w http.ResponseWriter, r *http.Request, ri
 				defer task.cancel()
 			}
 			task.ctx = i18n.WithRequestLang(task.ctx, r)
-			session := hand.workerMan
+			session := hand.FetchWorkerManager(r)
 			if session == nil {
 				if r.Method == "GET" || r.Method == "HEAD" {
 					session = app.readSession
@@ -399,6 +414,7 @@ func (app *Application) addDefaultHandlers() {
 	app.AddDefaultHandler("POST", "/ping", PingHandler, "ping")
 	app.AddDefaultHandler("GET", "/ping", PingHandler, "ping")
 	app.AddDefaultHandler("GET", "/worker_stats", WorkerStatsHandler, "worker_stats")
+	app.AddDefaultHandler("GET", "/metrics", MetricsHandler, "metrics")
 }
 
 func timeoutHandle(h http.Handler) http.HandlerFunc {
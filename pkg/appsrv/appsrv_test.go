@@ -77,6 +77,20 @@ func (suite *ApplicationTestSuit) TestHandler() {
 	assert.True(suite.T(), assert.HTTPBodyContains(suite.T(), app.ServeHTTP, "GET", "/delaypanic", nil, "the handler is delay panic"))
 }
 
+func (suite *ApplicationTestSuit) TestMetrics() {
+	app := suite.app
+	app.AddDefaultHandler("GET", "/metrics", MetricsHandler, "metrics")
+	assert.True(suite.T(), assert.HTTPBodyContains(suite.T(), app.ServeHTTP, "GET", "/delay", nil, "delay pong"))
+	for _, metric := range []string{
+		`appsrv_http_request_duration_seconds_count{code="2XX",handler=`,
+		"appsrv_worker_queue_depth",
+		"appsrv_worker_active",
+		"go_goroutines",
+	} {
+		assert.True(suite.T(), assert.HTTPBodyContains(suite.T(), app.ServeHTTP, "GET", "/metrics", nil, metric))
+	}
+}
+
 func TestApplicationTestSuite(t *testing.T) {
 	suite.Run(t, new(ApplicationTestSuit))
 }
@@ -496,7 +496,7 @@ func (app *Application) CORSAllowHosts(hosts []string) {
 		AllowedOrigins:   hosts,
 		AllowedMethods:   []string{"HEAD", "GET", "POST", "PUT", "PATCH", "DELETE"},
 		AllowedHeaders:   []string{"*"},
-		ExposedHeaders:   []string{"Authorization"},
+		ExposedHeaders:   []string{"Authorization", "ETag"},
 		AllowCredentials: true,
 		// Debug: true,
 	}
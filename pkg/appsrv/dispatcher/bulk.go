@@ -0,0 +1,51 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"context"
+	"net/http"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+func bulkHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	manager, _, query, body := fetchEnv(ctx, w, r)
+	bulkManager, ok := manager.(IBulkModelDispatchHandler)
+	if !ok {
+		httperrors.NotImplementedError(ctx, w, "%s not support bulk operation", manager.KeywordPlural())
+		return
+	}
+	input := &apis.BulkOperationInput{}
+	if body != nil {
+		err := body.Unmarshal(input)
+		if err != nil {
+			httperrors.InputParameterError(ctx, w, "unmarshal bulk input: %v", err)
+			return
+		}
+	}
+	handleIdempotent(ctx, w, r, query, body, func(w http.ResponseWriter) {
+		output, err := bulkManager.Bulk(ctx, query, input)
+		if err != nil {
+			httperrors.GeneralServerError(ctx, w, err)
+			return
+		}
+		appsrv.SendJSON(w, jsonutils.Marshal(output))
+	})
+}
@@ -34,6 +34,7 @@ import (
 func AddModelDispatcher(prefix string, app *appsrv.Application, manager IModelDispatchHandler) {
 	metadata := map[string]interface{}{"manager": manager}
 	tags := map[string]string{"resource": manager.KeywordPlural()}
+	registerOpenAPI(prefix, app, manager)
 	// list
 	h := app.AddHandler2("GET",
 		fmt.Sprintf("%s/%s", prefix, manager.KeywordPlural()),
@@ -89,6 +90,11 @@ func AddModelDispatcher(prefix string, app *appsrv.Application, manager IModelDi
 		manager.CustomizeHandlerInfo(h)
 	}
 
+	// bulk operation
+	h = app.AddHandler2("POST",
+		fmt.Sprintf("%s/%s/bulk", prefix, manager.KeywordPlural()),
+		manager.Filter(bulkHandler), metadata, "bulk", tags)
+	manager.CustomizeHandlerInfo(h)
 	// batchPerformAction
 	h = app.AddHandler2("POST",
 		fmt.Sprintf("%s/%s/<action>", prefix, manager.KeywordPlural()),
@@ -202,6 +208,10 @@ func listHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 }
 
 func handleList(ctx context.Context, w http.ResponseWriter, manager IModelDispatchHandler, ctxIds []SResourceContext, query jsonutils.JSONObject) {
+	if jsonutils.QueryBoolean(query, "watch", false) {
+		handleWatch(ctx, w, manager, ctxIds, query)
+		return
+	}
 	listResult, err := manager.List(ctx, query, ctxIds)
 	if err != nil {
 		httperrors.GeneralServerError(ctx, w, err)
@@ -308,7 +318,10 @@ func writeErrInvalidRequestHeader(ctx context.Context, w http.ResponseWriter, r
 
 func createHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 	manager, params, query, body := fetchEnv(ctx, w, r)
-	handleCreate(ctx, w, manager, nil, mergeQueryParams(params, query), body, r)
+	query = mergeQueryParams(params, query)
+	handleIdempotent(ctx, w, r, query, body, func(w http.ResponseWriter) {
+		handleCreate(ctx, w, manager, nil, query, body, r)
+	})
 }
 
 func handleCreate(ctx context.Context, w http.ResponseWriter, manager IModelDispatchHandler, ctxIds []SResourceContext, query jsonutils.JSONObject, body jsonutils.JSONObject, r *http.Request) {
@@ -356,7 +369,10 @@ func handleCreate(ctx context.Context, w http.ResponseWriter, manager IModelDisp
 func createInContextHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 	manager, params, query, body := fetchEnv(ctx, w, r)
 	ctxIds, ctxKeys := fetchContextIds(appctx.AppContextCurrentRoot(ctx), params)
-	handleCreate(ctx, w, manager, ctxIds, mergeQueryParams(params, query, ctxKeys...), body, r)
+	query = mergeQueryParams(params, query, ctxKeys...)
+	handleIdempotent(ctx, w, r, query, body, func(w http.ResponseWriter) {
+		handleCreate(ctx, w, manager, ctxIds, query, body, r)
+	})
 }
 
 func performClassActionHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
@@ -374,16 +390,19 @@ func performClassActionHandler(ctx context.Context, w http.ResponseWriter, r *ht
 	} else {
 		data = jsonutils.NewDict()
 	}
-	results, err := manager.PerformClassAction(ctx, params["<action>"], mergeQueryParams(params, query, "<action>"), data)
-	if err != nil {
-		httperrors.GeneralServerError(ctx, w, err)
-		return
-	}
-	if results == nil {
-		results = jsonutils.NewDict()
-	}
-	sendJSON(ctx, w, results, manager.KeywordPlural())
-	// appsrv.SendJSON(w, wrapBody(results, manager.KeywordPlural()))
+	query = mergeQueryParams(params, query, "<action>")
+	handleIdempotent(ctx, w, r, query, data, func(w http.ResponseWriter) {
+		results, err := manager.PerformClassAction(ctx, params["<action>"], query, data)
+		if err != nil {
+			httperrors.GeneralServerError(ctx, w, err)
+			return
+		}
+		if results == nil {
+			results = jsonutils.NewDict()
+		}
+		sendJSON(ctx, w, results, manager.KeywordPlural())
+		// appsrv.SendJSON(w, wrapBody(results, manager.KeywordPlural()))
+	})
 }
 
 func performActionHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
@@ -401,13 +420,16 @@ func performActionHandler(ctx context.Context, w http.ResponseWriter, r *http.Re
 	} else {
 		data = jsonutils.NewDict()
 	}
-	result, err := manager.PerformAction(ctx, params["<resid>"], params["<action>"], mergeQueryParams(params, query, "<resid>", "<action>"), data)
-	if err != nil {
-		httperrors.GeneralServerError(ctx, w, err)
-		return
-	}
-	sendJSON(ctx, w, result, manager.Keyword())
-	// appsrv.SendJSON(w, wrapBody(result, manager.Keyword()))
+	query = mergeQueryParams(params, query, "<resid>", "<action>")
+	handleIdempotent(ctx, w, r, query, data, func(w http.ResponseWriter) {
+		result, err := manager.PerformAction(ctx, params["<resid>"], params["<action>"], query, data)
+		if err != nil {
+			httperrors.GeneralServerError(ctx, w, err)
+			return
+		}
+		sendJSON(ctx, w, result, manager.Keyword())
+		// appsrv.SendJSON(w, wrapBody(result, manager.Keyword()))
+	})
 }
 
 func updateHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
@@ -20,8 +20,10 @@ import (
 
 	"yunion.io/x/jsonutils"
 
+	"yunion.io/x/onecloud/pkg/apis"
 	"yunion.io/x/onecloud/pkg/appsrv"
 	"yunion.io/x/onecloud/pkg/mcclient/modulebase"
+	"yunion.io/x/onecloud/pkg/util/openapi"
 )
 
 type IMiddlewareFilter interface {
@@ -63,6 +65,27 @@ type IModelDispatchHandler interface {
 	FetchUpdateHeaderData(ctx context.Context, header http.Header) (jsonutils.JSONObject, error)
 }
 
+// IWatchModelDispatchHandler is implemented by dispatch handlers supporting
+// the ?watch=true streaming mode of list endpoints. Watch blocks and calls
+// send for each event until ctx is done or an error occurs
+type IWatchModelDispatchHandler interface {
+	Watch(ctx context.Context, query jsonutils.JSONObject, ctxIds []SResourceContext, send func(event *apis.WatchEvent) error) error
+}
+
+// IBulkModelDispatchHandler is implemented by dispatch handlers supporting
+// POST /<resources>/bulk, which applies one operation to a list of resources
+// and reports the result of each of them
+type IBulkModelDispatchHandler interface {
+	Bulk(ctx context.Context, query jsonutils.JSONObject, input *apis.BulkOperationInput) (*apis.BulkOperationOutput, error)
+}
+
+// IOpenAPIModelDispatchHandler is implemented by dispatch handlers able to
+// describe their endpoints in the OpenAPI document served at /openapi.json,
+// schemas of the request and response bodies are registered to gen
+type IOpenAPIModelDispatchHandler interface {
+	OpenAPIPaths(prefix string, gen *openapi.SSchemaGenerator) map[string]*openapi.SPathItem
+}
+
 type IJointModelDispatchHandler interface {
 	IMiddlewareFilter
 
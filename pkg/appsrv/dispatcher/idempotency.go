@@ -0,0 +1,149 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+)
+
+const (
+	IDEMPOTENCY_KEY_HEADER      = httputils.IDEMPOTENCY_KEY_HEADER
+	IDEMPOTENCY_REPLAYED_HEADER = "Idempotent-Replayed"
+
+	IDEMPOTENCY_KEY_MAX_LENGTH = 255
+)
+
+// SIdempotentResponse is the response stored for an idempotency key
+type SIdempotentResponse struct {
+	StatusCode int
+	Body       string
+}
+
+type IIdempotencyStore interface {
+	// Begin claims the key for the request identified by fingerprint, returning the stored
+	// response if the request has been completed, or an error if the key is in use by an
+	// ongoing request or by a request of different fingerprint
+	Begin(ctx context.Context, key string, fingerprint string) (*SIdempotentResponse, error)
+	// Finish stores the response of the request
+	Finish(ctx context.Context, key string, resp *SIdempotentResponse) error
+	// Abort releases the key so that the request can be retried
+	Abort(ctx context.Context, key string) error
+}
+
+var idempotencyStore IIdempotencyStore
+
+func SetIdempotencyStore(store IIdempotencyStore) {
+	idempotencyStore = store
+}
+
+// requestFingerprint digests what identifies a request besides its idempotency key
+func requestFingerprint(r *http.Request, query jsonutils.JSONObject, body jsonutils.JSONObject) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", r.Method, r.URL.Path)
+	if query != nil {
+		fmt.Fprintf(h, "%s\n", query.String())
+	}
+	if body != nil {
+		fmt.Fprintf(h, "%s\n", body.String())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type sResponseRecorder struct {
+	http.ResponseWriter
+
+	status int
+	body   bytes.Buffer
+}
+
+func (w *sResponseRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *sResponseRecorder) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// handleIdempotent runs handler at most once for requests carrying the same
+// Idempotency-Key header, replays of a completed request get the stored response
+func handleIdempotent(ctx context.Context, w http.ResponseWriter, r *http.Request, query jsonutils.JSONObject, body jsonutils.JSONObject, handler func(w http.ResponseWriter)) {
+	key := r.Header.Get(IDEMPOTENCY_KEY_HEADER)
+	if len(key) == 0 || idempotencyStore == nil {
+		handler(w)
+		return
+	}
+	if len(key) > IDEMPOTENCY_KEY_MAX_LENGTH {
+		httperrors.InputParameterError(ctx, w, "%s header too long, at most %d characters", IDEMPOTENCY_KEY_HEADER, IDEMPOTENCY_KEY_MAX_LENGTH)
+		return
+	}
+	resp, err := idempotencyStore.Begin(ctx, key, requestFingerprint(r, query, body))
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	if resp != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(resp.Body)))
+		w.Header().Set(IDEMPOTENCY_REPLAYED_HEADER, "true")
+		w.WriteHeader(resp.StatusCode)
+		w.Write([]byte(resp.Body))
+		return
+	}
+
+	recorder := &sResponseRecorder{ResponseWriter: w}
+	finished := false
+	defer func() {
+		if finished {
+			return
+		}
+		// failed requests and panics release the key so that clients can retry
+		if err := idempotencyStore.Abort(ctx, key); err != nil {
+			log.Errorf("abort idempotency key %s: %v", key, err)
+		}
+	}()
+	handler(recorder)
+	if recorder.status >= 200 && recorder.status < 300 {
+		// the request has taken effect and must never be run again.  If the
+		// response cannot be saved, the key is left in progress so that
+		// retries get conflict until it expires
+		finished = true
+		err := idempotencyStore.Finish(ctx, key, &SIdempotentResponse{
+			StatusCode: recorder.status,
+			Body:       recorder.body.String(),
+		})
+		if err != nil {
+			log.Errorf("save response of idempotency key %s: %v", key, err)
+		}
+	}
+}
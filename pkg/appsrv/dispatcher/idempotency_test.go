@@ -0,0 +1,111 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+type sMemIdempotencyStore struct {
+	fingerprints map[string]string
+	responses    map[string]*SIdempotentResponse
+	finishErr    error
+}
+
+func (s *sMemIdempotencyStore) Begin(ctx context.Context, key string, fingerprint string) (*SIdempotentResponse, error) {
+	if fp, ok := s.fingerprints[key]; ok {
+		if fp != fingerprint {
+			return nil, httperrors.NewInputParameterError("key reused")
+		}
+		if resp, ok := s.responses[key]; ok {
+			return resp, nil
+		}
+		return nil, httperrors.NewConflictError("in progress")
+	}
+	s.fingerprints[key] = fingerprint
+	return nil, nil
+}
+
+func (s *sMemIdempotencyStore) Finish(ctx context.Context, key string, resp *SIdempotentResponse) error {
+	if s.finishErr != nil {
+		return s.finishErr
+	}
+	s.responses[key] = resp
+	return nil
+}
+
+func (s *sMemIdempotencyStore) Abort(ctx context.Context, key string) error {
+	delete(s.fingerprints, key)
+	return nil
+}
+
+func TestHandleIdempotent(t *testing.T) {
+	store := &sMemIdempotencyStore{
+		fingerprints: map[string]string{},
+		responses:    map[string]*SIdempotentResponse{},
+	}
+	SetIdempotencyStore(store)
+	defer SetIdempotencyStore(nil)
+
+	calls := 0
+	do := func(key string, body jsonutils.JSONObject, status int) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/servers", nil)
+		r.Header.Set(IDEMPOTENCY_KEY_HEADER, key)
+		w := httptest.NewRecorder()
+		handleIdempotent(context.Background(), w, r, nil, body, func(w http.ResponseWriter) {
+			calls += 1
+			w.WriteHeader(status)
+			fmt.Fprintf(w, `{"call":%d}`, calls)
+		})
+		return w
+	}
+
+	body := jsonutils.Marshal(map[string]string{"name": "vm1"})
+	w := do("k1", body, http.StatusOK)
+	if calls != 1 || w.Body.String() != `{"call":1}` {
+		t.Fatalf("first request: calls %d body %s", calls, w.Body.String())
+	}
+	w = do("k1", body, http.StatusOK)
+	if calls != 1 || w.Body.String() != `{"call":1}` || w.Header().Get(IDEMPOTENCY_REPLAYED_HEADER) != "true" {
+		t.Errorf("replay should return stored response: calls %d body %s", calls, w.Body.String())
+	}
+	w = do("k1", jsonutils.Marshal(map[string]string{"name": "vm2"}), http.StatusOK)
+	if calls != 1 || w.Code != http.StatusBadRequest {
+		t.Errorf("mismatched payload should be rejected: calls %d code %d", calls, w.Code)
+	}
+
+	// failed requests release the key
+	do("k2", body, http.StatusInternalServerError)
+	w = do("k2", body, http.StatusOK)
+	if calls != 3 || w.Code != http.StatusOK {
+		t.Errorf("retry after failure should run the handler: calls %d code %d", calls, w.Code)
+	}
+
+	// succeeded requests keep the key even if the response cannot be saved
+	store.finishErr = fmt.Errorf("db gone")
+	do("k3", body, http.StatusOK)
+	w = do("k3", body, http.StatusOK)
+	if calls != 4 || w.Code != http.StatusConflict {
+		t.Errorf("retry after unsaved success should conflict: calls %d code %d", calls, w.Code)
+	}
+}
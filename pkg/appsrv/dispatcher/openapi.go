@@ -0,0 +1,110 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/util/version"
+
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/util/openapi"
+)
+
+const (
+	OPENAPI_PATH = "/openapi.json"
+)
+
+type sOpenAPIManager struct {
+	prefix  string
+	manager IModelDispatchHandler
+}
+
+// sOpenAPIRegistry collects the model dispatchers of an application, the
+// document is generated on the first request after the registration changes
+type sOpenAPIRegistry struct {
+	app      *appsrv.Application
+	lock     sync.Mutex
+	managers []sOpenAPIManager
+	document jsonutils.JSONObject
+}
+
+var (
+	openAPIRegistryLock sync.Mutex
+	openAPIRegistries   = make(map[*appsrv.Application]*sOpenAPIRegistry)
+)
+
+func registerOpenAPI(prefix string, app *appsrv.Application, manager IModelDispatchHandler) {
+	openAPIRegistryLock.Lock()
+	defer openAPIRegistryLock.Unlock()
+
+	registry, ok := openAPIRegistries[app]
+	if !ok {
+		registry = &sOpenAPIRegistry{app: app}
+		openAPIRegistries[app] = registry
+		app.AddDefaultHandler("GET", OPENAPI_PATH, registry.handler, "openapi")
+	}
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	registry.managers = append(registry.managers, sOpenAPIManager{prefix: prefix, manager: manager})
+	registry.document = nil
+}
+
+func (registry *sOpenAPIRegistry) generate() *openapi.SDocument {
+	doc := openapi.NewDocument(registry.app.GetName(), version.GetShortString())
+	gen := openapi.NewSchemaGenerator()
+	managers := make([]sOpenAPIManager, len(registry.managers))
+	copy(managers, registry.managers)
+	sort.SliceStable(managers, func(i, j int) bool {
+		return managers[i].manager.KeywordPlural() < managers[j].manager.KeywordPlural()
+	})
+	for _, m := range managers {
+		if h, ok := m.manager.(IOpenAPIModelDispatchHandler); ok {
+			doc.AddPaths(h.OpenAPIPaths(m.prefix, gen))
+		}
+	}
+	doc.Components.Schemas = gen.Schemas()
+	return doc
+}
+
+// OpenAPIDocument returns the OpenAPI document of the model dispatchers registered
+// to app, or nil if none is registered
+func OpenAPIDocument(app *appsrv.Application) jsonutils.JSONObject {
+	openAPIRegistryLock.Lock()
+	registry, ok := openAPIRegistries[app]
+	openAPIRegistryLock.Unlock()
+	if !ok {
+		return nil
+	}
+	return registry.getDocument()
+}
+
+func (registry *sOpenAPIRegistry) getDocument() jsonutils.JSONObject {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	if registry.document == nil {
+		registry.document = jsonutils.Marshal(registry.generate())
+	}
+	return registry.document
+}
+
+func (registry *sOpenAPIRegistry) handler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	appsrv.SendJSON(w, registry.getDocument())
+}
@@ -0,0 +1,160 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+const (
+	LAST_EVENT_ID_HEADER = "Last-Event-ID"
+
+	WATCH_HEARTBEAT_INTERVAL = 30 * time.Second
+)
+
+// sWatchStream writes watch events as Server-Sent Events, the response header
+// is only sent with the first event so that errors detected before can still
+// be reported with a proper status code
+type sWatchStream struct {
+	w       http.ResponseWriter
+	lock    sync.Mutex
+	started bool
+	closed  bool
+}
+
+func (s *sWatchStream) writeLocked(data string) error {
+	if !s.started {
+		hdr := s.w.Header()
+		hdr.Set("Content-Type", "text/event-stream")
+		hdr.Set("Cache-Control", "no-cache")
+		hdr.Set("X-Accel-Buffering", "no")
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+	_, err := s.w.Write([]byte(data))
+	if err != nil {
+		return err
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+func formatWatchEvent(event *apis.WatchEvent) string {
+	data := ""
+	if event.ResourceVersion > 0 {
+		data += fmt.Sprintf("id: %d\n", event.ResourceVersion)
+	}
+	return data + fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, jsonutils.Marshal(event).String())
+}
+
+func (s *sWatchStream) send(event *apis.WatchEvent) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("watch stream closed")
+	}
+	return s.writeLocked(formatWatchEvent(event))
+}
+
+func (s *sWatchStream) heartbeat() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed || !s.started {
+		return
+	}
+	if err := s.writeLocked(": keepalive\n\n"); err != nil {
+		log.Debugf("watch heartbeat: %v", err)
+	}
+}
+
+func (s *sWatchStream) close(ctx context.Context, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if err == nil {
+		return
+	}
+	if !s.started {
+		httperrors.GeneralServerError(ctx, s.w, err)
+		return
+	}
+	s.writeLocked(formatWatchEvent(&apis.WatchEvent{Type: apis.WATCH_EVENT_ERROR, Error: err.Error()}))
+}
+
+func handleWatch(ctx context.Context, w http.ResponseWriter, manager IModelDispatchHandler, ctxIds []SResourceContext, query jsonutils.JSONObject) {
+	watcher, ok := manager.(IWatchModelDispatchHandler)
+	if !ok {
+		httperrors.NotImplementedError(ctx, w, "%s does not support watch", manager.KeywordPlural())
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	appParams := appsrv.AppContextGetParams(ctx)
+	if appParams != nil && appParams.Request != nil {
+		// EventSource clients resume with the id of the last received event
+		queryDict := query.(*jsonutils.JSONDict)
+		lastId := appParams.Request.Header.Get(LAST_EVENT_ID_HEADER)
+		if len(lastId) > 0 && !queryDict.Contains("resource_version") {
+			queryDict.Set("resource_version", jsonutils.NewString(lastId))
+		}
+		// stop watching as soon as the client goes away
+		reqCtx := appParams.Request.Context()
+		go func() {
+			select {
+			case <-reqCtx.Done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	stream := &sWatchStream{w: w}
+	go func() {
+		ticker := time.NewTicker(WATCH_HEARTBEAT_INTERVAL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				stream.heartbeat()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	err := watcher.Watch(ctx, query, ctxIds, stream.send)
+	stream.close(ctx, err)
+}
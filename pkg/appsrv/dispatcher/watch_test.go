@@ -0,0 +1,75 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+type sFakeWatchManager struct {
+	IModelDispatchHandler
+
+	events []*apis.WatchEvent
+	err    error
+}
+
+func (m *sFakeWatchManager) KeywordPlural() string {
+	return "networks"
+}
+
+func (m *sFakeWatchManager) Watch(ctx context.Context, query jsonutils.JSONObject, ctxIds []SResourceContext, send func(event *apis.WatchEvent) error) error {
+	for _, ev := range m.events {
+		if err := send(ev); err != nil {
+			return err
+		}
+	}
+	return m.err
+}
+
+func TestHandleWatch(t *testing.T) {
+	manager := &sFakeWatchManager{
+		events: []*apis.WatchEvent{
+			{Type: apis.WATCH_EVENT_SYNC, ResourceVersion: 10},
+			{Type: apis.WATCH_EVENT_UPDATE, ResourceVersion: 11, Id: "n1", Object: jsonutils.Marshal(map[string]string{"status": "ready"})},
+		},
+		err: httperrors.NewResourceExpiredError("too slow"),
+	}
+	w := httptest.NewRecorder()
+	handleWatch(context.Background(), w, manager, nil, jsonutils.NewDict())
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("unexpected content type %s", ct)
+	}
+	body := w.Body.String()
+	for _, expect := range []string{"id: 10\nevent: sync\n", "id: 11\nevent: update\ndata: {", "event: error\n"} {
+		if !strings.Contains(body, expect) {
+			t.Errorf("missing %q in %s", expect, body)
+		}
+	}
+
+	manager = &sFakeWatchManager{err: httperrors.NewResourceExpiredError("expired")}
+	w = httptest.NewRecorder()
+	handleWatch(context.Background(), w, manager, nil, jsonutils.NewDict())
+	if w.Code != 410 {
+		t.Errorf("expect status 410 before the stream starts, got %d", w.Code)
+	}
+}
@@ -28,6 +28,8 @@ type handlerRequestCounter struct {
 
 type TProcessTimeoutCallback func(*SHandlerInfo, *http.Request) time.Duration
 
+type TWorkerManagerCallback func(*SHandlerInfo, *http.Request) *SWorkerManager
+
 type SHandlerInfo struct {
 	method     string
 	path       []string
@@ -43,6 +45,8 @@ type SHandlerInfo struct {
 
 	processTimeout         time.Duration
 	processTimeoutCallback TProcessTimeoutCallback
+
+	workerManCallback TWorkerManagerCallback
 }
 
 func (this *SHandlerInfo) FetchProcessTimeout(r *http.Request) time.Duration {
@@ -61,6 +65,17 @@ func (this *SHandlerInfo) SetProcessTimeoutCallback(callback TProcessTimeoutCall
 	this.processTimeoutCallback = callback
 }
 
+// FetchWorkerManager returns the worker manager that should serve the request,
+// nil means the default read or write worker manager of the application
+func (this *SHandlerInfo) FetchWorkerManager(r *http.Request) *SWorkerManager {
+	if this.workerManCallback != nil {
+		if workerMan := this.workerManCallback(this, r); workerMan != nil {
+			return workerMan
+		}
+	}
+	return this.workerMan
+}
+
 func (this *SHandlerInfo) GetName(params map[string]string) string {
 	if len(this.name) > 0 {
 		return this.name
@@ -141,6 +156,11 @@ func (hi *SHandlerInfo) SetWorkerManager(workerMan *SWorkerManager) *SHandlerInf
 	return hi
 }
 
+func (hi *SHandlerInfo) SetWorkerManagerCallback(callback TWorkerManagerCallback) *SHandlerInfo {
+	hi.workerManCallback = callback
+	return hi
+}
+
 func (hi *SHandlerInfo) SetSkipLog(skip bool) *SHandlerInfo {
 	hi.skipLog = skip
 	return hi
@@ -0,0 +1,219 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appsrv
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"yunion.io/x/log"
+)
+
+var (
+	metricsRegistry = prometheus.NewRegistry()
+
+	requestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "appsrv",
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of http requests served by appsrv handlers",
+			Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		},
+		[]string{"service", "method", "handler", "code"},
+	)
+)
+
+func init() {
+	RegisterMetricsCollector(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		requestDuration,
+		&sWorkerCollector{},
+		dbStatsCollector,
+	)
+}
+
+// RegisterMetricsCollector adds collectors to the registry exposed by the
+// /metrics handler of every application in this process
+func RegisterMetricsCollector(collectors ...prometheus.Collector) {
+	for _, c := range collectors {
+		if err := metricsRegistry.Register(c); err != nil {
+			if _, ok := err.(prometheus.AlreadyRegisteredError); ok {
+				continue
+			}
+			log.Errorf("register metrics collector: %v", err)
+		}
+	}
+}
+
+func statusCodeClass(status int) string {
+	return fmt.Sprintf("%dXX", status/100)
+}
+
+func observeRequest(app *Application, method string, hi *SHandlerInfo, status int, seconds float64) {
+	requestDuration.WithLabelValues(app.GetName(), method, hi.GetName(nil), statusCodeClass(status)).Observe(seconds)
+}
+
+func MetricsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{
+		ErrorLog:      metricsErrorLogger{},
+		ErrorHandling: promhttp.ContinueOnError,
+	}).ServeHTTP(w, r)
+}
+
+type metricsErrorLogger struct{}
+
+func (l metricsErrorLogger) Println(v ...interface{}) {
+	log.Errorln(v...)
+}
+
+var (
+	workerQueueDesc = prometheus.NewDesc(
+		"appsrv_worker_queue_depth",
+		"Number of tasks waiting in the queue of a worker manager",
+		[]string{"worker_manager"}, nil,
+	)
+	workerActiveDesc = prometheus.NewDesc(
+		"appsrv_worker_active",
+		"Number of active workers of a worker manager",
+		[]string{"worker_manager"}, nil,
+	)
+	workerDetachedDesc = prometheus.NewDesc(
+		"appsrv_worker_detached",
+		"Number of detached workers of a worker manager",
+		[]string{"worker_manager"}, nil,
+	)
+	workerMaxDesc = prometheus.NewDesc(
+		"appsrv_worker_max",
+		"Maximal number of workers of a worker manager",
+		[]string{"worker_manager"}, nil,
+	)
+)
+
+type sWorkerCollector struct{}
+
+func (c *sWorkerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- workerQueueDesc
+	ch <- workerActiveDesc
+	ch <- workerDetachedDesc
+	ch <- workerMaxDesc
+}
+
+func (c *sWorkerCollector) Collect(ch chan<- prometheus.Metric) {
+	// worker managers are not required to have unique names, sum up the states of the same name
+	names := make([]string, 0)
+	states := make(map[string]*SWorkerManagerStates)
+	for i := 0; i < len(workerManagers); i += 1 {
+		state := workerManagers[i].getState()
+		if total, ok := states[state.Name]; ok {
+			total.QueueCnt += state.QueueCnt
+			total.ActiveWorkerCnt += state.ActiveWorkerCnt
+			total.DetachWorkerCnt += state.DetachWorkerCnt
+			total.MaxWorkerCnt += state.MaxWorkerCnt
+		} else {
+			names = append(names, state.Name)
+			states[state.Name] = &state
+		}
+	}
+	for _, name := range names {
+		state := states[name]
+		ch <- prometheus.MustNewConstMetric(workerQueueDesc, prometheus.GaugeValue, float64(state.QueueCnt), name)
+		ch <- prometheus.MustNewConstMetric(workerActiveDesc, prometheus.GaugeValue, float64(state.ActiveWorkerCnt), name)
+		ch <- prometheus.MustNewConstMetric(workerDetachedDesc, prometheus.GaugeValue, float64(state.DetachWorkerCnt), name)
+		ch <- prometheus.MustNewConstMetric(workerMaxDesc, prometheus.GaugeValue, float64(state.MaxWorkerCnt), name)
+	}
+}
+
+var (
+	dbOpenConnsDesc = prometheus.NewDesc(
+		"db_open_connections",
+		"Number of established connections of a database connection pool, both in use and idle",
+		[]string{"db_name"}, nil,
+	)
+	dbInUseConnsDesc = prometheus.NewDesc(
+		"db_in_use_connections",
+		"Number of connections of a database connection pool currently in use",
+		[]string{"db_name"}, nil,
+	)
+	dbIdleConnsDesc = prometheus.NewDesc(
+		"db_idle_connections",
+		"Number of idle connections of a database connection pool",
+		[]string{"db_name"}, nil,
+	)
+	dbMaxOpenConnsDesc = prometheus.NewDesc(
+		"db_max_open_connections",
+		"Maximal number of open connections of a database connection pool, 0 means unlimited",
+		[]string{"db_name"}, nil,
+	)
+	dbWaitCountDesc = prometheus.NewDesc(
+		"db_wait_count_total",
+		"Total number of connections waited for",
+		[]string{"db_name"}, nil,
+	)
+	dbWaitDurationDesc = prometheus.NewDesc(
+		"db_wait_duration_seconds_total",
+		"Total time blocked waiting for a new connection",
+		[]string{"db_name"}, nil,
+	)
+)
+
+type sDBStatsCollector struct {
+	lock  sync.Mutex
+	names []string
+	dbs   map[string]*sql.DB
+}
+
+var dbStatsCollector = &sDBStatsCollector{dbs: make(map[string]*sql.DB)}
+
+// RegisterDBStats exposes the connection pool usage of db under the given name
+func RegisterDBStats(name string, db *sql.DB) {
+	dbStatsCollector.lock.Lock()
+	defer dbStatsCollector.lock.Unlock()
+
+	if _, ok := dbStatsCollector.dbs[name]; !ok {
+		dbStatsCollector.names = append(dbStatsCollector.names, name)
+	}
+	dbStatsCollector.dbs[name] = db
+}
+
+func (c *sDBStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dbOpenConnsDesc
+	ch <- dbInUseConnsDesc
+	ch <- dbIdleConnsDesc
+	ch <- dbMaxOpenConnsDesc
+	ch <- dbWaitCountDesc
+	ch <- dbWaitDurationDesc
+}
+
+func (c *sDBStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, name := range c.names {
+		stats := c.dbs[name].Stats()
+		ch <- prometheus.MustNewConstMetric(dbOpenConnsDesc, prometheus.GaugeValue, float64(stats.OpenConnections), name)
+		ch <- prometheus.MustNewConstMetric(dbInUseConnsDesc, prometheus.GaugeValue, float64(stats.InUse), name)
+		ch <- prometheus.MustNewConstMetric(dbIdleConnsDesc, prometheus.GaugeValue, float64(stats.Idle), name)
+		ch <- prometheus.MustNewConstMetric(dbMaxOpenConnsDesc, prometheus.GaugeValue, float64(stats.MaxOpenConnections), name)
+		ch <- prometheus.MustNewConstMetric(dbWaitCountDesc, prometheus.CounterValue, float64(stats.WaitCount), name)
+		ch <- prometheus.MustNewConstMetric(dbWaitDurationDesc, prometheus.CounterValue, stats.WaitDuration.Seconds(), name)
+	}
+}
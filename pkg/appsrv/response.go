@@ -37,6 +37,9 @@ type responseWriterChannel struct {
 	statusChan chan int
 	statusResp chan bool
 
+	// status code written by the handler, 0 if none
+	status int
+
 	isClosed bool
 }
 
@@ -68,6 +71,7 @@ func (w *responseWriterChannel) WriteHeader(status int) {
 	if w.isClosed {
 		return
 	}
+	w.status = status
 	w.statusChan <- status
 	<-w.statusResp
 }
@@ -24,6 +24,7 @@ import (
 	"yunion.io/x/onecloud/pkg/appsrv"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
 	"yunion.io/x/onecloud/pkg/util/seclib2"
+	"yunion.io/x/onecloud/pkg/util/tracing"
 )
 
 func InitApp(options *common_options.BaseOptions, dbAccess bool) *appsrv.Application {
@@ -32,6 +33,15 @@ func InitApp(options *common_options.BaseOptions, dbAccess bool) *appsrv.Applica
 	app := appsrv.NewApplication(options.ApplicationID, options.RequestWorkerCount, dbAccess)
 	app.CORSAllowHosts(options.CorsHosts)
 
+	err := tracing.Init(tracing.SOptions{
+		ServiceName:  options.ApplicationID,
+		OtlpEndpoint: options.OtlpTracesEndpoint,
+		FilePath:     options.TracingExportFile,
+	})
+	if err != nil {
+		log.Errorf("init tracing: %v", err)
+	}
+
 	// app.SetContext(appsrv.APP_CONTEXT_KEY_CACHE, cache)
 	// if dbConn != nil {
 	//	app.SetContext(appsrv.APP_CONTEXT_KEY_DB, dbConn)
@@ -74,5 +84,10 @@ func ServeForeverExtended(app *appsrv.Application, options *common_options.BaseO
 		}
 		sslfile = options.SslKeyfile
 	}
-	app.ListenAndServeTLSWithCleanup2(addr, certfile, sslfile, onStop, isMaster)
+	app.ListenAndServeTLSWithCleanup2(addr, certfile, sslfile, func() {
+		if onStop != nil {
+			onStop()
+		}
+		tracing.Shutdown()
+	}, isMaster)
 }
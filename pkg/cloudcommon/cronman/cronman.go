@@ -78,6 +78,8 @@ type SCronJob struct {
 	Timer    ICronTimer
 	Next     time.Time
 	StartRun bool
+
+	stats sCronJobStats
 }
 
 type CronJobTimerHeap []*SCronJob
@@ -137,6 +139,7 @@ func InitCronJobManager(isDbWorker bool, workerCount int) *SCronJobManager {
 			dataLock: new(sync.Mutex),
 			add:      make(chan struct{}),
 		}
+		appsrv.RegisterMetricsCollector(&sCronJobCollector{manager: manager})
 	}
 	return manager
 }
@@ -391,11 +394,14 @@ func (job *SCronJob) runJob(isStart bool) {
 }
 
 func (job *SCronJob) runJobInWorker(isStart bool) {
+	job.stats.start()
 	defer func() {
-		if r := recover(); r != nil {
+		r := recover()
+		if r != nil {
 			log.Errorf("CronJob task %s run error: %s", job.Name, r)
 			debug.PrintStack()
 		}
+		job.stats.finish(r != nil)
 	}()
 
 	log.Debugf("Cron job: %s started", job.Name)
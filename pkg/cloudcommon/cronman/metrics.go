@@ -0,0 +1,137 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cronman
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type sCronJobStats struct {
+	lock sync.Mutex
+
+	running      bool
+	runs         int64
+	failures     int64
+	lastStart    time.Time
+	lastDuration time.Duration
+	lastFailed   bool
+}
+
+func (s *sCronJobStats) start() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.running = true
+	s.lastStart = time.Now()
+}
+
+func (s *sCronJobStats) finish(failed bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.running = false
+	s.runs += 1
+	if failed {
+		s.failures += 1
+	}
+	s.lastFailed = failed
+	s.lastDuration = time.Since(s.lastStart)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+var (
+	cronJobRunsDesc = prometheus.NewDesc(
+		"cronman_job_runs_total",
+		"Number of finished runs of a cron job",
+		[]string{"job"}, nil,
+	)
+	cronJobFailuresDesc = prometheus.NewDesc(
+		"cronman_job_failures_total",
+		"Number of runs of a cron job that panicked",
+		[]string{"job"}, nil,
+	)
+	cronJobRunningDesc = prometheus.NewDesc(
+		"cronman_job_running",
+		"Whether a cron job is running now",
+		[]string{"job"}, nil,
+	)
+	cronJobLastFailedDesc = prometheus.NewDesc(
+		"cronman_job_last_run_failed",
+		"Whether the last run of a cron job panicked",
+		[]string{"job"}, nil,
+	)
+	cronJobLastStartDesc = prometheus.NewDesc(
+		"cronman_job_last_start_timestamp_seconds",
+		"Unix time the last run of a cron job started",
+		[]string{"job"}, nil,
+	)
+	cronJobLastDurationDesc = prometheus.NewDesc(
+		"cronman_job_last_duration_seconds",
+		"Duration of the last finished run of a cron job",
+		[]string{"job"}, nil,
+	)
+	cronJobNextRunDesc = prometheus.NewDesc(
+		"cronman_job_next_run_timestamp_seconds",
+		"Unix time a cron job is scheduled to run next",
+		[]string{"job"}, nil,
+	)
+)
+
+type sCronJobCollector struct {
+	manager *SCronJobManager
+}
+
+func (c *sCronJobCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cronJobRunsDesc
+	ch <- cronJobFailuresDesc
+	ch <- cronJobRunningDesc
+	ch <- cronJobLastFailedDesc
+	ch <- cronJobLastStartDesc
+	ch <- cronJobLastDurationDesc
+	ch <- cronJobNextRunDesc
+}
+
+func (c *sCronJobCollector) Collect(ch chan<- prometheus.Metric) {
+	c.manager.dataLock.Lock()
+	defer c.manager.dataLock.Unlock()
+
+	for _, job := range c.manager.jobs {
+		if !job.Next.IsZero() {
+			ch <- prometheus.MustNewConstMetric(cronJobNextRunDesc, prometheus.GaugeValue, float64(job.Next.Unix()), job.Name)
+		}
+
+		job.stats.lock.Lock()
+		ch <- prometheus.MustNewConstMetric(cronJobRunsDesc, prometheus.CounterValue, float64(job.stats.runs), job.Name)
+		ch <- prometheus.MustNewConstMetric(cronJobFailuresDesc, prometheus.CounterValue, float64(job.stats.failures), job.Name)
+		ch <- prometheus.MustNewConstMetric(cronJobRunningDesc, prometheus.GaugeValue, boolValue(job.stats.running), job.Name)
+		if !job.stats.lastStart.IsZero() {
+			ch <- prometheus.MustNewConstMetric(cronJobLastStartDesc, prometheus.GaugeValue, float64(job.stats.lastStart.Unix()), job.Name)
+		}
+		if job.stats.runs > 0 {
+			ch <- prometheus.MustNewConstMetric(cronJobLastFailedDesc, prometheus.GaugeValue, boolValue(job.stats.lastFailed), job.Name)
+			ch <- prometheus.MustNewConstMetric(cronJobLastDurationDesc, prometheus.GaugeValue, job.stats.lastDuration.Seconds(), job.Name)
+		}
+		job.stats.lock.Unlock()
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+	"sync"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/cloudcommon/policy"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+)
+
+const (
+	BULK_DEFAULT_CONCURRENCY = 8
+	BULK_MAX_CONCURRENCY     = 32
+	// maximal number of resources a single bulk operation may touch
+	BULK_MAX_ITEMS = 1000
+)
+
+func validateBulkInput(input *apis.BulkOperationInput) error {
+	switch input.Operation {
+	case apis.BULK_OPERATION_PERFORM:
+		if len(input.Action) == 0 {
+			return httperrors.NewMissingParameterError("action")
+		}
+	case apis.BULK_OPERATION_UPDATE:
+		if input.Params == nil {
+			return httperrors.NewMissingParameterError("params")
+		}
+		if _, ok := input.Params.(*jsonutils.JSONDict); !ok {
+			return httperrors.NewInputParameterError("params of update must be a dict")
+		}
+	case apis.BULK_OPERATION_DELETE:
+	case "":
+		return httperrors.NewMissingParameterError("operation")
+	default:
+		return httperrors.NewInputParameterError("unsupported bulk operation %q", input.Operation)
+	}
+	if len(input.Ids) > 0 && input.Filter != nil {
+		return httperrors.NewInputParameterError("ids and filter are mutually exclusive")
+	}
+	if len(input.Ids) == 0 && input.Filter == nil {
+		return httperrors.NewMissingParameterError("ids")
+	}
+	if len(input.Ids) > BULK_MAX_ITEMS {
+		return httperrors.NewOutOfLimitError("too many resources in one bulk operation, max %d", BULK_MAX_ITEMS)
+	}
+	if input.Concurrency <= 0 {
+		input.Concurrency = BULK_DEFAULT_CONCURRENCY
+	} else if input.Concurrency > BULK_MAX_CONCURRENCY {
+		input.Concurrency = BULK_MAX_CONCURRENCY
+	}
+	return nil
+}
+
+// fetchBulkFilterIds returns the ids of the resources that the caller would
+// see when listing with filter, the list RBAC of the caller is applied
+func (dispatcher *DBModelDispatcher) fetchBulkFilterIds(ctx context.Context, filter *jsonutils.JSONDict) ([]string, error) {
+	userCred := fetchUserCredential(ctx)
+	manager := dispatcher.modelManager
+	query := filter.CopyExcludes("limit", "offset", "paging_marker", "export_keys", "details")
+	q := manager.Query()
+	q, err := listItemQueryFiltersRaw(manager, ctx, q, userCred, query, policy.PolicyActionList, true, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "listItemQueryFiltersRaw")
+	}
+	sq := q.SubQuery()
+	rows, err := sq.Query(sq.Field("id")).Limit(BULK_MAX_ITEMS + 1).Rows()
+	if err != nil {
+		return nil, errors.Wrap(err, "Rows")
+	}
+	defer rows.Close()
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "Scan")
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) > BULK_MAX_ITEMS {
+		return nil, httperrors.NewOutOfLimitError("filter matches more than %d resources", BULK_MAX_ITEMS)
+	}
+	return ids, nil
+}
+
+// sBulkItemResponseWriter discards whatever an item writes to the response,
+// headers of the bulk response are left to the bulk request itself
+type sBulkItemResponseWriter struct {
+	header http.Header
+}
+
+func (w *sBulkItemResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *sBulkItemResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *sBulkItemResponseWriter) WriteHeader(statusCode int) {}
+
+// bulkItemContext derives the context of a single item. Each item gets its
+// own context so that object locks are not shared between concurrent items.
+// Items see a copy of the bulk request without If-Match header, which is not
+// meant for every item, nor body, which is the bulk input rather than content
+// uploaded to the item, and write to a response which is discarded
+func bulkItemContext(ctx context.Context) context.Context {
+	appParams := appsrv.AppContextGetParams(ctx)
+	if appParams == nil {
+		return context.WithValue(ctx, appsrv.APP_CONTEXT_KEY_APP_PARAMS, (*appsrv.SAppParams)(nil))
+	}
+	itemParams := *appParams
+	if appParams.Request != nil {
+		itemParams.Request = appParams.Request.Clone(ctx)
+		itemParams.Request.Header.Del(httputils.IF_MATCH_HEADER)
+		itemParams.Request.Body = http.NoBody
+		itemParams.Request.ContentLength = 0
+	}
+	itemParams.Response = &sBulkItemResponseWriter{header: http.Header{}}
+	return context.WithValue(ctx, appsrv.APP_CONTEXT_KEY_APP_PARAMS, &itemParams)
+}
+
+func (dispatcher *DBModelDispatcher) bulkItem(ctx context.Context, id string, query jsonutils.JSONObject, input *apis.BulkOperationInput) (ret jsonutils.JSONObject, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("bulk %s %s %s panic: %v\n%s", input.Operation, dispatcher.modelManager.Keyword(), id, r, debug.Stack())
+			err = httperrors.NewInternalServerError("%v", r)
+		}
+	}()
+
+	params := jsonutils.NewDict()
+	if dict, ok := input.Params.(*jsonutils.JSONDict); ok {
+		params = dict.Copy()
+	}
+	switch input.Operation {
+	case apis.BULK_OPERATION_PERFORM:
+		return dispatcher.PerformAction(ctx, id, input.Action, query, params)
+	case apis.BULK_OPERATION_UPDATE:
+		return dispatcher.Update(ctx, id, query, params, nil)
+	default:
+		itemQuery := query.(*jsonutils.JSONDict).Copy()
+		itemQuery.Update(params)
+		return dispatcher.Delete(ctx, id, itemQuery, params, nil)
+	}
+}
+
+func (dispatcher *DBModelDispatcher) Bulk(ctx context.Context, query jsonutils.JSONObject, input *apis.BulkOperationInput) (*apis.BulkOperationOutput, error) {
+	err := validateBulkInput(input)
+	if err != nil {
+		return nil, err
+	}
+	queryDict, ok := query.(*jsonutils.JSONDict)
+	if !ok {
+		queryDict = jsonutils.NewDict()
+	}
+	ids := input.Ids
+	if input.Filter != nil {
+		ids, err = dispatcher.fetchBulkFilterIds(ctx, input.Filter)
+		if err != nil {
+			return nil, httperrors.NewGeneralError(err)
+		}
+	}
+
+	output := &apis.BulkOperationOutput{
+		Total:   len(ids),
+		Results: make([]apis.BulkOperationResult, len(ids)),
+	}
+	sem := make(chan struct{}, input.Concurrency)
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			result := &output.Results[i]
+			result.Id = ids[i]
+			data, err := dispatcher.bulkItem(bulkItemContext(ctx), ids[i], queryDict.Copy(), input)
+			if err != nil {
+				jsonErr := httperrors.NewGeneralError(err)
+				result.Status = jsonErr.Code
+				result.Data = jsonutils.Marshal(jsonErr)
+				return
+			}
+			result.Status = 200
+			if data == nil {
+				data = jsonutils.NewDict()
+			}
+			result.Data = data
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range output.Results {
+		if output.Results[i].Status < 300 {
+			output.Succeeded += 1
+		} else {
+			output.Failed += 1
+		}
+	}
+	return output, nil
+}
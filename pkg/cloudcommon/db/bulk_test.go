@@ -0,0 +1,93 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+)
+
+func TestValidateBulkInput(t *testing.T) {
+	cases := []struct {
+		input   apis.BulkOperationInput
+		wantErr bool
+	}{
+		{apis.BulkOperationInput{Operation: apis.BULK_OPERATION_PERFORM, Ids: []string{"a"}}, true},
+		{apis.BulkOperationInput{Operation: apis.BULK_OPERATION_PERFORM, Action: "start", Ids: []string{"a"}}, false},
+		{apis.BulkOperationInput{Operation: apis.BULK_OPERATION_UPDATE, Ids: []string{"a"}}, true},
+		{apis.BulkOperationInput{Operation: apis.BULK_OPERATION_UPDATE, Ids: []string{"a"}, Params: jsonutils.NewString("x")}, true},
+		{apis.BulkOperationInput{Operation: apis.BULK_OPERATION_UPDATE, Ids: []string{"a"}, Params: jsonutils.NewDict()}, false},
+		{apis.BulkOperationInput{Operation: apis.BULK_OPERATION_DELETE}, true},
+		{apis.BulkOperationInput{Operation: apis.BULK_OPERATION_DELETE, Ids: []string{"a"}, Filter: jsonutils.NewDict()}, true},
+		{apis.BulkOperationInput{Operation: apis.BULK_OPERATION_DELETE, Filter: jsonutils.NewDict()}, false},
+		{apis.BulkOperationInput{Operation: "rebuild", Ids: []string{"a"}}, true},
+		{apis.BulkOperationInput{Ids: []string{"a"}}, true},
+	}
+	for i, c := range cases {
+		err := validateBulkInput(&c.input)
+		if (err != nil) != c.wantErr {
+			t.Errorf("case %d: want error %v, got %v", i, c.wantErr, err)
+		}
+		if err == nil && c.input.Concurrency != BULK_DEFAULT_CONCURRENCY {
+			t.Errorf("case %d: expect default concurrency, got %d", i, c.input.Concurrency)
+		}
+	}
+
+	input := apis.BulkOperationInput{Operation: apis.BULK_OPERATION_DELETE, Ids: []string{"a"}, Concurrency: 1000}
+	if err := validateBulkInput(&input); err != nil || input.Concurrency != BULK_MAX_CONCURRENCY {
+		t.Errorf("expect concurrency capped to %d, got %d (%v)", BULK_MAX_CONCURRENCY, input.Concurrency, err)
+	}
+}
+
+func TestBulkItemContext(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/servers/bulk", strings.NewReader(`{"operation":"update"}`))
+	r.Header.Set(httputils.IF_MATCH_HEADER, `"1-1600000000"`)
+	appParams := &appsrv.SAppParams{Name: "bulk", Request: r, Response: httptest.NewRecorder()}
+	ctx := context.WithValue(context.Background(), appsrv.APP_CONTEXT_KEY_APP_PARAMS, appParams)
+	itemCtx := bulkItemContext(ctx)
+	if itemCtx == ctx {
+		t.Fatalf("item context must differ from the request context")
+	}
+	itemParams := appsrv.AppContextGetParams(itemCtx)
+	if itemParams == nil || itemParams.Request == nil || itemParams.Response == nil || itemParams.Name != "bulk" {
+		t.Fatalf("unexpected item params %#v", itemParams)
+	}
+	if itemParams.Request.Header.Get(httputils.IF_MATCH_HEADER) != "" {
+		t.Errorf("item request must not carry If-Match header")
+	}
+	if itemParams.Request.ContentLength != 0 {
+		t.Errorf("item request must not carry body of bulk request")
+	}
+	itemParams.Response.Header().Set(httputils.ETAG_HEADER, `"2-1600000000"`)
+	itemParams.Response.WriteHeader(http.StatusNoContent)
+	if appParams.Request.Header.Get(httputils.IF_MATCH_HEADER) == "" {
+		t.Errorf("request params must not be modified")
+	}
+	if rec := appParams.Response.(*httptest.ResponseRecorder); rec.Header().Get(httputils.ETAG_HEADER) != "" || rec.Code != http.StatusOK {
+		t.Errorf("item must not write to the bulk response")
+	}
+	if p := appsrv.AppContextGetParams(bulkItemContext(context.Background())); p != nil {
+		t.Errorf("expect nil params, got %#v", p)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"yunion.io/x/sqlchemy"
 
 	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/appsrv/dispatcher"
 )
 
 const (
@@ -45,6 +46,11 @@ func AppDBInit(app *appsrv.Application) {
 	}
 
 	app.AddDefaultHandler("GET", "/db_stats", DBStatsHandler, "db_stats")
+
+	if GetModelManager(IdempotencyKeyManager.Keyword()) != nil {
+		dispatcher.SetIdempotencyStore(IdempotencyKeyManager)
+		go IdempotencyKeyManager.purgeExpiredLoop()
+	}
 }
 
 func DBStatsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
@@ -973,6 +973,7 @@ func (dispatcher *DBModelDispatcher) Get(ctx context.Context, idStr string, quer
 			appParams.SkipLog = true
 		}
 	}
+	setETagHeader(ctx, model)
 	return getModelItemDetails(dispatcher.modelManager, model, ctx, userCred, query, isHead)
 }
 
@@ -1505,10 +1506,20 @@ func (dispatcher *DBModelDispatcher) PerformAction(ctx context.Context, idStr st
 	lockman.LockObject(ctx, model)
 	defer lockman.ReleaseObject(ctx, model)
 
+	model, err = checkIfMatch(ctx, dispatcher.modelManager, model)
+	if err != nil {
+		return nil, err
+	}
+
 	if err := model.PreCheckPerformAction(ctx, userCred, action, query, data); err != nil {
 		return nil, err
 	}
-	return objectPerformAction(dispatcher, model, reflect.ValueOf(model), ctx, userCred, action, query, data)
+	result, err := objectPerformAction(dispatcher, model, reflect.ValueOf(model), ctx, userCred, action, query, data)
+	if err != nil {
+		return nil, err
+	}
+	refreshETagHeader(ctx, dispatcher.modelManager, model)
+	return result, nil
 }
 
 func objectPerformAction(dispatcher *DBModelDispatcher, model IModel, modelValue reflect.Value, ctx context.Context, userCred mcclient.TokenCredential, action string, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
@@ -1692,7 +1703,17 @@ func (dispatcher *DBModelDispatcher) Update(ctx context.Context, idStr string, q
 		lockman.LockObject(ctx, model)
 		defer lockman.ReleaseObject(ctx, model)
 
-		return updateItem(dispatcher.modelManager, model, ctx, userCred, query, data)
+		model, err = checkIfMatch(ctx, dispatcher.modelManager, model)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := updateItem(dispatcher.modelManager, model, ctx, userCred, query, data)
+		if err != nil {
+			return nil, err
+		}
+		refreshETagHeader(ctx, dispatcher.modelManager, model)
+		return result, nil
 	}
 }
 
@@ -1708,7 +1729,17 @@ func (dispatcher *DBModelDispatcher) UpdateSpec(ctx context.Context, idStr strin
 	lockman.LockObject(ctx, model)
 	defer lockman.ReleaseObject(ctx, model)
 
-	return objectUpdateSpec(dispatcher, model, reflect.ValueOf(model), ctx, userCred, spec, query, data)
+	model, err = checkIfMatch(ctx, dispatcher.modelManager, model)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := objectUpdateSpec(dispatcher, model, reflect.ValueOf(model), ctx, userCred, spec, query, data)
+	if err != nil {
+		return nil, err
+	}
+	refreshETagHeader(ctx, dispatcher.modelManager, model)
+	return result, nil
 }
 
 func objectUpdateSpec(dispatcher *DBModelDispatcher, model IModel, modelValue reflect.Value, ctx context.Context, userCred mcclient.TokenCredential, spec string, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
@@ -0,0 +1,107 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+)
+
+// ModelETag returns the entity tag of a model derived from its update_version
+// and updated_at, or an empty string for models that carry no version
+func ModelETag(model IModel) string {
+	ver := model.GetUpdateVersion()
+	updatedAt := model.GetUpdatedAt()
+	if ver == 0 && updatedAt.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("\"%d-%d\"", ver, updatedAt.Unix())
+}
+
+// matchETag reports whether etag satisfies the value of an If-Match header
+func matchETag(ifMatch string, etag string) bool {
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if len(etag) == 0 {
+			continue
+		}
+		// If-Match uses strong comparison, weak tags never match
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		// tolerate unquoted tags passed from command line
+		if tag == etag || tag == strings.Trim(etag, "\"") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch honors the If-Match header of the current request. It must be
+// called with the object lock held and returns the freshly loaded model that
+// the precondition was evaluated against
+func checkIfMatch(ctx context.Context, manager IModelManager, model IModel) (IModel, error) {
+	appParams := appsrv.AppContextGetParams(ctx)
+	if appParams == nil || appParams.Request == nil {
+		return model, nil
+	}
+	ifMatch := appParams.Request.Header.Get(httputils.IF_MATCH_HEADER)
+	if len(ifMatch) == 0 || len(model.GetId()) == 0 {
+		return model, nil
+	}
+	current, err := FetchById(manager, model.GetId())
+	if err != nil {
+		return nil, httperrors.NewGeneralError(errors.Wrapf(err, "FetchById %s", model.GetId()))
+	}
+	etag := ModelETag(current)
+	if !matchETag(ifMatch, etag) {
+		return nil, httperrors.NewPreconditionFailedError("%s %s has been modified, current etag %s", manager.Keyword(), model.GetId(), etag)
+	}
+	return current, nil
+}
+
+func setETagHeader(ctx context.Context, model IModel) {
+	appParams := appsrv.AppContextGetParams(ctx)
+	if appParams == nil || appParams.Response == nil {
+		return
+	}
+	etag := ModelETag(model)
+	if len(etag) > 0 {
+		appParams.Response.Header().Set(httputils.ETAG_HEADER, etag)
+	}
+}
+
+// refreshETagHeader reloads the model after a modification so that the
+// returned ETag reflects the values actually stored in the database
+func refreshETagHeader(ctx context.Context, manager IModelManager, model IModel) {
+	if appsrv.AppContextGetParams(ctx) == nil || len(model.GetId()) == 0 {
+		return
+	}
+	current, err := FetchById(manager, model.GetId())
+	if err != nil {
+		return
+	}
+	setETagHeader(ctx, current)
+}
@@ -0,0 +1,52 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestETag(t *testing.T) {
+	model := &SResourceBase{}
+	if etag := ModelETag(model); etag != "" {
+		t.Errorf("expect empty etag for unversioned model, got %s", etag)
+	}
+	model.UpdateVersion = 3
+	model.UpdatedAt = time.Unix(1600000000, 0)
+	etag := ModelETag(model)
+	if etag != `"3-1600000000"` {
+		t.Errorf("unexpected etag %s", etag)
+	}
+	cases := []struct {
+		ifMatch string
+		want    bool
+	}{
+		{`"3-1600000000"`, true},
+		{`3-1600000000`, true},
+		{`W/"3-1600000000"`, false},
+		{`"2-1599999999", "3-1600000000"`, true},
+		{`*`, true},
+		{`"2-1599999999"`, false},
+	}
+	for _, c := range cases {
+		if got := matchETag(c.ifMatch, etag); got != c.want {
+			t.Errorf("matchETag(%s) = %v, want %v", c.ifMatch, got, c.want)
+		}
+	}
+	if matchETag(`"0-0"`, "") {
+		t.Errorf("should not match empty etag")
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+
+	"yunion.io/x/onecloud/pkg/appsrv/dispatcher"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+const (
+	IDEMPOTENCY_KEY_STATUS_PROCESSING = "processing"
+	IDEMPOTENCY_KEY_STATUS_COMPLETE   = "complete"
+
+	// ER_DUP_ENTRY
+	mysqlErrDupEntry = 1062
+)
+
+// SIdempotencyKeyManager persists Idempotency-Key headers of create and perform requests
+type SIdempotencyKeyManager struct {
+	SModelBaseManager
+
+	retention time.Duration
+}
+
+var IdempotencyKeyManager *SIdempotencyKeyManager
+
+func init() {
+	IdempotencyKeyManager = &SIdempotencyKeyManager{
+		SModelBaseManager: NewModelBaseManager(SIdempotencyKey{}, "idempotency_keys_tbl", "idempotency_key", "idempotency_keys"),
+		retention:         24 * time.Hour,
+	}
+	IdempotencyKeyManager.SetVirtualObject(IdempotencyKeyManager)
+}
+
+type SIdempotencyKey struct {
+	SModelBase
+
+	// sha256 of the user id and the key
+	Id string `width:"64" charset:"ascii" nullable:"false" primary:"true"`
+
+	Key         string `width:"255" charset:"utf8" nullable:"false"`
+	UserId      string `width:"128" charset:"ascii" nullable:"true"`
+	Fingerprint string `width:"64" charset:"ascii" nullable:"false"`
+	Status      string `width:"16" charset:"ascii" nullable:"false"`
+	StatusCode  int    `nullable:"true"`
+	Response    string `length:"medium" charset:"utf8" nullable:"true"`
+
+	CreatedAt time.Time `nullable:"false" created_at:"true"`
+	ExpiredAt time.Time `nullable:"false" index:"true"`
+}
+
+// SetIdempotencyKeyRetention sets how long responses of idempotent requests are kept for replays
+func SetIdempotencyKeyRetention(retention time.Duration) {
+	if retention > 0 {
+		IdempotencyKeyManager.retention = retention
+	}
+}
+
+func idempotencyKeyId(userCred mcclient.TokenCredential, key string) (string, string) {
+	userId := ""
+	if userCred != nil {
+		userId = userCred.GetUserId()
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%s", userId, key)))
+	return hex.EncodeToString(sum[:]), userId
+}
+
+func (manager *SIdempotencyKeyManager) fetchKey(id string) (*SIdempotencyKey, error) {
+	record := &SIdempotencyKey{}
+	record.SetModelManager(manager, record)
+	err := manager.Query().Equals("id", id).First(record)
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (manager *SIdempotencyKeyManager) deleteKeys(where string, args ...interface{}) (int64, error) {
+	sqlStr := fmt.Sprintf("DELETE FROM `%s` WHERE %s", manager.TableSpec().Name(), where)
+	result, err := manager.TableSpec().GetTableSpec().Database().Exec(sqlStr, args...)
+	if err != nil {
+		return 0, errors.Wrap(err, "Exec")
+	}
+	return result.RowsAffected()
+}
+
+// isDuplicateEntryError tells whether err is caused by violating the
+// primary key, i.e. the key has been inserted by another request
+func isDuplicateEntryError(err error) bool {
+	if err == nil {
+		return false
+	}
+	cause := errors.Cause(err)
+	if cause == sqlchemy.ErrDuplicateEntry {
+		return true
+	}
+	if myErr, ok := cause.(*mysql.MySQLError); ok {
+		return myErr.Number == mysqlErrDupEntry
+	}
+	// sqlite, used by unit tests
+	return strings.Contains(cause.Error(), "UNIQUE constraint failed")
+}
+
+func (manager *SIdempotencyKeyManager) Begin(ctx context.Context, key string, fingerprint string) (*dispatcher.SIdempotentResponse, error) {
+	id, userId := idempotencyKeyId(fetchUserCredential(ctx), key)
+	now := time.Now().UTC()
+	record := &SIdempotencyKey{
+		Id:          id,
+		Key:         key,
+		UserId:      userId,
+		Fingerprint: fingerprint,
+		Status:      IDEMPOTENCY_KEY_STATUS_PROCESSING,
+		ExpiredAt:   now.Add(manager.retention),
+	}
+	record.SetModelManager(manager, record)
+	err := manager.TableSpec().Insert(ctx, record)
+	if err == nil {
+		return nil, nil
+	}
+	if !isDuplicateEntryError(err) {
+		return nil, httperrors.NewInternalServerError("insert idempotency key: %v", err)
+	}
+
+	// the key exists, it's a replay or a conflict
+	existing, err := manager.fetchKey(id)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			// deleted concurrently, let the client retry
+			return nil, httperrors.NewConflictError("request with %s %q is in progress", dispatcher.IDEMPOTENCY_KEY_HEADER, key)
+		}
+		return nil, httperrors.NewInternalServerError("fetch idempotency key: %v", err)
+	}
+	if existing.ExpiredAt.Before(now) {
+		if _, err := manager.deleteKeys("id = ? AND expired_at < ?", id, now); err != nil {
+			return nil, httperrors.NewInternalServerError("delete expired idempotency key: %v", err)
+		}
+		if err := manager.TableSpec().Insert(ctx, record); err != nil {
+			if isDuplicateEntryError(err) {
+				return nil, httperrors.NewConflictError("request with %s %q is in progress", dispatcher.IDEMPOTENCY_KEY_HEADER, key)
+			}
+			return nil, httperrors.NewInternalServerError("insert idempotency key: %v", err)
+		}
+		return nil, nil
+	}
+	if existing.Fingerprint != fingerprint {
+		return nil, httperrors.NewInputParameterError("%s %q has been used by a different request", dispatcher.IDEMPOTENCY_KEY_HEADER, key)
+	}
+	if existing.Status != IDEMPOTENCY_KEY_STATUS_COMPLETE {
+		return nil, httperrors.NewConflictError("request with %s %q is in progress", dispatcher.IDEMPOTENCY_KEY_HEADER, key)
+	}
+	return &dispatcher.SIdempotentResponse{
+		StatusCode: existing.StatusCode,
+		Body:       existing.Response,
+	}, nil
+}
+
+func (manager *SIdempotencyKeyManager) Finish(ctx context.Context, key string, resp *dispatcher.SIdempotentResponse) error {
+	id, _ := idempotencyKeyId(fetchUserCredential(ctx), key)
+	record, err := manager.fetchKey(id)
+	if err != nil {
+		return errors.Wrapf(err, "fetch idempotency key %s", id)
+	}
+	_, err = Update(record, func() error {
+		record.Status = IDEMPOTENCY_KEY_STATUS_COMPLETE
+		record.StatusCode = resp.StatusCode
+		record.Response = resp.Body
+		return nil
+	})
+	return err
+}
+
+func (manager *SIdempotencyKeyManager) Abort(ctx context.Context, key string) error {
+	id, _ := idempotencyKeyId(fetchUserCredential(ctx), key)
+	_, err := manager.deleteKeys("id = ? AND status = ?", id, IDEMPOTENCY_KEY_STATUS_PROCESSING)
+	return err
+}
+
+func (manager *SIdempotencyKeyManager) purgeExpired() {
+	cnt, err := manager.deleteKeys("expired_at < ?", time.Now().UTC())
+	if err != nil {
+		log.Errorf("purge expired idempotency keys: %v", err)
+		return
+	}
+	if cnt > 0 {
+		log.Infof("purged %d expired idempotency keys", cnt)
+	}
+}
+
+// purgeExpiredLoop removes keys whose retention window has passed every hour
+func (manager *SIdempotencyKeyManager) purgeExpiredLoop() {
+	for {
+		manager.purgeExpired()
+		time.Sleep(time.Hour)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/sqlchemy"
+	_ "yunion.io/x/sqlchemy/backends"
+
+	"yunion.io/x/onecloud/pkg/appsrv/dispatcher"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+)
+
+func TestIdempotencyKeyManagerBegin(t *testing.T) {
+	dbConn, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer dbConn.Close()
+	// every connection of in-memory sqlite opens a database of its own
+	dbConn.SetMaxOpenConns(1)
+	sqlchemy.SetDBWithNameBackend(dbConn, sqlchemy.DefaultDB, sqlchemy.SQLiteBackend)
+	defer sqlchemy.CloseDB()
+
+	ctx := context.Background()
+	checkCode := func(err error, code int) {
+		t.Helper()
+		jce, ok := err.(*httputils.JSONClientError)
+		if !ok {
+			t.Fatalf("want JSONClientError with code %d, got %T: %v", code, err, err)
+		}
+		if jce.Code != code {
+			t.Fatalf("want code %d, got %d: %v", code, jce.Code, err)
+		}
+	}
+
+	// insert failures other than duplicate keys are server errors
+	_, err = IdempotencyKeyManager.Begin(ctx, "key", "fp")
+	checkCode(err, 500)
+
+	err = IdempotencyKeyManager.TableSpec().GetTableSpec().Sync()
+	if err != nil {
+		t.Fatalf("sync table: %v", err)
+	}
+	resp, err := IdempotencyKeyManager.Begin(ctx, "key", "fp")
+	if err != nil || resp != nil {
+		t.Fatalf("first request: want nil, nil, got %v, %v", resp, err)
+	}
+	_, err = IdempotencyKeyManager.Begin(ctx, "key", "fp")
+	checkCode(err, 409)
+	_, err = IdempotencyKeyManager.Begin(ctx, "key", "other")
+	checkCode(err, 400)
+
+	err = IdempotencyKeyManager.Finish(ctx, "key", &dispatcher.SIdempotentResponse{StatusCode: 200, Body: `{"id":"x"}`})
+	if err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	resp, err = IdempotencyKeyManager.Begin(ctx, "key", "fp")
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if resp == nil || resp.StatusCode != 200 || resp.Body != `{"id":"x"}` {
+		t.Fatalf("replay: got %#v", resp)
+	}
+}
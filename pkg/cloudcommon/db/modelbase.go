@@ -19,6 +19,8 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"yunion.io/x/jsonutils"
@@ -282,6 +284,9 @@ func (manager *SModelBaseManager) CustomizeHandlerInfo(info *appsrv.SHandlerInfo
 	case "get_splitable_export":
 		info.SetProcessTimeout(time.Minute * 120)
 	}
+	if strings.HasPrefix(info.GetName(nil), "list") {
+		info.SetWorkerManagerCallback(watchWorkerManager)
+	}
 	info.SetProcessTimeoutCallback(manager.GetIModelManager().SetHandlerProcessTimeout)
 }
 
@@ -289,6 +294,11 @@ func (manager *SModelBaseManager) SetHandlerProcessTimeout(info *appsrv.SHandler
 	if r.Method == http.MethodGet && len(r.URL.Query().Get("export_keys")) > 0 {
 		return time.Hour * 2
 	}
+	if isWatchRequest(r) {
+		// leave the watch some time to close the stream gracefully
+		seconds, _ := strconv.ParseInt(r.URL.Query().Get("timeout_seconds"), 10, 64)
+		return watchTimeout(seconds) + time.Minute
+	}
 	return -time.Second
 }
 
@@ -162,6 +162,10 @@ func CheckSync(autoSync bool, enableChecksumTables bool, skipInitChecksum bool)
 func EnsureAppSyncDB(app *appsrv.Application, opt *common_options.DBOptions, modelInitDBFunc func() error) {
 	// cloudcommon.InitDB(opt)
 
+	if GetModelManager(IdempotencyKeyManager.Keyword()) == nil {
+		RegisterModelManager(IdempotencyKeyManager)
+	}
+
 	if !CheckSync(opt.AutoSyncTable, opt.EnableDBChecksumTables, opt.DBChecksumSkipInit) {
 		log.Fatalf("database schema not in sync!")
 	}
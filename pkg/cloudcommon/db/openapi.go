@@ -0,0 +1,222 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"yunion.io/x/pkg/utils"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/openapi"
+)
+
+var (
+	contextType         = reflect.TypeOf((*context.Context)(nil)).Elem()
+	tokenCredentialType = reflect.TypeOf((*mcclient.TokenCredential)(nil)).Elem()
+)
+
+// sOpenAPIMethod is a reflected Perform<Action> or GetDetails<Spec> method
+type sOpenAPIMethod struct {
+	spec   string
+	input  reflect.Type
+	output reflect.Type
+}
+
+// funcInput returns the type of the idx-th input of method fName of val, or
+// nil if the method does not exist or takes less inputs
+func funcInput(val reflect.Value, fName string, idx int) reflect.Type {
+	funcVal, err := findFunc(val, fName)
+	if err != nil || !funcVal.IsValid() {
+		return nil
+	}
+	if funcVal.Type().NumIn() <= idx {
+		return nil
+	}
+	return funcVal.Type().In(idx)
+}
+
+// fetchOpenAPIMethods lists the methods of t with the given prefix and the
+// signature (ctx, userCred, [query,] input) (output, error) that the
+// dispatcher calls by reflection
+func fetchOpenAPIMethods(t reflect.Type, prefix string, numIn int) []sOpenAPIMethod {
+	ret := make([]sOpenAPIMethod, 0)
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		if !strings.HasPrefix(m.Name, prefix) || len(m.Name) == len(prefix) {
+			continue
+		}
+		// the receiver is the first input of a method of a type
+		ft := m.Type
+		if ft.NumIn() != numIn+1 || ft.NumOut() != 2 {
+			continue
+		}
+		if ft.In(1) != contextType || ft.In(2) != tokenCredentialType {
+			continue
+		}
+		ret = append(ret, sOpenAPIMethod{
+			spec:   utils.CamelSplit(m.Name[len(prefix):], "-"),
+			input:  ft.In(numIn),
+			output: ft.Out(0),
+		})
+	}
+	return ret
+}
+
+func openAPIOperation(tag, opId, summary string) *openapi.SOperation {
+	return &openapi.SOperation{
+		OperationId: strings.Replace(opId, "-", "_", -1),
+		Summary:     summary,
+		Tags:        []string{tag},
+		Responses:   make(map[string]*openapi.SResponse),
+	}
+}
+
+func openAPIResponses(op *openapi.SOperation, errSchema, schema *openapi.SSchema) *openapi.SOperation {
+	resp := &openapi.SResponse{Description: "OK"}
+	if schema != nil {
+		resp.Content = openapi.JSONContent(schema)
+	}
+	op.Responses["200"] = resp
+	op.Responses["default"] = &openapi.SResponse{
+		Description: "Error",
+		Content:     openapi.JSONContent(errSchema),
+	}
+	return op
+}
+
+func openAPIRequestBody(op *openapi.SOperation, schema *openapi.SSchema) *openapi.SOperation {
+	if schema != nil {
+		op.RequestBody = &openapi.SRequestBody{
+			Content: openapi.JSONContent(schema),
+		}
+	}
+	return op
+}
+
+// OpenAPIPaths describes the endpoints registered by AddModelDispatcher for
+// the manager, request and response schemas are derived from the input types
+// of ListItemFilter, ValidateCreateData, ValidateUpdateData, Perform<Action>,
+// GetDetails<Spec> and the details type returned by FetchCustomizeColumns
+func (dispatcher *DBModelDispatcher) OpenAPIPaths(prefix string, gen *openapi.SSchemaGenerator) map[string]*openapi.SPathItem {
+	manager := dispatcher.modelManager
+	keyword := manager.Keyword()
+	plural := manager.KeywordPlural()
+	managerValue := reflect.ValueOf(manager)
+	model, err := NewModelObject(manager)
+	if err != nil {
+		return nil
+	}
+	modelValue := reflect.ValueOf(model)
+
+	errSchema := gen.SchemaOf(reflect.TypeOf(httperrors.Error{}))
+	detailsSchema := &openapi.SSchema{}
+	funcVal, err := findFunc(managerValue, "FetchCustomizeColumns")
+	if err == nil && funcVal.Type().NumOut() > 0 && funcVal.Type().Out(0).Kind() == reflect.Slice {
+		detailsSchema = gen.SchemaOf(funcVal.Type().Out(0).Elem())
+	}
+
+	base := "/" + plural
+	if len(prefix) > 0 {
+		base = "/" + strings.Trim(prefix, "/") + base
+	}
+	idPath := base + "/{id}"
+	idParam := openapi.PathParameter("id")
+	paths := map[string]*openapi.SPathItem{
+		base:   {},
+		idPath: {Parameters: []*openapi.SParameter{idParam}},
+	}
+
+	// list
+	listOp := openAPIOperation(plural, plural+"_list", fmt.Sprintf("List %s", plural))
+	if input := funcInput(managerValue, "ListItemFilter", 3); input != nil {
+		listOp.Parameters = []*openapi.SParameter{openapi.QueryParameters("query", gen.SchemaOf(input))}
+	}
+	paths[base].Get = openAPIResponses(listOp, errSchema, &openapi.SSchema{
+		Type: "object",
+		Properties: map[string]*openapi.SSchema{
+			plural:        {Type: "array", Items: detailsSchema},
+			"total":       {Type: "integer"},
+			"limit":       {Type: "integer"},
+			"offset":      {Type: "integer"},
+			"next_marker": {Type: "string"},
+		},
+	})
+
+	// create
+	createOp := openAPIOperation(plural, plural+"_create", fmt.Sprintf("Create a %s", keyword))
+	if input := funcInput(managerValue, "ValidateCreateData", 4); input != nil {
+		openAPIRequestBody(createOp, openapi.WrapSchema(keyword, gen.SchemaOf(input)))
+	}
+	paths[base].Post = openAPIResponses(createOp, errSchema, openapi.WrapSchema(keyword, detailsSchema))
+
+	// get, update, delete
+	paths[idPath].Get = openAPIResponses(openAPIOperation(plural, plural+"_get", fmt.Sprintf("Show a %s", keyword)),
+		errSchema, openapi.WrapSchema(keyword, detailsSchema))
+	updateOp := openAPIOperation(plural, plural+"_update", fmt.Sprintf("Update a %s", keyword))
+	if input := funcInput(modelValue, "ValidateUpdateData", 3); input != nil {
+		openAPIRequestBody(updateOp, openapi.WrapSchema(keyword, gen.SchemaOf(input)))
+	}
+	paths[idPath].Put = openAPIResponses(updateOp, errSchema, openapi.WrapSchema(keyword, detailsSchema))
+	paths[idPath].Delete = openAPIResponses(openAPIOperation(plural, plural+"_delete", fmt.Sprintf("Delete a %s", keyword)),
+		errSchema, openapi.WrapSchema(keyword, detailsSchema))
+
+	// bulk
+	bulkOp := openAPIOperation(plural, plural+"_bulk", fmt.Sprintf("Apply one operation to multiple %s", plural))
+	openAPIRequestBody(bulkOp, gen.SchemaOf(reflect.TypeOf(apis.BulkOperationInput{})))
+	paths[base+"/bulk"] = &openapi.SPathItem{
+		Post: openAPIResponses(bulkOp, errSchema, gen.SchemaOf(reflect.TypeOf(apis.BulkOperationOutput{}))),
+	}
+
+	// perform actions of an object and of the class
+	for _, m := range fetchOpenAPIMethods(modelValue.Type(), "Perform", 4) {
+		op := openAPIOperation(plural, fmt.Sprintf("%s_perform_%s", plural, m.spec), fmt.Sprintf("Perform %s on a %s", m.spec, keyword))
+		openAPIRequestBody(op, openapi.WrapSchema(keyword, gen.SchemaOf(m.input)))
+		paths[fmt.Sprintf("%s/%s", idPath, m.spec)] = &openapi.SPathItem{
+			Parameters: []*openapi.SParameter{idParam},
+			Post:       openAPIResponses(op, errSchema, openapi.WrapSchema(keyword, gen.SchemaOf(m.output))),
+		}
+	}
+	for _, m := range fetchOpenAPIMethods(managerValue.Type(), "Perform", 4) {
+		path := fmt.Sprintf("%s/%s", base, m.spec)
+		if _, ok := paths[path]; ok {
+			continue
+		}
+		op := openAPIOperation(plural, fmt.Sprintf("%s_perform_class_%s", plural, m.spec), fmt.Sprintf("Perform %s on %s", m.spec, plural))
+		openAPIRequestBody(op, openapi.WrapSchema(plural, gen.SchemaOf(m.input)))
+		paths[path] = &openapi.SPathItem{
+			Post: openAPIResponses(op, errSchema, openapi.WrapSchema(plural, gen.SchemaOf(m.output))),
+		}
+	}
+
+	// specific details of an object
+	for _, m := range fetchOpenAPIMethods(modelValue.Type(), "GetDetails", 3) {
+		op := openAPIOperation(plural, fmt.Sprintf("%s_get_%s", plural, m.spec), fmt.Sprintf("Get %s of a %s", m.spec, keyword))
+		op.Parameters = []*openapi.SParameter{openapi.QueryParameters("query", gen.SchemaOf(m.input))}
+		path := fmt.Sprintf("%s/%s", idPath, m.spec)
+		item, ok := paths[path]
+		if !ok {
+			item = &openapi.SPathItem{Parameters: []*openapi.SParameter{idParam}}
+			paths[path] = item
+		}
+		item.Get = openAPIResponses(op, errSchema, openapi.WrapSchema(keyword, gen.SchemaOf(m.output)))
+	}
+	return paths
+}
@@ -24,6 +24,7 @@ import (
 	"yunion.io/x/pkg/errors"
 	"yunion.io/x/sqlchemy"
 
+	"yunion.io/x/onecloud/pkg/apis"
 	api "yunion.io/x/onecloud/pkg/apis/notify"
 	"yunion.io/x/onecloud/pkg/cloudcommon/informer"
 	"yunion.io/x/onecloud/pkg/util/nopanic"
@@ -153,6 +154,7 @@ func (ts *sTableSpec) Insert(ctx context.Context, dt interface{}) error {
 	}
 	ts.rejectRecordChecksumAfterInsert(dt.(IModel))
 	ts.inform(ctx, dt, informer.Create)
+	ts.notifyWatch(dt, apis.WATCH_EVENT_CREATE)
 	return nil
 }
 
@@ -170,6 +172,7 @@ func (ts *sTableSpec) InsertOrUpdate(ctx context.Context, dt interface{}) error
 	}
 	ts.rejectRecordChecksumAfterInsert(dt.(IModel))
 	ts.inform(ctx, dt, informer.Create)
+	ts.notifyWatch(dt, apis.WATCH_EVENT_CREATE)
 	return nil
 }
 
@@ -236,8 +239,10 @@ func (ts *sTableSpec) Update(ctx context.Context, dt interface{}, doUpdate func(
 	}
 	if isDeleted {
 		ts.inform(ctx, dt, informer.Delete)
+		ts.notifyWatch(dt, apis.WATCH_EVENT_DELETE)
 	} else {
 		ts.informUpdate(ctx, dt, oldObj.(*jsonutils.JSONDict))
+		ts.notifyWatch(dt, apis.WATCH_EVENT_UPDATE)
 	}
 	return diffs, nil
 }
@@ -249,6 +254,7 @@ func (ts *sTableSpec) Increment(ctx context.Context, diff, target interface{}) e
 		return errors.Wrap(err, "Increment")
 	}
 	ts.informUpdate(ctx, target, oldObj.(*jsonutils.JSONDict))
+	ts.notifyWatch(target, apis.WATCH_EVENT_UPDATE)
 	return nil
 }
 
@@ -259,6 +265,7 @@ func (ts *sTableSpec) Decrement(ctx context.Context, diff, target interface{}) e
 		return err
 	}
 	ts.informUpdate(ctx, target, oldObj.(*jsonutils.JSONDict))
+	ts.notifyWatch(target, apis.WATCH_EVENT_UPDATE)
 	return nil
 }
 
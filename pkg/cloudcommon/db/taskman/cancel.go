@@ -0,0 +1,131 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"context"
+	"fmt"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+const (
+	// TASK_STATUS_CANCEL is posted as __status__ to a task to cancel it
+	TASK_STATUS_CANCEL = "cancel"
+
+	// stage of the task when it is cancelled, callbacks of that stage arrived
+	// after cancellation are dropped
+	TASK_CANCELLED_STAGE_KEY = "__cancelled_stage"
+	// stage of the task whose failed handler has been scheduled by abort
+	TASK_ABORTED_STAGE_KEY = "__aborted_stage"
+)
+
+func isTaskClosedStage(stage string) bool {
+	return stage == TASK_STAGE_COMPLETE || stage == TASK_STAGE_FAILED
+}
+
+func (self *STask) IsClosed() bool {
+	return isTaskClosedStage(self.Stage)
+}
+
+func (self *STask) isAborted() bool {
+	stage, _ := self.Params.GetString(TASK_ABORTED_STAGE_KEY)
+	return len(stage) > 0 && stage == self.Stage
+}
+
+func (self *STask) IsCancelled() bool {
+	stage, _ := self.Params.GetString(TASK_CANCELLED_STAGE_KEY)
+	return len(stage) > 0 && stage == self.Stage
+}
+
+func (manager *STaskManager) cancelTask(ctx context.Context, userCred mcclient.TokenCredential, taskId string, reason string) (jsonutils.JSONObject, error) {
+	task := manager.fetchTask(taskId)
+	if task == nil {
+		return nil, httperrors.NewResourceNotFoundError2(manager.Keyword(), taskId)
+	}
+	if userCred.GetProjectId() != task.UserCred.GetProjectId() && !db.IsAdminAllowPerform(ctx, userCred, task, "cancel") {
+		return nil, httperrors.NewForbiddenError("not allow to cancel task %s", taskId)
+	}
+	if task.IsClosed() {
+		return nil, httperrors.NewInvalidStatusError("task %s is already %s", taskId, task.Stage)
+	}
+	if task.isAborted() {
+		return nil, httperrors.NewInvalidStatusError("task %s is already aborted on stage %s", taskId, task.Stage)
+	}
+	msg := fmt.Sprintf("cancelled by %s", userCred.GetUserName())
+	if len(reason) > 0 {
+		msg = fmt.Sprintf("%s: %s", msg, reason)
+	}
+	err := task.abort(ctx, msg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "abort task %s", taskId)
+	}
+	resp := jsonutils.NewDict()
+	resp.Add(jsonutils.NewString("ok"), "result")
+	return resp, nil
+}
+
+// abort drives the task into failed handler of its current stage. Pending subtasks
+// of the stage are aborted first, and the task is resumed with their failures as it
+// would be when subtasks fail on their own. Aborting an already cancelled stage skips
+// subtasks, so that a task waiting on lost subtasks could still be resolved. The failed
+// handler of a stage is scheduled by abort at most once.
+func (self *STask) abort(ctx context.Context, reason string) error {
+	if self.IsClosed() || self.isAborted() {
+		return nil
+	}
+	log.Infof("abort task %s(%s) on stage %s: %s", self.TaskName, self.Id, self.Stage, reason)
+	aborted := 0
+	if !self.IsCancelled() {
+		data := jsonutils.NewDict()
+		data.Set(TASK_CANCELLED_STAGE_KEY, jsonutils.NewString(self.Stage))
+		err := self.SaveParams(data)
+		if err != nil {
+			return errors.Wrap(err, "SaveParams")
+		}
+		for _, subtask := range SubTaskManager.GetInitSubtasks(self.Id, self.Stage) {
+			sub := TaskManager.fetchTask(subtask.SubtaskId)
+			if sub == nil || sub.IsClosed() {
+				continue
+			}
+			err := sub.abort(ctx, reason)
+			if err != nil {
+				log.Errorf("abort subtask %s of %s: %v", sub.Id, self.Id, err)
+				continue
+			}
+			aborted++
+		}
+	}
+	if aborted > 0 {
+		// resumed by NotifyParentTaskFailure of subtasks
+		return nil
+	}
+	data := jsonutils.NewDict()
+	data.Set(TASK_ABORTED_STAGE_KEY, jsonutils.NewString(self.Stage))
+	err := self.SaveParams(data)
+	if err != nil {
+		return errors.Wrap(err, "SaveParams")
+	}
+	body := jsonutils.NewDict()
+	body.Add(jsonutils.NewString("error"), "__status__")
+	body.Add(jsonutils.NewString(reason), "__reason__")
+	return self.ScheduleRun(body)
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"context"
+	"testing"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+)
+
+func TestTaskAbort(t *testing.T) {
+	initTaskTestDB(t)
+	ctx := context.Background()
+
+	task := newTestTask(t, "abort", "abortTestTask", "OnWait", jsonutils.NewDict())
+	if err := task.abort(ctx, "test abort"); err != nil {
+		t.Fatalf("abort: %v", err)
+	}
+	task = TaskManager.fetchTask(task.Id)
+	if !task.IsCancelled() || !task.isAborted() {
+		t.Fatalf("expect task cancelled and aborted on stage OnWait, got %s", task.Params)
+	}
+	version := task.UpdateVersion
+	if err := task.abort(ctx, "test abort again"); err != nil {
+		t.Fatalf("abort again: %v", err)
+	}
+	if task = TaskManager.fetchTask(task.Id); task.UpdateVersion != version {
+		t.Errorf("expect abort only once, update version %d => %d", version, task.UpdateVersion)
+	}
+
+	parent := newTestTask(t, "abort-parent", "abortTestTask", "OnWait", jsonutils.NewDict())
+	sub := newTestTask(t, "abort-sub", "abortTestTask", "OnStart", jsonutils.NewDict())
+	err := SubTaskManager.TableSpec().GetTableSpec().Insert(&SSubTask{TaskId: parent.Id, Stage: "OnWait", SubtaskId: sub.Id, Status: SUBTASK_INIT})
+	if err != nil {
+		t.Fatalf("insert subtask: %v", err)
+	}
+	if err := parent.abort(ctx, "test abort parent"); err != nil {
+		t.Fatalf("abort parent: %v", err)
+	}
+	if sub = TaskManager.fetchTask(sub.Id); !sub.isAborted() {
+		t.Errorf("expect subtask aborted, got %s", sub.Params)
+	}
+	// parent is resumed by failure of the subtask
+	if parent = TaskManager.fetchTask(parent.Id); !parent.IsCancelled() || parent.isAborted() {
+		t.Errorf("expect parent cancelled but not aborted, got %s", parent.Params)
+	}
+}
+
+func TestCancelTask(t *testing.T) {
+	initTaskTestDB(t)
+	ctx := context.Background()
+	userCred := &mcclient.SSimpleToken{User: "test"}
+
+	aborted := jsonutils.NewDict()
+	aborted.Set(TASK_ABORTED_STAGE_KEY, jsonutils.NewString("OnWait"))
+	abortedOnPrevStage := jsonutils.NewDict()
+	abortedOnPrevStage.Set(TASK_ABORTED_STAGE_KEY, jsonutils.NewString("OnStart"))
+	cases := []struct {
+		name    string
+		taskId  string
+		stage   string
+		params  *jsonutils.JSONDict
+		errCode int
+	}{
+		{"running", "cancel-running", "OnWait", jsonutils.NewDict(), 0},
+		{"aborted on previous stage", "cancel-prev", "OnWait", abortedOnPrevStage, 0},
+		{"completed", "cancel-complete", TASK_STAGE_COMPLETE, jsonutils.NewDict(), 400},
+		{"failed", "cancel-failed", TASK_STAGE_FAILED, jsonutils.NewDict(), 400},
+		{"aborted", "cancel-aborted", "OnWait", aborted, 400},
+	}
+	for _, c := range cases {
+		task := newTestTask(t, c.taskId, "abortTestTask", c.stage, c.params)
+		_, err := TaskManager.cancelTask(ctx, userCred, task.Id, "test")
+		if c.errCode == 0 {
+			if err != nil {
+				t.Errorf("%s: cancel: %v", c.name, err)
+			} else if task = TaskManager.fetchTask(task.Id); !task.isAborted() {
+				t.Errorf("%s: expect task aborted, got %s", c.name, task.Params)
+			}
+			continue
+		}
+		if je, ok := err.(*httputils.JSONClientError); !ok || je.Code != c.errCode {
+			t.Errorf("%s: want error code %d, got %v", c.name, c.errCode, err)
+		}
+	}
+
+	_, err := TaskManager.cancelTask(ctx, userCred, "cancel-not-exists", "test")
+	if je, ok := err.(*httputils.JSONClientError); !ok || je.Code != 404 {
+		t.Errorf("not exists: want error code 404, got %v", err)
+	}
+}
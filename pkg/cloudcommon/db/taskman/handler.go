@@ -23,4 +23,6 @@ import (
 func AddTaskHandler(prefix string, app *appsrv.Application) {
 	handler := db.NewModelHandler(TaskManager)
 	dispatcher.AddModelDispatcher(prefix, app, handler)
+
+	appsrv.RegisterMetricsCollector(stageExecutions, &sTaskCollector{})
 }
@@ -0,0 +1,107 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"yunion.io/x/log"
+	"yunion.io/x/sqlchemy"
+)
+
+const (
+	// only tasks created within this window are counted as open tasks,
+	// older leftovers are reported by SweepStuckTasks
+	OPEN_TASK_METRIC_WINDOW = 7 * 24 * time.Hour
+	// open task counts are reused by scrapes within this interval
+	OPEN_TASK_METRIC_CACHE_TTL = 30 * time.Second
+)
+
+var (
+	stageExecutions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "taskman",
+			Name:      "stage_executions_total",
+			Help:      "Number of task stages executed, by task type, stage and result",
+		},
+		[]string{"task_name", "stage", "result"},
+	)
+
+	openTasksDesc = prometheus.NewDesc(
+		"taskman_open_tasks",
+		"Number of tasks created in the last 7 days and not yet complete or failed, by task type and current stage",
+		[]string{"task_name", "stage"}, nil,
+	)
+)
+
+func observeStageExecution(taskName, stage string, failed bool) {
+	result := "ok"
+	if failed {
+		result = "failed"
+	}
+	stageExecutions.WithLabelValues(taskName, stage, result).Inc()
+}
+
+type sOpenTaskCount struct {
+	TaskName string
+	Stage    string
+	Count    int
+}
+
+type sTaskCollector struct {
+	lock      sync.Mutex
+	counts    []sOpenTaskCount
+	fetchedAt time.Time
+}
+
+func (c *sTaskCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- openTasksDesc
+}
+
+func (c *sTaskCollector) openTaskCounts() ([]sOpenTaskCount, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.counts != nil && time.Since(c.fetchedAt) < OPEN_TASK_METRIC_CACHE_TTL {
+		return c.counts, nil
+	}
+	q := TaskManager.Query("task_name", "stage")
+	q = q.AppendField(sqlchemy.COUNT("count"))
+	q = q.GE("created_at", time.Now().Add(-OPEN_TASK_METRIC_WINDOW))
+	q = q.Filter(sqlchemy.NOT(sqlchemy.In(q.Field("stage"), []string{TASK_STAGE_COMPLETE, TASK_STAGE_FAILED})))
+	q = q.GroupBy(q.Field("task_name"), q.Field("stage"))
+	counts := []sOpenTaskCount{}
+	err := q.All(&counts)
+	if err != nil {
+		return nil, err
+	}
+	c.counts = counts
+	c.fetchedAt = time.Now()
+	return counts, nil
+}
+
+func (c *sTaskCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := c.openTaskCounts()
+	if err != nil {
+		log.Errorf("query open task counts: %v", err)
+		return
+	}
+	for _, cnt := range counts {
+		ch <- prometheus.MustNewConstMetric(openTasksDesc, prometheus.GaugeValue, float64(cnt.Count), cnt.TaskName, cnt.Stage)
+	}
+}
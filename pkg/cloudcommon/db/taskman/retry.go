@@ -0,0 +1,411 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/sqlchemy"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+)
+
+type TRetryErrorClass string
+
+const (
+	RetryErrorNetwork     = TRetryErrorClass("network")
+	RetryErrorServerError = TRetryErrorClass("server_error")
+	RetryErrorThrottling  = TRetryErrorClass("throttling")
+
+	// stage and input data of last executed retryable stage
+	TASK_RETRY_POINT_KEY = "__retry_point"
+	// attempt number carried by data of a retry run
+	TASK_RETRY_ATTEMPT_KEY = "__retry_attempt__"
+	// time when the failed retry point stage is due to run again
+	TASK_RETRY_PENDING_KEY = "__retry_pending_at"
+
+	DEFAULT_STAGE_MAX_ATTEMPTS    = 3
+	DEFAULT_STAGE_INITIAL_BACKOFF = 10 * time.Second
+	DEFAULT_STAGE_MAX_BACKOFF     = 5 * time.Minute
+
+	// max pending retries resumed by one run of ResumeStageRetries
+	RESUME_STAGE_RETRY_BATCH = 100
+)
+
+// status code field of serialized http errors, e.g. "code":503 or status: 503
+const retryStatusCodePattern = `\b(status|status_?code|http_?code|code)"?\s*[:=]\s*"?`
+
+// fallback patterns of error messages which carry no status code, e.g. errors
+// of cloud sdks or plain messages reported by host agents
+var retryErrorPatterns = []struct {
+	class   TRetryErrorClass
+	pattern *regexp.Regexp
+}{
+	{RetryErrorThrottling, regexp.MustCompile(`(?i)throttl|TooManyRequests|too many requests|RequestLimitExceeded|rate exceeded|rate limit|` + retryStatusCodePattern + `429\b`)},
+	{RetryErrorServerError, regexp.MustCompile(`(?i)BadGateway|ServiceUnavailable|GatewayTimeout|` + retryStatusCodePattern + `50[234]\b`)},
+	{RetryErrorNetwork, regexp.MustCompile(`(?i)connection refused|connection reset|broken pipe|no route to host|network is unreachable|i/o timeout|TLS handshake timeout|unexpected EOF`)},
+}
+
+// findJSONClientError walks the causes of err for an http error. errors.Cause
+// can not be used, as JSONClientError has a Cause of its own
+func findJSONClientError(err error) *httputils.JSONClientError {
+	for err != nil {
+		if jsonErr, ok := err.(*httputils.JSONClientError); ok {
+			return jsonErr
+		}
+		causer, ok := err.(interface{ Cause() error })
+		if !ok {
+			return nil
+		}
+		cause := causer.Cause()
+		if cause == err {
+			return nil
+		}
+		err = cause
+	}
+	return nil
+}
+
+// ClassifyRetryError returns the retryable class of an error, empty if not retryable.
+// Http errors are classified by status code, only throttling, bad gateway,
+// unavailable and gateway timeout are retryable, as well as request failures
+// before any response.  Other errors are classified by message
+func ClassifyRetryError(err error) TRetryErrorClass {
+	if err == nil {
+		return ""
+	}
+	if jsonErr := findJSONClientError(err); jsonErr != nil {
+		switch jsonErr.Code {
+		case http.StatusTooManyRequests:
+			return RetryErrorThrottling
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return RetryErrorServerError
+		case 499:
+			// set by httputils when request fails without response
+			return RetryErrorNetwork
+		}
+		if jsonErr.Code > 0 {
+			return ""
+		}
+	}
+	if _, ok := errors.Cause(err).(net.Error); ok {
+		return RetryErrorNetwork
+	}
+	msg := err.Error()
+	for _, p := range retryErrorPatterns {
+		if p.pattern.MatchString(msg) {
+			return p.class
+		}
+	}
+	return ""
+}
+
+// reasonToError restores the error of failure data passed to a stage. Http
+// errors arrive serialized from host agents or other services, either as the
+// reason itself or as its message
+func reasonToError(data jsonutils.JSONObject) error {
+	if data == nil {
+		return nil
+	}
+	reason := data
+	if dict, ok := data.(*jsonutils.JSONDict); ok && dict.Contains("__reason__") {
+		reason, _ = dict.Get("__reason__")
+	}
+	msg, err := reason.GetString()
+	if err != nil {
+		msg = reason.String()
+	} else if obj, err := jsonutils.ParseString(msg); err == nil {
+		reason = obj
+	}
+	if dict, ok := reason.(*jsonutils.JSONDict); ok {
+		// JSONClientError.Error() wraps the error as {"error":{...}}
+		errObj := jsonutils.JSONObject(dict)
+		if dict.Contains("error") {
+			errObj, _ = dict.Get("error")
+		}
+		jsonErr := &httputils.JSONClientError{}
+		if errObj.Unmarshal(jsonErr) == nil && jsonErr.Code > 0 {
+			return jsonErr
+		}
+	}
+	return errors.Error(msg)
+}
+
+// SStageRetryPolicy declares how a failed stage is re-run
+type SStageRetryPolicy struct {
+	// max executions of the stage including the first one, default 3
+	MaxAttempts int
+	// backoff before the first retry, doubled for each following retry, default 10s
+	InitialBackoff time.Duration
+	// upper bound of backoff, default 5m
+	MaxBackoff time.Duration
+	// retryable error classes, empty for all classes
+	ErrorClasses []TRetryErrorClass
+}
+
+func (policy SStageRetryPolicy) isRetryable(class TRetryErrorClass) bool {
+	if len(class) == 0 {
+		return false
+	}
+	if len(policy.ErrorClasses) == 0 {
+		return true
+	}
+	for _, c := range policy.ErrorClasses {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// getBackoff returns the wait before run the stage again after attempt failed
+func (policy SStageRetryPolicy) getBackoff(attempt int) time.Duration {
+	backoff := policy.InitialBackoff
+	for i := 1; i < attempt && backoff < policy.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > policy.MaxBackoff {
+		backoff = policy.MaxBackoff
+	}
+	return backoff
+}
+
+// taskName => stage => policy
+var taskStageRetryPolicies = map[string]map[string]SStageRetryPolicy{}
+
+// RegisterStageRetry declares an idempotent stage to be re-run with exponential backoff
+// when the stage it switched to receives a failure callback with retryable errors.
+// Failures raised by SetStageFailed are not retried, as failed handlers have run then.
+// The retry is persisted in task params and resumed by ResumeStageRetries, e.g.
+//
+//	taskman.RegisterStageRetry(CloudAccountSyncInfoTask{}, "OnInit", taskman.SStageRetryPolicy{MaxAttempts: 3})
+func RegisterStageRetry(task interface{}, stage string, policy SStageRetryPolicy) {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = DEFAULT_STAGE_MAX_ATTEMPTS
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = DEFAULT_STAGE_INITIAL_BACKOFF
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = DEFAULT_STAGE_MAX_BACKOFF
+	}
+	taskName := gotypes.GetInstanceTypeName(task)
+	if _, ok := taskStageRetryPolicies[taskName]; !ok {
+		taskStageRetryPolicies[taskName] = map[string]SStageRetryPolicy{}
+	}
+	taskStageRetryPolicies[taskName][normalizeStageName(stage)] = policy
+}
+
+func getStageRetryPolicy(taskName, stage string) (SStageRetryPolicy, bool) {
+	policies, ok := taskStageRetryPolicies[taskName]
+	if !ok {
+		return SStageRetryPolicy{}, false
+	}
+	policy, ok := policies[normalizeStageName(stage)]
+	return policy, ok
+}
+
+type sRetryPoint struct {
+	Stage   string
+	Attempt int
+	Data    jsonutils.JSONObject
+}
+
+func (self *STask) getRetryPoint() *sRetryPoint {
+	if !self.Params.Contains(TASK_RETRY_POINT_KEY) {
+		return nil
+	}
+	point := &sRetryPoint{}
+	err := self.Params.Unmarshal(point, TASK_RETRY_POINT_KEY)
+	if err != nil {
+		log.Errorf("unmarshal retry point of task %s: %v", self.Id, err)
+		return nil
+	}
+	return point
+}
+
+// prepareStageRun records current stage as retry point if it is retryable, otherwise
+// clears retry point, as the request issued by retry point stage has succeeded.
+// data passed to stage is returned with retry attempt stripped.
+func (self *STask) prepareStageRun(data jsonutils.JSONObject) jsonutils.JSONObject {
+	attempt := 1
+	if dict, ok := data.(*jsonutils.JSONDict); ok && dict.Contains(TASK_RETRY_ATTEMPT_KEY) {
+		retryAttempt, _ := dict.Int(TASK_RETRY_ATTEMPT_KEY)
+		attempt = int(retryAttempt)
+		data = dict.CopyExcludes(TASK_RETRY_ATTEMPT_KEY)
+	}
+	if _, ok := getStageRetryPolicy(self.TaskName, self.Stage); !ok {
+		if self.Params.Contains(TASK_RETRY_POINT_KEY) {
+			self.updateParams(func(params *jsonutils.JSONDict) {
+				params.Remove(TASK_RETRY_POINT_KEY)
+			})
+		}
+		return data
+	}
+	point := sRetryPoint{
+		Stage:   self.Stage,
+		Attempt: attempt,
+		Data:    data,
+	}
+	self.updateParams(func(params *jsonutils.JSONDict) {
+		params.Set(TASK_RETRY_POINT_KEY, jsonutils.Marshal(point))
+	})
+	return data
+}
+
+// retryStage schedules the retry point stage again if the failure is retryable,
+// returns false if the failure should be passed to failed handler
+func (self *STask) retryStage(ctx context.Context, reason jsonutils.JSONObject) bool {
+	if self.IsCancelled() {
+		return false
+	}
+	point := self.getRetryPoint()
+	if point == nil {
+		return false
+	}
+	policy, ok := getStageRetryPolicy(self.TaskName, point.Stage)
+	if !ok || point.Attempt >= policy.MaxAttempts {
+		return false
+	}
+	reasonErr := reasonToError(reason)
+	class := ClassifyRetryError(reasonErr)
+	if !policy.isRetryable(class) {
+		return false
+	}
+	backoff := policy.getBackoff(point.Attempt)
+	log.Warningf("task %s(%s) stage %s attempt %d failed with %s error, retry in %s: %v", self.TaskName, self.Id, point.Stage, point.Attempt, class, backoff, reasonErr)
+
+	// record failed attempt in stage history
+	history := jsonutils.NewDict()
+	history.Add(jsonutils.NewString(self.Stage), "name")
+	history.Add(jsonutils.NewTimeString(time.Now()), "complete_at")
+	history.Add(jsonutils.NewString(point.Stage), "retry_stage")
+	history.Add(jsonutils.NewInt(int64(point.Attempt)), "attempt")
+	history.Add(jsonutils.NewString(string(class)), "error_class")
+	history.Add(jsonutils.NewString(reasonErr.Error()), "reason")
+	history.Add(jsonutils.NewString(backoff.String()), "backoff")
+	err := self.updateParams(func(params *jsonutils.JSONDict) {
+		stages, _ := params.Get("__stages")
+		if stages == nil {
+			stages = jsonutils.NewArray()
+			params.Add(stages, "__stages")
+		}
+		stages.(*jsonutils.JSONArray).Add(history)
+		params.Set(TASK_RETRY_PENDING_KEY, jsonutils.NewTimeString(time.Now().Add(backoff)))
+	})
+	if err != nil {
+		log.Errorf("record retry of task %s: %v", self.Id, err)
+		return false
+	}
+	return true
+}
+
+func (self *STask) isRetryPending() bool {
+	return self.Params != nil && self.Params.Contains(TASK_RETRY_PENDING_KEY)
+}
+
+// ResumeStageRetries is a cron job which re-runs retry point stages whose backoff has expired
+func (manager *STaskManager) ResumeStageRetries(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	taskNames := make([]string, 0, len(taskStageRetryPolicies))
+	for taskName := range taskStageRetryPolicies {
+		taskNames = append(taskNames, taskName)
+	}
+	if len(taskNames) == 0 {
+		return
+	}
+	q := manager.Query()
+	q = q.Filter(sqlchemy.NOT(sqlchemy.In(q.Field("stage"), []string{TASK_STAGE_COMPLETE, TASK_STAGE_FAILED})))
+	q = q.In("task_name", taskNames)
+	// underscores of the key are left unescaped, as sqlite takes no backslash
+	// escape in like patterns. They match themselves as well, and the pending
+	// retry is checked again before it is resumed
+	q = q.Like("params", "%"+TASK_RETRY_PENDING_KEY+"%")
+	q = q.Asc("updated_at").Limit(RESUME_STAGE_RETRY_BATCH)
+	tasks := make([]STask, 0)
+	err := db.FetchModelObjects(manager, q, &tasks)
+	if err != nil {
+		log.Errorf("fetch tasks pending retry: %v", err)
+		return
+	}
+	for i := range tasks {
+		err := tasks[i].resumeRetry(ctx)
+		if err != nil {
+			log.Errorf("resume retry of task %s(%s): %v", tasks[i].TaskName, tasks[i].Id, err)
+		}
+	}
+}
+
+// resumeRetry switches the task back to retry point stage and runs it, if the retry is due
+func (self *STask) resumeRetry(ctx context.Context) error {
+	lockman.LockRawObject(ctx, "tasks", self.Id)
+	defer lockman.ReleaseRawObject(ctx, "tasks", self.Id)
+
+	task := TaskManager.fetchTask(self.Id)
+	if task == nil || task.IsClosed() || !task.isRetryPending() {
+		return nil
+	}
+	retryAt, err := task.Params.GetTime(TASK_RETRY_PENDING_KEY)
+	if err == nil && retryAt.After(time.Now()) {
+		return nil
+	}
+	point := task.getRetryPoint()
+	if point == nil || task.IsCancelled() {
+		// a cancelled task has been driven into failed handler by abort
+		return task.updateParams(func(params *jsonutils.JSONDict) {
+			params.Remove(TASK_RETRY_PENDING_KEY)
+		})
+	}
+	err = task.updateParams(func(params *jsonutils.JSONDict) {
+		params.Remove(TASK_RETRY_PENDING_KEY)
+	}, point.Stage)
+	if err != nil {
+		return err
+	}
+	data := jsonutils.NewDict()
+	if dict, ok := point.Data.(*jsonutils.JSONDict); ok {
+		data.Update(dict)
+	}
+	data.Set(TASK_RETRY_ATTEMPT_KEY, jsonutils.NewInt(int64(point.Attempt+1)))
+	return runTask(task.Id, data)
+}
+
+// updateParams modifies task params in place, and switches to stage if given
+func (self *STask) updateParams(update func(params *jsonutils.JSONDict), stage ...string) error {
+	_, err := db.Update(self, func() error {
+		params := jsonutils.NewDict()
+		params.Update(self.Params)
+		update(params)
+		self.Params = params
+		if len(stage) > 0 && len(stage[0]) > 0 {
+			self.Stage = stage[0]
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "update task params")
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+	_ "yunion.io/x/sqlchemy/backends"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+)
+
+var taskTestDBOnce sync.Once
+
+// initTaskTestDB sets up tasks table in an in-memory sqlite database shared
+// by tests of the package, as resumed tasks run in background workers
+func initTaskTestDB(t *testing.T) {
+	taskTestDBOnce.Do(func() {
+		dbConn, err := sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			t.Fatalf("open sqlite: %v", err)
+		}
+		// every connection of in-memory sqlite opens a database of its own
+		dbConn.SetMaxOpenConns(1)
+		sqlchemy.SetDBWithNameBackend(dbConn, sqlchemy.DefaultDB, sqlchemy.SQLiteBackend)
+		for _, manager := range []interface{ GetTableSpec() *sqlchemy.STableSpec }{
+			TaskManager.TableSpec(), SubTaskManager.TableSpec(),
+		} {
+			err = manager.GetTableSpec().Sync()
+			if err != nil {
+				t.Fatalf("sync table: %v", err)
+			}
+		}
+		lockman.Init(lockman.NewInMemoryLockManager())
+	})
+}
+
+// newTestTask inserts a task, id is suffixed to be unique in the shared database
+func newTestTask(t *testing.T, id, taskName, stage string, params *jsonutils.JSONDict) *STask {
+	id = fmt.Sprintf("%s-%d", id, time.Now().UnixNano())
+	task := &STask{
+		Id:       id,
+		ObjName:  "server",
+		ObjId:    "obj-" + id,
+		TaskName: taskName,
+		UserCred: &mcclient.SSimpleToken{User: "test"},
+		Stage:    stage,
+		Params:   params,
+	}
+	err := TaskManager.TableSpec().GetTableSpec().Insert(task)
+	if err != nil {
+		t.Fatalf("insert task %s: %v", id, err)
+	}
+	return TaskManager.fetchTask(id)
+}
+
+func TestClassifyRetryError(t *testing.T) {
+	newJSONError := func(code int, class string) error {
+		return &httputils.JSONClientError{Code: code, Class: class, Details: "details"}
+	}
+	cases := []struct {
+		name string
+		err  error
+		want TRetryErrorClass
+	}{
+		{"nil", nil, ""},
+		{"too many requests", newJSONError(429, "TooManyRequests"), RetryErrorThrottling},
+		{"bad gateway", newJSONError(502, "BadGateway"), RetryErrorServerError},
+		{"unavailable", newJSONError(503, "ServiceUnavailable"), RetryErrorServerError},
+		{"gateway timeout", newJSONError(504, "TimeoutError"), RetryErrorServerError},
+		{"wrapped unavailable", errors.Wrap(newJSONError(503, ""), "request host"), RetryErrorServerError},
+		{"request without response", newJSONError(499, string(errors.ErrConnectRefused)), RetryErrorNetwork},
+		{"internal error", newJSONError(500, "InternalServerError"), ""},
+		{"internal error of network failure", newJSONError(500, "connection refused"), ""},
+		{"not found", newJSONError(404, "ResourceNotFoundError"), ""},
+		{"net error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.Error("refused")}, RetryErrorNetwork},
+		{"connection reset message", errors.Error("read tcp: connection reset by peer"), RetryErrorNetwork},
+		{"throttling message", errors.Error("Throttling: Rate exceeded"), RetryErrorThrottling},
+		{"unavailable status message", errors.Error(`upstream returned "status": 503`), RetryErrorServerError},
+		{"internal error message", errors.Error("InternalServerError: deploy guest fail"), ""},
+		{"internal status message", errors.Error(`{"code":500,"details":"insert iso fail"}`), ""},
+		{"plain message", errors.Error("disk not found"), ""},
+	}
+	for _, c := range cases {
+		if got := ClassifyRetryError(c.err); got != c.want {
+			t.Errorf("%s: want %q, got %q", c.name, c.want, got)
+		}
+	}
+}
+
+func TestReasonToError(t *testing.T) {
+	failure := func(reason jsonutils.JSONObject) jsonutils.JSONObject {
+		data := jsonutils.NewDict()
+		data.Set("__status__", jsonutils.NewString("ERROR"))
+		data.Set("__reason__", reason)
+		return data
+	}
+	unavailable := &httputils.JSONClientError{Code: 503, Class: "ServiceUnavailable", Details: "host busy"}
+	internal := &httputils.JSONClientError{Code: 500, Class: "InternalServerError", Details: "connection refused"}
+	cases := []struct {
+		name string
+		data jsonutils.JSONObject
+		want TRetryErrorClass
+	}{
+		{"serialized error message", failure(jsonutils.NewString(unavailable.Error())), RetryErrorServerError},
+		{"serialized internal error", failure(jsonutils.NewString(internal.Error())), ""},
+		{"error dict", failure(jsonutils.Marshal(unavailable)), RetryErrorServerError},
+		{"internal error dict", failure(jsonutils.Marshal(internal)), ""},
+		{"plain message", failure(jsonutils.NewString("dial tcp 10.0.0.1:8885: i/o timeout")), RetryErrorNetwork},
+		{"bare message", jsonutils.NewString("connection refused"), RetryErrorNetwork},
+	}
+	for _, c := range cases {
+		if got := ClassifyRetryError(reasonToError(c.data)); got != c.want {
+			t.Errorf("%s: want %q, got %q", c.name, c.want, got)
+		}
+	}
+}
+
+func TestStageRetryPolicyGetBackoff(t *testing.T) {
+	policy := SStageRetryPolicy{InitialBackoff: 10 * time.Second, MaxBackoff: time.Minute}
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, time.Minute},
+		{100, time.Minute},
+	}
+	for _, c := range cases {
+		if got := policy.getBackoff(c.attempt); got != c.want {
+			t.Errorf("attempt %d: want %s, got %s", c.attempt, c.want, got)
+		}
+	}
+	policy = SStageRetryPolicy{InitialBackoff: 2 * time.Minute, MaxBackoff: time.Minute}
+	if got := policy.getBackoff(1); got != time.Minute {
+		t.Errorf("initial backoff over max: want %s, got %s", time.Minute, got)
+	}
+}
+
+type retryTestTask struct {
+	STask
+}
+
+func retryPointParams(stage string, attempt int) *jsonutils.JSONDict {
+	params := jsonutils.NewDict()
+	params.Set(TASK_RETRY_POINT_KEY, jsonutils.Marshal(sRetryPoint{
+		Stage:   stage,
+		Attempt: attempt,
+		Data:    jsonutils.Marshal(map[string]string{"disk_id": "d1"}),
+	}))
+	return params
+}
+
+func TestRetryStage(t *testing.T) {
+	initTaskTestDB(t)
+	RegisterStageRetry(retryTestTask{}, "OnPrepare", SStageRetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Minute,
+		ErrorClasses:   []TRetryErrorClass{RetryErrorServerError, RetryErrorNetwork},
+	})
+	unavailable := jsonutils.NewString((&httputils.JSONClientError{Code: 503, Details: "busy"}).Error())
+	cancelled := retryPointParams("OnPrepare", 1)
+	cancelled.Set(TASK_CANCELLED_STAGE_KEY, jsonutils.NewString("OnPrepareComplete"))
+	cases := []struct {
+		name   string
+		params *jsonutils.JSONDict
+		reason jsonutils.JSONObject
+		want   bool
+	}{
+		{"retryable", retryPointParams("OnPrepare", 1), unavailable, true},
+		{"last attempt", retryPointParams("OnPrepare", 3), unavailable, false},
+		{"not retryable class", retryPointParams("OnPrepare", 1), jsonutils.NewString("Throttling: rate exceeded"), false},
+		{"deterministic error", retryPointParams("OnPrepare", 1), jsonutils.NewString("InternalServerError: insert iso fail"), false},
+		{"no retry point", jsonutils.NewDict(), unavailable, false},
+		{"cancelled", cancelled, unavailable, false},
+	}
+	for i, c := range cases {
+		task := newTestTask(t, "retry-stage-"+string(rune('a'+i)), "retryTestTask", "OnPrepareComplete", c.params)
+		start := time.Now()
+		if got := task.retryStage(context.Background(), c.reason); got != c.want {
+			t.Errorf("%s: want %v, got %v", c.name, c.want, got)
+			continue
+		}
+		task = TaskManager.fetchTask(task.Id)
+		if task.isRetryPending() != c.want {
+			t.Errorf("%s: unexpected pending retry %s", c.name, task.Params)
+			continue
+		}
+		if !c.want {
+			continue
+		}
+		retryAt, err := task.Params.GetTime(TASK_RETRY_PENDING_KEY)
+		if err != nil || retryAt.Before(start.Add(time.Minute).Add(-time.Second)) || retryAt.After(time.Now().Add(time.Minute)) {
+			t.Errorf("%s: unexpected retry time %s (%v)", c.name, retryAt, err)
+		}
+		stages, _ := task.Params.GetArray("__stages")
+		if len(stages) != 1 {
+			t.Fatalf("%s: expect retry recorded in stage history, got %s", c.name, task.Params)
+		}
+		if class, _ := stages[0].GetString("error_class"); class != string(RetryErrorServerError) {
+			t.Errorf("%s: unexpected error class %s", c.name, class)
+		}
+	}
+}
+
+func TestResumeStageRetries(t *testing.T) {
+	initTaskTestDB(t)
+	RegisterStageRetry(retryTestTask{}, "OnPrepare", SStageRetryPolicy{})
+	pending := func(at time.Time) *jsonutils.JSONDict {
+		params := retryPointParams("OnPrepare", 1)
+		params.Set(TASK_RETRY_PENDING_KEY, jsonutils.NewTimeString(at))
+		return params
+	}
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	cancelled := pending(past)
+	cancelled.Set(TASK_CANCELLED_STAGE_KEY, jsonutils.NewString("OnPrepareComplete"))
+
+	due := newTestTask(t, "resume-due", "retryTestTask", "OnPrepareComplete", pending(past))
+	notDue := newTestTask(t, "resume-not-due", "retryTestTask", "OnPrepareComplete", pending(future))
+	cancelledTask := newTestTask(t, "resume-cancelled", "retryTestTask", "OnPrepareComplete", cancelled)
+	closed := newTestTask(t, "resume-closed", "retryTestTask", TASK_STAGE_COMPLETE, pending(past))
+	unregistered := newTestTask(t, "resume-unregistered", "otherTask", "OnPrepareComplete", pending(past))
+
+	TaskManager.ResumeStageRetries(context.Background(), nil, false)
+
+	cases := []struct {
+		task      *STask
+		stage     string
+		isPending bool
+	}{
+		{due, "OnPrepare", false},
+		{notDue, "OnPrepareComplete", true},
+		{cancelledTask, "OnPrepareComplete", false},
+		{closed, TASK_STAGE_COMPLETE, true},
+		{unregistered, "OnPrepareComplete", true},
+	}
+	for _, c := range cases {
+		task := TaskManager.fetchTask(c.task.Id)
+		if task.Stage != c.stage || task.isRetryPending() != c.isPending {
+			t.Errorf("task %s: want stage %s pending %v, got stage %s params %s", c.task.Id, c.stage, c.isPending, task.Stage, task.Params)
+		}
+	}
+}
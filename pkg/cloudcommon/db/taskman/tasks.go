@@ -44,6 +44,7 @@ import (
 	"yunion.io/x/onecloud/pkg/mcclient/auth"
 	"yunion.io/x/onecloud/pkg/util/httputils"
 	"yunion.io/x/onecloud/pkg/util/rbacutils"
+	"yunion.io/x/onecloud/pkg/util/tracing"
 )
 
 const (
@@ -121,6 +122,12 @@ func (manager *STaskManager) AllowPerformAction(ctx context.Context, userCred mc
 }
 
 func (manager *STaskManager) PerformAction(ctx context.Context, userCred mcclient.TokenCredential, taskId string, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	if data != nil {
+		if status, _ := data.GetString("__status__"); status == TASK_STATUS_CANCEL {
+			reason, _ := data.GetString("__reason__")
+			return manager.cancelTask(ctx, userCred, taskId, reason)
+		}
+	}
 	err := runTask(taskId, data)
 	if err != nil {
 		return nil, errors.Wrapf(err, "runTask")
@@ -404,6 +411,13 @@ func execITask(taskValue reflect.Value, task *STask, odata jsonutils.JSONObject,
 	ctxData := task.GetRequestContext()
 	ctx := ctxData.GetContext()
 
+	// each stage continues the trace of the request that started the task
+	ctx, span := tracing.StartSpan(ctx, fmt.Sprintf("%s.%s", task.TaskName, task.Stage), tracing.SPAN_KIND_INTERNAL)
+	span.SetAttribute("task_id", task.Id)
+	span.SetAttribute("obj_type", task.ObjName)
+	span.SetAttribute("obj_id", task.ObjId)
+	defer span.End()
+
 	taskFailed := false
 
 	var data jsonutils.JSONObject
@@ -434,6 +448,30 @@ func execITask(taskValue reflect.Value, task *STask, odata jsonutils.JSONObject,
 		data = jsonutils.NewDict()
 	}
 
+	if task.IsClosed() {
+		log.Warningf("Task %s(%s) is already %s, ignore data %s", task.TaskName, task.Id, task.Stage, data)
+		return
+	}
+	if task.IsCancelled() && !taskFailed {
+		log.Warningf("Task %s(%s) is cancelled on stage %s, ignore data %s", task.TaskName, task.Id, task.Stage, data)
+		return
+	}
+	if task.isRetryPending() && !task.IsCancelled() {
+		log.Warningf("Task %s(%s) is waiting to retry on stage %s, ignore data %s", task.TaskName, task.Id, task.Stage, data)
+		return
+	}
+
+	if taskFailed {
+		span.SetStatus(tracing.STATUS_ERROR, data.String())
+		if task.retryStage(ctx, data) {
+			task.SaveRequestContext(&ctxData)
+			return
+		}
+	} else {
+		data = task.prepareStageRun(data)
+	}
+	observeStageExecution(task.TaskName, task.Stage, taskFailed)
+
 	var stageName string
 	if taskFailed {
 		stageName = fmt.Sprintf("%sFailed", task.Stage)
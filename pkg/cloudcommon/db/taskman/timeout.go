@@ -0,0 +1,213 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/pkg/util/timeutils"
+	"yunion.io/x/pkg/utils"
+	"yunion.io/x/sqlchemy"
+
+	api "yunion.io/x/onecloud/pkg/apis/notify"
+	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/notifyclient"
+	"yunion.io/x/onecloud/pkg/cloudcommon/options"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+const (
+	// stage of the task when it is reported as stuck
+	STUCK_REPORTED_STAGE_KEY = "__stuck_reported_stage"
+
+	// max tasks checked by one run of SweepStuckTasks
+	SWEEP_STUCK_TASK_BATCH = 1000
+	// interval of ResumeStageRetries
+	RESUME_STAGE_RETRY_INTERVAL = 10 * time.Second
+)
+
+// taskName => stage => timeout
+var taskStageTimeouts = map[string]map[string]time.Duration{}
+
+var (
+	stuckTaskThreshold time.Duration
+	autoFailStuckTasks bool
+
+	// update time and id of the last task checked by previous SweepStuckTasks
+	stuckTaskSweepCursor   time.Time
+	stuckTaskSweepCursorId string
+)
+
+// RegisterStageTimeout declares the longest time the task could stay in stage,
+// an expired stage is driven into its failed handler with timeout reason, e.g.
+//
+//	taskman.RegisterStageTimeout(GuestDeployTask{}, "OnDeployGuestComplete", 30*time.Minute)
+func RegisterStageTimeout(task interface{}, stage string, timeout time.Duration) {
+	taskName := gotypes.GetInstanceTypeName(task)
+	if _, ok := taskStageTimeouts[taskName]; !ok {
+		taskStageTimeouts[taskName] = map[string]time.Duration{}
+	}
+	taskStageTimeouts[taskName][normalizeStageName(stage)] = timeout
+}
+
+// SetStuckTaskPolicy sets how SweepStuckTasks treats tasks without stage timeout,
+// tasks staying in one stage longer than threshold are reported, and failed if autoFail
+func SetStuckTaskPolicy(threshold time.Duration, autoFail bool) {
+	stuckTaskThreshold = threshold
+	autoFailStuckTasks = autoFail
+}
+
+func normalizeStageName(stage string) string {
+	// Kebab2Camel lowers all but the first letter, keep camel names as they are
+	if strings.Contains(stage, "_") {
+		return utils.Kebab2Camel(stage, "_")
+	}
+	return stage
+}
+
+func getStageTimeout(taskName, stage string) (time.Duration, bool) {
+	timeouts, ok := taskStageTimeouts[taskName]
+	if !ok {
+		return 0, false
+	}
+	timeout, ok := timeouts[normalizeStageName(stage)]
+	return timeout, ok && timeout > 0
+}
+
+func minStuckDuration() time.Duration {
+	ret := stuckTaskThreshold
+	for _, timeouts := range taskStageTimeouts {
+		for _, timeout := range timeouts {
+			if timeout > 0 && (ret <= 0 || timeout < ret) {
+				ret = timeout
+			}
+		}
+	}
+	return ret
+}
+
+// GetStageStartTime returns the time when the task entered current stage
+func (self *STask) GetStageStartTime() time.Time {
+	stages, _ := self.Params.GetArray("__stages")
+	if len(stages) > 0 {
+		startAt, err := stages[len(stages)-1].GetTime("complete_at")
+		if err == nil {
+			return startAt
+		}
+	}
+	return self.CreatedAt
+}
+
+// AddTaskCronJobs registers cron jobs which sweep timeout and stuck tasks, and resume
+// pending stage retries. Every service running tasks should call it
+func AddTaskCronJobs(cron *cronman.SCronJobManager, opts *options.DBOptions) {
+	if opts.StuckTaskCheckIntervalSeconds > 0 {
+		SetStuckTaskPolicy(time.Duration(opts.StuckTaskThresholdHours)*time.Hour, opts.AutoFailStuckTasks)
+		cron.AddJobAtIntervals("SweepStuckTasks", time.Duration(opts.StuckTaskCheckIntervalSeconds)*time.Second, TaskManager.SweepStuckTasks)
+	}
+	cron.AddJobAtIntervalsWithStartRun("ResumeStageRetries", RESUME_STAGE_RETRY_INTERVAL, TaskManager.ResumeStageRetries, true)
+}
+
+// SweepStuckTasks is a cron job which fails tasks with expired stage and reports
+// tasks staying in one stage longer than the stuck threshold. At most
+// SWEEP_STUCK_TASK_BATCH tasks are checked each run in order of update time and id,
+// following runs continue from where the last one stopped
+func (manager *STaskManager) SweepStuckTasks(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	minDuration := minStuckDuration()
+	if minDuration <= 0 {
+		return
+	}
+	now := timeutils.UtcNow()
+	q := manager.Query()
+	q = q.Filter(sqlchemy.NOT(sqlchemy.In(q.Field("stage"), []string{TASK_STAGE_COMPLETE, TASK_STAGE_FAILED})))
+	// stage of the task started no later than it was last updated
+	q = q.LT("updated_at", now.Add(-minDuration))
+	if !stuckTaskSweepCursor.IsZero() {
+		// tasks updated at the same time may be split across batches
+		q = q.Filter(sqlchemy.OR(
+			sqlchemy.GT(q.Field("updated_at"), stuckTaskSweepCursor),
+			sqlchemy.AND(
+				sqlchemy.Equals(q.Field("updated_at"), stuckTaskSweepCursor),
+				sqlchemy.GT(q.Field("id"), stuckTaskSweepCursorId),
+			),
+		))
+	}
+	q = q.Asc("updated_at").Asc("id").Limit(SWEEP_STUCK_TASK_BATCH)
+	tasks := make([]STask, 0)
+	err := db.FetchModelObjects(manager, q, &tasks)
+	if err != nil {
+		log.Errorf("fetch open tasks: %v", err)
+		return
+	}
+	if len(tasks) < SWEEP_STUCK_TASK_BATCH {
+		stuckTaskSweepCursor, stuckTaskSweepCursorId = time.Time{}, ""
+	} else {
+		last := tasks[len(tasks)-1]
+		stuckTaskSweepCursor, stuckTaskSweepCursorId = last.UpdatedAt, last.Id
+	}
+	for i := range tasks {
+		task := &tasks[i]
+		if task.Params == nil {
+			task.Params = jsonutils.NewDict()
+		}
+		if task.isAborted() {
+			// waiting for failed handler scheduled by previous abort
+			continue
+		}
+		elapsed := now.Sub(task.GetStageStartTime())
+		if timeout, ok := getStageTimeout(task.TaskName, task.Stage); ok && elapsed > timeout {
+			err := task.abort(ctx, fmt.Sprintf("stage %s timeout after %s", task.Stage, timeout))
+			if err != nil {
+				log.Errorf("abort timeout task %s(%s): %v", task.TaskName, task.Id, err)
+			}
+			continue
+		}
+		if stuckTaskThreshold <= 0 || elapsed <= stuckTaskThreshold {
+			continue
+		}
+		if reported, _ := task.Params.GetString(STUCK_REPORTED_STAGE_KEY); reported != task.Stage {
+			manager.reportStuckTask(ctx, task, elapsed)
+		}
+		if autoFailStuckTasks {
+			err := task.abort(ctx, fmt.Sprintf("stuck in stage %s for %s", task.Stage, elapsed))
+			if err != nil {
+				log.Errorf("abort stuck task %s(%s): %v", task.TaskName, task.Id, err)
+			}
+		}
+	}
+}
+
+func (manager *STaskManager) reportStuckTask(ctx context.Context, task *STask, elapsed time.Duration) {
+	log.Warningf("task %s(%s) of %s %s stuck in stage %s for %s", task.TaskName, task.Id, task.ObjName, task.ObjId, task.Stage, elapsed)
+	data := jsonutils.NewDict()
+	data.Add(jsonutils.NewString(task.TaskName), "task_name")
+	data.Add(jsonutils.NewString(task.Id), "task_id")
+	data.Add(jsonutils.NewString(task.ObjName), "obj_name")
+	data.Add(jsonutils.NewString(task.GetObjectIdStr()), "obj_id")
+	data.Add(jsonutils.NewString(task.Stage), "stage")
+	data.Add(jsonutils.NewString(elapsed.String()), "elapsed")
+	notifyclient.SystemExceptionNotify(ctx, api.ActionSystemException, api.TOPIC_RESOURCE_TASK, data)
+
+	reported := jsonutils.NewDict()
+	reported.Add(jsonutils.NewString(task.Stage), STUCK_REPORTED_STAGE_KEY)
+	task.SaveParams(reported)
+}
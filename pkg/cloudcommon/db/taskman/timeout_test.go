@@ -0,0 +1,91 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/sqlchemy"
+)
+
+type timeoutTestTask struct {
+	STask
+}
+
+func TestGetStageTimeout(t *testing.T) {
+	RegisterStageTimeout(timeoutTestTask{}, "OnDeployComplete", time.Minute)
+	RegisterStageTimeout(timeoutTestTask{}, "on_start_complete", 2*time.Minute)
+	cases := []struct {
+		stage string
+		want  time.Duration
+		ok    bool
+	}{
+		{"OnDeployComplete", time.Minute, true},
+		{"on_deploy_complete", time.Minute, true},
+		{"OnStartComplete", 2 * time.Minute, true},
+		{"on_start_complete", 2 * time.Minute, true},
+		{"OnDeploy", 0, false},
+	}
+	for _, c := range cases {
+		got, ok := getStageTimeout("timeoutTestTask", c.stage)
+		if got != c.want || ok != c.ok {
+			t.Errorf("stage %s: want %s %v, got %s %v", c.stage, c.want, c.ok, got, ok)
+		}
+	}
+}
+
+type sweepTestTask struct {
+	STask
+}
+
+func TestSweepStuckTasks(t *testing.T) {
+	initTaskTestDB(t)
+	RegisterStageTimeout(sweepTestTask{}, "OnWait", time.Minute)
+	stuckTaskSweepCursor, stuckTaskSweepCursorId = time.Time{}, ""
+
+	// one more than a batch, all entered the stage at the same time
+	tasks := make([]*STask, 0, SWEEP_STUCK_TASK_BATCH+1)
+	for i := 0; i <= SWEEP_STUCK_TASK_BATCH; i++ {
+		tasks = append(tasks, newTestTask(t, fmt.Sprintf("sweep-%04d", i), "sweepTestTask", "OnWait", jsonutils.NewDict()))
+	}
+	fresh := newTestTask(t, "sweep-fresh", "sweepTestTask", "OnWait", jsonutils.NewDict())
+	defer func() {
+		sqlchemy.Exec("DELETE FROM tasks_tbl WHERE task_name = ?", "sweepTestTask")
+	}()
+	expired := time.Now().UTC().Add(-time.Hour)
+	_, err := sqlchemy.Exec("UPDATE tasks_tbl SET created_at = ?, updated_at = ? WHERE task_name = ? AND id != ?", expired, expired, "sweepTestTask", fresh.Id)
+	if err != nil {
+		t.Fatalf("expire tasks: %v", err)
+	}
+
+	TaskManager.SweepStuckTasks(context.Background(), nil, false)
+	TaskManager.SweepStuckTasks(context.Background(), nil, false)
+
+	for _, task := range tasks {
+		if task = TaskManager.fetchTask(task.Id); !task.isAborted() {
+			t.Fatalf("expect task %s aborted, got %s", task.Id, task.Params)
+		}
+		if stage, _ := task.Params.GetString(TASK_CANCELLED_STAGE_KEY); stage != "OnWait" {
+			t.Fatalf("expect task %s cancelled on stage OnWait, got %s", task.Id, task.Params)
+		}
+	}
+	if fresh = TaskManager.fetchTask(fresh.Id); fresh.IsCancelled() {
+		t.Errorf("expect task %s in time not aborted, got %s", fresh.Id, fresh.Params)
+	}
+}
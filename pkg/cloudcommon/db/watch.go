@@ -0,0 +1,411 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
+	"yunion.io/x/sqlchemy"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/appsrv/dispatcher"
+	"yunion.io/x/onecloud/pkg/cloudcommon/policy"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+const (
+	// number of recent events kept for resuming watches
+	WATCH_EVENT_BUFFER_SIZE = 4096
+	// number of pending events of a watcher before it is considered too slow
+	WATCH_CHANNEL_SIZE = 256
+
+	WATCH_WORKER_COUNT = 128
+
+	WATCH_DEFAULT_TIMEOUT = 30 * time.Minute
+	WATCH_MAX_TIMEOUT     = 2 * time.Hour
+
+	// low bits of resource version counting events, high bits identify the
+	// process issuing it. 53 bits in total keep versions exact in javascript
+	WATCH_VERSION_COUNTER_BITS = 37
+	WATCH_VERSION_REPLICA_BITS = 16
+)
+
+var (
+	watchHub = newWatchHub()
+
+	watchWorkerMan *appsrv.SWorkerManager
+)
+
+func init() {
+	watchWorkerMan = appsrv.NewWorkerManager("watch_worker", WATCH_WORKER_COUNT, 16, false)
+}
+
+type sWatchEvent struct {
+	version       uint64
+	event         string
+	keywordPlural string
+	id            string
+	name          string
+}
+
+type sWatcher struct {
+	keywordPlural string
+	events        chan *sWatchEvent
+	overflow      bool
+}
+
+// sWatchHub numbers the changes of the models of this process and fans them
+// out to the watchers. Only changes written through this process are seen,
+// watchers of deployments with more than one api server should stick to one.
+// Resource versions carry a random replica id of the process, so that
+// versions issued by another server or before a restart are reported as
+// expired instead of resuming from a backlog they do not belong to
+type sWatchHub struct {
+	lock     sync.Mutex
+	version  uint64
+	buffer   []*sWatchEvent
+	head     int
+	watchers map[*sWatcher]bool
+}
+
+// newWatchReplicaId returns a random non-zero replica id. Package level rand
+// is not seeded yet when the hub is created
+func newWatchReplicaId() uint64 {
+	r := rand.New(rand.NewSource(time.Now().UnixNano() ^ int64(os.Getpid())))
+	return uint64(r.Intn(1<<WATCH_VERSION_REPLICA_BITS-1) + 1)
+}
+
+func newWatchHub() *sWatchHub {
+	return &sWatchHub{
+		version:  newWatchReplicaId() << WATCH_VERSION_COUNTER_BITS,
+		buffer:   make([]*sWatchEvent, 0, WATCH_EVENT_BUFFER_SIZE),
+		watchers: make(map[*sWatcher]bool),
+	}
+}
+
+func (hub *sWatchHub) publish(keywordPlural, event, id, name string) {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	hub.version += 1
+	ev := &sWatchEvent{
+		version:       hub.version,
+		event:         event,
+		keywordPlural: keywordPlural,
+		id:            id,
+		name:          name,
+	}
+	if len(hub.buffer) < cap(hub.buffer) {
+		hub.buffer = append(hub.buffer, ev)
+	} else {
+		hub.buffer[hub.head] = ev
+		hub.head = (hub.head + 1) % len(hub.buffer)
+	}
+	for w := range hub.watchers {
+		if w.overflow || w.keywordPlural != keywordPlural {
+			continue
+		}
+		select {
+		case w.events <- ev:
+		default:
+			w.overflow = true
+			close(w.events)
+		}
+	}
+}
+
+// subscribe registers a watcher and returns the buffered events after since
+// together with the current resource version
+func (hub *sWatchHub) subscribe(keywordPlural string, since *uint64) (*sWatcher, []*sWatchEvent, uint64, error) {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	var backlog []*sWatchEvent
+	if since != nil {
+		oldest := hub.version + 1
+		if len(hub.buffer) > 0 {
+			oldest = hub.buffer[hub.head].version
+		}
+		if *since>>WATCH_VERSION_COUNTER_BITS != hub.version>>WATCH_VERSION_COUNTER_BITS {
+			return nil, nil, 0, httperrors.NewResourceExpiredError("resource version %d was issued by another server or before restart", *since)
+		}
+		if *since > hub.version || *since+1 < oldest {
+			return nil, nil, 0, httperrors.NewResourceExpiredError("resource version %d is too old or unknown, current %d", *since, hub.version)
+		}
+		for i := 0; i < len(hub.buffer); i++ {
+			ev := hub.buffer[(hub.head+i)%len(hub.buffer)]
+			if ev.version > *since && ev.keywordPlural == keywordPlural {
+				backlog = append(backlog, ev)
+			}
+		}
+	}
+	w := &sWatcher{
+		keywordPlural: keywordPlural,
+		events:        make(chan *sWatchEvent, WATCH_CHANNEL_SIZE),
+	}
+	hub.watchers[w] = true
+	return w, backlog, hub.version, nil
+}
+
+func (hub *sWatchHub) unsubscribe(w *sWatcher) {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	delete(hub.watchers, w)
+}
+
+func (ts *sTableSpec) notifyWatch(dt interface{}, event string) {
+	obj, ok := dt.(IModel)
+	if !ok || obj.GetModelManager() == nil || len(obj.GetId()) == 0 {
+		return
+	}
+	watchHub.publish(obj.KeywordPlural(), event, obj.GetId(), obj.GetName())
+}
+
+func isWatchRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet && utils.ToBool(r.URL.Query().Get("watch"))
+}
+
+func watchTimeout(seconds int64) time.Duration {
+	timeout := WATCH_DEFAULT_TIMEOUT
+	if seconds > 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+	if timeout > WATCH_MAX_TIMEOUT {
+		timeout = WATCH_MAX_TIMEOUT
+	}
+	return timeout
+}
+
+// watchWorkerManager keeps long running watch requests off the read workers
+func watchWorkerManager(info *appsrv.SHandlerInfo, r *http.Request) *appsrv.SWorkerManager {
+	if isWatchRequest(r) {
+		return watchWorkerMan
+	}
+	return nil
+}
+
+type sModelWatch struct {
+	manager  IModelManager
+	userCred mcclient.TokenCredential
+	query    *jsonutils.JSONDict
+	ids      []string
+	matched  map[string]bool
+	send     func(event *apis.WatchEvent) error
+}
+
+// fetchMatchedIds returns ids of rows of q matching the filters of the watch
+func (w *sModelWatch) fetchMatchedIds(ctx context.Context, q *sqlchemy.SQuery) ([]string, error) {
+	q, err := listItemQueryFiltersRaw(w.manager, ctx, q, w.userCred, w.query.Copy(), policy.PolicyActionList, true, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "listItemQueryFiltersRaw")
+	}
+	sq := q.SubQuery()
+	rows, err := sq.Query(sq.Field("id")).Rows()
+	if err != nil {
+		return nil, errors.Wrap(err, "Rows")
+	}
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "Scan")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// backlogDeletedIds returns ids deleted within the backlog that are not
+// matched now.  They may have been matched as of the resumed version
+func (w *sModelWatch) backlogDeletedIds(backlog []*sWatchEvent) []string {
+	ids := []string{}
+	for _, ev := range backlog {
+		if ev.event == apis.WATCH_EVENT_DELETE && !w.matched[ev.id] && !utils.IsInStringArray(ev.id, ids) {
+			ids = append(ids, ev.id)
+		}
+	}
+	return ids
+}
+
+// rewindMatched turns ids matched now into those matched as of the resumed
+// version, so that the backlog is replayed with the right event types.
+// Ids deleted within the backlog and matching the filters before deletion,
+// as in deletedMatched, were there.  Ids created within the backlog were not
+func (w *sModelWatch) rewindMatched(backlog []*sWatchEvent, deletedMatched []string) {
+	for _, id := range deletedMatched {
+		w.matched[id] = true
+	}
+	seen := make(map[string]bool)
+	for _, ev := range backlog {
+		if seen[ev.id] {
+			continue
+		}
+		seen[ev.id] = true
+		if ev.event == apis.WATCH_EVENT_CREATE {
+			delete(w.matched, ev.id)
+		}
+	}
+}
+
+// fetchObject returns the details of the object as the list API would, or nil
+// if the object no longer matches the filters of the watch
+func (w *sModelWatch) fetchObject(ctx context.Context, id string) (jsonutils.JSONObject, error) {
+	if len(w.ids) > 0 && !utils.IsInStringArray(id, w.ids) {
+		return nil, nil
+	}
+	query := w.query.Copy()
+	query.Set("id", jsonutils.NewStringArray([]string{id}))
+	result, err := ListItems(w.manager, ctx, w.userCred, query, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ListItems %s", id)
+	}
+	if len(result.Data) == 0 {
+		return nil, nil
+	}
+	return result.Data[0], nil
+}
+
+func (w *sModelWatch) handle(ctx context.Context, ev *sWatchEvent) error {
+	event := &apis.WatchEvent{
+		ResourceVersion: ev.version,
+		Id:              ev.id,
+	}
+	if ev.event != apis.WATCH_EVENT_DELETE {
+		obj, err := w.fetchObject(ctx, ev.id)
+		if err != nil {
+			return err
+		}
+		if obj != nil {
+			event.Type = apis.WATCH_EVENT_UPDATE
+			if !w.matched[ev.id] {
+				event.Type = apis.WATCH_EVENT_CREATE
+			}
+			event.Object = obj
+			w.matched[ev.id] = true
+			return w.send(event)
+		}
+	}
+	if !w.matched[ev.id] {
+		return nil
+	}
+	delete(w.matched, ev.id)
+	event.Type = apis.WATCH_EVENT_DELETE
+	obj := jsonutils.NewDict()
+	obj.Set("id", jsonutils.NewString(ev.id))
+	obj.Set("name", jsonutils.NewString(ev.name))
+	event.Object = obj
+	return w.send(event)
+}
+
+func (dispatcher *DBModelDispatcher) Watch(ctx context.Context, query jsonutils.JSONObject, ctxIds []dispatcher.SResourceContext, send func(event *apis.WatchEvent) error) error {
+	userCred := fetchUserCredential(ctx)
+	manager := dispatcher.modelManager
+	if _, ok := manager.(IStandaloneModelManager); !ok {
+		return httperrors.NewNotSupportedError("%s does not support watch", manager.KeywordPlural())
+	}
+
+	queryDict, ok := query.(*jsonutils.JSONDict)
+	if !ok {
+		return httperrors.NewInputParameterError("invalid query format")
+	}
+	var since *uint64
+	if queryDict.Contains("resource_version") {
+		version, err := queryDict.Int("resource_version")
+		if err != nil || version < 0 {
+			return httperrors.NewInputParameterError("invalid resource_version")
+		}
+		v := uint64(version)
+		since = &v
+	}
+	seconds, _ := queryDict.Int("timeout_seconds")
+	ctx, cancel := context.WithTimeout(ctx, watchTimeout(seconds))
+	defer cancel()
+
+	queryDict = queryDict.CopyExcludes("watch", "resource_version", "timeout_seconds", "limit", "offset", "paging_marker", "export_keys")
+	if len(ctxIds) > 0 {
+		var err error
+		queryDict, err = fetchContextObjectsIds(manager, ctx, userCred, ctxIds, queryDict)
+		if err != nil {
+			return err
+		}
+	}
+
+	watcher, backlog, version, err := watchHub.subscribe(manager.KeywordPlural(), since)
+	if err != nil {
+		return err
+	}
+	defer watchHub.unsubscribe(watcher)
+
+	w := &sModelWatch{
+		manager:  manager,
+		userCred: userCred,
+		query:    queryDict,
+		ids:      jsonutils.GetQueryStringArray(queryDict, "id"),
+		matched:  make(map[string]bool),
+		send:     send,
+	}
+	ids, err := w.fetchMatchedIds(ctx, manager.Query())
+	if err != nil {
+		return httperrors.NewGeneralError(err)
+	}
+	for _, id := range ids {
+		w.matched[id] = true
+	}
+	if len(backlog) > 0 {
+		var deletedMatched []string
+		if deletedIds := w.backlogDeletedIds(backlog); len(deletedIds) > 0 {
+			// deleted rows are kept with deleted flag set
+			deletedMatched, err = w.fetchMatchedIds(ctx, manager.RawQuery().In("id", deletedIds))
+			if err != nil {
+				return httperrors.NewGeneralError(err)
+			}
+		}
+		w.rewindMatched(backlog, deletedMatched)
+	}
+	err = send(&apis.WatchEvent{Type: apis.WATCH_EVENT_SYNC, ResourceVersion: version})
+	if err != nil {
+		return nil
+	}
+	for _, ev := range backlog {
+		if err := w.handle(ctx, ev); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-watcher.events:
+			if !ok {
+				return httperrors.NewResourceExpiredError("watcher of %s is too slow to consume events", manager.KeywordPlural())
+			}
+			if err := w.handle(ctx, ev); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db
+
+import (
+	"context"
+	"testing"
+
+	"yunion.io/x/onecloud/pkg/apis"
+)
+
+func TestWatchHub(t *testing.T) {
+	hub := newWatchHub()
+	start := hub.version
+
+	w, backlog, version, err := hub.subscribe("networks", nil)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if len(backlog) != 0 || version != start {
+		t.Errorf("unexpected backlog %d or version %d", len(backlog), version)
+	}
+	hub.publish("networks", apis.WATCH_EVENT_CREATE, "n1", "net1")
+	hub.publish("secgroups", apis.WATCH_EVENT_CREATE, "s1", "sec1")
+	hub.publish("networks", apis.WATCH_EVENT_UPDATE, "n1", "net1")
+	hub.unsubscribe(w)
+
+	if len(w.events) != 2 {
+		t.Fatalf("expect 2 network events, got %d", len(w.events))
+	}
+	ev := <-w.events
+	if ev.id != "n1" || ev.event != apis.WATCH_EVENT_CREATE || ev.version != start+1 {
+		t.Errorf("unexpected event %#v", ev)
+	}
+
+	since := start + 1
+	_, backlog, version, err = hub.subscribe("networks", &since)
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if len(backlog) != 1 || backlog[0].version != start+3 || version != start+3 {
+		t.Errorf("unexpected resume backlog %#v version %d", backlog, version)
+	}
+
+	for i := 0; i < WATCH_EVENT_BUFFER_SIZE; i++ {
+		hub.publish("networks", apis.WATCH_EVENT_UPDATE, "n1", "net1")
+	}
+	if _, _, _, err := hub.subscribe("networks", &since); err == nil {
+		t.Errorf("expect expired resource version")
+	}
+	future := hub.version + 1
+	if _, _, _, err := hub.subscribe("networks", &future); err == nil {
+		t.Errorf("expect unknown resource version")
+	}
+
+	// version in range of this hub, but issued by another replica
+	replica := hub.version >> WATCH_VERSION_COUNTER_BITS
+	counter := hub.version & (1<<WATCH_VERSION_COUNTER_BITS - 1)
+	foreign := (replica%(1<<WATCH_VERSION_REPLICA_BITS-1)+1)<<WATCH_VERSION_COUNTER_BITS | (counter - 1)
+	if _, _, _, err := hub.subscribe("networks", &foreign); err == nil {
+		t.Errorf("expect resource version of another replica expired")
+	}
+	if hub.version >= 1<<53 {
+		t.Errorf("resource version %d exceeds 53 bits", hub.version)
+	}
+}
+
+func TestWatchOverflow(t *testing.T) {
+	hub := newWatchHub()
+	w, _, _, _ := hub.subscribe("networks", nil)
+	for i := 0; i <= WATCH_CHANNEL_SIZE; i++ {
+		hub.publish("networks", apis.WATCH_EVENT_UPDATE, "n1", "net1")
+	}
+	if !w.overflow {
+		t.Fatalf("expect watcher overflow")
+	}
+	cnt := 0
+	for range w.events {
+		cnt++
+	}
+	if cnt != WATCH_CHANNEL_SIZE {
+		t.Errorf("expect %d buffered events, got %d", WATCH_CHANNEL_SIZE, cnt)
+	}
+}
+
+func TestWatchResumeAfterDelete(t *testing.T) {
+	hub := newWatchHub()
+	since := hub.version
+	// n1 matched before the reconnect gap and deleted within it, n2 created
+	// within it and still there
+	hub.publish("networks", apis.WATCH_EVENT_DELETE, "n1", "net1")
+	hub.publish("networks", apis.WATCH_EVENT_CREATE, "n2", "net2")
+	hub.publish("networks", apis.WATCH_EVENT_CREATE, "n3", "net3")
+	hub.publish("networks", apis.WATCH_EVENT_DELETE, "n3", "net3")
+	_, backlog, _, err := hub.subscribe("networks", &since)
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+
+	var events []*apis.WatchEvent
+	w := &sModelWatch{
+		matched: map[string]bool{"n2": true},
+		send: func(event *apis.WatchEvent) error {
+			events = append(events, event)
+			return nil
+		},
+	}
+	deletedIds := w.backlogDeletedIds(backlog)
+	if len(deletedIds) != 2 || deletedIds[0] != "n1" || deletedIds[1] != "n3" {
+		t.Fatalf("unexpected deleted ids %v", deletedIds)
+	}
+	// n3 matches the filters as well, but was created within the gap
+	w.rewindMatched(backlog, []string{"n1", "n3"})
+	if !w.matched["n1"] || w.matched["n2"] || w.matched["n3"] {
+		t.Fatalf("unexpected matched ids %v", w.matched)
+	}
+	for _, ev := range []*sWatchEvent{backlog[0], backlog[3]} {
+		if err := w.handle(context.Background(), ev); err != nil {
+			t.Fatalf("handle: %v", err)
+		}
+	}
+	if len(events) != 1 || events[0].Type != apis.WATCH_EVENT_DELETE || events[0].Id != "n1" {
+		t.Fatalf("expect delete event of n1, got %#v", events)
+	}
+}
@@ -165,6 +165,7 @@ func (b *EtcdBackend) PutWithLease(ctx context.Context, key, val string, ttlSeco
 }
 
 func (b *EtcdBackend) onKeepaliveFailure() {
+	informerKeepaliveFailures.Inc()
 	if err := b.client.RestartSession(); err != nil {
 		log.Errorf("restart etcd session error: %v", err)
 		return
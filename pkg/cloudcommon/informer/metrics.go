@@ -0,0 +1,56 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package informer
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"yunion.io/x/onecloud/pkg/appsrv"
+)
+
+var (
+	informerEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "informer",
+			Name:      "events_total",
+			Help:      "Number of resource events sent to the informer backend, by result",
+		},
+		[]string{"result"},
+	)
+	informerKeepaliveFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "informer",
+			Name:      "keepalive_failures_total",
+			Help:      "Number of times the informer backend session lost its keepalive",
+		},
+	)
+	informerBackendUp = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: "informer",
+			Name:      "backend_up",
+			Help:      "Whether the informer backend is initialized",
+		},
+		func() float64 {
+			if IsInit() {
+				return 1
+			}
+			return 0
+		},
+	)
+)
+
+func init() {
+	appsrv.RegisterMetricsCollector(informerEvents, informerKeepaliveFailures, informerBackendUp)
+}
@@ -60,7 +60,10 @@ func (t *informerTask) Run() {
 	nopanic.Run(func() {
 		// outside context ignored cause of run in worker
 		if err := t.f(context.Background(), t.be); err != nil {
+			informerEvents.WithLabelValues("error").Inc()
 			log.Errorf("run informer error: %v", err)
+		} else {
+			informerEvents.WithLabelValues("ok").Inc()
 		}
 	})
 }
@@ -72,6 +75,7 @@ func (t *informerTask) Dump() string {
 func run(ctx context.Context, f func(ctx context.Context, be IInformerBackend) error) error {
 	be := GetDefaultBackend()
 	if be == nil {
+		informerEvents.WithLabelValues("not_init").Inc()
 		return ErrBackendNotInit
 	}
 	task := informerTask{
@@ -106,6 +106,9 @@ type BaseOptions struct {
 	GlobalHTTPProxy  string `help:"Global http proxy"`
 	GlobalHTTPSProxy string `help:"Global https proxy"`
 
+	OtlpTracesEndpoint string `help:"OTLP/HTTP endpoint to export trace spans to, e.g. http://otel-collector:4318/v1/traces"`
+	TracingExportFile  string `help:"Local file to append exported trace spans to in OTLP/JSON format, for testing"`
+
 	IgnoreNonrunningGuests bool `default:"true" help:"Count memory for running guests only when do scheduling. Ignore memory allocation for non-running guests"`
 
 	PlatformName  string            `help:"identity name of this platform" default:"Cloudpods"`
@@ -164,6 +167,12 @@ type DBOptions struct {
 	LockmanMethod string `help:"method for lock synchronization" choices:"inmemory|etcd" default:"inmemory"`
 
 	OpsLogMaxKeepMonths int `help:"maximal months of logs to keep, default 6 months" default:"6"`
+
+	IdempotencyKeyRetentionHours int `help:"hours to keep the responses of requests with Idempotency-Key header for replays, default 24 hours" default:"24"`
+
+	StuckTaskCheckIntervalSeconds int  `help:"Interval to check timeout and stuck tasks, 0 to disable, default is 5 minutes" default:"300"`
+	StuckTaskThresholdHours       int  `help:"Task staying in one stage longer than this is reported as stuck, 0 to disable" default:"24"`
+	AutoFailStuckTasks            bool `help:"Drive stuck tasks into failed stage after reported" default:"false"`
 	// SplitableMaxDurationHours int `help:"maximal number of hours that a splitable segement lasts, default 30 days" default:"720"`
 
 	EtcdOptions
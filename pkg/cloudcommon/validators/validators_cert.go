@@ -443,3 +443,83 @@ func (v *ValidatorCertKey) UpdateCertKeyInfo(ctx context.Context, data *jsonutil
 	data.Set("fingerprint", jsonutils.NewString(api.LB_TLS_CERT_FINGERPRINT_ALGO_SHA256+":"+v.ValidatorCertificate.FingerprintSha256String()))
 	return data
 }
+
+// ValidatorCABundle validates a bundle of CA certificates used for
+// verifying peer certificates.  Unlike ValidatorCertificate, certificates
+// in the bundle are not required to form a chain
+type ValidatorCABundle struct {
+	ValidatorPEM
+	Certificates []*x509.Certificate
+}
+
+func NewCABundleValidator(key string) *ValidatorCABundle {
+	v := &ValidatorCABundle{
+		ValidatorPEM: *NewPEMValidator(key),
+	}
+	v.SetParent(v)
+	return v
+}
+
+func (v *ValidatorCABundle) getValue() interface{} {
+	return v.Certificates
+}
+
+func (v *ValidatorCABundle) Validate(data *jsonutils.JSONDict) error {
+	if err, isSet := v.Validator.validateEx(data); err != nil || !isSet {
+		return err
+	}
+	s, err := v.value.GetString()
+	if err != nil {
+		return newInvalidTypeError(v.Key, "ca bundle", err)
+	}
+	blocks := v.ValidatorPEM.parseFromString(s)
+	if len(blocks) == 0 {
+		return newInvalidValueError(v.Key, "empty ca bundle")
+	}
+	certs := make([]*x509.Certificate, 0, len(blocks))
+	pems := []byte{}
+	for i, block := range blocks {
+		if block.Type != "CERTIFICATE" {
+			err := fmt.Errorf("wrong PEM type: %s", block.Type)
+			return newInvalidTypeError(v.Key, "ca bundle", err)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return newInvalidTypeError(v.Key, "ca bundle", err)
+		}
+		if !cert.BasicConstraintsValid || !cert.IsCA {
+			msg := fmt.Sprintf("certificate %d in the bundle is not a CA certificate", i+1)
+			return newInvalidValueError(v.Key, msg)
+		}
+		certs = append(certs, cert)
+		pems = append(pems, pem.EncodeToMemory(block)...)
+	}
+	v.Blocks = blocks
+	v.Certificates = certs
+	v.value = jsonutils.NewString(string(pems))
+	data.Set(v.Key, v.value)
+	return nil
+}
+
+// UpdateCABundleInfo sets derived attributes of the bundle.  Validity
+// period is the intersection of those of all certificates in the bundle
+func (v *ValidatorCABundle) UpdateCABundleInfo(ctx context.Context, data *jsonutils.JSONDict) *jsonutils.JSONDict {
+	cert := v.Certificates[0]
+	notBefore, notAfter := cert.NotBefore, cert.NotAfter
+	h := sha256.New()
+	for _, c := range v.Certificates {
+		if c.NotBefore.After(notBefore) {
+			notBefore = c.NotBefore
+		}
+		if c.NotAfter.Before(notAfter) {
+			notAfter = c.NotAfter
+		}
+		h.Write(c.Raw)
+	}
+	data.Set("common_name", jsonutils.NewString(cert.Subject.CommonName))
+	data.Set("not_before", jsonutils.NewTimeString(notBefore))
+	data.Set("not_after", jsonutils.NewTimeString(notAfter))
+	data.Set("signature_algorithm", jsonutils.NewString(cert.SignatureAlgorithm.String()))
+	data.Set("fingerprint", jsonutils.NewString(api.LB_TLS_CERT_FINGERPRINT_ALGO_SHA256+":"+hex.EncodeToString(h.Sum(nil))))
+	return data
+}
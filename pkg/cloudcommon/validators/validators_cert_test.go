@@ -15,7 +15,16 @@
 package validators
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
 	"testing"
+	"time"
 
 	"yunion.io/x/jsonutils"
 )
@@ -119,3 +128,56 @@ Oycn3Dy2jpdE7SpoBCt3HsIhra8a6h7BcCQ87UwObqTsdLe+7/oD
 		})
 	}
 }
+
+func testGenCertPEM(t *testing.T, cn string, isCA bool) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestValidatorCABundle(t *testing.T) {
+	ca1 := testGenCertPEM(t, "ca1", true)
+	ca2 := testGenCertPEM(t, "ca2", true)
+	leaf := testGenCertPEM(t, "leaf", false)
+	for _, c := range []struct {
+		name    string
+		bundle  string
+		wantErr bool
+	}{
+		{"one ca", ca1, false},
+		{"unrelated cas", ca1 + ca2, false},
+		{"leaf", ca1 + leaf, true},
+		{"empty", "", true},
+		{"garbage", "not a pem", true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			data := jsonutils.NewDict()
+			data.Set("certificate", jsonutils.NewString(c.bundle))
+			v := NewCABundleValidator("certificate")
+			err := v.Validate(data)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, c.wantErr)
+			}
+			if err == nil {
+				v.UpdateCABundleInfo(context.Background(), data)
+				if cn, _ := data.GetString("common_name"); cn != "ca1" {
+					t.Errorf("common_name: got %q", cn)
+				}
+			}
+		})
+	}
+}
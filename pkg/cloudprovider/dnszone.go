@@ -112,6 +112,9 @@ var (
 var (
 	DnsPolicyValueEmpty = TDnsPolicyValue("")
 
+	DnsPolicyValuePrimary   = TDnsPolicyValue("PRIMARY")
+	DnsPolicyValueSecondary = TDnsPolicyValue("SECONDARY")
+
 	DnsPolicyValueUnicom      = TDnsPolicyValue("unicom")
 	DnsPolicyValueTelecom     = TDnsPolicyValue("telecom")
 	DnsPolicyValueChinaMobile = TDnsPolicyValue("chinamobile")
@@ -468,8 +471,8 @@ var AwsRegions = []TDnsPolicyValue{
 }
 
 var AwsFailovers = []TDnsPolicyValue{
-	TDnsPolicyValue("PRIMARY"),
-	TDnsPolicyValue("SECONDARY"),
+	DnsPolicyValuePrimary,
+	DnsPolicyValueSecondary,
 }
 
 type TTlRange struct {
@@ -719,10 +719,28 @@ func checkAssignHost(ctx context.Context, userCred mcclient.TokenCredential, pre
 	return nil
 }
 
+// checkSpdkDisks returns error if guest has disks on spdk storage, which could not leave the host
+func checkSpdkDisks(guest *models.SGuest) error {
+	disks, err := guest.GetDisks()
+	if err != nil {
+		return errors.Wrapf(err, "GetDisks")
+	}
+	for _, disk := range disks {
+		storage, _ := disk.GetStorage()
+		if storage != nil && storage.StorageType == api.STORAGE_SPDK {
+			return httperrors.NewBadRequestError("Cannot migrate with disk %s on %s storage", disk.Name, api.STORAGE_SPDK)
+		}
+	}
+	return nil
+}
+
 func (self *SKVMGuestDriver) CheckMigrate(ctx context.Context, guest *models.SGuest, userCred mcclient.TokenCredential, input api.GuestMigrateInput) error {
 	if len(guest.BackupHostId) > 0 {
 		return httperrors.NewBadRequestError("Guest have backup, can't migrate")
 	}
+	if err := checkSpdkDisks(guest); err != nil {
+		return err
+	}
 	if !input.IsRescueMode && guest.Status != api.VM_READY {
 		return httperrors.NewServerStatusError("Cannot normal migrate guest in status %s, try rescue mode or server-live-migrate?", guest.Status)
 	}
@@ -759,6 +777,9 @@ func (self *SKVMGuestDriver) CheckLiveMigrate(ctx context.Context, guest *models
 	if len(guest.BackupHostId) > 0 {
 		return httperrors.NewBadRequestError("Guest have backup, can't migrate")
 	}
+	if err := checkSpdkDisks(guest); err != nil {
+		return err
+	}
 	if utils.IsInStringArray(guest.Status, []string{api.VM_RUNNING, api.VM_SUSPEND}) {
 		cdrom := guest.GetCdrom()
 		if cdrom != nil && len(cdrom.ImageId) > 0 {
@@ -179,6 +179,8 @@ func (self *SVirtualizedGuestDriver) Attach2RandomNetwork(guest *models.SGuest,
 		Network:             selNet,
 		PendingUsage:        pendingUsage,
 		IpAddr:              netConfig.Address,
+		Ip6Addr:             netConfig.Address6,
+		RequireIPv6:         netConfig.RequireIPv6,
 		NicDriver:           netConfig.Driver,
 		BwLimit:             netConfig.BwLimit,
 		Virtual:             netConfig.Vip,
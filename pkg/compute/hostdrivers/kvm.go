@@ -60,7 +60,7 @@ func (self *SKVMHostDriver) GetHypervisor() string {
 }
 
 func (self *SKVMHostDriver) ValidateAttachStorage(ctx context.Context, userCred mcclient.TokenCredential, host *models.SHost, storage *models.SStorage, input api.HostStorageCreateInput) (api.HostStorageCreateInput, error) {
-	if !utils.IsInStringArray(storage.StorageType, append([]string{api.STORAGE_LOCAL}, api.SHARED_STORAGE...)) {
+	if !utils.IsInStringArray(storage.StorageType, append([]string{api.STORAGE_LOCAL}, api.HOST_ATTACH_STORAGE...)) {
 		return input, httperrors.NewUnsupportOperationError("Unsupport attach %s storage for %s host", storage.StorageType, host.HostType)
 	}
 	if storage.StorageType == api.STORAGE_RBD {
@@ -69,6 +69,23 @@ func (self *SKVMHostDriver) ValidateAttachStorage(ctx context.Context, userCred
 		}
 		pool, _ := storage.StorageConf.GetString("pool")
 		input.MountPoint = fmt.Sprintf("rbd:%s", pool)
+	} else if storage.StorageType == api.STORAGE_SPDK {
+		if host.HostStatus != api.HOST_ONLINE {
+			return input, httperrors.NewInvalidStatusError("Attach spdk storage require host status is online")
+		}
+		// lvstore lives on local nvme of one host
+		count, err := models.HoststorageManager.Query().Equals("storage_id", storage.Id).CountWithError()
+		if err != nil {
+			return input, httperrors.NewInternalServerError("Query host storage error %s", err)
+		}
+		if count > 0 {
+			return input, httperrors.NewBadRequestError("Spdk storage %s has already been attached to host", storage.Name)
+		}
+		if host.GetLocalStoragecache() == nil {
+			return input, httperrors.NewBadRequestError("Host %s has no local storagecache for spdk storage", host.Name)
+		}
+		lvstore, _ := storage.StorageConf.GetString("spdk_lvstore")
+		input.MountPoint = fmt.Sprintf("spdk:%s", lvstore)
 	} else if utils.IsInStringArray(storage.StorageType, api.SHARED_FILE_STORAGE) {
 		if len(input.MountPoint) == 0 {
 			return input, httperrors.NewMissingParameterError("mount_point")
@@ -104,8 +121,22 @@ func (self *SKVMHostDriver) ValidateAttachStorage(ctx context.Context, userCred
 
 func (self *SKVMHostDriver) RequestAttachStorage(ctx context.Context, hoststorage *models.SHoststorage, host *models.SHost, storage *models.SStorage, task taskman.ITask) error {
 	taskman.LocalTaskRun(task, func() (jsonutils.JSONObject, error) {
-		if utils.IsInStringArray(storage.StorageType, api.SHARED_STORAGE) {
+		if utils.IsInStringArray(storage.StorageType, api.HOST_ATTACH_STORAGE) {
 			log.Infof("Attach SharedStorage[%s] on host %s ...", storage.Name, host.Name)
+			if storage.StorageType == api.STORAGE_SPDK {
+				// spdk disks are created from images cached on host local storagecache
+				sc := host.GetLocalStoragecache()
+				if sc == nil {
+					return nil, errors.Errorf("host %s has no local storagecache", host.Name)
+				}
+				_, err := db.Update(storage, func() error {
+					storage.StoragecacheId = sc.Id
+					return nil
+				})
+				if err != nil {
+					return nil, errors.Wrap(err, "update storagecache")
+				}
+			}
 			url := fmt.Sprintf("%s/storages/attach", host.ManagerUri)
 			headers := mcclient.GetTokenHeaders(task.GetUserCred())
 			data := map[string]interface{}{
@@ -132,7 +163,7 @@ func (self *SKVMHostDriver) RequestAttachStorage(ctx context.Context, hoststorag
 
 func (self *SKVMHostDriver) RequestDetachStorage(ctx context.Context, host *models.SHost, storage *models.SStorage, task taskman.ITask) error {
 	taskman.LocalTaskRun(task, func() (jsonutils.JSONObject, error) {
-		if utils.IsInStringArray(storage.StorageType, api.SHARED_STORAGE) && host.HostStatus == api.HOST_ONLINE {
+		if utils.IsInStringArray(storage.StorageType, api.HOST_ATTACH_STORAGE) && host.HostStatus == api.HOST_ONLINE {
 			log.Infof("Detach SharedStorage[%s] on host %s ...", storage.Name, host.Name)
 			url := fmt.Sprintf("%s/storages/detach", host.ManagerUri)
 			headers := mcclient.GetTokenHeaders(task.GetUserCred())
@@ -240,10 +240,14 @@ type IBillingModel interface {
 }
 
 func fetchExpiredModels(manager db.IModelManager, advanceDay int) ([]IBillingModel, error) {
+	return fetchExpiredModelsByField(manager, "expired_at", advanceDay)
+}
+
+func fetchExpiredModelsByField(manager db.IModelManager, field string, advanceDay int) ([]IBillingModel, error) {
 	upLimit := time.Now().AddDate(0, 0, advanceDay)
 	downLimit := time.Now().AddDate(0, 0, advanceDay-1)
 	v := reflect.MakeSlice(reflect.SliceOf(manager.TableSpec().DataType()), 0, 0)
-	q := manager.Query().LE("expired_at", upLimit).GE("expired_at", downLimit)
+	q := manager.Query().LE(field, upLimit).GE(field, downLimit)
 
 	vp := reflect.New(v.Type())
 	vp.Elem().Set(v)
@@ -294,6 +298,7 @@ func CheckBillingResourceExpireAt(ctx context.Context, userCred mcclient.TokenCr
 		GuestManager,
 		DBInstanceManager,
 		ElasticcacheManager,
+		LoadbalancerCertificateManager,
 	}
 	for _, advanceDay := range advanceDays {
 		for _, manager := range billingResourceManagers {
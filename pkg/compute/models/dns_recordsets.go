@@ -19,6 +19,7 @@ import (
 	"database/sql"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"yunion.io/x/jsonutils"
@@ -80,11 +81,41 @@ type SDnsRecordSetValidateInfo struct {
 	TrafficPolicies []api.DnsRecordPolicy
 }
 
+// validateOnecloudDnsrecordPolicy validates policies of provider OneCloud,
+// which are honored by region dns answering private zones
+func validateOnecloudDnsrecordPolicy(dnsZone *SDnsZone, policy api.DnsRecordPolicy) error {
+	if cloudprovider.TDnsZoneType(dnsZone.ZoneType) != cloudprovider.PrivateZone {
+		return httperrors.NewNotSupportedError("%s %s not supported traffic policy", policy.Provider, dnsZone.ZoneType)
+	}
+	switch cloudprovider.TDnsPolicyType(policy.PolicyType) {
+	case cloudprovider.DnsPolicyTypeSimple:
+	case cloudprovider.DnsPolicyTypeWeighted:
+		weight, err := strconv.Atoi(policy.PolicyValue)
+		if err != nil || weight < 0 || weight > api.DNS_RECORDSET_MAX_WEIGHT {
+			return httperrors.NewInputParameterError("invalid weight %q, should be integer between 0 and %d", policy.PolicyValue, api.DNS_RECORDSET_MAX_WEIGHT)
+		}
+	case cloudprovider.DnsPolicyTypeFailover:
+		if isIn, _ := utils.InArray(cloudprovider.TDnsPolicyValue(policy.PolicyValue), cloudprovider.AwsFailovers); !isIn {
+			return httperrors.NewNotSupportedError("%s %s %s not support %s", policy.Provider, dnsZone.ZoneType, policy.PolicyType, policy.PolicyValue)
+		}
+	default:
+		return httperrors.NewNotSupportedError("%s %s not supported policy type %s", policy.Provider, dnsZone.ZoneType, policy.PolicyType)
+	}
+	return nil
+}
+
 func validateDnsrecordPolicy(dnsType string, dnsZone *SDnsZone, trafficPolicies []api.DnsRecordPolicy) error {
 	for _, policy := range trafficPolicies {
 		if len(policy.Provider) == 0 {
 			return httperrors.NewGeneralError(fmt.Errorf("missing traffic policy provider"))
 		}
+		if policy.Provider == api.CLOUD_PROVIDER_ONECLOUD {
+			err := validateOnecloudDnsrecordPolicy(dnsZone, policy)
+			if err != nil {
+				return err
+			}
+			continue
+		}
 		factory, err := cloudprovider.GetProviderFactory(policy.Provider)
 		if err != nil {
 			return httperrors.NewGeneralError(errors.Wrapf(err, "invalid provider %s for traffic policy", policy.Provider))
@@ -256,6 +287,7 @@ func (self *SDnsRecordSet) PostCreate(ctx context.Context, userCred mcclient.Tok
 		Action: notifyclient.ActionCreate,
 	})
 	dnsZone.DoSyncRecords(ctx, userCred)
+	dnsZone.incSerial(ctx)
 }
 
 // DNS记录列表
@@ -475,6 +507,16 @@ func (self *SDnsRecordSet) PreDelete(ctx context.Context, userCred mcclient.Toke
 	dnsZone.DoSyncRecords(ctx, userCred)
 }
 
+func (self *SDnsRecordSet) PostDelete(ctx context.Context, userCred mcclient.TokenCredential) {
+	self.SEnabledStatusStandaloneResourceBase.PostDelete(ctx, userCred)
+
+	dnsZone, err := self.GetDnsZone()
+	if err != nil {
+		return
+	}
+	dnsZone.incSerial(ctx)
+}
+
 // 更新
 func (self *SDnsRecordSet) ValidateUpdateData(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.DnsRecordSetUpdateInput) (api.DnsRecordSetUpdateInput, error) {
 	var err error
@@ -625,6 +667,7 @@ func (self *SDnsRecordSet) PostUpdate(ctx context.Context, userCred mcclient.Tok
 	logclient.AddSimpleActionLog(self, logclient.ACT_UPDATE, data, userCred, true)
 	notifyclient.NotifyWebhook(ctx, userCred, self, notifyclient.ActionUpdate)
 	dnsZone.DoSyncRecords(ctx, userCred)
+	dnsZone.incSerial(ctx)
 }
 
 func (self *SDnsRecordSet) GetDnsTrafficPolicies() ([]SDnsTrafficPolicy, error) {
@@ -666,6 +709,126 @@ func (self *SDnsRecordSet) GetDefaultDnsTrafficPolicy(provider string) (cloudpro
 	return cloudprovider.DnsPolicyTypeSimple, cloudprovider.DnsPolicyValueEmpty, nil, nil
 }
 
+// GetEnabledDnsRecordSetsByNames returns enabled records of any of names
+func (self *SDnsZone) GetEnabledDnsRecordSetsByNames(names []string) ([]SDnsRecordSet, error) {
+	records := []SDnsRecordSet{}
+	q := DnsRecordSetManager.Query().Equals("dns_zone_id", self.Id).In("name", names).IsTrue("enabled")
+	err := db.FetchModelObjects(DnsRecordSetManager, q, &records)
+	if err != nil {
+		return nil, errors.Wrapf(err, "db.FetchModelObjects")
+	}
+	return records, nil
+}
+
+// FetchDnsTrafficPolicies returns traffic policies of provider indexed by id
+// of record sets
+func (manager *SDnsRecordSetManager) FetchDnsTrafficPolicies(recordIds []string, provider string) (map[string]SDnsTrafficPolicy, error) {
+	recordPolicies := []SDnsRecordSetTrafficPolicy{}
+	q := DnsRecordSetTrafficPolicyManager.Query().In("dns_recordset_id", recordIds)
+	err := db.FetchModelObjects(DnsRecordSetTrafficPolicyManager, q, &recordPolicies)
+	if err != nil {
+		return nil, errors.Wrapf(err, "db.FetchModelObjects")
+	}
+	ret := map[string]SDnsTrafficPolicy{}
+	if len(recordPolicies) == 0 {
+		return ret, nil
+	}
+	policyIds := make([]string, len(recordPolicies))
+	for i := range recordPolicies {
+		policyIds[i] = recordPolicies[i].DnsTrafficPolicyId
+	}
+	policies := map[string]SDnsTrafficPolicy{}
+	err = db.FetchStandaloneObjectsByIds(DnsTrafficPolicyManager, policyIds, &policies)
+	if err != nil {
+		return nil, errors.Wrapf(err, "db.FetchStandaloneObjectsByIds")
+	}
+	for _, recordPolicy := range recordPolicies {
+		policy, ok := policies[recordPolicy.DnsTrafficPolicyId]
+		if ok && policy.Provider == provider {
+			ret[recordPolicy.DnsRecordsetId] = policy
+		}
+	}
+	return ret, nil
+}
+
+func (self *SDnsZone) GetDnsRecordSetsByName(name string, dnsType string) ([]SDnsRecordSet, error) {
+	records := []SDnsRecordSet{}
+	q := DnsRecordSetManager.Query().Equals("dns_zone_id", self.Id).Equals("name", name)
+	if len(dnsType) > 0 {
+		q = q.Equals("dns_type", dnsType)
+	}
+	err := db.FetchModelObjects(DnsRecordSetManager, q, &records)
+	if err != nil {
+		return nil, errors.Wrapf(err, "db.FetchModelObjects")
+	}
+	return records, nil
+}
+
+// AddDnsRecordSet adds a record on behalf of a dynamic update. As RFC 2136
+// requires, a duplicated record only has its ttl updated, a CNAME replaces
+// the existing one and records conflicting with CNAME are ignored silently.
+func (self *SDnsZone) AddDnsRecordSet(ctx context.Context, userCred mcclient.TokenCredential, input api.SDnsRecordSet) (bool, error) {
+	err := input.ValidateDnsrecordValue()
+	if err != nil {
+		return false, err
+	}
+	records, err := self.GetDnsRecordSetsByName(input.Name, "")
+	if err != nil {
+		return false, errors.Wrapf(err, "GetDnsRecordSetsByName")
+	}
+	for i := range records {
+		rec := &records[i]
+		isCNAME := rec.DnsType == string(cloudprovider.DnsTypeCNAME)
+		if isCNAME != (input.DnsType == string(cloudprovider.DnsTypeCNAME)) {
+			return false, nil
+		}
+		if rec.DnsType != input.DnsType {
+			continue
+		}
+		if isCNAME || (rec.DnsValue == input.DnsValue && rec.MxPriority == input.MxPriority) {
+			diff, err := db.Update(rec, func() error {
+				rec.DnsValue = input.DnsValue
+				rec.TTL = input.TTL
+				return nil
+			})
+			if err != nil {
+				return false, errors.Wrapf(err, "db.Update")
+			}
+			db.OpsLog.LogEvent(rec, db.ACT_UPDATE, diff, userCred)
+			return len(diff) > 0, nil
+		}
+	}
+
+	record := &SDnsRecordSet{}
+	record.SetModelManager(DnsRecordSetManager, record)
+	record.DnsZoneId = self.Id
+	record.Name = input.Name
+	record.Status = api.DNS_RECORDSET_STATUS_AVAILABLE
+	record.Enabled = tristate.True
+	record.DnsType = input.DnsType
+	record.DnsValue = input.DnsValue
+	record.TTL = input.TTL
+	record.MxPriority = input.MxPriority
+	err = DnsRecordSetManager.TableSpec().Insert(ctx, record)
+	if err != nil {
+		return false, errors.Wrapf(err, "Insert")
+	}
+	db.OpsLog.LogEvent(record, db.ACT_CREATE, record.GetShortDesc(ctx), userCred)
+	return true, nil
+}
+
+// RemoveDnsRecordSets removes records on behalf of a dynamic update
+func (self *SDnsZone) RemoveDnsRecordSets(ctx context.Context, userCred mcclient.TokenCredential, records []SDnsRecordSet) error {
+	for i := range records {
+		err := records[i].Delete(ctx, userCred)
+		if err != nil {
+			return errors.Wrapf(err, "Delete record %s(%s)", records[i].Name, records[i].Id)
+		}
+		db.OpsLog.LogEvent(&records[i], db.ACT_DELETE, records[i].GetShortDesc(ctx), userCred)
+	}
+	return nil
+}
+
 func (self *SDnsRecordSet) GetDnsZone() (*SDnsZone, error) {
 	dnsZone, err := DnsZoneManager.FetchById(self.DnsZoneId)
 	if err != nil {
@@ -752,6 +915,7 @@ func (self *SDnsRecordSet) PerformEnable(ctx context.Context, userCred mcclient.
 		return nil, httperrors.NewGeneralError(errors.Wrapf(err, "GetDnsZone"))
 	}
 	dnsZone.DoSyncRecords(ctx, userCred)
+	dnsZone.incSerial(ctx)
 	return nil, nil
 }
 
@@ -766,6 +930,7 @@ func (self *SDnsRecordSet) PerformDisable(ctx context.Context, userCred mcclient
 		return nil, httperrors.NewGeneralError(errors.Wrapf(err, "GetDnsZone"))
 	}
 	dnsZone.DoSyncRecords(ctx, userCred)
+	dnsZone.incSerial(ctx)
 	return nil, nil
 }
 
@@ -778,34 +943,11 @@ func (self *SDnsRecordSet) PerformSetTrafficPolicies(ctx context.Context, userCr
 	if err != nil {
 		return nil, httperrors.NewGeneralError(errors.Wrapf(err, "GetDnsZone"))
 	}
+	err = validateDnsrecordPolicy(self.DnsType, dnsZone, input.TrafficPolicies)
+	if err != nil {
+		return nil, err
+	}
 	for _, policy := range input.TrafficPolicies {
-		if len(policy.Provider) == 0 {
-			return nil, httperrors.NewGeneralError(fmt.Errorf("missing traffic policy provider"))
-		}
-		factory, err := cloudprovider.GetProviderFactory(policy.Provider)
-		if err != nil {
-			return nil, httperrors.NewGeneralError(errors.Wrapf(err, "invalid provider %s for traffic policy", policy.Provider))
-		}
-		_dnsTypes := factory.GetSupportedDnsTypes()
-		dnsTypes, _ := _dnsTypes[cloudprovider.TDnsZoneType(dnsZone.ZoneType)]
-		if ok, _ := utils.InArray(cloudprovider.TDnsType(self.DnsType), dnsTypes); !ok {
-			return nil, httperrors.NewNotSupportedError("%s %s not supported dns type %s", policy.Provider, dnsZone.ZoneType, self.DnsType)
-		}
-		_policyTypes := factory.GetSupportedDnsPolicyTypes()
-		policyTypes, _ := _policyTypes[cloudprovider.TDnsZoneType(dnsZone.ZoneType)]
-		if ok, _ := utils.InArray(cloudprovider.TDnsPolicyType(policy.PolicyType), policyTypes); !ok {
-			return nil, httperrors.NewNotSupportedError("%s %s not supported policy type %s", policy.Provider, dnsZone.ZoneType, policy.PolicyType)
-		}
-		_policyValues := factory.GetSupportedDnsPolicyValues()
-		policyValues, _ := _policyValues[cloudprovider.TDnsPolicyType(policy.PolicyType)]
-		if len(policyValues) > 0 {
-			if len(policy.PolicyValue) == 0 {
-				return nil, httperrors.NewMissingParameterError(fmt.Sprintf("missing %s policy value", policy.Provider))
-			}
-			if isIn, _ := utils.InArray(cloudprovider.TDnsPolicyValue(policy.PolicyValue), policyValues); !isIn {
-				return nil, httperrors.NewNotSupportedError("%s %s %s not support %s", policy.Provider, dnsZone.ZoneType, policy.PolicyType, policy.PolicyValue)
-			}
-		}
 		err = self.setTrafficPolicy(ctx, userCred, policy.Provider, cloudprovider.TDnsPolicyType(policy.PolicyType), cloudprovider.TDnsPolicyValue(policy.PolicyValue), policy.PolicyOptions)
 		if err != nil {
 			return nil, httperrors.NewGeneralError(errors.Wrapf(err, "setTrafficPolicy"))
@@ -813,5 +955,6 @@ func (self *SDnsRecordSet) PerformSetTrafficPolicies(ctx context.Context, userCr
 	}
 
 	dnsZone.DoSyncRecords(ctx, userCred)
+	dnsZone.incSerial(ctx)
 	return nil, nil
 }
@@ -0,0 +1,280 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"regexp"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
+	"yunion.io/x/sqlchemy"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/logclient"
+	"yunion.io/x/onecloud/pkg/util/stringutils2"
+)
+
+const (
+	dnsTsigKeySecretLength = 32
+)
+
+var dnsTsigKeyNameRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9_-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9_-]{0,61}[a-z0-9])?)*$`)
+
+// +onecloud:swagger-gen-model-singular=dns_tsig_key
+// +onecloud:swagger-gen-model-plural=dns_tsig_keys
+type SDnsTsigKeyManager struct {
+	db.SVirtualResourceBaseManager
+	SDnsZoneResourceBaseManager
+}
+
+var DnsTsigKeyManager *SDnsTsigKeyManager
+
+func init() {
+	DnsTsigKeyManager = &SDnsTsigKeyManager{
+		SVirtualResourceBaseManager: db.NewVirtualResourceBaseManager(
+			SDnsTsigKey{},
+			"dns_tsig_keys_tbl",
+			"dns_tsig_key",
+			"dns_tsig_keys",
+		),
+	}
+	DnsTsigKeyManager.SetVirtualObject(DnsTsigKeyManager)
+}
+
+// SDnsTsigKey is the TSIG key used by region-dns to authenticate zone
+// transfers and dynamic updates of a dns zone, records changed by dynamic
+// updates are logged on behalf of the project of the key
+type SDnsTsigKey struct {
+	db.SVirtualResourceBase
+	SDnsZoneResourceBase
+
+	// 签名算法
+	Algorithm string `width:"32" charset:"ascii" nullable:"false" default:"hmac-sha256" list:"user" create:"optional"`
+	// base64编码的密钥
+	Secret string `width:"128" charset:"ascii" nullable:"false" get:"user" create:"optional"`
+}
+
+func (manager *SDnsTsigKeyManager) EnableGenerateName() bool {
+	return false
+}
+
+func generateDnsTsigKeySecret() (string, error) {
+	secret := make([]byte, dnsTsigKeySecretLength)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", errors.Wrap(err, "rand.Read")
+	}
+	return base64.StdEncoding.EncodeToString(secret), nil
+}
+
+// 创建
+func (manager *SDnsTsigKeyManager) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, input api.DnsTsigKeyCreateInput) (api.DnsTsigKeyCreateInput, error) {
+	var err error
+	input.VirtualResourceCreateInput, err = manager.SVirtualResourceBaseManager.ValidateCreateData(ctx, userCred, ownerId, query, input.VirtualResourceCreateInput)
+	if err != nil {
+		return input, err
+	}
+	// TSIG key name is carried in the TSIG record, it must be a domain name
+	// and be unique among all keys
+	input.Name = strings.ToLower(strings.TrimSuffix(input.Name, "."))
+	if !dnsTsigKeyNameRegexp.MatchString(input.Name) {
+		return input, httperrors.NewInputParameterError("invalid tsig key name %s", input.Name)
+	}
+	cnt, err := manager.Query().Equals("name", input.Name).CountWithError()
+	if err != nil {
+		return input, httperrors.NewGeneralError(err)
+	}
+	if cnt > 0 {
+		return input, httperrors.NewDuplicateNameError("name", input.Name)
+	}
+
+	if len(input.DnsZoneId) == 0 {
+		return input, httperrors.NewMissingParameterError("dns_zone_id")
+	}
+	_dnsZone, err := DnsZoneManager.FetchByIdOrName(userCred, input.DnsZoneId)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return input, httperrors.NewResourceNotFoundError2("dns_zone", input.DnsZoneId)
+		}
+		return input, httperrors.NewGeneralError(err)
+	}
+	dnsZone := _dnsZone.(*SDnsZone)
+	if dnsZone.DomainId != ownerId.GetProjectDomainId() && !dnsZone.IsSharable(ownerId) {
+		return input, httperrors.NewForbiddenError("dns zone %s is not accessible by project %s", dnsZone.Name, ownerId.GetProjectName())
+	}
+	input.DnsZoneId = dnsZone.Id
+
+	if len(input.Algorithm) == 0 {
+		input.Algorithm = api.DNS_TSIG_ALGORITHM_HMAC_SHA256
+	}
+	if !utils.IsInStringArray(input.Algorithm, api.DNS_TSIG_ALGORITHMS) {
+		return input, httperrors.NewInputParameterError("invalid algorithm %s, supported %s", input.Algorithm, api.DNS_TSIG_ALGORITHMS)
+	}
+	if len(input.Secret) == 0 {
+		input.Secret, err = generateDnsTsigKeySecret()
+		if err != nil {
+			return input, httperrors.NewGeneralError(err)
+		}
+	} else if secret, err := base64.StdEncoding.DecodeString(input.Secret); err != nil || len(secret) < 16 {
+		return input, httperrors.NewInputParameterError("secret must be base64 encoded and at least 16 bytes long")
+	}
+	input.Status = api.DNS_TSIG_KEY_STATUS_AVAILABLE
+	return input, nil
+}
+
+// 更新
+func (self *SDnsTsigKey) ValidateUpdateData(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.DnsTsigKeyUpdateInput) (api.DnsTsigKeyUpdateInput, error) {
+	if len(input.Name) > 0 && input.Name != self.Name {
+		return input, httperrors.NewUnsupportOperationError("tsig key can not be renamed")
+	}
+	var err error
+	input.VirtualResourceBaseUpdateInput, err = self.SVirtualResourceBase.ValidateUpdateData(ctx, userCred, query, input.VirtualResourceBaseUpdateInput)
+	if err != nil {
+		return input, err
+	}
+	return input, nil
+}
+
+// 重新生成密钥
+func (self *SDnsTsigKey) PerformRegenerateSecret(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.DnsTsigKeyRegenerateSecretInput) (jsonutils.JSONObject, error) {
+	secret, err := generateDnsTsigKeySecret()
+	if err != nil {
+		return nil, httperrors.NewGeneralError(err)
+	}
+	_, err = db.Update(self, func() error {
+		self.Secret = secret
+		return nil
+	})
+	if err != nil {
+		return nil, httperrors.NewGeneralError(errors.Wrapf(err, "db.Update"))
+	}
+	db.OpsLog.LogEvent(self, db.ACT_UPDATE, "regenerate secret", userCred)
+	logclient.AddActionLogWithContext(ctx, self, logclient.ACT_UPDATE, "regenerate secret", userCred, true)
+	return nil, nil
+}
+
+// TSIG密钥列表
+func (manager *SDnsTsigKeyManager) ListItemFilter(
+	ctx context.Context,
+	q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	query api.DnsTsigKeyListInput,
+) (*sqlchemy.SQuery, error) {
+	var err error
+	q, err = manager.SVirtualResourceBaseManager.ListItemFilter(ctx, q, userCred, query.VirtualResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SVirtualResourceBaseManager.ListItemFilter")
+	}
+	q, err = manager.SDnsZoneResourceBaseManager.ListItemFilter(ctx, q, userCred, query.DnsZoneFilterListBase)
+	if err != nil {
+		return nil, errors.Wrap(err, "SDnsZoneResourceBaseManager.ListItemFilter")
+	}
+	if len(query.Algorithm) > 0 {
+		q = q.In("algorithm", query.Algorithm)
+	}
+	return q, nil
+}
+
+func (manager *SDnsTsigKeyManager) OrderByExtraFields(ctx context.Context, q *sqlchemy.SQuery, userCred mcclient.TokenCredential, query api.DnsTsigKeyListInput) (*sqlchemy.SQuery, error) {
+	return manager.SVirtualResourceBaseManager.OrderByExtraFields(ctx, q, userCred, query.VirtualResourceListInput)
+}
+
+func (manager *SDnsTsigKeyManager) QueryDistinctExtraField(q *sqlchemy.SQuery, field string) (*sqlchemy.SQuery, error) {
+	return manager.SVirtualResourceBaseManager.QueryDistinctExtraField(q, field)
+}
+
+func (manager *SDnsTsigKeyManager) FetchCustomizeColumns(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	objs []interface{},
+	fields stringutils2.SSortedStrings,
+	isList bool,
+) []api.DnsTsigKeyDetails {
+	rows := make([]api.DnsTsigKeyDetails, len(objs))
+	virtRows := manager.SVirtualResourceBaseManager.FetchCustomizeColumns(ctx, userCred, query, objs, fields, isList)
+	zoneIds := make([]string, len(objs))
+	for i := range rows {
+		rows[i] = api.DnsTsigKeyDetails{
+			VirtualResourceDetails: virtRows[i],
+		}
+		zoneIds[i] = objs[i].(*SDnsTsigKey).DnsZoneId
+	}
+	zones := make(map[string]SDnsZone)
+	err := db.FetchStandaloneObjectsByIds(DnsZoneManager, zoneIds, &zones)
+	if err != nil {
+		log.Errorf("FetchStandaloneObjectsByIds for dns zones error: %v", err)
+		return rows
+	}
+	for i := range rows {
+		if zone, ok := zones[zoneIds[i]]; ok {
+			rows[i].DnsZone = zone.Name
+		}
+	}
+	return rows
+}
+
+// FetchByKeyName returns the tsig key carried in TSIG record, trailing dot
+// of the name is ignored
+func (manager *SDnsTsigKeyManager) FetchByKeyName(name string) (*SDnsTsigKey, error) {
+	key := &SDnsTsigKey{}
+	key.SetModelManager(manager, key)
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	err := manager.Query().Equals("name", name).First(key)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (manager *SDnsTsigKeyManager) GetAllKeys() ([]SDnsTsigKey, error) {
+	keys := []SDnsTsigKey{}
+	q := manager.Query().Equals("status", api.DNS_TSIG_KEY_STATUS_AVAILABLE)
+	err := db.FetchModelObjects(manager, q, &keys)
+	if err != nil {
+		return nil, errors.Wrap(err, "db.FetchModelObjects")
+	}
+	return keys, nil
+}
+
+// GetUserCred returns the credential of the project owning the key, it is
+// used to log changes made by dynamic updates signed with the key
+func (self *SDnsTsigKey) GetUserCred(ctx context.Context) mcclient.TokenCredential {
+	token := &mcclient.SSimpleToken{
+		User:            "tsig:" + self.Name,
+		ProjectId:       self.ProjectId,
+		ProjectDomainId: self.DomainId,
+		DomainId:        self.DomainId,
+	}
+	tenant, err := db.TenantCacheManager.FetchTenantByIdWithoutExpireCheck(ctx, self.ProjectId)
+	if err != nil {
+		log.Warningf("fetch project %s of tsig key %s error: %v", self.ProjectId, self.Name, err)
+		return token
+	}
+	token.Project = tenant.Name
+	token.ProjectDomain = tenant.Domain
+	token.Domain = tenant.Domain
+	return token
+}
@@ -0,0 +1,166 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+)
+
+const (
+	// 每个Dns Zone保留的历史版本数量, 超出范围的IXFR请求退化为AXFR
+	dnsZoneJournalMaxHistory = 16
+)
+
+// SDnsZoneJournalManager keeps snapshots of enabled records of a dns zone
+// at each serial, so that region-dns can answer IXFR requests with the
+// difference between the serial of a secondary and the current one.
+type SDnsZoneJournalManager struct {
+	db.SResourceBaseManager
+	SDnsZoneResourceBaseManager
+}
+
+var DnsZoneJournalManager *SDnsZoneJournalManager
+
+func init() {
+	DnsZoneJournalManager = &SDnsZoneJournalManager{
+		SResourceBaseManager: db.NewResourceBaseManager(
+			SDnsZoneJournal{},
+			"dns_zone_journals_tbl",
+			"dns_zone_journal",
+			"dns_zone_journals",
+		),
+	}
+	DnsZoneJournalManager.SetVirtualObject(DnsZoneJournalManager)
+}
+
+type SDnsZoneJournal struct {
+	db.SResourceBase
+	SDnsZoneResourceBase `index:"true"`
+
+	Id int64 `primary:"true" auto_increment:"true"`
+
+	Serial  int64                `nullable:"false"`
+	Records *jsonutils.JSONArray `nullable:"true"`
+}
+
+type sDnsZoneJournalRecord struct {
+	Name       string
+	DnsType    string
+	DnsValue   string
+	TTL        int64 `json:"ttl"`
+	MxPriority int64
+}
+
+func (manager *SDnsZoneJournalManager) addJournal(ctx context.Context, zoneId string, serial int64, records []SDnsRecordSet) error {
+	recs := jsonutils.NewArray()
+	for i := range records {
+		recs.Add(jsonutils.Marshal(sDnsZoneJournalRecord{
+			Name:       records[i].Name,
+			DnsType:    records[i].DnsType,
+			DnsValue:   records[i].DnsValue,
+			TTL:        records[i].TTL,
+			MxPriority: records[i].MxPriority,
+		}))
+	}
+	journal := &SDnsZoneJournal{
+		Serial:  serial,
+		Records: recs,
+	}
+	journal.DnsZoneId = zoneId
+	journal.SetModelManager(manager, journal)
+	err := manager.TableSpec().Insert(ctx, journal)
+	if err != nil {
+		return errors.Wrap(err, "Insert")
+	}
+	return manager.purgeJournals(ctx, zoneId)
+}
+
+func (manager *SDnsZoneJournalManager) purgeJournals(ctx context.Context, zoneId string) error {
+	journals := []SDnsZoneJournal{}
+	q := manager.Query("id").Equals("dns_zone_id", zoneId).Desc("id").Limit(dnsZoneJournalMaxHistory)
+	err := q.All(&journals)
+	if err != nil {
+		return errors.Wrap(err, "query journals")
+	}
+	if len(journals) < dnsZoneJournalMaxHistory {
+		return nil
+	}
+	sqlStr := fmt.Sprintf("DELETE FROM `%s` WHERE dns_zone_id = ? AND id < ?", manager.TableSpec().Name())
+	_, err = manager.TableSpec().GetTableSpec().Database().Exec(sqlStr, zoneId, journals[len(journals)-1].Id)
+	if err != nil {
+		return errors.Wrap(err, "Exec")
+	}
+	return nil
+}
+
+func (manager *SDnsZoneJournalManager) purgeAllJournals(ctx context.Context, zoneId string) error {
+	sqlStr := fmt.Sprintf("DELETE FROM `%s` WHERE dns_zone_id = ?", manager.TableSpec().Name())
+	_, err := manager.TableSpec().GetTableSpec().Database().Exec(sqlStr, zoneId)
+	if err != nil {
+		return errors.Wrap(err, "Exec")
+	}
+	return nil
+}
+
+func (manager *SDnsZoneJournalManager) fetchJournal(zoneId string, serial int64) (*SDnsZoneJournal, error) {
+	journal := &SDnsZoneJournal{}
+	journal.SetModelManager(manager, journal)
+	q := manager.Query().Equals("dns_zone_id", zoneId).Equals("serial", serial).Desc("id")
+	err := q.First(journal)
+	if err != nil {
+		return nil, err
+	}
+	return journal, nil
+}
+
+func (journal *SDnsZoneJournal) GetRecordSets() ([]SDnsRecordSet, error) {
+	recs := []sDnsZoneJournalRecord{}
+	if journal.Records != nil {
+		err := journal.Records.Unmarshal(&recs)
+		if err != nil {
+			return nil, errors.Wrap(err, "Unmarshal")
+		}
+	}
+	ret := make([]SDnsRecordSet, len(recs))
+	for i := range recs {
+		ret[i].DnsZoneId = journal.DnsZoneId
+		ret[i].Name = recs[i].Name
+		ret[i].DnsType = recs[i].DnsType
+		ret[i].DnsValue = recs[i].DnsValue
+		ret[i].TTL = recs[i].TTL
+		ret[i].MxPriority = recs[i].MxPriority
+	}
+	return ret, nil
+}
+
+// GetJournalRecordSets returns the enabled records of the zone at the given
+// serial, or sql.ErrNoRows when that serial is no longer kept.
+func (zone *SDnsZone) GetJournalRecordSets(serial uint32) ([]SDnsRecordSet, error) {
+	journal, err := DnsZoneJournalManager.fetchJournal(zone.Id, int64(serial))
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, sql.ErrNoRows
+		}
+		return nil, errors.Wrapf(err, "fetchJournal %d", serial)
+	}
+	return journal.GetRecordSets()
+}
@@ -21,6 +21,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/miekg/dns"
+
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
@@ -69,6 +71,15 @@ type SDnsZone struct {
 
 	ZoneType string              `width:"32" charset:"ascii" nullable:"false" list:"domain" create:"domain_required"`
 	Options  *jsonutils.JSONDict `get:"domain" list:"domain" create:"domain_optional"`
+
+	// SOA序列号, 解析记录变化时递增
+	Serial int64 `nullable:"false" default:"1" list:"domain"`
+
+	// 是否启用DNSSEC签名, 仅对region dns应答的私有区域生效
+	DnssecEnabled bool `nullable:"false" default:"false" list:"domain"`
+	// DNSSEC公钥(DNSKEY记录)
+	DnssecKey        string `width:"1024" charset:"ascii" nullable:"true" list:"domain"`
+	DnssecPrivateKey string `width:"1024" charset:"ascii" nullable:"true"`
 }
 
 // 创建
@@ -273,6 +284,7 @@ func (manager *SDnsZoneManager) FetchCustomizeColumns(
 	for i := range rows {
 		records, _ := recordMaps[dnsZoneIds[i]]
 		rows[i].DnsRecordsetCount = len(records)
+		rows[i].DnssecDs = dnsZones[i].GetDnssecDs()
 
 		vpcs, _ := vpcMaps[dnsZoneIds[i]]
 		rows[i].VpcCount = 0
@@ -575,6 +587,10 @@ func (self *SDnsZone) RealDelete(ctx context.Context, userCred mcclient.TokenCre
 			return errors.Wrapf(err, "Delete record %s(%s)", records[i].Name, records[i].Id)
 		}
 	}
+	err = DnsZoneJournalManager.purgeAllJournals(ctx, self.Id)
+	if err != nil {
+		return errors.Wrapf(err, "purgeAllJournals")
+	}
 	return self.SEnabledStatusInfrasResourceBase.Delete(ctx, userCred)
 }
 
@@ -588,6 +604,52 @@ func (self *SDnsZone) GetDnsRecordSets() ([]SDnsRecordSet, error) {
 	return records, nil
 }
 
+func (self *SDnsZone) GetEnabledDnsRecordSets() ([]SDnsRecordSet, error) {
+	records := []SDnsRecordSet{}
+	q := DnsRecordSetManager.Query().Equals("dns_zone_id", self.Id).IsTrue("enabled").Asc("name").Asc("dns_type")
+	err := db.FetchModelObjects(DnsRecordSetManager, q, &records)
+	if err != nil {
+		return nil, errors.Wrapf(err, "db.FetchModelObjects")
+	}
+	return records, nil
+}
+
+func nextDnsZoneSerial(serial int64) int64 {
+	// RFC 1982 serial arithmetic wraps at 2^32, 0 is skipped
+	serial = (serial + 1) % (1 << 32)
+	if serial == 0 {
+		serial = 1
+	}
+	return serial
+}
+
+// IncSerial bumps the SOA serial after records of the zone changed and
+// keeps a snapshot of the enabled records for incremental zone transfer
+func (self *SDnsZone) IncSerial(ctx context.Context) error {
+	lockman.LockRawObject(ctx, self.Keyword(), fmt.Sprintf("%s-serial", self.Id))
+	defer lockman.ReleaseRawObject(ctx, self.Keyword(), fmt.Sprintf("%s-serial", self.Id))
+
+	records, err := self.GetEnabledDnsRecordSets()
+	if err != nil {
+		return errors.Wrapf(err, "GetEnabledDnsRecordSets")
+	}
+	_, err = db.Update(self, func() error {
+		self.Serial = nextDnsZoneSerial(self.Serial)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "db.Update")
+	}
+	return DnsZoneJournalManager.addJournal(ctx, self.Id, self.Serial, records)
+}
+
+func (self *SDnsZone) incSerial(ctx context.Context) {
+	err := self.IncSerial(ctx)
+	if err != nil {
+		log.Errorf("IncSerial for dns zone %s error: %v", self.Name, err)
+	}
+}
+
 func (self *SDnsZone) SyncDnsRecordSets(ctx context.Context, userCred mcclient.TokenCredential, provider string, ext cloudprovider.ICloudDnsZone) compare.SyncResult {
 	lockman.LockRawObject(ctx, self.Keyword(), fmt.Sprintf("%s-records", self.Id))
 	defer lockman.ReleaseRawObject(ctx, self.Keyword(), fmt.Sprintf("%s-records", self.Id))
@@ -626,6 +688,11 @@ func (self *SDnsZone) SyncDnsRecordSets(ctx context.Context, userCred mcclient.T
 	}
 
 	_, del, add, update := cloudprovider.CompareDnsRecordSet(iRecords, local, false)
+	defer func() {
+		if result.AddCnt+result.DelCnt+result.UpdateCnt > 0 {
+			self.incSerial(ctx)
+		}
+	}()
 	for i := range add {
 		_, err := self.newFromCloudDnsRecordSet(ctx, userCred, provider, add[i])
 		if err != nil {
@@ -723,6 +790,50 @@ func (self *SDnsZone) DoSyncRecords(ctx context.Context, userCred mcclient.Token
 	return nil
 }
 
+// MarkDirty marks records of the zone to be pushed to the cloud caches by
+// SyncDirtyDnsZones, used when records are changed outside region service
+func (self *SDnsZone) MarkDirty() error {
+	_, err := db.Update(self, func() error {
+		self.IsDirty = true
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "db.Update")
+	}
+	return nil
+}
+
+func (manager *SDnsZoneManager) SyncDirtyDnsZones(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	zones := []SDnsZone{}
+	q := manager.Query().IsTrue("is_dirty")
+	err := db.FetchModelObjects(manager, q, &zones)
+	if err != nil {
+		log.Errorf("fetch dirty dns zones error: %v", err)
+		return
+	}
+	for i := range zones {
+		zones[i].DelaySync(ctx, userCred)
+	}
+}
+
+// FetchByZoneName returns the enabled dns zone of the given domain name
+func (manager *SDnsZoneManager) FetchByZoneName(name string) (*SDnsZone, error) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	zones := []SDnsZone{}
+	q := manager.Query().Equals("name", name).IsTrue("enabled")
+	err := db.FetchModelObjects(manager, q, &zones)
+	if err != nil {
+		return nil, errors.Wrapf(err, "db.FetchModelObjects")
+	}
+	if len(zones) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	if len(zones) > 1 {
+		return nil, sqlchemy.ErrDuplicateEntry
+	}
+	return &zones[0], nil
+}
+
 func (self *SDnsZone) DelaySync(ctx context.Context, userCred mcclient.TokenCredential) {
 	needSync := false
 
@@ -907,6 +1018,102 @@ func (self *SDnsZone) PerformPurge(ctx context.Context, userCred mcclient.TokenC
 	return nil, self.StartDnsZoneDeleteTask(ctx, userCred, true, "")
 }
 
+// FetchPrivateZones returns enabled private zones of names which are bound
+// to any of vpcs
+func (manager *SDnsZoneManager) FetchPrivateZones(vpcIds []string, names []string) ([]SDnsZone, error) {
+	sq := DnsZoneVpcManager.Query("dns_zone_id").In("vpc_id", vpcIds).SubQuery()
+	q := manager.Query().In("id", sq).In("name", names).IsTrue("enabled").Equals("zone_type", cloudprovider.PrivateZone)
+	zones := []SDnsZone{}
+	err := db.FetchModelObjects(manager, q, &zones)
+	if err != nil {
+		return nil, errors.Wrapf(err, "db.FetchModelObjects")
+	}
+	return zones, nil
+}
+
+func (self *SDnsZone) generateDnssecKey() (string, string, error) {
+	key := &dns.DNSKEY{
+		Hdr: dns.RR_Header{
+			Name:   dns.Fqdn(self.Name),
+			Rrtype: dns.TypeDNSKEY,
+			Class:  dns.ClassINET,
+			Ttl:    3600,
+		},
+		// combined signing key signs both DNSKEY and zone data
+		Flags:     dns.ZONE | dns.SEP,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := key.Generate(256)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "Generate")
+	}
+	return key.String(), key.PrivateKeyString(priv), nil
+}
+
+// GetDnssecDs returns the DS record to be published in parent zone
+func (self *SDnsZone) GetDnssecDs() string {
+	if len(self.DnssecKey) == 0 {
+		return ""
+	}
+	rr, err := dns.NewRR(self.DnssecKey)
+	if err != nil {
+		log.Errorf("invalid dnssec key of dns zone %s: %v", self.Name, err)
+		return ""
+	}
+	key, ok := rr.(*dns.DNSKEY)
+	if !ok {
+		return ""
+	}
+	return key.ToDS(dns.SHA256).String()
+}
+
+// 启用DNSSEC
+func (self *SDnsZone) PerformEnableDnssec(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.DnsZoneEnableDnssecInput) (jsonutils.JSONObject, error) {
+	if self.DnssecEnabled {
+		return nil, nil
+	}
+	pubKey, privKey := self.DnssecKey, self.DnssecPrivateKey
+	if len(pubKey) == 0 || len(privKey) == 0 {
+		var err error
+		pubKey, privKey, err = self.generateDnssecKey()
+		if err != nil {
+			return nil, httperrors.NewGeneralError(errors.Wrapf(err, "generateDnssecKey"))
+		}
+	}
+	_, err := db.Update(self, func() error {
+		self.DnssecEnabled = true
+		self.DnssecKey = pubKey
+		self.DnssecPrivateKey = privKey
+		return nil
+	})
+	if err != nil {
+		return nil, httperrors.NewGeneralError(errors.Wrapf(err, "db.Update"))
+	}
+	db.OpsLog.LogEvent(self, db.ACT_UPDATE, "enable dnssec", userCred)
+	self.incSerial(ctx)
+	return nil, nil
+}
+
+// 禁用DNSSEC
+func (self *SDnsZone) PerformDisableDnssec(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.DnsZoneDisableDnssecInput) (jsonutils.JSONObject, error) {
+	if !self.DnssecEnabled {
+		return nil, nil
+	}
+	// the key is kept so that DS record in parent zone is still valid once
+	// dnssec is enabled again
+	_, err := db.Update(self, func() error {
+		self.DnssecEnabled = false
+		return nil
+	})
+	if err != nil {
+		return nil, httperrors.NewGeneralError(errors.Wrapf(err, "db.Update"))
+	}
+	db.OpsLog.LogEvent(self, db.ACT_UPDATE, "disable dnssec", userCred)
+	self.incSerial(ctx)
+	return nil, nil
+}
+
 func (manager *SDnsZoneManager) totalCount(scope rbacutils.TRbacScope, ownerId mcclient.IIdentityProvider) int {
 	q := manager.Query()
 	switch scope {
@@ -514,11 +514,29 @@ func (self *SGuest) PerformLiveMigrate(ctx context.Context, userCred mcclient.To
 	if input.EnableTLS == nil {
 		input.EnableTLS = &options.Options.EnableTlsMigration
 	}
-	return nil, self.StartGuestLiveMigrateTask(ctx, userCred, self.Status, input.PreferHost, input.SkipCpuCheck, input.SkipKernelCheck, input.EnableTLS, "")
+	if err := input.GuestLiveMigrateOptions.Validate(); err != nil {
+		return nil, err
+	}
+	return nil, self.StartGuestLiveMigrateTask(ctx, userCred, self.Status, input.PreferHost, input.SkipCpuCheck, input.SkipKernelCheck, input.EnableTLS, &input.GuestLiveMigrateOptions, "")
+}
+
+// ClearMigrateProgress clears progress reported by the last live migration
+func (self *SGuest) ClearMigrateProgress() {
+	if self.MigrateProgress == nil {
+		return
+	}
+	_, err := db.Update(self, func() error {
+		self.MigrateProgress = nil
+		return nil
+	})
+	if err != nil {
+		log.Errorf("clear migrate progress of guest %s: %v", self.Name, err)
+	}
 }
 
-func (self *SGuest) StartGuestLiveMigrateTask(ctx context.Context, userCred mcclient.TokenCredential, guestStatus, preferHostId string, skipCpuCheck *bool, skipKernelCheck *bool, enableTLS *bool, parentTaskId string) error {
+func (self *SGuest) StartGuestLiveMigrateTask(ctx context.Context, userCred mcclient.TokenCredential, guestStatus, preferHostId string, skipCpuCheck *bool, skipKernelCheck *bool, enableTLS *bool, migrateOpts *api.GuestLiveMigrateOptions, parentTaskId string) error {
 	self.SetStatus(userCred, api.VM_START_MIGRATE, "")
+	self.ClearMigrateProgress()
 	data := jsonutils.NewDict()
 	if len(preferHostId) > 0 {
 		data.Set("prefer_host_id", jsonutils.NewString(preferHostId))
@@ -532,6 +550,9 @@ func (self *SGuest) StartGuestLiveMigrateTask(ctx context.Context, userCred mccl
 	if enableTLS != nil {
 		data.Set("enable_tls", jsonutils.NewBool(*enableTLS))
 	}
+	if migrateOpts != nil && !migrateOpts.IsZero() {
+		data.Set("migrate_options", jsonutils.Marshal(migrateOpts))
+	}
 	data.Set("guest_status", jsonutils.NewString(guestStatus))
 	dedicateMigrateTask := "GuestLiveMigrateTask"
 	if self.GetHypervisor() != api.HYPERVISOR_KVM {
@@ -546,6 +567,56 @@ func (self *SGuest) StartGuestLiveMigrateTask(ctx context.Context, userCred mccl
 	return nil
 }
 
+// 取消正在进行的热迁移, 虚拟机继续在源宿主机运行
+func (self *SGuest) PerformCancelLiveMigrate(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	if self.GetHypervisor() != api.HYPERVISOR_KVM {
+		return nil, httperrors.NewNotSupportedError("cancel live migrate of hypervisor %s is not supported", self.GetHypervisor())
+	}
+	if self.Status != api.VM_MIGRATING {
+		return nil, httperrors.NewInvalidStatusError("cannot cancel live migrate in status %s", self.Status)
+	}
+	host, err := self.GetHost()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetHost")
+	}
+	task, err := self.getLiveMigrateTask()
+	if err != nil {
+		return nil, errors.Wrap(err, "getLiveMigrateTask")
+	}
+	if task == nil {
+		return nil, httperrors.NewInvalidStatusError("guest %s is not in live migrate task", self.Name)
+	}
+	// the task tells cancellation from failure by the flag rather than the reason reported by host
+	err = task.SaveParams(jsonutils.Marshal(map[string]bool{api.LIVE_MIGRATE_CANCELLED_PARAM: true}).(*jsonutils.JSONDict))
+	if err != nil {
+		return nil, errors.Wrap(err, "SaveParams")
+	}
+	url := fmt.Sprintf("%s/servers/%s/cancel-live-migrate", host.ManagerUri, self.Id)
+	header := http.Header{}
+	header.Add("X-Auth-Token", userCred.GetTokenString())
+	_, _, err = httputils.JSONRequest(httputils.GetDefaultClient(), ctx, "POST", url, header, nil, false)
+	if err != nil {
+		task.SaveParams(jsonutils.Marshal(map[string]bool{api.LIVE_MIGRATE_CANCELLED_PARAM: false}).(*jsonutils.JSONDict))
+		return nil, errors.Wrap(err, "request cancel live migrate")
+	}
+	db.OpsLog.LogEvent(self, db.ACT_MIGRATING, "cancel live migrate", userCred)
+	return nil, nil
+}
+
+func (self *SGuest) getLiveMigrateTask() (*taskman.STask, error) {
+	isOpen := true
+	tasks, err := taskman.TaskManager.FetchTasksOfObject(self, time.Time{}, &isOpen)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchTasksOfObject")
+	}
+	for i := range tasks {
+		if tasks[i].TaskName == "GuestLiveMigrateTask" {
+			return &tasks[i], nil
+		}
+	}
+	return nil, nil
+}
+
 func (self *SGuest) PerformClone(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
 	if self.IsEncrypted() {
 		return nil, httperrors.NewForbiddenError("cannot clone encrypted server")
@@ -1866,6 +1937,10 @@ func (self *SGuest) PerformDetachIsolatedDevice(ctx context.Context, userCred mc
 		lockman.LockObject(ctx, host)
 		defer lockman.ReleaseObject(ctx, host)
 		for i := 0; i < len(devs); i++ {
+			// virtual functions used by nics are released along with networks
+			if devs[i].IsSRIOVNic() && devs[i].NetworkIndex >= 0 {
+				continue
+			}
 			err := self.detachIsolateDevice(ctx, userCred, &devs[i])
 			if err != nil {
 				return nil, err
@@ -1887,6 +1962,12 @@ func (self *SGuest) startDetachIsolateDevice(ctx context.Context, userCred mccli
 	if dev.IsGPU() && self.GetStatus() != api.VM_READY {
 		return httperrors.NewInvalidStatusError("Can't detach GPU when status is %q", self.GetStatus())
 	}
+	if dev.IsMdev() && self.GetStatus() != api.VM_READY {
+		return httperrors.NewInvalidStatusError("Can't detach mediated device when status is %q", self.GetStatus())
+	}
+	if dev.IsSRIOVNic() && dev.NetworkIndex >= 0 {
+		return httperrors.NewBadRequestError("Isolated device %s is used by nic %d, detach the network instead", dev.GetName(), dev.NetworkIndex)
+	}
 	host, _ := self.GetHost()
 	lockman.LockObject(ctx, host)
 	defer lockman.ReleaseObject(ctx, host)
@@ -1902,6 +1983,7 @@ func (self *SGuest) detachIsolateDevice(ctx context.Context, userCred mcclient.T
 	}
 	_, err := db.Update(dev, func() error {
 		dev.GuestId = ""
+		dev.NetworkIndex = -1
 		return nil
 	})
 	if err != nil {
@@ -1994,6 +2076,9 @@ func (self *SGuest) startAttachIsolatedDevice(ctx context.Context, userCred mccl
 	if dev.IsGPU() && self.GetStatus() != api.VM_READY {
 		return httperrors.NewInvalidStatusError("Can't attach GPU when status is %q", self.GetStatus())
 	}
+	if dev.IsMdev() && self.GetStatus() != api.VM_READY {
+		return httperrors.NewInvalidStatusError("Can't attach mediated device when status is %q", self.GetStatus())
+	}
 	host, _ := self.GetHost()
 	lockman.LockObject(ctx, host)
 	defer lockman.ReleaseObject(ctx, host)
@@ -5525,6 +5610,31 @@ func (self *SGuest) GetDetailsCpusetCores(ctx context.Context, userCred mcclient
 	return resp, nil
 }
 
+// 获取虚拟机串口日志, 用于排查启动失败等问题
+func (self *SGuest) GetDetailsSerialLog(ctx context.Context, userCred mcclient.TokenCredential, input *api.ServerSerialLogInput) (*api.ServerSerialLogOutput, error) {
+	if self.GetHypervisor() != api.HYPERVISOR_KVM {
+		return nil, httperrors.NewNotSupportedError("serial log of hypervisor %s is not supported", self.GetHypervisor())
+	}
+	if input.Tail < 0 {
+		return nil, httperrors.NewInputParameterError("tail should not be negative")
+	}
+	host, err := self.GetHost()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetHost")
+	}
+	url := fmt.Sprintf("%s/servers/%s/serial-log?tail=%d", host.ManagerUri, self.Id, input.Tail)
+	header := mcclient.GetTokenHeaders(userCred)
+	resp, err := httputils.Request(httputils.GetDefaultClient(), ctx, "GET", url, header, nil, false)
+	_, data, err := httputils.ParseResponse("", resp, err, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "request serial log")
+	}
+	return &api.ServerSerialLogOutput{
+		HostId: host.Id,
+		Log:    string(data),
+	}, nil
+}
+
 func (self *SGuest) PerformCalculateRecordChecksum(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
 	checksum, err := db.CalculateModelChecksum(self)
 	if err != nil {
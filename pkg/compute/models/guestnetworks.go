@@ -19,6 +19,7 @@ import (
 	"database/sql"
 	"fmt"
 	"math/rand"
+	"net"
 	"regexp"
 	"time"
 
@@ -247,6 +248,8 @@ type newGuestNetworkArgs struct {
 	index int8
 
 	ipAddr              string
+	ip6Addr             string
+	requireIPv6         bool
 	allocDir            api.IPAllocationDirection
 	tryReserved         bool
 	requireDesignatedIP bool
@@ -274,6 +277,8 @@ func (manager *SGuestnetworkManager) newGuestNetwork(
 		network              = args.network
 		index                = args.index
 		address              = args.ipAddr
+		address6             = args.ip6Addr
+		requireIPv6          = args.requireIPv6
 		mac                  = args.macAddr
 		driver               = args.nicDriver
 		bwLimit              = args.bwLimit
@@ -342,6 +347,19 @@ func (manager *SGuestnetworkManager) newGuestNetwork(
 			gn.IpAddr = ipAddr
 		}
 
+		if provider == api.CLOUD_PROVIDER_ONECLOUD && network.IsSupportIPv6() {
+			ip6Addr, err := network.GetFreeIP6(ctx, userCred, nil, address6, allocDir)
+			if err != nil {
+				return nil, errors.Wrap(err, "GetFreeIP6")
+			}
+			if len(address6) > 0 && !net.ParseIP(address6).Equal(net.ParseIP(ip6Addr)) && requiredDesignatedIp {
+				return nil, fmt.Errorf("candidate ipv6 %s is occupied!", address6)
+			}
+			gn.Ip6Addr = ip6Addr
+		} else if requireIPv6 || len(address6) > 0 {
+			return nil, httperrors.NewNotSupportedError("network %s does not support ipv6", network.Name)
+		}
+
 		if vpc.Id != api.DEFAULT_VPC_ID && provider == api.CLOUD_PROVIDER_ONECLOUD {
 			var err error
 			GuestnetworkManager.lockAllocMappedAddr(ctx)
@@ -592,6 +610,13 @@ func (self *SGuestnetwork) getJsonDesc() *api.GuestnetworkJsonDesc {
 	desc.ExternalId = net.ExternalId
 	desc.TeamWith = self.TeamWith
 
+	if len(self.Ip6Addr) > 0 && net.IsSupportIPv6() {
+		desc.Ip6 = self.Ip6Addr
+		desc.Gateway6 = net.GuestGateway6
+		desc.Masklen6 = net.GuestIp6Mask
+		desc.Dns6 = net.GuestDns6
+	}
+
 	guest := self.getGuest()
 	if guest.GetHypervisor() != api.HYPERVISOR_KVM {
 		manual := true
@@ -618,6 +643,67 @@ func (manager *SGuestnetworkManager) GetGuestByAddress(address string) *SGuest {
 	return nil
 }
 
+func (manager *SGuestnetworkManager) queryByAddress(address string) *sqlchemy.SQuery {
+	q := manager.Query()
+	return q.Filter(sqlchemy.OR(
+		sqlchemy.Equals(q.Field("ip_addr"), address),
+		sqlchemy.Equals(q.Field("ip6_addr"), address),
+	))
+}
+
+// GetVpcIdsByAddress returns ids of vpcs of guests sending packets from
+// address.  Guests of onecloud vpcs reach hosts by their mapped address, while
+// guests of classic networks by their own address.  Addresses of vpcs overlap
+// by design, the same address could still be found in more than one vpc
+func (manager *SGuestnetworkManager) GetVpcIdsByAddress(address string) ([]string, error) {
+	guestnetworks := manager.Query().SubQuery()
+	networks := NetworkManager.Query().SubQuery()
+	wires := WireManager.Query().SubQuery()
+	q := wires.Query(wires.Field("vpc_id"))
+	q = q.Join(networks, sqlchemy.Equals(networks.Field("wire_id"), wires.Field("id")))
+	q = q.Join(guestnetworks, sqlchemy.Equals(guestnetworks.Field("network_id"), networks.Field("id")))
+	q = q.Filter(sqlchemy.OR(
+		sqlchemy.AND(
+			sqlchemy.NotEquals(wires.Field("vpc_id"), api.DEFAULT_VPC_ID),
+			sqlchemy.Equals(guestnetworks.Field("mapped_ip_addr"), address),
+		),
+		sqlchemy.AND(
+			sqlchemy.Equals(wires.Field("vpc_id"), api.DEFAULT_VPC_ID),
+			sqlchemy.OR(
+				sqlchemy.Equals(guestnetworks.Field("ip_addr"), address),
+				sqlchemy.Equals(guestnetworks.Field("ip6_addr"), address),
+			),
+		),
+	))
+	q = q.Distinct()
+	result := []struct {
+		VpcId string
+	}{}
+	err := q.All(&result)
+	if err != nil {
+		return nil, errors.Wrapf(err, "q.All")
+	}
+	vpcIds := make([]string, len(result))
+	for i := range result {
+		vpcIds[i] = result[i].VpcId
+	}
+	return vpcIds, nil
+}
+
+// GetGuestsByAddress returns guests having nic of address in vpcs
+func (manager *SGuestnetworkManager) GetGuestsByAddress(address string, vpcIds []string) ([]SGuest, error) {
+	wires := WireManager.Query("id").In("vpc_id", vpcIds).SubQuery()
+	networks := NetworkManager.Query("id").In("wire_id", wires).SubQuery()
+	guestnetworks := manager.queryByAddress(address).In("network_id", networks).SubQuery()
+	q := GuestManager.Query().In("id", guestnetworks.Query(guestnetworks.Field("guest_id")).SubQuery())
+	guests := []SGuest{}
+	err := db.FetchModelObjects(GuestManager, q, &guests)
+	if err != nil {
+		return nil, errors.Wrapf(err, "db.FetchModelObjects")
+	}
+	return guests, nil
+}
+
 func (self *SGuestnetwork) GetDetailedString() string {
 	network := self.GetNetwork()
 	return fmt.Sprintf("eth%d:%s/%d/%s/%d/%s/%s/%d", self.Index, self.IpAddr, network.GuestIpMask,
@@ -659,6 +745,11 @@ func (manager *SGuestnetworkManager) DeleteGuestNics(ctx context.Context, userCr
 		}
 		guest := gn.GetGuest()
 		net := gn.GetNetwork()
+		if gn.Driver == api.NETWORK_DRIVER_VFIO {
+			if err := IsolatedDeviceManager.releaseSRIOVNicOfGuestnetwork(ctx, userCred, guest, &gn); err != nil {
+				return errors.Wrap(err, "releaseSRIOVNicOfGuestnetwork")
+			}
+		}
 		if regutils.MatchIP4Addr(gn.IpAddr) || regutils.MatchIP6Addr(gn.Ip6Addr) {
 			net.updateDnsRecord(&gn, false)
 			if regutils.MatchIP4Addr(gn.IpAddr) {
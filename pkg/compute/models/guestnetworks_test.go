@@ -0,0 +1,110 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"database/sql"
+	"reflect"
+	"sort"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/sqlchemy"
+	_ "yunion.io/x/sqlchemy/backends"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+)
+
+func TestGuestnetworkManager_GetVpcIdsByAddress(t *testing.T) {
+	dbConn, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer dbConn.Close()
+	// every connection of in-memory sqlite opens a database of its own
+	dbConn.SetMaxOpenConns(1)
+	sqlchemy.SetDBWithNameBackend(dbConn, sqlchemy.DefaultDB, sqlchemy.SQLiteBackend)
+	defer sqlchemy.CloseDB()
+	if GuestnetworkManager == nil {
+		db.InitAllManagers()
+	}
+
+	for _, ts := range []*sqlchemy.STableSpec{WireManager.TableSpec().GetTableSpec(), NetworkManager.TableSpec().GetTableSpec(), GuestnetworkManager.TableSpec().GetTableSpec()} {
+		err := ts.Sync()
+		if err != nil {
+			t.Fatalf("sync table %s: %v", ts.Name(), err)
+		}
+	}
+	insert := func(ts *sqlchemy.STableSpec, obj interface{}) {
+		err := ts.Insert(obj)
+		if err != nil {
+			t.Fatalf("insert into %s: %v", ts.Name(), err)
+		}
+	}
+	for _, vpcId := range []string{api.DEFAULT_VPC_ID, "vpc1", "vpc2"} {
+		wire := &SWire{}
+		wire.Id = "wire-" + vpcId
+		wire.VpcId = vpcId
+		insert(WireManager.TableSpec().GetTableSpec(), wire)
+		network := &SNetwork{}
+		network.Id = "net-" + vpcId
+		network.WireId = wire.Id
+		insert(NetworkManager.TableSpec().GetTableSpec(), network)
+	}
+	for i, gn := range []struct {
+		vpcId    string
+		ipAddr   string
+		mappedIp string
+	}{
+		{api.DEFAULT_VPC_ID, "10.0.0.2", ""},
+		// guests of different vpcs sharing the same address
+		{"vpc1", "192.168.0.2", "100.64.0.1"},
+		{"vpc2", "192.168.0.2", "100.64.0.2"},
+		// guest of vpc with the same address of classic guest
+		{"vpc2", "10.0.0.2", "100.64.0.3"},
+	} {
+		guestnetwork := &SGuestnetwork{}
+		guestnetwork.RowId = int64(i + 1)
+		guestnetwork.GuestId = "guest-" + gn.mappedIp
+		guestnetwork.NetworkId = "net-" + gn.vpcId
+		guestnetwork.IpAddr = gn.ipAddr
+		guestnetwork.MappedIpAddr = gn.mappedIp
+		insert(GuestnetworkManager.TableSpec().GetTableSpec(), guestnetwork)
+	}
+
+	cases := []struct {
+		address string
+		want    []string
+	}{
+		{"10.0.0.2", []string{api.DEFAULT_VPC_ID}},
+		{"192.168.0.2", []string{}},
+		{"100.64.0.1", []string{"vpc1"}},
+		{"100.64.0.2", []string{"vpc2"}},
+		{"100.64.0.3", []string{"vpc2"}},
+		{"100.64.0.4", []string{}},
+	}
+	for _, c := range cases {
+		got, err := GuestnetworkManager.GetVpcIdsByAddress(c.address)
+		if err != nil {
+			t.Fatalf("GetVpcIdsByAddress %s: %v", c.address, err)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("GetVpcIdsByAddress %s: want %v, got %v", c.address, c.want, got)
+		}
+	}
+}
@@ -136,6 +136,8 @@ type SGuest struct {
 
 	// 迁移或克隆的速度
 	ProgressMbps float64 `nullable:"false" default:"0" list:"user" create:"optional" update:"user" log:"skip"`
+	// 热迁移进度
+	MigrateProgress *api.SGuestMigrateProgress `nullable:"true" list:"user" update:"user" log:"skip"`
 
 	Vga     string `width:"36" charset:"ascii" nullable:"true" list:"user" update:"user" create:"optional"`
 	Vdi     string `width:"36" charset:"ascii" nullable:"true" list:"user" update:"user" create:"optional"`
@@ -2922,6 +2924,8 @@ type Attach2NetworkArgs struct {
 	Network *SNetwork
 
 	IpAddr              string
+	Ip6Addr             string
+	RequireIPv6         bool
 	AllocDir            api.IPAllocationDirection
 	TryReserved         bool
 	RequireDesignatedIP bool
@@ -2944,6 +2948,8 @@ func (args *Attach2NetworkArgs) onceArgs(i int) attach2NetworkOnceArgs {
 		network: args.Network,
 
 		ipAddr:              args.IpAddr,
+		ip6Addr:             args.Ip6Addr,
+		requireIPv6:         args.RequireIPv6,
 		allocDir:            args.AllocDir,
 		tryReserved:         args.TryReserved,
 		requireDesignatedIP: args.RequireDesignatedIP,
@@ -2959,6 +2965,8 @@ func (args *Attach2NetworkArgs) onceArgs(i int) attach2NetworkOnceArgs {
 	}
 	if i > 0 {
 		r.ipAddr = ""
+		r.ip6Addr = ""
+		r.requireIPv6 = false
 		r.bwLimit = 0
 		r.virtual = true
 		r.tryReserved = false
@@ -2974,6 +2982,8 @@ type attach2NetworkOnceArgs struct {
 	network *SNetwork
 
 	ipAddr              string
+	ip6Addr             string
+	requireIPv6         bool
 	allocDir            api.IPAllocationDirection
 	tryReserved         bool
 	requireDesignatedIP bool
@@ -3042,6 +3052,8 @@ func (self *SGuest) attach2NetworkOnce(
 		index: index,
 
 		ipAddr:              args.ipAddr,
+		ip6Addr:             args.ip6Addr,
+		requireIPv6:         args.requireIPv6,
 		allocDir:            args.allocDir,
 		tryReserved:         args.tryReserved,
 		requireDesignatedIP: args.requireDesignatedIP,
@@ -3066,6 +3078,15 @@ func (self *SGuest) attach2NetworkOnce(
 		pendingUsage = args.pendingUsage
 		teamWithMac  = args.teamWithMac
 	)
+	if guestnic.Driver == api.NETWORK_DRIVER_VFIO {
+		err = IsolatedDeviceManager.attachSRIOVNicToGuestnetwork(ctx, userCred, self, guestnic, network)
+		if err != nil {
+			if err := guestnic.Delete(ctx, userCred); err != nil {
+				log.Errorf("delete guestnetwork %d: %v", guestnic.RowId, err)
+			}
+			return nil, errors.Wrap(err, "attachSRIOVNicToGuestnetwork")
+		}
+	}
 	network.updateDnsRecord(guestnic, true)
 	network.updateGuestNetmap(guestnic)
 	if pendingUsage != nil && len(teamWithMac) == 0 {
@@ -3684,6 +3705,8 @@ func (self *SGuest) attach2NamedNetworkDesc(ctx context.Context, userCred mcclie
 			Network:             net,
 			PendingUsage:        pendingUsage,
 			IpAddr:              netConfig.Address,
+			Ip6Addr:             netConfig.Address6,
+			RequireIPv6:         netConfig.RequireIPv6,
 			NicDriver:           netConfig.Driver,
 			BwLimit:             netConfig.BwLimit,
 			Virtual:             netConfig.Vip,
@@ -5573,13 +5596,17 @@ func (self *SGuest) ToIsolatedDevicesConfig() []*api.IsolatedDeviceConfig {
 	if len(guestIsolatedDevices) == 0 {
 		return nil
 	}
-	ret := make([]*api.IsolatedDeviceConfig, len(guestIsolatedDevices))
-	for idx, guestIsolatedDevice := range guestIsolatedDevices {
+	ret := make([]*api.IsolatedDeviceConfig, 0)
+	for _, guestIsolatedDevice := range guestIsolatedDevices {
+		// virtual functions of nics are allocated along with networks
+		if guestIsolatedDevice.IsSRIOVNic() && guestIsolatedDevice.NetworkIndex >= 0 {
+			continue
+		}
 		devConf := new(api.IsolatedDeviceConfig)
 		devConf.Model = guestIsolatedDevice.Model
 		devConf.Vendor = guestIsolatedDevice.getVendor()
 		devConf.DevType = guestIsolatedDevice.DevType
-		ret[idx] = devConf
+		ret = append(ret, devConf)
 	}
 	return ret
 }
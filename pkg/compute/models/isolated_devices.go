@@ -29,6 +29,7 @@ import (
 	api "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/util/rbacutils"
@@ -41,6 +42,7 @@ const (
 	GPU_VGA_TYPE    = api.GPU_VGA_TYPE // # for display
 	USB_TYPE        = api.USB_TYPE
 	NIC_TYPE        = api.NIC_TYPE
+	MDEV_TYPE       = api.MDEV_TYPE
 
 	NVIDIA_VENDOR_ID = api.NVIDIA_VENDOR_ID
 	AMD_VENDOR_ID    = api.AMD_VENDOR_ID
@@ -103,6 +105,15 @@ type SIsolatedDevice struct {
 
 	// reserved storage size for isolated device
 	ReservedStorage int `nullable:"true" default:"0" list:"domain" update:"domain" create:"domain_optional"`
+
+	// # wire of SR-IOV virtual function
+	WireId string `width:"36" charset:"ascii" nullable:"true" index:"true" list:"domain" create:"domain_optional"`
+
+	// # index of guest nic using SR-IOV virtual function, -1 when not used by any nic
+	NetworkIndex int8 `nullable:"false" default:"-1" list:"domain"`
+
+	// # mdev type of mediated device read from sysfs, e.g. nvidia-63
+	MdevType string `width:"64" charset:"ascii" nullable:"true" index:"true" list:"domain" create:"domain_optional"`
 }
 
 func (manager *SIsolatedDeviceManager) ExtraSearchConditions(ctx context.Context, q *sqlchemy.SQuery, like string) []sqlchemy.ICondition {
@@ -130,7 +141,7 @@ func (manager *SIsolatedDeviceManager) ValidateCreateData(ctx context.Context,
 	if input.DevType == "" {
 		return input, httperrors.NewNotEmptyError("dev_type is empty")
 	}
-	if !utils.IsInStringArray(input.DevType, []string{api.GPU_HPC_TYPE, api.GPU_VGA_TYPE, api.USB_TYPE, api.NIC_TYPE}) {
+	if !utils.IsInStringArray(input.DevType, []string{api.GPU_HPC_TYPE, api.GPU_VGA_TYPE, api.USB_TYPE, api.NIC_TYPE, api.MDEV_TYPE}) {
 		return input, httperrors.NewInputParameterError("device type %q not supported", input.DevType)
 	}
 
@@ -164,6 +175,22 @@ func (manager *SIsolatedDeviceManager) ValidateCreateData(ctx context.Context,
 	if input.ReservedStorage != nil && *input.ReservedStorage < 0 {
 		return input, httperrors.NewInputParameterError("reserved storage must >= 0")
 	}
+
+	// validate wire of SR-IOV virtual function, other passthrough nics have no wire
+	if len(input.WireId) > 0 {
+		if input.DevType != api.NIC_TYPE {
+			return input, httperrors.NewInputParameterError("wire_id is only for SR-IOV virtual function of %s type", api.NIC_TYPE)
+		}
+		wire, _, err := ValidateWireResourceInput(userCred, api.WireResourceInput{WireId: input.WireId})
+		if err != nil {
+			return input, errors.Wrap(err, "ValidateWireResourceInput")
+		}
+		input.WireId = wire.Id
+	}
+
+	if input.DevType == api.MDEV_TYPE && len(input.MdevType) == 0 {
+		return input, httperrors.NewMissingParameterError("mdev_type")
+	}
 	return input, nil
 }
 
@@ -231,6 +258,9 @@ func (manager *SIsolatedDeviceManager) ListItemFilter(
 	if len(query.VendorDeviceId) > 0 {
 		q = q.In("vendor_device_id", query.VendorDeviceId)
 	}
+	if len(query.MdevType) > 0 {
+		q = q.In("mdev_type", query.MdevType)
+	}
 
 	if !query.ShowBaremetalIsolatedDevices {
 		sq := HostManager.Query("id").Equals("host_type", api.HOST_TYPE_HYPERVISOR).SubQuery()
@@ -245,6 +275,14 @@ func (manager *SIsolatedDeviceManager) ListItemFilter(
 		q = q.Equals("guest_id", obj.GetId())
 	}
 
+	if query.WireId != "" {
+		wire, _, err := ValidateWireResourceInput(userCred, api.WireResourceInput{WireId: query.WireId})
+		if err != nil {
+			return nil, errors.Wrap(err, "ValidateWireResourceInput")
+		}
+		q = q.Equals("wire_id", wire.Id)
+	}
+
 	return q, nil
 }
 
@@ -367,6 +405,16 @@ func (self *SIsolatedDevice) IsGPU() bool {
 	return strings.HasPrefix(self.DevType, "GPU")
 }
 
+// IsSRIOVNic tells whether the device is a SR-IOV virtual function, which is
+// reported with the wire it connects to
+func (self *SIsolatedDevice) IsSRIOVNic() bool {
+	return self.DevType == api.NIC_TYPE && len(self.WireId) > 0
+}
+
+func (self *SIsolatedDevice) IsMdev() bool {
+	return self.DevType == api.MDEV_TYPE
+}
+
 func (manager *SIsolatedDeviceManager) parseDeviceInfo(userCred mcclient.TokenCredential, devConfig *api.IsolatedDeviceConfig) (*api.IsolatedDeviceConfig, error) {
 	var devId, devType, devVendor string
 	var matchDev *SIsolatedDevice
@@ -500,7 +548,10 @@ func (manager *SIsolatedDeviceManager) FindUnusedGpusOnHost(hostId string) ([]SI
 func (manager *SIsolatedDeviceManager) findHostUnusedByModel(model string, hostId string) ([]SIsolatedDevice, error) {
 	devs := make([]SIsolatedDevice, 0)
 	q := manager.findUnusedQuery()
-	q = q.Equals("model", model).Equals("host_id", hostId)
+	q = q.Filter(sqlchemy.OR(
+		sqlchemy.Equals(q.Field("model"), model),
+		sqlchemy.Equals(q.Field("mdev_type"), model),
+	)).Equals("host_id", hostId)
 	err := db.FetchModelObjects(manager, q, &devs)
 	if err != nil {
 		return nil, err
@@ -516,6 +567,7 @@ func (manager *SIsolatedDeviceManager) ReleaseDevicesOfGuest(ctx context.Context
 	for _, dev := range devs {
 		_, err := db.Update(&dev, func() error {
 			dev.GuestId = ""
+			dev.NetworkIndex = -1
 			return nil
 		})
 		if err != nil {
@@ -527,6 +579,63 @@ func (manager *SIsolatedDeviceManager) ReleaseDevicesOfGuest(ctx context.Context
 	return nil
 }
 
+func (manager *SIsolatedDeviceManager) findHostUnusedSRIOVNicsByWire(hostId string, wireId string) ([]SIsolatedDevice, error) {
+	devs := make([]SIsolatedDevice, 0)
+	q := manager.findUnusedQuery()
+	q = q.Equals("dev_type", api.NIC_TYPE).Equals("host_id", hostId).Equals("wire_id", wireId)
+	err := db.FetchModelObjects(manager, q, &devs)
+	if err != nil {
+		return nil, err
+	}
+	return devs, nil
+}
+
+// attachSRIOVNicToGuestnetwork binds an unused virtual function on the wire
+// of guest nic to the guest
+func (manager *SIsolatedDeviceManager) attachSRIOVNicToGuestnetwork(ctx context.Context, userCred mcclient.TokenCredential, guest *SGuest, gn *SGuestnetwork, network *SNetwork) error {
+	host, err := guest.GetHost()
+	if err != nil {
+		return errors.Wrap(err, "GetHost")
+	}
+	lockman.LockObject(ctx, host)
+	defer lockman.ReleaseObject(ctx, host)
+
+	devs, err := manager.findHostUnusedSRIOVNicsByWire(host.Id, network.WireId)
+	if err != nil {
+		return errors.Wrap(err, "findHostUnusedSRIOVNicsByWire")
+	}
+	if len(devs) == 0 {
+		return httperrors.NewInsufficientResourceError("host %s has no free SR-IOV virtual function on wire %s", host.GetName(), network.WireId)
+	}
+	dev := &devs[0]
+	if err := guest.attachIsolatedDevice(ctx, userCred, dev); err != nil {
+		return errors.Wrap(err, "attachIsolatedDevice")
+	}
+	_, err = db.Update(dev, func() error {
+		dev.NetworkIndex = gn.Index
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "update network index")
+	}
+	return host.ClearSchedDescCache()
+}
+
+// releaseSRIOVNicOfGuestnetwork releases the virtual function used by guest nic
+func (manager *SIsolatedDeviceManager) releaseSRIOVNicOfGuestnetwork(ctx context.Context, userCred mcclient.TokenCredential, guest *SGuest, gn *SGuestnetwork) error {
+	devs := make([]SIsolatedDevice, 0)
+	q := manager.Query().Equals("guest_id", guest.Id).Equals("dev_type", api.NIC_TYPE).Equals("network_index", gn.Index)
+	if err := db.FetchModelObjects(manager, q, &devs); err != nil {
+		return errors.Wrap(err, "FetchModelObjects")
+	}
+	for i := range devs {
+		if err := guest.detachIsolateDevice(ctx, userCred, &devs[i]); err != nil {
+			return errors.Wrapf(err, "detach virtual function %s", devs[i].Id)
+		}
+	}
+	return nil
+}
+
 func (manager *SIsolatedDeviceManager) totalCountQ(
 	scope rbacutils.TRbacScope, ownerId mcclient.IIdentityProvider, devType []string, hostTypes []string,
 	resourceTypes []string,
@@ -612,7 +721,7 @@ func (manager *SIsolatedDeviceManager) TotalCount(
 }
 
 func (self *SIsolatedDevice) getDesc() *api.IsolatedDeviceJsonDesc {
-	return &api.IsolatedDeviceJsonDesc{
+	desc := &api.IsolatedDeviceJsonDesc{
 		Id:             self.Id,
 		DevType:        self.DevType,
 		Model:          self.Model,
@@ -620,6 +729,17 @@ func (self *SIsolatedDevice) getDesc() *api.IsolatedDeviceJsonDesc {
 		VendorDeviceId: self.VendorDeviceId,
 		Vendor:         self.getVendor(),
 	}
+	if self.IsSRIOVNic() {
+		desc.WireId = self.WireId
+		if self.NetworkIndex >= 0 {
+			networkIndex := self.NetworkIndex
+			desc.NetworkIndex = &networkIndex
+		}
+	}
+	if self.IsMdev() {
+		desc.MdevType = self.MdevType
+	}
+	return desc
 }
 
 func (man *SIsolatedDeviceManager) GetSpecShouldCheckStatus(query *jsonutils.JSONDict) (bool, error) {
@@ -641,6 +761,12 @@ func (self *SIsolatedDevice) GetSpec(statusCheck bool) *jsonutils.JSONDict {
 	spec.Set("model", jsonutils.NewString(self.Model))
 	spec.Set("pci_id", jsonutils.NewString(self.VendorDeviceId))
 	spec.Set("vendor", jsonutils.NewString(self.getVendor()))
+	if self.IsSRIOVNic() {
+		spec.Set("wire_id", jsonutils.NewString(self.WireId))
+	}
+	if self.IsMdev() {
+		spec.Set("mdev_type", jsonutils.NewString(self.MdevType))
+	}
 	return spec
 }
 
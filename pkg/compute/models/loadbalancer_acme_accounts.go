@@ -0,0 +1,119 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
+	"yunion.io/x/onecloud/pkg/compute/options"
+	"yunion.io/x/onecloud/pkg/util/acmeutils"
+)
+
+// SLoadbalancerAcmeAccountManager keeps the account keys registered with
+// ACME CAs, one account for each directory url and contact email
+type SLoadbalancerAcmeAccountManager struct {
+	db.SResourceBaseManager
+}
+
+var LoadbalancerAcmeAccountManager *SLoadbalancerAcmeAccountManager
+
+func init() {
+	LoadbalancerAcmeAccountManager = &SLoadbalancerAcmeAccountManager{
+		SResourceBaseManager: db.NewResourceBaseManager(
+			SLoadbalancerAcmeAccount{},
+			"loadbalancer_acme_accounts_tbl",
+			"loadbalancer_acme_account",
+			"loadbalancer_acme_accounts",
+		),
+	}
+	LoadbalancerAcmeAccountManager.SetVirtualObject(LoadbalancerAcmeAccountManager)
+}
+
+type SLoadbalancerAcmeAccount struct {
+	db.SResourceBase
+
+	Id int64 `primary:"true" auto_increment:"true"`
+
+	DirectoryUrl string `width:"256" charset:"ascii" nullable:"false" index:"true"`
+	Email        string `width:"256" charset:"ascii" nullable:"true"`
+	PrivateKey   string `charset:"ascii" nullable:"false"`
+}
+
+func (manager *SLoadbalancerAcmeAccountManager) fetchAccount(directoryUrl, email string) (*SLoadbalancerAcmeAccount, error) {
+	accounts := []SLoadbalancerAcmeAccount{}
+	q := manager.Query().Equals("directory_url", directoryUrl).Equals("email", email).Desc("id")
+	err := db.FetchModelObjects(manager, q, &accounts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "db.FetchModelObjects")
+	}
+	if len(accounts) == 0 {
+		return nil, nil
+	}
+	return &accounts[0], nil
+}
+
+// GetAcmeClient returns the client of the ACME CA configured in options,
+// registering a new account on first use
+func (manager *SLoadbalancerAcmeAccountManager) GetAcmeClient(ctx context.Context) (*acmeutils.SAcmeClient, error) {
+	directoryUrl, email := options.Options.AcmeDirectoryUrl, options.Options.AcmeEmail
+	if len(directoryUrl) == 0 {
+		directoryUrl = acmeutils.LetsEncryptDirectoryUrl
+	}
+
+	lockman.LockRawObject(ctx, manager.Keyword(), fmt.Sprintf("%s-%s", directoryUrl, email))
+	defer lockman.ReleaseRawObject(ctx, manager.Keyword(), fmt.Sprintf("%s-%s", directoryUrl, email))
+
+	account, err := manager.fetchAccount(directoryUrl, email)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fetchAccount")
+	}
+	if account != nil {
+		key, err := acmeutils.ParsePrivateKey(account.PrivateKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "ParsePrivateKey of account %d", account.Id)
+		}
+		return acmeutils.NewAcmeClient(directoryUrl, key, options.Options.AcmeInsecure), nil
+	}
+
+	key, err := acmeutils.GenerateAccountKey()
+	if err != nil {
+		return nil, errors.Wrapf(err, "GenerateAccountKey")
+	}
+	keyPem, err := acmeutils.MarshalPrivateKey(key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "MarshalPrivateKey")
+	}
+	cli := acmeutils.NewAcmeClient(directoryUrl, key, options.Options.AcmeInsecure)
+	err = cli.Register(ctx, email)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Register account at %s", directoryUrl)
+	}
+	account = &SLoadbalancerAcmeAccount{
+		DirectoryUrl: directoryUrl,
+		Email:        email,
+		PrivateKey:   keyPem,
+	}
+	account.SetModelManager(manager, account)
+	err = manager.TableSpec().Insert(ctx, account)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Insert")
+	}
+	return cli, nil
+}
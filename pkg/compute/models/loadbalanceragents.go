@@ -123,6 +123,21 @@ type SLoadbalancerAgentParamsTelegraf struct {
 	HaproxyInputInterval    int `json:",omitzero"`
 }
 
+type SLoadbalancerAgentParamsAccessLog struct {
+	Sink          string
+	BatchSize     int `json:",omitzero"`
+	FlushInterval int `json:",omitzero"`
+
+	SyslogAddr string
+
+	S3Endpoint  string
+	S3AccessKey string
+	S3SecretKey string
+	S3Bucket    string
+	S3Prefix    string
+	S3UseSsl    bool
+}
+
 type SLoadbalancerAgentParams struct {
 	KeepalivedConfTmpl string
 	HaproxyConfTmpl    string
@@ -130,6 +145,7 @@ type SLoadbalancerAgentParams struct {
 	Vrrp               SLoadbalancerAgentParamsVrrp
 	Haproxy            SLoadbalancerAgentParamsHaproxy
 	Telegraf           SLoadbalancerAgentParamsTelegraf
+	AccessLog          SLoadbalancerAgentParamsAccessLog
 }
 
 func (p *SLoadbalancerAgentParamsVrrp) Validate(data *jsonutils.JSONDict) error {
@@ -289,6 +305,61 @@ func (p *SLoadbalancerAgentParamsTelegraf) initDefault(data *jsonutils.JSONDict)
 	}
 }
 
+func (p *SLoadbalancerAgentParamsAccessLog) Validate(data *jsonutils.JSONDict) error {
+	if p.BatchSize < 1 {
+		p.BatchSize = 1
+	}
+	if p.BatchSize > 10000 {
+		p.BatchSize = 10000
+	}
+	if p.FlushInterval < 1 {
+		p.FlushInterval = 1
+	}
+	if p.FlushInterval > 3600 {
+		p.FlushInterval = 3600
+	}
+	switch p.Sink {
+	case "", api.LB_ACCESS_LOG_SINK_LOGGER:
+	case api.LB_ACCESS_LOG_SINK_SYSLOG:
+		if p.SyslogAddr == "" {
+			return httperrors.NewInputParameterError("access_log params: syslog_addr is required for sink %s", p.Sink)
+		}
+		if u, err := url.Parse(p.SyslogAddr); err != nil {
+			return httperrors.NewInputParameterError("access_log params: invalid syslog_addr: %s", err)
+		} else if u.Scheme != "udp" && u.Scheme != "tcp" && u.Scheme != "unix" && u.Scheme != "unixgram" {
+			return httperrors.NewInputParameterError("access_log params: syslog_addr scheme must be one of udp, tcp, unix, unixgram, got %q", u.Scheme)
+		}
+	case api.LB_ACCESS_LOG_SINK_S3:
+		if p.S3Endpoint == "" || p.S3Bucket == "" {
+			return httperrors.NewInputParameterError("access_log params: s3_endpoint and s3_bucket are required for sink %s", p.Sink)
+		}
+		if p.S3AccessKey == "" || p.S3SecretKey == "" {
+			return httperrors.NewInputParameterError("access_log params: s3_access_key and s3_secret_key are required for sink %s", p.Sink)
+		}
+	default:
+		return httperrors.NewInputParameterError("access_log params: invalid sink %q, want one of %s",
+			p.Sink, api.LB_ACCESS_LOG_SINKS)
+	}
+	return nil
+}
+
+func (p *SLoadbalancerAgentParamsAccessLog) needsUpdatePeer(pp *SLoadbalancerAgentParamsAccessLog) bool {
+	return *p != *pp
+}
+
+func (p *SLoadbalancerAgentParamsAccessLog) updateBy(pp *SLoadbalancerAgentParamsAccessLog) {
+	*p = *pp
+}
+
+func (p *SLoadbalancerAgentParamsAccessLog) initDefault(data *jsonutils.JSONDict) {
+	if p.BatchSize == 0 {
+		p.BatchSize = 1000
+	}
+	if p.FlushInterval == 0 {
+		p.FlushInterval = 10
+	}
+}
+
 func (p *SLoadbalancerAgentParams) validateTmpl(k, s string) error {
 	d, err := base64.StdEncoding.DecodeString(s)
 	if err != nil {
@@ -315,6 +386,7 @@ func (p *SLoadbalancerAgentParams) initDefault(data *jsonutils.JSONDict) {
 	p.Vrrp.initDefault(data)
 	p.Haproxy.initDefault(data)
 	p.Telegraf.initDefault(data)
+	p.AccessLog.initDefault(data)
 }
 
 func (p *SLoadbalancerAgentParams) Validate(data *jsonutils.JSONDict) error {
@@ -337,6 +409,9 @@ func (p *SLoadbalancerAgentParams) Validate(data *jsonutils.JSONDict) error {
 	if err := p.Telegraf.Validate(data); err != nil {
 		return err
 	}
+	if err := p.AccessLog.Validate(data); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -348,7 +423,8 @@ func (p *SLoadbalancerAgentParams) needsUpdatePeer(pp *SLoadbalancerAgentParams)
 	}
 	return p.Vrrp.needsUpdatePeer(&pp.Vrrp) ||
 		p.Haproxy.needsUpdatePeer(&pp.Haproxy) ||
-		p.Telegraf.needsUpdatePeer(&pp.Telegraf)
+		p.Telegraf.needsUpdatePeer(&pp.Telegraf) ||
+		p.AccessLog.needsUpdatePeer(&pp.AccessLog)
 }
 
 func (p *SLoadbalancerAgentParams) updateBy(pp *SLoadbalancerAgentParams) {
@@ -359,6 +435,7 @@ func (p *SLoadbalancerAgentParams) updateBy(pp *SLoadbalancerAgentParams) {
 	p.Vrrp.updateBy(&pp.Vrrp)
 	p.Haproxy.updateBy(&pp.Haproxy)
 	p.Telegraf.updateBy(&pp.Telegraf)
+	p.AccessLog.updateBy(&pp.AccessLog)
 }
 
 func (p *SLoadbalancerAgentParams) String() string {
@@ -383,6 +383,18 @@ func (lbbg *SLoadbalancerBackendGroup) RefCount() (int, error) {
 		}
 		count += cnt
 	}
+	{
+		// weighted rules reference it other than by backend_group_id
+		cnt, err := LoadbalancerListenerRuleManager.Query().
+			IsFalse("pending_deleted").
+			NotEquals("backend_group_id", lbbg.Id).
+			Contains("backend_groups", lbbg.Id).
+			CountWithError()
+		if err != nil {
+			return -1, err
+		}
+		count += cnt
+	}
 
 	return count, nil
 }
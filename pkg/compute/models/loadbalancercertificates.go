@@ -26,6 +26,7 @@ import (
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
 	"yunion.io/x/sqlchemy"
 
 	"yunion.io/x/onecloud/pkg/apis"
@@ -63,7 +64,6 @@ func init() {
 
 // TODO
 //
-//  - notify users of cert expiration
 //  - ca info: self-signed, public ca
 type SLoadbalancerCertificate struct {
 	db.SSharableVirtualResourceBase
@@ -73,6 +73,19 @@ type SLoadbalancerCertificate struct {
 	// SCloudregionResourceBase
 
 	db.SCertificateResourceBase
+
+	// ACME验证方式, 为空表示用户上传的证书
+	// example: http-01
+	AcmeChallenge string `width:"16" charset:"ascii" nullable:"true" list:"user" create:"optional"`
+	// 通过ACME签发证书的域名, 以逗号分隔
+	// example: www.example.com,*.example.com
+	AcmeDomains string `width:"1024" charset:"ascii" nullable:"true" list:"user" create:"optional"`
+	// 待负载均衡代理响应的http-01验证, token到key authorization的映射
+	AcmeHttpTokens *jsonutils.JSONDict `nullable:"true" list:"admin"`
+
+	// 证书类型, server: 服务端证书; ca: 用于校验客户端或后端服务器证书的CA证书包, 不含私钥
+	// example: server
+	CertificateType string `width:"16" charset:"ascii" nullable:"true" list:"user" create:"optional" default:"server"`
 }
 
 func (lbcert *SLoadbalancerCertificate) GetCachedCerts() ([]SCachedLoadbalancerCertificate, error) {
@@ -115,10 +128,24 @@ func (lbcert *SLoadbalancerCertificate) ValidateUpdateData(ctx context.Context,
 
 func (lbcert *SLoadbalancerCertificate) PostCreate(ctx context.Context, userCred mcclient.TokenCredential, ownerProjId mcclient.IIdentityProvider, query jsonutils.JSONObject, data jsonutils.JSONObject) {
 	lbcert.SSharableVirtualResourceBase.PostCreate(ctx, userCred, ownerProjId, query, data)
+	if lbcert.IsAcme() {
+		err := lbcert.StartAcmeIssueTask(ctx, userCred, false, "")
+		if err != nil {
+			lbcert.SetStatus(userCred, api.LB_CERT_STATUS_ISSUE_FAILED, err.Error())
+		}
+		return
+	}
 	lbcert.SetStatus(userCred, api.LB_STATUS_ENABLED, "")
 }
 
+func (lbcert *SLoadbalancerCertificate) IsCA() bool {
+	return lbcert.CertificateType == api.LB_CERT_TYPE_CA
+}
+
 func (lbcert *SLoadbalancerCertificate) IsComplete() bool {
+	if lbcert.IsCA() {
+		return lbcert.Certificate != ""
+	}
 	return lbcert.PrivateKey != "" && lbcert.Certificate != ""
 }
 
@@ -170,7 +197,11 @@ func (lbcert *SLoadbalancerCertificate) ValidateDeleteCondition(ctx context.Cont
 		t := man.TableSpec().Instance()
 		pdF := t.Field("pending_deleted")
 		n, err := t.Query().
-			Equals("certificate_id", lbcertId).
+			Filter(sqlchemy.OR(
+				sqlchemy.Equals(t.Field("certificate_id"), lbcertId),
+				sqlchemy.Equals(t.Field("client_ca_certificate_id"), lbcertId),
+				sqlchemy.Equals(t.Field("backend_ca_certificate_id"), lbcertId),
+			)).
 			Filter(sqlchemy.OR(sqlchemy.IsNull(pdF), sqlchemy.IsFalse(pdF))).
 			CountWithError()
 		if err != nil {
@@ -275,6 +306,16 @@ func (man *SLoadbalancerCertificateManager) ListItemFilter(
 	if len(query.SubjectAlternativeNames) > 0 {
 		q = q.In("subject_alternative_names", query.SubjectAlternativeNames)
 	}
+	if len(query.CertificateType) > 0 {
+		if utils.IsInStringArray(api.LB_CERT_TYPE_SERVER, query.CertificateType) {
+			q = q.Filter(sqlchemy.OR(
+				sqlchemy.In(q.Field("certificate_type"), query.CertificateType),
+				sqlchemy.IsNullOrEmpty(q.Field("certificate_type")),
+			))
+		} else {
+			q = q.In("certificate_type", query.CertificateType)
+		}
+	}
 
 	return q, nil
 }
@@ -307,11 +348,35 @@ func (man *SLoadbalancerCertificateManager) QueryDistinctExtraField(q *sqlchemy.
 }
 
 func (man *SLoadbalancerCertificateManager) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, data *jsonutils.JSONDict) (*jsonutils.JSONDict, error) {
-	v := validators.NewCertKeyValidator("certificate", "private_key")
-	if err := v.Validate(data); err != nil {
+	certTypeV := validators.NewStringChoicesValidator("certificate_type", api.LB_CERT_TYPES)
+	certTypeV.Default(api.LB_CERT_TYPE_SERVER)
+	if err := certTypeV.Validate(data); err != nil {
 		return nil, err
 	}
-	data = v.UpdateCertKeyInfo(ctx, data)
+	if certTypeV.Value == api.LB_CERT_TYPE_CA {
+		if data.Contains("acme_challenge") {
+			return nil, httperrors.NewInputParameterError("ca certificate cannot be issued by ACME")
+		}
+		if data.Contains("private_key") {
+			return nil, httperrors.NewInputParameterError("ca certificate should not have private_key")
+		}
+		v := validators.NewCABundleValidator("certificate")
+		if err := v.Validate(data); err != nil {
+			return nil, err
+		}
+		data = v.UpdateCABundleInfo(ctx, data)
+		data.Set("private_key", jsonutils.NewString(""))
+	} else if challenge, _ := data.GetString("acme_challenge"); len(challenge) > 0 {
+		if err := man.validateAcmeCreateData(ctx, userCred, ownerId, data); err != nil {
+			return nil, err
+		}
+	} else {
+		v := validators.NewCertKeyValidator("certificate", "private_key")
+		if err := v.Validate(data); err != nil {
+			return nil, err
+		}
+		data = v.UpdateCertKeyInfo(ctx, data)
+	}
 
 	input := apis.SharableVirtualResourceCreateInput{}
 	err := data.Unmarshal(&input)
@@ -0,0 +1,374 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/regutils"
+	"yunion.io/x/pkg/utils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/cloudcommon/validators"
+	"yunion.io/x/onecloud/pkg/cloudprovider"
+	"yunion.io/x/onecloud/pkg/compute/options"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/acmeutils"
+)
+
+const (
+	// 单张证书最多包含的域名数量, 与Let's Encrypt的限制一致
+	acmeMaxDomains = 100
+	// dns-01验证TXT记录的TTL
+	acmeDns01RecordTTL = 60
+)
+
+func (lbcert *SLoadbalancerCertificate) IsAcme() bool {
+	return len(lbcert.AcmeChallenge) > 0
+}
+
+func (lbcert *SLoadbalancerCertificate) GetAcmeDomains() []string {
+	domains := []string{}
+	for _, domain := range strings.Split(lbcert.AcmeDomains, ",") {
+		if domain = strings.TrimSpace(domain); len(domain) > 0 {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// fetchAcmeDnsZone returns the public dns zone of domain holding the TXT
+// records of dns-01 challenges, the longest matching zone wins
+func (manager *SDnsZoneManager) fetchAcmeDnsZone(domain string, domainId string) (*SDnsZone, error) {
+	labels := strings.Split(strings.TrimPrefix(domain, "*."), ".")
+	for i := 0; i < len(labels)-1; i++ {
+		zones := []SDnsZone{}
+		q := manager.Query().Equals("name", strings.Join(labels[i:], ".")).
+			Equals("zone_type", string(cloudprovider.PublicZone)).
+			Equals("domain_id", domainId).
+			IsTrue("enabled")
+		err := db.FetchModelObjects(manager, q, &zones)
+		if err != nil {
+			return nil, errors.Wrapf(err, "db.FetchModelObjects")
+		}
+		if len(zones) > 0 {
+			return &zones[0], nil
+		}
+	}
+	return nil, errors.Wrapf(httperrors.ErrNotFound, "public dns zone of %s", domain)
+}
+
+func (manager *SLoadbalancerCertificateManager) validateAcmeCreateData(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, data *jsonutils.JSONDict) error {
+	challenge, _ := data.GetString("acme_challenge")
+	if !api.LB_CERT_ACME_CHALLENGES.Has(challenge) {
+		return httperrors.NewInputParameterError("invalid acme_challenge %s, want %s", challenge, api.LB_CERT_ACME_CHALLENGES.String())
+	}
+	if data.Contains("certificate") || data.Contains("private_key") {
+		return httperrors.NewInputParameterError("certificate and private_key are issued by ACME, should not be specified")
+	}
+
+	domainStr, _ := data.GetString("acme_domains")
+	domains := []string{}
+	for _, domain := range strings.Split(domainStr, ",") {
+		domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+		if len(domain) == 0 || utils.IsInStringArray(domain, domains) {
+			continue
+		}
+		name := domain
+		if strings.HasPrefix(domain, "*.") {
+			if challenge != api.LB_CERT_ACME_CHALLENGE_DNS01 {
+				return httperrors.NewInputParameterError("wildcard domain %s requires %s challenge", domain, api.LB_CERT_ACME_CHALLENGE_DNS01)
+			}
+			name = strings.TrimPrefix(domain, "*.")
+		}
+		if !regutils.MatchDomainName(name) {
+			return httperrors.NewInputParameterError("invalid domain %s", domain)
+		}
+		if challenge == api.LB_CERT_ACME_CHALLENGE_DNS01 {
+			_, err := DnsZoneManager.fetchAcmeDnsZone(domain, ownerId.GetProjectDomainId())
+			if err != nil {
+				if errors.Cause(err) == httperrors.ErrNotFound {
+					return httperrors.NewResourceNotFoundError("no public dns zone found for domain %s", domain)
+				}
+				return httperrors.NewGeneralError(err)
+			}
+		}
+		domains = append(domains, domain)
+	}
+	if len(domains) == 0 {
+		return httperrors.NewMissingParameterError("acme_domains")
+	}
+	if len(domains) > acmeMaxDomains {
+		return httperrors.NewInputParameterError("too many acme_domains, at most %d", acmeMaxDomains)
+	}
+
+	data.Set("acme_domains", jsonutils.NewString(strings.Join(domains, ",")))
+	data.Set("certificate", jsonutils.NewString(""))
+	data.Set("private_key", jsonutils.NewString(""))
+	return nil
+}
+
+func (lbcert *SLoadbalancerCertificate) StartAcmeIssueTask(ctx context.Context, userCred mcclient.TokenCredential, renew bool, parentTaskId string) error {
+	params := jsonutils.NewDict()
+	params.Set("renew", jsonutils.NewBool(renew))
+	task, err := taskman.TaskManager.NewTask(ctx, "LoadbalancerCertificateAcmeIssueTask", lbcert, userCred, params, parentTaskId, "", nil)
+	if err != nil {
+		return errors.Wrap(err, "NewTask")
+	}
+	if renew {
+		lbcert.SetStatus(userCred, api.LB_CERT_STATUS_RENEWING, "")
+	} else {
+		lbcert.SetStatus(userCred, api.LB_CERT_STATUS_ISSUING, "")
+	}
+	task.ScheduleRun(nil)
+	return nil
+}
+
+// 续期ACME证书
+func (lbcert *SLoadbalancerCertificate) PerformRenew(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.LoadbalancerCertificateRenewInput) (jsonutils.JSONObject, error) {
+	if !lbcert.IsAcme() {
+		return nil, httperrors.NewUnsupportOperationError("certificate %s is not issued by ACME", lbcert.Name)
+	}
+	if !utils.IsInStringArray(lbcert.Status, []string{api.LB_STATUS_ENABLED, api.LB_CERT_STATUS_ISSUE_FAILED}) {
+		return nil, httperrors.NewInvalidStatusError("can not renew certificate in status %s", lbcert.Status)
+	}
+	return nil, lbcert.StartAcmeIssueTask(ctx, userCred, lbcert.IsComplete(), "")
+}
+
+// AcmeIssue obtains the certificate of acme domains from the ACME CA and
+// replaces the certificate and private key in place, so that load
+// balancers referring to it pick the new one up on next config sync
+func (lbcert *SLoadbalancerCertificate) AcmeIssue(ctx context.Context, userCred mcclient.TokenCredential) error {
+	var solver acmeutils.IChallengeSolver
+	switch lbcert.AcmeChallenge {
+	case api.LB_CERT_ACME_CHALLENGE_HTTP01:
+		solver = &sLbcertHttp01Solver{lbcert: lbcert}
+	case api.LB_CERT_ACME_CHALLENGE_DNS01:
+		solver = &sLbcertDns01Solver{lbcert: lbcert, userCred: userCred}
+	default:
+		return errors.Wrapf(acmeutils.ErrNoChallenge, "%s", lbcert.AcmeChallenge)
+	}
+	domains := lbcert.GetAcmeDomains()
+	if len(domains) == 0 {
+		return errors.Wrap(httperrors.ErrMissingParameter, "acme_domains")
+	}
+
+	cli, err := LoadbalancerAcmeAccountManager.GetAcmeClient(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "GetAcmeClient")
+	}
+	wait := time.Duration(options.Options.AcmeChallengeWaitSeconds) * time.Second
+	certPem, keyPem, err := cli.ObtainCertificate(ctx, domains, solver, wait)
+	if err != nil {
+		return errors.Wrapf(err, "ObtainCertificate")
+	}
+
+	data := jsonutils.NewDict()
+	data.Set("certificate", jsonutils.NewString(certPem))
+	data.Set("private_key", jsonutils.NewString(keyPem))
+	v := validators.NewCertKeyValidator("certificate", "private_key")
+	err = v.Validate(data)
+	if err != nil {
+		return errors.Wrapf(err, "validate issued certificate")
+	}
+	data = v.UpdateCertKeyInfo(ctx, data)
+	_, err = db.Update(lbcert, func() error {
+		return data.Unmarshal(&lbcert.SCertificateResourceBase)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "db.Update")
+	}
+	notes := jsonutils.NewDict()
+	notes.Set("fingerprint", jsonutils.NewString(lbcert.Fingerprint))
+	notes.Set("not_after", jsonutils.NewTimeString(lbcert.NotAfter))
+	db.OpsLog.LogEvent(lbcert, db.ACT_UPDATE, notes, userCred)
+	return nil
+}
+
+// sLbcertHttp01Solver publishes http-01 responses in acme_http_tokens of
+// the certificate, lbagent serves them at /.well-known/acme-challenge/ of
+// http and https listeners in the same project
+type sLbcertHttp01Solver struct {
+	lbcert *SLoadbalancerCertificate
+}
+
+func (s *sLbcertHttp01Solver) ChallengeType() string {
+	return acmeutils.CHALLENGE_HTTP01
+}
+
+func (s *sLbcertHttp01Solver) updateTokens(token string, response string) error {
+	_, err := db.Update(s.lbcert, func() error {
+		tokens := jsonutils.NewDict()
+		if s.lbcert.AcmeHttpTokens != nil {
+			tokens.Update(s.lbcert.AcmeHttpTokens)
+		}
+		if len(response) > 0 {
+			tokens.Set(token, jsonutils.NewString(response))
+		} else {
+			tokens.Remove(token)
+		}
+		if tokens.Length() == 0 {
+			tokens = nil
+		}
+		s.lbcert.AcmeHttpTokens = tokens
+		return nil
+	})
+	return err
+}
+
+func (s *sLbcertHttp01Solver) Present(ctx context.Context, domain string, token string, response string) error {
+	return s.updateTokens(token, response)
+}
+
+func (s *sLbcertHttp01Solver) CleanUp(ctx context.Context, domain string, token string, response string) error {
+	return s.updateTokens(token, "")
+}
+
+// sLbcertDns01Solver publishes dns-01 responses as TXT records in the
+// public dns zones of the certificate domain
+type sLbcertDns01Solver struct {
+	lbcert   *SLoadbalancerCertificate
+	userCred mcclient.TokenCredential
+}
+
+func (s *sLbcertDns01Solver) ChallengeType() string {
+	return acmeutils.CHALLENGE_DNS01
+}
+
+func (s *sLbcertDns01Solver) recordName(zone *SDnsZone, domain string) string {
+	name := acmeutils.DNS01RecordName(domain)
+	return strings.TrimSuffix(strings.TrimSuffix(name, zone.Name), ".")
+}
+
+func (s *sLbcertDns01Solver) Present(ctx context.Context, domain string, token string, response string) error {
+	zone, err := DnsZoneManager.fetchAcmeDnsZone(domain, s.lbcert.DomainId)
+	if err != nil {
+		return errors.Wrapf(err, "fetchAcmeDnsZone")
+	}
+	record := api.SDnsRecordSet{
+		DnsType:  string(cloudprovider.DnsTypeTXT),
+		DnsValue: response,
+		TTL:      acmeDns01RecordTTL,
+	}
+	record.Name = s.recordName(zone, domain)
+	added, err := zone.AddDnsRecordSet(ctx, s.userCred, record)
+	if err != nil {
+		return errors.Wrapf(err, "AddDnsRecordSet %s", record.Name)
+	}
+	if !added {
+		// either the same record is there already, or it is ignored for
+		// conflicting with CNAME and the challenge would never succeed
+		present, err := s.isPresent(zone, record.Name, response)
+		if err != nil {
+			return err
+		}
+		if !present {
+			return errors.Wrapf(httperrors.ErrConflict, "TXT record %s conflicts with existing records of zone %s", record.Name, zone.Name)
+		}
+	}
+	zone.incSerial(ctx)
+	return zone.DoSyncRecords(ctx, s.userCred)
+}
+
+func (s *sLbcertDns01Solver) isPresent(zone *SDnsZone, name string, response string) (bool, error) {
+	records, err := zone.GetDnsRecordSetsByName(name, string(cloudprovider.DnsTypeTXT))
+	if err != nil {
+		return false, errors.Wrapf(err, "GetDnsRecordSetsByName")
+	}
+	for i := range records {
+		if records[i].DnsValue == response {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *sLbcertDns01Solver) CleanUp(ctx context.Context, domain string, token string, response string) error {
+	zone, err := DnsZoneManager.fetchAcmeDnsZone(domain, s.lbcert.DomainId)
+	if err != nil {
+		return errors.Wrapf(err, "fetchAcmeDnsZone")
+	}
+	records, err := zone.GetDnsRecordSetsByName(s.recordName(zone, domain), string(cloudprovider.DnsTypeTXT))
+	if err != nil {
+		return errors.Wrapf(err, "GetDnsRecordSetsByName")
+	}
+	remove := []SDnsRecordSet{}
+	for i := range records {
+		if records[i].DnsValue == response {
+			remove = append(remove, records[i])
+		}
+	}
+	if len(remove) == 0 {
+		return nil
+	}
+	err = zone.RemoveDnsRecordSets(ctx, s.userCred, remove)
+	if err != nil {
+		return errors.Wrapf(err, "RemoveDnsRecordSets")
+	}
+	zone.incSerial(ctx)
+	return zone.DoSyncRecords(ctx, s.userCred)
+}
+
+// AutoRenewAcmeCertificates renews ACME certificates expiring within
+// AcmeRenewBeforeDays, certificates failed to renew are retried next round
+func (manager *SLoadbalancerCertificateManager) AutoRenewAcmeCertificates(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	lbcerts := []SLoadbalancerCertificate{}
+	q := manager.Query().IsNotEmpty("acme_challenge").IsFalse("pending_deleted").
+		Equals("status", api.LB_STATUS_ENABLED).
+		LT("not_after", time.Now().AddDate(0, 0, options.Options.AcmeRenewBeforeDays))
+	err := db.FetchModelObjects(manager, q, &lbcerts)
+	if err != nil {
+		log.Errorf("fetch acme certificates to renew error: %v", err)
+		return
+	}
+	for i := range lbcerts {
+		lbcert := &lbcerts[i]
+		err := lbcert.StartAcmeIssueTask(ctx, userCred, true, "")
+		if err != nil {
+			log.Errorf("start renewing certificate %s(%s) error: %v", lbcert.Name, lbcert.Id, err)
+		}
+	}
+}
+
+func (lbcert *SLoadbalancerCertificate) GetExpiredAt() time.Time {
+	return lbcert.NotAfter
+}
+
+// GetExpiredModels returns certificates expiring in advanceDay days. ACME
+// certificates are renewed AcmeRenewBeforeDays ahead, so they are reported
+// only when still not renewed by then
+func (manager *SLoadbalancerCertificateManager) GetExpiredModels(advanceDay int) ([]IBillingModel, error) {
+	expired, err := fetchExpiredModelsByField(manager, "not_after", advanceDay)
+	if err != nil {
+		return nil, err
+	}
+	ret := []IBillingModel{}
+	for i := range expired {
+		lbcert := expired[i].(*SLoadbalancerCertificate)
+		if lbcert.PendingDeleted || (lbcert.IsAcme() && advanceDay >= options.Options.AcmeRenewBeforeDays) {
+			continue
+		}
+		ret = append(ret, lbcert)
+	}
+	return ret, nil
+}
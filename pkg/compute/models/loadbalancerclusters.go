@@ -16,6 +16,7 @@ package models
 
 import (
 	"context"
+	"strings"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
@@ -56,6 +57,24 @@ type SLoadbalancerCluster struct {
 	SZoneResourceBase
 	SWireResourceBase `width:"36" charset:"ascii" nullable:"true" list:"admin" create:"optional" update:"admin"`
 	//WireId string `width:"36" charset:"ascii" nullable:"true" list:"admin" create:"optional" update:"admin"`
+
+	// 集群内负载均衡代理使用的数据面, haproxy|envoy
+	// example: haproxy
+	DataPlane string `width:"16" charset:"ascii" nullable:"true" list:"admin" create:"optional" update:"admin" default:"haproxy"`
+
+	// 集群高可用模式, vrrp|bgp
+	// vrrp模式下地址只在主节点生效; bgp模式下所有健康节点同时宣告地址, 由对端路由器ECMP分担流量
+	// example: vrrp
+	HaMode string `width:"16" charset:"ascii" nullable:"true" list:"admin" create:"optional" update:"admin" default:"vrrp"`
+	// bgp模式下本端AS号
+	// example: 65001
+	BgpLocalAsn int64 `nullable:"true" list:"admin" create:"optional" update:"admin"`
+	// bgp模式下的对端, 格式为ip:asn, 多个以逗号分隔
+	// example: 192.168.1.1:65000,192.168.1.2:65000
+	BgpPeers string `width:"1024" charset:"ascii" nullable:"true" list:"admin" create:"optional" update:"admin"`
+	// bgp会话保持时间, 单位秒
+	// example: 9
+	BgpHoldTime int `nullable:"true" list:"admin" create:"optional" update:"admin" default:"9"`
 }
 
 // 负载均衡集群列表
@@ -82,6 +101,12 @@ func (man *SLoadbalancerClusterManager) ListItemFilter(
 	if err != nil {
 		return nil, errors.Wrap(err, "SWireResourceBaseManager.ListItemFilter")
 	}
+	if len(query.DataPlane) > 0 {
+		q = q.In("data_plane", query.DataPlane)
+	}
+	if len(query.HaMode) > 0 {
+		q = q.In("ha_mode", query.HaMode)
+	}
 
 	return q, nil
 }
@@ -138,12 +163,17 @@ func (man *SLoadbalancerClusterManager) ValidateCreateData(ctx context.Context,
 	vs := []validators.IValidator{
 		zoneV,
 		wireV.Optional(true),
+		validators.NewStringChoicesValidator("data_plane", api.LB_DATA_PLANES).Default(api.LB_DATA_PLANE_HAPROXY),
+		validators.NewStringChoicesValidator("ha_mode", api.LB_HA_MODES).Default(api.LB_HA_MODE_VRRP),
 	}
 	for _, v := range vs {
 		if err := v.Validate(data); err != nil {
 			return nil, err
 		}
 	}
+	if err := man.validateBgpData(data, nil); err != nil {
+		return nil, err
+	}
 	zone := zoneV.Model.(*SZone)
 	if zone.ExternalId != "" {
 		return nil, httperrors.NewInputParameterError("allow only internal zone, got %s(%s)", zone.Name, zone.Id)
@@ -169,12 +199,92 @@ func (man *SLoadbalancerClusterManager) ValidateCreateData(ctx context.Context,
 	return data, nil
 }
 
+// validateBgpData checks bgp params against the resulting ha mode.  lbc is
+// nil on create
+func (man *SLoadbalancerClusterManager) validateBgpData(data *jsonutils.JSONDict, lbc *SLoadbalancerCluster) error {
+	isUpdate := lbc != nil
+	asnV := validators.NewRangeValidator("bgp_local_asn", api.LB_BGP_ASN_MIN, api.LB_BGP_ASN_MAX)
+	holdTimeV := validators.NewRangeValidator("bgp_hold_time", 3, 65535)
+	peersV := validators.NewStringLenRangeValidator("bgp_peers", 0, 1024)
+	vs := []validators.IValidator{
+		asnV.Optional(true),
+		peersV.Optional(true),
+	}
+	if isUpdate {
+		vs = append(vs, holdTimeV.Optional(true))
+	} else {
+		vs = append(vs, holdTimeV.Default(api.LB_BGP_HOLD_TIME_DEFAULT))
+	}
+	for _, v := range vs {
+		if err := v.Validate(data); err != nil {
+			return err
+		}
+	}
+
+	var (
+		haMode, _   = data.GetString("ha_mode")
+		asn, _      = data.Int("bgp_local_asn")
+		peersStr, _ = data.GetString("bgp_peers")
+	)
+	if isUpdate {
+		if !data.Contains("ha_mode") {
+			haMode = lbc.HaMode
+		}
+		if !data.Contains("bgp_local_asn") {
+			asn = lbc.BgpLocalAsn
+		}
+		if !data.Contains("bgp_peers") {
+			peersStr = lbc.BgpPeers
+		}
+	}
+	if peersStr != "" {
+		if _, err := api.ParseLoadbalancerClusterBgpPeers(peersStr); err != nil {
+			return httperrors.NewInputParameterError("bgp_peers: %v", err)
+		}
+	}
+	if haMode == api.LB_HA_MODE_BGP {
+		if asn == 0 {
+			return httperrors.NewMissingParameterError("bgp_local_asn")
+		}
+		if peersStr == "" {
+			return httperrors.NewMissingParameterError("bgp_peers")
+		}
+	}
+	return nil
+}
+
 func (lbc *SLoadbalancerCluster) ValidateUpdateData(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data *jsonutils.JSONDict) (*jsonutils.JSONDict, error) {
 	wireV := validators.NewModelIdOrNameValidator("wire", "wire", lbc.GetOwnerId())
 	wireV.Optional(true)
 	if err := wireV.Validate(data); err != nil {
 		return nil, err
 	}
+	dataPlaneV := validators.NewStringChoicesValidator("data_plane", api.LB_DATA_PLANES)
+	dataPlaneV.Optional(true)
+	if err := dataPlaneV.Validate(data); err != nil {
+		return nil, err
+	}
+	if dataPlaneV.Value != "" && dataPlaneV.Value != lbc.DataPlane {
+		if dataPlaneV.Value == api.LB_DATA_PLANE_ENVOY {
+			if err := lbc.validateEnvoyDataPlane(); err != nil {
+				return nil, err
+			}
+		}
+		log.Infof("changing data plane of lbcluster %s(%s) from %s to %s",
+			lbc.Name, lbc.Id, lbc.DataPlane, dataPlaneV.Value)
+	}
+	haModeV := validators.NewStringChoicesValidator("ha_mode", api.LB_HA_MODES)
+	haModeV.Optional(true)
+	if err := haModeV.Validate(data); err != nil {
+		return nil, err
+	}
+	if haModeV.Value != "" && haModeV.Value != lbc.HaMode {
+		log.Infof("changing ha mode of lbcluster %s(%s) from %s to %s",
+			lbc.Name, lbc.Id, lbc.HaMode, haModeV.Value)
+	}
+	if err := LoadbalancerClusterManager.validateBgpData(data, lbc); err != nil {
+		return nil, err
+	}
 	if wireV.Model != nil {
 		wire := wireV.Model.(*SWire)
 		if wire.ZoneId != lbc.ZoneId {
@@ -202,6 +312,41 @@ func (lbc *SLoadbalancerCluster) ValidateUpdateData(ctx context.Context, userCre
 	return data, nil
 }
 
+// validateEnvoyDataPlane rejects switching to envoy when listeners of the
+// cluster use features envoy does not implement, as they would be dropped
+func (lbc *SLoadbalancerCluster) validateEnvoyDataPlane() error {
+	q := LoadbalancerManager.Query().Equals("cluster_id", lbc.Id).IsFalse("pending_deleted")
+	lbs := []SLoadbalancer{}
+	err := db.FetchModelObjects(LoadbalancerManager, q, &lbs)
+	if err != nil {
+		return httperrors.NewInternalServerError("fetch loadbalancers of lbcluster %s: %v", lbc.Id, err)
+	}
+	for i := range lbs {
+		listeners, err := lbs[i].GetLoadbalancerListeners()
+		if err != nil {
+			return httperrors.NewInternalServerError("fetch listeners of loadbalancer %s: %v", lbs[i].Id, err)
+		}
+		for j := range listeners {
+			lblis := &listeners[j]
+			if features := lblis.GetEnvoyUnsupportedFeatures(); len(features) > 0 {
+				return httperrors.NewNotSupportedError("listener %s(%s) uses %s, which is not supported by envoy data plane",
+					lblis.Name, lblis.Id, strings.Join(features, ", "))
+			}
+			rules, err := lblis.GetLoadbalancerListenerRules()
+			if err != nil {
+				return httperrors.NewInternalServerError("fetch rules of listener %s: %v", lblis.Id, err)
+			}
+			for k := range rules {
+				if features := rules[k].GetEnvoyUnsupportedFeatures(); len(features) > 0 {
+					return httperrors.NewNotSupportedError("listener rule %s(%s) uses %s, which is not supported by envoy data plane",
+						rules[k].Name, rules[k].Id, strings.Join(features, ", "))
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func (lbc *SLoadbalancerCluster) ValidateDeleteCondition(ctx context.Context, info jsonutils.JSONObject) error {
 	men := []db.IModelManager{
 		LoadbalancerManager,
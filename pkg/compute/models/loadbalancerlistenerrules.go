@@ -18,10 +18,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"reflect"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/gotypes"
 	"yunion.io/x/pkg/util/compare"
 	"yunion.io/x/pkg/utils"
 	"yunion.io/x/sqlchemy"
@@ -57,6 +59,10 @@ func init() {
 		),
 	}
 	LoadbalancerListenerRuleManager.SetVirtualObject(LoadbalancerListenerRuleManager)
+
+	gotypes.RegisterSerializable(reflect.TypeOf(&SLoadbalancerListenerRuleBackendGroups{}), func() gotypes.ISerializable {
+		return &SLoadbalancerListenerRuleBackendGroups{}
+	})
 }
 
 type SLoadbalancerListenerRule struct {
@@ -76,9 +82,65 @@ type SLoadbalancerListenerRule struct {
 	Path      string `width:"128" charset:"ascii" nullable:"true" list:"user" create:"optional"`
 	Condition string `charset:"ascii" nullable:"true" list:"user" create:"optional"`
 
+	// 按权重转发到多个后端服务器组，用于灰度发布，设置时BackendGroupId为其中第一个
+	BackendGroups *SLoadbalancerListenerRuleBackendGroups `nullable:"true" list:"user" create:"optional" update:"user"`
+
 	SLoadbalancerHealthCheck // 目前只有腾讯云HTTP、HTTPS类型的健康检查是和规则绑定的。
 	SLoadbalancerHTTPRateLimiter
 	SLoadbalancerHTTPRedirect
+	SLoadbalancerHTTPRewrite
+	SLoadbalancerHTTPFixedResponse
+}
+
+type SLoadbalancerHTTPRewrite struct {
+	RewriteHost string `width:"128" charset:"ascii" nullable:"true" list:"user" create:"optional" update:"user"` // 转发时变更Host
+	RewritePath string `width:"128" charset:"ascii" nullable:"true" list:"user" create:"optional" update:"user"` // 转发时将匹配的Path前缀替换为该值
+}
+
+type SLoadbalancerHTTPFixedResponse struct {
+	FixedResponseCode        int    `nullable:"true" list:"user" create:"optional" update:"user"`                            // 固定响应HTTP code，0表示不使用固定响应
+	FixedResponseContentType string `width:"64" charset:"ascii" nullable:"true" list:"user" create:"optional" update:"user"` // 固定响应Content-Type
+	FixedResponseBody        string `nullable:"true" list:"user" create:"optional" update:"user"`                            // 固定响应内容
+}
+
+type SLoadbalancerListenerRuleBackendGroup struct {
+	BackendGroupId string
+	Weight         int
+}
+
+type SLoadbalancerListenerRuleBackendGroups []*SLoadbalancerListenerRuleBackendGroup
+
+func (lbrbgs *SLoadbalancerListenerRuleBackendGroups) String() string {
+	return jsonutils.Marshal(lbrbgs).String()
+}
+
+func (lbrbgs *SLoadbalancerListenerRuleBackendGroups) IsZero() bool {
+	if len([]*SLoadbalancerListenerRuleBackendGroup(*lbrbgs)) == 0 {
+		return true
+	}
+	return false
+}
+
+func (lbrbgs *SLoadbalancerListenerRuleBackendGroups) Validate(data *jsonutils.JSONDict) error {
+	found := map[string]bool{}
+	total := 0
+	for _, lbrbg := range *lbrbgs {
+		if lbrbg.BackendGroupId == "" {
+			return httperrors.NewInputParameterError("empty backend group")
+		}
+		if lbrbg.Weight < 0 || lbrbg.Weight > 256 {
+			return httperrors.NewInputParameterError("backend group %s: weight %d out of range [0, 256]", lbrbg.BackendGroupId, lbrbg.Weight)
+		}
+		if found[lbrbg.BackendGroupId] {
+			return httperrors.NewInputParameterError("backend group duplicate %s", lbrbg.BackendGroupId)
+		}
+		found[lbrbg.BackendGroupId] = true
+		total += lbrbg.Weight
+	}
+	if len(*lbrbgs) > 0 && total == 0 {
+		return httperrors.NewInputParameterError("weights of backend groups are all zero")
+	}
+	return nil
 }
 
 func ValidateListenerRuleConditions(condition string) error {
@@ -378,6 +440,28 @@ func LoadbalancerListenerRuleCheckUniqueness(ctx context.Context, lbls *SLoadbal
 	return nil
 }
 
+// LoadbalancerListenerRuleCheckConditionUniqueness is like
+// LoadbalancerListenerRuleCheckUniqueness, but rules with different
+// conditions can share the same domain and path
+func LoadbalancerListenerRuleCheckConditionUniqueness(ctx context.Context, lbls *SLoadbalancerListener, domain, path, condition string) error {
+	q := LoadbalancerListenerRuleManager.Query().
+		IsFalse("pending_deleted").
+		Equals("listener_id", lbls.Id).
+		Equals("domain", domain).
+		Equals("path", path)
+	if condition == "" {
+		q = q.IsNullOrEmpty("condition")
+	} else {
+		q = q.Equals("condition", condition)
+	}
+	var lblsr SLoadbalancerListenerRule
+	q.First(&lblsr)
+	if len(lblsr.Id) > 0 {
+		return httperrors.NewConflictError("rule %s/%s with the same condition already occupied by rule %s(%s)", domain, path, lblsr.Name, lblsr.Id)
+	}
+	return nil
+}
+
 func (man *SLoadbalancerListenerRuleManager) pendingDeleteSubs(ctx context.Context, userCred mcclient.TokenCredential, q *sqlchemy.SQuery) {
 	subs := []SLoadbalancerListenerRule{}
 	db.FetchModelObjects(man, q, &subs)
@@ -550,6 +634,11 @@ func (lbr *SLoadbalancerListenerRule) StartLoadBalancerListenerRuleDeleteTask(ct
 }
 
 func (lbr *SLoadbalancerListenerRule) ValidateUpdateData(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data *jsonutils.JSONDict) (*jsonutils.JSONDict, error) {
+	// forwarding to a single backend group drops the weighted backend groups
+	if (data.Contains("backend_group") || data.Contains("backend_group_id")) && !data.Contains("backend_groups") &&
+		lbr.BackendGroups != nil && !lbr.BackendGroups.IsZero() {
+		data.Set("backend_groups", jsonutils.NewArray())
+	}
 	backendGroupV := validators.NewModelIdOrNameValidator("backend_group", "loadbalancerbackendgroup", lbr.GetOwnerId())
 	if lbr.BackendGroupId != "" {
 		backendGroupV.Default(lbr.BackendGroupId)
@@ -620,6 +709,26 @@ func (man *SLoadbalancerListenerRuleManager) FetchCustomizeColumns(
 	return rows
 }
 
+// GetEnvoyUnsupportedFeatures returns features enabled on the rule but not
+// implemented by envoy data plane
+func (lbr *SLoadbalancerListenerRule) GetEnvoyUnsupportedFeatures() []string {
+	features := []string{}
+	if lbr.HTTPRequestRate > 0 {
+		features = append(features, "http_request_rate")
+	}
+	if lbr.HTTPRequestRatePerSrc > 0 {
+		features = append(features, "http_request_rate_per_src")
+	}
+	conds, _ := api.ParseLoadbalancerListenerRuleConditions(lbr.Condition)
+	for _, cond := range conds {
+		if cond.Field == api.LB_RULE_CONDITION_SOURCE_IP {
+			features = append(features, "source_ip condition")
+			break
+		}
+	}
+	return features
+}
+
 func (lbr *SLoadbalancerListenerRule) GetRegion() (*SCloudregion, error) {
 	listener, err := lbr.GetLoadbalancerListener()
 	if err != nil {
@@ -102,6 +102,7 @@ type SLoadbalancerHTTPListener struct {
 
 	XForwardedFor bool `nullable:"true" list:"user" create:"optional" update:"user"` // 获取客户端真实IP
 	Gzip          bool `nullable:"true" list:"user" create:"optional" update:"user"` // Gzip数据压缩
+	BackendHttp2  bool `nullable:"true" list:"user" create:"optional" update:"user"` // 以HTTP/2协议访问后端, 如gRPC服务
 }
 
 type SLoadbalancerHTTPRedirect struct {
@@ -112,18 +113,38 @@ type SLoadbalancerHTTPRedirect struct {
 	RedirectPath   string `nullable:"true" list:"user" create:"optional" update:"user"`                          // 跳转时变更Path
 }
 
+type SLoadbalancerAccessLog struct {
+	AccessLog           bool `nullable:"true" list:"user" create:"optional" update:"user"`               // 访问日志开启状态
+	AccessLogSampleRate int  `nullable:"true" list:"user" create:"optional" update:"user" default:"100"` // 访问日志采样百分比 1-100
+}
+
 // TODO
 //
-//  - CACertificate string
 //  - Certificate2Id // multiple certificates for rsa, ecdsa
 //  - Use certificate for tcp listener
-//  - Customize ciphers?
 type SLoadbalancerHTTPSListener struct {
 	SLoadbalancerCertificateResourceBase
 
 	CachedCertificateId string `width:"36" charset:"ascii" nullable:"true" list:"user" create:"optional" update:"user"`
 	TLSCipherPolicy     string `width:"36" charset:"ascii" nullable:"true" list:"user" create:"optional" update:"user"`
 	EnableHttp2         bool   `create:"optional" list:"user" update:"user"`
+
+	// 最低TLS版本, 设置后覆盖TLSCipherPolicy中的版本要求
+	TLSMinVersion string `width:"16" charset:"ascii" nullable:"true" list:"user" create:"optional" update:"user"`
+	// 自定义加密套件, 以冒号分隔, 如 ECDHE-RSA-AES128-GCM-SHA256:ECDHE-RSA-AES256-GCM-SHA384
+	TLSCiphers string `width:"1024" charset:"ascii" nullable:"true" list:"user" create:"optional" update:"user"`
+
+	// 客户端证书校验 off|optional|required
+	ClientVerify string `width:"16" charset:"ascii" nullable:"true" list:"user" create:"optional" update:"user" default:"off"`
+	// 校验客户端证书所用的CA证书
+	ClientCaCertificateId string `width:"36" charset:"ascii" nullable:"true" list:"user" create:"optional" update:"user"`
+	// 是否将客户端证书信息以X-SSL-Client-*请求头传给后端
+	ClientCertHeaders bool `nullable:"true" list:"user" create:"optional" update:"user"`
+
+	// 是否以https访问后端服务器
+	BackendSsl bool `nullable:"true" list:"user" create:"optional" update:"user"`
+	// 校验后端服务器证书所用的CA证书, 为空则不校验
+	BackendCaCertificateId string `width:"36" charset:"ascii" nullable:"true" list:"user" create:"optional" update:"user"`
 }
 
 type SLoadbalancerListener struct {
@@ -162,6 +183,7 @@ type SLoadbalancerListener struct {
 	SLoadbalancerHealthCheck
 	SLoadbalancerHTTPRateLimiter
 	SLoadbalancerHTTPRedirect
+	SLoadbalancerAccessLog
 }
 
 func (man *SLoadbalancerListenerManager) CheckListenerUniqueness(ctx context.Context, lb *SLoadbalancer, listenerType string, listenerPort int64) error {
@@ -867,6 +889,25 @@ func (lblis *SLoadbalancerListener) GetLoadbalancer() (*SLoadbalancer, error) {
 	return loadbalancer, nil
 }
 
+// GetEnvoyUnsupportedFeatures returns features enabled on the listener but not
+// implemented by envoy data plane
+func (lblis *SLoadbalancerListener) GetEnvoyUnsupportedFeatures() []string {
+	features := []string{}
+	if lblis.AccessLog {
+		features = append(features, "access_log")
+	}
+	if lblis.StickySession == api.LB_BOOL_ON {
+		features = append(features, "sticky_session")
+	}
+	if lblis.HTTPRequestRate > 0 {
+		features = append(features, "http_request_rate")
+	}
+	if lblis.HTTPRequestRatePerSrc > 0 {
+		features = append(features, "http_request_rate_per_src")
+	}
+	return features
+}
+
 func (lblis *SLoadbalancerListener) GetRegion() (*SCloudregion, error) {
 	loadbalancer, err := lblis.GetLoadbalancer()
 	if err != nil {
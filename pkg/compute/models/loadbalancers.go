@@ -739,6 +739,15 @@ func (lb *SLoadbalancer) CustomizeDelete(ctx context.Context, userCred mcclient.
 	return lb.StartLoadBalancerDeleteTask(ctx, userCred, jsonutils.NewDict(), "")
 }
 
+// IsEnvoyDataPlane tells whether the loadbalancer is served by lbagents running envoy
+func (lb *SLoadbalancer) IsEnvoyDataPlane() bool {
+	if lb.ClusterId == "" {
+		return false
+	}
+	cluster := lb.GetLoadbalancerCluster()
+	return cluster != nil && cluster.DataPlane == api.LB_DATA_PLANE_ENVOY
+}
+
 func (lb *SLoadbalancer) GetLoadbalancerListeners() ([]SLoadbalancerListener, error) {
 	listeners := []SLoadbalancerListener{}
 	q := LoadbalancerListenerManager.Query().Equals("loadbalancer_id", lb.Id).IsFalse("pending_deleted")
@@ -120,11 +120,16 @@ type SNetwork struct {
 
 	GuestDomain string `width:"128" charset:"ascii" nullable:"true" get:"user" update:"user"`
 
-	GuestIp6Start string `width:"64" charset:"ascii" nullable:"true"`
-	GuestIp6End   string `width:"64" charset:"ascii" nullable:"true"`
-	GuestIp6Mask  int8   `nullable:"true"`
-	GuestGateway6 string `width:"64" charset:"ascii" nullable:"true"`
-	GuestDns6     string `width:"64" charset:"ascii" nullable:"true"`
+	// IPv6起始地址
+	GuestIp6Start string `width:"64" charset:"ascii" nullable:"true" list:"user" update:"user" create:"optional"`
+	// IPv6结束地址
+	GuestIp6End string `width:"64" charset:"ascii" nullable:"true" list:"user" update:"user" create:"optional"`
+	// IPv6前缀长度
+	GuestIp6Mask int8 `nullable:"true" list:"user" update:"user" create:"optional"`
+	// IPv6网关地址
+	GuestGateway6 string `width:"64" charset:"ascii" nullable:"true" list:"user" update:"user" create:"optional"`
+	// IPv6 DNS, allow multiple dns, seperated by ","
+	GuestDns6 string `width:"256" charset:"ascii" nullable:"true" list:"user" update:"user" create:"optional"`
 
 	GuestDomain6 string `width:"128" charset:"ascii" nullable:"true"`
 
@@ -1044,6 +1049,9 @@ func isValidNetworkInfo(ctx context.Context, userCred mcclient.TokenCredential,
 		if netConfig.BwLimit > api.MAX_BANDWIDTH {
 			return httperrors.NewInputParameterError("Bandwidth limit cannot exceed %dMbps", api.MAX_BANDWIDTH)
 		}
+		if netConfig.Driver == api.NETWORK_DRIVER_VFIO && net.isOneCloudVpcNetwork() {
+			return httperrors.NewInputParameterError("SR-IOV virtual function is not supported on vpc network %s", net.Name)
+		}
 		if net.ServerType == api.NETWORK_TYPE_BAREMETAL {
 			// not check baremetal network free address here
 			// TODO: find better solution ?
@@ -1707,6 +1715,12 @@ func (manager *SNetworkManager) ValidateCreateData(ctx context.Context, userCred
 		}
 	}
 
+	isOneCloud := region.Provider == api.CLOUD_PROVIDER_ONECLOUD
+	err = manager.validateCreateIPv6(&input, isOneCloud && vpc.Id != api.DEFAULT_VPC_ID, isOneCloud && vpc.Id == api.DEFAULT_VPC_ID)
+	if err != nil {
+		return input, err
+	}
+
 	input.GuestIpStart = ipStart.String()
 	input.GuestIpEnd = ipEnd.String()
 	input.SharableVirtualResourceCreateInput, err = manager.SSharableVirtualResourceBaseManager.ValidateCreateData(ctx, userCred, ownerId, query, input.SharableVirtualResourceCreateInput)
@@ -1831,6 +1845,11 @@ func (self *SNetwork) validateUpdateData(ctx context.Context, userCred mcclient.
 		}
 	}
 
+	err = self.validateUpdateIPv6(&input)
+	if err != nil {
+		return input, err
+	}
+
 	if input.IsAutoAlloc != nil && *input.IsAutoAlloc {
 		if self.ServerType != api.NETWORK_TYPE_GUEST {
 			return input, httperrors.NewInputParameterError("network server_type %s not support auto alloc", self.ServerType)
@@ -2042,6 +2061,15 @@ func (self *SNetwork) isOneCloudVpcNetwork() bool {
 	return IsOneCloudVpcResource(self)
 }
 
+func (self *SNetwork) isOneCloudClassicNetwork() bool {
+	vpc, _ := self.GetVpc()
+	if vpc == nil || vpc.Id != api.DEFAULT_VPC_ID {
+		return false
+	}
+	region, _ := self.GetRegion()
+	return region != nil && region.Provider == api.CLOUD_PROVIDER_ONECLOUD
+}
+
 func parseIpToIntArray(ip string) ([]int, error) {
 	ipSp := strings.Split(strings.Trim(ip, "."), ".")
 	if len(ipSp) > 4 {
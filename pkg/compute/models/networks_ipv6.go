@@ -0,0 +1,303 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/regutils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
+	"yunion.io/x/onecloud/pkg/compute/options"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/netutils2"
+)
+
+func isValidMaskLen6(maskLen int8) bool {
+	return maskLen >= 48 && maskLen <= 126
+}
+
+// IsSupportIPv6 returns whether an IPv6 address range is configured on the network
+func (self *SNetwork) IsSupportIPv6() bool {
+	return len(self.GuestIp6Start) > 0 && len(self.GuestIp6End) > 0 && self.GuestIp6Mask > 0
+}
+
+func (self *SNetwork) getIPv6Range() (netutils2.SIPv6AddrRange, error) {
+	start, err := netutils2.NewIPv6Addr(self.GuestIp6Start)
+	if err != nil {
+		return netutils2.SIPv6AddrRange{}, errors.Wrap(err, "guest_ip6_start")
+	}
+	end, err := netutils2.NewIPv6Addr(self.GuestIp6End)
+	if err != nil {
+		return netutils2.SIPv6AddrRange{}, errors.Wrap(err, "guest_ip6_end")
+	}
+	return netutils2.NewIPv6AddrRange(start, end), nil
+}
+
+func (self *SNetwork) GetUsedAddresses6() map[string]bool {
+	used := make(map[string]bool)
+	q := GuestnetworkManager.Query("ip6_addr").Equals("network_id", self.Id).IsNotEmpty("ip6_addr")
+	results, err := q.AllStringMap()
+	if err != nil {
+		log.Errorf("GetUsedAddresses6 fail %s", err)
+		return used
+	}
+	for _, result := range results {
+		used[result["ip6_addr"]] = true
+	}
+	if len(self.GuestGateway6) > 0 {
+		used[self.GuestGateway6] = true
+	}
+	return used
+}
+
+func (self *SNetwork) getFreeIP6(addrTable map[string]bool, candidate string, allocDir api.IPAllocationDirection) (string, error) {
+	iprange, err := self.getIPv6Range()
+	if err != nil {
+		return "", errors.Wrapf(err, "network %s ipv6 range", self.Name)
+	}
+	if len(candidate) > 0 {
+		candIP, err := netutils2.NewIPv6Addr(candidate)
+		if err != nil {
+			return "", httperrors.NewInputParameterError("invalid ipv6 address %s", candidate)
+		}
+		if !iprange.Contains(candIP) {
+			return "", httperrors.NewInputParameterError("candidate %s out of range", candidate)
+		}
+		// normalize textual form before looking up
+		if _, ok := addrTable[candIP.String()]; !ok {
+			return candIP.String(), nil
+		}
+	}
+	if len(self.AllocPolicy) > 0 && api.IPAllocationDirection(self.AllocPolicy) != api.IPAllocationNone {
+		allocDir = api.IPAllocationDirection(self.AllocPolicy)
+	}
+	if len(allocDir) == 0 {
+		allocDir = api.IPAllocationDirection(options.Options.DefaultIPAllocationDirection)
+	}
+	if allocDir == api.IPAllocationStepdown {
+		ip := iprange.EndIp()
+		for iprange.Contains(ip) {
+			if !addrTable[ip.String()] {
+				return ip.String(), nil
+			}
+			ip = ip.StepDown()
+		}
+	} else {
+		if allocDir == api.IPAllocationRadnom {
+			const MAX_TRIES = 5
+			for i := 0; i < MAX_TRIES; i += 1 {
+				ip := iprange.Random()
+				if !addrTable[ip.String()] {
+					return ip.String(), nil
+				}
+			}
+			// failed, fallback to IPAllocationStepup
+		}
+		ip := iprange.StartIp()
+		for iprange.Contains(ip) {
+			if !addrTable[ip.String()] {
+				return ip.String(), nil
+			}
+			ip = ip.StepUp()
+		}
+	}
+	return "", httperrors.NewInsufficientResourceError("Out of IPv6 address")
+}
+
+func (self *SNetwork) GetFreeIP6(ctx context.Context, userCred mcclient.TokenCredential, addrTable map[string]bool, candidate string, allocDir api.IPAllocationDirection) (string, error) {
+	if !self.IsSupportIPv6() {
+		return "", httperrors.NewNotSupportedError("network %s does not support ipv6", self.Name)
+	}
+	if addrTable == nil {
+		addrTable = self.GetUsedAddresses6()
+	}
+	return self.getFreeIP6(addrTable, candidate, allocDir)
+}
+
+func (self *SNetwork) GetFreeIP6WithLock(ctx context.Context, userCred mcclient.TokenCredential, addrTable map[string]bool, candidate string, allocDir api.IPAllocationDirection) (string, error) {
+	lockman.LockObject(ctx, self)
+	defer lockman.ReleaseObject(ctx, self)
+
+	return self.GetFreeIP6(ctx, userCred, addrTable, candidate, allocDir)
+}
+
+// width of guest_dns6 column
+const guestDns6MaxLength = 256
+
+func validateGuestDns6(dns string) error {
+	if len(dns) == 0 {
+		return nil
+	}
+	if len(dns) > guestDns6MaxLength {
+		return httperrors.NewInputParameterError("guest_dns6: too long, at most %d characters", guestDns6MaxLength)
+	}
+	for _, ipstr := range strings.Split(dns, ",") {
+		if !regutils.MatchIP6Addr(ipstr) {
+			return httperrors.NewInputParameterError("guest_dns6: Invalid IPv6 address %s", ipstr)
+		}
+	}
+	return nil
+}
+
+// validateIPv6Range checks the ipv6 range and gateway, and returns them in
+// canonical form. For onecloud vpc networks the first address of the prefix
+// is reserved as gateway
+func validateIPv6Range(startStr, endStr string, masklen int8, gatewayStr string, reserveGateway bool) (string, string, string, error) {
+	if !isValidMaskLen6(masklen) {
+		return "", "", "", httperrors.NewInputParameterError("Invalid ipv6 masklen %d", masklen)
+	}
+	start, err := netutils2.NewIPv6Addr(startStr)
+	if err != nil {
+		return "", "", "", httperrors.NewInputParameterError("Invalid ipv6 start ip: %s", startStr)
+	}
+	end, err := netutils2.NewIPv6Addr(endStr)
+	if err != nil {
+		return "", "", "", httperrors.NewInputParameterError("Invalid ipv6 end ip: %s", endStr)
+	}
+	if start.Cmp(end) > 0 {
+		start, end = end, start
+	}
+	netAddr := start.NetAddr(masklen)
+	if end.NetAddr(masklen).Cmp(netAddr) != 0 {
+		return "", "", "", httperrors.NewInputParameterError("ipv6 start and end ip not in the same subnet")
+	}
+	if len(gatewayStr) == 0 && reserveGateway {
+		gatewayStr = netAddr.StepUp().String()
+	}
+	if len(gatewayStr) > 0 {
+		gateway, err := netutils2.NewIPv6Addr(gatewayStr)
+		if err != nil {
+			return "", "", "", httperrors.NewInputParameterError("bad ipv6 gateway ip: %s", gatewayStr)
+		}
+		if gateway.NetAddr(masklen).Cmp(netAddr) != 0 {
+			return "", "", "", httperrors.NewInputParameterError("ipv6 gateway ip must be in the same subnet as start, end ip")
+		}
+		if reserveGateway && netutils2.NewIPv6AddrRange(start, end).Contains(gateway) {
+			if gateway.Cmp(start) == 0 {
+				start = start.StepUp()
+			} else {
+				return "", "", "", httperrors.NewInputParameterError("ipv6 gateway ip must not be in the range of start, end ip")
+			}
+		}
+		gatewayStr = gateway.String()
+	}
+	if start.Cmp(end) > 0 {
+		return "", "", "", httperrors.NewInputParameterError("empty ipv6 address range")
+	}
+	return start.String(), end.String(), gatewayStr, nil
+}
+
+// validateClassicIPv6 rejects ipv6 on classic onecloud networks unless enabled
+// explicitly.  Vpc networks get ipv6 ports and acls from vpcagent, while
+// openflow rules of classic ovs bridges are programmed by sdnagent, which is
+// out of this tree and may not filter ipv6 traffic of guests
+func validateClassicIPv6(isClassic bool) error {
+	if isClassic && !options.Options.EnableClassicNetworkIPv6 {
+		return httperrors.NewNotSupportedError("ipv6 on classic network requires sdnagent support, set enable_classic_network_ipv6 to allow it")
+	}
+	return nil
+}
+
+func (manager *SNetworkManager) validateCreateIPv6(input *api.NetworkCreateInput, reserveGateway, isClassic bool) error {
+	if len(input.GuestIp6Prefix) > 0 {
+		_, ipnet, err := net.ParseCIDR(input.GuestIp6Prefix)
+		if err != nil || ipnet.IP.To4() != nil {
+			return httperrors.NewInputParameterError("invalid guest_ip6_prefix %s", input.GuestIp6Prefix)
+		}
+		ones, _ := ipnet.Mask.Size()
+		input.GuestIp6Mask = int8(ones)
+		start, _ := netutils2.NewIPv6Addr(ipnet.IP.String())
+		last := make(net.IP, net.IPv6len)
+		for i := range last {
+			last[i] = ipnet.IP[i] | ^ipnet.Mask[i]
+		}
+		end, _ := netutils2.NewIPv6Addr(last.String())
+		// skip the subnet-router anycast address and the last address
+		input.GuestIp6Start = start.StepUp().String()
+		input.GuestIp6End = end.StepDown().String()
+		input.GuestIp6Prefix = ipnet.String()
+	}
+	if len(input.GuestIp6Start) == 0 && len(input.GuestIp6End) == 0 {
+		if len(input.GuestGateway6) > 0 || len(input.GuestDns6) > 0 {
+			return httperrors.NewInputParameterError("guest_ip6_start and guest_ip6_end required for ipv6 settings")
+		}
+		return nil
+	}
+	err := validateClassicIPv6(isClassic)
+	if err != nil {
+		return err
+	}
+	input.GuestIp6Start, input.GuestIp6End, input.GuestGateway6, err = validateIPv6Range(input.GuestIp6Start, input.GuestIp6End, input.GuestIp6Mask, input.GuestGateway6, reserveGateway)
+	if err != nil {
+		return err
+	}
+	return validateGuestDns6(input.GuestDns6)
+}
+
+func (self *SNetwork) validateUpdateIPv6(input *api.NetworkUpdateInput) error {
+	if len(input.GuestIp6Start) == 0 && len(input.GuestIp6End) == 0 && input.GuestIp6Mask == nil && len(input.GuestGateway6) == 0 {
+		return validateGuestDns6(input.GuestDns6)
+	}
+	err := validateClassicIPv6(self.isOneCloudClassicNetwork())
+	if err != nil {
+		return err
+	}
+	var (
+		start   = self.GuestIp6Start
+		end     = self.GuestIp6End
+		masklen = self.GuestIp6Mask
+		gateway = self.GuestGateway6
+	)
+	if len(input.GuestIp6Start) > 0 {
+		start = input.GuestIp6Start
+	}
+	if len(input.GuestIp6End) > 0 {
+		end = input.GuestIp6End
+	}
+	if input.GuestIp6Mask != nil {
+		masklen = *input.GuestIp6Mask
+	}
+	if len(input.GuestGateway6) > 0 {
+		gateway = input.GuestGateway6
+	}
+	start, end, gateway, err = validateIPv6Range(start, end, masklen, gateway, self.isOneCloudVpcNetwork())
+	if err != nil {
+		return err
+	}
+	startIp, _ := netutils2.NewIPv6Addr(start)
+	endIp, _ := netutils2.NewIPv6Addr(end)
+	netRange := netutils2.NewIPv6AddrRange(startIp, endIp)
+	for usedIpStr := range self.GetUsedAddresses6() {
+		if usedIpStr == self.GuestGateway6 {
+			continue
+		}
+		usedIp, err := netutils2.NewIPv6Addr(usedIpStr)
+		if err == nil && !netRange.Contains(usedIp) {
+			return httperrors.NewInputParameterError("IPv6 address %s been assigned out of new range", usedIpStr)
+		}
+	}
+	input.GuestIp6Start = start
+	input.GuestIp6End = end
+	input.GuestIp6Mask = &masklen
+	input.GuestGateway6 = gateway
+	return validateGuestDns6(input.GuestDns6)
+}
@@ -0,0 +1,344 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
+	"yunion.io/x/sqlchemy"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/validators"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/stringutils2"
+)
+
+type SSecgroupFlowLogManager struct {
+	db.SVirtualResourceBaseManager
+	db.SEnabledResourceBaseManager
+	SVpcResourceBaseManager
+}
+
+var SecgroupFlowLogManager *SSecgroupFlowLogManager
+
+func init() {
+	SecgroupFlowLogManager = &SSecgroupFlowLogManager{
+		SVirtualResourceBaseManager: db.NewVirtualResourceBaseManager(
+			SSecgroupFlowLog{},
+			"secgroupflowlogs_tbl",
+			"secgroupflowlog",
+			"secgroupflowlogs",
+		),
+	}
+	SecgroupFlowLogManager.SetVirtualObject(SecgroupFlowLogManager)
+}
+
+// 安全组流日志，按VPC或IP子网采样记录安全组规则命中的报文
+//
+// 目前仅支持OVN实现的VPC网络
+type SSecgroupFlowLog struct {
+	db.SVirtualResourceBase
+	db.SEnabledResourceBase `nullable:"false" default:"true" create:"optional" list:"user"`
+	SVpcResourceBase
+	SNetworkResourceBase
+
+	// 记录的流量类型
+	TrafficType string `width:"8" charset:"ascii" nullable:"false" default:"all" create:"optional" list:"user" update:"user"`
+	// 每台宿主机每秒最多记录的报文数
+	SampleRate int `nullable:"false" default:"100" create:"optional" list:"user" update:"user"`
+
+	// 流日志投递目标
+	Sink string `width:"16" charset:"ascii" nullable:"false" default:"logger" create:"optional" list:"user" update:"user"`
+	// 投递目标为bucket时的存储桶ID
+	BucketId string `width:"36" charset:"ascii" nullable:"true" create:"optional" list:"user" update:"user"`
+	// 存储桶内对象的前缀
+	Prefix string `width:"128" charset:"utf8" nullable:"true" create:"optional" list:"user" update:"user"`
+}
+
+func (manager *SSecgroupFlowLogManager) validateVpc(vpc *SVpc) error {
+	if vpc.Id == api.DEFAULT_VPC_ID || vpc.IsManaged() {
+		return httperrors.NewNotSupportedError("flow log is only supported for onecloud vpc, got %s(%s)", vpc.Name, vpc.Id)
+	}
+	return nil
+}
+
+func (manager *SSecgroupFlowLogManager) validateSampleRate(rate int) error {
+	if rate < 1 || rate > api.SECGROUP_FLOW_LOG_SAMPLE_RATE_MAX {
+		return httperrors.NewOutOfRangeError("sample_rate must be in range 1 ~ %d", api.SECGROUP_FLOW_LOG_SAMPLE_RATE_MAX)
+	}
+	return nil
+}
+
+func (manager *SSecgroupFlowLogManager) validateSink(userCred mcclient.TokenCredential, sink string, bucketId *string) error {
+	if !utils.IsInStringArray(sink, api.SECGROUP_FLOW_LOG_SINKS) {
+		return httperrors.NewInputParameterError("invalid sink %q", sink)
+	}
+	switch sink {
+	case api.SECGROUP_FLOW_LOG_SINK_BUCKET:
+		if len(*bucketId) == 0 {
+			return httperrors.NewMissingParameterError("bucket_id")
+		}
+		if _, err := validators.ValidateModel(userCred, BucketManager, bucketId); err != nil {
+			return err
+		}
+	default:
+		*bucketId = ""
+	}
+	return nil
+}
+
+func (manager *SSecgroupFlowLogManager) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, input api.SecgroupFlowLogCreateInput) (api.SecgroupFlowLogCreateInput, error) {
+	var vpc *SVpc
+	if len(input.NetworkId) > 0 {
+		network, netInput, err := ValidateNetworkResourceInput(userCred, input.NetworkResourceInput)
+		if err != nil {
+			return input, err
+		}
+		input.NetworkResourceInput = netInput
+		vpc, err = network.GetVpc()
+		if err != nil {
+			return input, httperrors.NewGeneralError(errors.Wrapf(err, "get vpc of network %s", network.Id))
+		}
+		if len(input.VpcId) > 0 && input.VpcId != vpc.Id && input.VpcId != vpc.Name {
+			return input, httperrors.NewInputParameterError("network %s does not belong to vpc %s", network.Name, input.VpcId)
+		}
+	} else {
+		if len(input.VpcId) == 0 {
+			return input, httperrors.NewMissingParameterError("vpc_id")
+		}
+		vpcObj, err := validators.ValidateModel(userCred, VpcManager, &input.VpcId)
+		if err != nil {
+			return input, err
+		}
+		vpc = vpcObj.(*SVpc)
+	}
+	if err := manager.validateVpc(vpc); err != nil {
+		return input, err
+	}
+	input.VpcId = vpc.Id
+
+	if len(input.TrafficType) == 0 {
+		input.TrafficType = api.SECGROUP_FLOW_LOG_TRAFFIC_ALL
+	}
+	if !utils.IsInStringArray(input.TrafficType, api.SECGROUP_FLOW_LOG_TRAFFIC_TYPES) {
+		return input, httperrors.NewInputParameterError("invalid traffic_type %q", input.TrafficType)
+	}
+	if input.SampleRate == 0 {
+		input.SampleRate = api.SECGROUP_FLOW_LOG_SAMPLE_RATE_DEFAULT
+	}
+	if err := manager.validateSampleRate(input.SampleRate); err != nil {
+		return input, err
+	}
+	if len(input.Sink) == 0 {
+		input.Sink = api.SECGROUP_FLOW_LOG_SINK_LOGGER
+	}
+	if err := manager.validateSink(userCred, input.Sink, &input.BucketId); err != nil {
+		return input, err
+	}
+	input.Prefix = strings.Trim(input.Prefix, "/")
+
+	var err error
+	input.VirtualResourceCreateInput, err = manager.SVirtualResourceBaseManager.ValidateCreateData(ctx, userCred, ownerId, query, input.VirtualResourceCreateInput)
+	if err != nil {
+		return input, err
+	}
+	return input, nil
+}
+
+func (flowLog *SSecgroupFlowLog) ValidateUpdateData(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.SecgroupFlowLogUpdateInput) (api.SecgroupFlowLogUpdateInput, error) {
+	man := SecgroupFlowLogManager
+	if len(input.TrafficType) > 0 && !utils.IsInStringArray(input.TrafficType, api.SECGROUP_FLOW_LOG_TRAFFIC_TYPES) {
+		return input, httperrors.NewInputParameterError("invalid traffic_type %q", input.TrafficType)
+	}
+	if input.SampleRate != nil {
+		if err := man.validateSampleRate(*input.SampleRate); err != nil {
+			return input, err
+		}
+	}
+	if len(input.Sink) > 0 || len(input.BucketId) > 0 {
+		if len(input.Sink) == 0 {
+			input.Sink = flowLog.Sink
+		}
+		if err := man.validateSink(userCred, input.Sink, &input.BucketId); err != nil {
+			return input, err
+		}
+	}
+	if input.Prefix != nil {
+		prefix := strings.Trim(*input.Prefix, "/")
+		input.Prefix = &prefix
+	}
+
+	var err error
+	input.VirtualResourceBaseUpdateInput, err = flowLog.SVirtualResourceBase.ValidateUpdateData(ctx, userCred, query, input.VirtualResourceBaseUpdateInput)
+	if err != nil {
+		return input, errors.Wrap(err, "SVirtualResourceBase.ValidateUpdateData")
+	}
+	return input, nil
+}
+
+func (flowLog *SSecgroupFlowLog) PerformEnable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformEnableInput) (jsonutils.JSONObject, error) {
+	err := db.EnabledPerformEnable(flowLog, ctx, userCred, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "db.EnabledPerformEnable")
+	}
+	return nil, nil
+}
+
+func (flowLog *SSecgroupFlowLog) PerformDisable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformDisableInput) (jsonutils.JSONObject, error) {
+	err := db.EnabledPerformEnable(flowLog, ctx, userCred, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "db.EnabledPerformEnable")
+	}
+	return nil, nil
+}
+
+// 安全组流日志列表
+func (manager *SSecgroupFlowLogManager) ListItemFilter(
+	ctx context.Context,
+	q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	query api.SecgroupFlowLogListInput,
+) (*sqlchemy.SQuery, error) {
+	var err error
+	q, err = manager.SVirtualResourceBaseManager.ListItemFilter(ctx, q, userCred, query.VirtualResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SVirtualResourceBaseManager.ListItemFilter")
+	}
+	q, err = manager.SEnabledResourceBaseManager.ListItemFilter(ctx, q, userCred, query.EnabledResourceBaseListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SEnabledResourceBaseManager.ListItemFilter")
+	}
+	q, err = manager.SVpcResourceBaseManager.ListItemFilter(ctx, q, userCred, query.VpcFilterListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SVpcResourceBaseManager.ListItemFilter")
+	}
+	if len(query.NetworkId) > 0 {
+		network, _, err := ValidateNetworkResourceInput(userCred, query.NetworkResourceInput)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Equals("network_id", network.Id)
+	}
+	if len(query.TrafficType) > 0 {
+		q = q.In("traffic_type", query.TrafficType)
+	}
+	if len(query.Sink) > 0 {
+		q = q.In("sink", query.Sink)
+	}
+	return q, nil
+}
+
+func (manager *SSecgroupFlowLogManager) OrderByExtraFields(
+	ctx context.Context,
+	q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	query api.SecgroupFlowLogListInput,
+) (*sqlchemy.SQuery, error) {
+	var err error
+	q, err = manager.SVirtualResourceBaseManager.OrderByExtraFields(ctx, q, userCred, query.VirtualResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SVirtualResourceBaseManager.OrderByExtraFields")
+	}
+	q, err = manager.SVpcResourceBaseManager.OrderByExtraFields(ctx, q, userCred, query.VpcFilterListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SVpcResourceBaseManager.OrderByExtraFields")
+	}
+	return q, nil
+}
+
+func (manager *SSecgroupFlowLogManager) QueryDistinctExtraField(q *sqlchemy.SQuery, field string) (*sqlchemy.SQuery, error) {
+	var err error
+	q, err = manager.SVirtualResourceBaseManager.QueryDistinctExtraField(q, field)
+	if err == nil {
+		return q, nil
+	}
+	q, err = manager.SVpcResourceBaseManager.QueryDistinctExtraField(q, field)
+	if err == nil {
+		return q, nil
+	}
+	return q, httperrors.ErrNotFound
+}
+
+func (manager *SSecgroupFlowLogManager) ListItemExportKeys(ctx context.Context,
+	q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	keys stringutils2.SSortedStrings,
+) (*sqlchemy.SQuery, error) {
+	var err error
+
+	q, err = manager.SVirtualResourceBaseManager.ListItemExportKeys(ctx, q, userCred, keys)
+	if err != nil {
+		return nil, errors.Wrap(err, "SVirtualResourceBaseManager.ListItemExportKeys")
+	}
+
+	if keys.ContainsAny(manager.SVpcResourceBaseManager.GetExportKeys()...) {
+		q, err = manager.SVpcResourceBaseManager.ListItemExportKeys(ctx, q, userCred, keys)
+		if err != nil {
+			return nil, errors.Wrap(err, "SVpcResourceBaseManager.ListItemExportKeys")
+		}
+	}
+
+	return q, nil
+}
+
+func (manager *SSecgroupFlowLogManager) FetchCustomizeColumns(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	objs []interface{},
+	fields stringutils2.SSortedStrings,
+	isList bool,
+) []api.SecgroupFlowLogDetails {
+	rows := make([]api.SecgroupFlowLogDetails, len(objs))
+	virtRows := manager.SVirtualResourceBaseManager.FetchCustomizeColumns(ctx, userCred, query, objs, fields, isList)
+	vpcRows := manager.SVpcResourceBaseManager.FetchCustomizeColumns(ctx, userCred, query, objs, fields, isList)
+	netIds := make([]string, len(objs))
+	bucketIds := make([]string, len(objs))
+	for i := range rows {
+		rows[i] = api.SecgroupFlowLogDetails{
+			VirtualResourceDetails: virtRows[i],
+		}
+		if vpcRows != nil {
+			rows[i].VpcResourceInfo = vpcRows[i]
+		}
+		flowLog := objs[i].(*SSecgroupFlowLog)
+		netIds[i] = flowLog.NetworkId
+		bucketIds[i] = flowLog.BucketId
+	}
+
+	netMaps, err := db.FetchIdNameMap2(NetworkManager, netIds)
+	if err != nil {
+		log.Errorf("db.FetchIdNameMap2 networks fail: %v", err)
+		return rows
+	}
+	bucketMaps, err := db.FetchIdNameMap2(BucketManager, bucketIds)
+	if err != nil {
+		log.Errorf("db.FetchIdNameMap2 buckets fail: %v", err)
+		return rows
+	}
+	for i := range rows {
+		rows[i].Network, _ = netMaps[netIds[i]]
+		rows[i].Bucket, _ = bucketMaps[bucketIds[i]]
+	}
+	return rows
+}
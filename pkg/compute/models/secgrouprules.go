@@ -168,6 +168,7 @@ func (manager *SSecurityGroupRuleManager) FetchCustomizeColumns(
 	secRows := manager.SSecurityGroupResourceBaseManager.FetchCustomizeColumns(ctx, userCred, query, objs, fields, isList)
 	secIds := make([]string, len(objs))
 	peerIds := make([]string, len(objs))
+	ruleIds := make([]string, len(objs))
 	for i := range rows {
 		rows[i] = api.SecgroupRuleDetails{
 			ResourceBaseDetails:       bRows[i],
@@ -176,10 +177,22 @@ func (manager *SSecurityGroupRuleManager) FetchCustomizeColumns(
 		rule := objs[i].(*SSecurityGroupRule)
 		secIds[i] = rule.SecgroupId
 		peerIds[i] = rule.PeerSecgroupId
+		ruleIds[i] = rule.Id
+	}
+
+	hits, err := SecurityGroupRuleStatManager.fetchRuleHits(ruleIds)
+	if err != nil {
+		log.Errorf("fetchRuleHits fail: %v", err)
+	}
+	for i := range rows {
+		if hit, ok := hits[ruleIds[i]]; ok {
+			rows[i].HitPackets = hit.Packets
+			rows[i].HitBytes = hit.Bytes
+		}
 	}
 
 	secgroups := make(map[string]SSecurityGroup)
-	err := db.FetchStandaloneObjectsByIds(SecurityGroupManager, secIds, &secgroups)
+	err = db.FetchStandaloneObjectsByIds(SecurityGroupManager, secIds, &secgroups)
 	if err != nil {
 		log.Errorf("FetchStandaloneObjectsByIds fail: %v", err)
 		return rows
@@ -359,13 +372,18 @@ func (self *SSecurityGroupRule) toRule() (*secrules.SecurityRule, error) {
 		Protocol:    self.Protocol,
 		Description: self.Description,
 	}
-	if regutils.MatchCIDR(self.CIDR) {
-		_, rule.IPNet, _ = net.ParseCIDR(self.CIDR)
-	} else if regutils.MatchIPAddr(self.CIDR) {
+	if _, ipnet, err := net.ParseCIDR(self.CIDR); err == nil {
+		rule.IPNet = ipnet
+	} else if regutils.MatchIP4Addr(self.CIDR) {
 		rule.IPNet = &net.IPNet{
 			IP:   net.ParseIP(self.CIDR),
 			Mask: net.CIDRMask(32, 32),
 		}
+	} else if regutils.MatchIP6Addr(self.CIDR) {
+		rule.IPNet = &net.IPNet{
+			IP:   net.ParseIP(self.CIDR),
+			Mask: net.CIDRMask(128, 128),
+		}
 	} else {
 		rule.IPNet = &net.IPNet{
 			IP:   net.IPv4zero,
@@ -405,6 +423,9 @@ func (self *SSecurityGroupRule) PreDelete(ctx context.Context, userCred mcclient
 		logclient.AddSimpleActionLog(secgroup, logclient.ACT_DELETE, jsonutils.Marshal(self), userCred, true)
 		secgroup.DoSync(ctx, userCred)
 	}
+	if err := SecurityGroupRuleStatManager.removeRuleStats(ctx, userCred, self.Id); err != nil {
+		log.Errorf("remove stats of secgrouprule %s: %v", self.Id, err)
+	}
 }
 
 func (self *SSecurityGroupRule) PostUpdate(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) {
@@ -0,0 +1,161 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"fmt"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+// +onecloud:swagger-gen-ignore
+type SSecurityGroupRuleStatManager struct {
+	db.SResourceBaseManager
+}
+
+var SecurityGroupRuleStatManager *SSecurityGroupRuleStatManager
+
+func init() {
+	SecurityGroupRuleStatManager = &SSecurityGroupRuleStatManager{
+		SResourceBaseManager: db.NewResourceBaseManager(
+			SSecurityGroupRuleStat{},
+			"secgrouprule_stats_tbl",
+			"secgrouprule_stat",
+			"secgrouprule_stats",
+		),
+	}
+	SecurityGroupRuleStatManager.SetVirtualObject(SecurityGroupRuleStatManager)
+}
+
+// 安全组规则在各宿主机上的命中计数，计数为宿主机上流表的累计值，流表重建后会清零
+type SSecurityGroupRuleStat struct {
+	db.SResourceBase
+
+	RuleId string `width:"128" charset:"ascii" nullable:"false" primary:"true"`
+	HostId string `width:"36" charset:"ascii" nullable:"false" primary:"true"`
+
+	Packets int64 `nullable:"false" default:"0"`
+	Bytes   int64 `nullable:"false" default:"0"`
+}
+
+func (self *SSecurityGroupRuleStat) GetId() string {
+	return fmt.Sprintf("%s/%s", self.RuleId, self.HostId)
+}
+
+func (self *SSecurityGroupRuleStat) GetName() string {
+	return self.RuleId
+}
+
+type sSecurityGroupRuleHits struct {
+	RuleId  string
+	Packets int64
+	Bytes   int64
+}
+
+// fetchRuleHits returns the counters of rules summed over all hosts
+func (manager *SSecurityGroupRuleStatManager) fetchRuleHits(ruleIds []string) (map[string]sSecurityGroupRuleHits, error) {
+	stats := manager.Query().SubQuery()
+	q := stats.Query(
+		stats.Field("rule_id"),
+		sqlchemy.SUM("packets", stats.Field("packets")),
+		sqlchemy.SUM("bytes", stats.Field("bytes")),
+	).In("rule_id", ruleIds).GroupBy(stats.Field("rule_id"))
+	hits := []sSecurityGroupRuleHits{}
+	if err := q.All(&hits); err != nil {
+		return nil, errors.Wrap(err, "query rule hits")
+	}
+	ret := make(map[string]sSecurityGroupRuleHits, len(hits))
+	for i := range hits {
+		ret[hits[i].RuleId] = hits[i]
+	}
+	return ret, nil
+}
+
+func (manager *SSecurityGroupRuleStatManager) removeRuleStats(ctx context.Context, userCred mcclient.TokenCredential, ruleId string) error {
+	q := manager.Query().Equals("rule_id", ruleId)
+	stats := []SSecurityGroupRuleStat{}
+	if err := db.FetchModelObjects(manager, q, &stats); err != nil {
+		return errors.Wrap(err, "db.FetchModelObjects")
+	}
+	for i := range stats {
+		if err := stats[i].Delete(ctx, userCred); err != nil {
+			return errors.Wrapf(err, "delete stat of rule %s on host %s", stats[i].RuleId, stats[i].HostId)
+		}
+	}
+	return nil
+}
+
+// setHostStats replaces all counters reported by the host
+func (manager *SSecurityGroupRuleStatManager) setHostStats(ctx context.Context, userCred mcclient.TokenCredential, hostId string, input []api.SecgroupRuleStat) error {
+	q := manager.Query().Equals("host_id", hostId)
+	olds := []SSecurityGroupRuleStat{}
+	if err := db.FetchModelObjects(manager, q, &olds); err != nil {
+		return errors.Wrap(err, "db.FetchModelObjects")
+	}
+	reported := map[string]struct{}{}
+	for i := range input {
+		in := &input[i]
+		if _, ok := reported[in.RuleId]; ok {
+			continue
+		}
+		reported[in.RuleId] = struct{}{}
+		stat := &SSecurityGroupRuleStat{
+			RuleId:  in.RuleId,
+			HostId:  hostId,
+			Packets: in.Packets,
+			Bytes:   in.Bytes,
+		}
+		stat.SetModelManager(manager, stat)
+		if err := manager.TableSpec().InsertOrUpdate(ctx, stat); err != nil {
+			return errors.Wrapf(err, "InsertOrUpdate stat of rule %s", in.RuleId)
+		}
+	}
+	for i := range olds {
+		if _, ok := reported[olds[i].RuleId]; ok {
+			continue
+		}
+		if err := olds[i].Delete(ctx, userCred); err != nil {
+			return errors.Wrapf(err, "delete stat of rule %s", olds[i].RuleId)
+		}
+	}
+	return nil
+}
+
+// 上报宿主机上安全组规则的命中计数，仅供宿主机使用
+func (manager *SSecurityGroupRuleManager) PerformReportStats(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.SecgroupRuleReportStatsInput) (jsonutils.JSONObject, error) {
+	if !userCred.HasSystemAdminPrivilege() {
+		return nil, httperrors.NewForbiddenError("not enough privilege")
+	}
+	if len(input.HostId) == 0 {
+		return nil, httperrors.NewMissingParameterError("host_id")
+	}
+	host, err := HostManager.FetchById(input.HostId)
+	if err != nil {
+		return nil, httperrors.NewResourceNotFoundError2(HostManager.Keyword(), input.HostId)
+	}
+	err = SecurityGroupRuleStatManager.setHostStats(ctx, userCred, host.GetId(), input.Stats)
+	if err != nil {
+		return nil, httperrors.NewGeneralError(err)
+	}
+	return nil, nil
+}
@@ -128,6 +128,15 @@ type ComputeOptions struct {
 	HostOfflineMaxSeconds        int `help:"Maximal seconds interval that a host considered offline during which it did not ping region, default is 3 minues" default:"180"`
 	HostOfflineDetectionInterval int `help:"Interval to check offline hosts, defualt is half a minute" default:"30"`
 
+	DnsZoneDirtySyncIntervalSeconds int `help:"Interval to sync dns zones changed by dynamic updates to cloud, default is 1 minute" default:"60"`
+
+	AcmeDirectoryUrl              string `help:"Directory url of ACME CA issuing loadbalancer certificates" default:"https://acme-v02.api.letsencrypt.org/directory"`
+	AcmeEmail                     string `help:"Contact email of the ACME account"`
+	AcmeInsecure                  bool   `help:"Skip verifying server certificate of ACME CA, only for test servers like pebble" default:"false"`
+	AcmeChallengeWaitSeconds      int    `help:"Seconds to wait for challenge responses to propagate before validation, default is 30 seconds" default:"30"`
+	AcmeRenewBeforeDays           int    `help:"Renew ACME certificates expiring in these days, default is 30 days" default:"30"`
+	AcmeRenewCheckIntervalMinutes int    `help:"Interval to check ACME certificates to renew, default is 1 hour" default:"60"`
+
 	MinimalIpAddrReusedIntervalSeconds int `help:"Minimal seconds when a release IP address can be reallocate" default:"30"`
 
 	CloudSyncWorkerCount         int `help:"how many current synchronization threads" default:"5"`
@@ -172,6 +181,8 @@ type ComputeOptions struct {
 
 	DefaultVpcExternalAccessMode string `help:"default external access mode for on-premise vpc"`
 
+	EnableClassicNetworkIPv6 bool `help:"allow ipv6 on classic onecloud networks, ipv6 anti-spoofing and security group flows of classic ovs bridges are programmed by sdnagent, enable only when sdnagent supports them" default:"false"`
+
 	NoCheckOsTypeForCachedImage bool `help:"Don't check os type for cached image"`
 
 	ProhibitRefreshingCloudImage bool `help:"Prohibit refreshing cloud image"`
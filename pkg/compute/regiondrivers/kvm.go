@@ -322,6 +322,9 @@ func (self *SKVMRegionDriver) ValidateCreateLoadbalancerListenerRuleData(ctx con
 		"redirect_host":   redirectHostV.AllowEmpty(true).Optional(true),
 		"redirect_path":   redirectPathV.AllowEmpty(true).Optional(true),
 	}
+	actionVs := newKvmListenerRuleActionValidators()
+	actionVs.setDefaults(nil)
+	actionVs.addTo(keyV)
 
 	if err := RunValidators(keyV, data, false); err != nil {
 		return nil, err
@@ -333,6 +336,18 @@ func (self *SKVMRegionDriver) ValidateCreateLoadbalancerListenerRuleData(ctx con
 		return nil, httperrors.NewInputParameterError("listener type must be http/https, got %s", listenerType)
 	}
 
+	condition, err := kvmValidateListenerRuleConditions(data)
+	if err != nil {
+		return nil, err
+	}
+	hasBackendGroups, err := kvmValidateListenerRuleBackendGroups(ctx, userCred, data, listener)
+	if err != nil {
+		return nil, err
+	}
+	if err := kvmValidateEnvoyListenerRuleData(listener, data, nil); err != nil {
+		return nil, err
+	}
+
 	redirectType := redirectV.Value
 	if redirectType != api.LB_REDIRECT_OFF {
 		if redirectType == api.LB_REDIRECT_RAW {
@@ -344,10 +359,8 @@ func (self *SKVMRegionDriver) ValidateCreateLoadbalancerListenerRuleData(ctx con
 	}
 
 	{
-		if redirectV.Value == api.LB_REDIRECT_OFF {
-			if backendGroup == nil {
-				return nil, httperrors.NewInputParameterError("backend_group argument is missing")
-			}
+		if err := actionVs.validate(pathV.Value, redirectV.Value, backendGroup != nil || hasBackendGroups); err != nil {
+			return nil, err
 		}
 		if lbbg, ok := backendGroup.(*models.SLoadbalancerBackendGroup); ok && lbbg.LoadbalancerId != listener.LoadbalancerId {
 			return nil, httperrors.NewInputParameterError("backend group %s(%s) belongs to loadbalancer %s instead of %s",
@@ -355,7 +368,7 @@ func (self *SKVMRegionDriver) ValidateCreateLoadbalancerListenerRuleData(ctx con
 		}
 	}
 
-	err := models.LoadbalancerListenerRuleCheckUniqueness(ctx, listener, domainV.Value, pathV.Value)
+	err = models.LoadbalancerListenerRuleCheckConditionUniqueness(ctx, listener, domainV.Value, pathV.Value, condition)
 	if err != nil {
 		return nil, err
 	}
@@ -407,6 +420,9 @@ func (self *SKVMRegionDriver) ValidateUpdateLoadbalancerListenerRuleData(ctx con
 		"redirect_host":   redirectHostV.AllowEmpty(true),
 		"redirect_path":   redirectPathV.AllowEmpty(true),
 	}
+	actionVs := newKvmListenerRuleActionValidators()
+	actionVs.setDefaults(lbr)
+	actionVs.addTo(keyV)
 	for _, v := range keyV {
 		v.Optional(true)
 		if err := v.Validate(data); err != nil {
@@ -430,8 +446,30 @@ func (self *SKVMRegionDriver) ValidateUpdateLoadbalancerListenerRuleData(ctx con
 		}
 	}
 
-	if redirectType == api.LB_REDIRECT_OFF && backendGroup == nil {
-		return nil, httperrors.NewInputParameterError("non redirect lblistener rule must have backend_group set")
+	hasBackendGroups := lbr.BackendGroups != nil && !lbr.BackendGroups.IsZero()
+	if data.Contains("backend_groups") {
+		lblis, err := lbr.GetLoadbalancerListener()
+		if err != nil {
+			return nil, httperrors.NewInputParameterError("loadbalancerlistenerrule %s(%s): fetching listener %s failed",
+				lbr.Name, lbr.Id, lbr.ListenerId)
+		}
+		hasBackendGroups, err = kvmValidateListenerRuleBackendGroups(ctx, userCred, data, lblis)
+		if err != nil {
+			return nil, err
+		}
+	}
+	{
+		lblis, err := lbr.GetLoadbalancerListener()
+		if err != nil {
+			return nil, httperrors.NewInputParameterError("loadbalancerlistenerrule %s(%s): fetching listener %s failed",
+				lbr.Name, lbr.Id, lbr.ListenerId)
+		}
+		if err := kvmValidateEnvoyListenerRuleData(lblis, data, lbr); err != nil {
+			return nil, err
+		}
+	}
+	if err := actionVs.validate(lbr.Path, redirectType, backendGroup != nil || hasBackendGroups); err != nil {
+		return nil, err
 	}
 	if backendGroup, ok := backendGroup.(*models.SLoadbalancerBackendGroup); ok && backendGroup.Id != lbr.BackendGroupId {
 		listenerM, err := models.LoadbalancerListenerManager.FetchById(lbr.ListenerId)
@@ -490,6 +528,7 @@ func (self *SKVMRegionDriver) ValidateCreateLoadbalancerListenerData(ctx context
 
 		"x_forwarded_for": validators.NewBoolValidator("x_forwarded_for").Default(true),
 		"gzip":            validators.NewBoolValidator("gzip").Default(false),
+		"backend_http2":   validators.NewBoolValidator("backend_http2").Default(false),
 
 		"http_request_rate":         validators.NewNonNegativeValidator("http_request_rate").Default(0),
 		"http_request_rate_per_src": validators.NewNonNegativeValidator("http_request_rate_per_src").Default(0),
@@ -499,6 +538,9 @@ func (self *SKVMRegionDriver) ValidateCreateLoadbalancerListenerData(ctx context
 		"redirect_scheme": redirectSchemeV.Optional(true),
 		"redirect_host":   redirectHostV.AllowEmpty(true).Optional(true),
 		"redirect_path":   redirectPathV.AllowEmpty(true).Optional(true),
+
+		"access_log":             validators.NewBoolValidator("access_log").Default(false),
+		"access_log_sample_rate": validators.NewRangeValidator("access_log_sample_rate", api.LB_ACCESS_LOG_SAMPLE_RATE_MIN, api.LB_ACCESS_LOG_SAMPLE_RATE_MAX).Default(api.LB_ACCESS_LOG_SAMPLE_RATE_MAX),
 	}
 
 	if err := RunValidators(keyV, data, false); err != nil {
@@ -532,17 +574,43 @@ func (self *SKVMRegionDriver) ValidateCreateLoadbalancerListenerData(ctx context
 
 	// https additional certificate check
 	if listenerType == api.LB_LISTENER_TYPE_HTTPS {
-		certV := validators.NewModelIdOrNameValidator("certificate", "loadbalancercertificate", ownerId)
+		var (
+			certV         = validators.NewModelIdOrNameValidator("certificate", "loadbalancercertificate", ownerId)
+			clientVerifyV = validators.NewStringChoicesValidator("client_verify", api.LB_CLIENT_VERIFY_CHOICES)
+			clientCaV     = validators.NewModelIdOrNameValidator("client_ca_certificate", "loadbalancercertificate", ownerId)
+			backendCaV    = validators.NewModelIdOrNameValidator("backend_ca_certificate", "loadbalancercertificate", ownerId)
+		)
 		tlsCipherPolicyV := validators.NewStringChoicesValidator("tls_cipher_policy", api.LB_TLS_CIPHER_POLICIES).Default(api.LB_TLS_CIPHER_POLICY_1_2)
 		httpsV := map[string]validators.IValidator{
 			"certificate":       certV,
 			"tls_cipher_policy": tlsCipherPolicyV,
 			"enable_http2":      validators.NewBoolValidator("enable_http2").Default(true),
+
+			"tls_min_version": validators.NewStringChoicesValidator("tls_min_version", api.LB_TLS_VERSIONS).Optional(true),
+			"tls_ciphers":     validators.NewRegexpValidator("tls_ciphers", lbTlsCiphersReg).Optional(true),
+
+			"client_verify":         clientVerifyV.Default(api.LB_CLIENT_VERIFY_OFF),
+			"client_ca_certificate": clientCaV.Optional(true),
+			"client_cert_headers":   validators.NewBoolValidator("client_cert_headers").Default(false),
+
+			"backend_ssl":            validators.NewBoolValidator("backend_ssl").Default(false),
+			"backend_ca_certificate": backendCaV.Optional(true),
 		}
 
 		if err := RunValidators(httpsV, data, false); err != nil {
 			return nil, err
 		}
+		if lbcert, ok := certV.Model.(*models.SLoadbalancerCertificate); ok && !lbcert.IsComplete() {
+			return nil, httperrors.NewInvalidStatusError("certificate %s is not issued yet", lbcert.Name)
+		}
+		if err := self.validateLoadbalancerListenerTls(data, certV.Model, clientCaV.Model, backendCaV.Model); err != nil {
+			return nil, err
+		}
+		if clientVerifyV.Value != api.LB_CLIENT_VERIFY_OFF && clientCaV.Model == nil {
+			return nil, httperrors.NewMissingParameterError("client_ca_certificate")
+		}
+	} else if clientVerify, _ := data.GetString("client_verify"); clientVerify != "" && clientVerify != api.LB_CLIENT_VERIFY_OFF {
+		return nil, httperrors.NewInputParameterError("client certificate verification can only be enabled for https listener")
 	}
 
 	// health check default depends on input parameters
@@ -570,6 +638,9 @@ func (self *SKVMRegionDriver) ValidateCreateLoadbalancerListenerData(ctx context
 		return nil, err
 	}
 
+	if err := kvmValidateEnvoyListenerData(lb, data, nil); err != nil {
+		return nil, err
+	}
 	data.Set("manager_id", jsonutils.NewString(lb.GetCloudproviderId()))
 	data.Set("cloudregion_id", jsonutils.NewString(lb.GetRegionId()))
 	return data, nil
@@ -616,6 +687,11 @@ func (self *SKVMRegionDriver) ValidateUpdateLoadbalancerListenerData(ctx context
 
 	certV := validators.NewModelIdOrNameValidator("certificate", "loadbalancercertificate", ownerId)
 	tlsCipherPolicyV := validators.NewStringChoicesValidator("tls_cipher_policy", api.LB_TLS_CIPHER_POLICIES).Default(api.LB_TLS_CIPHER_POLICY_1_2)
+	var (
+		clientVerifyV = validators.NewStringChoicesValidator("client_verify", api.LB_CLIENT_VERIFY_CHOICES)
+		clientCaV     = validators.NewModelIdOrNameValidator("client_ca_certificate", "loadbalancercertificate", ownerId)
+		backendCaV    = validators.NewModelIdOrNameValidator("backend_ca_certificate", "loadbalancercertificate", ownerId)
+	)
 	keyV := map[string]validators.IValidator{
 		"send_proxy": validators.NewStringChoicesValidator("send_proxy", api.LB_SENDPROXY_CHOICES),
 
@@ -650,6 +726,7 @@ func (self *SKVMRegionDriver) ValidateUpdateLoadbalancerListenerData(ctx context
 
 		"x_forwarded_for": validators.NewBoolValidator("x_forwarded_for"),
 		"gzip":            validators.NewBoolValidator("gzip"),
+		"backend_http2":   validators.NewBoolValidator("backend_http2"),
 
 		"http_request_rate":         validators.NewNonNegativeValidator("http_request_rate"),
 		"http_request_rate_per_src": validators.NewNonNegativeValidator("http_request_rate_per_src"),
@@ -658,21 +735,58 @@ func (self *SKVMRegionDriver) ValidateUpdateLoadbalancerListenerData(ctx context
 		"tls_cipher_policy": tlsCipherPolicyV,
 		"enable_http2":      validators.NewBoolValidator("enable_http2"),
 
+		"tls_min_version": validators.NewStringChoicesValidator("tls_min_version", api.LB_TLS_VERSIONS),
+		"tls_ciphers":     validators.NewRegexpValidator("tls_ciphers", lbTlsCiphersReg).AllowEmpty(true),
+
+		"client_verify":         clientVerifyV,
+		"client_ca_certificate": clientCaV,
+		"client_cert_headers":   validators.NewBoolValidator("client_cert_headers"),
+
+		"backend_ssl":            validators.NewBoolValidator("backend_ssl"),
+		"backend_ca_certificate": backendCaV,
+
 		"redirect":        redirectV,
 		"redirect_code":   redirectCodeV,
 		"redirect_scheme": redirectSchemeV,
 		"redirect_host":   redirectHostV.AllowEmpty(true),
 		"redirect_path":   redirectPathV.AllowEmpty(true),
+
+		"access_log":             validators.NewBoolValidator("access_log"),
+		"access_log_sample_rate": validators.NewRangeValidator("access_log_sample_rate", api.LB_ACCESS_LOG_SAMPLE_RATE_MIN, api.LB_ACCESS_LOG_SAMPLE_RATE_MAX),
 	}
 
 	if err := RunValidators(keyV, data, true); err != nil {
 		return nil, err
 	}
+	if lbcert, ok := certV.Model.(*models.SLoadbalancerCertificate); ok && !lbcert.IsComplete() {
+		return nil, httperrors.NewInvalidStatusError("certificate %s is not issued yet", lbcert.Name)
+	}
+	if err := self.validateLoadbalancerListenerTls(data, certV.Model, clientCaV.Model, backendCaV.Model); err != nil {
+		return nil, err
+	}
 
 	var (
 		redirectType = redirectV.Value
 		listenerType = lblis.ListenerType
 	)
+	{
+		clientVerify := lblis.ClientVerify
+		if data.Contains("client_verify") {
+			clientVerify = clientVerifyV.Value
+		}
+		clientCaId := lblis.ClientCaCertificateId
+		if data.Contains("client_ca_certificate_id") {
+			clientCaId, _ = data.GetString("client_ca_certificate_id")
+		}
+		if clientVerify != "" && clientVerify != api.LB_CLIENT_VERIFY_OFF {
+			if listenerType != api.LB_LISTENER_TYPE_HTTPS {
+				return nil, httperrors.NewInputParameterError("client certificate verification can only be enabled for https listener")
+			}
+			if clientCaId == "" {
+				return nil, httperrors.NewMissingParameterError("client_ca_certificate")
+			}
+		}
+	}
 	if redirectType != api.LB_REDIRECT_OFF {
 		if redirectType == api.LB_REDIRECT_RAW {
 			scheme, host, path := redirectSchemeV.Value, redirectHostV.Value, redirectPathV.Value
@@ -701,10 +815,39 @@ func (self *SKVMRegionDriver) ValidateUpdateLoadbalancerListenerData(ctx context
 				lbbg.Name, lbbg.Id, lbbg.LoadbalancerId, lblis.LoadbalancerId)
 		}
 	}
+	{
+		lb, err := lblis.GetLoadbalancer()
+		if err != nil {
+			return nil, httperrors.NewInputParameterError("listener %s(%s): fetching loadbalancer %s failed",
+				lblis.Name, lblis.Id, lblis.LoadbalancerId)
+		}
+		if err := kvmValidateEnvoyListenerData(lb, data, lblis); err != nil {
+			return nil, err
+		}
+	}
 
 	return data, nil
 }
 
+var lbTlsCiphersReg = regexp.MustCompile(`^[A-Za-z0-9:+!@=._-]+$`)
+
+// validateLoadbalancerListenerTls makes sure that server certificate and ca
+// bundles of https listener are not mixed up
+func (self *SKVMRegionDriver) validateLoadbalancerListenerTls(data *jsonutils.JSONDict, cert, clientCa, backendCa db.IModel) error {
+	if ciphers, _ := data.GetString("tls_ciphers"); len(ciphers) > 1024 {
+		return httperrors.NewInputParameterError("tls_ciphers too long")
+	}
+	if lbcert, ok := cert.(*models.SLoadbalancerCertificate); ok && lbcert.IsCA() {
+		return httperrors.NewInputParameterError("certificate %s is a ca certificate", lbcert.Name)
+	}
+	for _, m := range []db.IModel{clientCa, backendCa} {
+		if lbcert, ok := m.(*models.SLoadbalancerCertificate); ok && !lbcert.IsCA() {
+			return httperrors.NewInputParameterError("certificate %s is not a ca certificate", lbcert.Name)
+		}
+	}
+	return nil
+}
+
 func (self *SKVMRegionDriver) RequestCreateLoadbalancer(ctx context.Context, userCred mcclient.TokenCredential, lb *models.SLoadbalancer, task taskman.ITask) error {
 	taskman.LocalTaskRun(task, func() (jsonutils.JSONObject, error) {
 		_, err := db.Update(lb, func() error {
@@ -0,0 +1,74 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regiondrivers
+
+import (
+	"strings"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+// kvmValidateEnvoyListenerData rejects listener features which are not
+// implemented by envoy data plane, instead of dropping them silently.
+// lblis is nil on creation
+func kvmValidateEnvoyListenerData(lb *models.SLoadbalancer, data *jsonutils.JSONDict, lblis *models.SLoadbalancerListener) error {
+	if !lb.IsEnvoyDataPlane() {
+		return nil
+	}
+	listener := &models.SLoadbalancerListener{}
+	if lblis != nil {
+		if err := jsonutils.Marshal(lblis).Unmarshal(listener); err != nil {
+			return httperrors.NewInternalServerError("copy listener %s: %v", lblis.Id, err)
+		}
+	}
+	if err := data.Unmarshal(listener); err != nil {
+		return httperrors.NewInputParameterError("unmarshal listener data: %v", err)
+	}
+	if features := listener.GetEnvoyUnsupportedFeatures(); len(features) > 0 {
+		return httperrors.NewNotSupportedError("%s not supported by envoy data plane of loadbalancer %s(%s)",
+			strings.Join(features, ", "), lb.Name, lb.Id)
+	}
+	return nil
+}
+
+// kvmValidateEnvoyListenerRuleData is like kvmValidateEnvoyListenerData,
+// but for listener rules
+func kvmValidateEnvoyListenerRuleData(listener *models.SLoadbalancerListener, data *jsonutils.JSONDict, lbr *models.SLoadbalancerListenerRule) error {
+	lb, err := listener.GetLoadbalancer()
+	if err != nil {
+		return httperrors.NewInputParameterError("listener %s(%s): fetching loadbalancer %s failed",
+			listener.Name, listener.Id, listener.LoadbalancerId)
+	}
+	if !lb.IsEnvoyDataPlane() {
+		return nil
+	}
+	rule := &models.SLoadbalancerListenerRule{}
+	if lbr != nil {
+		if err := jsonutils.Marshal(lbr).Unmarshal(rule); err != nil {
+			return httperrors.NewInternalServerError("copy listener rule %s: %v", lbr.Id, err)
+		}
+	}
+	if err := data.Unmarshal(rule); err != nil {
+		return httperrors.NewInputParameterError("unmarshal listener rule data: %v", err)
+	}
+	if features := rule.GetEnvoyUnsupportedFeatures(); len(features) > 0 {
+		return httperrors.NewNotSupportedError("%s not supported by envoy data plane of loadbalancer %s(%s)",
+			strings.Join(features, ", "), lb.Name, lb.Id)
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regiondrivers
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/sqlchemy"
+	_ "yunion.io/x/sqlchemy/backends"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+)
+
+func setupEnvoyTestDB(t *testing.T) func() {
+	dbConn, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	// every connection of in-memory sqlite opens a database of its own
+	dbConn.SetMaxOpenConns(1)
+	sqlchemy.SetDBWithNameBackend(dbConn, sqlchemy.DefaultDB, sqlchemy.SQLiteBackend)
+	if models.LoadbalancerManager == nil {
+		db.InitAllManagers()
+	}
+	clusterTs := models.LoadbalancerClusterManager.TableSpec().GetTableSpec()
+	lbTs := models.LoadbalancerManager.TableSpec().GetTableSpec()
+	for _, ts := range []*sqlchemy.STableSpec{clusterTs, lbTs} {
+		if err := ts.Sync(); err != nil {
+			t.Fatalf("sync table %s: %v", ts.Name(), err)
+		}
+	}
+	for _, dataPlane := range []string{api.LB_DATA_PLANE_ENVOY, api.LB_DATA_PLANE_HAPROXY} {
+		cluster := &models.SLoadbalancerCluster{}
+		cluster.Id = "cluster-" + dataPlane
+		cluster.DataPlane = dataPlane
+		if err := clusterTs.Insert(cluster); err != nil {
+			t.Fatalf("insert cluster: %v", err)
+		}
+		lb := &models.SLoadbalancer{}
+		lb.Id = "lb-" + dataPlane
+		lb.ClusterId = cluster.Id
+		if err := lbTs.Insert(lb); err != nil {
+			t.Fatalf("insert loadbalancer: %v", err)
+		}
+	}
+	return func() {
+		sqlchemy.CloseDB()
+		dbConn.Close()
+	}
+}
+
+func fetchEnvoyTestLoadbalancer(t *testing.T, id string) *models.SLoadbalancer {
+	obj, err := models.LoadbalancerManager.FetchById(id)
+	if err != nil {
+		t.Fatalf("fetch loadbalancer %s: %v", id, err)
+	}
+	return obj.(*models.SLoadbalancer)
+}
+
+func checkEnvoyValidateError(t *testing.T, err error, rejected bool) {
+	if !rejected {
+		if err != nil {
+			t.Errorf("want allowed, got error: %v", err)
+		}
+		return
+	}
+	if err == nil {
+		t.Errorf("want rejected, got allowed")
+		return
+	}
+	jce, ok := err.(*httputils.JSONClientError)
+	if !ok {
+		t.Errorf("want JSONClientError, got %T: %v", err, err)
+		return
+	}
+	if jce.Code != 406 {
+		t.Errorf("want code 406, got %d: %v", jce.Code, err)
+	}
+}
+
+// table specs are bound to the database on first use, so all cases share
+// one in-memory database
+func TestKvmValidateEnvoyData(t *testing.T) {
+	defer setupEnvoyTestDB(t)()
+
+	t.Run("listener", testKvmValidateEnvoyListenerData)
+	t.Run("rule", testKvmValidateEnvoyListenerRuleData)
+}
+
+func testKvmValidateEnvoyListenerData(t *testing.T) {
+	stickyListener := &models.SLoadbalancerListener{}
+	stickyListener.StickySession = api.LB_BOOL_ON
+	accessLogListener := &models.SLoadbalancerListener{}
+	accessLogListener.AccessLog = true
+
+	cases := []struct {
+		name     string
+		lbId     string
+		lblis    *models.SLoadbalancerListener
+		data     jsonutils.JSONObject
+		rejected bool
+	}{
+		{
+			name: "baseline",
+			lbId: "lb-envoy",
+			data: jsonutils.NewDict(),
+		},
+		{
+			name:     "access_log",
+			lbId:     "lb-envoy",
+			data:     jsonutils.Marshal(map[string]interface{}{"access_log": true}),
+			rejected: true,
+		},
+		{
+			name:     "sticky_session",
+			lbId:     "lb-envoy",
+			data:     jsonutils.Marshal(map[string]interface{}{"sticky_session": api.LB_BOOL_ON}),
+			rejected: true,
+		},
+		{
+			name:     "http_request_rate",
+			lbId:     "lb-envoy",
+			data:     jsonutils.Marshal(map[string]interface{}{"http_request_rate": 10}),
+			rejected: true,
+		},
+		{
+			name:     "http_request_rate_per_src",
+			lbId:     "lb-envoy",
+			data:     jsonutils.Marshal(map[string]interface{}{"http_request_rate_per_src": 10}),
+			rejected: true,
+		},
+		{
+			name:  "update turning off sticky_session",
+			lbId:  "lb-envoy",
+			lblis: stickyListener,
+			data:  jsonutils.Marshal(map[string]interface{}{"sticky_session": api.LB_BOOL_OFF}),
+		},
+		{
+			name:     "update keeping access_log",
+			lbId:     "lb-envoy",
+			lblis:    accessLogListener,
+			data:     jsonutils.NewDict(),
+			rejected: true,
+		},
+		{
+			name: "haproxy",
+			lbId: "lb-haproxy",
+			data: jsonutils.Marshal(map[string]interface{}{
+				"access_log":     true,
+				"sticky_session": api.LB_BOOL_ON,
+			}),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			lb := fetchEnvoyTestLoadbalancer(t, c.lbId)
+			err := kvmValidateEnvoyListenerData(lb, c.data.(*jsonutils.JSONDict), c.lblis)
+			checkEnvoyValidateError(t, err, c.rejected)
+		})
+	}
+}
+
+func testKvmValidateEnvoyListenerRuleData(t *testing.T) {
+	sourceIpCond := jsonutils.Marshal([]api.LoadbalancerListenerRuleCondition{
+		{
+			Field: api.LB_RULE_CONDITION_SOURCE_IP,
+			SourceIpConfig: &api.LoadbalancerListenerRuleConditionValues{
+				Values: []string{"10.0.0.0/8"},
+			},
+		},
+	}).String()
+	rateRule := &models.SLoadbalancerListenerRule{}
+	rateRule.HTTPRequestRate = 10
+
+	cases := []struct {
+		name     string
+		lbId     string
+		lbr      *models.SLoadbalancerListenerRule
+		data     jsonutils.JSONObject
+		rejected bool
+	}{
+		{
+			name: "baseline",
+			lbId: "lb-envoy",
+			data: jsonutils.NewDict(),
+		},
+		{
+			name:     "http_request_rate",
+			lbId:     "lb-envoy",
+			data:     jsonutils.Marshal(map[string]interface{}{"http_request_rate": 10}),
+			rejected: true,
+		},
+		{
+			name:     "http_request_rate_per_src",
+			lbId:     "lb-envoy",
+			data:     jsonutils.Marshal(map[string]interface{}{"http_request_rate_per_src": 10}),
+			rejected: true,
+		},
+		{
+			name:     "source_ip condition",
+			lbId:     "lb-envoy",
+			data:     jsonutils.Marshal(map[string]interface{}{"condition": sourceIpCond}),
+			rejected: true,
+		},
+		{
+			name: "update clearing http_request_rate",
+			lbId: "lb-envoy",
+			lbr:  rateRule,
+			data: jsonutils.Marshal(map[string]interface{}{"http_request_rate": 0}),
+		},
+		{
+			name: "haproxy",
+			lbId: "lb-haproxy",
+			data: jsonutils.Marshal(map[string]interface{}{
+				"http_request_rate": 10,
+				"condition":         sourceIpCond,
+			}),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			listener := &models.SLoadbalancerListener{}
+			listener.LoadbalancerId = c.lbId
+			err := kvmValidateEnvoyListenerRuleData(listener, c.data.(*jsonutils.JSONDict), c.lbr)
+			checkEnvoyValidateError(t, err, c.rejected)
+		})
+	}
+}
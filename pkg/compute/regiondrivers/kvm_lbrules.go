@@ -0,0 +1,253 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regiondrivers
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"yunion.io/x/jsonutils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/validators"
+	"yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+// Listener rules of onecloud loadbalancers are rendered by lbagent into
+// haproxy acls and http-request actions.  Values are restricted here so
+// that they can be put into haproxy config without escaping
+var (
+	kvmLbRuleHeaderNameRegexp  = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	kvmLbRuleQueryKeyRegexp    = regexp.MustCompile(`^[A-Za-z0-9._~-]+$`)
+	kvmLbRuleMethodRegexp      = regexp.MustCompile(`^[A-Z_-]+$`)
+	kvmLbRuleHostRegexp        = regexp.MustCompile(`^[A-Za-z0-9.*?-]+$`)
+	kvmLbRuleValueRegexp       = regexp.MustCompile(`^[ !#-\[\]_-~]+$`) // printable ascii except " \ ^
+	kvmLbRuleRewritePathRegexp = regexp.MustCompile(`^/[A-Za-z0-9._~/-]*$`)
+)
+
+func kvmValidateListenerRuleConditionValues(field string, values []string, valueRegexp *regexp.Regexp) error {
+	if len(values) == 0 {
+		return httperrors.NewInputParameterError("%s condition: empty values", field)
+	}
+	for _, value := range values {
+		if !valueRegexp.MatchString(value) {
+			return httperrors.NewInputParameterError("%s condition: invalid value %q", field, value)
+		}
+	}
+	return nil
+}
+
+func kvmValidateListenerRuleCondition(cond *api.LoadbalancerListenerRuleCondition) error {
+	switch cond.Field {
+	case api.LB_RULE_CONDITION_HTTP_HEADER:
+		conf := cond.HttpHeaderConfig
+		if conf == nil || !kvmLbRuleHeaderNameRegexp.MatchString(conf.HttpHeaderName) {
+			return httperrors.NewInputParameterError("%s condition: invalid header name", cond.Field)
+		}
+		return kvmValidateListenerRuleConditionValues(cond.Field, conf.Values, kvmLbRuleValueRegexp)
+	case api.LB_RULE_CONDITION_PATH_PATTERN:
+		if cond.PathPatternConfig == nil {
+			return httperrors.NewInputParameterError("%s condition: missing config", cond.Field)
+		}
+		for _, value := range cond.PathPatternConfig.Values {
+			if !strings.HasPrefix(value, "/") {
+				return httperrors.NewInputParameterError("%s condition: path %q must start with /", cond.Field, value)
+			}
+		}
+		return kvmValidateListenerRuleConditionValues(cond.Field, cond.PathPatternConfig.Values, kvmLbRuleValueRegexp)
+	case api.LB_RULE_CONDITION_HTTP_REQUEST_METHOD:
+		if cond.HttpRequestMethodConfig == nil {
+			return httperrors.NewInputParameterError("%s condition: missing config", cond.Field)
+		}
+		return kvmValidateListenerRuleConditionValues(cond.Field, cond.HttpRequestMethodConfig.Values, kvmLbRuleMethodRegexp)
+	case api.LB_RULE_CONDITION_HOST_HEADER:
+		if cond.HostHeaderConfig == nil {
+			return httperrors.NewInputParameterError("%s condition: missing config", cond.Field)
+		}
+		return kvmValidateListenerRuleConditionValues(cond.Field, cond.HostHeaderConfig.Values, kvmLbRuleHostRegexp)
+	case api.LB_RULE_CONDITION_QUERY_STRING:
+		conf := cond.QueryStringConfig
+		if conf == nil || len(conf.Values) == 0 {
+			return httperrors.NewInputParameterError("%s condition: empty values", cond.Field)
+		}
+		for _, kv := range conf.Values {
+			if !kvmLbRuleQueryKeyRegexp.MatchString(kv.Key) {
+				return httperrors.NewInputParameterError("%s condition: invalid key %q", cond.Field, kv.Key)
+			}
+			if !kvmLbRuleValueRegexp.MatchString(kv.Value) {
+				return httperrors.NewInputParameterError("%s condition: invalid value %q", cond.Field, kv.Value)
+			}
+		}
+		return nil
+	case api.LB_RULE_CONDITION_SOURCE_IP:
+		if cond.SourceIpConfig == nil || len(cond.SourceIpConfig.Values) == 0 {
+			return httperrors.NewInputParameterError("%s condition: empty values", cond.Field)
+		}
+		for _, value := range cond.SourceIpConfig.Values {
+			if _, _, err := net.ParseCIDR(value); err == nil {
+				continue
+			}
+			if net.ParseIP(value) == nil {
+				return httperrors.NewInputParameterError("%s condition: invalid addr %q", cond.Field, value)
+			}
+		}
+		return nil
+	default:
+		return httperrors.NewInputParameterError("unsupported condition %q", cond.Field)
+	}
+}
+
+// kvmValidateListenerRuleConditions validates the condition field and
+// stores it in normalized form so that rules with the same conditions can
+// be told apart by plain string comparison
+func kvmValidateListenerRuleConditions(data *jsonutils.JSONDict) (string, error) {
+	condition, _ := data.GetString("condition")
+	if condition == "" {
+		return "", nil
+	}
+	if err := models.ValidateListenerRuleConditions(condition); err != nil {
+		return "", httperrors.NewInputParameterError("%v", err)
+	}
+	conds, err := api.ParseLoadbalancerListenerRuleConditions(condition)
+	if err != nil {
+		return "", httperrors.NewInputParameterError("invalid condition: %v", err)
+	}
+	for i := range conds {
+		if err := kvmValidateListenerRuleCondition(&conds[i]); err != nil {
+			return "", err
+		}
+	}
+	condition = jsonutils.Marshal(conds).String()
+	data.Set("condition", jsonutils.NewString(condition))
+	return condition, nil
+}
+
+// kvmValidateListenerRuleBackendGroups validates weighted backend groups
+// of the rule.  It returns true if there is at least one backend group set
+func kvmValidateListenerRuleBackendGroups(ctx context.Context, userCred mcclient.TokenCredential, data *jsonutils.JSONDict, listener *models.SLoadbalancerListener) (bool, error) {
+	if !data.Contains("backend_groups") {
+		return false, nil
+	}
+	lbrbgs := models.SLoadbalancerListenerRuleBackendGroups{}
+	if err := validators.NewStructValidator("backend_groups", &lbrbgs).Validate(data); err != nil {
+		return false, err
+	}
+	for _, lbrbg := range lbrbgs {
+		m, err := db.FetchByIdOrName(models.LoadbalancerBackendGroupManager, userCred, lbrbg.BackendGroupId)
+		if err != nil {
+			return false, httperrors.NewResourceNotFoundError2(models.LoadbalancerBackendGroupManager.Keyword(), lbrbg.BackendGroupId)
+		}
+		lbbg := m.(*models.SLoadbalancerBackendGroup)
+		if lbbg.LoadbalancerId != listener.LoadbalancerId {
+			return false, httperrors.NewInputParameterError("backend group %s(%s) belongs to loadbalancer %s instead of %s",
+				lbbg.Name, lbbg.Id, lbbg.LoadbalancerId, listener.LoadbalancerId)
+		}
+		lbrbg.BackendGroupId = lbbg.Id
+	}
+	// ids may have changed from names, validate again for duplicates
+	if err := lbrbgs.Validate(data); err != nil {
+		return false, err
+	}
+	data.Set("backend_groups", jsonutils.Marshal(&lbrbgs))
+	if len(lbrbgs) == 0 {
+		return false, nil
+	}
+	data.Set("backend_group_id", jsonutils.NewString(lbrbgs[0].BackendGroupId))
+	return true, nil
+}
+
+type sKvmListenerRuleActionValidators struct {
+	rewriteHostV *validators.ValidatorHostPort
+	rewritePathV *validators.ValidatorRegexp
+
+	fixedResponseCodeV        *validators.ValidatorRange
+	fixedResponseContentTypeV *validators.ValidatorStringChoices
+	fixedResponseBodyV        *validators.ValidatorStringLen
+}
+
+func newKvmListenerRuleActionValidators() *sKvmListenerRuleActionValidators {
+	return &sKvmListenerRuleActionValidators{
+		rewriteHostV: validators.NewHostPortValidator("rewrite_host").OptionalPort(true),
+		rewritePathV: validators.NewRegexpValidator("rewrite_path", kvmLbRuleRewritePathRegexp),
+
+		fixedResponseCodeV:        validators.NewRangeValidator("fixed_response_code", 0, api.LB_FIXED_RESPONSE_CODE_MAX),
+		fixedResponseContentTypeV: validators.NewStringChoicesValidator("fixed_response_content_type", api.LB_FIXED_RESPONSE_CONTENT_TYPES),
+		fixedResponseBodyV:        validators.NewMaxStringLenValidator("fixed_response_body", api.LB_FIXED_RESPONSE_BODY_MAX_LEN),
+	}
+}
+
+func (vs *sKvmListenerRuleActionValidators) addTo(keyV map[string]validators.IValidator) {
+	keyV["rewrite_host"] = vs.rewriteHostV.AllowEmpty(true)
+	keyV["rewrite_path"] = vs.rewritePathV.AllowEmpty(true)
+	keyV["fixed_response_code"] = vs.fixedResponseCodeV
+	keyV["fixed_response_content_type"] = vs.fixedResponseContentTypeV
+	keyV["fixed_response_body"] = vs.fixedResponseBodyV
+}
+
+func (vs *sKvmListenerRuleActionValidators) setDefaults(lbr *models.SLoadbalancerListenerRule) {
+	if lbr == nil {
+		vs.rewriteHostV.Default("")
+		vs.rewritePathV.Default("")
+		vs.fixedResponseCodeV.Default(0)
+		vs.fixedResponseContentTypeV.Default("text/plain")
+		vs.fixedResponseBodyV.Default("")
+		return
+	}
+	vs.rewriteHostV.Default(lbr.RewriteHost)
+	vs.rewritePathV.Default(lbr.RewritePath)
+	vs.fixedResponseCodeV.Default(int64(lbr.FixedResponseCode))
+	if lbr.FixedResponseContentType != "" {
+		vs.fixedResponseContentTypeV.Default(lbr.FixedResponseContentType)
+	} else {
+		vs.fixedResponseContentTypeV.Default("text/plain")
+	}
+	vs.fixedResponseBodyV.Default(lbr.FixedResponseBody)
+}
+
+func (vs *sKvmListenerRuleActionValidators) isFixedResponse() bool {
+	return vs.fixedResponseCodeV.Value > 0
+}
+
+// validate checks the actions of the rule are consistent with each other
+func (vs *sKvmListenerRuleActionValidators) validate(path, redirect string, hasBackendGroup bool) error {
+	if code := vs.fixedResponseCodeV.Value; code > 0 && code < api.LB_FIXED_RESPONSE_CODE_MIN {
+		return httperrors.NewInputParameterError("fixed_response_code must be 0 or in range [%d, %d]",
+			api.LB_FIXED_RESPONSE_CODE_MIN, api.LB_FIXED_RESPONSE_CODE_MAX)
+	}
+	for _, r := range vs.fixedResponseBodyV.Value {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return httperrors.NewInputParameterError("fixed_response_body contains non-printable char: %v", r)
+		}
+	}
+	if vs.isFixedResponse() {
+		if redirect != api.LB_REDIRECT_OFF {
+			return httperrors.NewInputParameterError("redirect and fixed response cannot be both enabled")
+		}
+		return nil
+	}
+	if redirect == api.LB_REDIRECT_OFF && !hasBackendGroup {
+		return httperrors.NewInputParameterError("backend_group argument is missing")
+	}
+	if vs.rewritePathV.Value != "" && path != "" && !kvmLbRuleRewritePathRegexp.MatchString(path) {
+		return httperrors.NewInputParameterError("path %q contains chars not allowed when rewriting path", path)
+	}
+	return nil
+}
@@ -81,6 +81,7 @@ func InitHandlers(app *appsrv.Application) {
 		models.InfrasPendingUsageManager,
 
 		models.CloudproviderCapabilityManager,
+		models.SecurityGroupRuleStatManager,
 
 		models.ScalingTimerManager,
 		models.ScalingAlarmManager,
@@ -88,6 +89,8 @@ func InitHandlers(app *appsrv.Application) {
 		models.ScalingGroupNetworkManager,
 
 		models.DnsRecordSetTrafficPolicyManager,
+		models.DnsZoneJournalManager,
+		models.LoadbalancerAcmeAccountManager,
 		models.CloudimageManager,
 
 		models.WafRuleStatementManager,
@@ -125,6 +128,7 @@ func InitHandlers(app *appsrv.Application) {
 		models.SecurityGroupManager,
 		models.SecurityGroupCacheManager,
 		models.SecurityGroupRuleManager,
+		models.SecgroupFlowLogManager,
 		// models.VCenterManager,
 		models.DnsRecordManager,
 		models.ElasticipManager,
@@ -196,6 +200,7 @@ func InitHandlers(app *appsrv.Application) {
 		models.DnsZoneCacheManager,
 		models.DnsRecordSetManager,
 		models.DnsTrafficPolicyManager,
+		models.DnsTsigKeyManager,
 
 		models.VpcPeeringConnectionManager,
 		models.InterVpcNetworkManager,
@@ -144,7 +144,9 @@ func StartService() {
 		cron.AddJobAtIntervals("CleanExpiredPostpaidServers", time.Duration(opts.PrepaidExpireCheckSeconds)*time.Second, models.GuestManager.DeleteExpiredPostpaidServers)
 		cron.AddJobAtIntervals("CleanExpiredPostpaidNatGateways", time.Duration(opts.PrepaidExpireCheckSeconds)*time.Second, models.NatGatewayManager.DeleteExpiredPostpaids)
 		cron.AddJobAtIntervals("CleanExpiredPostpaidNas", time.Duration(opts.PrepaidExpireCheckSeconds)*time.Second, models.FileSystemManager.DeleteExpiredPostpaids)
+		cron.AddJobAtIntervals("SyncDirtyDnsZones", time.Duration(opts.DnsZoneDirtySyncIntervalSeconds)*time.Second, models.DnsZoneManager.SyncDirtyDnsZones)
 		cron.AddJobAtIntervals("StartHostPingDetectionTask", time.Duration(opts.HostOfflineDetectionInterval)*time.Second, models.HostManager.PingDetectionTask)
+		taskman.AddTaskCronJobs(cron, &opts.DBOptions)
 
 		cron.AddJobAtIntervalsWithStartRun("CalculateQuotaUsages", time.Duration(opts.CalculateQuotaUsageIntervalSeconds)*time.Second, models.QuotaManager.CalculateQuotaUsages, true)
 		cron.AddJobAtIntervalsWithStartRun("CalculateRegionQuotaUsages", time.Duration(opts.CalculateQuotaUsageIntervalSeconds)*time.Second, models.RegionQuotaManager.CalculateQuotaUsages, true)
@@ -181,6 +183,8 @@ func StartService() {
 		cron.AddJobEveryFewHour("InspectAllTemplate", 1, 0, 0, models.GuestTemplateManager.InspectAllTemplate, true)
 
 		cron.AddJobEveryFewHour("CheckBillingResourceExpireAt", 1, 0, 0, models.CheckBillingResourceExpireAt, true)
+
+		cron.AddJobAtIntervalsWithStartRun("AutoRenewAcmeCertificates", time.Duration(opts.AcmeRenewCheckIntervalMinutes)*time.Minute, models.LoadbalancerCertificateManager.AutoRenewAcmeCertificates, true)
 		go cron.Start2(ctx, electObj)
 
 		// init auto scaling controller
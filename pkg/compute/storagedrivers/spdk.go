@@ -0,0 +1,72 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storagedrivers
+
+import (
+	"context"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+type SSpdkStorageDriver struct {
+	SBaseStorageDriver
+}
+
+func init() {
+	driver := SSpdkStorageDriver{}
+	models.RegisterStorageDriver(&driver)
+}
+
+func (self *SSpdkStorageDriver) GetStorageType() string {
+	return api.STORAGE_SPDK
+}
+
+func (self *SSpdkStorageDriver) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, input *api.StorageCreateInput) error {
+	input.SpdkLvstore = strings.TrimSpace(input.SpdkLvstore)
+	if len(input.SpdkLvstore) == 0 {
+		return httperrors.NewMissingParameterError("spdk_lvstore")
+	}
+	input.SpdkRpcSocket = strings.TrimSpace(input.SpdkRpcSocket)
+	if len(input.SpdkRpcSocket) == 0 {
+		input.SpdkRpcSocket = api.SPDK_DEFAULT_RPC_SOCKET
+	}
+	input.SpdkVhostDir = strings.TrimSpace(input.SpdkVhostDir)
+	if len(input.SpdkVhostDir) == 0 {
+		input.SpdkVhostDir = api.SPDK_DEFAULT_VHOST_DIR
+	}
+	for k, v := range map[string]string{"spdk_rpc_socket": input.SpdkRpcSocket, "spdk_vhost_dir": input.SpdkVhostDir} {
+		if !strings.HasPrefix(v, "/") {
+			return httperrors.NewInputParameterError("%s %s should be absolute path", k, v)
+		}
+	}
+	input.StorageConf = jsonutils.NewDict()
+	input.StorageConf.Update(
+		jsonutils.Marshal(map[string]interface{}{
+			"spdk_lvstore":    input.SpdkLvstore,
+			"spdk_rpc_socket": input.SpdkRpcSocket,
+			"spdk_vhost_dir":  input.SpdkVhostDir,
+		}))
+	return nil
+}
+
+func (self *SSpdkStorageDriver) ValidateCreateSnapshotData(ctx context.Context, userCred mcclient.TokenCredential, disk *models.SDisk, input *api.SnapshotCreateInput) error {
+	return httperrors.NewUnsupportOperationError("Not support create snapshot for %s storage", api.STORAGE_SPDK)
+}
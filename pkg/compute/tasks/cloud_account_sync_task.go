@@ -16,6 +16,7 @@ package tasks
 
 import (
 	"context"
+	"time"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
@@ -34,6 +35,12 @@ type CloudAccountSyncInfoTask struct {
 
 func init() {
 	taskman.RegisterTask(CloudAccountSyncInfoTask{})
+
+	// transient cloud api errors while syncing account info
+	taskman.RegisterStageRetry(CloudAccountSyncInfoTask{}, "OnInit", taskman.SStageRetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 30 * time.Second,
+	})
 }
 
 func (self *CloudAccountSyncInfoTask) OnInit(ctx context.Context, obj db.IStandaloneModel, body jsonutils.JSONObject) {
@@ -38,6 +38,12 @@ type GuestCreateTask struct {
 
 func init() {
 	taskman.RegisterTask(GuestCreateTask{})
+
+	// inserting iso and deploying are safe to redo when host agent is unreachable
+	taskman.RegisterStageRetry(GuestCreateTask{}, "OnDiskPrepared", taskman.SStageRetryPolicy{
+		MaxAttempts:  3,
+		ErrorClasses: []taskman.TRetryErrorClass{taskman.RetryErrorNetwork, taskman.RetryErrorServerError},
+	})
 }
 
 func (self *GuestCreateTask) OnInit(ctx context.Context, obj db.IStandaloneModel, body jsonutils.JSONObject) {
@@ -17,6 +17,7 @@ package tasks
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
@@ -162,4 +163,6 @@ func (self *GuestDeployTask) OnDeployGuestSyncstatusCompleteFailed(ctx context.C
 
 func init() {
 	taskman.RegisterTask(GuestDeployTask{})
+
+	taskman.RegisterStageTimeout(GuestDeployTask{}, "OnDeployGuestComplete", time.Hour)
 }
@@ -187,6 +187,9 @@ func (self *GuestMigrateTask) OnCachedCdromComplete(ctx context.Context, guest *
 	if !jsonutils.QueryBoolean(self.Params, "is_rescue_mode", false) && (guestStatus == api.VM_RUNNING || guestStatus == api.VM_SUSPEND) {
 		body.Set("live_migrate", jsonutils.JSONTrue)
 		body.Set("enable_tls", jsonutils.NewBool(jsonutils.QueryBoolean(self.GetParams(), "enable_tls", false)))
+		if migrateOpts, _ := self.Params.Get("migrate_options"); migrateOpts != nil {
+			body.Set("migrate_options", migrateOpts)
+		}
 	}
 
 	if !jsonutils.QueryBoolean(self.Params, "is_rescue_mode", false) {
@@ -235,6 +238,15 @@ func (self *GuestMigrateTask) OnSrcPrepareComplete(ctx context.Context, guest *m
 		}
 		body.Set("migrate_certs", certsObj)
 	}
+	if data != nil && data.Contains("migrate_options") {
+		// options resolved with source host defaults.  Save them so that
+		// both the source and the destination use the same options
+		migrateOpts, _ := data.Get("migrate_options")
+		body.Set("migrate_options", migrateOpts)
+		params := jsonutils.NewDict()
+		params.Set("migrate_options", migrateOpts)
+		self.SaveParams(params)
+	}
 	if err != nil {
 		self.TaskFailed(ctx, guest, jsonutils.NewString(err.Error()))
 		return
@@ -448,6 +460,9 @@ func (self *GuestLiveMigrateTask) OnStartDestComplete(ctx context.Context, guest
 	body.Set("live_migrate_dest_port", liveMigrateDestPort)
 	body.Set("dest_ip", jsonutils.NewString(targetHost.AccessIp))
 	body.Set("enable_tls", jsonutils.NewBool(jsonutils.QueryBoolean(self.GetParams(), "enable_tls", false)))
+	if migrateOpts, _ := self.Params.Get("migrate_options"); migrateOpts != nil {
+		body.Set("migrate_options", migrateOpts)
+	}
 
 	headers := self.GetTaskRequestHeader()
 
@@ -500,9 +515,23 @@ func (self *GuestMigrateTask) setGuest(ctx context.Context, guest *models.SGuest
 func (self *GuestLiveMigrateTask) OnLiveMigrateCompleteFailed(ctx context.Context, guest *models.SGuest, data jsonutils.JSONObject) {
 	targetHostId, _ := self.Params.GetString("target_host_id")
 	guest.StartUndeployGuestTask(ctx, self.UserCred, "", targetHostId)
+	if jsonutils.QueryBoolean(self.Params, api.LIVE_MIGRATE_CANCELLED_PARAM, false) {
+		self.taskCancelled(ctx, guest, data)
+		return
+	}
 	self.TaskFailed(ctx, guest, data)
 }
 
+// migration cancelled by user, guest keeps running on source host
+func (self *GuestLiveMigrateTask) taskCancelled(ctx context.Context, guest *models.SGuest, reason jsonutils.JSONObject) {
+	guestStatus, _ := self.Params.GetString("guest_status")
+	guest.SetStatus(self.UserCred, guestStatus, api.LIVE_MIGRATE_CANCELLED)
+	guest.ClearMigrateProgress()
+	db.OpsLog.LogEvent(guest, db.ACT_MIGRATE_FAIL, reason, self.UserCred)
+	logclient.AddActionLogWithContext(ctx, guest, logclient.ACT_MIGRATE, reason, self.UserCred, false)
+	self.SetStageFailed(ctx, reason)
+}
+
 func (self *GuestLiveMigrateTask) OnLiveMigrateComplete(ctx context.Context, guest *models.SGuest, data jsonutils.JSONObject) {
 	headers := self.GetTaskRequestHeader()
 	body := jsonutils.NewDict()
@@ -614,6 +643,7 @@ func (self *GuestMigrateTask) TaskComplete(ctx context.Context, guest *models.SG
 		self.TaskFailed(ctx, guest, jsonutils.NewString(err.Error()))
 		return
 	}
+	guest.ClearMigrateProgress()
 	self.SetStageComplete(ctx, nil)
 	db.OpsLog.LogEvent(guest, db.ACT_MIGRATE, "Migrate success", self.UserCred)
 	logclient.AddActionLogWithContext(ctx, guest, logclient.ACT_MIGRATE, self.Params, self.UserCred, true)
@@ -626,6 +656,7 @@ func (self *GuestMigrateTask) TaskFailed(ctx context.Context, guest *models.SGue
 
 func (self *GuestMigrateTask) markFailed(ctx context.Context, guest *models.SGuest, reason jsonutils.JSONObject) {
 	guest.SetStatus(self.UserCred, api.VM_MIGRATE_FAILED, reason.String())
+	guest.ClearMigrateProgress()
 	db.OpsLog.LogEvent(guest, db.ACT_MIGRATE_FAIL, reason, self.UserCred)
 	logclient.AddActionLogWithContext(ctx, guest, logclient.ACT_MIGRATE, reason, self.UserCred, false)
 	notifyclient.NotifySystemErrorWithCtx(ctx, guest.Id, guest.Name, api.VM_MIGRATE_FAILED, reason.String())
@@ -24,6 +24,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon"
 	app_common "yunion.io/x/onecloud/pkg/cloudcommon/app"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
 	"yunion.io/x/onecloud/pkg/devtool/models"
 	"yunion.io/x/onecloud/pkg/devtool/options"
@@ -55,6 +56,7 @@ func StartService() {
 	db.EnsureAppSyncDB(app, dbOpts, models.InitDB)
 
 	models.InitializeCronjobs()
+	taskman.AddTaskCronJobs(models.DevToolCronManager, dbOpts)
 
 	app_common.ServeForeverWithCleanup(app, baseOpts, func() {
 		cloudcommon.CloseDB()
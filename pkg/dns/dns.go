@@ -19,6 +19,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 	"time"
@@ -44,6 +45,7 @@ import (
 	_ "yunion.io/x/sqlchemy/backends"
 
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
 	"yunion.io/x/onecloud/pkg/compute/models"
 	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/mcclient/auth"
@@ -86,6 +88,11 @@ type SRegionDNS struct {
 	AdminPassword string
 	Region        string
 	K8sSkip       bool
+	// TransferAllow are networks allowed to transfer zones without TSIG
+	TransferAllow []*net.IPNet
+	// XfrListen is the address serving TSIG signed zone transfers and
+	// dynamic updates
+	XfrListen string
 
 	K8sManager            *k8s.SKubeClusterManager
 	primaryZoneLabelCount int
@@ -108,6 +115,7 @@ func (r *SRegionDNS) initDB(c *caddy.Controller) error {
 	sqlDb.SetMaxOpenConns(defaultDbMaxOpenConn)
 	sqlDb.SetMaxIdleConns(defaultDbMaxIdleConn)
 	sqlchemy.SetDB(sqlDb)
+	lockman.Init(lockman.NewInMemoryLockManager())
 	db.InitAllManagers()
 
 	c.OnShutdown(func() error {
@@ -139,6 +147,16 @@ func (r *SRegionDNS) ServeDNS(ctx context.Context, w dns.ResponseWriter, rmsg *d
 		err     error
 	)
 
+	if isZoneRequest(rmsg) {
+		// TSIG is not verified by coredns, only transfers from networks in
+		// transfer_allow are served here
+		return r.serveZoneRequest(ctx, w, rmsg, nil)
+	}
+
+	if handled, rcode, err := r.servePrivateZone(ctx, w, rmsg); handled {
+		return rcode, err
+	}
+
 	opt := plugin.Options{}
 	state := request.Request{W: w, Req: rmsg, Context: ctx}
 	zone := plugin.Zones(r.Zones).Matches(state.Name())
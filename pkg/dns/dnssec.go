@@ -0,0 +1,147 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"crypto"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/compute/models"
+)
+
+const (
+	errInvalidDnssecKey = errors.Error("invalid dnssec key")
+
+	// signatures are made valid since an hour ago to tolerate clock skew of
+	// resolvers
+	rrsigInception = time.Hour
+	rrsigValidity  = 7 * 24 * time.Hour
+)
+
+type sZoneSigner struct {
+	key    *dns.DNSKEY
+	signer crypto.Signer
+}
+
+func newZoneSigner(zone *models.SDnsZone) (*sZoneSigner, error) {
+	rr, err := dns.NewRR(zone.DnssecKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse dnskey")
+	}
+	key, ok := rr.(*dns.DNSKEY)
+	if !ok {
+		return nil, errors.Wrapf(errInvalidDnssecKey, "%s", zone.DnssecKey)
+	}
+	priv, err := key.NewPrivateKey(zone.DnssecPrivateKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse private key")
+	}
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, errors.Wrapf(errInvalidDnssecKey, "private key of %s is not a signer", zone.Name)
+	}
+	return &sZoneSigner{key: key, signer: signer}, nil
+}
+
+// splitRRsets groups records of the same owner and type, keeping their order
+func splitRRsets(rrs []dns.RR) [][]dns.RR {
+	rrsets := [][]dns.RR{}
+	index := map[string]int{}
+	for _, rr := range rrs {
+		hdr := rr.Header()
+		k := strings.ToLower(hdr.Name) + "/" + dns.TypeToString[hdr.Rrtype]
+		i, ok := index[k]
+		if !ok {
+			i = len(rrsets)
+			index[k] = i
+			rrsets = append(rrsets, nil)
+		}
+		rrsets[i] = append(rrsets[i], rr)
+	}
+	return rrsets
+}
+
+// sign returns rrs followed by signature of each rrset
+func (s *sZoneSigner) sign(rrs []dns.RR) ([]dns.RR, error) {
+	now := time.Now()
+	ret := make([]dns.RR, 0, len(rrs)*2)
+	for _, rrset := range splitRRsets(rrs) {
+		sig := &dns.RRSIG{
+			Hdr:        dns.RR_Header{Ttl: rrset[0].Header().Ttl},
+			Algorithm:  s.key.Algorithm,
+			KeyTag:     s.key.KeyTag(),
+			SignerName: s.key.Hdr.Name,
+			Inception:  uint32(now.Add(-rrsigInception).Unix()),
+			Expiration: uint32(now.Add(rrsigValidity).Unix()),
+		}
+		err := sig.Sign(s.signer, rrset)
+		if err != nil {
+			return nil, errors.Wrapf(err, "sign %s", rrset[0].Header().Name)
+		}
+		ret = append(ret, rrset...)
+		ret = append(ret, sig)
+	}
+	return ret, nil
+}
+
+// denialNSEC proves the absence of types at qname with the minimal covering
+// NSEC of signing on line, which needs no walk of the zone. Names not
+// existing are claimed to exist without any type, so that the reply turns
+// into NODATA.
+func denialNSEC(qname string, types []uint16, ttl uint32) *dns.NSEC {
+	bitmap := append([]uint16{dns.TypeRRSIG, dns.TypeNSEC}, types...)
+	sort.Slice(bitmap, func(i, j int) bool { return bitmap[i] < bitmap[j] })
+	return &dns.NSEC{
+		Hdr: dns.RR_Header{
+			Name:   qname,
+			Rrtype: dns.TypeNSEC,
+			Class:  dns.ClassINET,
+			Ttl:    ttl,
+		},
+		NextDomain: `\000.` + qname,
+		TypeBitMap: bitmap,
+	}
+}
+
+// signPrivateZoneReply signs answer and authority sections of reply from a
+// zone with dnssec enabled
+func signPrivateZoneReply(m *dns.Msg, zone *models.SDnsZone, qname string, types []uint16, exists bool) error {
+	signer, err := newZoneSigner(zone)
+	if err != nil {
+		return errors.Wrapf(err, "newZoneSigner")
+	}
+	if len(m.Answer) == 0 {
+		if !exists {
+			m.Rcode = dns.RcodeSuccess
+			types = nil
+		}
+		m.Ns = append(m.Ns, denialNSEC(qname, types, xfrMinTTL))
+	}
+	m.Answer, err = signer.sign(m.Answer)
+	if err != nil {
+		return errors.Wrapf(err, "sign answer")
+	}
+	m.Ns, err = signer.sign(m.Ns)
+	if err != nil {
+		return errors.Wrapf(err, "sign authority")
+	}
+	return nil
+}
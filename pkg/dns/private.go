@@ -0,0 +1,368 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+
+	ylog "yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudprovider"
+	"yunion.io/x/onecloud/pkg/compute/models"
+)
+
+const (
+	// maximal number of CNAME followed inside a private zone
+	maxCnameChain = 8
+)
+
+// zoneNameCandidates returns qname and all its parent domains in the form
+// zone names are stored
+func zoneNameCandidates(qname string) []string {
+	labels := dns.SplitDomainName(strings.ToLower(qname))
+	names := make([]string, 0, len(labels))
+	for i := range labels {
+		names = append(names, strings.Join(labels[i:], "."))
+	}
+	return names
+}
+
+// findPrivateZone returns the closest enclosing private zone of qname bound
+// to any of vpcs
+func findPrivateZone(vpcIds []string, qname string) (*models.SDnsZone, error) {
+	zones, err := models.DnsZoneManager.FetchPrivateZones(vpcIds, zoneNameCandidates(qname))
+	if err != nil {
+		return nil, errors.Wrapf(err, "FetchPrivateZones")
+	}
+	var zone *models.SDnsZone
+	for i := range zones {
+		if zone == nil || dns.CountLabel(zones[i].Name) > dns.CountLabel(zone.Name) {
+			zone = &zones[i]
+		} else if dns.CountLabel(zones[i].Name) == dns.CountLabel(zone.Name) {
+			// the source address is found in several vpcs bound to
+			// different zones of the same name
+			return nil, errors.Wrapf(sqlchemy.ErrDuplicateEntry, "zone %s", zone.Name)
+		}
+	}
+	if zone == nil {
+		return nil, sql.ErrNoRows
+	}
+	return zone, nil
+}
+
+func wildcardName(name string) string {
+	if name == "@" {
+		return ""
+	}
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) == 1 {
+		return "*"
+	}
+	return "*." + parts[1]
+}
+
+// selectByPolicy picks records to answer according to their traffic
+// policies. Records without policy are always answered, one of weighted
+// records is picked in proportion to its weight, and secondary records of
+// failover are answered only when no primary one is healthy.
+func selectByPolicy(records []models.SDnsRecordSet, policies map[string]models.SDnsTrafficPolicy, healthy func(*models.SDnsRecordSet) bool, intn func(int) int) []models.SDnsRecordSet {
+	var (
+		ret       = []models.SDnsRecordSet{}
+		weighted  = []models.SDnsRecordSet{}
+		weights   = []int{}
+		total     = 0
+		primary   = []models.SDnsRecordSet{}
+		secondary = []models.SDnsRecordSet{}
+	)
+	for i := range records {
+		policy, ok := policies[records[i].Id]
+		if !ok {
+			ret = append(ret, records[i])
+			continue
+		}
+		switch cloudprovider.TDnsPolicyType(policy.PolicyType) {
+		case cloudprovider.DnsPolicyTypeWeighted:
+			weight, _ := strconv.Atoi(policy.PolicyValue)
+			if weight < 0 {
+				weight = 0
+			}
+			weighted = append(weighted, records[i])
+			weights = append(weights, weight)
+			total += weight
+		case cloudprovider.DnsPolicyTypeFailover:
+			if cloudprovider.TDnsPolicyValue(policy.PolicyValue) == cloudprovider.DnsPolicyValueSecondary {
+				secondary = append(secondary, records[i])
+			} else {
+				primary = append(primary, records[i])
+			}
+		default:
+			ret = append(ret, records[i])
+		}
+	}
+	if len(weighted) > 0 {
+		if total == 0 {
+			// records of zero weight are picked evenly
+			ret = append(ret, weighted[intn(len(weighted))])
+		} else {
+			n := intn(total)
+			for i := range weighted {
+				if n < weights[i] {
+					ret = append(ret, weighted[i])
+					break
+				}
+				n -= weights[i]
+			}
+		}
+	}
+	if len(primary)+len(secondary) > 0 {
+		healthyPrimary := []models.SDnsRecordSet{}
+		for i := range primary {
+			if healthy(&primary[i]) {
+				healthyPrimary = append(healthyPrimary, primary[i])
+			}
+		}
+		switch {
+		case len(healthyPrimary) > 0:
+			ret = append(ret, healthyPrimary...)
+		case len(secondary) > 0:
+			ret = append(ret, secondary...)
+		default:
+			ret = append(ret, primary...)
+		}
+	}
+	return ret
+}
+
+// isRecordHealthy reports whether the address of record is being served,
+// records pointing to guests none of which is running are unhealthy
+func isRecordHealthy(rec *models.SDnsRecordSet, vpcIds []string) bool {
+	if rec.DnsType != "A" && rec.DnsType != "AAAA" {
+		return true
+	}
+	guests, err := models.GuestnetworkManager.GetGuestsByAddress(rec.DnsValue, vpcIds)
+	if err != nil {
+		ylog.Errorf("GetGuestsByAddress %s: %v", rec.DnsValue, err)
+		return true
+	}
+	if len(guests) == 0 {
+		return true
+	}
+	for i := range guests {
+		if guests[i].Status == api.VM_RUNNING {
+			return true
+		}
+	}
+	return false
+}
+
+func selectRecords(records []models.SDnsRecordSet, vpcIds []string) []models.SDnsRecordSet {
+	if len(records) <= 1 {
+		return records
+	}
+	recordIds := make([]string, len(records))
+	for i := range records {
+		recordIds[i] = records[i].Id
+	}
+	policies, err := models.DnsRecordSetManager.FetchDnsTrafficPolicies(recordIds, api.CLOUD_PROVIDER_ONECLOUD)
+	if err != nil {
+		ylog.Errorf("FetchDnsTrafficPolicies: %v", err)
+		return records
+	}
+	healthy := func(rec *models.SDnsRecordSet) bool {
+		return isRecordHealthy(rec, vpcIds)
+	}
+	return selectByPolicy(records, policies, healthy, rand.Intn)
+}
+
+type sPrivateZoneLookup struct {
+	zone   *models.SDnsZone
+	origin string
+	vpcIds []string
+}
+
+// lookupName returns records of qname in the zone, along with types present
+// at the name for denial of existence. exists is false when neither the
+// name nor a wildcard covering it is found.
+func (l *sPrivateZoneLookup) lookupName(qname string, qtype uint16) (rrs []dns.RR, types []uint16, exists bool, err error) {
+	name, err := recordName(l.origin, qname)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	names := []string{name}
+	if wildcard := wildcardName(name); len(wildcard) > 0 {
+		names = append(names, wildcard)
+	}
+	all, err := l.zone.GetEnabledDnsRecordSetsByNames(names)
+	if err != nil {
+		return nil, nil, false, errors.Wrapf(err, "GetEnabledDnsRecordSetsByNames")
+	}
+	records := []models.SDnsRecordSet{}
+	for _, owner := range names {
+		for i := range all {
+			if all[i].Name == owner {
+				records = append(records, all[i])
+			}
+		}
+		if len(records) > 0 {
+			break
+		}
+	}
+
+	exists = len(records) > 0 || name == "@"
+	synthesized := []dns.RR{}
+	if name == "@" {
+		synthesized = append(synthesized, zoneSOA(l.origin, uint32(l.zone.Serial)))
+		if l.zone.DnssecEnabled {
+			if key, err := dns.NewRR(l.zone.DnssecKey); err == nil {
+				synthesized = append(synthesized, key)
+			}
+		}
+	}
+	present := map[uint16]bool{}
+	for _, rr := range synthesized {
+		present[rr.Header().Rrtype] = true
+		if rr.Header().Rrtype == qtype {
+			rrs = append(rrs, rr)
+		}
+	}
+
+	matched, cnames := []models.SDnsRecordSet{}, []models.SDnsRecordSet{}
+	for i := range records {
+		rrtype := dns.StringToType[records[i].DnsType]
+		present[rrtype] = true
+		if rrtype == dns.TypeCNAME {
+			cnames = append(cnames, records[i])
+		}
+		if rrtype == qtype {
+			matched = append(matched, records[i])
+		}
+	}
+	if len(cnames) > 0 && qtype != dns.TypeCNAME {
+		matched = cnames
+	}
+	for _, rr := range recordSetsToRRs(l.origin, selectRecords(matched, l.vpcIds)) {
+		// answers synthesized from wildcard are owned by qname
+		rr.Header().Name = qname
+		rrs = append(rrs, rr)
+	}
+	for rrtype := range present {
+		types = append(types, rrtype)
+	}
+	return rrs, types, exists, nil
+}
+
+// lookup resolves qname in the zone, following CNAME inside the zone
+func (l *sPrivateZoneLookup) lookup(qname string, qtype uint16) ([]dns.RR, []uint16, bool, error) {
+	answer := []dns.RR{}
+	rrs, types, exists, err := l.lookupName(qname, qtype)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	for i := 0; i < maxCnameChain && len(rrs) > 0; i++ {
+		answer = append(answer, rrs...)
+		cname, ok := rrs[0].(*dns.CNAME)
+		if !ok || qtype == dns.TypeCNAME {
+			break
+		}
+		target := strings.ToLower(cname.Target)
+		if !dns.IsSubDomain(l.origin, target) {
+			// left to the resolver
+			break
+		}
+		rrs, _, _, err = l.lookupName(target, qtype)
+		if err != nil {
+			return nil, nil, false, err
+		}
+	}
+	return answer, types, exists, nil
+}
+
+// privateZoneReply builds the authoritative reply of query to zone
+func privateZoneReply(state request.Request, zone *models.SDnsZone, vpcIds []string) *dns.Msg {
+	origin := dns.Fqdn(strings.ToLower(zone.Name))
+	qname := strings.ToLower(state.Name())
+	m := new(dns.Msg)
+	m.SetReply(state.Req)
+	m.Authoritative, m.RecursionAvailable = true, true
+
+	l := &sPrivateZoneLookup{zone: zone, origin: origin, vpcIds: vpcIds}
+	answer, types, exists, err := l.lookup(qname, state.QType())
+	if err != nil {
+		ylog.Errorf("lookup %s in private zone %s: %v", qname, zone.Name, err)
+		m.Rcode = dns.RcodeServerFailure
+		return m
+	}
+	m.Answer = answer
+	if len(answer) == 0 {
+		m.Ns = []dns.RR{zoneSOA(origin, uint32(zone.Serial))}
+		if !exists {
+			m.Rcode = dns.RcodeNameError
+		}
+	}
+	if zone.DnssecEnabled && state.Do() {
+		err = signPrivateZoneReply(m, zone, qname, types, exists)
+		if err != nil {
+			ylog.Errorf("sign reply of private zone %s: %v", zone.Name, err)
+			m.Answer, m.Ns = nil, nil
+			m.Rcode = dns.RcodeServerFailure
+		}
+	}
+	return m
+}
+
+// servePrivateZone answers queries from guests to private zones bound to
+// their vpcs. Queries out of such zones are not handled.
+func (r *SRegionDNS) servePrivateZone(ctx context.Context, w dns.ResponseWriter, req *dns.Msg) (bool, int, error) {
+	state := request.Request{W: w, Req: req, Context: ctx}
+	vpcIds, err := models.GuestnetworkManager.GetVpcIdsByAddress(state.IP())
+	if err != nil {
+		ylog.Errorf("GetVpcIdsByAddress %s: %v", state.IP(), err)
+		return false, dns.RcodeSuccess, nil
+	}
+	if len(vpcIds) == 0 {
+		return false, dns.RcodeSuccess, nil
+	}
+	if len(vpcIds) > 1 {
+		// never answer from private zones of vpcs the querier may not belong to
+		ylog.Warningf("querier %s found in vpcs %s, skip private zones", state.IP(), strings.Join(vpcIds, ","))
+		return false, dns.RcodeSuccess, nil
+	}
+	zone, err := findPrivateZone(vpcIds, state.Name())
+	if err != nil {
+		if errors.Cause(err) != sql.ErrNoRows {
+			ylog.Warningf("find private zone of %s from %s: %v", state.Name(), state.IP(), err)
+		}
+		return false, dns.RcodeSuccess, nil
+	}
+	m := privateZoneReply(state, zone, vpcIds)
+	if !plugin.ClientWrite(m.Rcode) {
+		return true, m.Rcode, nil
+	}
+	state.SizeAndDo(m)
+	m = state.Scrub(m)
+	w.WriteMsg(m)
+	return true, m.Rcode, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+
+	"yunion.io/x/onecloud/pkg/compute/models"
+)
+
+func TestSelectByPolicy(t *testing.T) {
+	newRecord := func(id, value string) models.SDnsRecordSet {
+		rec := models.SDnsRecordSet{}
+		rec.Id = id
+		rec.DnsType = "A"
+		rec.DnsValue = value
+		return rec
+	}
+	newPolicy := func(policyType, policyValue string) models.SDnsTrafficPolicy {
+		policy := models.SDnsTrafficPolicy{}
+		policy.PolicyType = policyType
+		policy.PolicyValue = policyValue
+		return policy
+	}
+	records := []models.SDnsRecordSet{
+		newRecord("simple", "10.0.0.1"),
+		newRecord("w1", "10.0.0.2"),
+		newRecord("w3", "10.0.0.3"),
+		newRecord("primary", "10.0.0.4"),
+		newRecord("secondary", "10.0.0.5"),
+	}
+	policies := map[string]models.SDnsTrafficPolicy{
+		"w1":        newPolicy("Weighted", "1"),
+		"w3":        newPolicy("Weighted", "3"),
+		"primary":   newPolicy("Failover", "PRIMARY"),
+		"secondary": newPolicy("Failover", "SECONDARY"),
+	}
+	ids := func(records []models.SDnsRecordSet) []string {
+		ret := []string{}
+		for i := range records {
+			ret = append(ret, records[i].Id)
+		}
+		return ret
+	}
+	cases := []struct {
+		name    string
+		healthy bool
+		n       int
+		want    []string
+	}{
+		{"first weight", true, 0, []string{"simple", "w1", "primary"}},
+		{"second weight", true, 1, []string{"simple", "w3", "primary"}},
+		{"primary down", false, 3, []string{"simple", "w3", "secondary"}},
+	}
+	for _, c := range cases {
+		healthy := func(*models.SDnsRecordSet) bool { return c.healthy }
+		intn := func(total int) int {
+			if total != 4 {
+				t.Errorf("%s: total weight %d, want 4", c.name, total)
+			}
+			return c.n
+		}
+		got := ids(selectByPolicy(records, policies, healthy, intn))
+		if len(got) != len(c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestSignPrivateZoneReply(t *testing.T) {
+	zone := &models.SDnsZone{}
+	zone.Name = "example.com"
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     dns.ZONE | dns.SEP,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	zone.DnssecKey = key.String()
+	zone.DnssecPrivateKey = key.PrivateKeyString(priv)
+
+	rr, _ := dns.NewRR("www.example.com. 60 IN A 10.0.0.1")
+	m := new(dns.Msg)
+	m.Answer = []dns.RR{rr}
+	err = signPrivateZoneReply(m, zone, "www.example.com.", []uint16{dns.TypeA}, true)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if len(m.Answer) != 2 {
+		t.Fatalf("want record and signature, got %v", m.Answer)
+	}
+	sig, ok := m.Answer[1].(*dns.RRSIG)
+	if !ok {
+		t.Fatalf("want RRSIG, got %s", m.Answer[1])
+	}
+	if err := sig.Verify(key, []dns.RR{rr}); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+
+	m = new(dns.Msg)
+	m.Rcode = dns.RcodeNameError
+	m.Ns = []dns.RR{zoneSOA("example.com.", 1)}
+	err = signPrivateZoneReply(m, zone, "nx.example.com.", nil, false)
+	if err != nil {
+		t.Fatalf("sign denial: %v", err)
+	}
+	if m.Rcode != dns.RcodeSuccess {
+		t.Errorf("nonexistent name should be answered as NODATA, got rcode %d", m.Rcode)
+	}
+	nsecs := 0
+	for _, rr := range m.Ns {
+		if nsec, ok := rr.(*dns.NSEC); ok {
+			nsecs++
+			if nsec.Hdr.Name != "nx.example.com." || len(nsec.TypeBitMap) != 2 {
+				t.Errorf("unexpected denial %s", nsec)
+			}
+		}
+	}
+	if nsecs != 1 || len(m.Ns) != 4 {
+		t.Errorf("want signed SOA and NSEC, got %v", m.Ns)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/compute/models"
+)
+
+const (
+	errRecordNotInZone        = errors.Error("record not in zone")
+	errRecordTypeNotSupported = errors.Error("record type not supported")
+
+	// maximal length of a character string in TXT rdata
+	txtChunkLength = 255
+)
+
+// recordName returns the owner name of a record relative to origin, "@"
+// stands for the zone apex
+func recordName(origin, name string) (string, error) {
+	name = strings.ToLower(dns.Fqdn(name))
+	if name == origin {
+		return "@", nil
+	}
+	if !dns.IsSubDomain(origin, name) {
+		return "", errors.Wrapf(errRecordNotInZone, "%s not in %s", name, origin)
+	}
+	return strings.TrimSuffix(name, "."+origin), nil
+}
+
+func recordFqdn(origin, name string) string {
+	if len(name) == 0 || name == "@" {
+		return origin
+	}
+	return dns.Fqdn(name + "." + origin)
+}
+
+func splitTxt(value string) []string {
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		value = value[1 : len(value)-1]
+	}
+	txt := []string{}
+	for len(value) > txtChunkLength {
+		txt = append(txt, value[:txtChunkLength])
+		value = value[txtChunkLength:]
+	}
+	return append(txt, value)
+}
+
+// recordSetToRR converts a record of dns zone whose origin is given to
+// resource record
+func recordSetToRR(origin string, rec *models.SDnsRecordSet) (dns.RR, error) {
+	hdr := dns.RR_Header{
+		Name:   recordFqdn(origin, rec.Name),
+		Rrtype: dns.StringToType[rec.DnsType],
+		Class:  dns.ClassINET,
+		Ttl:    uint32(rec.TTL),
+	}
+	if hdr.Rrtype == dns.TypeNone {
+		return nil, errors.Wrapf(errRecordTypeNotSupported, "%s", rec.DnsType)
+	}
+	switch hdr.Rrtype {
+	case dns.TypeTXT:
+		return &dns.TXT{Hdr: hdr, Txt: splitTxt(rec.DnsValue)}, nil
+	case dns.TypeSPF:
+		return &dns.SPF{Hdr: hdr, Txt: splitTxt(rec.DnsValue)}, nil
+	}
+	value := rec.DnsValue
+	switch hdr.Rrtype {
+	case dns.TypeMX:
+		value = fmt.Sprintf("%d %s", rec.MxPriority, dns.Fqdn(value))
+	case dns.TypeCNAME, dns.TypeNS, dns.TypePTR:
+		value = dns.Fqdn(value)
+	}
+	rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", hdr.Name, hdr.Ttl, rec.DnsType, value))
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse %s record %s", rec.DnsType, rec.DnsValue)
+	}
+	if rr == nil {
+		return nil, errors.Wrapf(errRecordTypeNotSupported, "empty %s record", rec.DnsType)
+	}
+	return rr, nil
+}
+
+// rrToRecordSet converts a resource record to the form stored in dns zone
+// whose origin is given
+func rrToRecordSet(origin string, rr dns.RR) (api.SDnsRecordSet, error) {
+	rec := api.SDnsRecordSet{}
+	hdr := rr.Header()
+	name, err := recordName(origin, hdr.Name)
+	if err != nil {
+		return rec, err
+	}
+	rec.Name = name
+	rec.DnsType = dns.TypeToString[hdr.Rrtype]
+	rec.TTL = int64(hdr.Ttl)
+	switch v := rr.(type) {
+	case *dns.A:
+		rec.DnsValue = v.A.String()
+	case *dns.AAAA:
+		rec.DnsValue = v.AAAA.String()
+	case *dns.CNAME:
+		rec.DnsValue = strings.TrimSuffix(v.Target, ".")
+	case *dns.NS:
+		rec.DnsValue = strings.TrimSuffix(v.Ns, ".")
+	case *dns.PTR:
+		rec.DnsValue = strings.TrimSuffix(v.Ptr, ".")
+	case *dns.MX:
+		rec.DnsValue = strings.TrimSuffix(v.Mx, ".")
+		rec.MxPriority = int64(v.Preference)
+	case *dns.TXT:
+		rec.DnsValue = strings.Join(v.Txt, "")
+	case *dns.SPF:
+		rec.DnsValue = strings.Join(v.Txt, "")
+	case *dns.SRV:
+		rec.DnsValue = fmt.Sprintf("%d %d %d %s", v.Priority, v.Weight, v.Port, strings.TrimSuffix(v.Target, "."))
+	case *dns.CAA:
+		rec.DnsValue = fmt.Sprintf("%d %s %q", v.Flag, v.Tag, v.Value)
+	default:
+		return rec, errors.Wrapf(errRecordTypeNotSupported, "%s", rec.DnsType)
+	}
+	return rec, nil
+}
+
+func isSameRecord(rec *models.SDnsRecordSet, target *api.SDnsRecordSet) bool {
+	if rec.Name != target.Name || rec.DnsType != target.DnsType {
+		return false
+	}
+	if rec.DnsType == "MX" && rec.MxPriority != target.MxPriority {
+		return false
+	}
+	if rec.DnsType == "TXT" || rec.DnsType == "SPF" {
+		return strings.Join(splitTxt(rec.DnsValue), "") == target.DnsValue
+	}
+	return strings.EqualFold(strings.TrimSuffix(rec.DnsValue, "."), target.DnsValue)
+}
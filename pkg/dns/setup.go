@@ -16,6 +16,7 @@ package dns
 
 import (
 	"fmt"
+	"net"
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
@@ -59,6 +60,18 @@ func setup(c *caddy.Controller) error {
 		go rDNS.initK8s()
 	}
 
+	if len(rDNS.XfrListen) > 0 {
+		xfrServer := newXfrServer(rDNS, rDNS.XfrListen)
+		c.OnStartup(func() error {
+			xfrServer.Start()
+			return nil
+		})
+		c.OnShutdown(func() error {
+			xfrServer.Stop()
+			return nil
+		})
+	}
+
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
 		rDNS.Next = next
 		return rDNS
@@ -132,6 +145,23 @@ func parseConfig(c *caddy.Controller) (*SRegionDNS, error) {
 					rDNS.Upstream = u
 				case "k8s_skip":
 					rDNS.K8sSkip = true
+				case "transfer_allow":
+					args := c.RemainingArgs()
+					if len(args) == 0 {
+						return nil, c.ArgErr()
+					}
+					for _, arg := range args {
+						_, ipnet, err := net.ParseCIDR(arg)
+						if err != nil {
+							return nil, c.Errf("invalid transfer_allow network %q", arg)
+						}
+						rDNS.TransferAllow = append(rDNS.TransferAllow, ipnet)
+					}
+				case "xfr_listen":
+					if !c.NextArg() {
+						return nil, c.ArgErr()
+					}
+					rDNS.XfrListen = c.Val()
 				default:
 					if c.Val() != "}" {
 						return nil, c.Errf("unknown property %q", c.Val())
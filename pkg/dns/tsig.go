@@ -0,0 +1,204 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+
+	ylog "yunion.io/x/log"
+
+	"yunion.io/x/onecloud/pkg/compute/models"
+)
+
+const (
+	tsigKeyReloadInterval = 30 * time.Second
+)
+
+// sXfrServer serves zone transfers and dynamic updates signed by TSIG keys.
+//
+// CoreDNS does not verify TSIG for plugins, so these requests are served
+// by a dedicated listener whose TSIG secrets are reloaded from the database
+// periodically.  The listener is restarted when keys change because the
+// secrets can not be changed after the server started, or when it exited
+// unexpectedly, e.g. failed to bind the address.
+type sXfrServer struct {
+	rdns *SRegionDNS
+	addr string
+
+	lock    sync.Mutex
+	secrets map[string]string
+	servers []*dns.Server
+	// some listener of servers exited unexpectedly
+	exited bool
+}
+
+func newXfrServer(rdns *SRegionDNS, addr string) *sXfrServer {
+	return &sXfrServer{
+		rdns: rdns,
+		addr: addr,
+	}
+}
+
+func tsigAlgorithm(alg string) string {
+	return dns.Fqdn(alg)
+}
+
+func (s *sXfrServer) loadSecrets() (map[string]string, error) {
+	keys, err := models.DnsTsigKeyManager.GetAllKeys()
+	if err != nil {
+		return nil, err
+	}
+	secrets := make(map[string]string, len(keys))
+	for i := range keys {
+		secrets[dns.Fqdn(keys[i].Name)] = keys[i].Secret
+	}
+	return secrets, nil
+}
+
+func (s *sXfrServer) Start() {
+	s.reload()
+	go func() {
+		tick := time.NewTicker(tsigKeyReloadInterval)
+		defer tick.Stop()
+		for range tick.C {
+			s.reload()
+		}
+	}()
+}
+
+func (s *sXfrServer) reload() {
+	secrets, err := s.loadSecrets()
+	if err != nil {
+		ylog.Errorf("load tsig keys error: %v", err)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.servers != nil && !s.exited && reflect.DeepEqual(secrets, s.secrets) {
+		return
+	}
+	for _, srv := range s.servers {
+		srv.Shutdown()
+	}
+	s.secrets = secrets
+	s.servers = nil
+	s.exited = false
+	for _, net := range []string{"udp", "tcp"} {
+		srv := &dns.Server{
+			Addr:       s.addr,
+			Net:        net,
+			Handler:    s,
+			TsigSecret: secrets,
+		}
+		go s.serve(srv)
+		s.servers = append(s.servers, srv)
+	}
+	ylog.Infof("xfr server listening on %s with %d tsig keys", s.addr, len(secrets))
+}
+
+// serve runs the listener until it is shut down, a listener exited on error
+// is restarted by next reload
+func (s *sXfrServer) serve(srv *dns.Server) {
+	err := srv.ListenAndServe()
+	if err == nil {
+		return
+	}
+	ylog.Errorf("xfr server %s/%s exited: %v", srv.Addr, srv.Net, err)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, running := range s.servers {
+		if running == srv {
+			s.exited = true
+			break
+		}
+	}
+}
+
+func (s *sXfrServer) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, srv := range s.servers {
+		srv.Shutdown()
+	}
+	s.servers = nil
+}
+
+func (s *sXfrServer) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	ctx := context.Background()
+	var key *models.SDnsTsigKey
+	if tsig := req.IsTsig(); tsig != nil {
+		err := w.TsigStatus()
+		if err == nil {
+			key, err = models.DnsTsigKeyManager.FetchByKeyName(tsig.Hdr.Name)
+			if err == nil && tsigAlgorithm(key.Algorithm) != tsig.Algorithm {
+				err = dns.ErrKeyAlg
+			}
+		}
+		if err != nil {
+			ylog.Warningf("tsig of %s from %s invalid: %v", tsig.Hdr.Name, w.RemoteAddr(), err)
+			m := new(dns.Msg)
+			m.SetRcode(req, dns.RcodeNotAuth)
+			w.WriteMsg(m)
+			return
+		}
+	}
+
+	rcode, err := s.rdns.serveZoneRequest(ctx, w, req, key)
+	if err != nil {
+		ylog.Errorf("serve %s from %s error: %v", dns.OpcodeToString[req.Opcode], w.RemoteAddr(), err)
+	}
+	if plugin.ClientWrite(rcode) {
+		m := new(dns.Msg)
+		m.SetRcode(req, rcode)
+		signReply(w, req, m)
+		w.WriteMsg(m)
+	}
+}
+
+func isZoneRequest(req *dns.Msg) bool {
+	if req.Opcode == dns.OpcodeUpdate {
+		return true
+	}
+	if len(req.Question) == 1 {
+		qtype := req.Question[0].Qtype
+		return qtype == dns.TypeAXFR || qtype == dns.TypeIXFR
+	}
+	return false
+}
+
+// serveZoneRequest serves zone transfers and dynamic updates, key is the
+// verified TSIG key signing the request if any
+func (r *SRegionDNS) serveZoneRequest(ctx context.Context, w dns.ResponseWriter, req *dns.Msg, key *models.SDnsTsigKey) (int, error) {
+	if req.Opcode == dns.OpcodeUpdate {
+		return r.update(ctx, w, req, key)
+	}
+	if !isZoneRequest(req) {
+		return dns.RcodeRefused, nil
+	}
+	state := request.Request{W: w, Req: req, Context: ctx}
+	return r.transfer(ctx, state, key)
+}
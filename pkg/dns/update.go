@@ -0,0 +1,298 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/miekg/dns"
+
+	ylog "yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
+	"yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+type updateOp struct {
+	class  uint16
+	rrtype uint16
+	name   string
+	record api.SDnsRecordSet
+}
+
+func signReply(w dns.ResponseWriter, req *dns.Msg, m *dns.Msg) {
+	if tsig := req.IsTsig(); tsig != nil && w.TsigStatus() == nil {
+		m.SetTsig(tsig.Hdr.Name, tsig.Algorithm, tsig.Fudge, time.Now().Unix())
+	}
+}
+
+// writeReply writes a reply of rcode which coredns does not write on behalf
+// of plugins
+func writeReply(w dns.ResponseWriter, req *dns.Msg, rcode int) (int, error) {
+	m := new(dns.Msg)
+	m.SetRcode(req, rcode)
+	m.Authoritative = true
+	signReply(w, req, m)
+	w.WriteMsg(m)
+	return rcode, nil
+}
+
+func enabledRecords(records []models.SDnsRecordSet) []models.SDnsRecordSet {
+	ret := make([]models.SDnsRecordSet, 0, len(records))
+	for i := range records {
+		if records[i].Enabled.IsTrue() {
+			ret = append(ret, records[i])
+		}
+	}
+	return ret
+}
+
+// checkPrerequisites checks prerequisite section of a dynamic update as
+// RFC 2136 section 3.2 describes
+func checkPrerequisites(zone *models.SDnsZone, origin string, prereqs []dns.RR) (int, error) {
+	type rrsetKey struct {
+		name   string
+		rrtype string
+	}
+	valueDependent := map[rrsetKey][]api.SDnsRecordSet{}
+	for _, rr := range prereqs {
+		hdr := rr.Header()
+		if hdr.Ttl != 0 {
+			return dns.RcodeFormatError, nil
+		}
+		name, err := recordName(origin, hdr.Name)
+		if err != nil {
+			return dns.RcodeNotZone, nil
+		}
+		rrtype := ""
+		if hdr.Rrtype != dns.TypeANY {
+			rrtype = dns.TypeToString[hdr.Rrtype]
+		}
+		switch hdr.Class {
+		case dns.ClassANY, dns.ClassNONE:
+			if hdr.Rdlength != 0 {
+				return dns.RcodeFormatError, nil
+			}
+			records, err := zone.GetDnsRecordSetsByName(name, rrtype)
+			if err != nil {
+				return dns.RcodeServerFailure, err
+			}
+			exists := len(enabledRecords(records)) > 0
+			switch {
+			case hdr.Class == dns.ClassANY && !exists && hdr.Rrtype == dns.TypeANY:
+				return dns.RcodeNameError, nil
+			case hdr.Class == dns.ClassANY && !exists:
+				return dns.RcodeNXRrset, nil
+			case hdr.Class == dns.ClassNONE && exists && hdr.Rrtype == dns.TypeANY:
+				return dns.RcodeYXDomain, nil
+			case hdr.Class == dns.ClassNONE && exists:
+				return dns.RcodeYXRrset, nil
+			}
+		case dns.ClassINET:
+			rec, err := rrToRecordSet(origin, rr)
+			if err != nil {
+				return dns.RcodeFormatError, nil
+			}
+			key := rrsetKey{name: name, rrtype: rec.DnsType}
+			valueDependent[key] = append(valueDependent[key], rec)
+		default:
+			return dns.RcodeFormatError, nil
+		}
+	}
+	for key, expected := range valueDependent {
+		records, err := zone.GetDnsRecordSetsByName(key.name, key.rrtype)
+		if err != nil {
+			return dns.RcodeServerFailure, err
+		}
+		records = enabledRecords(records)
+		if len(records) != len(expected) {
+			return dns.RcodeNXRrset, nil
+		}
+		for i := range expected {
+			found := false
+			for j := range records {
+				if isSameRecord(&records[j], &expected[i]) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return dns.RcodeNXRrset, nil
+			}
+		}
+	}
+	return dns.RcodeSuccess, nil
+}
+
+// parseUpdates prescans update section as RFC 2136 section 3.4.1 describes,
+// every update is validated before any of them is applied
+func parseUpdates(origin string, updates []dns.RR) ([]updateOp, int) {
+	ops := make([]updateOp, 0, len(updates))
+	for _, rr := range updates {
+		hdr := rr.Header()
+		name, err := recordName(origin, hdr.Name)
+		if err != nil {
+			return nil, dns.RcodeNotZone
+		}
+		if hdr.Rrtype == dns.TypeSOA {
+			// SOA is maintained by region, ignore it
+			continue
+		}
+		op := updateOp{
+			class:  hdr.Class,
+			rrtype: hdr.Rrtype,
+			name:   name,
+		}
+		switch hdr.Class {
+		case dns.ClassINET:
+			if hdr.Rrtype == dns.TypeANY || hdr.Rrtype == dns.TypeAXFR || hdr.Rrtype == dns.TypeIXFR {
+				return nil, dns.RcodeFormatError
+			}
+			op.record, err = rrToRecordSet(origin, rr)
+			if err != nil {
+				return nil, dns.RcodeNotImplemented
+			}
+			// refuse the whole update rather than failing halfway
+			err = op.record.ValidateDnsrecordValue()
+			if err != nil {
+				return nil, dns.RcodeRefused
+			}
+		case dns.ClassANY:
+			if hdr.Ttl != 0 || hdr.Rdlength != 0 {
+				return nil, dns.RcodeFormatError
+			}
+		case dns.ClassNONE:
+			if hdr.Ttl != 0 || hdr.Rrtype == dns.TypeANY {
+				return nil, dns.RcodeFormatError
+			}
+			op.record, err = rrToRecordSet(origin, rr)
+			if err != nil {
+				return nil, dns.RcodeNotImplemented
+			}
+		default:
+			return nil, dns.RcodeFormatError
+		}
+		ops = append(ops, op)
+	}
+	return ops, dns.RcodeSuccess
+}
+
+func applyUpdate(ctx context.Context, userCred mcclient.TokenCredential, zone *models.SDnsZone, op updateOp) (bool, error) {
+	if op.class == dns.ClassINET {
+		return zone.AddDnsRecordSet(ctx, userCred, op.record)
+	}
+	rrtype := ""
+	if op.rrtype != dns.TypeANY {
+		rrtype = dns.TypeToString[op.rrtype]
+	}
+	records, err := zone.GetDnsRecordSetsByName(op.name, rrtype)
+	if err != nil {
+		return false, errors.Wrapf(err, "GetDnsRecordSetsByName %s", op.name)
+	}
+	removed := []models.SDnsRecordSet{}
+	for i := range records {
+		// NS records of zone apex are never removed by dynamic update
+		if op.name == "@" && records[i].DnsType == "NS" && op.rrtype != dns.TypeNS {
+			continue
+		}
+		if op.class == dns.ClassNONE && !isSameRecord(&records[i], &op.record) {
+			continue
+		}
+		removed = append(removed, records[i])
+	}
+	if len(removed) == 0 {
+		return false, nil
+	}
+	err = zone.RemoveDnsRecordSets(ctx, userCred, removed)
+	if err != nil {
+		return false, errors.Wrap(err, "RemoveDnsRecordSets")
+	}
+	return true, nil
+}
+
+// update serves dynamic updates as RFC 2136 describes, the update must be
+// signed by a tsig key of the zone
+func (r *SRegionDNS) update(ctx context.Context, w dns.ResponseWriter, req *dns.Msg, key *models.SDnsTsigKey) (int, error) {
+	if len(req.Question) != 1 || req.Question[0].Qtype != dns.TypeSOA {
+		return dns.RcodeFormatError, nil
+	}
+	zone, err := models.DnsZoneManager.FetchByZoneName(req.Question[0].Name)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return writeReply(w, req, dns.RcodeNotAuth)
+		}
+		return dns.RcodeServerFailure, err
+	}
+	if key == nil || key.DnsZoneId != zone.Id {
+		ylog.Warningf("dynamic update of zone %s refused", zone.Name)
+		return dns.RcodeRefused, nil
+	}
+	origin := dns.Fqdn(strings.ToLower(zone.Name))
+
+	// prerequisites are checked and updates are applied as a whole
+	lockman.LockRawObject(ctx, zone.Keyword(), fmt.Sprintf("%s-records", zone.Id))
+	defer lockman.ReleaseRawObject(ctx, zone.Keyword(), fmt.Sprintf("%s-records", zone.Id))
+
+	rcode, err := checkPrerequisites(zone, origin, req.Answer)
+	if err != nil {
+		return rcode, errors.Wrap(err, "checkPrerequisites")
+	}
+	if rcode != dns.RcodeSuccess {
+		if plugin.ClientWrite(rcode) {
+			return rcode, nil
+		}
+		return writeReply(w, req, rcode)
+	}
+	ops, rcode := parseUpdates(origin, req.Ns)
+	if rcode != dns.RcodeSuccess {
+		if plugin.ClientWrite(rcode) {
+			return rcode, nil
+		}
+		return writeReply(w, req, rcode)
+	}
+
+	userCred := key.GetUserCred(ctx)
+	changed := false
+	for _, op := range ops {
+		ok, err := applyUpdate(ctx, userCred, zone, op)
+		if err != nil {
+			ylog.Errorf("dynamic update of zone %s by key %s error: %v", zone.Name, key.Name, err)
+			if changed {
+				zone.IncSerial(ctx)
+			}
+			return dns.RcodeServerFailure, err
+		}
+		changed = changed || ok
+	}
+	if changed {
+		err = zone.IncSerial(ctx)
+		if err != nil {
+			ylog.Errorf("IncSerial of zone %s error: %v", zone.Name, err)
+		}
+		err = zone.MarkDirty()
+		if err != nil {
+			ylog.Errorf("MarkDirty of zone %s error: %v", zone.Name, err)
+		}
+	}
+	return writeReply(w, req, dns.RcodeSuccess)
+}
@@ -0,0 +1,51 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestParseUpdates(t *testing.T) {
+	origin := "example.com."
+	newRR := func(s string) dns.RR {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatalf("parse rr %q: %v", s, err)
+		}
+		return rr
+	}
+	deleteRRset := &dns.ANY{Hdr: dns.RR_Header{Name: "old.example.com.", Rrtype: dns.TypeA, Class: dns.ClassANY}}
+	cases := []struct {
+		name  string
+		rrs   []dns.RR
+		rcode int
+		ops   int
+	}{
+		{"add and delete", []dns.RR{newRR("www.example.com. 60 IN A 10.0.0.1"), deleteRRset}, dns.RcodeSuccess, 2},
+		{"soa ignored", []dns.RR{newRR("example.com. 60 IN SOA ns.example.com. admin.example.com. 1 60 60 60 60")}, dns.RcodeSuccess, 0},
+		{"out of zone", []dns.RR{newRR("www.example.org. 60 IN A 10.0.0.1")}, dns.RcodeNotZone, 0},
+		{"invalid value", []dns.RR{newRR("www.example.com. 60 IN A 10.0.0.1"), newRR("example.com. 60 IN MX 100 mail.example.com.")}, dns.RcodeRefused, 0},
+		{"delete with ttl", []dns.RR{newRR("www.example.com. 60 NONE A 10.0.0.1")}, dns.RcodeFormatError, 0},
+	}
+	for _, c := range cases {
+		ops, rcode := parseUpdates(origin, c.rrs)
+		if rcode != c.rcode || len(ops) != c.ops {
+			t.Errorf("%s: want rcode %s with %d updates, got %s with %d", c.name, dns.RcodeToString[c.rcode], c.ops, dns.RcodeToString[rcode], len(ops))
+		}
+	}
+}
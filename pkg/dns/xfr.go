@@ -16,23 +16,226 @@ package dns
 
 import (
 	"context"
-	"time"
+	"database/sql"
+	"net"
 
 	"github.com/coredns/coredns/request"
 	"github.com/miekg/dns"
+
+	ylog "yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/compute/models"
+)
+
+const (
+	defaultSerial = 1
+	xfrMinTTL     = 30
+
+	// Start a new envelope after message reaches this size in bytes
+	transferLength = 4000
 )
 
 // Serial implements the Transferer interface
 func (r *SRegionDNS) Serial(state request.Request) uint32 {
-	return uint32(time.Now().Unix())
+	zone, err := models.DnsZoneManager.FetchByZoneName(state.Zone)
+	if err != nil {
+		return defaultSerial
+	}
+	return uint32(zone.Serial)
 }
 
 // MinTTL implements the Transferer interface
 func (r *SRegionDNS) MinTTL(state request.Request) uint32 {
-	return 30
+	return xfrMinTTL
 }
 
 // Transferer implements the Transferer interface
 func (r *SRegionDNS) Transfer(ctx context.Context, state request.Request) (int, error) {
-	return dns.RcodeServerFailure, nil
+	return r.transfer(ctx, state, nil)
+}
+
+func (r *SRegionDNS) transferAllowed(state request.Request, zone *models.SDnsZone, key *models.SDnsTsigKey) bool {
+	if key != nil {
+		return key.DnsZoneId == zone.Id
+	}
+	ip := net.ParseIP(state.IP())
+	for _, ipnet := range r.TransferAllow {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func zoneSOA(origin string, serial uint32) *dns.SOA {
+	return &dns.SOA{
+		Hdr: dns.RR_Header{
+			Name:   origin,
+			Rrtype: dns.TypeSOA,
+			Class:  dns.ClassINET,
+			Ttl:    xfrMinTTL,
+		},
+		Ns:      defaultNSName + origin,
+		Mbox:    "hostmaster." + origin,
+		Serial:  serial,
+		Refresh: 7200,
+		Retry:   1800,
+		Expire:  86400,
+		Minttl:  xfrMinTTL,
+	}
+}
+
+func recordSetsToRRs(origin string, records []models.SDnsRecordSet) []dns.RR {
+	rrs := make([]dns.RR, 0, len(records))
+	for i := range records {
+		rr, err := recordSetToRR(origin, &records[i])
+		if err != nil {
+			ylog.Warningf("skip record %s %s of %s: %v", records[i].Name, records[i].DnsType, origin, err)
+			continue
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs
+}
+
+// serialLess compares serials with RFC 1982 serial number arithmetic
+func serialLess(a, b uint32) bool {
+	return a != b && b-a < 1<<31
+}
+
+func ixfrClientSerial(req *dns.Msg) (uint32, bool) {
+	for _, rr := range req.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Serial, true
+		}
+	}
+	return 0, false
+}
+
+// ixfrRecords builds the condensed difference between two versions of zone
+// as RFC 1995 allows
+func ixfrRecords(soa *dns.SOA, oldSerial uint32, oldRRs, newRRs []dns.RR) []dns.RR {
+	oldSet := make(map[string]dns.RR, len(oldRRs))
+	for _, rr := range oldRRs {
+		oldSet[rr.String()] = rr
+	}
+	newSet := make(map[string]dns.RR, len(newRRs))
+	for _, rr := range newRRs {
+		newSet[rr.String()] = rr
+	}
+
+	oldSOA := dns.Copy(soa).(*dns.SOA)
+	oldSOA.Serial = oldSerial
+	rrs := []dns.RR{soa, oldSOA}
+	for _, rr := range oldRRs {
+		if _, ok := newSet[rr.String()]; !ok {
+			rrs = append(rrs, rr)
+		}
+	}
+	rrs = append(rrs, soa)
+	for _, rr := range newRRs {
+		if _, ok := oldSet[rr.String()]; !ok {
+			rrs = append(rrs, rr)
+		}
+	}
+	return append(rrs, soa)
+}
+
+func (r *SRegionDNS) transferRecords(state request.Request, zone *models.SDnsZone) ([]dns.RR, error) {
+	origin := dns.Fqdn(zone.Name)
+	soa := zoneSOA(origin, uint32(zone.Serial))
+	records, err := zone.GetEnabledDnsRecordSets()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetEnabledDnsRecordSets")
+	}
+	rrs := recordSetsToRRs(origin, records)
+
+	if state.QType() == dns.TypeIXFR {
+		clientSerial, ok := ixfrClientSerial(state.Req)
+		if ok {
+			if !serialLess(clientSerial, soa.Serial) {
+				return []dns.RR{soa}, nil
+			}
+			oldRecords, err := zone.GetJournalRecordSets(clientSerial)
+			if err == nil {
+				return ixfrRecords(soa, clientSerial, recordSetsToRRs(origin, oldRecords), rrs), nil
+			}
+			if errors.Cause(err) != sql.ErrNoRows {
+				return nil, errors.Wrapf(err, "GetJournalRecordSets %d", clientSerial)
+			}
+			// history not available, fall back to AXFR
+		}
+	}
+
+	ret := make([]dns.RR, 0, len(rrs)+2)
+	ret = append(ret, soa)
+	ret = append(ret, rrs...)
+	return append(ret, soa), nil
+}
+
+func (r *SRegionDNS) transfer(ctx context.Context, state request.Request, key *models.SDnsTsigKey) (int, error) {
+	zone, err := models.DnsZoneManager.FetchByZoneName(state.Name())
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return writeReply(state.W, state.Req, dns.RcodeNotAuth)
+		}
+		return dns.RcodeServerFailure, err
+	}
+	if !r.transferAllowed(state, zone, key) {
+		ylog.Warningf("zone transfer of %s from %s refused", zone.Name, state.IP())
+		return dns.RcodeRefused, nil
+	}
+
+	records, err := r.transferRecords(state, zone)
+	if err != nil {
+		return dns.RcodeServerFailure, err
+	}
+
+	if state.Proto() == "udp" {
+		// RFC 1995: reply with SOA only when the difference does not fit
+		// into one datagram, so that the client retries with TCP
+		if state.QType() == dns.TypeAXFR {
+			return dns.RcodeRefused, nil
+		}
+		m := new(dns.Msg)
+		m.SetReply(state.Req)
+		m.Authoritative = true
+		m.Answer = records
+		if m.Len() > dns.MinMsgSize {
+			m.Answer = records[:1]
+		}
+		signReply(state.W, state.Req, m)
+		state.W.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	}
+
+	envelopes := []*dns.Envelope{}
+	j, l := 0, 0
+	for i, rr := range records {
+		l += dns.Len(rr)
+		if l > transferLength && i > j {
+			envelopes = append(envelopes, &dns.Envelope{RR: records[j:i]})
+			l = dns.Len(rr)
+			j = i
+		}
+	}
+	if j < len(records) {
+		envelopes = append(envelopes, &dns.Envelope{RR: records[j:]})
+	}
+	ch := make(chan *dns.Envelope, len(envelopes))
+	for _, env := range envelopes {
+		ch <- env
+	}
+	close(ch)
+
+	ylog.Infof("Outgoing %s of %d records of zone %s serial %d to %s started", dns.TypeToString[state.QType()], len(records), zone.Name, zone.Serial, state.IP())
+	tr := new(dns.Transfer)
+	err = tr.Out(state.W, state.Req, ch)
+	if err != nil {
+		ylog.Errorf("Outgoing transfer of zone %s to %s error: %v", zone.Name, state.IP(), err)
+	}
+
+	state.W.Hijack()
+	return dns.RcodeSuccess, nil
 }
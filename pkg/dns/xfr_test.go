@@ -0,0 +1,132 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+
+	"yunion.io/x/onecloud/pkg/compute/models"
+)
+
+func newRecordSet(name, dnsType, value string, ttl, mxPriority int64) models.SDnsRecordSet {
+	rec := models.SDnsRecordSet{}
+	rec.Name = name
+	rec.DnsType = dnsType
+	rec.DnsValue = value
+	rec.TTL = ttl
+	rec.MxPriority = mxPriority
+	return rec
+}
+
+func TestRecordSetRRConversion(t *testing.T) {
+	origin := "example.com."
+	cases := []struct {
+		rec  models.SDnsRecordSet
+		want string
+	}{
+		{
+			rec:  newRecordSet("www", "A", "10.0.0.1", 300, 0),
+			want: "www.example.com.\t300\tIN\tA\t10.0.0.1",
+		},
+		{
+			rec:  newRecordSet("@", "MX", "mail.example.com", 600, 10),
+			want: "example.com.\t600\tIN\tMX\t10 mail.example.com.",
+		},
+		{
+			rec:  newRecordSet("alias", "CNAME", "www.example.com", 60, 0),
+			want: "alias.example.com.\t60\tIN\tCNAME\twww.example.com.",
+		},
+		{
+			rec:  newRecordSet("_sip._tcp", "SRV", "10 60 5060 sip.example.com", 60, 0),
+			want: "_sip._tcp.example.com.\t60\tIN\tSRV\t10 60 5060 sip.example.com.",
+		},
+		{
+			rec:  newRecordSet("@", "TXT", "v=spf1 -all", 60, 0),
+			want: "example.com.\t60\tIN\tTXT\t\"v=spf1 -all\"",
+		},
+	}
+	for _, c := range cases {
+		rr, err := recordSetToRR(origin, &c.rec)
+		if err != nil {
+			t.Fatalf("recordSetToRR %s %s: %v", c.rec.Name, c.rec.DnsType, err)
+		}
+		if got := rr.String(); got != c.want {
+			t.Errorf("recordSetToRR got %q, want %q", got, c.want)
+		}
+		back, err := rrToRecordSet(origin, rr)
+		if err != nil {
+			t.Fatalf("rrToRecordSet %s: %v", rr, err)
+		}
+		if !isSameRecord(&c.rec, &back) || back.TTL != c.rec.TTL {
+			t.Errorf("rrToRecordSet got %#v, want %#v", back, c.rec)
+		}
+	}
+
+	rr, _ := dns.NewRR("www.example.org. 60 IN A 10.0.0.1")
+	if _, err := rrToRecordSet(origin, rr); err == nil {
+		t.Errorf("record out of zone should be rejected")
+	}
+}
+
+func TestSerialLess(t *testing.T) {
+	cases := []struct {
+		a, b uint32
+		want bool
+	}{
+		{1, 2, true},
+		{2, 1, false},
+		{2, 2, false},
+		{0xffffffff, 1, true},
+		{1, 0xffffffff, false},
+	}
+	for _, c := range cases {
+		if got := serialLess(c.a, c.b); got != c.want {
+			t.Errorf("serialLess(%d, %d) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestIxfrRecords(t *testing.T) {
+	origin := "example.com."
+	soa := zoneSOA(origin, 3)
+	mustRR := func(s string) dns.RR {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatalf("NewRR %s: %v", s, err)
+		}
+		return rr
+	}
+	kept := mustRR("www.example.com. 60 IN A 10.0.0.1")
+	removed := mustRR("old.example.com. 60 IN A 10.0.0.2")
+	added := mustRR("new.example.com. 60 IN A 10.0.0.3")
+
+	rrs := ixfrRecords(soa, 1, []dns.RR{kept, removed}, []dns.RR{kept, added})
+	if len(rrs) != 6 {
+		t.Fatalf("want 6 records, got %d: %v", len(rrs), rrs)
+	}
+	serials := []uint32{3, 1, 3, 3}
+	soaIdx := []int{0, 1, 3, 5}
+	for i, idx := range soaIdx {
+		s, ok := rrs[idx].(*dns.SOA)
+		if !ok || s.Serial != serials[i] {
+			t.Errorf("record %d should be SOA of serial %d, got %s", idx, serials[i], rrs[idx])
+		}
+	}
+	if rrs[2].String() != removed.String() || rrs[4].String() != added.String() {
+		t.Errorf("unexpected difference %v", rrs)
+	}
+}
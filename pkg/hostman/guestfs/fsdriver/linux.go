@@ -759,12 +759,28 @@ func (d *sDebianLikeRootFs) DeployNetworkingScripts(rootFs IDiskPartition, nics
 				cmds.WriteString(getNicTeamingConfigCmds(nicDesc.TeamingSlaves))
 			}
 			cmds.WriteString("\n")
+			if len(nicDesc.Ip6) > 0 {
+				cmds.WriteString(fmt.Sprintf("iface %s inet6 static\n", nicDesc.Name))
+				cmds.WriteString(fmt.Sprintf("    address %s/%d\n", nicDesc.Ip6, nicDesc.Masklen6))
+				if len(nicDesc.Gateway6) > 0 && nicDesc.Ip == mainIp {
+					cmds.WriteString(fmt.Sprintf("    gateway %s\n", nicDesc.Gateway6))
+				}
+				if dns6 := getNicDns6(nicDesc); len(dns6) > 0 {
+					cmds.WriteString(fmt.Sprintf("    dns-nameservers %s\n", strings.Join(dns6, " ")))
+					dnss = append(dnss, dns6...)
+				}
+				cmds.WriteString("\n")
+			}
 		} else {
 			cmds.WriteString(fmt.Sprintf("iface %s inet dhcp\n", nicDesc.Name))
 			if len(nicDesc.TeamingSlaves) > 0 {
 				cmds.WriteString(getNicTeamingConfigCmds(nicDesc.TeamingSlaves))
 			}
 			cmds.WriteString("\n")
+			if len(nicDesc.Ip6) > 0 {
+				cmds.WriteString(fmt.Sprintf("iface %s inet6 dhcp\n", nicDesc.Name))
+				cmds.WriteString("\n")
+			}
 		}
 	}
 
@@ -1044,6 +1060,16 @@ func (r *sRedhatLikeRootFs) Centos5DeployNetworkingScripts(rootFs IDiskPartition
 	return nil
 }
 
+func getNicDns6(nic *types.SServerNic) []string {
+	dnslist := []string{}
+	for _, dns := range strings.Split(nic.Dns6, ",") {
+		if dns = strings.TrimSpace(dns); len(dns) > 0 {
+			dnslist = append(dnslist, dns)
+		}
+	}
+	return dnslist
+}
+
 func getMainNic(nics []*types.SServerNic) (*types.SServerNic, error) {
 	var mainIp netutils.IPV4Addr
 	var mainNic *types.SServerNic
@@ -1191,8 +1217,24 @@ func (r *sRedhatLikeRootFs) deployNetworkingScripts(rootFs IDiskPartition, nics
 					cmds.WriteString(fmt.Sprintf("DOMAIN=%s\n", nicDesc.Domain))
 				}
 			}
+			if len(nicDesc.Ip6) > 0 {
+				cmds.WriteString("IPV6INIT=yes\n")
+				cmds.WriteString("IPV6_AUTOCONF=no\n")
+				cmds.WriteString(fmt.Sprintf("IPV6ADDR=%s/%d\n", nicDesc.Ip6, nicDesc.Masklen6))
+				if len(nicDesc.Gateway6) > 0 && nicDesc.Ip == mainIp {
+					cmds.WriteString(fmt.Sprintf("IPV6_DEFAULTGW=%s\n", nicDesc.Gateway6))
+				}
+				for i, dns := range getNicDns6(nicDesc) {
+					cmds.WriteString(fmt.Sprintf("DNS%d=%s\n", len(dnslist)+i+1, dns))
+				}
+			}
 		} else {
 			cmds.WriteString("BOOTPROTO=dhcp\n")
+			if len(nicDesc.Ip6) > 0 {
+				cmds.WriteString("IPV6INIT=yes\n")
+				cmds.WriteString("IPV6_AUTOCONF=no\n")
+				cmds.WriteString("DHCPV6C=yes\n")
+			}
 		}
 		var fn = fmt.Sprintf("/etc/sysconfig/network-scripts/ifcfg-%s", nicDesc.Name)
 		log.Debugf("%s: %s", fn, cmds.String())
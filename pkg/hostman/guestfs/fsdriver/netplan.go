@@ -121,5 +121,15 @@ func getNetplanEthernetConfig(nic *types.SServerNic, isBond bool) *netplan.Ether
 		nicConf = netplan.NewDHCP4EthernetConfig()
 	}
 
+	if !nic.Virtual && len(nic.Ip6) > 0 {
+		if nic.Manual {
+			nicConf.Addresses = append(nicConf.Addresses, fmt.Sprintf("%s/%d", nic.Ip6, nic.Masklen6))
+			nicConf.Gateway6 = nic.Gateway6
+			nicConf.Nameservers.Addresses = append(nicConf.Nameservers.Addresses, getNicDns6(nic)...)
+		} else {
+			nicConf.DHCP6 = true
+		}
+	}
+
 	return nicConf
 }
@@ -40,14 +40,25 @@ type actionFunc func(context.Context, mcclient.TokenCredential, string, jsonutil
 
 var (
 	keyWords = []string{"servers"}
+
+	serialLogWorkerMan *appsrv.SWorkerManager
 )
 
+func init() {
+	serialLogWorkerMan = appsrv.NewWorkerManager("serial_log_worker", 8, appsrv.DEFAULT_BACKLOG, false)
+}
+
 func AddGuestTaskHandler(prefix string, app *appsrv.Application) {
 	for _, keyWord := range keyWords {
 		app.AddHandler("GET",
 			fmt.Sprintf("%s/%s/<sid>/status", prefix, keyWord),
 			auth.Authenticate(getStatus))
 
+		hi := app.AddHandler2("GET",
+			fmt.Sprintf("%s/%s/<sid>/serial-log", prefix, keyWord),
+			auth.Authenticate(getSerialLog), nil, "serial_log", nil)
+		hi.SetProcessNoTimeout().SetWorkerManager(serialLogWorkerMan)
+
 		app.AddHandler("POST",
 			fmt.Sprintf("%s/%s/cpu-node-balance", prefix, keyWord),
 			auth.Authenticate(cpusetBalance))
@@ -76,6 +87,7 @@ func AddGuestTaskHandler(prefix string, app *appsrv.Application) {
 			"src-prepare-migrate":   guestSrcPrepareMigrate,
 			"dest-prepare-migrate":  guestDestPrepareMigrate,
 			"live-migrate":          guestLiveMigrate,
+			"cancel-live-migrate":   guestCancelLiveMigrate,
 			"resume":                guestResume,
 			"drive-mirror":          guestDriveMirror,
 			"hotplug-cpu-mem":       guestHotplugCpuMem,
@@ -129,6 +141,41 @@ func getStatus(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 	hostutils.ResponseOk(ctx, w)
 }
 
+func getSerialLog(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	params, query, _ := appsrv.FetchEnv(ctx, w, r)
+	sid := params["<sid>"]
+	guest, ok := guestman.GetGuestManager().GetServer(sid)
+	if !ok {
+		httperrors.NotFoundError(ctx, w, "Guest %s not found", sid)
+		return
+	}
+	var tail int64
+	var follow bool
+	if query != nil {
+		tail, _ = query.Int("tail")
+		follow = jsonutils.QueryBoolean(query, "follow", false)
+	}
+	if !follow {
+		data, err := guest.ReadSerialLog(tail)
+		if err != nil {
+			hostutils.Response(ctx, w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(data)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	var flush func()
+	if f, ok := w.(http.Flusher); ok {
+		flush = f.Flush
+	}
+	if err := guest.FollowSerialLog(r.Context(), tail, w, flush); err != nil {
+		log.Warningf("follow serial log of %s: %v", sid, err)
+	}
+}
+
 func cpusetBalance(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 	hostutils.DelayTask(ctx, guestman.GetGuestManager().CpusetBalance, nil)
 	hostutils.ResponseOk(ctx, w)
@@ -286,15 +333,31 @@ func guestSrcPrepareMigrate(ctx context.Context, userCred mcclient.TokenCredenti
 	}
 	liveMigrate := jsonutils.QueryBoolean(body, "live_migrate", false)
 	liveMigrateEnableTls := jsonutils.QueryBoolean(body, "enable_tls", false)
+	migrateOpts, err := fetchLiveMigrateOptions(body)
+	if err != nil {
+		return nil, err
+	}
 	hostutils.DelayTask(ctx, guestman.GetGuestManager().SrcPrepareMigrate,
 		&guestman.SSrcPrepareMigrate{
 			Sid:               sid,
 			LiveMigrate:       liveMigrate,
 			LiveMigrateUseTLS: liveMigrateEnableTls,
+			MigrateOptions:    migrateOpts,
 		})
 	return nil, nil
 }
 
+func fetchLiveMigrateOptions(body jsonutils.JSONObject) (*computeapi.GuestLiveMigrateOptions, error) {
+	if !body.Contains("migrate_options") {
+		return nil, nil
+	}
+	opts := new(computeapi.GuestLiveMigrateOptions)
+	if err := body.Unmarshal(opts, "migrate_options"); err != nil {
+		return nil, httperrors.NewInputParameterError("unmarshal migrate_options: %s", err)
+	}
+	return opts, nil
+}
+
 func guestDestPrepareMigrate(ctx context.Context, userCred mcclient.TokenCredential, sid string, body jsonutils.JSONObject) (interface{}, error) {
 	if !guestman.GetGuestManager().CanMigrate(sid) {
 		return nil, httperrors.NewBadRequestError("Guest exist")
@@ -323,6 +386,10 @@ func guestDestPrepareMigrate(ctx context.Context, userCred mcclient.TokenCredent
 	params.LiveMigrate = liveMigrate
 	params.SourceQemuCmdline = qemuCmdline
 	params.EnableTLS = jsonutils.QueryBoolean(body, "enable_tls", false)
+	params.MigrateOptions, err = fetchLiveMigrateOptions(body)
+	if err != nil {
+		return nil, err
+	}
 	if params.EnableTLS {
 		certsObj, err := body.Get("migrate_certs")
 		if err != nil {
@@ -413,16 +480,28 @@ func guestLiveMigrate(ctx context.Context, userCred mcclient.TokenCredential, si
 		return nil, httperrors.NewMissingParameterError("is_local_storage")
 	}
 	enableTLS := jsonutils.QueryBoolean(body, "enable_tls", false)
+	migrateOpts, err := fetchLiveMigrateOptions(body)
+	if err != nil {
+		return nil, err
+	}
 	hostutils.DelayTaskWithoutReqctx(ctx, guestman.GetGuestManager().LiveMigrate, &guestman.SLiveMigrate{
-		Sid:       sid,
-		DestPort:  int(destPort),
-		DestIp:    destIp,
-		IsLocal:   isLocal,
-		EnableTLS: enableTLS,
+		Sid:            sid,
+		DestPort:       int(destPort),
+		DestIp:         destIp,
+		IsLocal:        isLocal,
+		EnableTLS:      enableTLS,
+		MigrateOptions: migrateOpts,
 	})
 	return nil, nil
 }
 
+func guestCancelLiveMigrate(ctx context.Context, userCred mcclient.TokenCredential, sid string, body jsonutils.JSONObject) (interface{}, error) {
+	if !guestman.GetGuestManager().IsGuestExist(sid) {
+		return nil, httperrors.NewNotFoundError("Guest %s not found", sid)
+	}
+	return nil, guestman.GetGuestManager().CancelLiveMigrate(sid)
+}
+
 func guestResume(ctx context.Context, userCred mcclient.TokenCredential, sid string, body jsonutils.JSONObject) (interface{}, error) {
 	if !guestman.GetGuestManager().IsGuestExist(sid) {
 		return nil, httperrors.NewNotFoundError("Guest %s not found", sid)
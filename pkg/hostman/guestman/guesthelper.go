@@ -17,6 +17,7 @@ package guestman
 import (
 	"yunion.io/x/jsonutils"
 
+	api "yunion.io/x/onecloud/pkg/apis/compute"
 	hostapi "yunion.io/x/onecloud/pkg/apis/host"
 	"yunion.io/x/onecloud/pkg/hostman/storageman"
 	"yunion.io/x/onecloud/pkg/multicloud/esxi/vcenter"
@@ -37,6 +38,7 @@ type SSrcPrepareMigrate struct {
 	Sid               string
 	LiveMigrate       bool
 	LiveMigrateUseTLS bool
+	MigrateOptions    *api.GuestLiveMigrateOptions
 }
 
 type SDestPrepareMigrate struct {
@@ -59,6 +61,8 @@ type SDestPrepareMigrate struct {
 
 	MemorySnapshotsUri string
 	SrcMemorySnapshots []string
+
+	MigrateOptions *api.GuestLiveMigrateOptions
 }
 
 type SLiveMigrate struct {
@@ -67,6 +71,8 @@ type SLiveMigrate struct {
 	DestIp    string
 	IsLocal   bool
 	EnableTLS bool
+
+	MigrateOptions *api.GuestLiveMigrateOptions
 }
 
 type SDriverMirror struct {
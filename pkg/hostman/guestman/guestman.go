@@ -95,6 +95,7 @@ func NewGuestManager(host hostutils.IHost, serversPath string) *SGuestManager {
 	manager.ServersLock = &sync.Mutex{}
 	manager.GuestStartWorker = appsrv.NewWorkerManager("GuestStart", 1, appsrv.DEFAULT_BACKLOG, false)
 	manager.StartCpusetBalancer()
+	manager.StartSerialLogRotator()
 	manager.LoadExistingGuests()
 	manager.host.StartDHCPServer()
 	manager.dirtyServersChan = make(chan struct{})
@@ -810,6 +811,9 @@ func (m *SGuestManager) SrcPrepareMigrate(ctx context.Context, params interface{
 		}
 		ret.Set("migrate_certs", jsonutils.Marshal(certs))
 	}
+	if migParams.LiveMigrate {
+		ret.Set("migrate_options", jsonutils.Marshal(guest.ResolveLiveMigrateOptions(migParams.MigrateOptions)))
+	}
 	return ret, nil
 }
 
@@ -863,6 +867,10 @@ func (m *SGuestManager) DestPrepareMigrate(ctx context.Context, params interface
 		startParams.Set("need_migrate", jsonutils.JSONTrue)
 		startParams.Set("source_qemu_cmdline", jsonutils.NewString(migParams.SourceQemuCmdline))
 		startParams.Set("live_migrate_use_tls", jsonutils.NewBool(migParams.EnableTLS))
+		if opts := migParams.MigrateOptions; opts != nil && opts.MultifdChannels != nil && *opts.MultifdChannels > 0 {
+			startParams.Set("live_migrate_multifd_channels", jsonutils.NewInt(int64(*opts.MultifdChannels)))
+			startParams.Set("live_migrate_compression", jsonutils.NewString(opts.Compression))
+		}
 		if len(migParams.MigrateCerts) > 0 {
 			if err := guest.WriteMigrateCerts(migParams.MigrateCerts); err != nil {
 				return nil, errors.Wrap(err, "write migrate certs")
@@ -907,6 +915,15 @@ func (m *SGuestManager) LiveMigrate(ctx context.Context, params interface{}) (js
 	return nil, nil
 }
 
+func (m *SGuestManager) CancelLiveMigrate(sid string) error {
+	guest, _ := m.GetServer(sid)
+	if guest.migrateTask == nil {
+		return httperrors.NewBadRequestError("Guest %s not in live migrating", sid)
+	}
+	guest.migrateTask.cancel()
+	return nil
+}
+
 func (m *SGuestManager) CanMigrate(sid string) bool {
 	m.ServersLock.Lock()
 	defer m.ServersLock.Unlock()
@@ -28,6 +28,7 @@ import (
 	"yunion.io/x/pkg/errors"
 	"yunion.io/x/pkg/utils"
 
+	api "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/appctx"
 	"yunion.io/x/onecloud/pkg/hostman/guestman/qemu"
 	"yunion.io/x/onecloud/pkg/hostman/hostinfo"
@@ -626,6 +627,11 @@ func (t *SGuestIsolatedDeviceSyncTask) addDevice(dev jsonutils.JSONObject) {
 		return
 	}
 
+	if err := t.guest.setupSRIOVNic(dev); err != nil {
+		cb(errors.Wrap(err, "setupSRIOVNic").Error())
+		return
+	}
+
 	opts, err := devObj.GetHotPlugOptions()
 	if err != nil {
 		cb(errors.Wrap(err, "GetHotPlugOptions").Error())
@@ -683,6 +689,7 @@ type SGuestLiveMigrateTask struct {
 
 	timeoutAt        time.Time
 	doTimeoutMigrate bool
+	cancelled        bool
 }
 
 func NewGuestLiveMigrateTask(
@@ -703,8 +710,52 @@ func (s *SGuestLiveMigrateTask) onSetZeroBlocks(res string) {
 		hostutils.TaskFailed(s.ctx, fmt.Sprintf("Migrate set capability zero-blocks error: %s", res))
 		return
 	}
+	// options were resolved by src-prepare-migrate and are the same as
+	// those of the destination
+	opts := s.params.MigrateOptions
+	if opts == nil {
+		opts = &api.GuestLiveMigrateOptions{}
+	}
+	steps := []migrateSetupStep{}
 	// https://wiki.qemu.org/Features/AutoconvergeLiveMigration
-	s.Monitor.MigrateSetCapability("auto-converge", "on", s.startMigrate)
+	autoConverge := "off"
+	if opts.AutoConverge == nil || *opts.AutoConverge {
+		autoConverge = "on"
+	}
+	steps = append(steps, migrateSetupStep{
+		desc: "Migrate set capability auto-converge",
+		call: func(cb monitor.StringCallback) { s.Monitor.MigrateSetCapability("auto-converge", autoConverge, cb) },
+	})
+	if opts.MultifdChannels != nil {
+		steps = append(steps, s.getMigrateMultifdSteps(*opts.MultifdChannels, opts.Compression)...)
+	}
+	if opts.MaxBandwidthMb != nil && *opts.MaxBandwidthMb > 0 {
+		bandwidth := *opts.MaxBandwidthMb * 1024 * 1024
+		steps = append(steps, migrateSetupStep{
+			desc: "Migrate set max-bandwidth",
+			call: func(cb monitor.StringCallback) { s.Monitor.MigrateSetParameter("max-bandwidth", bandwidth, cb) },
+		})
+	}
+	if opts.DowntimeLimitMs != nil && *opts.DowntimeLimitMs > 0 {
+		downtime := *opts.DowntimeLimitMs
+		steps = append(steps, migrateSetupStep{
+			desc: "Migrate set downtime-limit",
+			call: func(cb monitor.StringCallback) { s.Monitor.MigrateSetParameter("downtime-limit", downtime, cb) },
+		})
+	}
+	if s.params.EnableTLS {
+		// https://wiki.qemu.org/Features/MigrationTLS
+		steps = append(steps, s.getMigrateTLSSteps("client")...)
+	} else {
+		steps = append(steps, migrateSetupStep{
+			desc: "Migrate set tls-creds to empty",
+			call: func(cb monitor.StringCallback) { s.Monitor.MigrateSetParameter("tls-creds", "", cb) },
+		})
+	}
+	runMigrateSetupSteps(steps, s.doMigrate, func(reason string) {
+		s.migrateTask = nil
+		hostutils.TaskFailed(s.ctx, reason)
+	})
 }
 
 func (s *SGuestLiveMigrateTask) startRamMigrateTimeout() {
@@ -721,47 +772,12 @@ func (s *SGuestLiveMigrateTask) startRamMigrateTimeout() {
 	log.Infof("migrate timeout seconds: %d now: %v expectfinial: %v", migSeconds, time.Now(), s.timeoutAt)
 }
 
-func (s *SGuestLiveMigrateTask) startMigrate(res string) {
-	if strings.Contains(strings.ToLower(res), "error") {
+func (s *SGuestLiveMigrateTask) doMigrate() {
+	if s.cancelled {
 		s.migrateTask = nil
-		hostutils.TaskFailed(s.ctx, fmt.Sprintf("Migrate set capability auto-converge error: %s", res))
+		hostutils.TaskFailed(s.ctx, api.LIVE_MIGRATE_CANCELLED)
 		return
 	}
-	if s.params.EnableTLS {
-		// https://wiki.qemu.org/Features/MigrationTLS
-		s.Monitor.ObjectAdd("tls-creds-x509", map[string]string{
-			"dir":         s.getPKIDirPath(),
-			"endpoint":    "client",
-			"id":          "tls0",
-			"verify-peer": "no",
-		}, func(res string) {
-			if strings.Contains(strings.ToLower(res), "error") {
-				s.migrateTask = nil
-				hostutils.TaskFailed(s.ctx, fmt.Sprintf("Migrate add tls-creds-x509 object client tls0 error: %s", res))
-				return
-			}
-			s.Monitor.MigrateSetParameter("tls-creds", "tls0", func(res string) {
-				if strings.Contains(strings.ToLower(res), "error") {
-					s.migrateTask = nil
-					hostutils.TaskFailed(s.ctx, fmt.Sprintf("Migrate set tls-creds tls0 error: %s", res))
-					return
-				}
-				s.doMigrate()
-			})
-		})
-	} else {
-		s.Monitor.MigrateSetParameter("tls-creds", "", func(res string) {
-			if strings.Contains(strings.ToLower(res), "error") {
-				s.migrateTask = nil
-				hostutils.TaskFailed(s.ctx, fmt.Sprintf("Migrate set tls-creds to empty error: %s", res))
-				return
-			}
-			s.doMigrate()
-		})
-	}
-}
-
-func (s *SGuestLiveMigrateTask) doMigrate() {
 	var copyIncremental = false
 	if s.params.IsLocal {
 		// copy disk data
@@ -771,6 +787,16 @@ func (s *SGuestLiveMigrateTask) doMigrate() {
 		copyIncremental, false, s.startMigrateStatusCheck)
 }
 
+// cancel stops the ongoing migration, the task fails once qemu reports cancelled status
+func (s *SGuestLiveMigrateTask) cancel() {
+	s.cancelled = true
+	if s.Monitor != nil {
+		s.Monitor.MigrateCancel(func(res string) {
+			log.Infof("Guest %s migrate cancel: %s", s.GetName(), res)
+		})
+	}
+}
+
 func (s *SGuestLiveMigrateTask) startMigrateStatusCheck(res string) {
 	if strings.Contains(strings.ToLower(res), "error") {
 		s.migrateTask = nil
@@ -800,7 +826,15 @@ func (s *SGuestLiveMigrateTask) startMigrateStatusCheck(res string) {
 func (s *SGuestLiveMigrateTask) onGetMigrateStatus(status string) {
 	if status == "completed" {
 		s.migrateComplete()
-	} else if status == "failed" || status == "cancelled" {
+	} else if status == "cancelled" {
+		s.migrateTask = nil
+		close(s.c)
+		if s.doTimeoutMigrate {
+			// guest was paused to finish migrate, resume it on source host
+			s.Monitor.SimpleCommand("cont", nil)
+		}
+		hostutils.TaskFailed(s.ctx, api.LIVE_MIGRATE_CANCELLED)
+	} else if status == "failed" {
 		s.migrateTask = nil
 		close(s.c)
 		hostutils.TaskFailed(s.ctx, fmt.Sprintf("Query migrate got status: %s", status))
@@ -838,6 +872,72 @@ func (s *SGuestLiveMigrateTask) migrateComplete() {
 	hostutils.TaskComplete(s.ctx, nil)
 }
 
+type migrateSetupStep struct {
+	desc string
+	call func(cb monitor.StringCallback)
+}
+
+// runMigrateSetupSteps executes monitor commands one by one, stops at the first error
+func runMigrateSetupSteps(steps []migrateSetupStep, onFinish func(), onError func(string)) {
+	if len(steps) == 0 {
+		onFinish()
+		return
+	}
+	step := steps[0]
+	step.call(func(res string) {
+		if strings.Contains(strings.ToLower(res), "error") {
+			onError(fmt.Sprintf("%s error: %s", step.desc, res))
+			return
+		}
+		runMigrateSetupSteps(steps[1:], onFinish, onError)
+	})
+}
+
+// getMigrateTLSSteps adds tls credentials object tls0 as endpoint client or server and uses it for migration
+func (s *SKVMGuestInstance) getMigrateTLSSteps(endpoint string) []migrateSetupStep {
+	return []migrateSetupStep{
+		{
+			desc: fmt.Sprintf("Migrate add tls-creds-x509 object %s tls0", endpoint),
+			call: func(cb monitor.StringCallback) {
+				s.Monitor.ObjectAdd("tls-creds-x509", map[string]string{
+					"dir":         s.getPKIDirPath(),
+					"endpoint":    endpoint,
+					"id":          "tls0",
+					"verify-peer": "no",
+				}, cb)
+			},
+		},
+		{
+			desc: "Migrate set tls-creds tls0",
+			call: func(cb monitor.StringCallback) { s.Monitor.MigrateSetParameter("tls-creds", "tls0", cb) },
+		},
+	}
+}
+
+// getMigrateMultifdSteps enables multifd, which must be set on both source and destination
+func (s *SKVMGuestInstance) getMigrateMultifdSteps(channels int, compression string) []migrateSetupStep {
+	if channels <= 0 {
+		return nil
+	}
+	steps := []migrateSetupStep{
+		{
+			desc: "Migrate set capability multifd",
+			call: func(cb monitor.StringCallback) { s.Monitor.MigrateSetCapability("multifd", "on", cb) },
+		},
+		{
+			desc: "Migrate set multifd-channels",
+			call: func(cb monitor.StringCallback) { s.Monitor.MigrateSetParameter("multifd-channels", channels, cb) },
+		},
+	}
+	if len(compression) > 0 {
+		steps = append(steps, migrateSetupStep{
+			desc: "Migrate set multifd-compression",
+			call: func(cb monitor.StringCallback) { s.Monitor.MigrateSetParameter("multifd-compression", compression, cb) },
+		})
+	}
+	return steps
+}
+
 /**
  *  GuestResumeTask
 **/
@@ -1519,9 +1619,38 @@ func NewGuestOnlineResizeDiskTask(
 }
 
 func (task *SGuestOnlineResizeDiskTask) Start() {
+	if disk := task.getSpdkDisk(); disk != nil {
+		// vhost-user-blk disk is not a qemu block node, resize the lvol
+		// and spdk target notifies guest of the capacity change
+		if err := disk.OnlineResize(task.sizeMB); err != nil {
+			task.OnResizeSucc(err.Error())
+		} else {
+			task.OnResizeSucc("")
+		}
+		return
+	}
 	task.Monitor.GetBlocks(task.OnGetBlocksSucc)
 }
 
+func (task *SGuestOnlineResizeDiskTask) getSpdkDisk() *storageman.SSpdkDisk {
+	disks, _ := task.Desc.GetArray("disks")
+	for i := 0; i < len(disks); i++ {
+		diskId, _ := disks[i].GetString("disk_id")
+		if diskId != task.diskId {
+			continue
+		}
+		diskPath, _ := disks[i].GetString("path")
+		disk, err := storageman.GetManager().GetDiskByPath(diskPath)
+		if err != nil {
+			return nil
+		}
+		if spdkDisk, ok := disk.(*storageman.SSpdkDisk); ok {
+			return spdkDisk
+		}
+	}
+	return nil
+}
+
 func (task *SGuestOnlineResizeDiskTask) OnGetBlocksSucc(blocks []monitor.QemuBlock) {
 	for i := 0; i < len(blocks); i += 1 {
 		image := ""
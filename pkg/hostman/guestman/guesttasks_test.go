@@ -0,0 +1,100 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guestman
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"yunion.io/x/onecloud/pkg/hostman/monitor"
+)
+
+type migrateTestMonitor struct {
+	monitor.Monitor
+
+	commands []string
+}
+
+func (m *migrateTestMonitor) MigrateSetCapability(capability, state string, callback monitor.StringCallback) {
+	m.commands = append(m.commands, fmt.Sprintf("capability %s=%s", capability, state))
+	callback("")
+}
+
+func (m *migrateTestMonitor) MigrateSetParameter(key string, val interface{}, callback monitor.StringCallback) {
+	m.commands = append(m.commands, fmt.Sprintf("parameter %s=%v", key, val))
+	callback("")
+}
+
+func TestRunMigrateSetupSteps(t *testing.T) {
+	newSteps := func(called *[]string, results ...string) []migrateSetupStep {
+		steps := make([]migrateSetupStep, len(results))
+		for i := range results {
+			desc, res := fmt.Sprintf("step%d", i), results[i]
+			steps[i] = migrateSetupStep{
+				desc: desc,
+				call: func(cb monitor.StringCallback) {
+					*called = append(*called, desc)
+					cb(res)
+				},
+			}
+		}
+		return steps
+	}
+	cases := []struct {
+		name    string
+		results []string
+		called  []string
+		err     string
+	}{
+		{"no step", nil, nil, ""},
+		{"all succeed", []string{"", "ok"}, []string{"step0", "step1"}, ""},
+		{"stop at error", []string{"", "Error: unknown parameter", ""}, []string{"step0", "step1"}, "step1 error: Error: unknown parameter"},
+	}
+	for _, c := range cases {
+		called := []string{}
+		finished, errMsg := false, ""
+		runMigrateSetupSteps(newSteps(&called, c.results...), func() { finished = true }, func(msg string) { errMsg = msg })
+		if len(called) != len(c.called) || (len(called) > 0 && !reflect.DeepEqual(called, c.called)) {
+			t.Errorf("%s: want steps %v called, got %v", c.name, c.called, called)
+		}
+		if finished != (len(c.err) == 0) || errMsg != c.err {
+			t.Errorf("%s: want error %q, got finished %v error %q", c.name, c.err, finished, errMsg)
+		}
+	}
+}
+
+func TestGetMigrateMultifdSteps(t *testing.T) {
+	cases := []struct {
+		channels    int
+		compression string
+		commands    []string
+	}{
+		{0, "zstd", nil},
+		{4, "", []string{"capability multifd=on", "parameter multifd-channels=4"}},
+		{2, "zstd", []string{"capability multifd=on", "parameter multifd-channels=2", "parameter multifd-compression=zstd"}},
+	}
+	for _, c := range cases {
+		mon := &migrateTestMonitor{}
+		s := &SKVMGuestInstance{Monitor: mon}
+		finished := false
+		runMigrateSetupSteps(s.getMigrateMultifdSteps(c.channels, c.compression), func() { finished = true }, func(msg string) {
+			t.Errorf("channels %d compression %q: %s", c.channels, c.compression, msg)
+		})
+		if !finished || len(mon.commands) != len(c.commands) || (len(c.commands) > 0 && !reflect.DeepEqual(mon.commands, c.commands)) {
+			t.Errorf("channels %d compression %q: want commands %v, got %v", c.channels, c.compression, c.commands, mon.commands)
+		}
+	}
+}
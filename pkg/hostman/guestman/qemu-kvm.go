@@ -644,32 +644,26 @@ func (s *SKVMGuestInstance) onMonitorConnected(ctx context.Context) {
 	})
 }
 
-func (s *SKVMGuestInstance) setDestMigrateTLS(ctx context.Context, data *jsonutils.JSONDict) {
+// setDestMigrateIncoming setup tls and multifd of the guest started with '-incoming defer',
+// then start listening on migrate port
+func (s *SKVMGuestInstance) setDestMigrateIncoming(ctx context.Context, data *jsonutils.JSONDict) {
 	port, _ := data.Int("live_migrate_dest_port")
-	s.Monitor.ObjectAdd("tls-creds-x509", map[string]string{
-		"dir":         s.getPKIDirPath(),
-		"endpoint":    "server",
-		"id":          "tls0",
-		"verify-peer": "no",
-	}, func(res string) {
-		if strings.Contains(strings.ToLower(res), "error") {
-			hostutils.TaskFailed(ctx, fmt.Sprintf("Migrate add tls-creds-x509 object server tls0 error: %s", res))
-			return
-		}
-		s.Monitor.MigrateSetParameter("tls-creds", "tls0", func(res string) {
-			if strings.Contains(strings.ToLower(res), "error") {
-				hostutils.TaskFailed(ctx, fmt.Sprintf("Migrate set tls-creds tls0 error: %s", res))
-				return
-			}
-			address := fmt.Sprintf("tcp:0:%d", port)
-			s.Monitor.MigrateIncoming(address, func(res string) {
-				if strings.Contains(strings.ToLower(res), "error") {
-					hostutils.TaskFailed(ctx, fmt.Sprintf("Migrate set incoming %q error: %s", address, res))
-					return
-				}
-				hostutils.TaskComplete(ctx, data)
-			})
-		})
+	steps := []migrateSetupStep{}
+	if jsonutils.QueryBoolean(s.Desc, "live_migrate_use_tls", false) {
+		steps = append(steps, s.getMigrateTLSSteps("server")...)
+	}
+	channels, _ := s.Desc.Int("live_migrate_multifd_channels")
+	compression, _ := s.Desc.GetString("live_migrate_compression")
+	steps = append(steps, s.getMigrateMultifdSteps(int(channels), compression)...)
+	address := fmt.Sprintf("tcp:0:%d", port)
+	steps = append(steps, migrateSetupStep{
+		desc: fmt.Sprintf("Migrate set incoming %q", address),
+		call: func(cb monitor.StringCallback) { s.Monitor.MigrateIncoming(address, cb) },
+	})
+	runMigrateSetupSteps(steps, func() {
+		hostutils.TaskComplete(ctx, data)
+	}, func(reason string) {
+		hostutils.TaskFailed(ctx, reason)
 	})
 }
 
@@ -680,8 +674,8 @@ func (s *SKVMGuestInstance) onGetQemuVersion(ctx context.Context, version string
 		migratePort, _ := s.Desc.Get("live_migrate_dest_port")
 		body := jsonutils.NewDict()
 		body.Set("live_migrate_dest_port", migratePort)
-		if jsonutils.QueryBoolean(s.Desc, "live_migrate_use_tls", false) {
-			s.setDestMigrateTLS(ctx, body)
+		if jsonutils.QueryBoolean(s.Desc, "live_migrate_use_tls", false) || s.Desc.Contains("live_migrate_multifd_channels") {
+			s.setDestMigrateIncoming(ctx, body)
 		} else {
 			hostutils.TaskComplete(ctx, body)
 		}
@@ -1141,6 +1135,7 @@ func (s *SKVMGuestInstance) Delete(ctx context.Context, migrated bool) error {
 	if err := s.delFlatFiles(ctx); err != nil {
 		return errors.Wrap(err, "delFlatFiles")
 	}
+	s.cleanupMdevs()
 	if fileutils2.Exists(s.getQemuLogPath()) {
 		procutils.NewRemoteCommandAsFarAsPossible("mv", s.getQemuLogPath(), fmt.Sprintf("/tmp/%s-qemu.log", s.GetId())).Run()
 	}
@@ -1154,6 +1149,7 @@ func (s *SKVMGuestInstance) Delete(ctx context.Context, migrated bool) error {
 func (s *SKVMGuestInstance) Stop() bool {
 	s.ExitCleanup(true)
 	if s.scriptStop() {
+		s.cleanupMdevs()
 		return true
 	} else {
 		return false
@@ -1164,6 +1160,7 @@ func (s *SKVMGuestInstance) scriptStart() error {
 	output, err := procutils.NewRemoteCommandAsFarAsPossible("bash", s.GetStartScriptPath()).Output()
 	if err != nil {
 		s.scriptStop()
+		s.cleanupMdevs()
 		return fmt.Errorf("Start VM Failed %s %s", output, err)
 	}
 	return nil
@@ -1267,9 +1264,11 @@ func (s *SKVMGuestInstance) compareDescIsolatedDevices(newDesc jsonutils.JSONObj
 	for _, oldDev := range oldDevs {
 		var find = false
 		oVendorDevId, _ := oldDev.GetString("vendor_device_id")
+		oAddr, _ := oldDev.GetString("addr")
 		for idx, addDev := range addDevs {
 			nVendorDevId, _ := addDev.GetString("vendor_device_id")
-			if oVendorDevId == nVendorDevId {
+			nAddr, _ := addDev.GetString("addr")
+			if oVendorDevId == nVendorDevId && oAddr == nAddr {
 				addDevs = append(addDevs[:idx], addDevs[idx+1:]...)
 				find = true
 				break
@@ -1932,6 +1931,48 @@ func (s *SKVMGuestInstance) ExecMemorySnapshotResetTask(ctx context.Context, inp
 	return nil, nil
 }
 
+// ResolveLiveMigrateOptions fills options not specified by request with host defaults,
+// and drops the ones not supported by qemu of the guest
+func (s *SKVMGuestInstance) ResolveLiveMigrateOptions(input *api.GuestLiveMigrateOptions) *api.GuestLiveMigrateOptions {
+	opts := api.GuestLiveMigrateOptions{}
+	if input != nil {
+		opts = *input
+	}
+	if opts.MultifdChannels == nil {
+		channels := options.HostOptions.LiveMigrateMultifdChannels
+		if len(opts.Compression) > 0 && channels == 0 {
+			// default multifd channels of qemu
+			channels = 2
+		}
+		opts.MultifdChannels = &channels
+	}
+	if len(opts.Compression) == 0 && *opts.MultifdChannels > 0 {
+		opts.Compression = options.HostOptions.LiveMigrateCompression
+	}
+	if *opts.MultifdChannels > 0 && version.LT(s.QemuVersion, "4.0.0") {
+		log.Warningf("qemu %s of guest %s not support multifd migration", s.QemuVersion, s.GetName())
+		channels := 0
+		opts.MultifdChannels = &channels
+	}
+	if len(opts.Compression) > 0 && (*opts.MultifdChannels == 0 || version.LT(s.QemuVersion, "5.0.0")) {
+		log.Warningf("multifd compression %s of guest %s not supported, qemu version %s", opts.Compression, s.GetName(), s.QemuVersion)
+		opts.Compression = ""
+	}
+	if opts.AutoConverge == nil {
+		autoConverge := options.HostOptions.LiveMigrateAutoConverge
+		opts.AutoConverge = &autoConverge
+	}
+	if opts.MaxBandwidthMb == nil {
+		bw := options.HostOptions.LiveMigrateMaxBandwidthMb
+		opts.MaxBandwidthMb = &bw
+	}
+	if opts.DowntimeLimitMs == nil {
+		downtime := options.HostOptions.LiveMigrateDowntimeLimitMs
+		opts.DowntimeLimitMs = &downtime
+	}
+	return &opts
+}
+
 func (s *SKVMGuestInstance) PrepareDisksMigrate(liveMigrage bool) (*jsonutils.JSONDict, error) {
 	disksBackFile := jsonutils.NewDict()
 	disks, _ := s.Desc.GetArray("disks")
@@ -0,0 +1,70 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guestman
+
+import (
+	"testing"
+
+	"yunion.io/x/jsonutils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/hostman/options"
+)
+
+func TestResolveLiveMigrateOptions(t *testing.T) {
+	defer func(opts options.SHostOptions) { options.HostOptions = opts }(options.HostOptions)
+	options.HostOptions.LiveMigrateMultifdChannels = 0
+	options.HostOptions.LiveMigrateCompression = ""
+	options.HostOptions.LiveMigrateAutoConverge = true
+	options.HostOptions.LiveMigrateMaxBandwidthMb = 100
+	options.HostOptions.LiveMigrateDowntimeLimitMs = 300
+
+	intPtr := func(i int) *int { return &i }
+	falseVal := false
+	cases := []struct {
+		name         string
+		qemuVersion  string
+		hostChannel  int
+		hostCompress string
+		input        *api.GuestLiveMigrateOptions
+		channels     int
+		compression  string
+	}{
+		{"host defaults", "5.2.0", 0, "", nil, 0, ""},
+		{"host multifd", "5.2.0", 4, api.LIVE_MIGRATE_COMPRESSION_ZSTD, nil, 4, api.LIVE_MIGRATE_COMPRESSION_ZSTD},
+		{"request overrides host", "5.2.0", 4, api.LIVE_MIGRATE_COMPRESSION_ZSTD, &api.GuestLiveMigrateOptions{MultifdChannels: intPtr(8)}, 8, api.LIVE_MIGRATE_COMPRESSION_ZSTD},
+		{"request disables multifd", "5.2.0", 4, api.LIVE_MIGRATE_COMPRESSION_ZSTD, &api.GuestLiveMigrateOptions{MultifdChannels: intPtr(0)}, 0, ""},
+		{"compression enables default channels", "5.2.0", 0, "", &api.GuestLiveMigrateOptions{Compression: api.LIVE_MIGRATE_COMPRESSION_ZSTD}, 2, api.LIVE_MIGRATE_COMPRESSION_ZSTD},
+		{"multifd unsupported", "3.1.0", 4, api.LIVE_MIGRATE_COMPRESSION_ZSTD, nil, 0, ""},
+		{"compression unsupported", "4.2.0", 4, api.LIVE_MIGRATE_COMPRESSION_ZSTD, nil, 4, ""},
+	}
+	for _, c := range cases {
+		options.HostOptions.LiveMigrateMultifdChannels = c.hostChannel
+		options.HostOptions.LiveMigrateCompression = c.hostCompress
+		s := &SKVMGuestInstance{QemuVersion: c.qemuVersion, Desc: jsonutils.NewDict()}
+		opts := s.ResolveLiveMigrateOptions(c.input)
+		if *opts.MultifdChannels != c.channels || opts.Compression != c.compression {
+			t.Errorf("%s: want multifd %d compression %q, got %d %q", c.name, c.channels, c.compression, *opts.MultifdChannels, opts.Compression)
+		}
+		if *opts.AutoConverge != true || *opts.MaxBandwidthMb != 100 || *opts.DowntimeLimitMs != 300 {
+			t.Errorf("%s: host defaults not applied, got %s", c.name, jsonutils.Marshal(opts))
+		}
+	}
+
+	opts := (&SKVMGuestInstance{QemuVersion: "5.2.0", Desc: jsonutils.NewDict()}).ResolveLiveMigrateOptions(&api.GuestLiveMigrateOptions{AutoConverge: &falseVal})
+	if *opts.AutoConverge {
+		t.Errorf("auto converge of request should not be overridden")
+	}
+}
@@ -34,6 +34,7 @@ import (
 	api "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/hostman/guestman/qemu"
 	qemucerts "yunion.io/x/onecloud/pkg/hostman/guestman/qemu/certs"
+	"yunion.io/x/onecloud/pkg/hostman/isolated_device"
 	"yunion.io/x/onecloud/pkg/hostman/options"
 	"yunion.io/x/onecloud/pkg/util/procutils"
 	"yunion.io/x/onecloud/pkg/util/qemutils"
@@ -200,6 +201,96 @@ func (s *SKVMGuestInstance) getNicAddr(index int) int {
 	return qemu.GetNicAddr(index, len(disks), len(isolatedDevices), s.IsVdiSpice())
 }
 
+// getTapNics returns nics backed by tap device, nics of SR-IOV virtual function are passthrough as isolated devices
+func (s *SKVMGuestInstance) getTapNics() []jsonutils.JSONObject {
+	nics, _ := s.Desc.GetArray("nics")
+	ret := make([]jsonutils.JSONObject, 0, len(nics))
+	for _, nic := range nics {
+		if driver, _ := nic.GetString("driver"); driver == api.NETWORK_DRIVER_VFIO {
+			continue
+		}
+		ret = append(ret, nic)
+	}
+	return ret
+}
+
+// setupSRIOVNic program mac and vlan of guest nic on the SR-IOV virtual function bound to it
+func (s *SKVMGuestInstance) setupSRIOVNic(params jsonutils.JSONObject) error {
+	devDesc := api.IsolatedDeviceJsonDesc{}
+	if err := params.Unmarshal(&devDesc); err != nil {
+		return errors.Wrap(err, "unmarshal isolated device desc")
+	}
+	if devDesc.DevType != api.NIC_TYPE || devDesc.NetworkIndex == nil || *devDesc.NetworkIndex < 0 {
+		return nil
+	}
+	dev := s.manager.GetHost().GetIsolatedDeviceManager().GetDeviceByIdent(devDesc.VendorDeviceId, devDesc.Addr)
+	if dev == nil {
+		return errors.Wrapf(errors.ErrNotFound, "isolated device %s", devDesc.Addr)
+	}
+	vfDev, ok := dev.(isolated_device.ISRIOVNicDevice)
+	if !ok {
+		return errors.Errorf("isolated device %s is not SR-IOV virtual function", devDesc.Addr)
+	}
+	nics, _ := s.Desc.GetArray("nics")
+	for _, nic := range nics {
+		if idx, _ := nic.Int("index"); idx != int64(*devDesc.NetworkIndex) {
+			continue
+		}
+		mac, _ := nic.GetString("mac")
+		vlan, _ := nic.Int("vlan")
+		return vfDev.SetNicConfig(mac, int(vlan))
+	}
+	return errors.Wrapf(errors.ErrNotFound, "guest nic of index %d", *devDesc.NetworkIndex)
+}
+
+func (s *SKVMGuestInstance) getMdevDevice(params jsonutils.JSONObject) (isolated_device.IMdevDevice, error) {
+	devType, _ := params.GetString("dev_type")
+	if devType != api.MDEV_TYPE {
+		return nil, nil
+	}
+	vendorDevId, _ := params.GetString("vendor_device_id")
+	addr, _ := params.GetString("addr")
+	dev := s.manager.GetHost().GetIsolatedDeviceManager().GetDeviceByIdent(vendorDevId, addr)
+	if dev == nil {
+		return nil, errors.Wrapf(errors.ErrNotFound, "isolated device %s", addr)
+	}
+	mdev, ok := dev.(isolated_device.IMdevDevice)
+	if !ok {
+		return nil, errors.Errorf("isolated device %s is not mediated device", addr)
+	}
+	return mdev, nil
+}
+
+// setupMdev create mdev instance before qemu start
+func (s *SKVMGuestInstance) setupMdev(params jsonutils.JSONObject) error {
+	mdev, err := s.getMdevDevice(params)
+	if err != nil || mdev == nil {
+		return err
+	}
+	if id, _ := params.GetString("id"); id != mdev.GetMdevId() {
+		return errors.Errorf("mediated device %s id mismatch, expect %s, got %s", mdev.GetAddr(), id, mdev.GetMdevId())
+	}
+	return mdev.CreateMdev()
+}
+
+// cleanupMdevs destroy mdev instances after qemu exited, so the capacity could be used by other types
+func (s *SKVMGuestInstance) cleanupMdevs() {
+	isolatedParams, _ := s.Desc.GetArray("isolated_devices")
+	for _, params := range isolatedParams {
+		mdev, err := s.getMdevDevice(params)
+		if err != nil {
+			log.Errorf("getMdevDevice: %v", err)
+			continue
+		}
+		if mdev == nil {
+			continue
+		}
+		if err := mdev.RemoveMdev(); err != nil {
+			log.Errorf("remove mdev of %s: %v", mdev.GetAddr(), err)
+		}
+	}
+}
+
 func (s *SKVMGuestInstance) extraOptions() string {
 	cmd := " "
 	extraOptions, _ := s.Desc.GetMap("extra_options")
@@ -224,7 +315,7 @@ func (s *SKVMGuestInstance) generateStartScript(data *jsonutils.JSONDict) (strin
 		mem, _  = s.Desc.Int("mem")
 		cpu, _  = s.Desc.Int("cpu")
 		name, _ = s.Desc.GetString("name")
-		nics, _ = s.Desc.GetArray("nics")
+		nics    = s.getTapNics()
 		osname  = s.getOsname()
 		input   = &qemu.GenerateStartOptionsInput{
 			UUID:                 uuid,
@@ -288,6 +379,12 @@ func (s *SKVMGuestInstance) generateStartScript(data *jsonutils.JSONDict) (strin
 	for _, params := range isolatedParams {
 		devAddr, _ := params.GetString("addr")
 		devAddrs = append(devAddrs, devAddr)
+		if err := s.setupSRIOVNic(params); err != nil {
+			return "", errors.Wrapf(err, "setup SR-IOV nic %s", devAddr)
+		}
+		if err := s.setupMdev(params); err != nil {
+			return "", errors.Wrapf(err, "setup mediated device %s", devAddr)
+		}
 	}
 	isolatedDevsParams := s.manager.GetHost().GetIsolatedDeviceManager().GetQemuParams(devAddrs)
 	input.IsolatedDevicesParams = isolatedDevsParams
@@ -443,7 +540,7 @@ function nic_mtu() {
 		if len(input.Nics) > 1 {
 			s.Desc.Set("nics", jsonutils.NewArray(input.Nics[0]))
 		}
-		nics, _ = s.Desc.GetArray("nics")
+		nics = s.getTapNics()
 		input.Nics = nics
 	}
 
@@ -511,6 +608,9 @@ function nic_mtu() {
 	// add serial device
 	if !s.disableIsaSerialDev() {
 		input.EnableSerialDevice = true
+		if options.HostOptions.EnableSerialLog {
+			input.SerialLogPath = s.getSerialLogPath()
+		}
 	}
 
 	if jsonutils.QueryBoolean(data, "need_migrate", false) {
@@ -522,6 +622,14 @@ function nic_mtu() {
 			input.LiveMigrateUseTLS = true
 			s.Desc.Set("live_migrate_use_tls", jsonutils.JSONTrue)
 		}
+		if channels, _ := data.Int("live_migrate_multifd_channels"); channels > 0 {
+			// multifd parameters must be set before migrate_incoming
+			input.LiveMigrateUseMultifd = true
+			s.Desc.Set("live_migrate_multifd_channels", jsonutils.NewInt(channels))
+			if compression, _ := data.GetString("live_migrate_compression"); len(compression) > 0 {
+				s.Desc.Set("live_migrate_compression", jsonutils.NewString(compression))
+			}
+		}
 	} else if jsonutils.QueryBoolean(s.Desc, "is_slave", false) {
 		input.IsSlave = true
 		input.LiveMigratePort = uint(s.manager.GetFreePortByBase(LIVE_MIGRATE_PORT_BASE))
@@ -622,7 +730,7 @@ func (s *SKVMGuestInstance) unifyMigrateQemuCmdline(cur string, src string) (str
 func (s *SKVMGuestInstance) generateStopScript(data *jsonutils.JSONDict) string {
 	var (
 		uuid, _ = s.Desc.GetString("uuid")
-		nics, _ = s.Desc.GetArray("nics")
+		nics    = s.getTapNics()
 	)
 
 	cmd := ""
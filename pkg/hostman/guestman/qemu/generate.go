@@ -72,9 +72,11 @@ type GenerateStartOptionsInput struct {
 	ExtraOptions          []string
 	EnableRNGRandom       bool
 	EnableSerialDevice    bool
+	SerialLogPath         string
 	NeedMigrate           bool
 	LiveMigratePort       uint
 	LiveMigrateUseTLS     bool
+	LiveMigrateUseMultifd bool
 	IsSlave               bool
 	IsMaster              bool
 	EnablePvpanic         bool
@@ -132,6 +134,9 @@ func GenerateStartOptions(
 	var memDev string
 	if input.HugepagesEnabled {
 		memDev = drvOpt.MemPath(input.Mem, fmt.Sprintf("/dev/hugepages/%s", input.UUID))
+	} else if hasVhostUserDisk(input.Disks) {
+		// vhost-user backend requires shared guest memory
+		memDev = drvOpt.MemFd(input.Mem)
 	} else {
 		memDev = drvOpt.MemDev(input.Mem)
 	}
@@ -226,7 +231,7 @@ func GenerateStartOptions(
 
 	// serial device
 	if input.EnableSerialDevice {
-		opts = append(opts, drvOpt.SerialDevice()...)
+		opts = append(opts, drvOpt.SerialDevice(input.SerialLogPath)...)
 	}
 
 	// migrate options
@@ -274,6 +279,10 @@ func generateDisksOptions(drvOpt QemuOptions, disks []api.GuestdiskJsonDesc, pci
 				firstDriver[driver] = true
 			}
 		}
+		if isVhostUserDisk(disk) {
+			opts = append(opts, getVhostUserDiskOptions(drvOpt, disk, pciBus, isVdiSpice)...)
+			continue
+		}
 		opts = append(opts,
 			getDiskDriveOption(drvOpt, disk, isArm, isEncrypt),
 			getDiskDeviceOption(drvOpt, disk, isArm, pciBus, isVdiSpice),
@@ -282,6 +291,39 @@ func generateDisksOptions(drvOpt QemuOptions, disks []api.GuestdiskJsonDesc, pci
 	return opts
 }
 
+func isVhostUserDisk(disk api.GuestdiskJsonDesc) bool {
+	return disk.StorageType == api.STORAGE_SPDK
+}
+
+func hasVhostUserDisk(disks []api.GuestdiskJsonDesc) bool {
+	for _, disk := range disks {
+		if isVhostUserDisk(disk) {
+			return true
+		}
+	}
+	return false
+}
+
+// getVhostUserDiskOptions connects disk to vhost-user-blk controller,
+// $DISK_N is the vhost socket path of the controller
+func getVhostUserDiskOptions(drvOpt QemuOptions, disk api.GuestdiskJsonDesc, pciBus string, isVdiSpice bool) []string {
+	diskIndex := disk.Index
+	numQueues := disk.NumQueues
+	if numQueues == 0 {
+		numQueues = 4
+	}
+	chardev := fmt.Sprintf("char_drive_%d", diskIndex)
+	opt := "vhost-user-blk-pci"
+	opt += fmt.Sprintf(",chardev=%s", chardev)
+	opt += fmt.Sprintf(",bus=%s,addr=0x%x", pciBus, GetDiskAddr(int(diskIndex), isVdiSpice))
+	opt += fmt.Sprintf(",num-queues=%d", numQueues)
+	opt += fmt.Sprintf(",id=drive_%d", diskIndex)
+	return []string{
+		drvOpt.Chardev(fmt.Sprintf("socket,path=$DISK_%d", diskIndex), chardev, ""),
+		drvOpt.Device(opt),
+	}
+}
+
 func getDiskDriveOption(drvOpt QemuOptions, disk api.GuestdiskJsonDesc, isArm bool, isEncrypt bool) string {
 	format := disk.Format
 	diskIndex := disk.Index
@@ -507,7 +549,7 @@ func getRNGRandomOptions(drvOpt QemuOptions) []string {
 func getMigrateOptions(drvOpt QemuOptions, input *GenerateStartOptionsInput) []string {
 	opts := []string{}
 	if input.NeedMigrate {
-		if input.LiveMigrateUseTLS {
+		if input.LiveMigrateUseTLS || input.LiveMigrateUseMultifd {
 			opts = append(opts, fmt.Sprintf("-incoming defer"))
 		} else {
 			opts = append(opts, fmt.Sprintf("-incoming tcp:0:%d", input.LiveMigratePort))
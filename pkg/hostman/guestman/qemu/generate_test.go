@@ -15,11 +15,14 @@
 package qemu
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	"yunion.io/x/log"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
 )
 
 func TestGenerateStartCommand(t *testing.T) {
@@ -42,3 +45,40 @@ func TestGenerateStartCommand(t *testing.T) {
 	log.Errorf("cmd: %s", cmd)
 	log.Errorf("error: %s", err)
 }
+
+func TestGenerateVhostUserDiskOptions(t *testing.T) {
+	input := &GenerateStartOptionsInput{
+		QemuVersion: Version_2_12_1,
+		QemuArch:    Arch_x86_64,
+		UUID:        "uuid-xxxx-xxxx",
+		Mem:         1024,
+		Cpu:         2,
+		Name:        "test-vm",
+		OsName:      OS_NAME_LINUX,
+		HomeDir:     "/opt/cloud/workspace/servers/sid",
+		PidFilePath: "/opt/cloud/workspace/servers/sid/pid",
+		Disks: []api.GuestdiskJsonDesc{
+			{
+				Index:       0,
+				Driver:      DISK_DRIVER_VIRTIO,
+				StorageType: api.STORAGE_SPDK,
+			},
+		},
+	}
+	cmd, err := GenerateStartOptions(input)
+	if err != nil {
+		t.Fatalf("GenerateStartOptions: %v", err)
+	}
+	for _, want := range []string{
+		"-object memory-backend-memfd,id=mem,size=1024M,share=on",
+		"-chardev socket,path=$DISK_0,id=char_drive_0",
+		"-device vhost-user-blk-pci,chardev=char_drive_0,",
+	} {
+		if !strings.Contains(cmd, want) {
+			t.Errorf("missing %q in %s", want, cmd)
+		}
+	}
+	if strings.Contains(cmd, "file=$DISK_0") {
+		t.Errorf("unexpected drive option for vhost-user disk: %s", cmd)
+	}
+}
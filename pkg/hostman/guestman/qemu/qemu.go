@@ -95,6 +95,7 @@ type QemuOptions interface {
 	Memory(sizeMB uint64) string
 	MemPath(sizeMB uint64, p string) string
 	MemDev(sizeMB uint64) string
+	MemFd(sizeMB uint64) string
 	Boot(order string, enableMenu bool) string
 	BIOS(file string) string
 	Device(devStr string) string
@@ -110,7 +111,7 @@ type QemuOptions interface {
 	VNC(port uint, usePasswd bool) string
 	VGA(vType string, alterOpt string) string
 	Cdrom(cdromPath string, osName string, isQ35 bool, disksLen int) []string
-	SerialDevice() []string
+	SerialDevice(logPath string) []string
 	QGA(homeDir string) []string
 	PvpanicDevice() string
 }
@@ -248,6 +249,11 @@ func (o baseOptions) MemDev(sizeMB uint64) string {
 	return fmt.Sprintf("-object memory-backend-ram,id=mem,size=%dM -numa node,memdev=mem", sizeMB)
 }
 
+// MemFd shares guest memory with vhost-user backends without hugepages
+func (o baseOptions) MemFd(sizeMB uint64) string {
+	return fmt.Sprintf("-object memory-backend-memfd,id=mem,size=%dM,share=on -numa node,memdev=mem", sizeMB)
+}
+
 func (o baseOptions) Boot(order string, enableMenu bool) string {
 	opt := "-boot order=" + order
 	if enableMenu {
@@ -432,9 +438,14 @@ func (o baseOptions_x86_64) Cdrom(cdromPath string, osName string, isQ35 bool, d
 	return opts
 }
 
-func (o baseOptions_x86_64) SerialDevice() []string {
+func (o baseOptions_x86_64) SerialDevice(logPath string) []string {
+	chardev := o.Chardev("pty", "charserial0", "")
+	if len(logPath) > 0 {
+		// qemu keeps all serial output in logfile even no one connects to the pty
+		chardev = fmt.Sprintf("%s,logfile=%s,logappend=on", chardev, logPath)
+	}
 	return []string{
-		o.Chardev("pty", "charserial0", ""),
+		chardev,
 		o.Device("isa-serial,chardev=charserial0,id=serial0"),
 	}
 }
@@ -514,7 +525,7 @@ func (o baseOptions_aarch64) Cdrom(cdromPath string, osName string, isQ35 bool,
 	return opts
 }
 
-func (o baseOptions_aarch64) SerialDevice() []string {
+func (o baseOptions_aarch64) SerialDevice(_ string) []string {
 	return nil
 }
 
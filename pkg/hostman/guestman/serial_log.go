@@ -0,0 +1,290 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guestman
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/hostman/options"
+	"yunion.io/x/onecloud/pkg/util/fileutils2"
+)
+
+const (
+	SERIAL_LOG_FILE = "serial.log"
+
+	serialLogFollowInterval = time.Second
+)
+
+func (s *SKVMGuestInstance) getSerialLogPath() string {
+	return path.Join(s.HomeDir(), SERIAL_LOG_FILE)
+}
+
+func getSerialLogBackupPath(logPath string, idx int) string {
+	return fmt.Sprintf("%s.%d", logPath, idx)
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// rotateSerialLog copies serial log to backups and truncates it when exceeding maxSize,
+// qemu opens the log file in append mode so it keeps writing to the truncated file
+func rotateSerialLog(logPath string, maxSize int64, backupCount int) error {
+	fi, err := os.Stat(logPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "stat %s", logPath)
+	}
+	if fi.Size() <= maxSize {
+		return nil
+	}
+	if backupCount > 0 {
+		for i := backupCount - 1; i > 0; i-- {
+			src := getSerialLogBackupPath(logPath, i)
+			if !fileutils2.Exists(src) {
+				continue
+			}
+			if err := os.Rename(src, getSerialLogBackupPath(logPath, i+1)); err != nil {
+				return errors.Wrapf(err, "rename %s", src)
+			}
+		}
+		if err := copyFile(logPath, getSerialLogBackupPath(logPath, 1)); err != nil {
+			return errors.Wrapf(err, "backup %s", logPath)
+		}
+	}
+	if err := os.Truncate(logPath, 0); err != nil {
+		return errors.Wrapf(err, "truncate %s", logPath)
+	}
+	return nil
+}
+
+// readSerialLog returns the last tailBytes of serial log including the latest backup,
+// the whole content is returned if tailBytes is not positive
+func readSerialLog(logPath string, tailBytes int64) ([]byte, error) {
+	data := []byte{}
+	for _, fn := range []string{getSerialLogBackupPath(logPath, 1), logPath} {
+		if !fileutils2.Exists(fn) {
+			continue
+		}
+		content, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read %s", fn)
+		}
+		data = append(data, content...)
+	}
+	if tailBytes > 0 && int64(len(data)) > tailBytes {
+		data = data[int64(len(data))-tailBytes:]
+	}
+	return data, nil
+}
+
+// followSerialLog writes data appended to serial log since offset until ctx done
+func followSerialLog(ctx context.Context, logPath string, offset int64, w io.Writer, flush func()) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(serialLogFollowInterval):
+		}
+		fi, err := os.Stat(logPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return errors.Wrapf(err, "stat %s", logPath)
+		}
+		if fi.Size() < offset {
+			// log rotated
+			offset = 0
+		}
+		if fi.Size() == offset {
+			continue
+		}
+		f, err := os.Open(logPath)
+		if err != nil {
+			return errors.Wrapf(err, "open %s", logPath)
+		}
+		n, err := io.Copy(w, io.NewSectionReader(f, offset, fi.Size()-offset))
+		f.Close()
+		if err != nil {
+			return errors.Wrap(err, "write serial log")
+		}
+		offset += n
+		if flush != nil {
+			flush()
+		}
+	}
+}
+
+func (s *SKVMGuestInstance) ReadSerialLog(tailBytes int64) ([]byte, error) {
+	return readSerialLog(s.getSerialLogPath(), tailBytes)
+}
+
+// FollowSerialLog writes the last tailBytes of serial log and then keeps streaming new output
+func (s *SKVMGuestInstance) FollowSerialLog(ctx context.Context, tailBytes int64, w io.Writer, flush func()) error {
+	logPath := s.getSerialLogPath()
+	var offset int64
+	if fi, err := os.Stat(logPath); err == nil {
+		offset = fi.Size()
+	}
+	data, err := readSerialLog(logPath, tailBytes)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return errors.Wrap(err, "write serial log")
+	}
+	if flush != nil {
+		flush()
+	}
+	return followSerialLog(ctx, logPath, offset, w, flush)
+}
+
+// sSerialLogRotator rotates serial logs of guests under serversPath as soon as
+// they are written beyond maxSize
+type sSerialLogRotator struct {
+	serversPath string
+	maxSize     int64
+	backupCount int
+
+	watcher *fsnotify.Watcher
+}
+
+func newSerialLogRotator(serversPath string, maxSize int64, backupCount int) (*sSerialLogRotator, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "new watcher")
+	}
+	r := &sSerialLogRotator{
+		serversPath: filepath.Clean(serversPath),
+		maxSize:     maxSize,
+		backupCount: backupCount,
+		watcher:     watcher,
+	}
+	// home dirs of guests created later are watched on their creation
+	err = watcher.Add(r.serversPath)
+	if err != nil {
+		watcher.Close()
+		return nil, errors.Wrapf(err, "watch %s", r.serversPath)
+	}
+	fis, err := ioutil.ReadDir(r.serversPath)
+	if err != nil {
+		watcher.Close()
+		return nil, errors.Wrapf(err, "read dir %s", r.serversPath)
+	}
+	for _, fi := range fis {
+		if fi.IsDir() {
+			r.watchGuestDir(path.Join(r.serversPath, fi.Name()))
+		}
+	}
+	return r, nil
+}
+
+func (r *sSerialLogRotator) watchGuestDir(dir string) {
+	err := r.watcher.Add(dir)
+	if err != nil {
+		log.Warningf("watch serial log in %s: %v", dir, err)
+		return
+	}
+	// log written before the dir is watched
+	r.rotate(path.Join(dir, SERIAL_LOG_FILE))
+}
+
+func (r *sSerialLogRotator) rotate(logPath string) {
+	err := rotateSerialLog(logPath, r.maxSize, r.backupCount)
+	if err != nil {
+		log.Warningf("rotate serial log %s: %v", logPath, err)
+	}
+}
+
+func (r *sSerialLogRotator) run() {
+	for {
+		select {
+		case ev, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			switch {
+			case filepath.Dir(ev.Name) == r.serversPath:
+				if ev.Op&fsnotify.Create == 0 {
+					continue
+				}
+				if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+					r.watchGuestDir(ev.Name)
+				}
+			case filepath.Base(ev.Name) == SERIAL_LOG_FILE:
+				if ev.Op&(fsnotify.Create|fsnotify.Write) != 0 {
+					r.rotate(ev.Name)
+				}
+			}
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Warningf("serial log watcher: %v", err)
+		}
+	}
+}
+
+func (r *sSerialLogRotator) Close() error {
+	return r.watcher.Close()
+}
+
+func (m *SGuestManager) StartSerialLogRotator() {
+	if !options.HostOptions.EnableSerialLog {
+		return
+	}
+	maxSize := int64(options.HostOptions.SerialLogMaxSizeKb) * 1024
+	rotator, err := newSerialLogRotator(m.ServersPath, maxSize, options.HostOptions.SerialLogBackupCount)
+	if err != nil {
+		log.Errorf("Start serial log rotator failed: %v", err)
+		return
+	}
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				debug.PrintStack()
+				log.Errorf("Serial log rotator failed %s", r)
+			}
+		}()
+		rotator.run()
+	}()
+}
@@ -0,0 +1,159 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guestman
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func writeSerialLog(t *testing.T, fn string, data []byte) {
+	f, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("open %s: %v", fn, err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		t.Fatalf("write %s: %v", fn, err)
+	}
+}
+
+func TestRotateSerialLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "serial-log")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	logPath := path.Join(dir, SERIAL_LOG_FILE)
+
+	if err := rotateSerialLog(logPath, 4, 2); err != nil {
+		t.Fatalf("rotate missing log: %v", err)
+	}
+	writeSerialLog(t, logPath, []byte("abcd"))
+	if err := rotateSerialLog(logPath, 4, 2); err != nil {
+		t.Fatalf("rotate log within size: %v", err)
+	}
+	if data, _ := ioutil.ReadFile(logPath); string(data) != "abcd" {
+		t.Errorf("log within size rotated, got %q", data)
+	}
+
+	// every rotation shifts backups and the oldest beyond backupCount is dropped
+	for _, content := range []string{"first", "second", "third"} {
+		writeSerialLog(t, logPath, []byte(content))
+		if err := rotateSerialLog(logPath, 4, 2); err != nil {
+			t.Fatalf("rotate %s: %v", content, err)
+		}
+		if data, _ := ioutil.ReadFile(logPath); len(data) != 0 {
+			t.Errorf("rotate %s: log not truncated, got %q", content, data)
+		}
+	}
+	for idx, want := range map[int]string{1: "third", 2: "second"} {
+		if data, _ := ioutil.ReadFile(getSerialLogBackupPath(logPath, idx)); string(data) != want {
+			t.Errorf("backup %d: want %q, got %q", idx, want, data)
+		}
+	}
+	if _, err := os.Stat(getSerialLogBackupPath(logPath, 3)); !os.IsNotExist(err) {
+		t.Errorf("backup 3 should not exist: %v", err)
+	}
+}
+
+func TestReadSerialLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "serial-log")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	logPath := path.Join(dir, SERIAL_LOG_FILE)
+
+	if data, err := readSerialLog(logPath, 0); err != nil || len(data) != 0 {
+		t.Fatalf("read missing log: %q %v", data, err)
+	}
+	writeSerialLog(t, getSerialLogBackupPath(logPath, 2), []byte("old "))
+	writeSerialLog(t, getSerialLogBackupPath(logPath, 1), []byte("backup "))
+	writeSerialLog(t, logPath, []byte("current"))
+	cases := []struct {
+		tail int64
+		want string
+	}{
+		{0, "backup current"},
+		{-1, "backup current"},
+		{4, "rent"},
+		{10, "up current"},
+		{100, "backup current"},
+	}
+	for _, c := range cases {
+		data, err := readSerialLog(logPath, c.tail)
+		if err != nil {
+			t.Fatalf("tail %d: %v", c.tail, err)
+		}
+		if string(data) != c.want {
+			t.Errorf("tail %d: want %q, got %q", c.tail, c.want, data)
+		}
+	}
+}
+
+func TestSerialLogRotator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "serial-log")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	existing := path.Join(dir, "existing")
+	if err := os.Mkdir(existing, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeSerialLog(t, path.Join(existing, SERIAL_LOG_FILE), bytes.Repeat([]byte("a"), 32))
+
+	rotator, err := newSerialLogRotator(dir, 16, 1)
+	if err != nil {
+		t.Fatalf("newSerialLogRotator: %v", err)
+	}
+	defer rotator.Close()
+	go rotator.run()
+
+	waitRotated := func(logPath string) {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if fi, err := os.Stat(logPath); err == nil && fi.Size() == 0 {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("%s not rotated", logPath)
+	}
+	waitRotated(path.Join(existing, SERIAL_LOG_FILE))
+
+	created := path.Join(dir, "created")
+	if err := os.Mkdir(created, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	// wait for the new dir being watched
+	time.Sleep(100 * time.Millisecond)
+	logPath := path.Join(created, SERIAL_LOG_FILE)
+	writeSerialLog(t, logPath, bytes.Repeat([]byte("b"), 8))
+	time.Sleep(100 * time.Millisecond)
+	if fi, err := os.Stat(logPath); err != nil || fi.Size() != 8 {
+		t.Fatalf("log within size rotated: %v", err)
+	}
+	writeSerialLog(t, logPath, bytes.Repeat([]byte("b"), 16))
+	waitRotated(logPath)
+	if data, _ := ioutil.ReadFile(getSerialLogBackupPath(logPath, 1)); len(data) != 24 {
+		t.Errorf("backup of rotated log: want 24 bytes, got %d", len(data))
+	}
+}
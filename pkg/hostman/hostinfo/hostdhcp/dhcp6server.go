@@ -0,0 +1,130 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostdhcp
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket/layers"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/types"
+	guestman "yunion.io/x/onecloud/pkg/hostman/guestman/types"
+	"yunion.io/x/onecloud/pkg/hostman/options"
+	"yunion.io/x/onecloud/pkg/util/dhcp"
+)
+
+// SGuestDHCP6Server assigns guest IPv6 address by stateful DHCPv6. Router
+// advertisements sent only tell guests to use DHCPv6 and the on-link prefix,
+// the default route is still advertised by the physical gateway
+type SGuestDHCP6Server struct {
+	server *dhcp.DHCP6Server
+
+	iface string
+}
+
+func NewGuestDHCP6Server(iface string) (*SGuestDHCP6Server, error) {
+	server, err := dhcp.NewDHCP6Server(iface)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewDHCP6Server")
+	}
+	return &SGuestDHCP6Server{
+		server: server,
+		iface:  iface,
+	}, nil
+}
+
+func (s *SGuestDHCP6Server) Start(blocking bool) {
+	log.Infof("SGuestDHCP6Server starting ...")
+	serve := func() {
+		err := s.server.ListenAndServe(s)
+		if err != nil {
+			log.Errorf("DHCPv6 serve error: %s", err)
+		}
+	}
+	if blocking {
+		serve()
+	} else {
+		go serve()
+	}
+}
+
+func (s *SGuestDHCP6Server) getGuestNic(mac net.HardwareAddr) (jsonutils.JSONObject, *types.SServerNic) {
+	if guestman.GuestDescGetter == nil {
+		return nil, nil
+	}
+	var (
+		macStr      = mac.String()
+		ip, port    = "", ""
+		isCandidate = false
+	)
+	guestDesc, guestNic := guestman.GuestDescGetter.GetGuestNicDesc(macStr, ip, port, s.iface, isCandidate)
+	if guestNic == nil {
+		guestDesc, guestNic = guestman.GuestDescGetter.GetGuestNicDesc(macStr, ip, port, s.iface, !isCandidate)
+	}
+	if guestNic == nil || jsonutils.QueryBoolean(guestNic, "virtual", false) {
+		return nil, nil
+	}
+	var nicdesc = new(types.SServerNic)
+	if err := guestNic.Unmarshal(nicdesc); err != nil {
+		log.Errorln(err)
+		return nil, nil
+	}
+	if len(nicdesc.Ip6) == 0 || nicdesc.Masklen6 == 0 {
+		return nil, nil
+	}
+	return guestDesc, nicdesc
+}
+
+func (s *SGuestDHCP6Server) ServeDHCPv6(pkt *layers.DHCPv6, srcMac net.HardwareAddr) (*dhcp.DHCPv6ResponseConfig, error) {
+	_, nicdesc := s.getGuestNic(srcMac)
+	if nicdesc == nil {
+		return nil, nil
+	}
+	conf := &dhcp.DHCPv6ResponseConfig{
+		ClientIP:          net.ParseIP(nicdesc.Ip6),
+		Domain:            nicdesc.Domain,
+		PreferredLifetime: time.Duration(options.HostOptions.DhcpRenewalTime) * time.Second,
+		ValidLifetime:     time.Duration(options.HostOptions.DhcpLeaseTime) * time.Second,
+	}
+	if len(nicdesc.Dns6) > 0 {
+		for _, dns := range strings.Split(nicdesc.Dns6, ",") {
+			conf.DNSServers = append(conf.DNSServers, net.ParseIP(dns))
+		}
+	}
+	return conf, nil
+}
+
+func (s *SGuestDHCP6Server) ServeRouterSolicitation(srcMac net.HardwareAddr) (*dhcp.RouterAdvertConfig, error) {
+	_, nicdesc := s.getGuestNic(srcMac)
+	if nicdesc == nil {
+		return nil, nil
+	}
+	conf := &dhcp.RouterAdvertConfig{
+		Prefix:    net.ParseIP(nicdesc.Ip6),
+		PrefixLen: uint8(nicdesc.Masklen6),
+		Managed:   true,
+		Other:     true,
+	}
+	if nicdesc.Mtu > 0 {
+		conf.MTU = uint32(nicdesc.Mtu)
+	}
+	return conf, nil
+}
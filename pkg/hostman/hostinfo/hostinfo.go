@@ -75,6 +75,9 @@ type SHostInfo struct {
 	saved  bool
 	pinger *SHostPingTask
 
+	secgroupRuleStats *SSecgroupRuleStatsTask
+	secgroupFlowLog   *SSecgroupFlowLogTask
+
 	Cpu     *SCPUInfo
 	Mem     *SMemory
 	sysinfo *SSysInfo
@@ -129,6 +132,9 @@ func (h *SHostInfo) GetBridgeDev(bridge string) hostbridge.IBridgeDriver {
 func (h *SHostInfo) StartDHCPServer() {
 	for _, nic := range h.Nics {
 		nic.dhcpServer.Start(false)
+		if nic.dhcp6Server != nil {
+			nic.dhcp6Server.Start(false)
+		}
 	}
 }
 
@@ -1610,7 +1616,7 @@ func (h *SHostInfo) probeSyncIsolatedDevicesStep() {
 }
 
 func (h *SHostInfo) probeSyncIsolatedDevices() (*jsonutils.JSONArray, error) {
-	if err := h.IsolatedDeviceMan.ProbePCIDevices(options.HostOptions.DisableGPU, options.HostOptions.DisableUSB); err != nil {
+	if err := h.IsolatedDeviceMan.ProbePCIDevices(options.HostOptions.DisableGPU, options.HostOptions.DisableUSB, options.HostOptions.SRIOVNics, options.HostOptions.MdevTypes); err != nil {
 		return nil, errors.Wrap(err, "ProbePCIDevices")
 	}
 
@@ -1642,6 +1648,11 @@ func (h *SHostInfo) probeSyncIsolatedDevices() (*jsonutils.JSONArray, error) {
 		if obj, err := isolated_device.SyncDeviceInfo(h.GetSession(), h.HostId, dev); err != nil {
 			return nil, errors.Wrapf(err, "Sync device %s", dev)
 		} else {
+			// remember id of newly created device, mdev instance is named by it
+			info := isolated_device.CloudDeviceInfo{}
+			if err := obj.Unmarshal(&info); err == nil {
+				dev.SetDeviceInfo(info)
+			}
 			updateDevs.Add(obj)
 		}
 	}
@@ -1701,6 +1712,7 @@ func (h *SHostInfo) onSucc() {
 			panic(err.Error())
 		}
 		h.StartPinger()
+		h.StartSecgroupTasks()
 		if h.registerCallback != nil {
 			h.registerCallback()
 		}
@@ -1718,6 +1730,22 @@ func (h *SHostInfo) StartPinger() {
 	}
 }
 
+// StartSecgroupTasks starts collecting security group rule hit counters and
+// shipping flow logs.  Only guests attached to ovn are covered
+func (h *SHostInfo) StartSecgroupTasks() {
+	if !HasOvnSupport() || options.HostOptions.BridgeDriver != hostbridge.DRV_OPEN_VSWITCH {
+		return
+	}
+	h.secgroupRuleStats = NewSecgroupRuleStatsTask(options.HostOptions.SecgroupRuleStatsInterval)
+	if h.secgroupRuleStats != nil {
+		go h.secgroupRuleStats.Start()
+	}
+	h.secgroupFlowLog = NewSecgroupFlowLogTask(options.HostOptions.SecgroupFlowLogShipInterval)
+	if h.secgroupFlowLog != nil {
+		go h.secgroupFlowLog.Start()
+	}
+}
+
 func (h *SHostInfo) save() error {
 	if h.saved {
 		return nil
@@ -1783,6 +1811,12 @@ func (h *SHostInfo) stop() {
 	if h.pinger != nil {
 		h.pinger.Stop()
 	}
+	if h.secgroupRuleStats != nil {
+		h.secgroupRuleStats.Stop()
+	}
+	if h.secgroupFlowLog != nil {
+		h.secgroupFlowLog.Stop()
+	}
 	for _, nic := range h.Nics {
 		nic.ExitCleanup()
 	}
@@ -181,6 +181,8 @@ type SNIC struct {
 	Bandwidth  int
 	BridgeDev  hostbridge.IBridgeDriver
 	dhcpServer *hostdhcp.SGuestDHCPServer
+
+	dhcp6Server *hostdhcp.SGuestDHCP6Server
 }
 
 func (n *SNIC) EnableDHCPRelay() bool {
@@ -304,6 +306,14 @@ func NewNIC(desc string) (*SNIC, error) {
 	if err != nil {
 		return nil, err
 	}
+	if options.HostOptions.EnableDhcp6 {
+		nic.dhcp6Server, err = hostdhcp.NewGuestDHCP6Server(nic.Bridge)
+		if err != nil {
+			// guest ipv6 is optional, do not block host startup
+			log.Errorf("create dhcpv6 server on %s: %v", nic.Bridge, err)
+			nic.dhcp6Server = nil
+		}
+	}
 	// dhcp server start after guest manager init
 	return nic, nil
 }
@@ -0,0 +1,739 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostinfo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
+	loggerapi "yunion.io/x/onecloud/pkg/apis/logger"
+	"yunion.io/x/onecloud/pkg/hostman/hostutils"
+	"yunion.io/x/onecloud/pkg/hostman/options"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	modules "yunion.io/x/onecloud/pkg/mcclient/modules/compute"
+	loggermodules "yunion.io/x/onecloud/pkg/mcclient/modules/logger"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+	"yunion.io/x/onecloud/pkg/util/procutils"
+)
+
+// Security group rules of kvm guests are enforced as ovn acls, named after
+// rule id by vpcagent.  Hit counters are collected from openflow flows of the
+// integration bridge, traced back to acls by flow cookie, which is the prefix
+// of logical flow uuid, and stage-hint of logical flow, which is the prefix
+// of acl uuid.
+//
+// Flow logs are acl logs written by ovn-controller, rate limited by meter of
+// the flow log.
+//
+// Guests on classic ovs bridges are not covered.  Their flows are programmed
+// by sdnagent, which is not part of this tree, and carry no reference to
+// security group rules to trace back with
+
+type SSecgroupRuleStatsTask struct {
+	interval int // second
+	running  bool
+
+	cookies sSecgroupRuleCookies
+}
+
+func NewSecgroupRuleStatsTask(interval int) *SSecgroupRuleStatsTask {
+	if interval <= 0 || options.HostOptions.OvnNorthDatabase == "" {
+		return nil
+	}
+	return &SSecgroupRuleStatsTask{
+		interval: interval,
+		running:  true,
+	}
+}
+
+func (t *SSecgroupRuleStatsTask) Start() {
+	for {
+		if !t.running {
+			return
+		}
+		if err := t.report(context.Background()); err != nil {
+			log.Errorf("report secgroup rule stats: %v", err)
+		}
+		time.Sleep(time.Duration(t.interval) * time.Second)
+	}
+}
+
+func (t *SSecgroupRuleStatsTask) Stop() {
+	if t.running {
+		t.running = false
+	}
+}
+
+func (t *SSecgroupRuleStatsTask) report(ctx context.Context) error {
+	stats, err := t.collect()
+	if err != nil {
+		return err
+	}
+	input := computeapi.SecgroupRuleReportStatsInput{
+		HostId: Instance().GetHostId(),
+		Stats:  stats,
+	}
+	s := hostutils.GetComputeSession(ctx)
+	if _, err := modules.SecGroupRules.PerformClassAction(s, "report-stats", jsonutils.Marshal(input)); err != nil {
+		return errors.Wrap(err, "report-stats")
+	}
+	return nil
+}
+
+// collect returns counters of rules with flows on this host
+func (t *SSecgroupRuleStatsTask) collect() ([]computeapi.SecgroupRuleStat, error) {
+	opts := &options.HostOptions
+	output, err := procutils.NewCommand("ovs-ofctl", "-O", "OpenFlow13",
+		"dump-flows", opts.OvnIntegrationBridge).Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "dump flows: %s", output)
+	}
+	flowStats := parseOfctlFlowStats(string(output))
+	cookies := make([]string, 0, len(flowStats))
+	for cookie := range flowStats {
+		cookies = append(cookies, cookie)
+	}
+	cookieRules, err := t.cookies.resolve(cookies, fetchOvnLflowStageHints, fetchOvnAclNames)
+	if err != nil {
+		return nil, err
+	}
+
+	ruleStats := map[string]*computeapi.SecgroupRuleStat{}
+	for cookie, ruleId := range cookieRules {
+		flowStat := flowStats[cookie]
+		ruleStat, ok := ruleStats[ruleId]
+		if !ok {
+			ruleStat = &computeapi.SecgroupRuleStat{RuleId: ruleId}
+			ruleStats[ruleId] = ruleStat
+		}
+		ruleStat.Packets += flowStat.Packets
+		ruleStat.Bytes += flowStat.Bytes
+	}
+	r := make([]computeapi.SecgroupRuleStat, 0, len(ruleStats))
+	for _, ruleStat := range ruleStats {
+		r = append(r, *ruleStat)
+	}
+	return r, nil
+}
+
+func fetchOvnLflowStageHints() (map[string]string, error) {
+	output, err := procutils.NewCommand("ovn-sbctl", "--db="+options.HostOptions.OvnSouthDatabase,
+		"--format=json", "--columns=_uuid,external_ids", "list", "Logical_Flow").Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "list logical flow: %s", output)
+	}
+	return parseOvnLflowStageHints(string(output))
+}
+
+func fetchOvnAclNames() (map[string]string, error) {
+	output, err := procutils.NewCommand("ovn-nbctl", "--db="+options.HostOptions.OvnNorthDatabase,
+		"--format=json", "--columns=_uuid,name", "find", "ACL", "name!=[]").Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "find acl: %s", output)
+	}
+	return parseOvnAclNames(string(output))
+}
+
+// sSecgroupRuleCookies caches mapping from openflow cookie to rule id.
+// Logical flows and acls do not change once created, as vpcagent recreates
+// acls on rule change, so central databases are only queried when unknown
+// cookies or stage hints show up in local flows.  Cookies and stage hints
+// known to map to nothing are cached as empty string
+type sSecgroupRuleCookies struct {
+	stageHints map[string]string
+	aclNames   map[string]string
+}
+
+// resolve returns rule ids of cookies.  Cookies of flows not of named acls
+// are left out
+func (c *sSecgroupRuleCookies) resolve(
+	cookies []string,
+	fetchStageHints func() (map[string]string, error),
+	fetchAclNames func() (map[string]string, error),
+) (map[string]string, error) {
+	stageHints, err := resolveCached(c.stageHints, cookies, fetchStageHints)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolve stage hints")
+	}
+	c.stageHints = stageHints
+
+	hints := make([]string, 0, len(stageHints))
+	for _, hint := range stageHints {
+		if hint != "" {
+			hints = append(hints, hint)
+		}
+	}
+	aclNames, err := resolveCached(c.aclNames, hints, fetchAclNames)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolve acl names")
+	}
+	c.aclNames = aclNames
+
+	r := map[string]string{}
+	for cookie, hint := range stageHints {
+		if ruleId := aclNames[hint]; hint != "" && ruleId != "" {
+			r[cookie] = ruleId
+		}
+	}
+	return r, nil
+}
+
+// resolveCached returns mapping of keys, fetching all with fetch only if any
+// is not in cache.  Keys no longer in use are dropped from the result
+func resolveCached(cache map[string]string, keys []string, fetch func() (map[string]string, error)) (map[string]string, error) {
+	src := cache
+	for _, k := range keys {
+		if _, ok := cache[k]; !ok {
+			fetched, err := fetch()
+			if err != nil {
+				return nil, err
+			}
+			src = fetched
+			break
+		}
+	}
+	r := make(map[string]string, len(keys))
+	for _, k := range keys {
+		r[k] = src[k]
+	}
+	return r, nil
+}
+
+// ovsdbListRows parses output of "--format=json list" of ovn-nbctl and
+// ovn-sbctl
+func ovsdbListRows(output string) ([][]jsonutils.JSONObject, error) {
+	obj, err := jsonutils.ParseString(output)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse ovsdb json output")
+	}
+	data, err := obj.GetArray("data")
+	if err != nil {
+		return nil, errors.Wrap(err, "get data")
+	}
+	rows := make([][]jsonutils.JSONObject, 0, len(data))
+	for _, row := range data {
+		cols, err := row.GetArray()
+		if err != nil {
+			return nil, errors.Wrap(err, "get row columns")
+		}
+		rows = append(rows, cols)
+	}
+	return rows, nil
+}
+
+// ovsdbAtom returns value of ["uuid", "xx"] pair, or plain string.  Empty
+// optional column, ["set", []], is returned as empty string
+func ovsdbAtom(obj jsonutils.JSONObject) string {
+	if s, ok := obj.(*jsonutils.JSONString); ok {
+		return s.Value()
+	}
+	pair, err := obj.GetArray()
+	if err != nil || len(pair) != 2 {
+		return ""
+	}
+	if s, ok := pair[1].(*jsonutils.JSONString); ok {
+		return s.Value()
+	}
+	return ""
+}
+
+// ovsdbMap returns value of ["map", [["k", "v"], ...]]
+func ovsdbMap(obj jsonutils.JSONObject) map[string]string {
+	r := map[string]string{}
+	pair, err := obj.GetArray()
+	if err != nil || len(pair) != 2 {
+		return r
+	}
+	kvs, err := pair[1].GetArray()
+	if err != nil {
+		return r
+	}
+	for _, kv := range kvs {
+		kva, err := kv.GetArray()
+		if err != nil || len(kva) != 2 {
+			continue
+		}
+		k, _ := kva[0].GetString()
+		v, _ := kva[1].GetString()
+		r[k] = v
+	}
+	return r
+}
+
+// parseOvnAclNames returns map from acl uuid prefix to acl name.  Acls
+// without name are skipped
+func parseOvnAclNames(output string) (map[string]string, error) {
+	rows, err := ovsdbListRows(output)
+	if err != nil {
+		return nil, errors.Wrap(err, "acl names")
+	}
+	r := map[string]string{}
+	for _, cols := range rows {
+		if len(cols) != 2 {
+			continue
+		}
+		uuid, name := ovsdbAtom(cols[0]), ovsdbAtom(cols[1])
+		if len(uuid) < 8 || name == "" {
+			continue
+		}
+		r[uuid[:8]] = name
+	}
+	return r, nil
+}
+
+// parseOvnLflowStageHints returns map from logical flow uuid prefix, i.e.
+// openflow cookie, to stage-hint of the logical flow
+func parseOvnLflowStageHints(output string) (map[string]string, error) {
+	rows, err := ovsdbListRows(output)
+	if err != nil {
+		return nil, errors.Wrap(err, "logical flow stage hints")
+	}
+	r := map[string]string{}
+	for _, cols := range rows {
+		if len(cols) != 2 {
+			continue
+		}
+		uuid := ovsdbAtom(cols[0])
+		hint := ovsdbMap(cols[1])["stage-hint"]
+		if len(uuid) < 8 || hint == "" {
+			continue
+		}
+		r[uuid[:8]] = hint
+	}
+	return r, nil
+}
+
+type ofctlFlowStat struct {
+	Packets int64
+	Bytes   int64
+}
+
+// parseOfctlFlowStats sums n_packets and n_bytes of output of ovs-ofctl
+// dump-flows by cookie, formatted as 8 hex digits
+func parseOfctlFlowStats(output string) map[string]*ofctlFlowStat {
+	r := map[string]*ofctlFlowStat{}
+	for _, line := range strings.Split(output, "\n") {
+		var (
+			cookie string
+			stat   ofctlFlowStat
+		)
+		for _, field := range strings.Split(strings.TrimSpace(line), ",") {
+			kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch kv[0] {
+			case "cookie":
+				v, err := strconv.ParseUint(strings.TrimPrefix(kv[1], "0x"), 16, 64)
+				if err == nil && v != 0 {
+					cookie = fmt.Sprintf("%08x", v)
+				}
+			case "n_packets":
+				stat.Packets, _ = strconv.ParseInt(kv[1], 10, 64)
+			case "n_bytes":
+				stat.Bytes, _ = strconv.ParseInt(kv[1], 10, 64)
+			}
+		}
+		if cookie == "" {
+			continue
+		}
+		if s, ok := r[cookie]; ok {
+			s.Packets += stat.Packets
+			s.Bytes += stat.Bytes
+		} else {
+			r[cookie] = &stat
+		}
+	}
+	return r
+}
+
+type aclLogEntry struct {
+	Timestamp time.Time
+	Name      string
+	Verdict   string
+
+	Protocol string
+	DlSrc    string
+	DlDst    string
+	SrcIp    string
+	DstIp    string
+	SrcPort  int
+	DstPort  int
+}
+
+// parseAclLogLine parses acl log line of ovn-controller like the following
+//
+//	2021-04-06T07:58:37.640Z|00039|acl_log(ovn_pinctrl0)|INFO|name="xx", verdict=allow, severity=info: tcp,vlan_tci=0x0000,dl_src=00:22:00:00:00:01,dl_dst=00:22:00:00:00:02,nw_src=10.0.0.1,nw_dst=10.0.0.2,nw_tos=0,nw_ecn=0,nw_ttl=64,tp_src=36452,tp_dst=22,tcp_flags=syn
+func parseAclLogLine(line string) (*aclLogEntry, bool) {
+	parts := strings.SplitN(strings.TrimSpace(line), "|", 5)
+	if len(parts) != 5 || !strings.HasPrefix(parts[2], "acl_log") {
+		return nil, false
+	}
+	msg := strings.SplitN(parts[4], ": ", 2)
+	if len(msg) != 2 {
+		return nil, false
+	}
+	entry := &aclLogEntry{}
+	if t, err := time.Parse("2006-01-02T15:04:05.000Z", parts[0]); err == nil {
+		entry.Timestamp = t
+	} else {
+		entry.Timestamp = time.Now().UTC()
+	}
+	for _, field := range strings.Split(msg[0], ", ") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "name":
+			entry.Name = strings.Trim(kv[1], `"`)
+		case "verdict":
+			entry.Verdict = kv[1]
+		}
+	}
+	for i, field := range strings.Split(msg[1], ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			if i == 0 {
+				entry.Protocol = strings.TrimSuffix(field, "6")
+			}
+			continue
+		}
+		switch kv[0] {
+		case "dl_src":
+			entry.DlSrc = kv[1]
+		case "dl_dst":
+			entry.DlDst = kv[1]
+		case "nw_src", "ipv6_src":
+			entry.SrcIp = kv[1]
+		case "nw_dst", "ipv6_dst":
+			entry.DstIp = kv[1]
+		case "tp_src":
+			entry.SrcPort, _ = strconv.Atoi(kv[1])
+		case "tp_dst":
+			entry.DstPort, _ = strconv.Atoi(kv[1])
+		}
+	}
+	if entry.Name == "" || entry.Verdict == "" {
+		return nil, false
+	}
+	return entry, true
+}
+
+type secgroupFlowLogPort struct {
+	GuestId   string
+	NetworkId string
+	VpcId     string
+}
+
+type SSecgroupFlowLogTask struct {
+	interval int // second
+	running  bool
+
+	offset     int64
+	networkVpc map[string]string
+}
+
+func NewSecgroupFlowLogTask(interval int) *SSecgroupFlowLogTask {
+	if interval <= 0 || options.HostOptions.OvnControllerLogPath == "" {
+		return nil
+	}
+	return &SSecgroupFlowLogTask{
+		interval:   interval,
+		running:    true,
+		offset:     -1,
+		networkVpc: map[string]string{},
+	}
+}
+
+func (t *SSecgroupFlowLogTask) Start() {
+	for {
+		if !t.running {
+			return
+		}
+		if err := t.ship(context.Background()); err != nil {
+			log.Errorf("ship secgroup flow logs: %v", err)
+		}
+		time.Sleep(time.Duration(t.interval) * time.Second)
+	}
+}
+
+func (t *SSecgroupFlowLogTask) Stop() {
+	if t.running {
+		t.running = false
+	}
+}
+
+// readAclLogs reads acl logs appended since last read.  Logs written before
+// the first read are skipped
+func (t *SSecgroupFlowLogTask) readAclLogs() ([]*aclLogEntry, error) {
+	f, err := os.Open(options.HostOptions.OvnControllerLogPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "open ovn-controller log")
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, errors.Wrap(err, "stat ovn-controller log")
+	}
+	if t.offset < 0 || fi.Size() < t.offset {
+		// first read, or log rotated
+		if t.offset < 0 {
+			t.offset = fi.Size()
+			return nil, nil
+		}
+		t.offset = 0
+	}
+	if _, err := f.Seek(t.offset, io.SeekStart); err != nil {
+		return nil, errors.Wrap(err, "seek ovn-controller log")
+	}
+	var (
+		entries []*aclLogEntry
+		r       = bufio.NewReader(f)
+	)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			// leave partial line for next read
+			break
+		}
+		t.offset += int64(len(line))
+		if entry, ok := parseAclLogLine(line); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (t *SSecgroupFlowLogTask) fetchPorts(s *mcclient.ClientSession) (map[string]*secgroupFlowLogPort, error) {
+	params := jsonutils.NewDict()
+	params.Set("host_id", jsonutils.NewString(Instance().GetHostId()))
+	params.Set("scope", jsonutils.NewString("system"))
+	params.Set("limit", jsonutils.NewInt(0))
+	res, err := modules.Servernetworks.List(s, params)
+	if err != nil {
+		return nil, errors.Wrap(err, "list guestnetworks")
+	}
+	ports := map[string]*secgroupFlowLogPort{}
+	for _, obj := range res.Data {
+		gn := computeapi.GuestnetworkDetails{}
+		if err := obj.Unmarshal(&gn); err != nil {
+			return nil, errors.Wrap(err, "unmarshal guestnetwork")
+		}
+		vpcId, ok := t.networkVpc[gn.NetworkId]
+		if !ok {
+			network, err := modules.Networks.Get(s, gn.NetworkId, nil)
+			if err != nil {
+				log.Warningf("get network %s: %v", gn.NetworkId, err)
+				continue
+			}
+			vpcId, _ = network.GetString("vpc_id")
+			t.networkVpc[gn.NetworkId] = vpcId
+		}
+		ports[gn.MacAddr] = &secgroupFlowLogPort{
+			GuestId:   gn.GuestId,
+			NetworkId: gn.NetworkId,
+			VpcId:     vpcId,
+		}
+	}
+	return ports, nil
+}
+
+func fetchSecgroupFlowLogs(s *mcclient.ClientSession) ([]computeapi.SecgroupFlowLogDetails, error) {
+	params := jsonutils.NewDict()
+	params.Set("enabled", jsonutils.JSONTrue)
+	params.Set("scope", jsonutils.NewString("system"))
+	params.Set("limit", jsonutils.NewInt(0))
+	res, err := modules.SecgroupFlowLogs.List(s, params)
+	if err != nil {
+		return nil, errors.Wrap(err, "list secgroup flow logs")
+	}
+	flowLogs := make([]computeapi.SecgroupFlowLogDetails, 0, len(res.Data))
+	for _, obj := range res.Data {
+		flowLog := computeapi.SecgroupFlowLogDetails{}
+		if err := obj.Unmarshal(&flowLog); err != nil {
+			return nil, errors.Wrap(err, "unmarshal secgroup flow log")
+		}
+		flowLogs = append(flowLogs, flowLog)
+	}
+	return flowLogs, nil
+}
+
+// pickSecgroupFlowLog picks the flow log applying to port in the same way as
+// vpcagent does when enabling acl logs
+func pickSecgroupFlowLog(flowLogs []computeapi.SecgroupFlowLogDetails, port *secgroupFlowLogPort, action string) *computeapi.SecgroupFlowLogDetails {
+	var r *computeapi.SecgroupFlowLogDetails
+	for i := range flowLogs {
+		flowLog := &flowLogs[i]
+		if flowLog.VpcId != port.VpcId {
+			continue
+		}
+		if flowLog.NetworkId != "" && flowLog.NetworkId != port.NetworkId {
+			continue
+		}
+		if flowLog.TrafficType != computeapi.SECGROUP_FLOW_LOG_TRAFFIC_ALL && flowLog.TrafficType != action {
+			continue
+		}
+		if r == nil ||
+			flowLog.SampleRate > r.SampleRate ||
+			(flowLog.SampleRate == r.SampleRate && flowLog.Id < r.Id) {
+			r = flowLog
+		}
+	}
+	return r
+}
+
+func (t *SSecgroupFlowLogTask) ship(ctx context.Context) error {
+	aclLogs, err := t.readAclLogs()
+	if err != nil {
+		return err
+	}
+	if len(aclLogs) == 0 {
+		return nil
+	}
+	s := hostutils.GetComputeSession(ctx)
+	flowLogs, err := fetchSecgroupFlowLogs(s)
+	if err != nil {
+		return err
+	}
+	if len(flowLogs) == 0 {
+		return nil
+	}
+	ports, err := t.fetchPorts(s)
+	if err != nil {
+		return err
+	}
+
+	var (
+		hostId       = Instance().GetHostId()
+		entries      = map[string][]loggerapi.SecgroupFlowLogEntry{}
+		flowLogsById = map[string]*computeapi.SecgroupFlowLogDetails{}
+	)
+	for _, aclLog := range aclLogs {
+		var (
+			direction string
+			port      *secgroupFlowLogPort
+			action    = computeapi.SECGROUP_FLOW_LOG_TRAFFIC_ALLOW
+		)
+		if p, ok := ports[aclLog.DlDst]; ok {
+			port, direction = p, "in"
+		} else if p, ok := ports[aclLog.DlSrc]; ok {
+			port, direction = p, "out"
+		} else {
+			continue
+		}
+		if aclLog.Verdict != "allow" {
+			action = computeapi.SECGROUP_FLOW_LOG_TRAFFIC_DENY
+		}
+		flowLog := pickSecgroupFlowLog(flowLogs, port, action)
+		if flowLog == nil {
+			continue
+		}
+		flowLogsById[flowLog.Id] = flowLog
+		entries[flowLog.Id] = append(entries[flowLog.Id], loggerapi.SecgroupFlowLogEntry{
+			Timestamp:     aclLog.Timestamp,
+			FlowLogId:     flowLog.Id,
+			OwnerTenantId: flowLog.ProjectId,
+			OwnerDomainId: flowLog.DomainId,
+			VpcId:         port.VpcId,
+			NetworkId:     port.NetworkId,
+			GuestId:       port.GuestId,
+			HostId:        hostId,
+			RuleId:        aclLog.Name,
+			Direction:     direction,
+			Action:        action,
+			Protocol:      aclLog.Protocol,
+			SrcIp:         aclLog.SrcIp,
+			SrcPort:       aclLog.SrcPort,
+			DstIp:         aclLog.DstIp,
+			DstPort:       aclLog.DstPort,
+		})
+	}
+
+	var loggerEntries []loggerapi.SecgroupFlowLogEntry
+	for flowLogId, flowLogEntries := range entries {
+		flowLog := flowLogsById[flowLogId]
+		switch flowLog.Sink {
+		case computeapi.SECGROUP_FLOW_LOG_SINK_BUCKET:
+			if err := shipSecgroupFlowLogToBucket(ctx, s, flowLog, hostId, flowLogEntries); err != nil {
+				log.Errorf("ship flow log %s to bucket %s: %v", flowLog.Id, flowLog.BucketId, err)
+			}
+		default:
+			loggerEntries = append(loggerEntries, flowLogEntries...)
+		}
+	}
+	if len(loggerEntries) > 0 {
+		input := loggerapi.SecgroupFlowLogUploadInput{
+			Logs: loggerEntries,
+		}
+		if _, err := loggermodules.SecgroupFlowLogRecords.PerformClassAction(s, "upload", jsonutils.Marshal(input)); err != nil {
+			return errors.Wrap(err, "upload")
+		}
+	}
+	return nil
+}
+
+// shipSecgroupFlowLogToBucket writes entries as json lines into one object
+// keyed by date, host id and time of shipping, with temp url of the bucket
+func shipSecgroupFlowLogToBucket(ctx context.Context, s *mcclient.ClientSession, flowLog *computeapi.SecgroupFlowLogDetails, hostId string, entries []loggerapi.SecgroupFlowLogEntry) error {
+	buf := &bytes.Buffer{}
+	for i := range entries {
+		buf.WriteString(jsonutils.Marshal(&entries[i]).String())
+		buf.WriteByte('\n')
+	}
+	now := time.Now().UTC()
+	key := path.Join(
+		flowLog.Prefix,
+		now.Format("2006/01/02"),
+		fmt.Sprintf("%s-%d.json", hostId, now.UnixNano()),
+	)
+	params := jsonutils.NewDict()
+	params.Set("method", jsonutils.NewString("PUT"))
+	params.Set("key", jsonutils.NewString(key))
+	params.Set("expire_seconds", jsonutils.NewInt(300))
+	res, err := modules.Buckets.PerformAction(s, flowLog.BucketId, "temp-url", params)
+	if err != nil {
+		return errors.Wrap(err, "temp-url")
+	}
+	url, err := res.GetString("url")
+	if err != nil {
+		return errors.Wrap(err, "get temp url")
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-ndjson")
+	resp, err := httputils.Request(httputils.GetDefaultClient(), ctx, httputils.PUT, url, header, buf, false)
+	if err != nil {
+		return errors.Wrapf(err, "put object %s", key)
+	}
+	defer httputils.CloseResponse(resp)
+	if resp.StatusCode >= 300 {
+		return errors.Errorf("put object %s: %s", key, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostinfo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseAclLogLine(t *testing.T) {
+	line := `2021-04-06T07:58:37.640Z|00039|acl_log(ovn_pinctrl0)|INFO|name="rule0", verdict=drop, severity=info: tcp,vlan_tci=0x0000,dl_src=00:22:00:00:00:01,dl_dst=00:22:00:00:00:02,nw_src=10.0.0.1,nw_dst=10.0.0.2,nw_tos=0,nw_ecn=0,nw_ttl=64,tp_src=36452,tp_dst=22,tcp_flags=syn`
+	got, ok := parseAclLogLine(line)
+	if !ok {
+		t.Fatalf("parse failed")
+	}
+	want := &aclLogEntry{
+		Timestamp: time.Date(2021, 4, 6, 7, 58, 37, 640000000, time.UTC),
+		Name:      "rule0",
+		Verdict:   "drop",
+		Protocol:  "tcp",
+		DlSrc:     "00:22:00:00:00:01",
+		DlDst:     "00:22:00:00:00:02",
+		SrcIp:     "10.0.0.1",
+		DstIp:     "10.0.0.2",
+		SrcPort:   36452,
+		DstPort:   22,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %#v, got %#v", want, got)
+	}
+
+	line = `2021-04-06T07:58:37.640Z|00040|binding|INFO|Claiming lport iface-xx for this chassis.`
+	if _, ok := parseAclLogLine(line); ok {
+		t.Errorf("non acl log line parsed")
+	}
+}
+
+func TestCollectSecgroupRuleStatsParsers(t *testing.T) {
+	aclNames, err := parseOvnAclNames(`{"data":[[["uuid","1a2b3c4d-0000-0000-0000-000000000000"],"rule0"],[["uuid","5e6f7a8b-0000-0000-0000-000000000000"],["set",[]]]],"headings":["_uuid","name"]}`)
+	if err != nil {
+		t.Fatalf("parse acl names: %v", err)
+	}
+	if want := map[string]string{"1a2b3c4d": "rule0"}; !reflect.DeepEqual(aclNames, want) {
+		t.Errorf("acl names: want %v, got %v", want, aclNames)
+	}
+
+	hints, err := parseOvnLflowStageHints(`{"data":[[["uuid","0000abcd-0000-0000-0000-000000000000"],["map",[["source","northd.c:5000"],["stage-hint","1a2b3c4d"],["stage-name","ls_in_acl"]]]]],"headings":["_uuid","external_ids"]}`)
+	if err != nil {
+		t.Fatalf("parse stage hints: %v", err)
+	}
+	if want := map[string]string{"0000abcd": "1a2b3c4d"}; !reflect.DeepEqual(hints, want) {
+		t.Errorf("stage hints: want %v, got %v", want, hints)
+	}
+
+	stats := parseOfctlFlowStats(`OFPST_FLOW reply (OF1.3) (xid=0x2):
+ cookie=0xabcd, duration=10.1s, table=44, n_packets=10, n_bytes=1000, priority=2001,ct_state=+new,tcp,reg15=0x2,metadata=0x1,tp_dst=22 actions=load:0x1->NXM_NX_XXREG0[97],resubmit(,45)
+ cookie=0xabcd, duration=10.1s, table=44, n_packets=5, n_bytes=500, priority=2001,ct_state=-new+est,tcp,reg15=0x2,metadata=0x1,tp_dst=22 actions=resubmit(,45)
+ cookie=0x0, duration=10.1s, table=0, n_packets=3, n_bytes=300, priority=0 actions=drop
+`)
+	if want := map[string]*ofctlFlowStat{"0000abcd": {Packets: 15, Bytes: 1500}}; !reflect.DeepEqual(stats, want) {
+		t.Errorf("flow stats: want %v, got %v", want, stats)
+	}
+}
+
+func TestSecgroupRuleCookiesResolve(t *testing.T) {
+	var (
+		c       sSecgroupRuleCookies
+		nHints  int
+		nNames  int
+		hintsDB = map[string]string{"0000abcd": "1a2b3c4d", "0000abce": "5e6f7a8b"}
+		namesDB = map[string]string{"1a2b3c4d": "rule0"}
+	)
+	fetchHints := func() (map[string]string, error) {
+		nHints++
+		return hintsDB, nil
+	}
+	fetchNames := func() (map[string]string, error) {
+		nNames++
+		return namesDB, nil
+	}
+	// 0000abce is of unnamed acl, 0000ffff is not of acl
+	cookies := []string{"0000abcd", "0000abce", "0000ffff"}
+	for i := 0; i < 2; i++ {
+		got, err := c.resolve(cookies, fetchHints, fetchNames)
+		if err != nil {
+			t.Fatalf("resolve: %v", err)
+		}
+		if want := map[string]string{"0000abcd": "rule0"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("round %d: want %v, got %v", i, want, got)
+		}
+	}
+	if nHints != 1 || nNames != 1 {
+		t.Errorf("central databases should be queried once, got %d, %d", nHints, nNames)
+	}
+
+	// new acl of rule change
+	hintsDB["0000abcf"] = "9c9d9e9f"
+	namesDB["9c9d9e9f"] = "rule1"
+	got, err := c.resolve([]string{"0000abcd", "0000abcf"}, fetchHints, fetchNames)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if want := map[string]string{"0000abcd": "rule0", "0000abcf": "rule1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	if nHints != 2 || nNames != 2 {
+		t.Errorf("unknown cookie should trigger query, got %d, %d", nHints, nNames)
+	}
+}
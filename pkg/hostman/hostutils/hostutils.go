@@ -22,6 +22,7 @@ import (
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
 
+	api "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/appctx"
 	"yunion.io/x/onecloud/pkg/appsrv"
 	"yunion.io/x/onecloud/pkg/cloudcommon/workmanager"
@@ -158,6 +159,14 @@ func UpdateServerProgress(ctx context.Context, sid string, progress, progressMbp
 	return modules.Servers.Update(GetComputeSession(ctx), sid, jsonutils.Marshal(params))
 }
 
+func UpdateServerMigrateProgress(ctx context.Context, sid string, progress float64, migProgress *api.SGuestMigrateProgress) (jsonutils.JSONObject, error) {
+	params := jsonutils.NewDict()
+	params.Set("progress", jsonutils.NewFloat64(progress))
+	params.Set("progress_mbps", jsonutils.NewFloat64(migProgress.Mbps))
+	params.Set("migrate_progress", jsonutils.Marshal(migProgress))
+	return modules.Servers.Update(GetComputeSession(ctx), sid, params)
+}
+
 func ResponseOk(ctx context.Context, w http.ResponseWriter) {
 	Response(ctx, w, map[string]string{"result": "ok"})
 }
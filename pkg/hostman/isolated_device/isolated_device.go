@@ -80,12 +80,17 @@ type IDevice interface {
 
 	GetHotPlugOptions() ([]*HotPlugOption, error)
 	GetHotUnplugOptions() ([]*HotUnplugOption, error)
+
+	// wire of SR-IOV virtual function, empty for other devices
+	GetWireId() string
+	// mdev type of mediated device, empty for other devices
+	GetMdevType() string
 }
 
 type IsolatedDeviceManager interface {
 	GetDevices() []IDevice
 	GetDeviceByIdent(vendorDevId string, addr string) IDevice
-	ProbePCIDevices(skipGPUs, skipUSBs bool) error
+	ProbePCIDevices(skipGPUs, skipUSBs bool, sriovNics, mdevTypes []string) error
 	StartDetachTask()
 	BatchCustomProbe() error
 	AppendDetachedDevice(dev *CloudDeviceInfo)
@@ -112,7 +117,7 @@ func (man *isolatedDeviceManager) GetDevices() []IDevice {
 	return man.devices
 }
 
-func (man *isolatedDeviceManager) ProbePCIDevices(skipGPUs, skipUSBs bool) error {
+func (man *isolatedDeviceManager) ProbePCIDevices(skipGPUs, skipUSBs bool, sriovNics, mdevTypes []string) error {
 	man.devices = make([]IDevice, 0)
 	if !skipGPUs {
 		gpus, err := getPassthroughGPUS()
@@ -139,6 +144,30 @@ func (man *isolatedDeviceManager) ProbePCIDevices(skipGPUs, skipUSBs bool) error
 		}
 	}
 
+	if len(sriovNics) > 0 {
+		nics, err := getPassthroughSRIOVNics(sriovNics)
+		if err != nil {
+			log.Errorf("getPassthroughSRIOVNics: %v", err)
+			return nil
+		}
+		for idx, nic := range nics {
+			man.devices = append(man.devices, nic)
+			log.Infof("Add SR-IOV NIC device: %d => %#v", idx, nic)
+		}
+	}
+
+	if len(mdevTypes) > 0 {
+		mdevs, err := getMdevDevices(mdevTypes)
+		if err != nil {
+			log.Errorf("getMdevDevices: %v", err)
+			return nil
+		}
+		for idx, mdev := range mdevs {
+			man.devices = append(man.devices, mdev)
+			log.Infof("Add mediated device: %d => %#v", idx, mdev)
+		}
+	}
+
 	return nil
 }
 
@@ -301,6 +330,14 @@ func (dev *sBaseDevice) GetGuestId() string {
 	return dev.guestId
 }
 
+func (dev *sBaseDevice) GetWireId() string {
+	return ""
+}
+
+func (dev *sBaseDevice) GetMdevType() string {
+	return ""
+}
+
 func GetApiResourceData(dev IDevice) *jsonutils.JSONDict {
 	data := map[string]interface{}{
 		"dev_type":         dev.GetDeviceType(),
@@ -322,6 +359,12 @@ func GetApiResourceData(dev IDevice) *jsonutils.JSONDict {
 	if len(dev.GetGuestId()) != 0 {
 		data["guest_id"] = dev.GetGuestId()
 	}
+	if len(dev.GetWireId()) != 0 {
+		data["wire_id"] = dev.GetWireId()
+	}
+	if len(dev.GetMdevType()) != 0 {
+		data["mdev_type"] = dev.GetMdevType()
+	}
 	return jsonutils.Marshal(data).(*jsonutils.JSONDict)
 }
 
@@ -402,7 +445,7 @@ func getQemuParams(man *isolatedDeviceManager, devAddrs []string) *QemuParams {
 		if !ok {
 			devices[devType] = []IDevice{dev}
 		} else {
-			devs = append(devs, dev)
+			devices[devType] = append(devs, dev)
 		}
 	}
 
@@ -413,7 +456,7 @@ func getQemuParams(man *isolatedDeviceManager, devAddrs []string) *QemuParams {
 			if dev.GetVGACmd() != vgaCmd && dev.GetDeviceType() == api.GPU_VGA_TYPE {
 				vgaCmd = dev.GetVGACmd()
 			}
-			if dev.GetCPUCmd() != cpuCmd {
+			if len(dev.GetCPUCmd()) > 0 && dev.GetCPUCmd() != cpuCmd {
 				cpuCmd = dev.GetCPUCmd()
 			}
 		}
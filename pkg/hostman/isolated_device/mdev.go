@@ -0,0 +1,277 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package isolated_device
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/util/fileutils2"
+)
+
+func sysfsMdevBusPath() string {
+	return path.Join(sysfsRoot, "class/mdev_bus")
+}
+
+func sysfsMdevTypePath(parentAddr, mdevType string) string {
+	return path.Join(sysfsMdevBusPath(), parentAddr, "mdev_supported_types", mdevType)
+}
+
+func sysfsMdevDevicePath(uuid string) string {
+	return path.Join(sysfsRoot, "bus/mdev/devices", uuid)
+}
+
+type sMdevType struct {
+	// parent pci address with domain, e.g. 0000:3b:00.0
+	ParentAddr string
+	// mdev type id, e.g. nvidia-63
+	Type string
+	// human readable name, e.g. GRID P4-2Q
+	Name string
+	// instances could be created
+	AvailableInstances int
+	// instances already created
+	Instances []string
+}
+
+// Capacity returns the total count of instances this mdev type could hold
+func (t *sMdevType) Capacity() int {
+	return t.AvailableInstances + len(t.Instances)
+}
+
+// listMdevParents returns pci addresses of devices registered to mdev bus
+func listMdevParents() ([]string, error) {
+	files, err := ioutil.ReadDir(sysfsMdevBusPath())
+	if err != nil {
+		return nil, err
+	}
+	parents := make([]string, 0, len(files))
+	for _, f := range files {
+		parents = append(parents, f.Name())
+	}
+	sort.Strings(parents)
+	return parents, nil
+}
+
+// listMdevTypes returns mdev types supported by parent device
+func listMdevTypes(parentAddr string) ([]*sMdevType, error) {
+	typesPath := path.Join(sysfsMdevBusPath(), parentAddr, "mdev_supported_types")
+	files, err := ioutil.ReadDir(typesPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read dir %s", typesPath)
+	}
+	ret := make([]*sMdevType, 0, len(files))
+	for _, f := range files {
+		t, err := getMdevType(parentAddr, f.Name())
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, t)
+	}
+	return ret, nil
+}
+
+func getMdevType(parentAddr, mdevType string) (*sMdevType, error) {
+	typePath := sysfsMdevTypePath(parentAddr, mdevType)
+	avail, err := readSysfsInt(path.Join(typePath, "available_instances"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "read available_instances of %s", mdevType)
+	}
+	t := &sMdevType{
+		ParentAddr:         parentAddr,
+		Type:               mdevType,
+		AvailableInstances: avail,
+		Instances:          make([]string, 0),
+	}
+	if name, err := fileutils2.FileGetContents(path.Join(typePath, "name")); err == nil {
+		t.Name = strings.TrimSpace(name)
+	}
+	if len(t.Name) == 0 {
+		t.Name = mdevType
+	}
+	if files, err := ioutil.ReadDir(path.Join(typePath, "devices")); err == nil {
+		for _, f := range files {
+			t.Instances = append(t.Instances, f.Name())
+		}
+	}
+	return t, nil
+}
+
+type sMdevDevice struct {
+	*sBaseDevice
+
+	parentAddr string
+	mdevType   string
+}
+
+// getMdevSlotAddr returns address of the index-th instance slot on parent device
+func getMdevSlotAddr(parentAddr string, index int) string {
+	return fmt.Sprintf("%s/%d", strings.TrimPrefix(parentAddr, "0000:"), index)
+}
+
+func newMdevDevice(dev *PCIDevice, parentAddr string, mdevType string) *sMdevDevice {
+	return &sMdevDevice{
+		sBaseDevice: newBaseDevice(dev, api.MDEV_TYPE),
+		parentAddr:  parentAddr,
+		mdevType:    mdevType,
+	}
+}
+
+func (dev *sMdevDevice) GetMdevType() string {
+	return dev.mdevType
+}
+
+// GetMdevId returns uuid of the mdev instance, which is the id of isolated device on region
+func (dev *sMdevDevice) GetMdevId() string {
+	return dev.GetCloudId()
+}
+
+func (dev *sMdevDevice) GetCPUCmd() string {
+	return ""
+}
+
+func (dev *sMdevDevice) GetVGACmd() string {
+	return ""
+}
+
+func (dev *sMdevDevice) CustomProbe() error {
+	return dev.DetectByAddr()
+}
+
+func (dev *sMdevDevice) DetectByAddr() error {
+	if !fileutils2.Exists(sysfsMdevTypePath(dev.parentAddr, dev.mdevType)) {
+		return errors.Wrapf(errors.ErrNotFound, "mdev type %s of %s", dev.mdevType, dev.parentAddr)
+	}
+	return nil
+}
+
+func (dev *sMdevDevice) GetIOMMUGroupDeviceCmd() string {
+	return ""
+}
+
+func (dev *sMdevDevice) GetPassthroughCmd(_ int) string {
+	return fmt.Sprintf(" -device vfio-pci,sysfsdev=%s", sysfsMdevDevicePath(dev.GetMdevId()))
+}
+
+func (dev *sMdevDevice) GetHotPlugOptions() ([]*HotPlugOption, error) {
+	return nil, fmt.Errorf("Not implemented")
+}
+
+func (dev *sMdevDevice) GetHotUnplugOptions() ([]*HotUnplugOption, error) {
+	return nil, fmt.Errorf("Not implemented")
+}
+
+// CreateMdev create mdev instance of the slot, do nothing if it already exists
+func (dev *sMdevDevice) CreateMdev() error {
+	uuid := dev.GetMdevId()
+	if len(uuid) == 0 {
+		return errors.Errorf("mdev slot %s not synced to region", dev.GetAddr())
+	}
+	if fileutils2.Exists(sysfsMdevDevicePath(uuid)) {
+		return nil
+	}
+	t, err := getMdevType(dev.parentAddr, dev.mdevType)
+	if err != nil {
+		return err
+	}
+	if t.AvailableInstances <= 0 {
+		return errors.Errorf("no available instance of mdev type %s on %s", dev.mdevType, dev.parentAddr)
+	}
+	createPath := path.Join(sysfsMdevTypePath(dev.parentAddr, dev.mdevType), "create")
+	if err := fileutils2.FilePutContents(createPath, uuid, false); err != nil {
+		return errors.Wrapf(err, "create mdev %s", uuid)
+	}
+	log.Infof("mdev %s of type %s created on %s", uuid, dev.mdevType, dev.parentAddr)
+	return nil
+}
+
+// RemoveMdev destroy mdev instance of the slot, do nothing if not exists
+func (dev *sMdevDevice) RemoveMdev() error {
+	uuid := dev.GetMdevId()
+	if len(uuid) == 0 || !fileutils2.Exists(sysfsMdevDevicePath(uuid)) {
+		return nil
+	}
+	if err := fileutils2.FilePutContents(path.Join(sysfsMdevDevicePath(uuid), "remove"), "1", false); err != nil {
+		return errors.Wrapf(err, "remove mdev %s", uuid)
+	}
+	log.Infof("mdev %s of type %s removed from %s", uuid, dev.mdevType, dev.parentAddr)
+	return nil
+}
+
+// IMdevDevice is implemented by isolated devices backed by mediated device
+type IMdevDevice interface {
+	IDevice
+
+	GetMdevId() string
+	CreateMdev() error
+	RemoveMdev() error
+}
+
+// getMdevDevices returns an isolated device for each instance slot of the enabled mdev types,
+// one parent device only exposes the first enabled type it supports
+func getMdevDevices(enabledTypes []string) ([]*sMdevDevice, error) {
+	parents, err := listMdevParents()
+	if err != nil {
+		return nil, errors.Wrap(err, "listMdevParents")
+	}
+	devs := make([]*sMdevDevice, 0)
+	for _, parent := range parents {
+		types, err := listMdevTypes(parent)
+		if err != nil {
+			return nil, errors.Wrapf(err, "listMdevTypes of %s", parent)
+		}
+		var mdevType *sMdevType
+		for _, enabled := range enabledTypes {
+			for _, t := range types {
+				if t.Type == enabled {
+					mdevType = t
+					break
+				}
+			}
+			if mdevType != nil {
+				break
+			}
+		}
+		if mdevType == nil {
+			log.Infof("mdev parent %s supports none of %v, skip it", parent, enabledTypes)
+			continue
+		}
+		vendorId, err := readSysfsPCIId(path.Join(sysfsPCIDevicePath(parent), "vendor"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "read vendor of %s", parent)
+		}
+		deviceId, err := readSysfsPCIId(path.Join(sysfsPCIDevicePath(parent), "device"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "read device of %s", parent)
+		}
+		for i := 0; i < mdevType.Capacity(); i++ {
+			pciDev := &PCIDevice{
+				Addr:      getMdevSlotAddr(parent, i),
+				VendorId:  vendorId,
+				DeviceId:  deviceId,
+				ModelName: mdevType.Name,
+			}
+			devs = append(devs, newMdevDevice(pciDev, parent, mdevType.Type))
+		}
+	}
+	return devs, nil
+}
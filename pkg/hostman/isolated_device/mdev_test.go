@@ -0,0 +1,122 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package isolated_device
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/util/fileutils2"
+)
+
+// newFakeMdevSysfs create a sysfs tree with one GPU supporting 2 mdev types
+func newFakeMdevSysfs(t *testing.T) string {
+	root, err := ioutil.TempDir("", "sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := "0000:3b:00.0"
+	mustWriteFile(t, path.Join(root, "bus/pci/devices", parent, "vendor"), "0x10de\n")
+	mustWriteFile(t, path.Join(root, "bus/pci/devices", parent, "device"), "0x1bb3\n")
+	for typ, conf := range map[string][2]string{
+		"nvidia-63": {"GRID P4-1Q", "6"},
+		"nvidia-64": {"GRID P4-2Q", "4"},
+	} {
+		typePath := path.Join(root, "class/mdev_bus", parent, "mdev_supported_types", typ)
+		mustWriteFile(t, path.Join(typePath, "name"), conf[0]+"\n")
+		mustWriteFile(t, path.Join(typePath, "available_instances"), conf[1]+"\n")
+		mustWriteFile(t, path.Join(typePath, "device_api"), "vfio-pci\n")
+		if err := os.MkdirAll(path.Join(typePath, "devices"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestGetMdevDevices(t *testing.T) {
+	root := newFakeMdevSysfs(t)
+	defer os.RemoveAll(root)
+	oldRoot := sysfsRoot
+	sysfsRoot = root
+	defer func() { sysfsRoot = oldRoot }()
+
+	devs, err := getMdevDevices([]string{"nvidia-64", "nvidia-63"})
+	if err != nil {
+		t.Fatalf("getMdevDevices: %v", err)
+	}
+	if len(devs) != 4 {
+		t.Fatalf("expect 4 mdev slots, got %d", len(devs))
+	}
+	dev := devs[1]
+	if dev.GetAddr() != "3b:00.0/1" {
+		t.Errorf("addr = %q, want 3b:00.0/1", dev.GetAddr())
+	}
+	if dev.GetVendorDeviceId() != "10de:1bb3" {
+		t.Errorf("vendor device id = %q, want 10de:1bb3", dev.GetVendorDeviceId())
+	}
+	if dev.GetModelName() != "GRID P4-2Q" || dev.GetMdevType() != "nvidia-64" || dev.GetDeviceType() != api.MDEV_TYPE {
+		t.Errorf("unexpected mdev device %s/%s/%s", dev.GetModelName(), dev.GetMdevType(), dev.GetDeviceType())
+	}
+	if err := dev.DetectByAddr(); err != nil {
+		t.Errorf("DetectByAddr: %v", err)
+	}
+
+	if devs, _ := getMdevDevices([]string{"nvidia-1"}); len(devs) != 0 {
+		t.Errorf("expect no device for unsupported type, got %d", len(devs))
+	}
+}
+
+func TestMdevLifecycle(t *testing.T) {
+	root := newFakeMdevSysfs(t)
+	defer os.RemoveAll(root)
+	oldRoot := sysfsRoot
+	sysfsRoot = root
+	defer func() { sysfsRoot = oldRoot }()
+
+	devs, err := getMdevDevices([]string{"nvidia-63"})
+	if err != nil {
+		t.Fatalf("getMdevDevices: %v", err)
+	}
+	dev := devs[0]
+	if err := dev.CreateMdev(); err == nil {
+		t.Errorf("expect error creating mdev of device not synced to region")
+	}
+	uuid := "d8a9d7ee-1c1b-4b3a-8b8c-2f2a0c4e8f11"
+	dev.SetDeviceInfo(CloudDeviceInfo{Id: uuid})
+
+	if err := dev.CreateMdev(); err != nil {
+		t.Fatalf("CreateMdev: %v", err)
+	}
+	createPath := path.Join(sysfsMdevTypePath("0000:3b:00.0", "nvidia-63"), "create")
+	if content, _ := fileutils2.FileGetContents(createPath); content != uuid {
+		t.Errorf("create content = %q, want %q", content, uuid)
+	}
+	if cmd := dev.GetPassthroughCmd(0); !strings.Contains(cmd, "sysfsdev="+sysfsMdevDevicePath(uuid)) {
+		t.Errorf("unexpected passthrough cmd %q", cmd)
+	}
+
+	// kernel creates the instance
+	mustWriteFile(t, path.Join(sysfsMdevDevicePath(uuid), "remove"), "")
+	if err := dev.RemoveMdev(); err != nil {
+		t.Fatalf("RemoveMdev: %v", err)
+	}
+	if content, _ := fileutils2.FileGetContents(path.Join(sysfsMdevDevicePath(uuid), "remove")); content != "1" {
+		t.Errorf("remove content = %q, want 1", content)
+	}
+}
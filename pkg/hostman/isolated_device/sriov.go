@@ -0,0 +1,330 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package isolated_device
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/util/fileutils2"
+	"yunion.io/x/onecloud/pkg/util/procutils"
+)
+
+// sysfsRoot is where sysfs mounted, replaced by a fake tree in tests
+var sysfsRoot = "/sys"
+
+type SRIOVNicConf struct {
+	// physical function interface name, e.g. eth1
+	Interface string
+	// wire id or name the physical function connected to
+	Wire string
+	// count of virtual functions to create
+	VfCount int
+}
+
+// ParseSRIOVNicConf parse config of `<ifname>/<wire>/<vf_count>` format
+func ParseSRIOVNicConf(conf string) (*SRIOVNicConf, error) {
+	parts := strings.Split(conf, "/")
+	if len(parts) != 3 {
+		return nil, errors.Errorf("invalid SR-IOV nic config %q, expect <ifname>/<wire>/<vf_count>", conf)
+	}
+	for _, p := range parts {
+		if len(p) == 0 {
+			return nil, errors.Errorf("invalid SR-IOV nic config %q, empty field", conf)
+		}
+	}
+	cnt, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse vf count of %q", conf)
+	}
+	if cnt <= 0 {
+		return nil, errors.Errorf("invalid vf count %d of %q", cnt, conf)
+	}
+	return &SRIOVNicConf{
+		Interface: parts[0],
+		Wire:      parts[1],
+		VfCount:   cnt,
+	}, nil
+}
+
+func sysfsPFDevicePath(pfName string) string {
+	return path.Join(sysfsRoot, "class/net", pfName, "device")
+}
+
+func sysfsPCIDevicePath(addr string) string {
+	if len(addr) == 7 {
+		addr = fmt.Sprintf("0000:%s", addr)
+	}
+	return path.Join(sysfsRoot, "bus/pci/devices", addr)
+}
+
+func readSysfsInt(p string) (int, error) {
+	content, err := fileutils2.FileGetContents(p)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(content))
+}
+
+// readSysfsPCIId read vendor or device id like 0x8086 and return 8086
+func readSysfsPCIId(p string) (string, error) {
+	content, err := fileutils2.FileGetContents(p)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(strings.TrimSpace(content), "0x"), nil
+}
+
+// ensureSRIOVVfCount make physical function expose at least conf.VfCount virtual functions
+func ensureSRIOVVfCount(conf *SRIOVNicConf) error {
+	devPath := sysfsPFDevicePath(conf.Interface)
+	totalVfs, err := readSysfsInt(path.Join(devPath, "sriov_totalvfs"))
+	if err != nil {
+		return errors.Wrapf(err, "%s not SR-IOV capable", conf.Interface)
+	}
+	if conf.VfCount > totalVfs {
+		return errors.Errorf("%s support at most %d virtual functions, request %d", conf.Interface, totalVfs, conf.VfCount)
+	}
+	numVfs, err := readSysfsInt(path.Join(devPath, "sriov_numvfs"))
+	if err != nil {
+		return errors.Wrap(err, "read sriov_numvfs")
+	}
+	if numVfs == conf.VfCount {
+		return nil
+	}
+	// kernel refuses to change vf count unless reset to 0 first
+	if numVfs > 0 {
+		if err := fileutils2.FilePutContents(path.Join(devPath, "sriov_numvfs"), "0", false); err != nil {
+			return errors.Wrap(err, "reset sriov_numvfs")
+		}
+	}
+	if err := fileutils2.FilePutContents(path.Join(devPath, "sriov_numvfs"), strconv.Itoa(conf.VfCount), false); err != nil {
+		return errors.Wrapf(err, "set sriov_numvfs to %d", conf.VfCount)
+	}
+	return nil
+}
+
+type sSRIOVVirtualFunction struct {
+	Index    int
+	Addr     string
+	VendorId string
+	DeviceId string
+}
+
+// listSRIOVVirtualFunctions list virtual functions created on physical function
+func listSRIOVVirtualFunctions(pfName string) ([]*sSRIOVVirtualFunction, error) {
+	devPath := sysfsPFDevicePath(pfName)
+	links, err := filepath.Glob(path.Join(devPath, "virtfn*"))
+	if err != nil {
+		return nil, errors.Wrap(err, "glob virtfn")
+	}
+	vfs := make([]*sSRIOVVirtualFunction, 0)
+	for _, link := range links {
+		idx, err := strconv.Atoi(strings.TrimPrefix(path.Base(link), "virtfn"))
+		if err != nil {
+			continue
+		}
+		target, err := os.Readlink(link)
+		if err != nil {
+			return nil, errors.Wrapf(err, "readlink %s", link)
+		}
+		fullAddr := path.Base(target)
+		vendorId, err := readSysfsPCIId(path.Join(sysfsPCIDevicePath(fullAddr), "vendor"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "read vendor of %s", fullAddr)
+		}
+		deviceId, err := readSysfsPCIId(path.Join(sysfsPCIDevicePath(fullAddr), "device"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "read device of %s", fullAddr)
+		}
+		vfs = append(vfs, &sSRIOVVirtualFunction{
+			Index:    idx,
+			Addr:     strings.TrimPrefix(fullAddr, "0000:"),
+			VendorId: vendorId,
+			DeviceId: deviceId,
+		})
+	}
+	sort.Slice(vfs, func(i, j int) bool { return vfs[i].Index < vfs[j].Index })
+	return vfs, nil
+}
+
+func getSysfsPCIKernelDriver(addr string) string {
+	target, err := os.Readlink(path.Join(sysfsPCIDevicePath(addr), "driver"))
+	if err != nil {
+		return ""
+	}
+	return path.Base(target)
+}
+
+// bindSysfsVFIOPCIDriver rebind pci device to vfio-pci by driver_override
+func bindSysfsVFIOPCIDriver(addr string) error {
+	if getSysfsPCIKernelDriver(addr) == VFIO_PCI_KERNEL_DRIVER {
+		return nil
+	}
+	devPath := sysfsPCIDevicePath(addr)
+	fullAddr := path.Base(devPath)
+	if err := fileutils2.FilePutContents(path.Join(devPath, "driver_override"), VFIO_PCI_KERNEL_DRIVER, false); err != nil {
+		return errors.Wrap(err, "set driver_override")
+	}
+	if len(getSysfsPCIKernelDriver(addr)) > 0 {
+		if err := fileutils2.FilePutContents(path.Join(devPath, "driver", "unbind"), fullAddr, false); err != nil {
+			return errors.Wrap(err, "unbind driver")
+		}
+	}
+	if err := fileutils2.FilePutContents(path.Join(sysfsRoot, "bus/pci/drivers_probe"), fullAddr, false); err != nil {
+		return errors.Wrap(err, "drivers_probe")
+	}
+	return nil
+}
+
+type sSRIOVNicDevice struct {
+	*sBaseDevice
+
+	pfName  string
+	vfIndex int
+	wireId  string
+}
+
+func newSRIOVNicDevice(dev *PCIDevice, pfName string, vfIndex int, wireId string) *sSRIOVNicDevice {
+	return &sSRIOVNicDevice{
+		sBaseDevice: newBaseDevice(dev, api.NIC_TYPE),
+		pfName:      pfName,
+		vfIndex:     vfIndex,
+		wireId:      wireId,
+	}
+}
+
+func (dev *sSRIOVNicDevice) GetWireId() string {
+	return dev.wireId
+}
+
+func (dev *sSRIOVNicDevice) GetCPUCmd() string {
+	return ""
+}
+
+func (dev *sSRIOVNicDevice) GetVGACmd() string {
+	return ""
+}
+
+func (dev *sSRIOVNicDevice) CustomProbe() error {
+	if err := procutils.NewRemoteCommandAsFarAsPossible("modprobe", VFIO_PCI_KERNEL_DRIVER).Run(); err != nil {
+		return fmt.Errorf("modprobe %s: %v", VFIO_PCI_KERNEL_DRIVER, err)
+	}
+	if driver := getSysfsPCIKernelDriver(dev.GetAddr()); driver != VFIO_PCI_KERNEL_DRIVER {
+		return fmt.Errorf("SR-IOV virtual function %s is occupied by driver %q", dev.GetAddr(), driver)
+	}
+	return nil
+}
+
+func (dev *sSRIOVNicDevice) DetectByAddr() error {
+	if !fileutils2.Exists(sysfsPCIDevicePath(dev.GetAddr())) {
+		return errors.Wrapf(errors.ErrNotFound, "virtual function %s", dev.GetAddr())
+	}
+	return nil
+}
+
+func (dev *sSRIOVNicDevice) GetQemuId() string {
+	return fmt.Sprintf("dev_%s", strings.NewReplacer(":", "_", ".", "_").Replace(dev.GetAddr()))
+}
+
+func (dev *sSRIOVNicDevice) GetPassthroughCmd(_ int) string {
+	return fmt.Sprintf(" -device vfio-pci,host=%s,id=%s", dev.GetAddr(), dev.GetQemuId())
+}
+
+func (dev *sSRIOVNicDevice) GetHotPlugOptions() ([]*HotPlugOption, error) {
+	return []*HotPlugOption{
+		{
+			Device: VFIO_PCI_KERNEL_DRIVER,
+			Options: map[string]interface{}{
+				"host": dev.GetAddr(),
+				"id":   dev.GetQemuId(),
+			},
+		},
+	}, nil
+}
+
+func (dev *sSRIOVNicDevice) GetHotUnplugOptions() ([]*HotUnplugOption, error) {
+	return []*HotUnplugOption{
+		{Id: dev.GetQemuId()},
+	}, nil
+}
+
+// SetNicConfig program mac address and vlan of guest nic on virtual function
+func (dev *sSRIOVNicDevice) SetNicConfig(mac string, vlan int) error {
+	// vlan 1 is the default untagged vlan of onecloud
+	if vlan <= 1 {
+		vlan = 0
+	}
+	args := []string{"link", "set", dev.pfName, "vf", strconv.Itoa(dev.vfIndex), "mac", mac, "vlan", strconv.Itoa(vlan)}
+	if output, err := procutils.NewRemoteCommandAsFarAsPossible("ip", args...).Output(); err != nil {
+		return errors.Wrapf(err, "ip %s: %s", strings.Join(args, " "), output)
+	}
+	return nil
+}
+
+// ISRIOVNicDevice is implemented by isolated devices backed by SR-IOV virtual function
+type ISRIOVNicDevice interface {
+	IDevice
+
+	SetNicConfig(mac string, vlan int) error
+}
+
+func getPassthroughSRIOVNics(confs []string) ([]*sSRIOVNicDevice, error) {
+	devs := make([]*sSRIOVNicDevice, 0)
+	for _, c := range confs {
+		conf, err := ParseSRIOVNicConf(c)
+		if err != nil {
+			return nil, err
+		}
+		if err := ensureSRIOVVfCount(conf); err != nil {
+			return nil, errors.Wrapf(err, "ensureSRIOVVfCount of %s", conf.Interface)
+		}
+		vfs, err := listSRIOVVirtualFunctions(conf.Interface)
+		if err != nil {
+			return nil, errors.Wrapf(err, "listSRIOVVirtualFunctions of %s", conf.Interface)
+		}
+		for _, vf := range vfs {
+			if err := bindSysfsVFIOPCIDriver(vf.Addr); err != nil {
+				return nil, errors.Wrapf(err, "bind %s to vfio-pci", vf.Addr)
+			}
+			pciDev, err := detectPCIDevByAddrWithoutIOMMUGroup(vf.Addr)
+			if err != nil || len(pciDev.Addr) == 0 {
+				log.Warningf("lspci virtual function %s: %v, use ids from sysfs", vf.Addr, err)
+				pciDev = &PCIDevice{
+					Addr:     vf.Addr,
+					VendorId: vf.VendorId,
+					DeviceId: vf.DeviceId,
+				}
+			}
+			if len(pciDev.ModelName) == 0 {
+				pciDev.ModelName = pciDev.DeviceName
+			}
+			if len(pciDev.ModelName) == 0 {
+				pciDev.ModelName = fmt.Sprintf("%s Virtual Function", pciDev.GetVendorDeviceId())
+			}
+			devs = append(devs, newSRIOVNicDevice(pciDev, conf.Interface, vf.Index, conf.Wire))
+		}
+	}
+	return devs, nil
+}
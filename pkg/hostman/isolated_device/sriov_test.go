@@ -0,0 +1,112 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package isolated_device
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestParseSRIOVNicConf(t *testing.T) {
+	tests := []struct {
+		conf    string
+		want    *SRIOVNicConf
+		wantErr bool
+	}{
+		{
+			conf: "eth1/bcast0/8",
+			want: &SRIOVNicConf{Interface: "eth1", Wire: "bcast0", VfCount: 8},
+		},
+		{conf: "eth1/bcast0", wantErr: true},
+		{conf: "eth1//8", wantErr: true},
+		{conf: "eth1/bcast0/0", wantErr: true},
+		{conf: "eth1/bcast0/x", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSRIOVNicConf(tt.conf)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSRIOVNicConf(%q) error = %v, wantErr %v", tt.conf, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSRIOVNicConf(%q) = %#v, want %#v", tt.conf, got, tt.want)
+		}
+	}
+}
+
+func mustWriteFile(t *testing.T, p string, content string) {
+	if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSRIOVVirtualFunctions(t *testing.T) {
+	root, err := ioutil.TempDir("", "sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	oldRoot := sysfsRoot
+	sysfsRoot = root
+	defer func() { sysfsRoot = oldRoot }()
+
+	pfDev := path.Join(root, "bus/pci/devices/0000:3b:00.0")
+	mustWriteFile(t, path.Join(pfDev, "sriov_totalvfs"), "4\n")
+	mustWriteFile(t, path.Join(pfDev, "sriov_numvfs"), "2\n")
+	if err := os.MkdirAll(path.Join(root, "class/net/eth1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(pfDev, path.Join(root, "class/net/eth1/device")); err != nil {
+		t.Fatal(err)
+	}
+	for i, addr := range []string{"0000:3b:02.0", "0000:3b:02.1"} {
+		vfDev := path.Join(root, "bus/pci/devices", addr)
+		mustWriteFile(t, path.Join(vfDev, "vendor"), "0x8086\n")
+		mustWriteFile(t, path.Join(vfDev, "device"), "0x154c\n")
+		if err := os.Symlink(vfDev, path.Join(pfDev, "virtfn"+string(rune('0'+i)))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conf := &SRIOVNicConf{Interface: "eth1", Wire: "bcast0", VfCount: 8}
+	if err := ensureSRIOVVfCount(conf); err == nil {
+		t.Errorf("expect error when vf count exceeds sriov_totalvfs")
+	}
+	conf.VfCount = 3
+	if err := ensureSRIOVVfCount(conf); err != nil {
+		t.Fatalf("ensureSRIOVVfCount: %v", err)
+	}
+	if cnt, _ := readSysfsInt(path.Join(pfDev, "sriov_numvfs")); cnt != 3 {
+		t.Errorf("sriov_numvfs = %d, want 3", cnt)
+	}
+
+	vfs, err := listSRIOVVirtualFunctions("eth1")
+	if err != nil {
+		t.Fatalf("listSRIOVVirtualFunctions: %v", err)
+	}
+	want := []*sSRIOVVirtualFunction{
+		{Index: 0, Addr: "3b:02.0", VendorId: "8086", DeviceId: "154c"},
+		{Index: 1, Addr: "3b:02.1", VendorId: "8086", DeviceId: "154c"},
+	}
+	if !reflect.DeepEqual(vfs, want) {
+		t.Errorf("listSRIOVVirtualFunctions = %#v, want %#v", vfs, want)
+	}
+}
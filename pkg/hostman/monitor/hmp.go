@@ -308,8 +308,13 @@ func (m *HmpMonitor) MigrateSetCapability(capability, state string, callback Str
 	m.Query(fmt.Sprintf("migrate_set_capability %s %s", capability, state), callback)
 }
 
-func (m *HmpMonitor) MigrateSetParameter(key string, val string, callback StringCallback) {
-	cmd := fmt.Sprintf("migrate_set_parameter %s %s", key, val)
+func (m *HmpMonitor) MigrateSetParameter(key string, val interface{}, callback StringCallback) {
+	if key == "max-bandwidth" {
+		// hmp takes max-bandwidth without unit suffix as MiB/s.  The
+		// value is in bytes per second, so append the "B" suffix
+		val = fmt.Sprintf("%vB", val)
+	}
+	cmd := fmt.Sprintf("migrate_set_parameter %s %v", key, val)
 	m.Query(cmd, callback)
 }
 
@@ -356,6 +361,10 @@ func (m *HmpMonitor) MigrateStartPostcopy(callback StringCallback) {
 	m.Query("migrate_start_postcopy", cb)
 }
 
+func (m *HmpMonitor) MigrateCancel(callback StringCallback) {
+	m.Query("migrate_cancel", callback)
+}
+
 func (m *HmpMonitor) GetBlockJobCounts(callback func(jobs int)) {
 	cb := func(output string) {
 		lines := strings.Split(strings.TrimSuffix(output, "\r\n"), "\r\n")
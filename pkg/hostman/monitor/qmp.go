@@ -31,6 +31,7 @@ import (
 	"yunion.io/x/pkg/errors"
 	"yunion.io/x/pkg/utils"
 
+	api "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/hostman/hostutils"
 )
 
@@ -608,7 +609,7 @@ func (m *QmpMonitor) MigrateSetCapability(capability, state string, callback Str
 	m.Query(cmd, cb)
 }
 
-func (m *QmpMonitor) MigrateSetParameter(key string, val string, callback StringCallback) {
+func (m *QmpMonitor) MigrateSetParameter(key string, val interface{}, callback StringCallback) {
 	var (
 		cb = func(res *Response) {
 			callback(m.actionResult(res))
@@ -698,7 +699,19 @@ func (m *QmpMonitor) GetMigrateStatus(callback StringCallback) {
 						mbps := ramMbps + diskMbps
 						progress := (1 - float64(diskRemain+ramRemain)/float64(diskTotal+ramTotal)) * 100.0
 						log.Debugf("progress: %f mbps: %f", progress, mbps)
-						hostutils.UpdateServerProgress(context.Background(), m.sid, progress, mbps)
+						ramTransferred, _ := ret.Int("ram", "transferred")
+						diskTransferred, _ := ret.Int("disk", "transferred")
+						dirtyRate, _ := ret.Int("ram", "dirty-pages-rate")
+						downtime, _ := ret.Int("expected-downtime")
+						hostutils.UpdateServerMigrateProgress(context.Background(), m.sid, progress, &api.SGuestMigrateProgress{
+							Status:             status,
+							TotalBytes:         ramTotal + diskTotal,
+							TransferredBytes:   ramTransferred + diskTransferred,
+							RemainingBytes:     ramRemain + diskRemain,
+							DirtyPagesRate:     dirtyRate,
+							Mbps:               mbps,
+							ExpectedDowntimeMs: downtime,
+						})
 					}
 
 					callback(status)
@@ -730,6 +743,16 @@ func (m *QmpMonitor) MigrateStartPostcopy(callback StringCallback) {
 	m.Query(cmd, cb)
 }
 
+func (m *QmpMonitor) MigrateCancel(callback StringCallback) {
+	var (
+		cb = func(res *Response) {
+			callback(m.actionResult(res))
+		}
+		cmd = &Command{Execute: "migrate_cancel"}
+	)
+	m.Query(cmd, cb)
+}
+
 func (m *QmpMonitor) blockJobs(res *Response) ([]BlockJob, error) {
 	if res.ErrorVal != nil {
 		return nil, errors.Errorf("GetBlockJobs for %s %s", m.server, jsonutils.Marshal(res.ErrorVal).String())
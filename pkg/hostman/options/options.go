@@ -82,6 +82,7 @@ type SHostOptions struct {
 	CheckSystemServices bool `help:"Check system services (ntpd, telegraf) on startup" default:"true"`
 
 	DhcpServerPort     int    `help:"Host dhcp server bind port" default:"67"`
+	EnableDhcp6        bool   `help:"Enable DHCPv6 and router advertisement responder for guest IPv6 address" default:"true"`
 	DiskIsSsd          bool   `default:"false"`
 	FetcherfsPath      string `default:"/opt/yunion/fetchclient/bin/fetcherfs" help:"Fuse fetcherfs path"`
 	FetcherfsBlockSize int    `default:"16" help:"Fuse fetcherfs fetch chunk_size MB"`
@@ -106,6 +107,16 @@ type SHostOptions struct {
 	// 热迁移带宽，预期不低于8MBps, 1G Memory takes 128 seconds
 	MigrateExpectRate        int `default:"8" help:"Expected memory migration rate in MB/sec, default 8MBps"`
 	MinMigrateTimeoutSeconds int `default:"30" help:"minimal timeout for a migration process, default 30 seconds"`
+	// 热迁移默认传输参数, 可被迁移请求覆盖
+	LiveMigrateMultifdChannels int    `default:"0" help:"Default multifd channels of live migration, 0 means multifd disabled"`
+	LiveMigrateCompression     string `help:"Default multifd compression method of live migration, e.g. zstd, requires multifd"`
+	LiveMigrateAutoConverge    bool   `default:"true" help:"Enable auto-converge of live migration by default"`
+	LiveMigrateMaxBandwidthMb  int64  `default:"0" help:"Default max bandwidth of live migration in MB/sec, 0 means unlimited"`
+	LiveMigrateDowntimeLimitMs int64  `default:"0" help:"Default max downtime of live migration in milliseconds, 0 means qemu default"`
+
+	EnableSerialLog      bool `default:"true" help:"Capture serial console output of guests to log file"`
+	SerialLogMaxSizeKb   int  `default:"1024" help:"Serial log is rotated when exceeding this size in KB"`
+	SerialLogBackupCount int  `default:"1" help:"Count of rotated serial log files to keep"`
 
 	SnapshotDirSuffix  string `help:"Snapshot dir name equal diskId concat snapshot dir suffix" default:"_snap"`
 	SnapshotRecycleDay int    `default:"1" help:"Snapshot Recycle delete Duration day"`
@@ -141,6 +152,11 @@ type SHostOptions struct {
 	OvnEipBridge              string `help:"name of bridge for eip traffic management" default:"$HOST_OVN_EIP_BRIDGE|breip"`
 	OvnUnderlayMtu            int    `help:"mtu of ovn underlay network" default:"1500"`
 
+	OvnNorthDatabase            string `help:"address for accessing ovn north database.  Security group rule hit counters will not be collected if empty" default:"$HOST_OVN_NORTH_DATABASE"`
+	OvnControllerLogPath        string `help:"path of ovn-controller log file where acl logs of security group flow log are read" default:"$HOST_OVN_CONTROLLER_LOG_PATH|/var/log/openvswitch/ovn-controller.log"`
+	SecgroupRuleStatsInterval   int    `help:"interval in seconds for reporting security group rule hit counters, 0 to disable" default:"60"`
+	SecgroupFlowLogShipInterval int    `help:"interval in seconds for shipping security group flow logs, 0 to disable" default:"30"`
+
 	EnableRemoteExecutor bool   `help:"Enable remote executor" default:"false"`
 	EnableHealthChecker  bool   `help:"enable host health checker" default:"false"`
 	HealthDriver         string `help:"Component save host health state" default:"etcd"`
@@ -158,6 +174,9 @@ type SHostOptions struct {
 	DisableGPU bool `help:"force disable GPU detect" default:"false" json:"disable_gpu"`
 	DisableUSB bool `help:"force disable USB detect" default:"true" json:"disable_usb"`
 
+	SRIOVNics []string `help:"SR-IOV capable physical NICs to create virtual functions on, format <ifname>/<wire>/<vf_count>" json:"sriov_nics"`
+	MdevTypes []string `help:"mediated device types exposed as isolated devices, e.g. nvidia-63, a parent device uses the first type it supports" json:"mdev_types"`
+
 	EthtoolEnableGso bool `help:"use ethtool to turn on or off GSO(generic segment offloading)" default:"false" json:"ethtool_enable_gso"`
 
 	EnableVmUuid bool `help:"enable vm UUID" default:"true" json:"enable_vm_uuid"`
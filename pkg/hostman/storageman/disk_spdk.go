@@ -0,0 +1,297 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageman
+
+import (
+	"context"
+	"fmt"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudprovider"
+	deployapi "yunion.io/x/onecloud/pkg/hostman/hostdeployer/apis"
+	"yunion.io/x/onecloud/pkg/hostman/hostutils"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/util/procutils"
+	"yunion.io/x/onecloud/pkg/util/qemuimg"
+	"yunion.io/x/onecloud/pkg/util/qemutils"
+)
+
+// SSpdkDisk is a spdk logical volume exported to guest by vhost-user-blk controller
+type SSpdkDisk struct {
+	SBaseDisk
+}
+
+func NewSpdkDisk(storage IStorage, id string) *SSpdkDisk {
+	var ret = new(SSpdkDisk)
+	ret.SBaseDisk = *NewBaseDisk(storage, id)
+	return ret
+}
+
+func (d *SSpdkDisk) getStorage() *SSpdkStorage {
+	return d.Storage.(*SSpdkStorage)
+}
+
+func (d *SSpdkDisk) GetType() string {
+	return api.STORAGE_SPDK
+}
+
+func (d *SSpdkDisk) Probe() error {
+	_, err := d.getStorage().GetClient().GetBdev(d.getStorage().getLvolName(d.Id))
+	if err != nil {
+		if errors.Cause(err) == errors.ErrNotFound {
+			return cloudprovider.ErrNotFound
+		}
+		return errors.Wrapf(err, "GetBdev")
+	}
+	return nil
+}
+
+// GetPath returns vhost-user socket path of the disk
+func (d *SSpdkDisk) GetPath() string {
+	return d.getStorage().getVhostSocketPath(d.Id)
+}
+
+func (d *SSpdkDisk) GetSnapshotDir() string {
+	return ""
+}
+
+func (d *SSpdkDisk) getSizeMb() int64 {
+	bdev, err := d.getStorage().GetClient().GetBdev(d.getStorage().getLvolName(d.Id))
+	if err != nil {
+		log.Errorf("get bdev of disk %s: %v", d.Id, err)
+		return 0
+	}
+	return bdev.SizeMb()
+}
+
+func (d *SSpdkDisk) GetDiskDesc() jsonutils.JSONObject {
+	desc := map[string]interface{}{
+		"disk_id":     d.Id,
+		"disk_format": "raw",
+		"disk_path":   d.getStorage().getDiskPath(d.Id),
+		"disk_size":   d.getSizeMb(),
+	}
+	return jsonutils.Marshal(desc)
+}
+
+// ensureVhostController creates vhost-blk controller of the disk if not exists,
+// the controller is kept across guest restart and removed with the disk
+func (d *SSpdkDisk) ensureVhostController() error {
+	storage := d.getStorage()
+	cli := storage.GetClient()
+	ctrlr := storage.getVhostCtrlr(d.Id)
+	_, err := cli.GetVhostController(ctrlr)
+	if err == nil {
+		return nil
+	}
+	if errors.Cause(err) != errors.ErrNotFound {
+		return errors.Wrapf(err, "GetVhostController %s", ctrlr)
+	}
+	err = cli.CreateVhostBlkController(ctrlr, storage.getLvolName(d.Id))
+	if err != nil {
+		return errors.Wrapf(err, "CreateVhostBlkController %s", ctrlr)
+	}
+	return nil
+}
+
+func (d *SSpdkDisk) GetDiskSetupScripts(idx int) string {
+	if err := d.ensureVhostController(); err != nil {
+		log.Errorf("ensure vhost controller of disk %s: %v", d.Id, err)
+	}
+	return fmt.Sprintf("DISK_%d='%s'\n", idx, d.GetPath())
+}
+
+func (d *SSpdkDisk) DeleteAllSnapshot(skipRecycle bool) error {
+	return fmt.Errorf("Not Impl")
+}
+
+func (d *SSpdkDisk) Delete(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	storage := d.getStorage()
+	cli := storage.GetClient()
+	ctrlr := storage.getVhostCtrlr(d.Id)
+	if _, err := cli.GetVhostController(ctrlr); err == nil {
+		if err := cli.DeleteVhostController(ctrlr); err != nil {
+			return nil, errors.Wrapf(err, "DeleteVhostController %s", ctrlr)
+		}
+	}
+	err := cli.DeleteLvol(storage.getLvolName(d.Id))
+	if err != nil && errors.Cause(err) != errors.ErrNotFound {
+		return nil, errors.Wrapf(err, "DeleteLvol %s", d.Id)
+	}
+	return nil, nil
+}
+
+func (d *SSpdkDisk) OnRebuildRoot(ctx context.Context, params api.DiskAllocateInput) error {
+	_, err := d.Delete(ctx, api.DiskDeleteInput{})
+	return err
+}
+
+func (d *SSpdkDisk) Resize(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	diskInfo, ok := params.(*jsonutils.JSONDict)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+	sizeMb, _ := diskInfo.Int("size")
+	if err := d.OnlineResize(sizeMb); err != nil {
+		return nil, err
+	}
+	err := d.getStorage().withNbdDevice(d.Id, func(dev string) error {
+		return d.ResizeFs(dev)
+	})
+	if err != nil {
+		log.Errorf("Resize fs of disk %s fail %s", d.Id, err)
+	}
+	return d.GetDiskDesc(), nil
+}
+
+// OnlineResize grows the lvol only, the running guest is notified by vhost-user-blk
+// config change and file system is left to the guest
+func (d *SSpdkDisk) OnlineResize(sizeMb int64) error {
+	err := d.getStorage().GetClient().ResizeLvol(d.getStorage().getLvolName(d.Id), sizeMb)
+	if err != nil {
+		return errors.Wrapf(err, "ResizeLvol %s", d.Id)
+	}
+	return nil
+}
+
+func (d *SSpdkDisk) PrepareSaveToGlance(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	return nil, fmt.Errorf("Not support")
+}
+
+func (d *SSpdkDisk) CleanupSnapshots(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	return nil, fmt.Errorf("Not support")
+}
+
+func (d *SSpdkDisk) ResetFromSnapshot(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	return nil, fmt.Errorf("Not support")
+}
+
+func (d *SSpdkDisk) PrepareMigrate(liveMigrate bool) (string, error) {
+	return "", fmt.Errorf("Not support")
+}
+
+func (d *SSpdkDisk) CreateFromTemplate(ctx context.Context, imageId string, format string, size int64, encryptInfo *apis.SEncryptInfo) (jsonutils.JSONObject, error) {
+	if encryptInfo != nil {
+		return nil, errors.Wrap(httperrors.ErrNotSupported, "spdk not support encryptInfo")
+	}
+	var imageCacheManager = storageManager.GetStoragecacheById(d.Storage.GetStoragecacheId())
+	if imageCacheManager == nil {
+		return nil, fmt.Errorf("failed to find image cache manger for storage %s", d.Storage.GetStorageName())
+	}
+	input := api.CacheImageInput{
+		ImageId: imageId,
+		Zone:    d.GetZoneId(),
+	}
+	imageCache, err := imageCacheManager.AcquireImage(ctx, input, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "AcquireImage")
+	}
+	defer imageCacheManager.ReleaseImage(ctx, imageId)
+
+	cacheImagePath := imageCache.GetPath()
+	img, err := qemuimg.NewQemuImage(cacheImagePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "NewQemuImage(%s)", cacheImagePath)
+	}
+	sizeMb := int64(img.GetSizeMB())
+	if size > sizeMb {
+		sizeMb = size
+	}
+
+	storage := d.getStorage()
+	// 重装系统时，需要删除以前的系统盘
+	if _, err := d.Delete(ctx, api.DiskDeleteInput{}); err != nil {
+		return nil, errors.Wrap(err, "delete previous disk")
+	}
+	_, err = storage.GetClient().CreateLvol(storage.SpdkLvstore, d.Id, sizeMb, true)
+	if err != nil {
+		return nil, errors.Wrapf(err, "CreateLvol %s", d.Id)
+	}
+	err = storage.withNbdDevice(d.Id, func(dev string) error {
+		output, err := procutils.NewRemoteCommandAsFarAsPossible(qemutils.GetQemuImg(),
+			"convert", "-n", "-O", "raw", cacheImagePath, dev).Output()
+		if err != nil {
+			return errors.Wrapf(err, "qemu-img convert %s to %s: %s", cacheImagePath, dev, output)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := d.ensureVhostController(); err != nil {
+		return nil, err
+	}
+	return d.GetDiskDesc(), nil
+}
+
+func (d *SSpdkDisk) CreateFromImageFuse(ctx context.Context, url string, size int64) error {
+	return fmt.Errorf("Not support")
+}
+
+func (d *SSpdkDisk) CreateRaw(ctx context.Context, sizeMb int, diskFromat string, fsFormat string, encryptInfo *apis.SEncryptInfo, diskId string, back string) (jsonutils.JSONObject, error) {
+	if encryptInfo != nil {
+		return nil, errors.Wrap(httperrors.ErrNotSupported, "spdk not support encryptInfo")
+	}
+	storage := d.getStorage()
+	_, err := storage.GetClient().CreateLvol(storage.SpdkLvstore, d.Id, int64(sizeMb), true)
+	if err != nil {
+		return nil, errors.Wrapf(err, "CreateLvol %s", d.Id)
+	}
+
+	if utils.IsInStringArray(fsFormat, []string{"swap", "ext2", "ext3", "ext4", "xfs"}) {
+		err = storage.withNbdDevice(d.Id, func(dev string) error {
+			d.FormatFs(fsFormat, diskId, dev)
+			return nil
+		})
+		if err != nil {
+			log.Errorf("format disk %s: %v", d.Id, err)
+		}
+	}
+
+	if err := d.ensureVhostController(); err != nil {
+		return nil, err
+	}
+	return d.GetDiskDesc(), nil
+}
+
+func (d *SSpdkDisk) PostCreateFromImageFuse() {
+	log.Errorf("Not support PostCreateFromImageFuse")
+}
+
+// DeployGuestFs deploys the lvol through host nbd device, as vhost socket is not a block device
+func (d *SSpdkDisk) DeployGuestFs(diskPath string, guestDesc *jsonutils.JSONDict,
+	deployInfo *deployapi.DeployInfo) (jsonutils.JSONObject, error) {
+	var ret jsonutils.JSONObject
+	err := d.getStorage().withNbdDevice(d.Id, func(dev string) error {
+		var err error
+		ret, err = d.SBaseDisk.DeployGuestFs(dev, guestDesc, deployInfo)
+		return err
+	})
+	return ret, err
+}
+
+func (d *SSpdkDisk) CreateSnapshot(snapshotId string) error {
+	return fmt.Errorf("Not support")
+}
+
+func (d *SSpdkDisk) DeleteSnapshot(snapshotId, convertSnapshot string, pendingDelete bool) error {
+	return fmt.Errorf("Not support")
+}
@@ -0,0 +1,263 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storageman
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/gotypes"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudprovider"
+	"yunion.io/x/onecloud/pkg/hostman/hostutils"
+	modules "yunion.io/x/onecloud/pkg/mcclient/modules/compute"
+	"yunion.io/x/onecloud/pkg/util/spdk"
+)
+
+type sSpdkStorageConf struct {
+	SpdkLvstore   string
+	SpdkRpcSocket string
+	SpdkVhostDir  string
+}
+
+// SSpdkStorage manages logical volumes on a lvstore of local spdk target,
+// disks are exported to qemu as vhost-user-blk controllers
+type SSpdkStorage struct {
+	SBaseStorage
+	sSpdkStorageConf
+}
+
+func NewSpdkStorage(manager *SStorageManager, path string) *SSpdkStorage {
+	var ret = new(SSpdkStorage)
+	ret.SBaseStorage = *NewBaseStorage(manager, path)
+	return ret
+}
+
+type SSpdkStorageFactory struct {
+}
+
+func (factory *SSpdkStorageFactory) NewStorage(manager *SStorageManager, mountPoint string) IStorage {
+	return NewSpdkStorage(manager, mountPoint)
+}
+
+func (factory *SSpdkStorageFactory) StorageType() string {
+	return api.STORAGE_SPDK
+}
+
+func init() {
+	registerStorageFactory(&SSpdkStorageFactory{})
+}
+
+func (s *SSpdkStorage) StorageType() string {
+	return api.STORAGE_SPDK
+}
+
+func (s *SSpdkStorage) GetClient() *spdk.SClient {
+	return spdk.NewClient(s.SpdkRpcSocket)
+}
+
+func (s *SSpdkStorage) SetStorageInfo(storageId, storageName string, conf jsonutils.JSONObject) error {
+	s.StorageId = storageId
+	s.StorageName = storageName
+	if gotypes.IsNil(conf) {
+		return fmt.Errorf("empty storage conf for storage %s(%s)", storageName, storageId)
+	}
+	if dconf, ok := conf.(*jsonutils.JSONDict); ok {
+		s.StorageConf = dconf
+	}
+	conf.Unmarshal(&s.sSpdkStorageConf)
+	if len(s.SpdkLvstore) == 0 {
+		return fmt.Errorf("empty spdk_lvstore for storage %s(%s)", storageName, storageId)
+	}
+	if len(s.SpdkRpcSocket) == 0 {
+		s.SpdkRpcSocket = api.SPDK_DEFAULT_RPC_SOCKET
+	}
+	if len(s.SpdkVhostDir) == 0 {
+		s.SpdkVhostDir = api.SPDK_DEFAULT_VHOST_DIR
+	}
+	return nil
+}
+
+func (s *SSpdkStorage) getLvstore() (*spdk.SLvstore, error) {
+	return s.GetClient().GetLvstore(s.SpdkLvstore)
+}
+
+func (s *SSpdkStorage) GetCapacity() int {
+	lvs, err := s.getLvstore()
+	if err != nil {
+		log.Errorf("get lvstore %s: %v", s.SpdkLvstore, err)
+		return -1
+	}
+	return int(lvs.TotalSizeMb())
+}
+
+func (s *SSpdkStorage) GetFreeSizeMb() int {
+	lvs, err := s.getLvstore()
+	if err != nil {
+		log.Errorf("get lvstore %s: %v", s.SpdkLvstore, err)
+		return -1
+	}
+	return int(lvs.FreeSizeMb())
+}
+
+func (s *SSpdkStorage) SyncStorageSize() error {
+	lvs, err := s.getLvstore()
+	if err != nil {
+		return errors.Wrapf(err, "get lvstore %s", s.SpdkLvstore)
+	}
+	content := jsonutils.NewDict()
+	content.Set("capacity", jsonutils.NewInt(lvs.TotalSizeMb()))
+	content.Set("actual_capacity_used", jsonutils.NewInt(lvs.TotalSizeMb()-lvs.FreeSizeMb()))
+	_, err = modules.Storages.Put(
+		hostutils.GetComputeSession(context.Background()),
+		s.StorageId, content)
+	return errors.Wrapf(err, "storage update")
+}
+
+func (s *SSpdkStorage) SyncStorageInfo() (jsonutils.JSONObject, error) {
+	if len(s.StorageId) == 0 {
+		return nil, fmt.Errorf("Sync spdk storage without storage id")
+	}
+	lvs, err := s.getLvstore()
+	if err != nil {
+		log.Errorf("get lvstore %s: %v", s.SpdkLvstore, err)
+		return modules.Storages.PerformAction(hostutils.GetComputeSession(context.Background()), s.StorageId, "offline", nil)
+	}
+	content := map[string]interface{}{
+		"name":                 s.StorageName,
+		"capacity":             lvs.TotalSizeMb(),
+		"actual_capacity_used": lvs.TotalSizeMb() - lvs.FreeSizeMb(),
+		"status":               api.STORAGE_ONLINE,
+		"zone":                 s.GetZoneId(),
+	}
+	return modules.Storages.Put(hostutils.GetComputeSession(context.Background()), s.StorageId, jsonutils.Marshal(content))
+}
+
+func (s *SSpdkStorage) Accessible() error {
+	_, err := s.getLvstore()
+	return err
+}
+
+func (s *SSpdkStorage) Detach() error {
+	return nil
+}
+
+func (s *SSpdkStorage) GetDiskById(diskId string) (IDisk, error) {
+	s.DiskLock.Lock()
+	defer s.DiskLock.Unlock()
+	for i := 0; i < len(s.Disks); i++ {
+		if s.Disks[i].GetId() == diskId {
+			err := s.Disks[i].Probe()
+			if err != nil {
+				return nil, errors.Wrapf(err, "disk.Prob")
+			}
+			return s.Disks[i], nil
+		}
+	}
+	var disk = NewSpdkDisk(s, diskId)
+	if disk.Probe() == nil {
+		s.Disks = append(s.Disks, disk)
+		return disk, nil
+	}
+	return nil, cloudprovider.ErrNotFound
+}
+
+func (s *SSpdkStorage) CreateDisk(diskId string) IDisk {
+	s.DiskLock.Lock()
+	defer s.DiskLock.Unlock()
+	disk := NewSpdkDisk(s, diskId)
+	s.Disks = append(s.Disks, disk)
+	return disk
+}
+
+// getLvolName returns bdev alias of the disk, e.g. lvs0/<disk_id>
+func (s *SSpdkStorage) getLvolName(diskId string) string {
+	return spdk.LvolName(s.SpdkLvstore, diskId)
+}
+
+func (s *SSpdkStorage) getVhostCtrlr(diskId string) string {
+	return fmt.Sprintf("vhost-blk-%s", diskId)
+}
+
+// getVhostSocketPath returns the vhost-user socket created by spdk target for the disk
+func (s *SSpdkStorage) getVhostSocketPath(diskId string) string {
+	return path.Join(s.SpdkVhostDir, s.getVhostCtrlr(diskId))
+}
+
+// getDiskPath returns disk access path recorded by region, which is used to find disk by path
+func (s *SSpdkStorage) getDiskPath(diskId string) string {
+	return path.Join(s.Path, diskId)
+}
+
+// withNbdDevice exports the disk as host nbd device during callback, so that
+// host tools like qemu-img and deployer could access the lvol
+func (s *SSpdkStorage) withNbdDevice(diskId string, callback func(dev string) error) error {
+	cli := s.GetClient()
+	dev, err := cli.StartNbdDisk(s.getLvolName(diskId))
+	if err != nil {
+		return errors.Wrapf(err, "start nbd disk of %s", diskId)
+	}
+	defer func() {
+		if err := cli.StopNbdDisk(dev); err != nil {
+			log.Errorf("stop nbd disk %s of %s: %v", dev, diskId, err)
+		}
+	}()
+	return callback(dev)
+}
+
+func (s *SSpdkStorage) GetSnapshotDir() string {
+	return ""
+}
+
+func (s *SSpdkStorage) GetSnapshotPathByIds(diskId, snapshotId string) string {
+	return ""
+}
+
+func (s *SSpdkStorage) IsSnapshotExist(diskId, snapshotId string) (bool, error) {
+	return false, nil
+}
+
+func (s *SSpdkStorage) DeleteSnapshots(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	return nil, fmt.Errorf("Not support delete snapshots")
+}
+
+func (s *SSpdkStorage) CreateSnapshotFormUrl(ctx context.Context, snapshotUrl, diskId, snapshotPath string) error {
+	return fmt.Errorf("Not support")
+}
+
+func (s *SSpdkStorage) CreateDiskFromSnapshot(ctx context.Context, disk IDisk, input *SDiskCreateByDiskinfo) error {
+	return fmt.Errorf("Not support")
+}
+
+func (s *SSpdkStorage) SaveToGlance(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	return nil, fmt.Errorf("Not support")
+}
+
+func (s *SSpdkStorage) GetFuseTmpPath() string {
+	return ""
+}
+
+func (s *SSpdkStorage) GetFuseMountPath() string {
+	return ""
+}
+
+func (s *SSpdkStorage) GetImgsaveBackupPath() string {
+	return ""
+}
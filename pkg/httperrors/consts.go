@@ -68,6 +68,9 @@ const (
 	ErrConflict          = errors.Error("ConflictError")
 	ErrDuplicateId       = errors.ErrDuplicateId
 
+	ErrPreconditionFailed = errors.Error("PreconditionFailedError")
+	ErrResourceExpired    = errors.Error("ResourceExpiredError")
+
 	ErrResourceBusy   = errors.Error("ResourceBusyError")
 	ErrRequireLicense = errors.Error("RequireLicenseError")
 
@@ -157,6 +160,9 @@ var (
 		ErrConflict:          409,
 		ErrDuplicateId:       409,
 
+		ErrPreconditionFailed: 412,
+		ErrResourceExpired:    410,
+
 		ErrResourceBusy: 409,
 
 		ErrRequireLicense: 402,
@@ -172,6 +172,14 @@ func NewConflictError(msg string, params ...interface{}) *httputils.JSONClientEr
 	return httputils.NewJsonClientError(httpErrorCode[ErrConflict], string(ErrConflict), msg, params...)
 }
 
+func NewPreconditionFailedError(msg string, params ...interface{}) *httputils.JSONClientError {
+	return httputils.NewJsonClientError(httpErrorCode[ErrPreconditionFailed], string(ErrPreconditionFailed), msg, params...)
+}
+
+func NewResourceExpiredError(msg string, params ...interface{}) *httputils.JSONClientError {
+	return httputils.NewJsonClientError(httpErrorCode[ErrResourceExpired], string(ErrResourceExpired), msg, params...)
+}
+
 func NewResourceBusyError(msg string, params ...interface{}) *httputils.JSONClientError {
 	return httputils.NewJsonClientError(httpErrorCode[ErrResourceBusy], string(ErrResourceBusy), msg, params...)
 }
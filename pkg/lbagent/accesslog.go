@@ -0,0 +1,363 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lbagent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/syslog"
+	"math/rand"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/s3cli"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	loggerapi "yunion.io/x/onecloud/pkg/apis/logger"
+	agentmodels "yunion.io/x/onecloud/pkg/lbagent/models"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
+	"yunion.io/x/onecloud/pkg/mcclient/models"
+	loggermodules "yunion.io/x/onecloud/pkg/mcclient/modules/logger"
+)
+
+// AccessLogProvider collects listener access logs haproxy sends to
+// AccessLogSocket()
+type AccessLogProvider interface {
+	AccessLogSocket() string
+	UseCorpus(corpus *agentmodels.LoadbalancerCorpus, agentParams *agentmodels.AgentParams)
+}
+
+type accessLogListener struct {
+	loadbalancerId string
+	tenantId       string
+	domainId       string
+	sampleRate     int
+
+	backendGroupIds map[string]string
+}
+
+type accessLogSink interface {
+	Ship(ctx context.Context, entries []*loggerapi.LbAccessLogEntry) error
+	Close()
+}
+
+type AccessLogHelper struct {
+	opts *Options
+
+	mu        sync.Mutex
+	listeners map[string]accessLogListener
+	params    models.LoadbalancerAgentParamsAccessLog
+	sink      accessLogSink
+
+	entryChan chan *loggerapi.LbAccessLogEntry
+	dropped   int
+}
+
+func NewAccessLogHelper(opts *Options) (*AccessLogHelper, error) {
+	helper := &AccessLogHelper{
+		opts:      opts,
+		listeners: map[string]accessLogListener{},
+		entryChan: make(chan *loggerapi.LbAccessLogEntry, 4096),
+	}
+	return helper, nil
+}
+
+func (h *AccessLogHelper) AccessLogSocket() string {
+	return filepath.Join(h.opts.haproxyRunDir, "access_log.sock")
+}
+
+func (h *AccessLogHelper) UseCorpus(corpus *agentmodels.LoadbalancerCorpus, agentParams *agentmodels.AgentParams) {
+	listeners := map[string]accessLogListener{}
+	for _, listener := range corpus.LoadbalancerListeners {
+		if !listener.AccessLog {
+			continue
+		}
+		listeners[listener.Id] = accessLogListener{
+			loadbalancerId: listener.LoadbalancerId,
+			tenantId:       listener.ProjectId,
+			domainId:       listener.DomainId,
+			sampleRate:     listener.AccessLogSampleRate,
+
+			backendGroupIds: listener.HaproxyBackendGroupIds(),
+		}
+	}
+	params := agentParams.AgentModel.Params.AccessLog
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.listeners = listeners
+	if params == h.params && (h.sink != nil || params.Sink == "") {
+		return
+	}
+	if h.sink != nil {
+		h.sink.Close()
+		h.sink = nil
+	}
+	h.params = params
+	if params.Sink == "" {
+		return
+	}
+	sink, err := h.newSink(&params)
+	if err != nil {
+		log.Errorf("access log: making %s sink: %v", params.Sink, err)
+		return
+	}
+	h.sink = sink
+}
+
+func (h *AccessLogHelper) newSink(params *models.LoadbalancerAgentParamsAccessLog) (accessLogSink, error) {
+	switch params.Sink {
+	case api.LB_ACCESS_LOG_SINK_LOGGER:
+		return &accessLogLoggerSink{region: h.opts.CommonOptions.Region}, nil
+	case api.LB_ACCESS_LOG_SINK_S3:
+		return newAccessLogS3Sink(params, h.opts.ApiLbagentId)
+	case api.LB_ACCESS_LOG_SINK_SYSLOG:
+		return newAccessLogSyslogSink(params)
+	default:
+		return nil, errors.Errorf("unknown sink %q", params.Sink)
+	}
+}
+
+// accept fills in listener info and returns false if the entry should be
+// dropped as the result of sampling or the listener being unknown
+func (h *AccessLogHelper) accept(entry *loggerapi.LbAccessLogEntry, backend string) bool {
+	h.mu.Lock()
+	listener, ok := h.listeners[entry.ListenerId]
+	h.mu.Unlock()
+	if !ok {
+		return false
+	}
+	if listener.sampleRate > 0 && listener.sampleRate < 100 && rand.Intn(100) >= listener.sampleRate {
+		return false
+	}
+	entry.LoadbalancerId = listener.loadbalancerId
+	entry.OwnerTenantId = listener.tenantId
+	entry.OwnerDomainId = listener.domainId
+	entry.BackendGroupId = listener.backendGroupIds[backend]
+	return true
+}
+
+func (h *AccessLogHelper) recv(ctx context.Context, conn *net.UnixConn) {
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := conn.ReadFromUnix(buf)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Errorf("access log: read: %v", err)
+			}
+			return
+		}
+		entry, backend, err := agentmodels.ParseHaproxyAccessLog(string(buf[:n]))
+		if err != nil {
+			log.Debugf("access log: ignore message: %v", err)
+			continue
+		}
+		if !h.accept(entry, backend) {
+			continue
+		}
+		select {
+		case h.entryChan <- entry:
+		default:
+			// shipping cannot keep up, drop it instead of blocking haproxy
+			h.mu.Lock()
+			h.dropped++
+			h.mu.Unlock()
+		}
+	}
+}
+
+func (h *AccessLogHelper) flush(ctx context.Context, entries []*loggerapi.LbAccessLogEntry) {
+	h.mu.Lock()
+	sink := h.sink
+	dropped := h.dropped
+	h.dropped = 0
+	h.mu.Unlock()
+	if dropped > 0 {
+		log.Warningf("access log: %d entries dropped because of full queue", dropped)
+	}
+	if len(entries) == 0 || sink == nil {
+		return
+	}
+	// failed batches are not retried to keep memory usage bounded
+	if err := sink.Ship(ctx, entries); err != nil {
+		log.Errorf("access log: shipping %d entries: %v", len(entries), err)
+	}
+}
+
+func (h *AccessLogHelper) Run(ctx context.Context) {
+	defer func() {
+		log.Infof("access log helper bye")
+		wg := ctx.Value("wg").(*sync.WaitGroup)
+		wg.Done()
+	}()
+
+	sockPath := h.AccessLogSocket()
+	if err := os.Remove(sockPath); err != nil && !os.IsNotExist(err) {
+		log.Errorf("access log: remove stale socket %s: %v", sockPath, err)
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sockPath, Net: "unixgram"})
+	if err != nil {
+		log.Errorf("access log: listen on %s: %v", sockPath, err)
+		return
+	}
+	defer conn.Close()
+	go h.recv(ctx, conn)
+
+	var (
+		entries   []*loggerapi.LbAccessLogEntry
+		lastFlush = time.Now()
+		ticker    = time.NewTicker(time.Second)
+	)
+	defer ticker.Stop()
+	for {
+		select {
+		case entry := <-h.entryChan:
+			entries = append(entries, entry)
+			h.mu.Lock()
+			batchSize := h.params.BatchSize
+			h.mu.Unlock()
+			if len(entries) >= batchSize {
+				h.flush(ctx, entries)
+				entries = nil
+				lastFlush = time.Now()
+			}
+		case now := <-ticker.C:
+			h.mu.Lock()
+			interval := time.Duration(h.params.FlushInterval) * time.Second
+			h.mu.Unlock()
+			if now.Sub(lastFlush) >= interval {
+				h.flush(ctx, entries)
+				entries = nil
+				lastFlush = now
+			}
+		case <-ctx.Done():
+			h.flush(context.Background(), entries)
+			h.mu.Lock()
+			if h.sink != nil {
+				h.sink.Close()
+				h.sink = nil
+			}
+			h.mu.Unlock()
+			return
+		}
+	}
+}
+
+type accessLogLoggerSink struct {
+	region string
+}
+
+func (sink *accessLogLoggerSink) Ship(ctx context.Context, entries []*loggerapi.LbAccessLogEntry) error {
+	s := auth.GetAdminSession(ctx, sink.region, "v2")
+	params := jsonutils.NewDict()
+	params.Set("logs", jsonutils.Marshal(entries))
+	_, err := loggermodules.LbAccessLogs.PerformClassAction(s, "upload", params)
+	if err != nil {
+		return errors.Wrap(err, "upload")
+	}
+	return nil
+}
+
+func (sink *accessLogLoggerSink) Close() {
+}
+
+type accessLogS3Sink struct {
+	client  *s3cli.Client
+	bucket  string
+	prefix  string
+	agentId string
+}
+
+func newAccessLogS3Sink(params *models.LoadbalancerAgentParamsAccessLog, agentId string) (*accessLogS3Sink, error) {
+	client, err := s3cli.New(params.S3Endpoint, params.S3AccessKey, params.S3SecretKey, params.S3UseSsl, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "s3cli.New")
+	}
+	sink := &accessLogS3Sink{
+		client:  client,
+		bucket:  params.S3Bucket,
+		prefix:  params.S3Prefix,
+		agentId: agentId,
+	}
+	return sink, nil
+}
+
+// Ship writes entries as json lines into one object keyed by date, agent
+// id and time of shipping
+func (sink *accessLogS3Sink) Ship(ctx context.Context, entries []*loggerapi.LbAccessLogEntry) error {
+	buf := &bytes.Buffer{}
+	for _, entry := range entries {
+		buf.WriteString(jsonutils.Marshal(entry).String())
+		buf.WriteByte('\n')
+	}
+	now := time.Now().UTC()
+	key := path.Join(
+		sink.prefix,
+		now.Format("2006/01/02"),
+		fmt.Sprintf("%s-%d.json", sink.agentId, now.UnixNano()),
+	)
+	_, err := sink.client.PutObjectWithContext(ctx, sink.bucket, key, buf, int64(buf.Len()), s3cli.PutObjectOptions{
+		ContentType: "application/x-ndjson",
+	})
+	if err != nil {
+		return errors.Wrapf(err, "put object %s", key)
+	}
+	return nil
+}
+
+func (sink *accessLogS3Sink) Close() {
+}
+
+type accessLogSyslogSink struct {
+	w *syslog.Writer
+}
+
+func newAccessLogSyslogSink(params *models.LoadbalancerAgentParamsAccessLog) (*accessLogSyslogSink, error) {
+	u, err := url.Parse(params.SyslogAddr)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse syslog addr")
+	}
+	addr := u.Host
+	if u.Scheme == "unix" || u.Scheme == "unixgram" {
+		addr = u.Path
+	}
+	w, err := syslog.Dial(u.Scheme, addr, syslog.LOG_INFO|syslog.LOG_LOCAL0, agentmodels.HaproxyAccessLogTag)
+	if err != nil {
+		return nil, errors.Wrap(err, "syslog.Dial")
+	}
+	return &accessLogSyslogSink{w: w}, nil
+}
+
+func (sink *accessLogSyslogSink) Ship(ctx context.Context, entries []*loggerapi.LbAccessLogEntry) error {
+	for _, entry := range entries {
+		if err := sink.w.Info(jsonutils.Marshal(entry).String()); err != nil {
+			return errors.Wrap(err, "syslog write")
+		}
+	}
+	return nil
+}
+
+func (sink *accessLogSyslogSink) Close() {
+	sink.w.Close()
+}
@@ -86,6 +86,12 @@ func (h *ApiHelper) Run(ctx context.Context) {
 		case state := <-h.haStateProvider.StateChannel():
 			switch state {
 			case api.LB_HA_STATE_BACKUP:
+				if h.agentParams != nil && h.agentParams.HaMode == api.LB_HA_MODE_BGP {
+					// all nodes are active in bgp mode.  It must be
+					// keepalived saying goodbye
+					log.Warningf("ignore ha state %s in bgp mode", state)
+					break
+				}
 				h.doStopDaemons(ctx)
 			default:
 				if state != h.haState {
@@ -356,6 +362,22 @@ func (h *ApiHelper) saveCorpus(ctx context.Context) error {
 	return err
 }
 
+func (h *ApiHelper) clusterPeek(ctx context.Context, agent *models.LoadbalancerAgent) (*models.LoadbalancerCluster, error) {
+	cluster := &models.LoadbalancerCluster{}
+	if agent.ClusterId == "" {
+		return cluster, nil
+	}
+	s := h.adminClientSession(ctx)
+	data, err := modules.LoadbalancerClusters.Get(s, agent.ClusterId, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cluster get error: %s", err)
+	}
+	if err := data.Unmarshal(cluster); err != nil {
+		return nil, fmt.Errorf("cluster data unmarshal error: %s", err)
+	}
+	return cluster, nil
+}
+
 func (h *ApiHelper) doSyncAgentParams(ctx context.Context) bool {
 	agent, err := h.agentPeekOnce(ctx)
 	if err != nil {
@@ -379,6 +401,11 @@ func (h *ApiHelper) doSyncAgentParams(ctx context.Context) bool {
 		unicastPeer = append(unicastPeer, peer.IP)
 	}
 	useUnicast := len(unicastPeer) == len(peers)-1
+	cluster, err := h.clusterPeek(ctx, agent)
+	if err != nil {
+		log.Errorf("agent get cluster failure: %s", err)
+		return false
+	}
 
 	agentParams, err := agentmodels.NewAgentParams(agent)
 	if err != nil {
@@ -389,10 +416,15 @@ func (h *ApiHelper) doSyncAgentParams(ctx context.Context) bool {
 	if useUnicast {
 		agentParams.SetVrrpParams("unicast_peer", unicastPeer)
 	}
+	if err := agentParams.SetClusterParams(cluster); err != nil {
+		log.Errorf("agent params prepare failure: %s", err)
+		return false
+	}
 	if !agentParams.Equals(h.agentParams) {
 		if useUnicast {
 			log.Infof("use unicast vrrp from %s to %s", agent.IP, strings.Join(unicastPeer, ","))
 		}
+		log.Infof("use data plane %s, ha mode %s", agentParams.DataPlane, agentParams.HaMode)
 		h.agentParams = agentParams
 		return true
 	}
@@ -0,0 +1,227 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lbagent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"yunion.io/x/log"
+
+	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
+	agentmodels "yunion.io/x/onecloud/pkg/lbagent/models"
+	agentutils "yunion.io/x/onecloud/pkg/lbagent/utils"
+)
+
+// bgpVipInterface holds loadbalancer addresses in bgp mode so that the
+// kernel takes packets routed to them by peers as local
+const bgpVipInterface = "lbvip0"
+
+func (h *HaproxyHelper) birdConf() string {
+	return filepath.Join(h.opts.haproxyConfigDir, agentmodels.BirdConfFile)
+}
+
+func (h *HaproxyHelper) birdPidFile() *agentutils.PidFile {
+	pf := agentutils.NewPidFile(
+		filepath.Join(h.opts.haproxyRunDir, "bird.pid"),
+		"bird",
+	)
+	return pf
+}
+
+func (h *HaproxyHelper) birdCtlSocketFile() string {
+	return filepath.Join(h.opts.haproxyRunDir, "bird.ctl")
+}
+
+// useBgpConfigs announces addresses with bird.  keepalived left from vrrp
+// mode is stopped
+func (h *HaproxyHelper) useBgpConfigs(ctx context.Context, d string, agentParams *agentmodels.AgentParams, addresses []string) error {
+	birdConf := h.birdConf()
+	if err := os.RemoveAll(birdConf); err != nil {
+		return err
+	}
+	if err := os.Symlink(filepath.Join(d, agentmodels.BirdConfFile), birdConf); err != nil {
+		return err
+	}
+	h.stopDaemons(
+		h.keepalivedPidFile(),
+		h.keepalivedVrrpPidFile(),
+		h.keepalivedCheckersPidFile(),
+	)
+	{
+		// answer arp only for addresses on the receiving interface, not
+		// those of bgpVipInterface
+		args := []string{
+			"sysctl", "-w",
+			"net.ipv4.conf.all.arp_ignore=1",
+			"net.ipv4.conf.all.arp_announce=2",
+		}
+		if err := h.runCmd(args); err != nil {
+			return fmt.Errorf("sysctl: %s", err)
+		}
+	}
+	if err := h.syncBgpVips(addresses); err != nil {
+		return err
+	}
+	if err := h.reloadBird(ctx); err != nil {
+		return err
+	}
+	h.bgpDataPlane = agentParams.DataPlane
+	// check now and enable or disable announcement explicitly
+	h.bgpHaState = ""
+	h.bgpHealthCheck(ctx)
+	return nil
+}
+
+func (h *HaproxyHelper) reloadBird(ctx context.Context) error {
+	pidFile := h.birdPidFile()
+	{
+		_, confirmed, err := pidFile.ConfirmOrUnlink()
+		if confirmed {
+			log.Infof("reloading bird")
+			return h.runCmd([]string{
+				h.opts.BirdcBin,
+				"-s", h.birdCtlSocketFile(),
+				"configure",
+			})
+		}
+		if err != nil {
+			log.Warningln(err.Error())
+		}
+	}
+	log.Infof("starting bird")
+	args := []string{
+		h.opts.BirdBin,
+		"-c", h.birdConf(),
+		"-s", h.birdCtlSocketFile(),
+		"-P", pidFile.Path,
+	}
+	return h.runCmd(args)
+}
+
+// syncBgpVips makes addresses the only ones on bgpVipInterface.  The
+// interface is removed when there are none
+func (h *HaproxyHelper) syncBgpVips(addresses []string) error {
+	iface, err := net.InterfaceByName(bgpVipInterface)
+	if len(addresses) == 0 {
+		if err != nil {
+			return nil
+		}
+		return h.runCmd([]string{"ip", "link", "del", bgpVipInterface})
+	}
+	if err != nil {
+		if err := h.runCmd([]string{"ip", "link", "add", bgpVipInterface, "type", "dummy"}); err != nil {
+			return err
+		}
+		iface, err = net.InterfaceByName(bgpVipInterface)
+		if err != nil {
+			return err
+		}
+	}
+	if err := h.runCmd([]string{"ip", "link", "set", bgpVipInterface, "up"}); err != nil {
+		return err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return fmt.Errorf("list addresses of %s: %s", bgpVipInterface, err)
+	}
+	want := map[string]bool{}
+	for _, addr := range addresses {
+		want[addr+"/32"] = true
+	}
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.To4() == nil {
+			continue
+		}
+		s := ipnet.String()
+		if want[s] {
+			delete(want, s)
+			continue
+		}
+		if err := h.runCmd([]string{"ip", "addr", "del", s, "dev", bgpVipInterface}); err != nil {
+			return err
+		}
+	}
+	for s := range want {
+		if err := h.runCmd([]string{"ip", "addr", "add", s, "dev", bgpVipInterface}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *HaproxyHelper) bgpDataPlaneHealthy() bool {
+	var pidFile *agentutils.PidFile
+	switch h.bgpDataPlane {
+	case computeapi.LB_DATA_PLANE_ENVOY:
+		pidFile = h.envoyPidFile()
+	default:
+		pidFile = h.haproxyPidFile()
+	}
+	_, confirmed, err := pidFile.ConfirmOrUnlink()
+	if err != nil {
+		log.Warningln(err.Error())
+	}
+	return confirmed
+}
+
+// bgpHealthCheck withdraws routes when the data plane is down and
+// announces them again when it comes back.  The result is reported as ha
+// state: MASTER when announcing, FAULT otherwise
+func (h *HaproxyHelper) bgpHealthCheck(ctx context.Context) {
+	var (
+		state = computeapi.LB_HA_STATE_MASTER
+		cmd   = "enable"
+	)
+	if !h.bgpDataPlaneHealthy() {
+		state = computeapi.LB_HA_STATE_FAULT
+		cmd = "disable"
+	}
+	if state == h.bgpHaState {
+		return
+	}
+	args := []string{
+		h.opts.BirdcBin,
+		"-s", h.birdCtlSocketFile(),
+		cmd, agentmodels.BirdVipProtocol,
+	}
+	if err := h.runCmd(args); err != nil {
+		// retry on next check
+		log.Errorf("bgp: %s", err)
+		return
+	}
+	log.Infof("bgp: %s %s routes, ha state %s", cmd, h.bgpDataPlane, state)
+	h.bgpHaState = state
+	if err := h.writeHaState(state); err != nil {
+		log.Errorf("bgp: write ha state: %s", err)
+	}
+}
+
+// writeHaState reports state the same way as keepalived notify script
+// does so that HaStateWatcher picks it up
+func (h *HaproxyHelper) writeHaState(state string) error {
+	p := filepath.Join(h.opts.haproxyRunDir, HA_STATE_FILENAME)
+	data := []byte(fmt.Sprintf("BGP YunionLB %s\n", state))
+	if old, err := ioutil.ReadFile(p); err == nil && bytes.Equal(old, data) {
+		return nil
+	}
+	return ioutil.WriteFile(p, data, agentutils.FileModeFile)
+}
@@ -0,0 +1,160 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lbagent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"yunion.io/x/log"
+
+	"yunion.io/x/onecloud/pkg/lbagent/envoy"
+	agentmodels "yunion.io/x/onecloud/pkg/lbagent/models"
+	agentutils "yunion.io/x/onecloud/pkg/lbagent/utils"
+)
+
+// envoyConfD is a plain directory envoy watches.  Resource files from the
+// most recent config dir are moved into it to trigger hot reloads
+func (h *HaproxyHelper) envoyConfD() string {
+	return filepath.Join(h.opts.haproxyConfigDir, "envoy.d")
+}
+
+func (h *HaproxyHelper) envoyBootstrapConf() string {
+	return filepath.Join(h.opts.haproxyConfigDir, "envoy-bootstrap.json")
+}
+
+func (h *HaproxyHelper) envoyPidFile() *agentutils.PidFile {
+	pf := agentutils.NewPidFile(
+		filepath.Join(h.opts.haproxyRunDir, "envoy.pid"),
+		"envoy",
+	)
+	return pf
+}
+
+func (h *HaproxyHelper) envoyAdminSocketFile() string {
+	return filepath.Join(h.opts.haproxyRunDir, "envoy-admin.sock")
+}
+
+func (h *HaproxyHelper) envoyBootstrap(agentParams *agentmodels.AgentParams) *envoy.Bootstrap {
+	configSource := func(fn string) envoy.ConfigSource {
+		return envoy.ConfigSource{
+			ResourceApiVersion: "V3",
+			PathConfigSource: envoy.PathConfigSource{
+				Path: filepath.Join(h.envoyConfD(), fn),
+				WatchedDirectory: &envoy.WatchedDirectory{
+					Path: h.envoyConfD(),
+				},
+			},
+		}
+	}
+	agent := agentParams.AgentModel
+	return &envoy.Bootstrap{
+		Node: envoy.Node{
+			Id:      agent.Id,
+			Cluster: agent.ClusterId,
+		},
+		Admin: envoy.Admin{
+			Address: envoy.Address{
+				Pipe: &envoy.Pipe{Path: h.envoyAdminSocketFile()},
+			},
+		},
+		DynamicResources: envoy.DynamicResources{
+			LdsConfig: configSource(agentmodels.EnvoyLdsFile),
+			CdsConfig: configSource(agentmodels.EnvoyCdsFile),
+		},
+	}
+}
+
+// useEnvoyConfigs moves resource files of config dir d into envoyConfD.
+// Clusters go first so that new listeners can find them
+func (h *HaproxyHelper) useEnvoyConfigs(ctx context.Context, d string) error {
+	confD := h.envoyConfD()
+	if err := os.MkdirAll(confD, agentutils.FileModeDir); err != nil {
+		return fmt.Errorf("mkdir %s: %s", confD, err)
+	}
+	for _, fn := range []string{
+		agentmodels.EnvoyCdsFile,
+		agentmodels.EnvoyLdsFile,
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(d, fn))
+		if err != nil {
+			return err
+		}
+		tmp := filepath.Join(confD, "."+fn+".tmp")
+		if err := ioutil.WriteFile(tmp, data, agentutils.FileModeFile); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, filepath.Join(confD, fn)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reloadEnvoy starts envoy when it's not running or the bootstrap config
+// changed.  Otherwise envoy picks up resources changes by itself without
+// dropping existing connections
+func (h *HaproxyHelper) reloadEnvoy(ctx context.Context, agentParams *agentmodels.AgentParams) error {
+	bootstrap, err := json.MarshalIndent(h.envoyBootstrap(agentParams), "", "  ")
+	if err != nil {
+		return err
+	}
+	bootstrapConf := h.envoyBootstrapConf()
+	bootstrapChanged := true
+	if old, err := ioutil.ReadFile(bootstrapConf); err == nil && bytes.Equal(old, bootstrap) {
+		bootstrapChanged = false
+	}
+	if bootstrapChanged {
+		if err := ioutil.WriteFile(bootstrapConf, bootstrap, agentutils.FileModeFile); err != nil {
+			return fmt.Errorf("writing %s: %s", bootstrapConf, err)
+		}
+	}
+
+	pidFile := h.envoyPidFile()
+	{
+		proc, confirmed, err := pidFile.ConfirmOrUnlink()
+		if confirmed {
+			if !bootstrapChanged {
+				return nil
+			}
+			log.Infof("stopping envoy(%d) for bootstrap change", proc.Pid)
+			proc.Kill()
+			proc.Wait()
+		}
+		if err != nil {
+			log.Warningln(err.Error())
+		}
+	}
+	args := []string{
+		h.opts.EnvoyBin,
+		"--config-path", bootstrapConf,
+		"--use-dynamic-base-id",
+	}
+	log.Infof("starting envoy")
+	cmd, err := h.startCmd(args)
+	if err != nil {
+		return err
+	}
+	err = agentutils.WritePidFile(cmd.Process.Pid, pidFile.Path)
+	if err != nil {
+		return fmt.Errorf("writing envoy pid file: %s", err)
+	}
+	return nil
+}
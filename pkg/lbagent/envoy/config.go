@@ -0,0 +1,429 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoy
+
+const (
+	TypeListener              = "type.googleapis.com/envoy.config.listener.v3.Listener"
+	TypeCluster               = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
+	TypeTcpProxy              = "type.googleapis.com/envoy.extensions.filters.network.tcp_proxy.v3.TcpProxy"
+	TypeUdpProxy              = "type.googleapis.com/envoy.extensions.filters.udp.udp_proxy.v3.UdpProxyConfig"
+	TypeHttpConnectionManager = "type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager"
+	TypeRouter                = "type.googleapis.com/envoy.extensions.filters.http.router.v3.Router"
+	TypeNetworkRBAC           = "type.googleapis.com/envoy.extensions.filters.network.rbac.v3.RBAC"
+	TypeHttpRBAC              = "type.googleapis.com/envoy.extensions.filters.http.rbac.v3.RBAC"
+	TypeCompressor            = "type.googleapis.com/envoy.extensions.filters.http.compressor.v3.Compressor"
+	TypeGzip                  = "type.googleapis.com/envoy.extensions.compression.gzip.compressor.v3.Gzip"
+	TypeDownstreamTlsContext  = "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.DownstreamTlsContext"
+	TypeUpstreamTlsContext    = "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.UpstreamTlsContext"
+	TypeProxyProtocolUpstream = "type.googleapis.com/envoy.extensions.transport_sockets.proxy_protocol.v3.ProxyProtocolUpstreamTransport"
+	TypeRawBuffer             = "type.googleapis.com/envoy.extensions.transport_sockets.raw_buffer.v3.RawBuffer"
+	TypeHttpProtocolOptions   = "type.googleapis.com/envoy.extensions.upstreams.http.v3.HttpProtocolOptions"
+
+	HttpProtocolOptionsName = "envoy.extensions.upstreams.http.v3.HttpProtocolOptions"
+)
+
+// Bootstrap is the static part of envoy config.  Listeners and clusters
+// are loaded from files referred to by DynamicResources
+type Bootstrap struct {
+	Node             Node             `json:"node"`
+	Admin            Admin            `json:"admin"`
+	DynamicResources DynamicResources `json:"dynamic_resources"`
+}
+
+type Node struct {
+	Id      string `json:"id"`
+	Cluster string `json:"cluster"`
+}
+
+type Admin struct {
+	Address Address `json:"address"`
+}
+
+type DynamicResources struct {
+	LdsConfig ConfigSource `json:"lds_config"`
+	CdsConfig ConfigSource `json:"cds_config"`
+}
+
+type ConfigSource struct {
+	ResourceApiVersion string           `json:"resource_api_version"`
+	PathConfigSource   PathConfigSource `json:"path_config_source"`
+}
+
+// PathConfigSource tells envoy to reload the file at Path whenever files
+// are moved into WatchedDirectory, which is how symlinks are swapped
+type PathConfigSource struct {
+	Path             string            `json:"path"`
+	WatchedDirectory *WatchedDirectory `json:"watched_directory,omitempty"`
+}
+
+type WatchedDirectory struct {
+	Path string `json:"path"`
+}
+
+// DiscoveryResponse is the content of file based xDS resources
+type DiscoveryResponse struct {
+	VersionInfo string        `json:"version_info"`
+	Resources   []interface{} `json:"resources"`
+}
+
+type Address struct {
+	SocketAddress *SocketAddress `json:"socket_address,omitempty"`
+	Pipe          *Pipe          `json:"pipe,omitempty"`
+}
+
+type SocketAddress struct {
+	// TCP | UDP
+	Protocol  string `json:"protocol,omitempty"`
+	Address   string `json:"address"`
+	PortValue int    `json:"port_value"`
+}
+
+type Pipe struct {
+	Path string `json:"path"`
+}
+
+type DataSource struct {
+	Filename     string `json:"filename,omitempty"`
+	InlineString string `json:"inline_string,omitempty"`
+}
+
+type CidrRange struct {
+	AddressPrefix string `json:"address_prefix"`
+	PrefixLen     int    `json:"prefix_len"`
+}
+
+type Listener struct {
+	Type              string             `json:"@type"`
+	Name              string             `json:"name"`
+	Address           Address            `json:"address"`
+	FilterChains      []FilterChain      `json:"filter_chains,omitempty"`
+	ListenerFilters   []Filter           `json:"listener_filters,omitempty"`
+	UdpListenerConfig *UdpListenerConfig `json:"udp_listener_config,omitempty"`
+	// envoy binds to addresses not present yet on backup nodes, like
+	// haproxy with net.ipv4.ip_nonlocal_bind
+	Freebind bool `json:"freebind,omitempty"`
+}
+
+type UdpListenerConfig struct{}
+
+type FilterChain struct {
+	Filters         []Filter         `json:"filters"`
+	TransportSocket *TransportSocket `json:"transport_socket,omitempty"`
+}
+
+type Filter struct {
+	Name        string      `json:"name"`
+	TypedConfig interface{} `json:"typed_config"`
+}
+
+type TransportSocket struct {
+	Name        string      `json:"name"`
+	TypedConfig interface{} `json:"typed_config"`
+}
+
+type TcpProxy struct {
+	Type        string          `json:"@type"`
+	StatPrefix  string          `json:"stat_prefix"`
+	Cluster     string          `json:"cluster"`
+	IdleTimeout string          `json:"idle_timeout,omitempty"`
+	HashPolicy  []TcpHashPolicy `json:"hash_policy,omitempty"`
+}
+
+type TcpHashPolicy struct {
+	SourceIp *struct{} `json:"source_ip,omitempty"`
+}
+
+type UdpProxy struct {
+	Type         string          `json:"@type"`
+	StatPrefix   string          `json:"stat_prefix"`
+	Cluster      string          `json:"cluster"`
+	IdleTimeout  string          `json:"idle_timeout,omitempty"`
+	HashPolicies []UdpHashPolicy `json:"hash_policies,omitempty"`
+}
+
+type UdpHashPolicy struct {
+	SourceIp bool `json:"source_ip"`
+}
+
+// RBAC is used as both network and http filter to implement acl of
+// listeners
+type RBAC struct {
+	Type       string    `json:"@type"`
+	StatPrefix string    `json:"stat_prefix"`
+	Rules      RBACRules `json:"rules"`
+}
+
+type RBACRules struct {
+	// ALLOW | DENY
+	Action   string                `json:"action"`
+	Policies map[string]RBACPolicy `json:"policies"`
+}
+
+type RBACPolicy struct {
+	Permissions []RBACPermission `json:"permissions"`
+	Principals  []RBACPrincipal  `json:"principals"`
+}
+
+type RBACPermission struct {
+	Any bool `json:"any"`
+}
+
+type RBACPrincipal struct {
+	DirectRemoteIp *CidrRange `json:"direct_remote_ip"`
+}
+
+type HttpConnectionManager struct {
+	Type                      string                     `json:"@type"`
+	StatPrefix                string                     `json:"stat_prefix"`
+	CodecType                 string                     `json:"codec_type"`
+	RouteConfig               RouteConfiguration         `json:"route_config"`
+	HttpFilters               []Filter                   `json:"http_filters"`
+	UseRemoteAddress          bool                       `json:"use_remote_address"`
+	SkipXffAppend             bool                       `json:"skip_xff_append"`
+	RequestHeadersTimeout     string                     `json:"request_headers_timeout,omitempty"`
+	CommonHttpProtocolOptions *CommonHttpProtocolOptions `json:"common_http_protocol_options,omitempty"`
+}
+
+type CommonHttpProtocolOptions struct {
+	IdleTimeout string `json:"idle_timeout,omitempty"`
+}
+
+type RouteConfiguration struct {
+	Name         string        `json:"name"`
+	VirtualHosts []VirtualHost `json:"virtual_hosts"`
+}
+
+type VirtualHost struct {
+	Name                   string              `json:"name"`
+	Domains                []string            `json:"domains"`
+	Routes                 []Route             `json:"routes"`
+	RequestHeadersToAdd    []HeaderValueOption `json:"request_headers_to_add,omitempty"`
+	RequestHeadersToRemove []string            `json:"request_headers_to_remove,omitempty"`
+}
+
+type Route struct {
+	Name                 string                `json:"name,omitempty"`
+	Match                RouteMatch            `json:"match"`
+	Route                *RouteAction          `json:"route,omitempty"`
+	Redirect             *RedirectAction       `json:"redirect,omitempty"`
+	DirectResponse       *DirectResponseAction `json:"direct_response,omitempty"`
+	RequestHeadersToAdd  []HeaderValueOption   `json:"request_headers_to_add,omitempty"`
+	ResponseHeadersToAdd []HeaderValueOption   `json:"response_headers_to_add,omitempty"`
+}
+
+type RouteMatch struct {
+	Prefix          string                  `json:"prefix,omitempty"`
+	Path            string                  `json:"path,omitempty"`
+	SafeRegex       *RegexMatcher           `json:"safe_regex,omitempty"`
+	Headers         []HeaderMatcher         `json:"headers,omitempty"`
+	QueryParameters []QueryParameterMatcher `json:"query_parameters,omitempty"`
+}
+
+type RegexMatcher struct {
+	Regex string `json:"regex"`
+}
+
+type StringMatcher struct {
+	Exact     string        `json:"exact,omitempty"`
+	SafeRegex *RegexMatcher `json:"safe_regex,omitempty"`
+}
+
+type HeaderMatcher struct {
+	Name        string        `json:"name"`
+	StringMatch StringMatcher `json:"string_match"`
+}
+
+type QueryParameterMatcher struct {
+	Name        string        `json:"name"`
+	StringMatch StringMatcher `json:"string_match"`
+}
+
+type RouteAction struct {
+	Cluster            string            `json:"cluster,omitempty"`
+	WeightedClusters   *WeightedClusters `json:"weighted_clusters,omitempty"`
+	PrefixRewrite      string            `json:"prefix_rewrite,omitempty"`
+	HostRewriteLiteral string            `json:"host_rewrite_literal,omitempty"`
+	HashPolicy         []HashPolicy      `json:"hash_policy,omitempty"`
+	// "0s" disables the default 15s timeout, which is too short for
+	// long polling and streaming grpc
+	Timeout     string `json:"timeout,omitempty"`
+	IdleTimeout string `json:"idle_timeout,omitempty"`
+}
+
+type WeightedClusters struct {
+	Clusters []ClusterWeight `json:"clusters"`
+}
+
+type ClusterWeight struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+type HashPolicy struct {
+	ConnectionProperties *HashPolicyConnectionProperties `json:"connection_properties,omitempty"`
+}
+
+type HashPolicyConnectionProperties struct {
+	SourceIp bool `json:"source_ip"`
+}
+
+type RedirectAction struct {
+	SchemeRedirect string `json:"scheme_redirect,omitempty"`
+	HostRedirect   string `json:"host_redirect,omitempty"`
+	PathRedirect   string `json:"path_redirect,omitempty"`
+	// MOVED_PERMANENTLY | FOUND | TEMPORARY_REDIRECT
+	ResponseCode string `json:"response_code,omitempty"`
+}
+
+type DirectResponseAction struct {
+	Status int         `json:"status"`
+	Body   *DataSource `json:"body,omitempty"`
+}
+
+type HeaderValueOption struct {
+	Header HeaderValue `json:"header"`
+	// APPEND_IF_EXISTS_OR_ADD | OVERWRITE_IF_EXISTS_OR_ADD
+	AppendAction string `json:"append_action,omitempty"`
+}
+
+type HeaderValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type Compressor struct {
+	Type              string                 `json:"@type"`
+	CompressorLibrary TypedExtensionConfig   `json:"compressor_library"`
+	ResponseDirection map[string]interface{} `json:"response_direction_config,omitempty"`
+}
+
+type TypedExtensionConfig struct {
+	Name        string      `json:"name"`
+	TypedConfig interface{} `json:"typed_config"`
+}
+
+// TypedOnly is for typed configs without fields set
+type TypedOnly struct {
+	Type string `json:"@type"`
+}
+
+type DownstreamTlsContext struct {
+	Type                     string           `json:"@type"`
+	CommonTlsContext         CommonTlsContext `json:"common_tls_context"`
+	RequireClientCertificate bool             `json:"require_client_certificate"`
+}
+
+type UpstreamTlsContext struct {
+	Type             string           `json:"@type"`
+	CommonTlsContext CommonTlsContext `json:"common_tls_context"`
+}
+
+type CommonTlsContext struct {
+	TlsParams         *TlsParameters            `json:"tls_params,omitempty"`
+	TlsCertificates   []TlsCertificate          `json:"tls_certificates,omitempty"`
+	ValidationContext *CertificateValidationCtx `json:"validation_context,omitempty"`
+	AlpnProtocols     []string                  `json:"alpn_protocols,omitempty"`
+}
+
+type TlsParameters struct {
+	// TLSv1_0 | TLSv1_1 | TLSv1_2 | TLSv1_3
+	TlsMinimumProtocolVersion string   `json:"tls_minimum_protocol_version,omitempty"`
+	CipherSuites              []string `json:"cipher_suites,omitempty"`
+}
+
+type TlsCertificate struct {
+	CertificateChain DataSource `json:"certificate_chain"`
+	PrivateKey       DataSource `json:"private_key"`
+}
+
+type CertificateValidationCtx struct {
+	TrustedCa DataSource `json:"trusted_ca"`
+}
+
+type ProxyProtocolUpstreamTransport struct {
+	Type            string          `json:"@type"`
+	Config          ProxyProtocol   `json:"config"`
+	TransportSocket TransportSocket `json:"transport_socket"`
+}
+
+type ProxyProtocol struct {
+	// V1 | V2
+	Version string `json:"version"`
+}
+
+type Cluster struct {
+	Type           string `json:"@type"`
+	Name           string `json:"name"`
+	DiscoveryType  string `json:"type"`
+	ConnectTimeout string `json:"connect_timeout,omitempty"`
+	// ROUND_ROBIN | LEAST_REQUEST | RING_HASH
+	LbPolicy                      string                 `json:"lb_policy"`
+	LoadAssignment                ClusterLoadAssignment  `json:"load_assignment"`
+	HealthChecks                  []HealthCheck          `json:"health_checks,omitempty"`
+	TransportSocket               *TransportSocket       `json:"transport_socket,omitempty"`
+	TypedExtensionProtocolOptions map[string]interface{} `json:"typed_extension_protocol_options,omitempty"`
+}
+
+type ClusterLoadAssignment struct {
+	ClusterName string                `json:"cluster_name"`
+	Endpoints   []LocalityLbEndpoints `json:"endpoints"`
+}
+
+type LocalityLbEndpoints struct {
+	LbEndpoints []LbEndpoint `json:"lb_endpoints"`
+}
+
+type LbEndpoint struct {
+	Endpoint            Endpoint `json:"endpoint"`
+	LoadBalancingWeight int      `json:"load_balancing_weight,omitempty"`
+}
+
+type Endpoint struct {
+	Address Address `json:"address"`
+}
+
+type HealthCheck struct {
+	Timeout            string           `json:"timeout"`
+	Interval           string           `json:"interval"`
+	HealthyThreshold   int              `json:"healthy_threshold"`
+	UnhealthyThreshold int              `json:"unhealthy_threshold"`
+	TcpHealthCheck     *TcpHealthCheck  `json:"tcp_health_check,omitempty"`
+	HttpHealthCheck    *HttpHealthCheck `json:"http_health_check,omitempty"`
+}
+
+type TcpHealthCheck struct{}
+
+type HttpHealthCheck struct {
+	Host             string       `json:"host,omitempty"`
+	Path             string       `json:"path"`
+	ExpectedStatuses []Int64Range `json:"expected_statuses,omitempty"`
+}
+
+// Int64Range is [Start, End)
+type Int64Range struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+type HttpProtocolOptions struct {
+	Type                      string                     `json:"@type"`
+	CommonHttpProtocolOptions *CommonHttpProtocolOptions `json:"common_http_protocol_options,omitempty"`
+	ExplicitHttpConfig        ExplicitHttpConfig         `json:"explicit_http_config"`
+}
+
+type ExplicitHttpConfig struct {
+	HttpProtocolOptions  *struct{} `json:"http_protocol_options,omitempty"`
+	Http2ProtocolOptions *struct{} `json:"http2_protocol_options,omitempty"`
+}
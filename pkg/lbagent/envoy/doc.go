@@ -0,0 +1,18 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package envoy defines the subset of envoy v3 api used by lbagent.  Types
+// are marshaled into the canonical json form of the protobuf messages,
+// which envoy accepts as bootstrap and as file based xDS resources
+package envoy // import "yunion.io/x/onecloud/pkg/lbagent/envoy"
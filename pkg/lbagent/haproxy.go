@@ -30,6 +30,7 @@ import (
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
 
+	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
 	agentmodels "yunion.io/x/onecloud/pkg/lbagent/models"
 	agentutils "yunion.io/x/onecloud/pkg/lbagent/utils"
 )
@@ -38,6 +39,13 @@ type HaproxyHelper struct {
 	opts *Options
 
 	configDirMan *agentutils.ConfigDirManager
+
+	accessLogProvider AccessLogProvider
+
+	// bgpDataPlane is data plane checked for health in bgp mode, empty
+	// when not in bgp mode
+	bgpDataPlane string
+	bgpHaState   string
 }
 
 func NewHaproxyHelper(opts *Options) (*HaproxyHelper, error) {
@@ -59,12 +67,18 @@ func NewHaproxyHelper(opts *Options) (*HaproxyHelper, error) {
 	return helper, nil
 }
 
+func (h *HaproxyHelper) SetAccessLogProvider(alp AccessLogProvider) {
+	h.accessLogProvider = alp
+}
+
 func (h *HaproxyHelper) Run(ctx context.Context) {
 	defer func() {
 		wg := ctx.Value("wg").(*sync.WaitGroup)
 		wg.Done()
 	}()
 	cmdChan := ctx.Value("cmdChan").(chan *LbagentCmd)
+	bgpTicker := time.NewTicker(time.Duration(h.opts.BgpHealthCheckInterval) * time.Second)
+	defer bgpTicker.Stop()
 	for {
 		for {
 			select {
@@ -73,6 +87,10 @@ func (h *HaproxyHelper) Run(ctx context.Context) {
 				return
 			case cmd := <-cmdChan:
 				h.handleCmd(ctx, cmd)
+			case <-bgpTicker.C:
+				if h.bgpDataPlane != "" {
+					h.bgpHealthCheck(ctx)
+				}
 			}
 		}
 	}
@@ -92,11 +110,20 @@ func (h *HaproxyHelper) handleCmd(ctx context.Context, cmd *LbagentCmd) {
 }
 
 func (h *HaproxyHelper) handleStopDaemonsCmd(ctx context.Context) {
-	pidFiles := []*agentutils.PidFile{
+	h.stopDaemons(
+		h.envoyPidFile(),
 		h.gobetweenPidFile(),
 		h.haproxyPidFile(),
 		h.telegrafPidFile(),
+	)
+	if h.bgpDataPlane != "" {
+		// withdraw routes as nothing is serving now
+		h.bgpDataPlane = ""
+		h.stopDaemons(h.birdPidFile())
 	}
+}
+
+func (h *HaproxyHelper) stopDaemons(pidFiles ...*agentutils.PidFile) {
 	wg := &sync.WaitGroup{}
 	wg.Add(len(pidFiles))
 
@@ -126,49 +153,79 @@ func (h *HaproxyHelper) handleStopDaemonsCmd(ctx context.Context) {
 }
 
 func (h *HaproxyHelper) handleUseCorpusCmd(ctx context.Context, cmd *LbagentCmd) {
+	cmdData := cmd.Data.(*LbagentCmdUseCorpusData)
+	corpus := cmdData.Corpus
+	agentParams := cmdData.AgentParams
+	useEnvoy := agentParams.DataPlane == computeapi.LB_DATA_PLANE_ENVOY
+	useBgp := agentParams.HaMode == computeapi.LB_HA_MODE_BGP
+	var bgpAddresses []string
 	// haproxy config dir
 	dir, err := h.configDirMan.NewDir(func(dir string) error {
-		cmdData := cmd.Data.(*LbagentCmdUseCorpusData)
-		corpus := cmdData.Corpus
-		agentParams := cmdData.AgentParams
 		{
 			opt := fmt.Sprintf("stats socket %s expose-fd listeners", h.haproxyStatsSocketFile())
 			agentParams.SetHaproxyParams("global_stats_socket", opt)
 		}
-		var genHaproxyConfigsResult *agentmodels.GenHaproxyConfigsResult
-		var err error
-		{
-			// haproxy toplevel global/defaults config
-			err = corpus.GenHaproxyToplevelConfig(dir, agentParams)
-			if err != nil {
-				err = fmt.Errorf("generating haproxy toplevel config failed: %s", err)
-				return err
-			}
+		if h.accessLogProvider != nil {
+			agentParams.SetAccessLogParams("socket", h.accessLogProvider.AccessLogSocket())
 		}
-		{
-			// haproxy configs
-			genHaproxyConfigsResult, err = corpus.GenHaproxyConfigs(dir, agentParams)
+		var loadbalancersEnabled []*agentmodels.Loadbalancer
+		if useEnvoy {
+			// envoy serves all of http, tcp and udp listeners
+			genEnvoyConfigsResult, err := corpus.GenEnvoyConfigs(dir, agentParams)
 			if err != nil {
-				err = fmt.Errorf("generating haproxy config failed: %s", err)
+				err = fmt.Errorf("generating envoy config failed: %s", err)
 				return err
 			}
+			loadbalancersEnabled = genEnvoyConfigsResult.LoadbalancersEnabled
+		} else {
+			var genHaproxyConfigsResult *agentmodels.GenHaproxyConfigsResult
+			var err error
+			{
+				// haproxy toplevel global/defaults config
+				err = corpus.GenHaproxyToplevelConfig(dir, agentParams)
+				if err != nil {
+					err = fmt.Errorf("generating haproxy toplevel config failed: %s", err)
+					return err
+				}
+			}
+			{
+				// haproxy configs
+				genHaproxyConfigsResult, err = corpus.GenHaproxyConfigs(dir, agentParams)
+				if err != nil {
+					err = fmt.Errorf("generating haproxy config failed: %s", err)
+					return err
+				}
+			}
+			{
+				// gobetween config
+				opts := &agentmodels.GenGobetweenConfigOptions{
+					LoadbalancersEnabled: genHaproxyConfigsResult.LoadbalancersEnabled,
+					AgentParams:          agentParams,
+				}
+				err := corpus.GenGobetweenConfigs(dir, opts)
+				if err != nil {
+					err = fmt.Errorf("generating gobetween config failed: %s", err)
+					return err
+				}
+			}
+			loadbalancersEnabled = genHaproxyConfigsResult.LoadbalancersEnabled
 		}
-		{
-			// gobetween config
-			opts := &agentmodels.GenGobetweenConfigOptions{
-				LoadbalancersEnabled: genHaproxyConfigsResult.LoadbalancersEnabled,
+		if useBgp {
+			// bird config
+			opts := &agentmodels.GenBirdConfigOptions{
+				LoadbalancersEnabled: loadbalancersEnabled,
 				AgentParams:          agentParams,
 			}
-			err := corpus.GenGobetweenConfigs(dir, opts)
+			genBirdConfigsResult, err := corpus.GenBirdConfigs(dir, opts)
 			if err != nil {
-				err = fmt.Errorf("generating gobetween config failed: %s", err)
+				err = fmt.Errorf("generating bird config failed: %s", err)
 				return err
 			}
-		}
-		{
+			bgpAddresses = genBirdConfigsResult.Addresses
+		} else {
 			// keepalived config
 			opts := &agentmodels.GenKeepalivedConfigOptions{
-				LoadbalancersEnabled: genHaproxyConfigsResult.LoadbalancersEnabled,
+				LoadbalancersEnabled: loadbalancersEnabled,
 				AgentParams:          agentParams,
 			}
 			err := corpus.GenKeepalivedConfigs(dir, opts)
@@ -177,6 +234,10 @@ func (h *HaproxyHelper) handleUseCorpusCmd(ctx context.Context, cmd *LbagentCmd)
 				return err
 			}
 		}
+		if h.accessLogProvider != nil {
+			// know about new listeners before haproxy starts logging for them
+			h.accessLogProvider.UseCorpus(corpus, agentParams)
+		}
 		if agentParams.AgentModel.Params.Telegraf.InfluxDbOutputUrl != "" {
 			agentParams.SetTelegrafParams("haproxy_input_stats_socket", h.haproxyStatsSocketFile())
 			// telegraf config
@@ -209,9 +270,22 @@ func (h *HaproxyHelper) handleUseCorpusCmd(ctx context.Context, cmd *LbagentCmd)
 		log.Errorf("prune configs dir failed: %s", err)
 		// continue
 	}
-	if err := h.useConfigs(ctx, dir); err != nil {
+	if useEnvoy {
+		err = h.useEnvoyDataPlane(ctx, dir, agentParams)
+	} else {
+		err = h.useConfigs(ctx, dir)
+	}
+	if err != nil {
 		log.Errorf("useConfigs: %s", err)
 	}
+	if useBgp {
+		err = h.useBgpConfigs(ctx, dir, agentParams, bgpAddresses)
+	} else {
+		err = h.useVrrpConfigs(ctx, dir)
+	}
+	if err != nil {
+		log.Errorf("use ha configs: %s", err)
+	}
 }
 
 func (h *HaproxyHelper) useConfigs(ctx context.Context, d string) error {
@@ -225,13 +299,11 @@ func (h *HaproxyHelper) useConfigs(ctx context.Context, d string) error {
 	}
 	haproxyConfD := h.haproxyConfD()
 	gobetweenJson := filepath.Join(h.opts.haproxyConfigDir, "gobetween.json")
-	keepalivedConf := filepath.Join(h.opts.haproxyConfigDir, "keepalived.conf")
 	telegrafConf := filepath.Join(h.opts.haproxyConfigDir, "telegraf.conf")
 	dirMap := map[string]string{
-		haproxyConfD:   d,
-		gobetweenJson:  filepath.Join(d, "gobetween.json"),
-		keepalivedConf: filepath.Join(d, "keepalived.conf"),
-		telegrafConf:   filepath.Join(d, "telegraf.conf"),
+		haproxyConfD:  d,
+		gobetweenJson: filepath.Join(d, "gobetween.json"),
+		telegrafConf:  filepath.Join(d, "telegraf.conf"),
 	}
 	for new, old := range dirMap {
 		err := lnF(old, new)
@@ -239,6 +311,8 @@ func (h *HaproxyHelper) useConfigs(ctx context.Context, d string) error {
 			return err
 		}
 	}
+	// envoy left from the previous data plane
+	h.stopDaemons(h.envoyPidFile())
 	{
 		var errs []error
 		var err error
@@ -256,13 +330,6 @@ func (h *HaproxyHelper) useConfigs(ctx context.Context, d string) error {
 				errs = append(errs, err)
 			}
 		}
-		{
-			// reload keepalived
-			err = h.reloadKeepalived(ctx)
-			if err != nil {
-				errs = append(errs, err)
-			}
-		}
 		if len(errs) == 0 {
 			return nil
 		}
@@ -270,6 +337,45 @@ func (h *HaproxyHelper) useConfigs(ctx context.Context, d string) error {
 	}
 }
 
+// useVrrpConfigs announces addresses with keepalived.  bird and addresses
+// left from bgp mode are removed
+func (h *HaproxyHelper) useVrrpConfigs(ctx context.Context, d string) error {
+	keepalivedConf := h.keepalivedConf()
+	if err := os.RemoveAll(keepalivedConf); err != nil {
+		return err
+	}
+	if err := os.Symlink(filepath.Join(d, "keepalived.conf"), keepalivedConf); err != nil {
+		return err
+	}
+	// bird may be left by previous runs in bgp mode
+	h.bgpDataPlane = ""
+	h.stopDaemons(h.birdPidFile())
+	if err := h.syncBgpVips(nil); err != nil {
+		log.Errorf("removing bgp addresses: %s", err)
+	}
+	return h.reloadKeepalived(ctx)
+}
+
+// useEnvoyDataPlane is useConfigs for clusters with envoy as data plane.
+// haproxy and gobetween left from the previous data plane are stopped
+func (h *HaproxyHelper) useEnvoyDataPlane(ctx context.Context, d string, agentParams *agentmodels.AgentParams) error {
+	telegrafConf := filepath.Join(h.opts.haproxyConfigDir, "telegraf.conf")
+	if err := os.RemoveAll(telegrafConf); err != nil {
+		return err
+	}
+	if err := os.Symlink(filepath.Join(d, "telegraf.conf"), telegrafConf); err != nil {
+		return err
+	}
+	if err := h.useEnvoyConfigs(ctx, d); err != nil {
+		return err
+	}
+	h.stopDaemons(
+		h.haproxyPidFile(),
+		h.gobetweenPidFile(),
+	)
+	return h.reloadEnvoy(ctx, agentParams)
+}
+
 func (h *HaproxyHelper) haproxyConfD() string {
 	return filepath.Join(h.opts.haproxyConfigDir, "haproxy.conf.d")
 }
@@ -22,6 +22,7 @@ import (
 
 	"yunion.io/x/pkg/utils"
 
+	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/mcclient/models"
 )
 
@@ -54,6 +55,15 @@ type AgentParams struct {
 	HaproxyConfigTmpl    *template.Template
 	TelegrafConfigTmpl   *template.Template
 	Data                 map[string]map[string]interface{}
+
+	// DataPlane is the data plane used by the cluster this agent is in
+	DataPlane string
+	// HaMode tells how addresses are announced, with vrrp by keepalived
+	// or with bgp by bird
+	HaMode      string
+	BgpLocalAsn int64
+	BgpPeers    []computeapi.LoadbalancerClusterBgpPeer
+	BgpHoldTime int
 }
 
 func NewAgentParams(agent *models.LoadbalancerAgent) (*AgentParams, error) {
@@ -84,6 +94,8 @@ func NewAgentParams(agent *models.LoadbalancerAgent) (*AgentParams, error) {
 		"vrrp":     dataFromParams(agent.Params.Vrrp),
 		"haproxy":  dataFromParams(agent.Params.Haproxy),
 		"telegraf": dataFromParams(agent.Params.Telegraf),
+
+		"access_log": dataFromParams(agent.Params.AccessLog),
 	}
 	agentParams := &AgentParams{
 		AgentModel:           agent,
@@ -91,6 +103,8 @@ func NewAgentParams(agent *models.LoadbalancerAgent) (*AgentParams, error) {
 		HaproxyConfigTmpl:    tmpls["haproxy_conf_tmpl"],
 		TelegrafConfigTmpl:   tmpls["telegraf_conf_tmpl"],
 		Data:                 data,
+		DataPlane:            computeapi.LB_DATA_PLANE_HAPROXY,
+		HaMode:               computeapi.LB_HA_MODE_VRRP,
 	}
 	return agentParams, nil
 }
@@ -107,6 +121,15 @@ func (p *AgentParams) Equals(p2 *AgentParams) bool {
 	if agentP.Params != agentP2.Params {
 		return false
 	}
+	if p.DataPlane != p2.DataPlane {
+		return false
+	}
+	if p.HaMode != p2.HaMode ||
+		p.BgpLocalAsn != p2.BgpLocalAsn ||
+		p.BgpHoldTime != p2.BgpHoldTime ||
+		!reflect.DeepEqual(p.BgpPeers, p2.BgpPeers) {
+		return false
+	}
 	keys := []string{"notify_script", "unicast_peer"}
 	for _, key := range keys {
 		v := p.GetVrrpParams(key)
@@ -118,6 +141,32 @@ func (p *AgentParams) Equals(p2 *AgentParams) bool {
 	return true
 }
 
+// SetClusterParams takes data plane and ha params from cluster the agent is
+// in
+func (p *AgentParams) SetClusterParams(cluster *models.LoadbalancerCluster) error {
+	if cluster.DataPlane != "" {
+		p.DataPlane = cluster.DataPlane
+	}
+	if cluster.HaMode != computeapi.LB_HA_MODE_BGP {
+		return nil
+	}
+	peers, err := computeapi.ParseLoadbalancerClusterBgpPeers(cluster.BgpPeers)
+	if err != nil {
+		return fmt.Errorf("cluster %s(%s): %s", cluster.Name, cluster.Id, err)
+	}
+	if cluster.BgpLocalAsn == 0 || len(peers) == 0 {
+		return fmt.Errorf("cluster %s(%s): bgp mode without local asn or peers", cluster.Name, cluster.Id)
+	}
+	p.HaMode = computeapi.LB_HA_MODE_BGP
+	p.BgpLocalAsn = cluster.BgpLocalAsn
+	p.BgpPeers = peers
+	p.BgpHoldTime = cluster.BgpHoldTime
+	if p.BgpHoldTime <= 0 {
+		p.BgpHoldTime = computeapi.LB_BGP_HOLD_TIME_DEFAULT
+	}
+	return nil
+}
+
 func (p *AgentParams) setXxParams(xx, k string, v interface{}) map[string]interface{} {
 	var dt map[string]interface{}
 	d, ok := p.Data[xx]
@@ -155,5 +204,13 @@ func (p *AgentParams) SetTelegrafParams(k string, v interface{}) map[string]inte
 	return p.setXxParams("telegraf", k, v)
 }
 
+func (p *AgentParams) SetAccessLogParams(k string, v interface{}) map[string]interface{} {
+	return p.setXxParams("access_log", k, v)
+}
+
+func (p *AgentParams) GetAccessLogParams(k string) interface{} {
+	return p.getXxParams("access_log", k)
+}
+
 func (p *AgentParams) KeepalivedConfig() {
 }
@@ -0,0 +1,115 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"text/template"
+
+	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
+	agentutils "yunion.io/x/onecloud/pkg/lbagent/utils"
+)
+
+const (
+	BirdConfFile = "bird.conf"
+
+	// BirdVipProtocol is name of the static protocol holding routes of
+	// loadbalancer addresses.  Disabling it withdraws them from all peers
+	BirdVipProtocol = "lbvips"
+)
+
+type GenBirdConfigOptions struct {
+	LoadbalancersEnabled []*Loadbalancer
+	AgentParams          *AgentParams
+}
+
+type GenBirdConfigsResult struct {
+	// Addresses are loadbalancer addresses announced
+	Addresses []string
+}
+
+// GenBirdConfigs writes bird.conf announcing addresses of enabled
+// loadbalancers as /32 routes to bgp peers of the cluster.  All agents of
+// the cluster announce the same routes so that peers can do ECMP among them
+func (b *LoadbalancerCorpus) GenBirdConfigs(dir string, opts *GenBirdConfigOptions) (*GenBirdConfigsResult, error) {
+	agentParams := opts.AgentParams
+	if agentParams.HaMode != computeapi.LB_HA_MODE_BGP {
+		return nil, fmt.Errorf("ha mode is %s, not bgp", agentParams.HaMode)
+	}
+	addresses := []string{}
+	{
+		seen := map[string]bool{}
+		for _, lb := range opts.LoadbalancersEnabled {
+			if lb.Status != "enabled" {
+				continue
+			}
+			if lb.Address == "" || seen[lb.Address] {
+				continue
+			}
+			seen[lb.Address] = true
+			addresses = append(addresses, lb.Address)
+		}
+		sort.Strings(addresses)
+	}
+	data := map[string]interface{}{
+		"router_id":    agentParams.AgentModel.IP,
+		"local_asn":    agentParams.BgpLocalAsn,
+		"hold_time":    agentParams.BgpHoldTime,
+		"peers":        agentParams.BgpPeers,
+		"addresses":    addresses,
+		"vip_protocol": BirdVipProtocol,
+	}
+	buf := bytes.NewBufferString("# yunion lb auto-generated bird.conf\n")
+	if err := birdConfTmpl.Execute(buf, data); err != nil {
+		return nil, err
+	}
+	p := filepath.Join(dir, BirdConfFile)
+	if err := ioutil.WriteFile(p, buf.Bytes(), agentutils.FileModeFile); err != nil {
+		return nil, err
+	}
+	return &GenBirdConfigsResult{Addresses: addresses}, nil
+}
+
+// Routes of the static protocol are never exported to kernel.  Addresses
+// are made local by lbagent itself
+var birdConfTmpl = template.Must(template.New("").Parse(`
+{{- if .router_id }}router id {{ .router_id }};
+{{ end }}
+protocol device {
+}
+
+protocol static {{ .vip_protocol }} {
+	ipv4;
+	{{- range .addresses }}
+	route {{ . }}/32 blackhole;
+	{{- end }}
+}
+{{ range $i, $peer := .peers }}
+protocol bgp peer{{ $i }} {
+	local as {{ $.local_asn }};
+	neighbor {{ $peer.Address }} as {{ $peer.Asn }};
+	hold time {{ $.hold_time }};
+	ipv4 {
+		import none;
+		export where proto = "{{ $.vip_protocol }}";
+		next hop self;
+	};
+}
+{{ end -}}
+`))
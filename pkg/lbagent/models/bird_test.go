@@ -0,0 +1,89 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/mcclient/models"
+)
+
+func TestGenBirdConfigs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lbagent-bird")
+	if err != nil {
+		t.Fatalf("tempdir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	agentParams := &AgentParams{
+		AgentModel: &models.LoadbalancerAgent{IP: "192.168.0.10"},
+	}
+	cluster := &models.LoadbalancerCluster{
+		HaMode:      computeapi.LB_HA_MODE_BGP,
+		BgpLocalAsn: 65001,
+		BgpPeers:    "192.168.0.1:65000, 192.168.0.2:65000",
+	}
+	if err := agentParams.SetClusterParams(cluster); err != nil {
+		t.Fatalf("SetClusterParams: %v", err)
+	}
+	newLb := func(addr, status string) *Loadbalancer {
+		m := &models.Loadbalancer{Address: addr}
+		m.Status = status
+		return &Loadbalancer{Loadbalancer: m}
+	}
+	opts := &GenBirdConfigOptions{
+		LoadbalancersEnabled: []*Loadbalancer{
+			newLb("10.0.0.2", "enabled"),
+			newLb("10.0.0.1", "enabled"),
+			newLb("10.0.0.1", "enabled"),
+			newLb("10.0.0.3", "disabled"),
+		},
+		AgentParams: agentParams,
+	}
+	corpus := NewEmptyLoadbalancerCorpus()
+	r, err := corpus.GenBirdConfigs(dir, opts)
+	if err != nil {
+		t.Fatalf("GenBirdConfigs: %v", err)
+	}
+	if got := strings.Join(r.Addresses, ","); got != "10.0.0.1,10.0.0.2" {
+		t.Errorf("addresses: got %s", got)
+	}
+	d, err := ioutil.ReadFile(filepath.Join(dir, BirdConfFile))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	conf := string(d)
+	for _, want := range []string{
+		"router id 192.168.0.10;",
+		"route 10.0.0.1/32 blackhole;",
+		"route 10.0.0.2/32 blackhole;",
+		"protocol bgp peer1 {",
+		"neighbor 192.168.0.2 as 65000;",
+		"hold time 9;",
+		`export where proto = "lbvips";`,
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("missing %q in:\n%s", want, conf)
+		}
+	}
+	if strings.Contains(conf, "10.0.0.3") {
+		t.Errorf("disabled loadbalancer announced:\n%s", conf)
+	}
+}
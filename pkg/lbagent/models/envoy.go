@@ -0,0 +1,1019 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"yunion.io/x/log"
+
+	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/lbagent/envoy"
+	agentutils "yunion.io/x/onecloud/pkg/lbagent/utils"
+	"yunion.io/x/onecloud/pkg/mcclient/models"
+)
+
+const (
+	EnvoyLdsFile = "envoy-lds.json"
+	EnvoyCdsFile = "envoy-cds.json"
+)
+
+var envoyConfigErrNop = errors.New("nop envoy config")
+
+type GenEnvoyConfigsResult struct {
+	LoadbalancersEnabled []*Loadbalancer
+}
+
+// envoyConfigs collects resources of one config dir.  Clusters are keyed
+// by name as rules may refer to the same backend group more than once
+type envoyConfigs struct {
+	certsBase string
+	listeners []interface{}
+	clusters  map[string]*envoy.Cluster
+}
+
+func (cfgs *envoyConfigs) addCluster(cluster *envoy.Cluster) {
+	cfgs.clusters[cluster.Name] = cluster
+}
+
+// GenEnvoyConfigs renders the corpus into file based LDS and CDS resources
+// of envoy.  It's the envoy counterpart of GenHaproxyConfigs and
+// GenGobetweenConfigs: tcp, udp, http and https listeners are all served
+// by the one envoy daemon
+func (b *LoadbalancerCorpus) GenEnvoyConfigs(dir string, opts *AgentParams) (*GenEnvoyConfigsResult, error) {
+	cfgs := &envoyConfigs{
+		certsBase: filepath.Join(agentutils.DirStagingToFinal(dir), "envoy-certs"),
+		clusters:  map[string]*envoy.Cluster{},
+	}
+	if err := b.genEnvoyCerts(filepath.Join(dir, "envoy-certs")); err != nil {
+		return nil, err
+	}
+
+	r := &GenEnvoyConfigsResult{
+		LoadbalancersEnabled: []*Loadbalancer{},
+	}
+	lbIds := []string{}
+	for lbId := range b.Loadbalancers {
+		lbIds = append(lbIds, lbId)
+	}
+	sort.Strings(lbIds)
+	for _, lbId := range lbIds {
+		lb := b.Loadbalancers[lbId]
+		if lb.ClusterId != opts.AgentModel.ClusterId {
+			continue
+		}
+		if lb.Status != "enabled" {
+			continue
+		}
+		if lb.Address == "" {
+			continue
+		}
+		listenerIds := []string{}
+		for listenerId := range lb.listeners {
+			listenerIds = append(listenerIds, listenerId)
+		}
+		sort.Strings(listenerIds)
+		hasActiveListener := false
+		for _, listenerId := range listenerIds {
+			listener := lb.listeners[listenerId]
+			if listener.Status != "enabled" {
+				continue
+			}
+			var err error
+			switch listener.ListenerType {
+			case "http", "https":
+				err = b.genEnvoyConfigHttp(cfgs, listener)
+			case "tcp":
+				err = b.genEnvoyConfigTcp(cfgs, listener)
+			case "udp":
+				err = b.genEnvoyConfigUdp(cfgs, listener)
+			default:
+				log.Infof("envoy: ignore listener type %s", listener.ListenerType)
+				continue
+			}
+			if err == envoyConfigErrNop {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			hasActiveListener = true
+		}
+		if hasActiveListener {
+			r.LoadbalancersEnabled = append(r.LoadbalancersEnabled, lb)
+		}
+	}
+
+	clusterNames := []string{}
+	for name := range cfgs.clusters {
+		clusterNames = append(clusterNames, name)
+	}
+	sort.Strings(clusterNames)
+	clusters := []interface{}{}
+	for _, name := range clusterNames {
+		clusters = append(clusters, cfgs.clusters[name])
+	}
+	version := filepath.Base(agentutils.DirStagingToFinal(dir))
+	for fn, resources := range map[string][]interface{}{
+		EnvoyLdsFile: cfgs.listeners,
+		EnvoyCdsFile: clusters,
+	} {
+		resp := &envoy.DiscoveryResponse{
+			VersionInfo: version,
+			Resources:   resources,
+		}
+		if resp.Resources == nil {
+			resp.Resources = []interface{}{}
+		}
+		d, err := json.MarshalIndent(resp, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		p := filepath.Join(dir, fn)
+		err = ioutil.WriteFile(p, d, agentutils.FileModeFile)
+		if err != nil {
+			return nil, fmt.Errorf("write %s: %s", fn, err)
+		}
+	}
+	return r, nil
+}
+
+// genEnvoyCerts writes certificate chain and private key into separate
+// files as envoy wants.  CA bundles have no private key
+func (b *LoadbalancerCorpus) genEnvoyCerts(certsBase string) error {
+	if len(b.LoadbalancerCertificates) == 0 {
+		return nil
+	}
+	err := os.MkdirAll(certsBase, agentutils.FileModeDirSensitive)
+	if err != nil {
+		return fmt.Errorf("mkdir %s: %s", certsBase, err)
+	}
+	for _, lbcert := range b.LoadbalancerCertificates {
+		if lbcert.Certificate == "" {
+			// acme certificate not issued yet
+			continue
+		}
+		files := map[string]string{}
+		if lbcert.CertificateType == computeapi.LB_CERT_TYPE_CA {
+			files[haproxyCertFile(lbcert)] = lbcert.Certificate
+		} else {
+			files[lbcert.Id+".crt"] = lbcert.Certificate
+			files[lbcert.Id+".key"] = lbcert.PrivateKey
+		}
+		for fn, d := range files {
+			p := filepath.Join(certsBase, fn)
+			err := ioutil.WriteFile(p, []byte(d), agentutils.FileModeFileSensitive)
+			if err != nil {
+				return fmt.Errorf("write cert %s: %s", lbcert.Id, err)
+			}
+		}
+	}
+	return nil
+}
+
+func envoyDuration(seconds int) string {
+	if seconds <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%ds", seconds)
+}
+
+func envoySocketAddress(protocol, addr string, port int) envoy.Address {
+	return envoy.Address{
+		SocketAddress: &envoy.SocketAddress{
+			Protocol:  protocol,
+			Address:   addr,
+			PortValue: port,
+		},
+	}
+}
+
+func envoyCidrRange(s string) (*envoy.CidrRange, error) {
+	if strings.Contains(s, "/") {
+		ip, ipnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		ones, _ := ipnet.Mask.Size()
+		return &envoy.CidrRange{AddressPrefix: ip.String(), PrefixLen: ones}, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip address %q", s)
+	}
+	if ip.To4() != nil {
+		return &envoy.CidrRange{AddressPrefix: ip.String(), PrefixLen: 32}, nil
+	}
+	return &envoy.CidrRange{AddressPrefix: ip.String(), PrefixLen: 128}, nil
+}
+
+// envoyAclRules returns rbac rules of listener acl.  Empty white list
+// allows nobody
+func (b *LoadbalancerCorpus) envoyAclRules(listener *LoadbalancerListener) *envoy.RBACRules {
+	if listener.AclStatus != "on" {
+		return nil
+	}
+	lbacl, ok := b.LoadbalancerAcls[listener.AclId]
+	if !ok || lbacl.AclEntries == nil || len(*lbacl.AclEntries) == 0 {
+		return nil
+	}
+	var action string
+	switch listener.AclType {
+	case "black":
+		action = "DENY"
+	case "white":
+		action = "ALLOW"
+	default:
+		return nil
+	}
+	principals := []envoy.RBACPrincipal{}
+	for _, aclEntry := range *lbacl.AclEntries {
+		cidr, err := envoyCidrRange(aclEntry.Cidr)
+		if err != nil {
+			log.Warningf("acl %s(%s): ignore entry: %v", lbacl.Name, lbacl.Id, err)
+			continue
+		}
+		principals = append(principals, envoy.RBACPrincipal{DirectRemoteIp: cidr})
+	}
+	rules := &envoy.RBACRules{
+		Action:   action,
+		Policies: map[string]envoy.RBACPolicy{},
+	}
+	if len(principals) > 0 {
+		rules.Policies["acl-"+lbacl.Id] = envoy.RBACPolicy{
+			Permissions: []envoy.RBACPermission{{Any: true}},
+			Principals:  principals,
+		}
+	} else if action == "DENY" {
+		return nil
+	}
+	return rules
+}
+
+func envoyIsHashLbPolicy(policy string) bool {
+	return policy == "RING_HASH" || policy == "MAGLEV"
+}
+
+func envoyProxyProtocolVersion(sendProxy string) (string, error) {
+	switch sendProxy {
+	case computeapi.LB_SENDPROXY_OFF, "":
+		return "", nil
+	case computeapi.LB_SENDPROXY_V1:
+		return "V1", nil
+	case computeapi.LB_SENDPROXY_V2:
+		return "V2", nil
+	case computeapi.LB_SENDPROXY_V2_SSL, computeapi.LB_SENDPROXY_V2_SSL_CN:
+		// ssl tlvs are not supported by envoy
+		return "V2", nil
+	default:
+		return "", fmt.Errorf("unknown SendProxy: %s", sendProxy)
+	}
+}
+
+func (b *LoadbalancerCorpus) envoyCluster(cfgs *envoyConfigs, name string, listener *LoadbalancerListener, backendGroup *LoadbalancerBackendGroup) (*envoy.Cluster, error) {
+	lbPolicy, err := agentutils.EnvoyLbPolicy(listener.Scheduler)
+	if err != nil {
+		return nil, err
+	}
+	isHttp := listener.ListenerType == "http" || listener.ListenerType == "https"
+
+	backendIds := []string{}
+	for backendId := range backendGroup.backends {
+		backendIds = append(backendIds, backendId)
+	}
+	sort.Strings(backendIds)
+	var (
+		lbEndpoints = []envoy.LbEndpoint{}
+		nSsl        int
+	)
+	for _, backendId := range backendIds {
+		backend := backendGroup.backends[backendId]
+		weight := 1
+		if listener.Scheduler != computeapi.LB_SCHEDULER_RR {
+			weight = backend.Weight
+		}
+		if weight <= 0 {
+			// drained
+			continue
+		}
+		if backend.Ssl == "on" {
+			nSsl++
+		}
+		if backend.SendProxy != "" && backend.SendProxy != computeapi.LB_SENDPROXY_OFF && backend.SendProxy != listener.SendProxy {
+			log.Warningf("backend %s(%s): send_proxy of backend is not supported by envoy, use that of listener", backend.Name, backend.Id)
+		}
+		lbEndpoints = append(lbEndpoints, envoy.LbEndpoint{
+			Endpoint: envoy.Endpoint{
+				Address: envoySocketAddress("", backend.Address, backend.Port),
+			},
+			LoadBalancingWeight: weight,
+		})
+	}
+	cluster := &envoy.Cluster{
+		Type:           envoy.TypeCluster,
+		Name:           name,
+		DiscoveryType:  "STATIC",
+		ConnectTimeout: envoyDuration(listener.BackendConnectTimeout),
+		LbPolicy:       lbPolicy,
+		LoadAssignment: envoy.ClusterLoadAssignment{
+			ClusterName: name,
+			Endpoints: []envoy.LocalityLbEndpoints{
+				{LbEndpoints: lbEndpoints},
+			},
+		},
+	}
+	if cluster.ConnectTimeout == "" {
+		cluster.ConnectTimeout = "5s"
+	}
+	if listener.HealthCheck == "on" {
+		hc := envoy.HealthCheck{
+			Timeout:            envoyDuration(listener.HealthCheckTimeout),
+			Interval:           envoyDuration(listener.HealthCheckInterval),
+			HealthyThreshold:   listener.HealthCheckRise,
+			UnhealthyThreshold: listener.HealthCheckFall,
+		}
+		switch listener.HealthCheckType {
+		case "tcp":
+			hc.TcpHealthCheck = &envoy.TcpHealthCheck{}
+		case "http":
+			uri := listener.HealthCheckURI
+			if uri == "" {
+				uri = "/"
+			}
+			hc.HttpHealthCheck = &envoy.HttpHealthCheck{
+				Host: listener.HealthCheckDomain,
+				Path: uri,
+			}
+			for _, se := range agentutils.EnvoyExpectedStatuses(listener.HealthCheckHttpCode) {
+				hc.HttpHealthCheck.ExpectedStatuses = append(hc.HttpHealthCheck.ExpectedStatuses,
+					envoy.Int64Range{Start: se[0], End: se[1]})
+			}
+		default:
+			log.Warningf("listener %s(%s): health check type %s is not supported by envoy",
+				listener.Name, listener.Id, listener.HealthCheckType)
+		}
+		if hc.TcpHealthCheck != nil || hc.HttpHealthCheck != nil {
+			if hc.Timeout == "" {
+				hc.Timeout = "5s"
+			}
+			if hc.Interval == "" {
+				hc.Interval = "5s"
+			}
+			cluster.HealthChecks = []envoy.HealthCheck{hc}
+		}
+	}
+
+	http2 := isHttp && listener.BackendHttp2
+	var transportSocket *envoy.TransportSocket
+	{
+		useSsl := listener.ListenerType == "https" && listener.BackendSsl
+		if !useSsl && nSsl > 0 && listener.ListenerType != "udp" {
+			if nSsl < len(lbEndpoints) {
+				log.Warningf("listener %s(%s): backends of group %s(%s) mixed with and without ssl, use ssl for all",
+					listener.Name, listener.Id, backendGroup.Name, backendGroup.Id)
+			}
+			useSsl = true
+		}
+		if useSsl {
+			tlsCtx := envoy.UpstreamTlsContext{
+				Type: envoy.TypeUpstreamTlsContext,
+			}
+			if cacert := listener.backendCaCertificate; listener.BackendSsl && cacert != nil && cacert.Certificate != "" {
+				tlsCtx.CommonTlsContext.ValidationContext = &envoy.CertificateValidationCtx{
+					TrustedCa: envoy.DataSource{Filename: filepath.Join(cfgs.certsBase, haproxyCertFile(cacert))},
+				}
+			}
+			if http2 {
+				tlsCtx.CommonTlsContext.AlpnProtocols = []string{"h2"}
+			}
+			transportSocket = &envoy.TransportSocket{
+				Name:        "envoy.transport_sockets.tls",
+				TypedConfig: tlsCtx,
+			}
+		}
+	}
+	if ver, err := envoyProxyProtocolVersion(listener.SendProxy); err != nil {
+		return nil, fmt.Errorf("listener %s(%s): %v", listener.Name, listener.Id, err)
+	} else if ver != "" {
+		inner := transportSocket
+		if inner == nil {
+			inner = &envoy.TransportSocket{
+				Name:        "envoy.transport_sockets.raw_buffer",
+				TypedConfig: envoy.TypedOnly{Type: envoy.TypeRawBuffer},
+			}
+		}
+		transportSocket = &envoy.TransportSocket{
+			Name: "envoy.transport_sockets.upstream_proxy_protocol",
+			TypedConfig: envoy.ProxyProtocolUpstreamTransport{
+				Type:            envoy.TypeProxyProtocolUpstream,
+				Config:          envoy.ProxyProtocol{Version: ver},
+				TransportSocket: *inner,
+			},
+		}
+	}
+	cluster.TransportSocket = transportSocket
+
+	if isHttp {
+		opts := envoy.HttpProtocolOptions{
+			Type: envoy.TypeHttpProtocolOptions,
+		}
+		if idle := envoyDuration(listener.BackendIdleTimeout); idle != "" {
+			opts.CommonHttpProtocolOptions = &envoy.CommonHttpProtocolOptions{IdleTimeout: idle}
+		}
+		if http2 {
+			opts.ExplicitHttpConfig.Http2ProtocolOptions = &struct{}{}
+		} else {
+			opts.ExplicitHttpConfig.HttpProtocolOptions = &struct{}{}
+		}
+		cluster.TypedExtensionProtocolOptions = map[string]interface{}{
+			envoy.HttpProtocolOptionsName: opts,
+		}
+	}
+	return cluster, nil
+}
+
+func (b *LoadbalancerCorpus) envoyBackendGroup(listener *LoadbalancerListener, backendGroupId string) (*LoadbalancerBackendGroup, error) {
+	backendGroup, ok := listener.loadbalancer.backendGroups[backendGroupId]
+	if !ok {
+		return nil, fmt.Errorf("listener %s(%s): backend group %s not found", listener.Name, listener.Id, backendGroupId)
+	}
+	return backendGroup, nil
+}
+
+func (b *LoadbalancerCorpus) genEnvoyConfigTcp(cfgs *envoyConfigs, listener *LoadbalancerListener) error {
+	lb := listener.loadbalancer
+	if listener.BackendGroupId == "" {
+		return envoyConfigErrNop
+	}
+	backendGroup, err := b.envoyBackendGroup(listener, listener.BackendGroupId)
+	if err != nil {
+		return err
+	}
+	cluster, err := b.envoyCluster(cfgs, fmt.Sprintf("backends_listener-%s", listener.Id), listener, backendGroup)
+	if err != nil {
+		return err
+	}
+	cfgs.addCluster(cluster)
+
+	filters := []envoy.Filter{}
+	if rules := b.envoyAclRules(listener); rules != nil {
+		filters = append(filters, envoy.Filter{
+			Name: "envoy.filters.network.rbac",
+			TypedConfig: envoy.RBAC{
+				Type:       envoy.TypeNetworkRBAC,
+				StatPrefix: listener.Id,
+				Rules:      *rules,
+			},
+		})
+	}
+	tcpProxy := envoy.TcpProxy{
+		Type:        envoy.TypeTcpProxy,
+		StatPrefix:  listener.Id,
+		Cluster:     cluster.Name,
+		IdleTimeout: envoyDuration(listener.ClientIdleTimeout),
+	}
+	if envoyIsHashLbPolicy(cluster.LbPolicy) {
+		tcpProxy.HashPolicy = []envoy.TcpHashPolicy{{SourceIp: &struct{}{}}}
+	}
+	filters = append(filters, envoy.Filter{
+		Name:        "envoy.filters.network.tcp_proxy",
+		TypedConfig: tcpProxy,
+	})
+	cfgs.listeners = append(cfgs.listeners, &envoy.Listener{
+		Type:         envoy.TypeListener,
+		Name:         listener.Id,
+		Address:      envoySocketAddress("TCP", lb.Address, listener.ListenerPort),
+		FilterChains: []envoy.FilterChain{{Filters: filters}},
+		Freebind:     true,
+	})
+	return nil
+}
+
+func (b *LoadbalancerCorpus) genEnvoyConfigUdp(cfgs *envoyConfigs, listener *LoadbalancerListener) error {
+	lb := listener.loadbalancer
+	if listener.BackendGroupId == "" {
+		return envoyConfigErrNop
+	}
+	backendGroup, err := b.envoyBackendGroup(listener, listener.BackendGroupId)
+	if err != nil {
+		return err
+	}
+	if len(backendGroup.backends) == 0 {
+		return envoyConfigErrNop
+	}
+	if b.envoyAclRules(listener) != nil {
+		log.Warningf("listener %s(%s): acl of udp listener is not supported by envoy", listener.Name, listener.Id)
+	}
+	cluster, err := b.envoyCluster(cfgs, fmt.Sprintf("backends_listener-%s", listener.Id), listener, backendGroup)
+	if err != nil {
+		return err
+	}
+	cfgs.addCluster(cluster)
+
+	udpProxy := envoy.UdpProxy{
+		Type:        envoy.TypeUdpProxy,
+		StatPrefix:  listener.Id,
+		Cluster:     cluster.Name,
+		IdleTimeout: envoyDuration(listener.ClientIdleTimeout),
+	}
+	if envoyIsHashLbPolicy(cluster.LbPolicy) {
+		udpProxy.HashPolicies = []envoy.UdpHashPolicy{{SourceIp: true}}
+	}
+	cfgs.listeners = append(cfgs.listeners, &envoy.Listener{
+		Type:    envoy.TypeListener,
+		Name:    listener.Id,
+		Address: envoySocketAddress("UDP", lb.Address, listener.ListenerPort),
+		ListenerFilters: []envoy.Filter{
+			{
+				Name:        "envoy.filters.udp_listener.udp_proxy",
+				TypedConfig: udpProxy,
+			},
+		},
+		UdpListenerConfig: &envoy.UdpListenerConfig{},
+		Freebind:          true,
+	})
+	return nil
+}
+
+// envoyGlobRegexp converts globs into one unanchored regular expression
+// matching any of them
+func envoyGlobRegexp(globs []string) (string, error) {
+	res := []string{}
+	for _, glob := range globs {
+		if !haproxyRuleSafeValueRegexp.MatchString(glob) {
+			return "", fmt.Errorf("invalid value %q", glob)
+		}
+		re := haproxyGlobRegexp(glob)
+		res = append(res, re[1:len(re)-1])
+	}
+	if len(res) == 0 {
+		return "", fmt.Errorf("empty values")
+	}
+	return "(?:" + strings.Join(res, "|") + ")", nil
+}
+
+func envoyRegexHeaderMatcher(name, re string) envoy.HeaderMatcher {
+	return envoy.HeaderMatcher{
+		Name: name,
+		StringMatch: envoy.StringMatcher{
+			SafeRegex: &envoy.RegexMatcher{Regex: re},
+		},
+	}
+}
+
+// envoyRuleRouteMatches returns route matches of the rule.  Query string
+// values are or'ed as in haproxy, so one match is made for each of them
+func envoyRuleRouteMatches(rule *LoadbalancerListenerRule) ([]envoy.RouteMatch, error) {
+	base := envoy.RouteMatch{Prefix: "/"}
+	if rule.Path != "" {
+		base.Prefix = rule.Path
+	}
+	if rule.Domain != "" {
+		re := fmt.Sprintf(`(?i)^(?:.*\.)?%s(?::[0-9]+)?$`, regexp.QuoteMeta(rule.Domain))
+		base.Headers = append(base.Headers, envoyRegexHeaderMatcher(":authority", re))
+	}
+	var queries []envoy.QueryParameterMatcher
+	if rule.Condition != "" {
+		conds, err := computeapi.ParseLoadbalancerListenerRuleConditions(rule.Condition)
+		if err != nil {
+			return nil, err
+		}
+		for _, cond := range conds {
+			switch cond.Field {
+			case computeapi.LB_RULE_CONDITION_HTTP_HEADER:
+				conf := cond.HttpHeaderConfig
+				if conf == nil || !haproxyRuleSafeTokenRegexp.MatchString(conf.HttpHeaderName) {
+					return nil, fmt.Errorf("%s: invalid header name", cond.Field)
+				}
+				re, err := envoyGlobRegexp(conf.Values)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", cond.Field, err)
+				}
+				base.Headers = append(base.Headers, envoyRegexHeaderMatcher(strings.ToLower(conf.HttpHeaderName), "(?i)^"+re+"$"))
+			case computeapi.LB_RULE_CONDITION_PATH_PATTERN:
+				if cond.PathPatternConfig == nil {
+					return nil, fmt.Errorf("%s: missing config", cond.Field)
+				}
+				re, err := envoyGlobRegexp(cond.PathPatternConfig.Values)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", cond.Field, err)
+				}
+				// :path has query string in it
+				base.Headers = append(base.Headers, envoyRegexHeaderMatcher(":path", "^"+re+`(?:\?.*)?$`))
+			case computeapi.LB_RULE_CONDITION_HTTP_REQUEST_METHOD:
+				if cond.HttpRequestMethodConfig == nil || len(cond.HttpRequestMethodConfig.Values) == 0 {
+					return nil, fmt.Errorf("%s: empty values", cond.Field)
+				}
+				for _, method := range cond.HttpRequestMethodConfig.Values {
+					if !haproxyRuleSafeTokenRegexp.MatchString(method) {
+						return nil, fmt.Errorf("%s: invalid method %q", cond.Field, method)
+					}
+				}
+				re := "^(?:" + strings.Join(cond.HttpRequestMethodConfig.Values, "|") + ")$"
+				base.Headers = append(base.Headers, envoyRegexHeaderMatcher(":method", re))
+			case computeapi.LB_RULE_CONDITION_HOST_HEADER:
+				if cond.HostHeaderConfig == nil {
+					return nil, fmt.Errorf("%s: missing config", cond.Field)
+				}
+				re, err := envoyGlobRegexp(cond.HostHeaderConfig.Values)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", cond.Field, err)
+				}
+				base.Headers = append(base.Headers, envoyRegexHeaderMatcher(":authority", "(?i)^"+re+"(?::[0-9]+)?$"))
+			case computeapi.LB_RULE_CONDITION_QUERY_STRING:
+				if cond.QueryStringConfig == nil || len(cond.QueryStringConfig.Values) == 0 {
+					return nil, fmt.Errorf("%s: empty values", cond.Field)
+				}
+				if queries != nil {
+					return nil, fmt.Errorf("%s: specified more than once", cond.Field)
+				}
+				for _, kv := range cond.QueryStringConfig.Values {
+					if !haproxyRuleSafeTokenRegexp.MatchString(kv.Key) {
+						return nil, fmt.Errorf("%s: invalid key %q", cond.Field, kv.Key)
+					}
+					re, err := envoyGlobRegexp([]string{kv.Value})
+					if err != nil {
+						return nil, fmt.Errorf("%s: %v", cond.Field, err)
+					}
+					queries = append(queries, envoy.QueryParameterMatcher{
+						Name: kv.Key,
+						StringMatch: envoy.StringMatcher{
+							SafeRegex: &envoy.RegexMatcher{Regex: "(?i)^" + re + "$"},
+						},
+					})
+				}
+			case computeapi.LB_RULE_CONDITION_SOURCE_IP:
+				// route match of envoy knows nothing about the peer
+				return nil, fmt.Errorf("%s: not supported by envoy", cond.Field)
+			default:
+				return nil, fmt.Errorf("unsupported condition %q", cond.Field)
+			}
+		}
+	}
+	if len(queries) == 0 {
+		return []envoy.RouteMatch{base}, nil
+	}
+	matches := []envoy.RouteMatch{}
+	for _, query := range queries {
+		m := base
+		m.QueryParameters = []envoy.QueryParameterMatcher{query}
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
+
+func envoyRedirectAction(r *models.LoadbalancerHTTPRedirect) *envoy.RedirectAction {
+	action := &envoy.RedirectAction{
+		SchemeRedirect: r.RedirectScheme,
+		HostRedirect:   r.RedirectHost,
+		PathRedirect:   r.RedirectPath,
+	}
+	switch r.RedirectCode {
+	case 301:
+		action.ResponseCode = "MOVED_PERMANENTLY"
+	case 307:
+		action.ResponseCode = "TEMPORARY_REDIRECT"
+	default:
+		action.ResponseCode = "FOUND"
+	}
+	return action
+}
+
+func envoyDirectResponseRoute(match envoy.RouteMatch, code int, contentType, body string) envoy.Route {
+	return envoy.Route{
+		Match: match,
+		DirectResponse: &envoy.DirectResponseAction{
+			Status: code,
+			Body:   &envoy.DataSource{InlineString: body},
+		},
+		ResponseHeadersToAdd: []envoy.HeaderValueOption{
+			{
+				Header:       envoy.HeaderValue{Key: "content-type", Value: contentType},
+				AppendAction: "OVERWRITE_IF_EXISTS_OR_ADD",
+			},
+			{
+				Header:       envoy.HeaderValue{Key: "cache-control", Value: "no-cache"},
+				AppendAction: "OVERWRITE_IF_EXISTS_OR_ADD",
+			},
+		},
+	}
+}
+
+// envoyClientCertHeaders passes client certificate info to backends with
+// the same headers as haproxy does, except those envoy has no value for
+func envoyClientCertHeaders() ([]envoy.HeaderValueOption, []string) {
+	var (
+		add    = []envoy.HeaderValueOption{}
+		remove = []string{}
+	)
+	for _, kv := range [][2]string{
+		{"X-SSL-Client-DN", "%DOWNSTREAM_PEER_SUBJECT%"},
+		{"X-SSL-Client-Issuer-DN", "%DOWNSTREAM_PEER_ISSUER%"},
+		{"X-SSL-Client-Serial", "%DOWNSTREAM_PEER_SERIAL%"},
+		{"X-SSL-Client-NotAfter", "%DOWNSTREAM_PEER_CERT_V_END%"},
+	} {
+		add = append(add, envoy.HeaderValueOption{
+			Header:       envoy.HeaderValue{Key: kv[0], Value: kv[1]},
+			AppendAction: "OVERWRITE_IF_EXISTS_OR_ADD",
+		})
+	}
+	for _, h := range []string{
+		"X-SSL-Client-Used",
+		"X-SSL-Client-Verify",
+		"X-SSL-Client-DN",
+		"X-SSL-Client-CN",
+		"X-SSL-Client-Issuer-DN",
+		"X-SSL-Client-Serial",
+		"X-SSL-Client-NotAfter",
+	} {
+		remove = append(remove, h)
+	}
+	return add, remove
+}
+
+func (b *LoadbalancerCorpus) envoyDownstreamTlsContext(cfgs *envoyConfigs, listener *LoadbalancerListener) envoy.DownstreamTlsContext {
+	lbcert := listener.certificate
+	tlsCtx := envoy.DownstreamTlsContext{
+		Type: envoy.TypeDownstreamTlsContext,
+		CommonTlsContext: envoy.CommonTlsContext{
+			TlsCertificates: []envoy.TlsCertificate{
+				{
+					CertificateChain: envoy.DataSource{Filename: filepath.Join(cfgs.certsBase, lbcert.Id+".crt")},
+					PrivateKey:       envoy.DataSource{Filename: filepath.Join(cfgs.certsBase, lbcert.Id+".key")},
+				},
+			},
+			AlpnProtocols: []string{"http/1.1"},
+		},
+	}
+	var sslMinVer, ciphers string
+	if policy := agentutils.HaproxySslPolicy(listener.TLSCipherPolicy); policy != nil {
+		sslMinVer = policy.SslMinVer
+		ciphers = policy.Ciphers
+	}
+	if listener.TLSMinVersion != "" {
+		sslMinVer = listener.TLSMinVersion
+	}
+	if listener.TLSCiphers != "" {
+		ciphers = listener.TLSCiphers
+	}
+	if sslMinVer != "" || ciphers != "" {
+		tlsCtx.CommonTlsContext.TlsParams = &envoy.TlsParameters{
+			TlsMinimumProtocolVersion: agentutils.EnvoyTlsVersion(sslMinVer),
+		}
+		if ciphers != "" {
+			tlsCtx.CommonTlsContext.TlsParams.CipherSuites = strings.Split(ciphers, ":")
+		}
+	}
+	if listener.EnableHttp2 {
+		tlsCtx.CommonTlsContext.AlpnProtocols = []string{"h2", "http/1.1"}
+	}
+	if haproxyListenerVerifyClient(listener) {
+		// certificates presented are always verified, optional only
+		// means clients may present none
+		tlsCtx.CommonTlsContext.ValidationContext = &envoy.CertificateValidationCtx{
+			TrustedCa: envoy.DataSource{Filename: filepath.Join(cfgs.certsBase, haproxyCertFile(listener.clientCaCertificate))},
+		}
+		tlsCtx.RequireClientCertificate = listener.ClientVerify == computeapi.LB_CLIENT_VERIFY_REQUIRED
+	}
+	return tlsCtx
+}
+
+func (b *LoadbalancerCorpus) genEnvoyConfigHttp(cfgs *envoyConfigs, listener *LoadbalancerListener) error {
+	lb := listener.loadbalancer
+	isHttps := listener.ListenerType == "https"
+	if isHttps && (listener.certificate == nil || listener.certificate.Certificate == "") {
+		return envoyConfigErrNop
+	}
+	if isHttps && listener.ClientVerify != "" && listener.ClientVerify != computeapi.LB_CLIENT_VERIFY_OFF {
+		// refuse to serve unverified clients when ca is not available
+		if !haproxyListenerVerifyClient(listener) {
+			return envoyConfigErrNop
+		}
+	}
+	if listener.HTTPRequestRate > 0 || listener.HTTPRequestRatePerSrc > 0 {
+		log.Warningf("listener %s(%s): request rate limit is not supported by envoy", listener.Name, listener.Id)
+	}
+	if listener.StickySession == "on" {
+		log.Warningf("listener %s(%s): sticky session is not supported by envoy", listener.Name, listener.Id)
+	}
+	if listener.AccessLog {
+		log.Warningf("listener %s(%s): access log is not supported by envoy", listener.Name, listener.Id)
+	}
+
+	var (
+		rules  = listener.rules.OrderedEnabledList()
+		routes = []envoy.Route{}
+	)
+	newRouteAction := func(clusters []envoy.ClusterWeight, lbPolicy string) *envoy.RouteAction {
+		action := &envoy.RouteAction{
+			Timeout:     "0s",
+			IdleTimeout: envoyDuration(listener.BackendIdleTimeout),
+		}
+		if len(clusters) == 1 {
+			action.Cluster = clusters[0].Name
+		} else {
+			action.WeightedClusters = &envoy.WeightedClusters{Clusters: clusters}
+		}
+		if envoyIsHashLbPolicy(lbPolicy) {
+			action.HashPolicy = []envoy.HashPolicy{
+				{ConnectionProperties: &envoy.HashPolicyConnectionProperties{SourceIp: true}},
+			}
+		}
+		return action
+	}
+	{ // acme challenges go first
+		for _, chal := range b.acmeHttpChallenges() {
+			if chal.projectId != listener.ProjectId {
+				continue
+			}
+			match := envoy.RouteMatch{Path: acmeHttpChallengePath + chal.token}
+			routes = append(routes, envoyDirectResponseRoute(match, 200, "text/plain", chal.keyAuth))
+		}
+	}
+	{ // dispatch
+		for _, rule := range rules {
+			matches, err := envoyRuleRouteMatches(rule)
+			if err != nil {
+				log.Warningf("rule %s(%s): ignored for invalid condition: %v", rule.Name, rule.Id, err)
+				continue
+			}
+			var route envoy.Route
+			if rule.Redirect == computeapi.LB_REDIRECT_OFF {
+				if haproxyRuleIsFixedResponse(rule) {
+					contentType := rule.FixedResponseContentType
+					if !haproxyRuleContentTypeRegexp.MatchString(contentType) {
+						contentType = "text/plain"
+					}
+					route = envoyDirectResponseRoute(envoy.RouteMatch{}, rule.FixedResponseCode, contentType, rule.FixedResponseBody)
+				} else {
+					lbrbgs := haproxyRuleBackendGroups(rule)
+					if len(lbrbgs) == 0 {
+						continue
+					}
+					clusters := []envoy.ClusterWeight{}
+					var lbPolicy string
+					for _, lbrbg := range lbrbgs {
+						backendGroup, err := b.envoyBackendGroup(listener, lbrbg.backendGroupId)
+						if err != nil {
+							return fmt.Errorf("rule %s(%s): %v", rule.Name, rule.Id, err)
+						}
+						cluster, err := b.envoyCluster(cfgs, lbrbg.backendId, listener, backendGroup)
+						if err != nil {
+							return err
+						}
+						cfgs.addCluster(cluster)
+						clusters = append(clusters, envoy.ClusterWeight{Name: cluster.Name, Weight: lbrbg.weight})
+						lbPolicy = cluster.LbPolicy
+					}
+					action := newRouteAction(clusters, lbPolicy)
+					if host := rule.RewriteHost; host != "" {
+						if !haproxyRuleSafeTokenRegexp.MatchString(strings.Replace(host, ":", "", 1)) {
+							log.Warningf("rule %s(%s): ignore invalid rewrite host %q", rule.Name, rule.Id, host)
+						} else {
+							action.HostRewriteLiteral = host
+						}
+					}
+					if path := rule.RewritePath; path != "" {
+						if !haproxyRuleSafePathRegexp.MatchString(path) {
+							log.Warningf("rule %s(%s): ignore invalid rewrite path %q", rule.Name, rule.Id, path)
+						} else {
+							// replaces the prefix matched
+							action.PrefixRewrite = path
+						}
+					}
+					route = envoy.Route{Route: action}
+				}
+			} else if rule.Redirect == computeapi.LB_REDIRECT_RAW {
+				route = envoy.Route{Redirect: envoyRedirectAction(&rule.LoadbalancerHTTPRedirect)}
+			} else {
+				return envoyConfigErrNop
+			}
+			for i, match := range matches {
+				route.Name = fmt.Sprintf("rule-%s-%d", rule.Id, i)
+				route.Match = match
+				routes = append(routes, route)
+			}
+		}
+		// default
+		if listener.Redirect == computeapi.LB_REDIRECT_RAW {
+			routes = append(routes, envoy.Route{
+				Name:     "default",
+				Match:    envoy.RouteMatch{Prefix: "/"},
+				Redirect: envoyRedirectAction(&listener.LoadbalancerHTTPRedirect),
+			})
+		} else if listener.Redirect == computeapi.LB_REDIRECT_OFF && listener.BackendGroupId != "" {
+			backendGroup, err := b.envoyBackendGroup(listener, listener.BackendGroupId)
+			if err != nil {
+				return err
+			}
+			cluster, err := b.envoyCluster(cfgs, fmt.Sprintf("backends_listener_default-%s", listener.Id), listener, backendGroup)
+			if err != nil {
+				return err
+			}
+			cfgs.addCluster(cluster)
+			routes = append(routes, envoy.Route{
+				Name:  "default",
+				Match: envoy.RouteMatch{Prefix: "/"},
+				Route: newRouteAction([]envoy.ClusterWeight{{Name: cluster.Name, Weight: 1}}, cluster.LbPolicy),
+			})
+		}
+	}
+	if len(routes) == 0 {
+		// nothing to serve
+		return envoyConfigErrNop
+	}
+
+	virtualHost := envoy.VirtualHost{
+		Name:    listener.Id,
+		Domains: []string{"*"},
+		Routes:  routes,
+	}
+	if isHttps && haproxyListenerVerifyClient(listener) && listener.ClientCertHeaders {
+		virtualHost.RequestHeadersToAdd, virtualHost.RequestHeadersToRemove = envoyClientCertHeaders()
+	}
+	httpFilters := []envoy.Filter{}
+	if rules := b.envoyAclRules(listener); rules != nil {
+		httpFilters = append(httpFilters, envoy.Filter{
+			Name: "envoy.filters.http.rbac",
+			TypedConfig: envoy.RBAC{
+				Type:  envoy.TypeHttpRBAC,
+				Rules: *rules,
+			},
+		})
+	}
+	if listener.Gzip {
+		httpFilters = append(httpFilters, envoy.Filter{
+			Name: "envoy.filters.http.compressor",
+			TypedConfig: envoy.Compressor{
+				Type: envoy.TypeCompressor,
+				CompressorLibrary: envoy.TypedExtensionConfig{
+					Name:        "gzip",
+					TypedConfig: envoy.TypedOnly{Type: envoy.TypeGzip},
+				},
+			},
+		})
+	}
+	httpFilters = append(httpFilters, envoy.Filter{
+		Name:        "envoy.filters.http.router",
+		TypedConfig: envoy.TypedOnly{Type: envoy.TypeRouter},
+	})
+	hcm := envoy.HttpConnectionManager{
+		Type:       envoy.TypeHttpConnectionManager,
+		StatPrefix: listener.Id,
+		CodecType:  "AUTO",
+		RouteConfig: envoy.RouteConfiguration{
+			Name:         listener.Id,
+			VirtualHosts: []envoy.VirtualHost{virtualHost},
+		},
+		HttpFilters:           httpFilters,
+		UseRemoteAddress:      true,
+		SkipXffAppend:         !listener.XForwardedFor,
+		RequestHeadersTimeout: envoyDuration(listener.ClientRequestTimeout),
+	}
+	if idle := envoyDuration(listener.ClientIdleTimeout); idle != "" {
+		hcm.CommonHttpProtocolOptions = &envoy.CommonHttpProtocolOptions{IdleTimeout: idle}
+	}
+	filterChain := envoy.FilterChain{
+		Filters: []envoy.Filter{
+			{
+				Name:        "envoy.filters.network.http_connection_manager",
+				TypedConfig: hcm,
+			},
+		},
+	}
+	if isHttps {
+		filterChain.TransportSocket = &envoy.TransportSocket{
+			Name:        "envoy.transport_sockets.tls",
+			TypedConfig: b.envoyDownstreamTlsContext(cfgs, listener),
+		}
+	}
+	cfgs.listeners = append(cfgs.listeners, &envoy.Listener{
+		Type:         envoy.TypeListener,
+		Name:         listener.Id,
+		Address:      envoySocketAddress("TCP", lb.Address, listener.ListenerPort),
+		FilterChains: []envoy.FilterChain{filterChain},
+		Freebind:     true,
+	})
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"testing"
+
+	"yunion.io/x/onecloud/pkg/lbagent/envoy"
+	"yunion.io/x/onecloud/pkg/mcclient/models"
+)
+
+func TestEnvoyRuleRouteMatches(t *testing.T) {
+	m := &models.LoadbalancerListenerRule{
+		Domain:    "example.com",
+		Path:      "/api",
+		Condition: `[{"field":"http-request-method","httpRequestMethodConfig":{"values":["GET","POST"]}},{"field":"query-string","queryStringConfig":{"values":[{"key":"v","value":"1"},{"key":"v","value":"2*"}]}}]`,
+	}
+	rule := &LoadbalancerListenerRule{LoadbalancerListenerRule: m}
+	matches, err := envoyRuleRouteMatches(rule)
+	if err != nil {
+		t.Fatalf("envoyRuleRouteMatches: %v", err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("want one match for each query value, got %d", len(matches))
+	}
+	for i, want := range []string{`(?i)^(?:1)$`, `(?i)^(?:2.*)$`} {
+		match := matches[i]
+		if match.Prefix != "/api" || len(match.Headers) != 2 {
+			t.Errorf("match %d: bad prefix or headers: %#v", i, match)
+		}
+		if got := match.Headers[1].StringMatch.SafeRegex.Regex; got != "^(?:GET|POST)$" {
+			t.Errorf("match %d: method regex %q", i, got)
+		}
+		if got := match.QueryParameters[0].StringMatch.SafeRegex.Regex; got != want {
+			t.Errorf("match %d: query regex got %q, want %q", i, got, want)
+		}
+	}
+
+	m.Condition = `[{"field":"source-ip","sourceIpConfig":{"values":["10.0.0.0/8"]}}]`
+	if _, err := envoyRuleRouteMatches(rule); err == nil {
+		t.Errorf("source-ip condition should be rejected")
+	}
+}
+
+func TestEnvoyConfigUdp(t *testing.T) {
+	backend := &models.LoadbalancerBackend{Address: "192.168.0.2", Port: 53, Weight: 1}
+	backend.Id = "backend1"
+	backendGroup := &LoadbalancerBackendGroup{
+		LoadbalancerBackendGroup: &models.LoadbalancerBackendGroup{},
+		backends:                 LoadbalancerBackends{backend.Id: &LoadbalancerBackend{LoadbalancerBackend: backend}},
+	}
+	backendGroup.Id = "backendgroup1"
+	m := &models.LoadbalancerListener{
+		ListenerType:   "udp",
+		ListenerPort:   53,
+		Scheduler:      "sch",
+		BackendGroupId: backendGroup.Id,
+	}
+	m.Id = "listener1"
+	listener := &LoadbalancerListener{
+		LoadbalancerListener: m,
+		loadbalancer: &Loadbalancer{
+			Loadbalancer:  &models.Loadbalancer{Address: "10.0.0.1"},
+			backendGroups: LoadbalancerBackendGroups{backendGroup.Id: backendGroup},
+		},
+	}
+
+	corpus := NewEmptyLoadbalancerCorpus()
+	cfgs := &envoyConfigs{clusters: map[string]*envoy.Cluster{}}
+	if err := corpus.genEnvoyConfigUdp(cfgs, listener); err != nil {
+		t.Fatalf("genEnvoyConfigUdp: %v", err)
+	}
+	if len(cfgs.listeners) != 1 || len(cfgs.clusters) != 1 {
+		t.Fatalf("want 1 listener and 1 cluster, got %d and %d", len(cfgs.listeners), len(cfgs.clusters))
+	}
+	l := cfgs.listeners[0].(*envoy.Listener)
+	if l.Address.SocketAddress.Protocol != "UDP" || l.UdpListenerConfig == nil {
+		t.Errorf("not an udp listener: %#v", l)
+	}
+	cluster := cfgs.clusters["backends_listener-listener1"]
+	if cluster == nil || cluster.LbPolicy != "RING_HASH" {
+		t.Errorf("bad cluster: %#v", cluster)
+	}
+	proxy := l.ListenerFilters[0].TypedConfig.(envoy.UdpProxy)
+	if len(proxy.HashPolicies) != 1 || !proxy.HashPolicies[0].SourceIp {
+		t.Errorf("want source ip hash policy: %#v", proxy)
+	}
+}
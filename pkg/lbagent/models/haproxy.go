@@ -19,6 +19,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -54,6 +55,12 @@ func (b *LoadbalancerCorpus) GenHaproxyToplevelConfig(dir string, opts *AgentPar
 }
 
 func (b *LoadbalancerCorpus) GenHaproxyConfigs(dir string, opts *AgentParams) (*GenHaproxyConfigsResult, error) {
+	if err := b.GenHaproxyAcmeChallenges(dir); err != nil {
+		return nil, err
+	}
+	if err := b.GenHaproxyFixedResponses(dir); err != nil {
+		return nil, err
+	}
 	if len(b.LoadbalancerCertificates) > 0 {
 		certsBase := filepath.Join(dir, "certs")
 		certsBaseFinal := filepath.Join(agentutils.DirStagingToFinal(dir), "certs")
@@ -66,6 +73,7 @@ func (b *LoadbalancerCorpus) GenHaproxyConfigs(dir string, opts *AgentParams) (*
 			lines := []string{
 				"global",
 				fmt.Sprintf("	crt-base %s", certsBaseFinal),
+				fmt.Sprintf("	ca-base %s", certsBaseFinal),
 				"",
 			}
 			s := strings.Join(lines, "\n")
@@ -75,12 +83,18 @@ func (b *LoadbalancerCorpus) GenHaproxyConfigs(dir string, opts *AgentParams) (*
 			}
 		}
 		for _, lbcert := range b.LoadbalancerCertificates {
+			if lbcert.Certificate == "" {
+				// acme certificate not issued yet
+				continue
+			}
 			d := []byte(lbcert.Certificate)
 			if len(d) > 0 && d[len(d)-1] != '\n' {
 				d = append(d, '\n')
 			}
-			d = append(d, []byte(lbcert.PrivateKey)...)
-			fn := fmt.Sprintf("%s.pem", lbcert.Id)
+			fn := haproxyCertFile(lbcert)
+			if lbcert.CertificateType != computeapi.LB_CERT_TYPE_CA {
+				d = append(d, []byte(lbcert.PrivateKey)...)
+			}
 			p := filepath.Join(certsBase, fn)
 			err := ioutil.WriteFile(p, d, agentutils.FileModeFileSensitive)
 			if err != nil {
@@ -179,13 +193,31 @@ func (b *LoadbalancerCorpus) genHaproxyConfigCommon(lb *Loadbalancer, listener *
 	{
 		bind := fmt.Sprintf("%s:%d", lb.Address, listener.ListenerPort)
 		if listener.ListenerType == "https" && listener.certificate != nil {
-			bind += fmt.Sprintf(" ssl crt %s.pem", listener.certificate.Id)
+			bind += fmt.Sprintf(" ssl crt %s", haproxyCertFile(listener.certificate))
+			var sslMinVer, ciphers string
 			if listener.TLSCipherPolicy != "" {
 				policy := agentutils.HaproxySslPolicy(listener.TLSCipherPolicy)
 				if policy != nil {
-					bind += fmt.Sprintf(" ssl-min-ver %s", policy.SslMinVer)
+					sslMinVer = policy.SslMinVer
+					ciphers = policy.Ciphers
 				}
 			}
+			if listener.TLSMinVersion != "" {
+				sslMinVer = listener.TLSMinVersion
+			}
+			if listener.TLSCiphers != "" {
+				ciphers = listener.TLSCiphers
+			}
+			if sslMinVer != "" {
+				bind += fmt.Sprintf(" ssl-min-ver %s", sslMinVer)
+			}
+			if ciphers != "" {
+				bind += fmt.Sprintf(" ciphers %s", ciphers)
+			}
+			if haproxyListenerVerifyClient(listener) {
+				bind += fmt.Sprintf(" verify %s ca-file %s",
+					listener.ClientVerify, haproxyCertFile(listener.clientCaCertificate))
+			}
 			if listener.EnableHttp2 {
 				bind += fmt.Sprintf(" alpn h2,http/1.1")
 			}
@@ -207,6 +239,11 @@ func (b *LoadbalancerCorpus) genHaproxyConfigCommon(lb *Loadbalancer, listener *
 			}
 		}
 	}
+	if listener.AccessLog && opts.AgentModel.Params.AccessLog.Sink != "" {
+		if sock, ok := opts.GetAccessLogParams("socket").(string); ok && sock != "" {
+			data["access_log"] = haproxyAccessLogLines(listener, sock)
+		}
+	}
 	if listener.AclStatus == "on" {
 		lbacl, ok := b.LoadbalancerAcls[listener.AclId]
 		if ok && lbacl.AclEntries != nil && len(*lbacl.AclEntries) > 0 {
@@ -309,6 +346,8 @@ func (b *LoadbalancerCorpus) genHaproxyConfigBackend(data map[string]interface{}
 		if err != nil {
 			return fmt.Errorf("listener %s(%s): %v", listener.Name, listener.Id, err)
 		}
+		backendHttp2 := listener.BackendHttp2 &&
+			(listener.ListenerType == "http" || listener.ListenerType == "https")
 		serverLines := []string{}
 		for _, backend := range backendGroup.backends {
 			serverLine := fmt.Sprintf("server %s %s:%d", backend.Id, backend.Address, backend.Port)
@@ -333,10 +372,19 @@ func (b *LoadbalancerCorpus) genHaproxyConfigBackend(data map[string]interface{}
 			} else {
 				// nothing to do
 			}
-			if backend.Ssl == "on" {
+			if backend.Ssl == "on" || (listener.ListenerType == "https" && listener.BackendSsl) {
 				serverLine += " ssl"
-				serverLine += " verify none"
+				if cacert := listener.backendCaCertificate; listener.BackendSsl && cacert != nil && cacert.Certificate != "" {
+					serverLine += fmt.Sprintf(" verify required ca-file %s", haproxyCertFile(cacert))
+				} else {
+					serverLine += " verify none"
+				}
 				serverLine += " check-ssl"
+				if backendHttp2 {
+					serverLine += " alpn h2"
+				}
+			} else if backendHttp2 {
+				serverLine += " proto h2"
 			}
 			serverLines = append(serverLines, serverLine)
 		}
@@ -428,6 +476,16 @@ func (b *LoadbalancerCorpus) genHaproxyConfigHttp(buf *bytes.Buffer, listener *L
 		lb = listener.loadbalancer
 	)
 
+	if listener.ListenerType == "https" && listener.certificate != nil && listener.certificate.Certificate == "" {
+		return haproxyConfigErrNop
+	}
+	if listener.ListenerType == "https" && listener.ClientVerify != "" && listener.ClientVerify != computeapi.LB_CLIENT_VERIFY_OFF {
+		// refuse to serve unverified clients when ca is not available
+		if !haproxyListenerVerifyClient(listener) {
+			return haproxyConfigErrNop
+		}
+	}
+
 	data := b.genHaproxyConfigCommon(lb, listener, opts)
 	{
 		// NOTE add X-Real-IP if needed
@@ -440,29 +498,40 @@ func (b *LoadbalancerCorpus) genHaproxyConfigHttp(buf *bytes.Buffer, listener *L
 
 	var (
 		rules     = listener.rules.OrderedEnabledList()
+		acls      = []string{}
 		ruleLines = []string{}
 		backends  = []interface{}{}
-
-		ruleBackendIdGen = func(id string) string {
-			return fmt.Sprintf("backends_rule-%s", id)
-		}
 	)
 	{ // dispatch
 		for _, rule := range rules {
-			sufCond := ""
-			if rule.Domain != "" || rule.Path != "" {
-				sufCond += " if"
-				if rule.Domain != "" {
-					sufCond += fmt.Sprintf(" { hdr_dom(host) %q }", rule.Domain)
-				}
-				if rule.Path != "" {
-					sufCond += fmt.Sprintf(" { path_beg %q }", rule.Path)
+			conds := []string{}
+			if rule.Domain != "" {
+				conds = append(conds, fmt.Sprintf("{ hdr_dom(host) %q }", rule.Domain))
+			}
+			if rule.Path != "" {
+				conds = append(conds, fmt.Sprintf("{ path_beg %q }", rule.Path))
+			}
+			if rule.Condition != "" {
+				ruleAcls, names, err := haproxyRuleConditionAcls(rule)
+				if err != nil {
+					log.Warningf("rule %s(%s): ignored for invalid condition: %v", rule.Name, rule.Id, err)
+					continue
 				}
+				acls = append(acls, ruleAcls...)
+				conds = append(conds, names...)
+			}
+			sufCond := ""
+			if len(conds) > 0 {
+				sufCond = " if " + strings.Join(conds, " ")
 			}
 			if rule.Redirect == computeapi.LB_REDIRECT_OFF {
+				if haproxyRuleIsFixedResponse(rule) {
+					ruleLine := fmt.Sprintf("use_backend %s", haproxyFixedResponseBackendId(rule))
+					ruleLines = append(ruleLines, ruleLine+sufCond)
+					continue
+				}
 				// use_backend rule.Id if xx
-				ruleLine := fmt.Sprintf("use_backend %s", ruleBackendIdGen(rule.Id))
-				ruleLines = append(ruleLines, ruleLine+sufCond)
+				ruleLines = append(ruleLines, haproxyRuleUseBackendLines(haproxyRuleBackendGroups(rule), conds)...)
 				continue
 			} else if rule.Redirect == computeapi.LB_REDIRECT_RAW {
 				// http-request redirect ... if xx
@@ -478,33 +547,40 @@ func (b *LoadbalancerCorpus) genHaproxyConfigHttp(buf *bytes.Buffer, listener *L
 				b.haproxyRedirectLine(&listener.LoadbalancerHTTPRedirect, listener.ListenerType),
 			)
 		}
+		if haproxyListenerVerifyClient(listener) && listener.ClientCertHeaders {
+			ruleLines = append(haproxyClientCertHeaderLines(), ruleLines...)
+		}
+		data["acls"] = acls
 		data["rules"] = ruleLines
 	}
 	{ // those with backend group
 		// rules backend group
 		for _, rule := range rules {
 			// NOTE dup is ok
-			if rule.BackendGroupId == "" {
-				// just in case
-				continue
-			}
-			if rule.Redirect != computeapi.LB_REDIRECT_OFF {
+			if rule.Redirect != computeapi.LB_REDIRECT_OFF || haproxyRuleIsFixedResponse(rule) {
 				continue
 			}
-			backendGroup := lb.backendGroups[rule.BackendGroupId]
-			backendData := map[string]interface{}{
-				"comment": fmt.Sprintf("rule %s(%s) backendGroup %s(%s)",
-					rule.Name, rule.Id,
-					backendGroup.Name, backendGroup.Id),
-				"id": ruleBackendIdGen(rule.Id),
-			}
-			if err := b.genHaproxyConfigBackend(backendData, lb, listener, backendGroup); err != nil {
-				return err
-			}
-			if err := b.genHaproxyConfigHttpRate(backendData, rule.HTTPRequestRate, rule.HTTPRequestRatePerSrc); err != nil {
-				return err
+			// NOTE rate limits apply to each of weighted backend groups
+			for _, lbrbg := range haproxyRuleBackendGroups(rule) {
+				backendGroup, ok := lb.backendGroups[lbrbg.backendGroupId]
+				if !ok {
+					return fmt.Errorf("rule %s(%s): backend group %s not found", rule.Name, rule.Id, lbrbg.backendGroupId)
+				}
+				backendData := map[string]interface{}{
+					"comment": fmt.Sprintf("rule %s(%s) backendGroup %s(%s)",
+						rule.Name, rule.Id,
+						backendGroup.Name, backendGroup.Id),
+					"id": lbrbg.backendId,
+				}
+				if err := b.genHaproxyConfigBackend(backendData, lb, listener, backendGroup); err != nil {
+					return err
+				}
+				if err := b.genHaproxyConfigHttpRate(backendData, rule.HTTPRequestRate, rule.HTTPRequestRatePerSrc); err != nil {
+					return err
+				}
+				backendData["rewrite_rules"] = haproxyRuleRewriteLines(rule)
+				backends = append(backends, backendData)
 			}
-			backends = append(backends, backendData)
 		}
 		// default backend group
 		if listener.Redirect == computeapi.LB_REDIRECT_OFF && listener.BackendGroupId != "" {
@@ -513,7 +589,7 @@ func (b *LoadbalancerCorpus) genHaproxyConfigHttp(buf *bytes.Buffer, listener *L
 				"comment": fmt.Sprintf("listener %s(%s) default backendGroup %s(%s)",
 					listener.Name, listener.Id,
 					backendGroup.Name, backendGroup.Id),
-				"id": fmt.Sprintf("backends_listener_default-%s", listener.Id),
+				"id": haproxyListenerDefaultBackendId(listener),
 			}
 			if err := b.genHaproxyConfigBackend(backendData, lb, listener, backendGroup); err != nil {
 				return err
@@ -530,10 +606,68 @@ func (b *LoadbalancerCorpus) genHaproxyConfigHttp(buf *bytes.Buffer, listener *L
 		// nothing to serve
 		return haproxyConfigErrNop
 	}
+	if acmeLines := b.haproxyAcmeChallengeRules(listener); len(acmeLines) > 0 {
+		// acme challenges go before other use_backend rules, http-request
+		// rules are kept ahead to avoid haproxy warnings
+		i := 0
+		for i < len(ruleLines) && !strings.HasPrefix(ruleLines[i], "use_backend") {
+			i++
+		}
+		lines := append([]string{}, ruleLines[:i]...)
+		lines = append(lines, acmeLines...)
+		data["rules"] = append(lines, ruleLines[i:]...)
+	}
 	err := haproxyConfigTmpl.ExecuteTemplate(buf, "httpListen", data)
 	return err
 }
 
+// haproxyCertFile returns file name of the certificate relative to
+// crt-base/ca-base.  CA bundles have no private key and are kept apart
+func haproxyCertFile(lbcert *LoadbalancerCertificate) string {
+	if lbcert.CertificateType == computeapi.LB_CERT_TYPE_CA {
+		return fmt.Sprintf("%s.ca.pem", lbcert.Id)
+	}
+	return fmt.Sprintf("%s.pem", lbcert.Id)
+}
+
+func haproxyListenerVerifyClient(listener *LoadbalancerListener) bool {
+	switch listener.ClientVerify {
+	case computeapi.LB_CLIENT_VERIFY_OPTIONAL, computeapi.LB_CLIENT_VERIFY_REQUIRED:
+	default:
+		return false
+	}
+	cacert := listener.clientCaCertificate
+	return cacert != nil && cacert.Certificate != ""
+}
+
+// haproxyClientCertHeaderLines passes client certificate info to backends.
+// Headers from clients are always overwritten or removed so that they
+// cannot be forged
+func haproxyClientCertHeaderLines() []string {
+	return []string{
+		"http-request del-header X-SSL-Client-DN",
+		"http-request del-header X-SSL-Client-CN",
+		"http-request del-header X-SSL-Client-Issuer-DN",
+		"http-request del-header X-SSL-Client-Serial",
+		"http-request del-header X-SSL-Client-NotAfter",
+		"http-request set-header X-SSL-Client-Used %[ssl_c_used]",
+		"http-request set-header X-SSL-Client-Verify %[ssl_c_verify]",
+		"http-request set-header X-SSL-Client-DN %{+Q}[ssl_c_s_dn] if { ssl_c_used }",
+		"http-request set-header X-SSL-Client-CN %{+Q}[ssl_c_s_dn(cn)] if { ssl_c_used }",
+		"http-request set-header X-SSL-Client-Issuer-DN %{+Q}[ssl_c_i_dn] if { ssl_c_used }",
+		"http-request set-header X-SSL-Client-Serial %[ssl_c_serial,hex] if { ssl_c_used }",
+		"http-request set-header X-SSL-Client-NotAfter %[ssl_c_notafter] if { ssl_c_used }",
+	}
+}
+
+func haproxyListenerBackendId(listener *LoadbalancerListener) string {
+	return fmt.Sprintf("backends_listener-%s", listener.Id)
+}
+
+func haproxyListenerDefaultBackendId(listener *LoadbalancerListener) string {
+	return fmt.Sprintf("backends_listener_default-%s", listener.Id)
+}
+
 func (b *LoadbalancerCorpus) genHaproxyConfigTcp(buf *bytes.Buffer, listener *LoadbalancerListener, opts *AgentParams) error {
 	lb := listener.loadbalancer
 	data := b.genHaproxyConfigCommon(lb, listener, opts)
@@ -543,7 +677,7 @@ func (b *LoadbalancerCorpus) genHaproxyConfigTcp(buf *bytes.Buffer, listener *Lo
 			"comment": fmt.Sprintf("listener %s(%s) backendGroup %s(%s)",
 				listener.Name, listener.Id,
 				backendGroup.Name, backendGroup.Id),
-			"id": fmt.Sprintf("backends_listener-%s", listener.Id),
+			"id": haproxyListenerBackendId(listener),
 		}
 		err := b.genHaproxyConfigBackend(backendData, lb, listener, backendGroup)
 		if err != nil {
@@ -563,7 +697,9 @@ listen {{ .id }}
 	bind {{ .bind }}
 	mode tcp
 	{{- println }}
-	{{- if .log }}	{{ println "option tcplog" }} {{- end }}
+	{{- if .access_log }}
+	{{- range .access_log }}	{{ println . }} {{- end }}
+	{{- else if .log }}	{{ println "option tcplog" }} {{- end }}
 	{{- if .acl }}	{{ println .acl }} {{- end}}
 	{{- if .client_idle_timeout }}	timeout client {{ println .client_idle_timeout }} {{- end}}
 	default_backend {{ .backend.id }}
@@ -580,12 +716,15 @@ frontend {{ .id }}
 	bind {{ .bind }}
 	mode http
 	{{- println }}
-	{{- if .log }}	{{ println "option httplog clf" }} {{- end }}
+	{{- if .access_log }}
+	{{- range .access_log }}	{{ println . }} {{- end }}
+	{{- else if .log }}	{{ println "option httplog clf" }} {{- end }}
 	{{- if .acl }}	{{ println .acl }} {{- end}}
 	{{- if .client_request_timeout }}	timeout http-request {{ println .client_request_timeout }} {{- end}}
 	{{- if .client_idle_timeout }}	timeout http-keep-alive {{ println .client_idle_timeout }} {{- end}}
 	{{- if .xforwardedfor }}	{{ println "option forwardfor" }} {{- end}}
 	{{- if .gzip }}	{{ println "compression algo gzip" }} {{- end}}
+	{{- range .acls }}	{{ println . }} {{- end }}
 	{{- range .rules }}	{{ println . }} {{- end }}
 	{{- if .default_backend.id }}	default_backend {{ println .default_backend.id }} {{- end }}
 {{- range .backends }}
@@ -604,6 +743,7 @@ backend {{ .id }}
 	balance {{ .balanceAlgorithm }}
 	{{- println }}
 	{{- range .rate_rules }}	{{ println . }} {{- end }}
+	{{- range .rewrite_rules }}	{{ println . }} {{- end }}
 	{{- if .backend_connect_timeout }}	timeout connect {{ println .backend_connect_timeout }} {{- end}}
 	{{- if .backend_idle_timeout }}	timeout server {{ println .backend_idle_timeout }} {{- end}}
 	{{- if .timeout_check }}	{{ println .timeout_check }} {{- end }}
@@ -613,3 +753,51 @@ backend {{ .id }}
 	{{- range .servers }}	{{ println . }} {{- end }}
 {{- end }}
 `))
+
+// haproxyErrorfileBackend has no server and answers every request with
+// its 503 errorfile, which is the raw http response
+type haproxyErrorfileBackend struct {
+	backendId string
+	name      string
+	resp      string
+}
+
+func haproxyHttpResponse(code int, contentType, body string) string {
+	reason := http.StatusText(code)
+	if reason == "" {
+		reason = "Unknown"
+	}
+	return fmt.Sprintf("HTTP/1.0 %d %s\r\nContent-Type: %s\r\nContent-Length: %d\r\nCache-Control: no-cache\r\nConnection: close\r\n\r\n%s",
+		code, reason, contentType, len(body), body)
+}
+
+// genHaproxyErrorfileBackends writes responses of backends into subdir of
+// dir, and the backends into config file cfgName
+func genHaproxyErrorfileBackends(dir, subdir, cfgName string, backends []haproxyErrorfileBackend) error {
+	if len(backends) == 0 {
+		return nil
+	}
+	respsBase := filepath.Join(dir, subdir)
+	respsBaseFinal := filepath.Join(agentutils.DirStagingToFinal(dir), subdir)
+	err := os.MkdirAll(respsBase, agentutils.FileModeDir)
+	if err != nil {
+		return fmt.Errorf("mkdir %s: %s", respsBase, err)
+	}
+	buf := bytes.NewBufferString(fmt.Sprintf("# yunion lb auto-generated %s\n", cfgName))
+	for _, backend := range backends {
+		fn := fmt.Sprintf("%s.http", backend.name)
+		err := ioutil.WriteFile(filepath.Join(respsBase, fn), []byte(backend.resp), agentutils.FileModeFile)
+		if err != nil {
+			return fmt.Errorf("write %s response %s: %s", subdir, backend.name, err)
+		}
+		buf.WriteString(fmt.Sprintf("\nbackend %s\n", backend.backendId))
+		buf.WriteString("	mode http\n")
+		buf.WriteString(fmt.Sprintf("	errorfile 503 %s\n", filepath.Join(respsBaseFinal, fn)))
+	}
+	p := filepath.Join(dir, cfgName)
+	err = ioutil.WriteFile(p, buf.Bytes(), agentutils.FileModeFile)
+	if err != nil {
+		return fmt.Errorf("write %s: %s", cfgName, err)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"yunion.io/x/pkg/errors"
+
+	loggerapi "yunion.io/x/onecloud/pkg/apis/logger"
+)
+
+// HaproxyAccessLogTag leads each access log line so that lines can be told
+// apart from those emitted by haproxy itself
+const HaproxyAccessLogTag = "lbaccess"
+
+const (
+	// fields following tag and listener id.  Fields not applicable to tcp
+	// listeners are filled with "-"
+	haproxyAccessLogFormatHttp = `%Ts %ci %cp %HM %{+Q}[capture.req.hdr(0)] %{+Q}HU %ST %U %B %Tt %b %s %ts`
+	haproxyAccessLogFormatTcp  = `%Ts %ci %cp - - - - %U %B %Tt %b %s %ts`
+
+	haproxyAccessLogNFields = 14
+)
+
+// haproxyAccessLogLines returns frontend lines sending structured access
+// log of the listener to lbagent over unix datagram socket sock
+func haproxyAccessLogLines(listener *LoadbalancerListener, sock string) []string {
+	var (
+		lines  []string
+		format string
+	)
+	switch listener.ListenerType {
+	case "http", "https":
+		lines = append(lines, "capture request header Host len 128")
+		format = haproxyAccessLogFormatHttp
+	case "tcp":
+		format = haproxyAccessLogFormatTcp
+	default:
+		return nil
+	}
+	lines = append(lines,
+		fmt.Sprintf("log %s local0 info", sock),
+		"no option dontlog-normal",
+		fmt.Sprintf(`log-format "%s %s %s"`, HaproxyAccessLogTag, listener.Id, format),
+	)
+	return lines
+}
+
+func haproxyAccessLogTokens(s string) ([]string, error) {
+	var (
+		toks []string
+		i    int
+	)
+	for i < len(s) {
+		if s[i] == ' ' {
+			i++
+			continue
+		}
+		if s[i] != '"' {
+			j := strings.IndexByte(s[i:], ' ')
+			if j < 0 {
+				j = len(s) - i
+			}
+			toks = append(toks, s[i:i+j])
+			i += j
+			continue
+		}
+		var (
+			buf    strings.Builder
+			closed bool
+		)
+		for i++; i < len(s); i++ {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) {
+				i++
+				buf.WriteByte(s[i])
+				continue
+			}
+			if c == '"' {
+				closed = true
+				i++
+				break
+			}
+			buf.WriteByte(c)
+		}
+		if !closed {
+			return nil, errors.Error("unterminated quoted field")
+		}
+		toks = append(toks, buf.String())
+	}
+	return toks, nil
+}
+
+func haproxyAccessLogString(s string) string {
+	if s == "-" || s == "<NOSRV>" {
+		return ""
+	}
+	return s
+}
+
+func haproxyAccessLogInt(s string) (int64, error) {
+	if s == "-" {
+		return 0, nil
+	}
+	// "+" is prepended to byte counts and timers when option logasap is
+	// in effect
+	return strconv.ParseInt(strings.TrimPrefix(s, "+"), 10, 64)
+}
+
+// ParseHaproxyAccessLog parses syslog message sent by haproxy according
+// to format set by haproxyAccessLogLines.  Only listener id is filled in
+// from what are known about the listener.  Name of haproxy backend is
+// returned as the 2nd value for looking up backend group id with
+// HaproxyBackendGroupIds
+func ParseHaproxyAccessLog(msg string) (*loggerapi.LbAccessLogEntry, string, error) {
+	msg = strings.TrimRight(msg, "\r\n\x00")
+	i := strings.Index(msg, HaproxyAccessLogTag+" ")
+	if i < 0 || (i > 0 && msg[i-1] != ' ') {
+		return nil, "", errors.Errorf("no %s tag", HaproxyAccessLogTag)
+	}
+	toks, err := haproxyAccessLogTokens(msg[i+len(HaproxyAccessLogTag)+1:])
+	if err != nil {
+		return nil, "", err
+	}
+	if len(toks) != haproxyAccessLogNFields {
+		return nil, "", errors.Errorf("want %d fields, got %d", haproxyAccessLogNFields, len(toks))
+	}
+	var nums [6]int64
+	for j, k := range []int{1, 3, 7, 8, 9, 10} {
+		nums[j], err = haproxyAccessLogInt(toks[k])
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "field %d", k)
+		}
+	}
+	entry := &loggerapi.LbAccessLogEntry{
+		ListenerId:       toks[0],
+		Timestamp:        time.Unix(nums[0], 0).UTC(),
+		ClientIp:         toks[2],
+		ClientPort:       int(nums[1]),
+		Method:           haproxyAccessLogString(toks[4]),
+		Host:             haproxyAccessLogString(toks[5]),
+		Uri:              haproxyAccessLogString(toks[6]),
+		Status:           int(nums[2]),
+		BytesIn:          nums[3],
+		BytesOut:         nums[4],
+		Duration:         nums[5],
+		BackendId:        haproxyAccessLogString(toks[12]),
+		TerminationState: haproxyAccessLogString(toks[13]),
+	}
+	return entry, haproxyAccessLogString(toks[11]), nil
+}
+
+// HaproxyBackendGroupIds maps names of haproxy backends of the listener, as
+// logged in access logs, to ids of backend groups they forward to.
+// Requests terminated by the frontend itself, fixed responses and acme
+// challenges have backends not in the map
+func (listener *LoadbalancerListener) HaproxyBackendGroupIds() map[string]string {
+	r := map[string]string{}
+	if listener.BackendGroupId != "" {
+		r[haproxyListenerBackendId(listener)] = listener.BackendGroupId
+		r[haproxyListenerDefaultBackendId(listener)] = listener.BackendGroupId
+	}
+	for _, rule := range listener.rules {
+		for _, lbrbg := range haproxyRuleBackendGroups(rule) {
+			r[lbrbg.backendId] = lbrbg.backendGroupId
+		}
+	}
+	return r
+}
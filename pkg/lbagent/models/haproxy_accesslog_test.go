@@ -0,0 +1,121 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	loggerapi "yunion.io/x/onecloud/pkg/apis/logger"
+	"yunion.io/x/onecloud/pkg/mcclient/models"
+)
+
+func TestParseHaproxyAccessLog(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		msg     string
+		want    *loggerapi.LbAccessLogEntry
+		backend string
+	}{
+		{
+			name: "http",
+			msg:  `<134>Oct 19 12:00:00 haproxy[42]: lbaccess lbl1 1760875200 10.0.0.8 52100 GET "www.example.com" "/a?b=\"c\"" 200 120 2048 15 backends_rule-lbr1-0 lbb1 --` + "\n",
+			want: &loggerapi.LbAccessLogEntry{
+				Timestamp:        time.Unix(1760875200, 0).UTC(),
+				ListenerId:       "lbl1",
+				ClientIp:         "10.0.0.8",
+				ClientPort:       52100,
+				Method:           "GET",
+				Host:             "www.example.com",
+				Uri:              `/a?b="c"`,
+				Status:           200,
+				BytesIn:          120,
+				BytesOut:         2048,
+				Duration:         15,
+				BackendId:        "lbb1",
+				TerminationState: "--",
+			},
+			backend: "backends_rule-lbr1-0",
+		},
+		{
+			name: "tcp no server",
+			msg:  `<134>Oct 19 12:00:00 haproxy[42]: lbaccess lbl2 1760875200 10.0.0.9 52101 - - - - 0 0 +3 lbl2 <NOSRV> SC`,
+			want: &loggerapi.LbAccessLogEntry{
+				Timestamp:        time.Unix(1760875200, 0).UTC(),
+				ListenerId:       "lbl2",
+				ClientIp:         "10.0.0.9",
+				ClientPort:       52101,
+				Duration:         3,
+				TerminationState: "SC",
+			},
+			backend: "lbl2",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			got, backend, err := ParseHaproxyAccessLog(c.msg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *got != *c.want {
+				t.Errorf("got\n%#v\nwant\n%#v", got, c.want)
+			}
+			if backend != c.backend {
+				t.Errorf("got backend %q, want %q", backend, c.backend)
+			}
+		})
+	}
+
+	for _, msg := range []string{
+		`<134>Oct 19 12:00:00 haproxy[42]: Proxy lbl1 started.`,
+		`<134>Oct 19 12:00:00 haproxy[42]: xlbaccess lbl1 1760875200 10.0.0.8 52100 - - - - 0 0 3 lbl1 <NOSRV> SC`,
+		`<134>Oct 19 12:00:00 haproxy[42]: lbaccess lbl1 1760875200 10.0.0.8 52100 GET "www.example.com" "/a 200 120 2048 15 lbbg1 lbb1 --`,
+		`<134>Oct 19 12:00:00 haproxy[42]: lbaccess lbl1 now 10.0.0.8 52100 - - - - 0 0 3 lbl1 <NOSRV> SC`,
+	} {
+		if _, _, err := ParseHaproxyAccessLog(msg); err == nil {
+			t.Errorf("expecting error for %q", msg)
+		}
+	}
+}
+
+func TestHaproxyBackendGroupIds(t *testing.T) {
+	listener := &LoadbalancerListener{LoadbalancerListener: &models.LoadbalancerListener{}}
+	listener.Id = "lbl1"
+	listener.BackendGroupId = "lbbg0"
+	rule1 := &LoadbalancerListenerRule{LoadbalancerListenerRule: &models.LoadbalancerListenerRule{}}
+	rule1.Id = "lbr1"
+	rule1.BackendGroupId = "lbbg1"
+	rule2 := &LoadbalancerListenerRule{LoadbalancerListenerRule: &models.LoadbalancerListenerRule{}}
+	rule2.Id = "lbr2"
+	rule2.BackendGroups = &models.LoadbalancerListenerRuleBackendGroups{
+		{BackendGroupId: "lbbg2", Weight: 90},
+		{BackendGroupId: "lbbg3", Weight: 10},
+	}
+	listener.rules = LoadbalancerListenerRules{
+		rule1.Id: rule1,
+		rule2.Id: rule2,
+	}
+	got := listener.HaproxyBackendGroupIds()
+	want := map[string]string{
+		"backends_listener-lbl1":         "lbbg0",
+		"backends_listener_default-lbl1": "lbbg0",
+		"backends_rule-lbr1":             "lbbg1",
+		"backends_rule-lbr2-0":           "lbbg2",
+		"backends_rule-lbr2-1":           "lbbg3",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"yunion.io/x/log"
+)
+
+const acmeHttpChallengePath = "/.well-known/acme-challenge/"
+
+var (
+	// token and key authorization are base64url encoded as RFC 8555
+	// section 8.1 requires, anything else must not go into haproxy config
+	acmeHttpTokenRegexp   = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	acmeHttpKeyAuthRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+$`)
+)
+
+type acmeHttpChallenge struct {
+	projectId string
+	token     string
+	keyAuth   string
+}
+
+// acmeHttpChallenges returns pending http-01 challenges of certificates,
+// sorted by token for stable config output
+func (b *LoadbalancerCorpus) acmeHttpChallenges() []acmeHttpChallenge {
+	chals := []acmeHttpChallenge{}
+	for _, lbcert := range b.LoadbalancerCertificates {
+		for token, keyAuth := range lbcert.AcmeHttpTokens {
+			if !acmeHttpTokenRegexp.MatchString(token) || !acmeHttpKeyAuthRegexp.MatchString(keyAuth) {
+				log.Warningf("certificate %s(%s): ignore invalid acme http token %q", lbcert.Name, lbcert.Id, token)
+				continue
+			}
+			chals = append(chals, acmeHttpChallenge{
+				projectId: lbcert.ProjectId,
+				token:     token,
+				keyAuth:   keyAuth,
+			})
+		}
+	}
+	sort.Slice(chals, func(i, j int) bool {
+		return chals[i].token < chals[j].token
+	})
+	return chals
+}
+
+func acmeHttpChallengeBackendId(token string) string {
+	return fmt.Sprintf("acme_challenge-%s", token)
+}
+
+// GenHaproxyAcmeChallenges writes one backend for each pending http-01
+// challenge, answering with the key authorization
+func (b *LoadbalancerCorpus) GenHaproxyAcmeChallenges(dir string) error {
+	backends := []haproxyErrorfileBackend{}
+	for _, chal := range b.acmeHttpChallenges() {
+		backends = append(backends, haproxyErrorfileBackend{
+			backendId: acmeHttpChallengeBackendId(chal.token),
+			name:      chal.token,
+			resp:      haproxyHttpResponse(http.StatusOK, "text/plain", chal.keyAuth),
+		})
+	}
+	return genHaproxyErrorfileBackends(dir, "acme-challenge", "02-acme-challenge.cfg", backends)
+}
+
+// haproxyAcmeChallengeRules dispatches requests of http-01 challenges to
+// their backends.  Only challenges of certificates in the same project
+// as the listener are answered, so that nobody can obtain certificates of
+// domains served by load balancers of others
+func (b *LoadbalancerCorpus) haproxyAcmeChallengeRules(listener *LoadbalancerListener) []string {
+	lines := []string{}
+	for _, chal := range b.acmeHttpChallenges() {
+		if chal.projectId != listener.ProjectId {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("use_backend %s if { path %s%s }",
+			acmeHttpChallengeBackendId(chal.token), acmeHttpChallengePath, chal.token))
+	}
+	return lines
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"yunion.io/x/onecloud/pkg/mcclient/models"
+)
+
+func newAcmeTestCorpus() *LoadbalancerCorpus {
+	corpus := NewEmptyLoadbalancerCorpus()
+	for _, cert := range []struct {
+		id        string
+		projectId string
+		tokens    map[string]string
+	}{
+		{"cert1", "proj1", map[string]string{"tok-B": "tok-B.thumb", "tok_A": "tok_A.thumb"}},
+		{"cert2", "proj2", map[string]string{"tok-C": "tok-C.thumb"}},
+		{"cert3", "proj1", map[string]string{"bad token": "x.y", "tok-D": "bad\nkeyauth"}},
+	} {
+		m := &models.LoadbalancerCertificate{AcmeHttpTokens: cert.tokens}
+		m.Id = cert.id
+		m.ProjectId = cert.projectId
+		corpus.LoadbalancerCertificates[cert.id] = &LoadbalancerCertificate{LoadbalancerCertificate: m}
+	}
+	return corpus
+}
+
+func TestHaproxyAcmeChallengeRules(t *testing.T) {
+	corpus := newAcmeTestCorpus()
+	listener := &LoadbalancerListener{LoadbalancerListener: &models.LoadbalancerListener{}}
+	listener.ProjectId = "proj1"
+
+	got := corpus.haproxyAcmeChallengeRules(listener)
+	want := []string{
+		"use_backend acme_challenge-tok-B if { path /.well-known/acme-challenge/tok-B }",
+		"use_backend acme_challenge-tok_A if { path /.well-known/acme-challenge/tok_A }",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rules of proj1:\ngot  %q\nwant %q", got, want)
+	}
+
+	listener.ProjectId = "proj3"
+	if got := corpus.haproxyAcmeChallengeRules(listener); len(got) != 0 {
+		t.Errorf("rules of proj3: want none, got %q", got)
+	}
+}
+
+func TestGenHaproxyAcmeChallenges(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "lbagent-acme")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	dir := filepath.Join(tmpDir, "haproxy.staging")
+
+	corpus := newAcmeTestCorpus()
+	if err := corpus.GenHaproxyAcmeChallenges(dir); err != nil {
+		t.Fatalf("GenHaproxyAcmeChallenges: %v", err)
+	}
+
+	cfg, err := ioutil.ReadFile(filepath.Join(dir, "02-acme-challenge.cfg"))
+	if err != nil {
+		t.Fatalf("read cfg: %v", err)
+	}
+	errorfile := filepath.Join(tmpDir, "haproxy", "acme-challenge", "tok-C.http")
+	if !strings.Contains(string(cfg), "backend acme_challenge-tok-C\n\tmode http\n\terrorfile 503 "+errorfile+"\n") {
+		t.Errorf("unexpected cfg:\n%s", cfg)
+	}
+	if strings.Contains(string(cfg), "tok-D") || strings.Contains(string(cfg), "bad token") {
+		t.Errorf("invalid tokens should be ignored:\n%s", cfg)
+	}
+
+	resp, err := ioutil.ReadFile(filepath.Join(dir, "acme-challenge", "tok-C.http"))
+	if err != nil {
+		t.Fatalf("read errorfile: %v", err)
+	}
+	if !strings.HasPrefix(string(resp), "HTTP/1.0 200 OK\r\n") || !strings.HasSuffix(string(resp), "\r\n\r\ntok-C.thumb") {
+		t.Errorf("unexpected errorfile:\n%q", resp)
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"yunion.io/x/log"
+
+	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
+)
+
+var (
+	// values are validated by region, checked here again as they go
+	// into haproxy config verbatim
+	haproxyRuleSafeValueRegexp   = regexp.MustCompile(`^[ !#-\[\]_-~]+$`)
+	haproxyRuleSafeTokenRegexp   = regexp.MustCompile(`^[A-Za-z0-9._~-]+$`)
+	haproxyRuleSafePathRegexp    = regexp.MustCompile(`^/[A-Za-z0-9._~/-]*$`)
+	haproxyRuleContentTypeRegexp = regexp.MustCompile(`^[a-z]+/[a-z0-9.+-]+$`)
+)
+
+// haproxyGlobRegexp converts glob pattern with * and ? into anchored
+// regular expression.  Meta chars are put into brackets instead of being
+// escaped with backslash, which would be eaten by haproxy config parser
+func haproxyGlobRegexp(glob string) string {
+	buf := bytes.NewBufferString("^")
+	for _, c := range glob {
+		switch c {
+		case '*':
+			buf.WriteString(".*")
+		case '?':
+			buf.WriteString(".")
+		case '.', '+', '(', ')', '|', '{', '}', '$', '[', ']':
+			buf.WriteString("[" + string(c) + "]")
+		default:
+			buf.WriteRune(c)
+		}
+	}
+	buf.WriteString("$")
+	return buf.String()
+}
+
+func haproxyGlobRegexps(globs []string) (string, error) {
+	res := []string{}
+	for _, glob := range globs {
+		if !haproxyRuleSafeValueRegexp.MatchString(glob) {
+			return "", fmt.Errorf("invalid value %q", glob)
+		}
+		res = append(res, fmt.Sprintf("%q", haproxyGlobRegexp(glob)))
+	}
+	if len(res) == 0 {
+		return "", fmt.Errorf("empty values")
+	}
+	return strings.Join(res, " "), nil
+}
+
+func haproxyRuleAclName(rule *LoadbalancerListenerRule, i int) string {
+	return fmt.Sprintf("rule_%s_%d", rule.Id, i)
+}
+
+// haproxyRuleConditionAcls renders conditions of the rule into named acls.
+// Values of the same condition are or'ed by declaring the acl multiple
+// times, names of the acls are returned to be and'ed by the caller
+func haproxyRuleConditionAcls(rule *LoadbalancerListenerRule) (acls []string, names []string, err error) {
+	conds, err := computeapi.ParseLoadbalancerListenerRuleConditions(rule.Condition)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, cond := range conds {
+		name := haproxyRuleAclName(rule, i)
+		switch cond.Field {
+		case computeapi.LB_RULE_CONDITION_HTTP_HEADER:
+			conf := cond.HttpHeaderConfig
+			if conf == nil || !haproxyRuleSafeTokenRegexp.MatchString(conf.HttpHeaderName) {
+				return nil, nil, fmt.Errorf("%s: invalid header name", cond.Field)
+			}
+			res, err := haproxyGlobRegexps(conf.Values)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %v", cond.Field, err)
+			}
+			acls = append(acls, fmt.Sprintf("acl %s req.hdr(%s) -m reg -i %s", name, conf.HttpHeaderName, res))
+		case computeapi.LB_RULE_CONDITION_PATH_PATTERN:
+			if cond.PathPatternConfig == nil {
+				return nil, nil, fmt.Errorf("%s: missing config", cond.Field)
+			}
+			res, err := haproxyGlobRegexps(cond.PathPatternConfig.Values)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %v", cond.Field, err)
+			}
+			acls = append(acls, fmt.Sprintf("acl %s path -m reg %s", name, res))
+		case computeapi.LB_RULE_CONDITION_HTTP_REQUEST_METHOD:
+			if cond.HttpRequestMethodConfig == nil || len(cond.HttpRequestMethodConfig.Values) == 0 {
+				return nil, nil, fmt.Errorf("%s: empty values", cond.Field)
+			}
+			for _, method := range cond.HttpRequestMethodConfig.Values {
+				if !haproxyRuleSafeTokenRegexp.MatchString(method) {
+					return nil, nil, fmt.Errorf("%s: invalid method %q", cond.Field, method)
+				}
+			}
+			acls = append(acls, fmt.Sprintf("acl %s method %s", name, strings.Join(cond.HttpRequestMethodConfig.Values, " ")))
+		case computeapi.LB_RULE_CONDITION_HOST_HEADER:
+			if cond.HostHeaderConfig == nil {
+				return nil, nil, fmt.Errorf("%s: missing config", cond.Field)
+			}
+			res, err := haproxyGlobRegexps(cond.HostHeaderConfig.Values)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %v", cond.Field, err)
+			}
+			acls = append(acls, fmt.Sprintf("acl %s req.hdr(host),field(1,:) -m reg -i %s", name, res))
+		case computeapi.LB_RULE_CONDITION_QUERY_STRING:
+			if cond.QueryStringConfig == nil || len(cond.QueryStringConfig.Values) == 0 {
+				return nil, nil, fmt.Errorf("%s: empty values", cond.Field)
+			}
+			for _, kv := range cond.QueryStringConfig.Values {
+				if !haproxyRuleSafeTokenRegexp.MatchString(kv.Key) {
+					return nil, nil, fmt.Errorf("%s: invalid key %q", cond.Field, kv.Key)
+				}
+				res, err := haproxyGlobRegexps([]string{kv.Value})
+				if err != nil {
+					return nil, nil, fmt.Errorf("%s: %v", cond.Field, err)
+				}
+				acls = append(acls, fmt.Sprintf("acl %s urlp(%s) -m reg -i %s", name, kv.Key, res))
+			}
+		case computeapi.LB_RULE_CONDITION_SOURCE_IP:
+			if cond.SourceIpConfig == nil || len(cond.SourceIpConfig.Values) == 0 {
+				return nil, nil, fmt.Errorf("%s: empty values", cond.Field)
+			}
+			for _, src := range cond.SourceIpConfig.Values {
+				if !haproxyRuleSafeTokenRegexp.MatchString(strings.NewReplacer(":", "", "/", "").Replace(src)) {
+					return nil, nil, fmt.Errorf("%s: invalid addr %q", cond.Field, src)
+				}
+			}
+			acls = append(acls, fmt.Sprintf("acl %s src %s", name, strings.Join(cond.SourceIpConfig.Values, " ")))
+		default:
+			return nil, nil, fmt.Errorf("unsupported condition %q", cond.Field)
+		}
+		names = append(names, name)
+	}
+	return acls, names, nil
+}
+
+type haproxyRuleBackendGroup struct {
+	backendId      string
+	backendGroupId string
+	weight         int
+}
+
+// haproxyRuleBackendGroups returns backend groups the rule forwards to.
+// Groups of zero weight are left out
+func haproxyRuleBackendGroups(rule *LoadbalancerListenerRule) []haproxyRuleBackendGroup {
+	if rule.BackendGroups == nil || len(*rule.BackendGroups) == 0 {
+		if rule.BackendGroupId == "" {
+			return nil
+		}
+		return []haproxyRuleBackendGroup{
+			{
+				backendId:      fmt.Sprintf("backends_rule-%s", rule.Id),
+				backendGroupId: rule.BackendGroupId,
+				weight:         1,
+			},
+		}
+	}
+	r := []haproxyRuleBackendGroup{}
+	for i, lbrbg := range *rule.BackendGroups {
+		if lbrbg.Weight <= 0 {
+			continue
+		}
+		r = append(r, haproxyRuleBackendGroup{
+			backendId:      fmt.Sprintf("backends_rule-%s-%d", rule.Id, i),
+			backendGroupId: lbrbg.BackendGroupId,
+			weight:         lbrbg.Weight,
+		})
+	}
+	return r
+}
+
+// haproxyRuleUseBackendLines dispatches requests to backend groups by
+// weight.  The k-th group is chosen with probability of its weight over
+// the sum of weights of groups from k on, given that groups before it are
+// not chosen.  This way rand() can be evaluated independently in each line
+func haproxyRuleUseBackendLines(lbrbgs []haproxyRuleBackendGroup, conds []string) []string {
+	remaining := 0
+	for _, lbrbg := range lbrbgs {
+		remaining += lbrbg.weight
+	}
+	lines := []string{}
+	for i, lbrbg := range lbrbgs {
+		terms := append([]string{}, conds...)
+		if i < len(lbrbgs)-1 {
+			terms = append(terms, fmt.Sprintf("{ rand(%d) lt %d }", remaining, lbrbg.weight))
+		}
+		line := fmt.Sprintf("use_backend %s", lbrbg.backendId)
+		if len(terms) > 0 {
+			line += " if " + strings.Join(terms, " ")
+		}
+		lines = append(lines, line)
+		remaining -= lbrbg.weight
+	}
+	return lines
+}
+
+// haproxyRuleRewriteLines changes host and path of requests before they
+// are forwarded.  The path prefix matched by the rule is replaced
+func haproxyRuleRewriteLines(rule *LoadbalancerListenerRule) []string {
+	lines := []string{}
+	if host := rule.RewriteHost; host != "" {
+		if !haproxyRuleSafeTokenRegexp.MatchString(strings.Replace(host, ":", "", 1)) {
+			log.Warningf("rule %s(%s): ignore invalid rewrite host %q", rule.Name, rule.Id, host)
+		} else {
+			lines = append(lines, fmt.Sprintf("http-request set-header Host %s", host))
+		}
+	}
+	if path := rule.RewritePath; path != "" {
+		prefix := rule.Path
+		if prefix == "" {
+			prefix = "/"
+		}
+		if !haproxyRuleSafePathRegexp.MatchString(path) || !haproxyRuleSafePathRegexp.MatchString(prefix) {
+			log.Warningf("rule %s(%s): ignore invalid rewrite path %q", rule.Name, rule.Id, path)
+		} else {
+			prefixRe := strings.Replace(prefix, ".", "[.]", -1)
+			lines = append(lines, fmt.Sprintf("http-request set-path %%[path,regsub(^%s,%s)]", prefixRe, path))
+		}
+	}
+	return lines
+}
+
+func haproxyFixedResponseBackendId(rule *LoadbalancerListenerRule) string {
+	return fmt.Sprintf("fixed_response-%s", rule.Id)
+}
+
+func haproxyRuleIsFixedResponse(rule *LoadbalancerListenerRule) bool {
+	return rule.FixedResponseCode > 0 && rule.Redirect == computeapi.LB_REDIRECT_OFF
+}
+
+// GenHaproxyFixedResponses writes one backend for each rule with fixed
+// response
+func (b *LoadbalancerCorpus) GenHaproxyFixedResponses(dir string) error {
+	rules := []*LoadbalancerListenerRule{}
+	for _, rule := range b.LoadbalancerListenerRules {
+		if rule.Status == "enabled" && haproxyRuleIsFixedResponse(rule) {
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Id < rules[j].Id
+	})
+	backends := []haproxyErrorfileBackend{}
+	for _, rule := range rules {
+		contentType := rule.FixedResponseContentType
+		if !haproxyRuleContentTypeRegexp.MatchString(contentType) {
+			contentType = "text/plain"
+		}
+		backends = append(backends, haproxyErrorfileBackend{
+			backendId: haproxyFixedResponseBackendId(rule),
+			name:      rule.Id,
+			resp:      haproxyHttpResponse(rule.FixedResponseCode, contentType, rule.FixedResponseBody),
+		})
+	}
+	return genHaproxyErrorfileBackends(dir, "fixed-response", "03-fixed-response.cfg", backends)
+}
@@ -0,0 +1,100 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"reflect"
+	"testing"
+
+	"yunion.io/x/onecloud/pkg/mcclient/models"
+)
+
+func TestHaproxyGlobRegexp(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want string
+	}{
+		{"canary", "^canary$"},
+		{"*.example.com", "^.*[.]example[.]com$"},
+		{"v?-(beta)", "^v.-[(]beta[)]$"},
+		{"a[1]+$", "^a[[]1[]][+][$]$"},
+	} {
+		if got := haproxyGlobRegexp(c.in); got != c.want {
+			t.Errorf("%q: got %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestHaproxyRuleConditionAcls(t *testing.T) {
+	rule := &LoadbalancerListenerRule{LoadbalancerListenerRule: &models.LoadbalancerListenerRule{}}
+	rule.Id = "r1"
+	rule.Condition = `[
+		{"field":"http-header","httpHeaderConfig":{"HttpHeaderName":"X-Canary","values":["yes","on*"]}},
+		{"field":"query-string","queryStringConfig":{"values":[{"key":"ver","value":"2"},{"key":"beta","value":"1"}]}},
+		{"field":"http-request-method","httpRequestMethodConfig":{"values":["GET","HEAD"]}},
+		{"field":"source-ip","sourceIpConfig":{"values":["10.0.0.0/8","fd00::/8"]}}
+	]`
+	acls, names, err := haproxyRuleConditionAcls(rule)
+	if err != nil {
+		t.Fatalf("haproxyRuleConditionAcls: %v", err)
+	}
+	wantAcls := []string{
+		`acl rule_r1_0 req.hdr(X-Canary) -m reg -i "^yes$" "^on.*$"`,
+		`acl rule_r1_1 urlp(ver) -m reg -i "^2$"`,
+		`acl rule_r1_1 urlp(beta) -m reg -i "^1$"`,
+		`acl rule_r1_2 method GET HEAD`,
+		`acl rule_r1_3 src 10.0.0.0/8 fd00::/8`,
+	}
+	if !reflect.DeepEqual(acls, wantAcls) {
+		t.Errorf("acls:\ngot  %q\nwant %q", acls, wantAcls)
+	}
+	wantNames := []string{"rule_r1_0", "rule_r1_1", "rule_r1_2", "rule_r1_3"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("names:\ngot  %q\nwant %q", names, wantNames)
+	}
+
+	rule.Condition = `[{"field":"http-header","httpHeaderConfig":{"HttpHeaderName":"X-A","values":["\"quoted\""]}}]`
+	if _, _, err := haproxyRuleConditionAcls(rule); err == nil {
+		t.Errorf("want error for value with quotes")
+	}
+}
+
+func TestHaproxyRuleUseBackendLines(t *testing.T) {
+	rule := &LoadbalancerListenerRule{LoadbalancerListenerRule: &models.LoadbalancerListenerRule{}}
+	rule.Id = "r1"
+	rule.BackendGroupId = "g1"
+	rule.BackendGroups = &models.LoadbalancerListenerRuleBackendGroups{
+		{BackendGroupId: "g1", Weight: 90},
+		{BackendGroupId: "g2", Weight: 0},
+		{BackendGroupId: "g3", Weight: 8},
+		{BackendGroupId: "g4", Weight: 2},
+	}
+	got := haproxyRuleUseBackendLines(haproxyRuleBackendGroups(rule), []string{"rule_r1_0"})
+	want := []string{
+		"use_backend backends_rule-r1-0 if rule_r1_0 { rand(100) lt 90 }",
+		"use_backend backends_rule-r1-2 if rule_r1_0 { rand(10) lt 8 }",
+		"use_backend backends_rule-r1-3 if rule_r1_0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("weighted:\ngot  %q\nwant %q", got, want)
+	}
+
+	rule.BackendGroups = nil
+	got = haproxyRuleUseBackendLines(haproxyRuleBackendGroups(rule), nil)
+	want = []string{"use_backend backends_rule-r1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("single:\ngot  %q\nwant %q", got, want)
+	}
+}
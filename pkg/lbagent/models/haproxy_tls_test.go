@@ -0,0 +1,85 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"testing"
+
+	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/mcclient/models"
+)
+
+func newTlsTestListener() *LoadbalancerListener {
+	newCert := func(id, certType string) *LoadbalancerCertificate {
+		m := &models.LoadbalancerCertificate{
+			Certificate:     "-----BEGIN CERTIFICATE-----",
+			CertificateType: certType,
+		}
+		m.Id = id
+		return &LoadbalancerCertificate{LoadbalancerCertificate: m}
+	}
+	m := &models.LoadbalancerListener{
+		ListenerType: "https",
+		ListenerPort: 443,
+		Scheduler:    "rr",
+	}
+	m.Id = "listener1"
+	m.TLSCipherPolicy = "tls_cipher_policy_1_2_strict"
+	m.TLSMinVersion = computeapi.LB_TLS_VERSION_1_3
+	m.TLSCiphers = "ECDHE-RSA-AES128-GCM-SHA256"
+	m.ClientVerify = computeapi.LB_CLIENT_VERIFY_REQUIRED
+	m.BackendSsl = true
+	return &LoadbalancerListener{
+		LoadbalancerListener: m,
+		loadbalancer: &Loadbalancer{
+			Loadbalancer: &models.Loadbalancer{Address: "10.0.0.1"},
+		},
+		certificate:          newCert("srv", computeapi.LB_CERT_TYPE_SERVER),
+		clientCaCertificate:  newCert("ca1", computeapi.LB_CERT_TYPE_CA),
+		backendCaCertificate: newCert("ca2", computeapi.LB_CERT_TYPE_CA),
+	}
+}
+
+func TestHaproxyConfigTls(t *testing.T) {
+	corpus := NewEmptyLoadbalancerCorpus()
+	opts := &AgentParams{AgentModel: &models.LoadbalancerAgent{}}
+	listener := newTlsTestListener()
+
+	data := corpus.genHaproxyConfigCommon(listener.loadbalancer, listener, opts)
+	want := "10.0.0.1:443 ssl crt srv.pem ssl-min-ver TLSv1.3 ciphers ECDHE-RSA-AES128-GCM-SHA256 verify required ca-file ca1.ca.pem"
+	if got := data["bind"]; got != want {
+		t.Errorf("bind:\ngot  %q\nwant %q", got, want)
+	}
+
+	backend := &models.LoadbalancerBackend{Address: "192.168.0.2", Port: 8443}
+	backend.Id = "backend1"
+	backendGroup := &LoadbalancerBackendGroup{
+		backends: LoadbalancerBackends{backend.Id: &LoadbalancerBackend{LoadbalancerBackend: backend}},
+	}
+	backendData := map[string]interface{}{}
+	if err := corpus.genHaproxyConfigBackend(backendData, listener.loadbalancer, listener, backendGroup); err != nil {
+		t.Fatalf("genHaproxyConfigBackend: %v", err)
+	}
+	servers := backendData["servers"].([]string)
+	want = "server backend1 192.168.0.2:8443 weight 1 ssl verify required ca-file ca2.ca.pem check-ssl"
+	if len(servers) != 1 || servers[0] != want {
+		t.Errorf("servers:\ngot  %q\nwant %q", servers, want)
+	}
+
+	listener.clientCaCertificate = nil
+	if haproxyListenerVerifyClient(listener) {
+		t.Errorf("client verification should be off without ca certificate")
+	}
+}
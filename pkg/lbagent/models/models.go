@@ -31,9 +31,11 @@ type Loadbalancer struct {
 type LoadbalancerListener struct {
 	*models.LoadbalancerListener
 
-	loadbalancer *Loadbalancer
-	certificate  *LoadbalancerCertificate
-	rules        LoadbalancerListenerRules
+	loadbalancer         *Loadbalancer
+	certificate          *LoadbalancerCertificate
+	clientCaCertificate  *LoadbalancerCertificate
+	backendCaCertificate *LoadbalancerCertificate
+	rules                LoadbalancerListenerRules
 }
 
 type LoadbalancerListenerRule struct {
@@ -19,6 +19,7 @@ import (
 
 	"yunion.io/x/log"
 
+	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/mcclient/models"
 	"yunion.io/x/onecloud/pkg/mcclient/modulebase"
 	modules "yunion.io/x/onecloud/pkg/mcclient/modules/compute"
@@ -156,15 +157,29 @@ func (ms LoadbalancerListeners) JoinCertificates(subEntries LoadbalancerCertific
 	correct := true
 	for _, m := range ms {
 		m.certificate = nil
-		if m.CertificateId != "" {
-			subEntry, ok := subEntries[m.CertificateId]
+		m.clientCaCertificate = nil
+		m.backendCaCertificate = nil
+		joins := []struct {
+			what   string
+			certId string
+			dst    **LoadbalancerCertificate
+		}{
+			{"certificate", m.CertificateId, &m.certificate},
+			{"client ca certificate", m.ClientCaCertificateId, &m.clientCaCertificate},
+			{"backend ca certificate", m.BackendCaCertificateId, &m.backendCaCertificate},
+		}
+		for _, j := range joins {
+			if j.certId == "" {
+				continue
+			}
+			subEntry, ok := subEntries[j.certId]
 			if !ok {
-				log.Warningf("loadbalancerlistener id %s: cannot find certificate id %s",
-					m.Id, m.CertificateId)
+				log.Warningf("loadbalancerlistener id %s: cannot find %s id %s",
+					m.Id, j.what, j.certId)
 				correct = false
 				continue
 			}
-			m.certificate = subEntry
+			*j.dst = subEntry
 		}
 	}
 	return correct
@@ -206,11 +221,29 @@ func (lst OrderedLoadbalancerListenerRuleList) Less(i, j int) bool {
 		lpj := len(lst[j].Path)
 		if lpi < lpj {
 			return true
+		} else if lpi == lpj {
+			// rules matching on more conditions are more specific.
+			// Rules alike are ordered by id to keep the generated
+			// config stable
+			lci := listenerRuleConditionCount(lst[i])
+			lcj := listenerRuleConditionCount(lst[j])
+			if lci != lcj {
+				return lci < lcj
+			}
+			return lst[i].Id > lst[j].Id
 		}
 	}
 	return false
 }
 
+func listenerRuleConditionCount(rule *LoadbalancerListenerRule) int {
+	conds, err := computeapi.ParseLoadbalancerListenerRuleConditions(rule.Condition)
+	if err != nil {
+		return 0
+	}
+	return len(conds)
+}
+
 func (lst OrderedLoadbalancerListenerRuleList) Swap(i, j int) {
 	lst[i], lst[j] = lst[j], lst[i]
 }
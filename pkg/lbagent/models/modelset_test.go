@@ -100,3 +100,42 @@ func TestLoadbalancerListenerRules_OrderedEnabledList(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadbalancerListenerRules_OrderedEnabledListConditions(t *testing.T) {
+	set := LoadbalancerListenerRules(map[string]*LoadbalancerListenerRule{
+		"header-method": {
+			LoadbalancerListenerRule: &models.LoadbalancerListenerRule{
+				Path:      "/api",
+				Condition: `[{"field":"http-header","httpHeaderConfig":{"HttpHeaderName":"X-Canary","values":["1"]}},{"field":"http-request-method","httpRequestMethodConfig":{"values":["GET"]}}]`,
+			},
+		},
+		"query-long": {
+			LoadbalancerListenerRule: &models.LoadbalancerListenerRule{
+				Path:      "/api",
+				Condition: `[{"field":"query-string","queryStringConfig":{"values":[{"key":"version","value":"v1"},{"key":"version","value":"v2"},{"key":"version","value":"v3"}]}}]`,
+			},
+		},
+		"source-a": {
+			LoadbalancerListenerRule: &models.LoadbalancerListenerRule{
+				Path:      "/api",
+				Condition: `[{"field":"source-ip","sourceIpConfig":{"values":["10.0.0.0/8"]}}]`,
+			},
+		},
+		"plain": {
+			LoadbalancerListenerRule: &models.LoadbalancerListenerRule{
+				Path: "/api",
+			},
+		},
+	})
+	for id, rule := range set {
+		rule.Id = id
+		rule.Status = "enabled"
+	}
+	want := []string{"header-method", "query-long", "source-a", "plain"}
+	rules := set.OrderedEnabledList()
+	for i, rule := range rules {
+		if rule.Id != want[i] {
+			t.Errorf("rule %d: want %s, got %s", i, want[i], rule.Id)
+		}
+	}
+}
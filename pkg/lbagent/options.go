@@ -44,6 +44,11 @@ type LbagentOptions struct {
 	HaproxyBin    string `default:"haproxy"`
 	GobetweenBin  string `default:"gobetween"`
 	TelegrafBin   string `default:"telegraf"`
+	EnvoyBin      string `default:"envoy"`
+	BirdBin       string `default:"bird"`
+	BirdcBin      string `default:"birdc"`
+
+	BgpHealthCheckInterval int `default:"3" help:"interval in seconds to check data plane health in bgp mode"`
 }
 
 type Options struct {
@@ -57,6 +62,10 @@ func (opts *Options) ValidateThenInit() error {
 		return fmt.Errorf("negative api batch list size: %d",
 			opts.ApiListBatchSize)
 	}
+	if opts.BgpHealthCheckInterval <= 0 {
+		return fmt.Errorf("non-positive bgp health check interval: %d",
+			opts.BgpHealthCheckInterval)
+	}
 	if err := opts.initDirs(); err != nil {
 		return err
 	}
@@ -0,0 +1,60 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"yunion.io/x/onecloud/pkg/apis/compute"
+)
+
+func EnvoyLbPolicy(scheduler string) (policy string, err error) {
+	switch scheduler {
+	case compute.LB_SCHEDULER_RR, compute.LB_SCHEDULER_WRR:
+		policy = "ROUND_ROBIN"
+	case compute.LB_SCHEDULER_WLC:
+		policy = "LEAST_REQUEST"
+	case compute.LB_SCHEDULER_SCH, compute.LB_SCHEDULER_TCH:
+		policy = "RING_HASH"
+	case compute.LB_SCHEDULER_MH:
+		policy = "MAGLEV"
+	default:
+		err = fmt.Errorf("unknown scheduler type %q", scheduler)
+	}
+	return
+}
+
+// EnvoyTlsVersion converts TLSv1.2 into TLSv1_2
+func EnvoyTlsVersion(ver string) string {
+	return strings.Replace(ver, ".", "_", 1)
+}
+
+// EnvoyExpectedStatuses converts http_2xx,http_3xx into ranges of status
+// codes [start, end)
+func EnvoyExpectedStatuses(s string) [][2]int {
+	r := [][2]int{}
+	for _, code := range strings.Split(s, ",") {
+		if !strings.HasPrefix(code, "http_") || !strings.HasSuffix(code, "xx") {
+			continue
+		}
+		var n int
+		if _, err := fmt.Sscanf(code, "http_%dxx", &n); err != nil || n < 1 || n > 5 {
+			continue
+		}
+		r = append(r, [2]int{n * 100, n*100 + 100})
+	}
+	return r
+}
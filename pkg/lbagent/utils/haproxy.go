@@ -57,6 +57,14 @@ func HaproxySslPolicy(policy string) *HaproxySslPolicyParams {
 		r.SslMinVer = "TLSv1.2"
 	case "tls_cipher_policy_1_2_strict":
 		r.SslMinVer = "TLSv1.2"
+		r.Ciphers = strings.Join([]string{
+			"ECDHE-ECDSA-AES128-GCM-SHA256",
+			"ECDHE-RSA-AES128-GCM-SHA256",
+			"ECDHE-ECDSA-AES256-GCM-SHA384",
+			"ECDHE-RSA-AES256-GCM-SHA384",
+			"ECDHE-ECDSA-CHACHA20-POLY1305",
+			"ECDHE-RSA-CHACHA20-POLY1305",
+		}, ":")
 	default:
 		return nil
 	}
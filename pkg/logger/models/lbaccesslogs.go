@@ -0,0 +1,222 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+	"yunion.io/x/sqlchemy/backends/clickhouse"
+
+	api "yunion.io/x/onecloud/pkg/apis/logger"
+	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/rbacutils"
+)
+
+type SLbAccessLogManager struct {
+	db.SModelBaseManager
+}
+
+// 负载均衡监听访问日志，由lbagent批量上报
+type SLbAccessLog struct {
+	db.SModelBase
+
+	Id      int64     `primary:"true" auto_increment:"true" list:"user" clickhouse_partition_by:"toInt64(divide(id,100000000000))"`
+	Created time.Time `nullable:"false" list:"user" index:"true"` // 请求时间
+
+	LoadbalancerId string `width:"36" charset:"ascii" nullable:"false" list:"user" index:"true"` // 负载均衡实例ID
+	ListenerId     string `width:"36" charset:"ascii" nullable:"false" list:"user" index:"true"` // 负载均衡监听ID
+	OwnerTenantId  string `width:"128" charset:"ascii" nullable:"true" list:"user" index:"true"` // 监听所属项目ID
+	OwnerDomainId  string `width:"128" charset:"ascii" nullable:"true" list:"user" index:"true"` // 监听所属域ID
+
+	ClientIp   string `width:"64" charset:"ascii" nullable:"false" list:"user"`
+	ClientPort int    `nullable:"false" list:"user"`
+
+	Method string `width:"16" charset:"ascii" nullable:"true" list:"user"`
+	Host   string `width:"128" charset:"utf8" nullable:"true" list:"user"`
+	Uri    string `charset:"utf8" nullable:"true" list:"user"`
+	Status int    `nullable:"true" list:"user"`
+
+	BytesIn  int64 `nullable:"true" list:"user"`
+	BytesOut int64 `nullable:"true" list:"user"`
+	Duration int64 `nullable:"true" list:"user"` // 请求总耗时，单位毫秒
+
+	BackendGroupId   string `width:"36" charset:"ascii" nullable:"true" list:"user"`
+	BackendId        string `width:"36" charset:"ascii" nullable:"true" list:"user"`
+	TerminationState string `width:"8" charset:"ascii" nullable:"true" list:"user"`
+}
+
+var LbAccessLogManager *SLbAccessLogManager
+
+func InitLbAccessLog() {
+	if consts.OpsLogWithClickhouse {
+		LbAccessLogManager = &SLbAccessLogManager{
+			SModelBaseManager: db.NewModelBaseManagerWithDBName(
+				SLbAccessLog{},
+				"lb_access_log_tbl",
+				"lbaccesslog",
+				"lbaccesslogs",
+				db.ClickhouseDB,
+			),
+		}
+		col := LbAccessLogManager.TableSpec().ColumnSpec("created")
+		if clickCol, ok := col.(clickhouse.IClickhouseColumnSpec); ok {
+			clickCol.SetTTL(consts.SplitableMaxKeepMonths(), "MONTH")
+		}
+	} else {
+		LbAccessLogManager = &SLbAccessLogManager{
+			SModelBaseManager: db.NewModelBaseManagerWithSplitable(
+				SLbAccessLog{},
+				"lb_access_log_tbl",
+				"lbaccesslog",
+				"lbaccesslogs",
+				"id",
+				"created",
+				consts.SplitableMaxDuration(),
+				consts.SplitableMaxKeepMonths(),
+			),
+		}
+	}
+	LbAccessLogManager.SetVirtualObject(LbAccessLogManager)
+}
+
+func (accessLog *SLbAccessLog) prepareUpload(id int64, now time.Time) {
+	if id > 0 {
+		accessLog.Id = id
+	}
+	if accessLog.Created.IsZero() {
+		accessLog.Created = now
+	}
+}
+
+func (accessLog *SLbAccessLog) GetId() string {
+	return fmt.Sprintf("%d", accessLog.Id)
+}
+
+func (accessLog *SLbAccessLog) GetName() string {
+	return accessLog.ListenerId
+}
+
+func (accessLog *SLbAccessLog) GetModelManager() db.IModelManager {
+	return LbAccessLogManager
+}
+
+func (manager *SLbAccessLogManager) ResourceScope() rbacutils.TRbacScope {
+	return rbacutils.ScopeProject
+}
+
+func (manager *SLbAccessLogManager) GetPagingConfig() *db.SPagingConfig {
+	return &db.SPagingConfig{
+		Order:        sqlchemy.SQL_ORDER_DESC,
+		MarkerFields: []string{"id"},
+		DefaultLimit: 20,
+	}
+}
+
+func (manager *SLbAccessLogManager) FilterByOwner(q *sqlchemy.SQuery, ownerId mcclient.IIdentityProvider, scope rbacutils.TRbacScope) *sqlchemy.SQuery {
+	if ownerId != nil {
+		switch scope {
+		case rbacutils.ScopeProject, rbacutils.ScopeUser:
+			if len(ownerId.GetProjectId()) > 0 {
+				q = q.Equals("owner_tenant_id", ownerId.GetProjectId())
+			}
+		case rbacutils.ScopeDomain:
+			if len(ownerId.GetProjectDomainId()) > 0 {
+				q = q.Equals("owner_domain_id", ownerId.GetProjectDomainId())
+			}
+		}
+	}
+	return q
+}
+
+// 负载均衡访问日志列表
+func (manager *SLbAccessLogManager) ListItemFilter(
+	ctx context.Context,
+	q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	query api.LbAccessLogListInput,
+) (*sqlchemy.SQuery, error) {
+	q, err := manager.SModelBaseManager.ListItemFilter(ctx, q, userCred, query.ModelBaseListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SModelBaseManager.ListItemFilter")
+	}
+
+	if !query.Since.IsZero() {
+		q = q.GT("created", query.Since)
+	}
+	if !query.Until.IsZero() {
+		q = q.LE("created", query.Until)
+	}
+	if len(query.LoadbalancerId) == 1 {
+		q = q.Equals("loadbalancer_id", query.LoadbalancerId[0])
+	} else if len(query.LoadbalancerId) > 1 {
+		q = q.In("loadbalancer_id", query.LoadbalancerId)
+	}
+	if len(query.ListenerId) == 1 {
+		q = q.Equals("listener_id", query.ListenerId[0])
+	} else if len(query.ListenerId) > 1 {
+		q = q.In("listener_id", query.ListenerId)
+	}
+	if len(query.ClientIp) == 1 {
+		q = q.Equals("client_ip", query.ClientIp[0])
+	} else if len(query.ClientIp) > 1 {
+		q = q.In("client_ip", query.ClientIp)
+	}
+	if len(query.Status) == 1 {
+		q = q.Equals("status", query.Status[0])
+	} else if len(query.Status) > 1 {
+		q = q.In("status", query.Status)
+	}
+	return q, nil
+}
+
+// 批量上报访问日志，仅供lbagent使用
+func (manager *SLbAccessLogManager) PerformUpload(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	input api.LbAccessLogUploadInput,
+) (jsonutils.JSONObject, error) {
+	records := make([]iUploadRecord, len(input.Logs))
+	for i := range input.Logs {
+		entry := &input.Logs[i]
+		records[i] = &SLbAccessLog{
+			Created:          entry.Timestamp,
+			LoadbalancerId:   entry.LoadbalancerId,
+			ListenerId:       entry.ListenerId,
+			OwnerTenantId:    entry.OwnerTenantId,
+			OwnerDomainId:    entry.OwnerDomainId,
+			ClientIp:         entry.ClientIp,
+			ClientPort:       entry.ClientPort,
+			Method:           entry.Method,
+			Host:             entry.Host,
+			Uri:              entry.Uri,
+			Status:           entry.Status,
+			BytesIn:          entry.BytesIn,
+			BytesOut:         entry.BytesOut,
+			Duration:         entry.Duration,
+			BackendGroupId:   entry.BackendGroupId,
+			BackendId:        entry.BackendId,
+			TerminationState: entry.TerminationState,
+		}
+	}
+	return uploadRecords(ctx, manager, userCred, records)
+}
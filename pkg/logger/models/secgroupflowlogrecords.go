@@ -0,0 +1,218 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+	"yunion.io/x/sqlchemy/backends/clickhouse"
+
+	api "yunion.io/x/onecloud/pkg/apis/logger"
+	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/rbacutils"
+)
+
+type SSecgroupFlowLogRecordManager struct {
+	db.SModelBaseManager
+}
+
+// 安全组流日志记录，由宿主机采样后批量上报
+type SSecgroupFlowLogRecord struct {
+	db.SModelBase
+
+	Id      int64     `primary:"true" auto_increment:"true" list:"user" clickhouse_partition_by:"toInt64(divide(id,100000000000))"`
+	Created time.Time `nullable:"false" list:"user" index:"true"` // 报文时间
+
+	FlowLogId     string `width:"36" charset:"ascii" nullable:"false" list:"user" index:"true"` // 安全组流日志ID
+	OwnerTenantId string `width:"128" charset:"ascii" nullable:"true" list:"user" index:"true"` // 流日志所属项目ID
+	OwnerDomainId string `width:"128" charset:"ascii" nullable:"true" list:"user" index:"true"` // 流日志所属域ID
+
+	VpcId     string `width:"36" charset:"ascii" nullable:"true" list:"user" index:"true"`
+	NetworkId string `width:"36" charset:"ascii" nullable:"true" list:"user" index:"true"`
+	GuestId   string `width:"36" charset:"ascii" nullable:"true" list:"user" index:"true"`
+	HostId    string `width:"36" charset:"ascii" nullable:"true" list:"user"`
+
+	RuleId    string `width:"128" charset:"ascii" nullable:"true" list:"user" index:"true"` // 命中的安全组规则ID
+	Direction string `width:"3" charset:"ascii" nullable:"false" list:"user"`
+	Action    string `width:"5" charset:"ascii" nullable:"false" list:"user"`
+
+	Protocol string `width:"8" charset:"ascii" nullable:"true" list:"user"`
+	SrcIp    string `width:"64" charset:"ascii" nullable:"false" list:"user"`
+	SrcPort  int    `nullable:"true" list:"user"`
+	DstIp    string `width:"64" charset:"ascii" nullable:"false" list:"user"`
+	DstPort  int    `nullable:"true" list:"user"`
+}
+
+var SecgroupFlowLogRecordManager *SSecgroupFlowLogRecordManager
+
+func InitSecgroupFlowLogRecord() {
+	if consts.OpsLogWithClickhouse {
+		SecgroupFlowLogRecordManager = &SSecgroupFlowLogRecordManager{
+			SModelBaseManager: db.NewModelBaseManagerWithDBName(
+				SSecgroupFlowLogRecord{},
+				"secgroup_flow_log_record_tbl",
+				"secgroupflowlogrecord",
+				"secgroupflowlogrecords",
+				db.ClickhouseDB,
+			),
+		}
+		col := SecgroupFlowLogRecordManager.TableSpec().ColumnSpec("created")
+		if clickCol, ok := col.(clickhouse.IClickhouseColumnSpec); ok {
+			clickCol.SetTTL(consts.SplitableMaxKeepMonths(), "MONTH")
+		}
+	} else {
+		SecgroupFlowLogRecordManager = &SSecgroupFlowLogRecordManager{
+			SModelBaseManager: db.NewModelBaseManagerWithSplitable(
+				SSecgroupFlowLogRecord{},
+				"secgroup_flow_log_record_tbl",
+				"secgroupflowlogrecord",
+				"secgroupflowlogrecords",
+				"id",
+				"created",
+				consts.SplitableMaxDuration(),
+				consts.SplitableMaxKeepMonths(),
+			),
+		}
+	}
+	SecgroupFlowLogRecordManager.SetVirtualObject(SecgroupFlowLogRecordManager)
+}
+
+func (record *SSecgroupFlowLogRecord) prepareUpload(id int64, now time.Time) {
+	if id > 0 {
+		record.Id = id
+	}
+	if record.Created.IsZero() {
+		record.Created = now
+	}
+}
+
+func (record *SSecgroupFlowLogRecord) GetId() string {
+	return fmt.Sprintf("%d", record.Id)
+}
+
+func (record *SSecgroupFlowLogRecord) GetName() string {
+	return record.FlowLogId
+}
+
+func (record *SSecgroupFlowLogRecord) GetModelManager() db.IModelManager {
+	return SecgroupFlowLogRecordManager
+}
+
+func (manager *SSecgroupFlowLogRecordManager) ResourceScope() rbacutils.TRbacScope {
+	return rbacutils.ScopeProject
+}
+
+func (manager *SSecgroupFlowLogRecordManager) GetPagingConfig() *db.SPagingConfig {
+	return &db.SPagingConfig{
+		Order:        sqlchemy.SQL_ORDER_DESC,
+		MarkerFields: []string{"id"},
+		DefaultLimit: 20,
+	}
+}
+
+func (manager *SSecgroupFlowLogRecordManager) FilterByOwner(q *sqlchemy.SQuery, ownerId mcclient.IIdentityProvider, scope rbacutils.TRbacScope) *sqlchemy.SQuery {
+	if ownerId != nil {
+		switch scope {
+		case rbacutils.ScopeProject, rbacutils.ScopeUser:
+			if len(ownerId.GetProjectId()) > 0 {
+				q = q.Equals("owner_tenant_id", ownerId.GetProjectId())
+			}
+		case rbacutils.ScopeDomain:
+			if len(ownerId.GetProjectDomainId()) > 0 {
+				q = q.Equals("owner_domain_id", ownerId.GetProjectDomainId())
+			}
+		}
+	}
+	return q
+}
+
+// 安全组流日志记录列表
+func (manager *SSecgroupFlowLogRecordManager) ListItemFilter(
+	ctx context.Context,
+	q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	query api.SecgroupFlowLogRecordListInput,
+) (*sqlchemy.SQuery, error) {
+	q, err := manager.SModelBaseManager.ListItemFilter(ctx, q, userCred, query.ModelBaseListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SModelBaseManager.ListItemFilter")
+	}
+
+	if !query.Since.IsZero() {
+		q = q.GT("created", query.Since)
+	}
+	if !query.Until.IsZero() {
+		q = q.LE("created", query.Until)
+	}
+	for _, filter := range []struct {
+		field  string
+		values []string
+	}{
+		{"flow_log_id", query.FlowLogId},
+		{"vpc_id", query.VpcId},
+		{"network_id", query.NetworkId},
+		{"guest_id", query.GuestId},
+		{"rule_id", query.RuleId},
+		{"action", query.Action},
+		{"src_ip", query.SrcIp},
+		{"dst_ip", query.DstIp},
+	} {
+		if len(filter.values) == 1 {
+			q = q.Equals(filter.field, filter.values[0])
+		} else if len(filter.values) > 1 {
+			q = q.In(filter.field, filter.values)
+		}
+	}
+	return q, nil
+}
+
+// 批量上报安全组流日志，仅供宿主机使用
+func (manager *SSecgroupFlowLogRecordManager) PerformUpload(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	input api.SecgroupFlowLogUploadInput,
+) (jsonutils.JSONObject, error) {
+	records := make([]iUploadRecord, len(input.Logs))
+	for i := range input.Logs {
+		entry := &input.Logs[i]
+		records[i] = &SSecgroupFlowLogRecord{
+			Created:       entry.Timestamp,
+			FlowLogId:     entry.FlowLogId,
+			OwnerTenantId: entry.OwnerTenantId,
+			OwnerDomainId: entry.OwnerDomainId,
+			VpcId:         entry.VpcId,
+			NetworkId:     entry.NetworkId,
+			GuestId:       entry.GuestId,
+			HostId:        entry.HostId,
+			RuleId:        entry.RuleId,
+			Direction:     entry.Direction,
+			Action:        entry.Action,
+			Protocol:      entry.Protocol,
+			SrcIp:         entry.SrcIp,
+			SrcPort:       entry.SrcPort,
+			DstIp:         entry.DstIp,
+			DstPort:       entry.DstPort,
+		}
+	}
+	return uploadRecords(ctx, manager, userCred, records)
+}
@@ -0,0 +1,134 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+// iUploadRecord is a record reported in batch by agents, e.g. lbagent
+type iUploadRecord interface {
+	db.IModel
+
+	// prepareUpload sets id allocated for clickhouse unless id is 0, and the
+	// creation time if it is not reported
+	prepareUpload(id int64, now time.Time)
+}
+
+var (
+	uploadRecordIdLock sync.Mutex
+	uploadRecordIdLast int64
+)
+
+// nextUploadRecordIds reserves count ids of records uploaded into clickhouse,
+// which has no auto increment, and returns the first one.  Ids are timestamps
+// in milliseconds as those of opslog, bumped to be unique within the process
+func nextUploadRecordIds(count int) int64 {
+	uploadRecordIdLock.Lock()
+	defer uploadRecordIdLock.Unlock()
+
+	id := db.CurrentTimestamp(time.Now().UTC())
+	if id <= uploadRecordIdLast {
+		id = uploadRecordIdLast + 1
+	}
+	uploadRecordIdLast = id + int64(count) - 1
+	return id
+}
+
+// uploadRecords inserts records reported by agents in batch.  Agents do not
+// retry, so a batch is accepted unless all of its records fail
+func uploadRecords(ctx context.Context, manager db.IModelManager, userCred mcclient.TokenCredential, records []iUploadRecord) (jsonutils.JSONObject, error) {
+	if !userCred.HasSystemAdminPrivilege() {
+		return nil, httperrors.NewForbiddenError("not enough privilege")
+	}
+	now := time.Now().UTC()
+	var failed int
+	if consts.OpsLogWithClickhouse {
+		failed = batchInsertRecords(manager, records, now)
+	} else {
+		// splitable tables of mysql route every record on its own
+		for _, record := range records {
+			record.prepareUpload(0, now)
+			record.SetModelManager(manager, record)
+			err := manager.TableSpec().Insert(ctx, record)
+			if err != nil {
+				log.Errorf("insert %s: %v", manager.Keyword(), err)
+				failed += 1
+			}
+		}
+	}
+	if failed > 0 && failed == len(records) {
+		return nil, httperrors.NewGeneralError(errors.Errorf("insert all %d %s failed", failed, manager.KeywordPlural()))
+	}
+	ret := jsonutils.NewDict()
+	ret.Set("count", jsonutils.NewInt(int64(len(records)-failed)))
+	ret.Set("failed", jsonutils.NewInt(int64(failed)))
+	return ret, nil
+}
+
+// batchInsertRecords writes records by a single prepared statement within one
+// transaction, which is sent to clickhouse as one block, and returns count of
+// records failed
+func batchInsertRecords(manager db.IModelManager, records []iUploadRecord, now time.Time) int {
+	if len(records) == 0 {
+		return 0
+	}
+	firstId := nextUploadRecordIds(len(records))
+	tableSpec := manager.TableSpec().GetTableSpec()
+	// statements of records differ in columns omitted for zero values
+	sqls := []string{}
+	varsList := map[string][][]interface{}{}
+	failed := 0
+	for i, record := range records {
+		record.prepareUpload(firstId+int64(i), now)
+		record.SetModelManager(manager, record)
+		result, err := tableSpec.InsertSqlPrep(record, false)
+		if err != nil {
+			log.Errorf("prepare insert %s: %v", manager.Keyword(), err)
+			failed += 1
+			continue
+		}
+		if _, ok := varsList[result.Sql]; !ok {
+			sqls = append(sqls, result.Sql)
+		}
+		varsList[result.Sql] = append(varsList[result.Sql], result.Values)
+	}
+	for _, sql := range sqls {
+		results, err := tableSpec.Database().TxBatchExec(sql, varsList[sql])
+		if err != nil {
+			log.Errorf("insert %d %s: %v", len(varsList[sql]), manager.KeywordPlural(), err)
+			failed += len(varsList[sql])
+			continue
+		}
+		for i := range results {
+			if results[i].Error != nil {
+				log.Errorf("insert %s: %v", manager.Keyword(), results[i].Error)
+				failed += 1
+			}
+		}
+	}
+	return failed
+}
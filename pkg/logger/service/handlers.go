@@ -26,6 +26,8 @@ func initHandlers(app *appsrv.Application) {
 
 	models.InitActionLog()
 	models.InitBaremetalEvent()
+	models.InitLbAccessLog()
+	models.InitSecgroupFlowLogRecord()
 
 	for _, manager := range []db.IModelManager{
 		db.UserCacheManager,
@@ -40,6 +42,8 @@ func initHandlers(app *appsrv.Application) {
 
 		models.ActionLog,
 		models.BaremetalEventManager,
+		models.LbAccessLogManager,
+		models.SecgroupFlowLogRecordManager,
 	} {
 		db.RegisterModelManager(manager)
 		handler := db.NewModelHandler(manager)
@@ -27,6 +27,7 @@ import (
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
 	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/pkg/util/stringutils"
 
 	"yunion.io/x/onecloud/pkg/apis"
 	api "yunion.io/x/onecloud/pkg/apis/identity"
@@ -43,6 +44,7 @@ type Client struct {
 	authUrl string
 	timeout int
 	debug   bool
+	retries int
 
 	httpconn        *http.Client
 	_serviceCatalog IServiceCatalog
@@ -152,6 +154,46 @@ func (this *Client) jsonRequest(ctx context.Context, endpoint string, token stri
 	return httputils.JSONRequest(this.httpconn, ctx, method, joinUrl(endpoint, url), getDefaultHeader(header, token), body, this.debug)
 }
 
+// SetRetries sets how many times a POST request is retried on transient errors,
+// all attempts carry the same Idempotency-Key header so that the server executes it at most once
+func (this *Client) SetRetries(retries int) {
+	this.retries = retries
+}
+
+func isRetryableError(err error) bool {
+	jce, ok := errors.Cause(err).(*httputils.JSONClientError)
+	if !ok {
+		return false
+	}
+	switch jce.Code {
+	case 499, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (this *Client) retryJsonRequest(ctx context.Context, endpoint string, token string, method httputils.THttpMethod, url string, header http.Header, body jsonutils.JSONObject) (http.Header, jsonutils.JSONObject, error) {
+	if method != httputils.POST || this.retries <= 0 {
+		return this.jsonRequest(ctx, endpoint, token, method, url, header, body)
+	}
+	if len(header.Get(httputils.IDEMPOTENCY_KEY_HEADER)) == 0 {
+		header.Set(httputils.IDEMPOTENCY_KEY_HEADER, stringutils.UUID4())
+	}
+	for attempt := 0; ; attempt++ {
+		hdr, ret, err := this.jsonRequest(ctx, endpoint, token, method, url, header, body)
+		if err == nil || attempt >= this.retries || !isRetryableError(err) {
+			return hdr, ret, err
+		}
+		backoff := time.Duration(1<<uint(attempt)) * time.Second
+		log.Warningf("%s %s failed: %v, retry in %s", method, url, err, backoff)
+		select {
+		case <-ctx.Done():
+			return hdr, ret, err
+		case <-time.After(backoff):
+		}
+	}
+}
+
 func (this *Client) _authV3(domainName, uname, passwd, projectId, projectName, projectDomain, token string, aCtx SAuthContext) (TokenCredential, error) {
 	input := SAuthenticationInputV3{}
 	if len(uname) > 0 && len(passwd) > 0 { // Password authentication
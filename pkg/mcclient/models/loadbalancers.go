@@ -49,6 +49,7 @@ type LoadbalancerHTTPListener struct {
 
 	XForwardedFor bool
 	Gzip          bool
+	BackendHttp2  bool
 }
 
 // CACertificate string
@@ -56,6 +57,16 @@ type LoadbalancerHTTPSListener struct {
 	CertificateId   string
 	TLSCipherPolicy string
 	EnableHttp2     bool
+
+	TLSMinVersion string
+	TLSCiphers    string
+
+	ClientVerify          string
+	ClientCaCertificateId string
+	ClientCertHeaders     bool
+
+	BackendSsl             bool
+	BackendCaCertificateId string
 }
 
 type LoadbalancerHTTPRateLimiter struct {
@@ -70,6 +81,24 @@ type LoadbalancerHTTPRedirect struct {
 	RedirectHost   string
 	RedirectPath   string
 }
+type LoadbalancerAccessLog struct {
+	AccessLog           bool
+	AccessLogSampleRate int
+}
+type LoadbalancerHTTPRewrite struct {
+	RewriteHost string
+	RewritePath string
+}
+type LoadbalancerHTTPFixedResponse struct {
+	FixedResponseCode        int
+	FixedResponseContentType string
+	FixedResponseBody        string
+}
+type LoadbalancerListenerRuleBackendGroup struct {
+	BackendGroupId string
+	Weight         int
+}
+type LoadbalancerListenerRuleBackendGroups []*LoadbalancerListenerRuleBackendGroup
 type LoadbalancerListener struct {
 	VirtualResource
 	ManagedResource
@@ -119,6 +148,7 @@ type LoadbalancerListener struct {
 
 	LoadbalancerHTTPRateLimiter
 	LoadbalancerHTTPRedirect
+	LoadbalancerAccessLog
 }
 
 type LoadbalancerListenerRule struct {
@@ -130,11 +160,16 @@ type LoadbalancerListenerRule struct {
 	ListenerId     string
 	BackendGroupId string
 
-	Domain string
-	Path   string
+	Domain    string
+	Path      string
+	Condition string
+
+	BackendGroups *LoadbalancerListenerRuleBackendGroups
 
 	LoadbalancerHTTPRateLimiter
 	LoadbalancerHTTPRedirect
+	LoadbalancerHTTPRewrite
+	LoadbalancerHTTPFixedResponse
 }
 
 type LoadbalancerBackendGroup struct {
@@ -196,11 +231,21 @@ type LoadbalancerCertificate struct {
 	NotAfter                time.Time
 	CommonName              string
 	SubjectAlternativeNames string
+
+	AcmeHttpTokens map[string]string
+
+	CertificateType string
 }
 
 type LoadbalancerCluster struct {
 	StandaloneResource
-	ZoneId string
+	ZoneId    string
+	DataPlane string
+
+	HaMode      string
+	BgpLocalAsn int64
+	BgpPeers    string
+	BgpHoldTime int
 }
 
 type LoadbalancerAgent struct {
@@ -251,6 +296,21 @@ type LoadbalancerAgentParamsTelegraf struct {
 	HaproxyInputInterval    int
 }
 
+type LoadbalancerAgentParamsAccessLog struct {
+	Sink          string
+	BatchSize     int
+	FlushInterval int
+
+	SyslogAddr string
+
+	S3Endpoint  string
+	S3AccessKey string
+	S3SecretKey string
+	S3Bucket    string
+	S3Prefix    string
+	S3UseSsl    bool
+}
+
 type LoadbalancerAgentParams struct {
 	KeepalivedConfTmpl string
 	HaproxyConfTmpl    string
@@ -258,6 +318,7 @@ type LoadbalancerAgentParams struct {
 	Vrrp               LoadbalancerAgentParamsVrrp
 	Haproxy            LoadbalancerAgentParamsHaproxy
 	Telegraf           LoadbalancerAgentParamsTelegraf
+	AccessLog          LoadbalancerAgentParamsAccessLog
 }
 
 type LoadbalancerDeployment struct {
@@ -0,0 +1,70 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modulebase
+
+import (
+	"fmt"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+// Bulk applies one operation to the resources selected by input on the
+// server side, the results are reported per resource in the order of input
+func (this *ResourceManager) Bulk(session *mcclient.ClientSession, input *apis.BulkOperationInput) ([]SubmitResult, error) {
+	path := fmt.Sprintf("/%s/bulk", this.ContextPath(nil))
+	resp, err := this._post(session, path, jsonutils.Marshal(input), "")
+	if err != nil {
+		return nil, err
+	}
+	output := apis.BulkOperationOutput{}
+	err = resp.Unmarshal(&output)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unmarshal BulkOperationOutput")
+	}
+	results := make([]SubmitResult, len(output.Results))
+	for i, r := range output.Results {
+		results[i] = SubmitResult{Status: r.Status, Id: r.Id, Data: r.Data}
+	}
+	return results, nil
+}
+
+func (this *ResourceManager) BulkPerformAction(session *mcclient.ClientSession, filter *jsonutils.JSONDict, action string, params jsonutils.JSONObject) ([]SubmitResult, error) {
+	return this.Bulk(session, &apis.BulkOperationInput{
+		Operation: apis.BULK_OPERATION_PERFORM,
+		Action:    action,
+		Filter:    filter,
+		Params:    params,
+	})
+}
+
+func (this *ResourceManager) BulkUpdate(session *mcclient.ClientSession, filter *jsonutils.JSONDict, params jsonutils.JSONObject) ([]SubmitResult, error) {
+	return this.Bulk(session, &apis.BulkOperationInput{
+		Operation: apis.BULK_OPERATION_UPDATE,
+		Filter:    filter,
+		Params:    params,
+	})
+}
+
+func (this *ResourceManager) BulkDelete(session *mcclient.ClientSession, filter *jsonutils.JSONDict, params jsonutils.JSONObject) ([]SubmitResult, error) {
+	return this.Bulk(session, &apis.BulkOperationInput{
+		Operation: apis.BULK_OPERATION_DELETE,
+		Filter:    filter,
+		Params:    params,
+	})
+}
@@ -147,6 +147,17 @@ func (this *ResourceManager) GetByIdInContexts(session *mcclient.ClientSession,
 	return this.filterSingleResult(session, obj, params)
 }
 
+// GetETag returns the entity tag of a resource, which can be used with
+// ClientSession.SetIfMatch to guard subsequent modifications
+func (this *ResourceManager) GetETag(session *mcclient.ClientSession, id string) (string, error) {
+	path := fmt.Sprintf("/%s/%s", this.ContextPath(nil), url.PathEscape(id))
+	hdr, _, err := this.jsonRequest(session, httputils.HEAD, path, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	return hdr.Get(httputils.ETAG_HEADER), nil
+}
+
 func (this *ResourceManager) GetByName(session *mcclient.ClientSession, name string, params jsonutils.JSONObject) (jsonutils.JSONObject, error) {
 	return this.GetByNameInContexts(session, name, params, nil)
 }
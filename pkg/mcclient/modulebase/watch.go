@@ -0,0 +1,210 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modulebase
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+)
+
+const (
+	ErrWatchInterrupted = errors.Error("WatchInterrupted")
+
+	watchMaxEventSize  = 16 * 1024 * 1024
+	waitStatusInterval = 5 * time.Second
+	// watches only see changes written through the api server serving them,
+	// the resource is fetched again at least this often
+	waitStatusWatchRound = time.Minute
+)
+
+// readWatchEvents parses a Server-Sent Events stream and calls callback for
+// each event until callback reports done or the stream ends
+func readWatchEvents(r io.Reader, callback func(event *apis.WatchEvent) (bool, error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), watchMaxEventSize)
+	data := make([]string, 0)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) > 0 {
+			if strings.HasPrefix(line, "data:") {
+				data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			}
+			// id, event and comment lines are carried by the data as well
+			continue
+		}
+		if len(data) == 0 {
+			continue
+		}
+		obj, err := jsonutils.ParseString(strings.Join(data, "\n"))
+		data = data[:0]
+		if err != nil {
+			return errors.Wrap(err, "parse watch event")
+		}
+		event := &apis.WatchEvent{}
+		err = obj.Unmarshal(event)
+		if err != nil {
+			return errors.Wrap(err, "unmarshal watch event")
+		}
+		if event.Type == apis.WATCH_EVENT_ERROR {
+			return errors.Wrap(ErrWatchInterrupted, event.Error)
+		}
+		done, err := callback(event)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// Watch streams the change events of the resources matching params, see
+// apis.WatchEvent. It returns when callback reports done, callback fails or
+// the server closes the stream, the latter after timeout_seconds of params
+func (this *ResourceManager) Watch(session *mcclient.ClientSession, params jsonutils.JSONObject, callback func(event *apis.WatchEvent) (bool, error)) error {
+	query := jsonutils.NewDict()
+	if params != nil {
+		query.Update(params)
+	}
+	query.Set("watch", jsonutils.JSONTrue)
+	path := fmt.Sprintf("/%s?%s", this.ContextPath(nil), query.QueryString())
+	header := http.Header{}
+	header.Set("Accept", "text/event-stream")
+	resp, err := this.rawRequest(session, httputils.GET, path, header, nil)
+	if err != nil || resp.StatusCode >= 300 {
+		_, _, err = session.ParseJSONResponse("", resp, err)
+		return err
+	}
+	defer httputils.CloseResponse(resp)
+	return readWatchEvents(resp.Body, callback)
+}
+
+func checkWaitStatus(obj jsonutils.JSONObject, expect []string, failed []string) (bool, error) {
+	status, _ := obj.GetString("status")
+	if utils.IsInStringArray(status, expect) {
+		return true, nil
+	}
+	if utils.IsInStringArray(status, failed) {
+		id, _ := obj.GetString("id")
+		return false, errors.Errorf("%s enters failed status %s", id, status)
+	}
+	return false, nil
+}
+
+// WaitStatus blocks until the resource reaches one of the expect statuses and
+// returns its details. It fails once the resource enters one of the failed
+// statuses, is deleted or timeout expires. Servers not supporting watch are
+// polled instead, and watches are renewed every waitStatusWatchRound so that
+// changes written through other api servers are not missed
+func (this *ResourceManager) WaitStatus(session *mcclient.ClientSession, id string, expect []string, failed []string, timeout time.Duration) (jsonutils.JSONObject, error) {
+	obj, err := this.Get(session, id, nil)
+	if err != nil {
+		return nil, err
+	}
+	id, _ = obj.GetString("id")
+	deadline := time.Now().Add(timeout)
+
+	var (
+		result  jsonutils.JSONObject
+		version uint64
+		done    bool
+	)
+	for !done && time.Now().Before(deadline) {
+		params := jsonutils.NewDict()
+		params.Set("id", jsonutils.NewString(id))
+		round := time.Until(deadline)
+		if round > waitStatusWatchRound {
+			round = waitStatusWatchRound
+		}
+		params.Set("timeout_seconds", jsonutils.NewInt(int64(round/time.Second)+1))
+		if version > 0 {
+			params.Set("resource_version", jsonutils.NewInt(int64(version)))
+		}
+		err = this.Watch(session, params, func(event *apis.WatchEvent) (bool, error) {
+			if event.ResourceVersion > 0 {
+				version = event.ResourceVersion
+			}
+			switch event.Type {
+			case apis.WATCH_EVENT_SYNC:
+				// events before the watch was established are not replayed
+				result, err = this.GetById(session, id, nil)
+				if err != nil {
+					return false, err
+				}
+			case apis.WATCH_EVENT_CREATE, apis.WATCH_EVENT_UPDATE:
+				result = event.Object
+			case apis.WATCH_EVENT_DELETE:
+				return false, httperrors.NewResourceNotFoundError2(this.Keyword, id)
+			default:
+				return false, nil
+			}
+			done, err = checkWaitStatus(result, expect, failed)
+			return done, err
+		})
+		if err == nil {
+			// start over with a fresh sync event of the resource
+			version = 0
+			continue
+		}
+		if errors.Cause(err) == ErrWatchInterrupted {
+			continue
+		}
+		je, ok := err.(*httputils.JSONClientError)
+		if ok && je.Code == http.StatusGone {
+			version = 0
+			continue
+		}
+		if ok && je.Code == http.StatusNotImplemented {
+			return this.pollStatus(session, id, expect, failed, deadline)
+		}
+		return nil, err
+	}
+	if !done {
+		return nil, httperrors.NewTimeoutError("wait %s %s status %v timeout", this.Keyword, id, expect)
+	}
+	return result, nil
+}
+
+func (this *ResourceManager) pollStatus(session *mcclient.ClientSession, id string, expect []string, failed []string, deadline time.Time) (jsonutils.JSONObject, error) {
+	for time.Now().Before(deadline) {
+		obj, err := this.GetById(session, id, nil)
+		if err != nil {
+			return nil, err
+		}
+		done, err := checkWaitStatus(obj, expect, failed)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			return obj, nil
+		}
+		time.Sleep(waitStatusInterval)
+	}
+	return nil, httperrors.NewTimeoutError("wait %s %s status %v timeout", this.Keyword, id, expect)
+}
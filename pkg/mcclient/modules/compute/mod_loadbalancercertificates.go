@@ -41,6 +41,9 @@ func init() {
 				"not_after",
 				"common_name",
 				"subject_alternative_names",
+				"status",
+				"acme_challenge",
+				"acme_domains",
 			},
 			[]string{"tenant"},
 		),
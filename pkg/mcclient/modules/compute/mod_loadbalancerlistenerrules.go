@@ -39,6 +39,7 @@ func init() {
 				"status",
 				"domain",
 				"path",
+				"condition",
 				"backend_id",
 			},
 			[]string{"tenant"},
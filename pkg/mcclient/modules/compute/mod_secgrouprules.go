@@ -27,7 +27,8 @@ func init() {
 	SecGroupRules = modules.NewComputeManager("secgrouprule", "secgrouprules",
 		[]string{"ID", "Name", "Direction",
 			"Action", "Protocol", "Ports", "Priority",
-			"Cidr", "Secgroup", "Peer_Secgroup_Id", "Peer_Secgroup", "Tenant", "Description"},
+			"Cidr", "Secgroup", "Peer_Secgroup_Id", "Peer_Secgroup", "Tenant", "Description",
+			"Hit_Packets", "Hit_Bytes"},
 		[]string{"SecGroups"})
 
 	modules.RegisterCompute(&SecGroupRules)
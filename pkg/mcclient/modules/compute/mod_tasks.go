@@ -105,3 +105,17 @@ func (this *TasksManager) List(session *mcclient.ClientSession, params jsonutils
 	}
 	return man.List(session, params)
 }
+
+// Cancel drives the task into failed handler of its current stage, service_type in params selects the service of the task
+func (this *TasksManager) Cancel(session *mcclient.ClientSession, taskId string, reason string, params jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	man, err := this.getManager(session, params)
+	if err != nil {
+		return nil, err
+	}
+	body := jsonutils.NewDict()
+	body.Add(jsonutils.NewString("cancel"), "__status__")
+	if len(reason) > 0 {
+		body.Add(jsonutils.NewString(reason), "__reason__")
+	}
+	return man.PerformClassAction(session, taskId, body)
+}
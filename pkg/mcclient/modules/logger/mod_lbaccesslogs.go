@@ -0,0 +1,35 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"yunion.io/x/onecloud/pkg/mcclient/modulebase"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+)
+
+var (
+	LbAccessLogs modulebase.ResourceManager
+)
+
+func init() {
+	LbAccessLogs = modules.NewActionManager("lbaccesslog", "lbaccesslogs",
+		[]string{"id", "created", "loadbalancer_id",
+			"listener_id", "client_ip", "method",
+			"host", "uri", "status", "duration",
+			"backend_id", "termination_state",
+		},
+		[]string{})
+	modules.Register(&LbAccessLogs)
+}
@@ -0,0 +1,38 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"yunion.io/x/onecloud/pkg/mcclient/modulebase"
+	"yunion.io/x/onecloud/pkg/mcclient/modules"
+)
+
+var (
+	Stacks         modulebase.ResourceManager
+	StackResources modulebase.ResourceManager
+)
+
+func init() {
+	Stacks = modules.NewStackManager("stack", "stacks",
+		[]string{"ID", "Name", "Status", "Region", "Drift_Status", "Drift_Checked_At", "Resource_Count", "Project"},
+		[]string{},
+	)
+	StackResources = modules.NewStackManager("stackresource", "stackresources",
+		[]string{"ID", "Name", "Status", "Stack", "Resource_Type", "External_Id", "Drift_Status", "Depends_On"},
+		[]string{},
+	)
+	modules.Register(&Stacks)
+	modules.Register(&StackResources)
+}
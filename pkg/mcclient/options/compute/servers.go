@@ -768,6 +768,12 @@ type ServerMonitorOptions struct {
 	Admin   *bool  `help:"Is this an admin call?"`
 }
 
+type ServerSerialLogOptions struct {
+	ID string `help:"ID or Name of server" json:"-"`
+
+	Tail int64 `help:"Only show the last given bytes of serial log" default:"0"`
+}
+
 type ServerSaveImageOptions struct {
 	ServerIdOptions
 	IMAGE     string `help:"Image name" json:"name"`
@@ -944,6 +950,12 @@ type ServerLiveMigrateOptions struct {
 	SkipCpuCheck    *bool  `help:"Skip check CPU mode of the target host" json:"skip_cpu_check"`
 	SkipKernelCheck *bool  `help:"Skip target kernel version check" json:"skip_kernel_check"`
 	EnableTLS       *bool  `help:"Enable tls migration" json:"enable_tls"`
+
+	MultifdChannels *int   `help:"Parallel migration channels (multifd), 0 to disable multifd" json:"multifd_channels"`
+	Compression     string `help:"Compression method of multifd migration" choices:"zstd" json:"compression"`
+	AutoConverge    *bool  `help:"Throttle guest cpu when dirty page rate is too high" json:"auto_converge" negative:"no_auto_converge"`
+	MaxBandwidthMb  *int64 `help:"Max migration bandwidth in MB/s, 0 means unlimited" json:"max_bandwidth_mb"`
+	DowntimeLimitMs *int64 `help:"Max tolerated downtime in milliseconds" json:"downtime_limit_ms"`
 }
 
 func (o *ServerLiveMigrateOptions) GetId() string {
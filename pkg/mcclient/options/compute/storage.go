@@ -66,7 +66,7 @@ type StorageCreateOptions struct {
 	ZONE                  string `help:"Zone id of storage"`
 	Capacity              int64  `help:"Capacity of the Storage"`
 	MediumType            string `help:"Medium type" choices:"ssd|rotate" default:"ssd"`
-	StorageType           string `help:"Storage type" choices:"local|nas|vsan|rbd|nfs|gpfs|baremetal"`
+	StorageType           string `help:"Storage type" choices:"local|nas|vsan|rbd|nfs|gpfs|baremetal|spdk"`
 	RbdMonHost            string `help:"Ceph mon_host config"`
 	RbdRadosMonOpTimeout  int64  `help:"ceph rados_mon_op_timeout"`
 	RbdRadosOsdOpTimeout  int64  `help:"ceph rados_osd_op_timeout"`
@@ -75,6 +75,9 @@ type StorageCreateOptions struct {
 	RbdPool               string `help:"Ceph Pool Name"`
 	NfsHost               string `help:"NFS host"`
 	NfsSharedDir          string `help:"NFS shared dir"`
+	SpdkLvstore           string `help:"SPDK lvstore name"`
+	SpdkRpcSocket         string `help:"SPDK target json-rpc socket path"`
+	SpdkVhostDir          string `help:"SPDK vhost-user socket dir"`
 }
 
 func (opts *StorageCreateOptions) Params() (jsonutils.JSONObject, error) {
@@ -86,6 +89,10 @@ func (opts *StorageCreateOptions) Params() (jsonutils.JSONObject, error) {
 		if len(opts.NfsHost) == 0 || len(opts.NfsSharedDir) == 0 {
 			return nil, fmt.Errorf("Storage type nfs missing conf host or shared dir")
 		}
+	} else if opts.StorageType == "spdk" {
+		if len(opts.SpdkLvstore) == 0 {
+			return nil, fmt.Errorf("Storage type spdk missing conf lvstore")
+		}
 	}
 	return options.StructToParams(opts)
 }
@@ -0,0 +1,53 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"yunion.io/x/jsonutils"
+)
+
+type DnsTsigKeyListOptions struct {
+	BaseListOptions
+
+	DnsZoneId string   `help:"Filter tsig keys by dns zone"`
+	Algorithm []string `help:"Filter tsig keys by algorithm" choices:"hmac-sha1|hmac-sha256|hmac-sha512"`
+}
+
+func (opts *DnsTsigKeyListOptions) Params() (jsonutils.JSONObject, error) {
+	return ListStructToParams(opts)
+}
+
+type DnsTsigKeyIdOptions struct {
+	ID string `help:"Tsig key Id or Name"`
+}
+
+func (opts *DnsTsigKeyIdOptions) GetId() string {
+	return opts.ID
+}
+
+func (opts *DnsTsigKeyIdOptions) Params() (jsonutils.JSONObject, error) {
+	return nil, nil
+}
+
+type DnsTsigKeyCreateOptions struct {
+	BaseCreateOptions
+	DNS_ZONE_ID string `help:"Dns zone the key is allowed to update and transfer" json:"dns_zone_id"`
+	Algorithm   string `help:"TSIG algorithm" choices:"hmac-sha1|hmac-sha256|hmac-sha512"`
+	Secret      string `help:"Base64 encoded secret, generated if not specified"`
+}
+
+func (opts *DnsTsigKeyCreateOptions) Params() (jsonutils.JSONObject, error) {
+	return jsonutils.Marshal(opts), nil
+}
@@ -49,6 +49,17 @@ type LoadbalancerAgentParamsOptions struct {
 	TelegrafInfluxDbOutputName      string
 	TelegrafInfluxDbOutputUnsafeSsl *bool
 	TelegrafHaproxyInputInterval    int
+
+	AccessLogSink          string `choices:"logger|s3|syslog" help:"where to ship listener access logs"`
+	AccessLogBatchSize     *int   `help:"max number of access log entries shipped in one batch"`
+	AccessLogFlushInterval *int   `help:"max seconds access log entries are held before being shipped"`
+	AccessLogSyslogAddr    string `help:"syslog address, e.g. udp://10.0.0.1:514"`
+	AccessLogS3Endpoint    string
+	AccessLogS3AccessKey   string
+	AccessLogS3SecretKey   string
+	AccessLogS3Bucket      string
+	AccessLogS3Prefix      string
+	AccessLogS3UseSsl      *bool
 }
 
 func (opts *LoadbalancerAgentParamsOptions) setPrefixedParams(params *jsonutils.JSONDict, pref string) {
@@ -75,6 +86,7 @@ func (opts *LoadbalancerAgentParamsOptions) Params() (*jsonutils.JSONDict, error
 	opts.setPrefixedParams(params, "vrrp")
 	opts.setPrefixedParams(params, "haproxy")
 	opts.setPrefixedParams(params, "telegraf")
+	opts.setPrefixedParams(params, "access_log")
 	return params, nil
 }
 
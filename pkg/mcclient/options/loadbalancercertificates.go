@@ -52,8 +52,13 @@ type LoadbalancerCertificateCreateOptions struct {
 
 	NAME string
 
-	Cert string `required:"true" json:"-" help:"path to certificate file"`
-	Pkey string `required:"true" json:"-" help:"path to private key file"`
+	Cert string `json:"-" help:"path to certificate file"`
+	Pkey string `json:"-" help:"path to private key file"`
+
+	CertificateType string `help:"certificate type, ca bundles have no private key" choices:"server|ca"`
+
+	AcmeChallenge string `help:"issue the certificate by ACME instead of uploading one" choices:"http-01|dns-01"`
+	AcmeDomains   string `help:"comma separated domains of the certificate issued by ACME, e.g. www.example.com,*.example.com"`
 }
 
 func (opts *LoadbalancerCertificateCreateOptions) Params() (*jsonutils.JSONDict, error) {
@@ -69,6 +74,23 @@ func (opts *LoadbalancerCertificateCreateOptions) Params() (*jsonutils.JSONDict,
 
 	params.Update(sp)
 
+	if opts.AcmeChallenge != "" {
+		if opts.AcmeDomains == "" {
+			return nil, fmt.Errorf("acme_domains: required by acme challenge")
+		}
+		return params, nil
+	}
+	if opts.CertificateType == "ca" {
+		if opts.Cert == "" {
+			return nil, fmt.Errorf("certificate: empty path")
+		}
+		paramsCa, err := loadbalancerCertificateLoadFiles(opts.Cert, "", true)
+		if err != nil {
+			return nil, err
+		}
+		params.Update(paramsCa)
+		return params, nil
+	}
 	paramsCertKey, err := loadbalancerCertificateLoadFiles(opts.Cert, opts.Pkey, false)
 	if err != nil {
 		return nil, err
@@ -92,7 +114,8 @@ type LoadbalancerCertificateListOptions struct {
 	PublicKeyBitLen    *int
 	SignatureAlgorithm string
 	Cloudregion        string
-	Usable             *bool `help:"List certificates are usable"`
+	Usable             *bool    `help:"List certificates are usable"`
+	CertificateType    []string `help:"List certificates of type" choices:"server|ca"`
 }
 
 type LoadbalancerCertificateUpdateOptions struct {
@@ -121,3 +144,7 @@ type LoadbalancerCertificatePublicOptions struct {
 type LoadbalancerCertificatePrivateOptions struct {
 	ID string `json:"-"`
 }
+
+type LoadbalancerCertificateRenewOptions struct {
+	ID string `json:"-"`
+}
@@ -19,12 +19,26 @@ type LoadbalancerClusterCreateOptions struct {
 
 	Zone string `required:"true"`
 	Wire string
+
+	DataPlane string `choices:"haproxy|envoy" help:"data plane of lbagents in the cluster"`
+
+	HaMode      string `choices:"vrrp|bgp" help:"announce addresses with vrrp or bgp"`
+	BgpLocalAsn *int64 `help:"local as number in bgp mode"`
+	BgpPeers    string `help:"bgp peers in the form of ip:asn,ip:asn"`
+	BgpHoldTime *int   `help:"bgp hold time in seconds"`
 }
 
 type LoadbalancerClusterUpdateOptions struct {
 	ID string `json:"-"`
 
 	Wire string
+
+	DataPlane string `choices:"haproxy|envoy" help:"data plane of lbagents in the cluster"`
+
+	HaMode      string `choices:"vrrp|bgp" help:"announce addresses with vrrp or bgp"`
+	BgpLocalAsn *int64 `help:"local as number in bgp mode"`
+	BgpPeers    string `help:"bgp peers in the form of ip:asn,ip:asn"`
+	BgpHoldTime *int   `help:"bgp hold time in seconds"`
 }
 
 type LoadbalancerClusterListOptions struct {
@@ -32,6 +46,9 @@ type LoadbalancerClusterListOptions struct {
 
 	Zone string
 	Wire string
+
+	DataPlane []string `choices:"haproxy|envoy"`
+	HaMode    []string `choices:"vrrp|bgp"`
 }
 
 type LoadbalancerClusterGetOptions struct {
@@ -14,12 +14,22 @@
 
 package options
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+)
+
 type LoadbalancerListenerRuleCreateOptions struct {
-	NAME         string
-	Listener     string `required:"true"`
-	BackendGroup string
-	Domain       string
-	Path         string
+	NAME               string
+	Listener           string `required:"true"`
+	BackendGroup       string
+	BackendGroupWeight []string `help:"backend group and its weight separated by colon, e.g. canary:10" json:"-"`
+	Domain             string
+	Path               string
+	Condition          string `help:"conditions in json, e.g. [{\"field\":\"http-header\",\"httpHeaderConfig\":{\"HttpHeaderName\":\"X-Canary\",\"values\":[\"yes\"]}}]"`
 
 	HTTPRequestRate       *int
 	HTTPRequestRatePerSrc *int
@@ -29,6 +39,13 @@ type LoadbalancerListenerRuleCreateOptions struct {
 	RedirectScheme *string `json:",allowempty" choices:"http|https|"`
 	RedirectHost   *string `json:",allowempty"`
 	RedirectPath   *string `json:",allowempty"`
+
+	RewriteHost *string `json:",allowempty"`
+	RewritePath *string `json:",allowempty"`
+
+	FixedResponseCode        *int
+	FixedResponseContentType *string `choices:"text/plain|text/css|text/html|application/javascript|application/json"`
+	FixedResponseBody        *string `json:",allowempty"`
 }
 
 type LoadbalancerListenerRuleListOptions struct {
@@ -50,7 +67,9 @@ type LoadbalancerListenerRuleUpdateOptions struct {
 	ID   string `json:"-"`
 	Name string
 
-	BackendGroup string
+	BackendGroup            string
+	BackendGroupWeight      []string `help:"backend group and its weight separated by colon, e.g. canary:10" json:"-"`
+	ClearBackendGroupWeight bool     `help:"forward to backend group only, no weighted backend groups" json:"-"`
 
 	HTTPRequestRate       *int
 	HTTPRequestRatePerSrc *int
@@ -60,6 +79,13 @@ type LoadbalancerListenerRuleUpdateOptions struct {
 	RedirectScheme *string `choices:"http|https|" json:",allowempty"`
 	RedirectHost   *string `json:",allowempty"`
 	RedirectPath   *string `json:",allowempty"`
+
+	RewriteHost *string `json:",allowempty"`
+	RewritePath *string `json:",allowempty"`
+
+	FixedResponseCode        *int
+	FixedResponseContentType *string `choices:"text/plain|text/css|text/html|application/javascript|application/json"`
+	FixedResponseBody        *string `json:",allowempty"`
 }
 
 type LoadbalancerListenerRuleGetOptions struct {
@@ -78,3 +104,54 @@ type LoadbalancerListenerRuleActionStatusOptions struct {
 	ID     string `json:"-"`
 	Status string `choices:"enabled|disabled"`
 }
+
+func newLoadbalancerListenerRuleBackendGroups(ss []string) (jsonutils.JSONObject, error) {
+	lbrbgs := jsonutils.NewArray()
+	for _, s := range ss {
+		i := strings.LastIndex(s, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid backend group weight %q, want <backend_group>:<weight>", s)
+		}
+		weight, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight of %q: %v", s, err)
+		}
+		lbrbg := jsonutils.NewDict()
+		lbrbg.Set("backend_group_id", jsonutils.NewString(s[:i]))
+		lbrbg.Set("weight", jsonutils.NewInt(int64(weight)))
+		lbrbgs.Add(lbrbg)
+	}
+	return lbrbgs, nil
+}
+
+func (opts *LoadbalancerListenerRuleCreateOptions) Params() (*jsonutils.JSONDict, error) {
+	params, err := optionsStructToParams(opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.BackendGroupWeight) > 0 {
+		lbrbgs, err := newLoadbalancerListenerRuleBackendGroups(opts.BackendGroupWeight)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("backend_groups", lbrbgs)
+	}
+	return params, nil
+}
+
+func (opts *LoadbalancerListenerRuleUpdateOptions) Params() (*jsonutils.JSONDict, error) {
+	params, err := optionsStructToParams(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ClearBackendGroupWeight {
+		params.Set("backend_groups", jsonutils.NewArray())
+	} else if len(opts.BackendGroupWeight) > 0 {
+		lbrbgs, err := newLoadbalancerListenerRuleBackendGroups(opts.BackendGroupWeight)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("backend_groups", lbrbgs)
+	}
+	return params, nil
+}
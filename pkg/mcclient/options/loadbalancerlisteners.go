@@ -60,10 +60,20 @@ type LoadbalancerListenerCreateOptions struct {
 
 	XForwardedFor string `choices:"true|false"`
 	Gzip          string `choices:"true|false"`
+	BackendHttp2  string `choices:"true|false" help:"talk to backends with http/2, e.g. grpc services"`
 
 	Certificate     string
 	TLSCipherPolicy string
 	EnableHttp2     string `choices:"true|false"`
+	TLSMinVersion   string `choices:"TLSv1.0|TLSv1.1|TLSv1.2|TLSv1.3"`
+	TLSCiphers      string `help:"colon separated cipher suites, e.g. ECDHE-RSA-AES128-GCM-SHA256:ECDHE-RSA-AES256-GCM-SHA384"`
+
+	ClientVerify        string `choices:"off|optional|required"`
+	ClientCaCertificate string `help:"ca certificate to verify client certificates"`
+	ClientCertHeaders   string `choices:"true|false" help:"pass client certificate info to backends in X-SSL-Client-* headers"`
+
+	BackendSsl           string `choices:"true|false" help:"connect to backends with ssl"`
+	BackendCaCertificate string `help:"ca certificate to verify backend server certificates"`
 
 	HTTPRequestRate       *int
 	HTTPRequestRatePerSrc *int
@@ -73,6 +83,9 @@ type LoadbalancerListenerCreateOptions struct {
 	RedirectScheme *string `choices:"http|https|" json:",allowempty"`
 	RedirectHost   *string `json:",allowempty"`
 	RedirectPath   *string `json:",allowempty"`
+
+	AccessLog           string `choices:"true|false"`
+	AccessLogSampleRate *int   `help:"percentage of requests to log, 1-100"`
 }
 
 type LoadbalancerListenerListOptions struct {
@@ -174,10 +187,20 @@ type LoadbalancerListenerUpdateOptions struct {
 
 	XForwardedFor string `choices:"true|false"`
 	Gzip          string `choices:"true|false"`
+	BackendHttp2  string `choices:"true|false" help:"talk to backends with http/2, e.g. grpc services"`
 
 	Certificate     string
 	TLSCipherPolicy string
 	EnableHttp2     string `choices:"true|false"`
+	TLSMinVersion   string `choices:"TLSv1.0|TLSv1.1|TLSv1.2|TLSv1.3"`
+	TLSCiphers      string `help:"colon separated cipher suites, e.g. ECDHE-RSA-AES128-GCM-SHA256:ECDHE-RSA-AES256-GCM-SHA384"`
+
+	ClientVerify        string `choices:"off|optional|required"`
+	ClientCaCertificate string `help:"ca certificate to verify client certificates"`
+	ClientCertHeaders   string `choices:"true|false" help:"pass client certificate info to backends in X-SSL-Client-* headers"`
+
+	BackendSsl           string `choices:"true|false" help:"connect to backends with ssl"`
+	BackendCaCertificate string `help:"ca certificate to verify backend server certificates"`
 
 	HTTPRequestRate       *int
 	HTTPRequestRatePerSrc *int
@@ -187,6 +210,9 @@ type LoadbalancerListenerUpdateOptions struct {
 	RedirectScheme *string `choices:"http|https|" json:",allowempty"`
 	RedirectHost   *string `json:",allowempty"`
 	RedirectPath   *string `json:",allowempty"`
+
+	AccessLog           string `choices:"true|false"`
+	AccessLogSampleRate *int   `help:"percentage of requests to log, 1-100"`
 }
 
 type LoadbalancerListenerGetOptions struct {
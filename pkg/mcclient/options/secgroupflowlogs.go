@@ -0,0 +1,81 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"yunion.io/x/jsonutils"
+)
+
+type SecgroupFlowLogListOptions struct {
+	BaseListOptions
+
+	Vpc         string   `help:"Filter flow logs by vpc" json:"vpc_id"`
+	Network     string   `help:"Filter flow logs by network" json:"network_id"`
+	TrafficType []string `help:"Filter flow logs by traffic type" choices:"all|allow|deny"`
+	Sink        []string `help:"Filter flow logs by sink" choices:"logger|bucket"`
+}
+
+func (opts *SecgroupFlowLogListOptions) Params() (jsonutils.JSONObject, error) {
+	return ListStructToParams(opts)
+}
+
+type SecgroupFlowLogIdOptions struct {
+	ID string `help:"ID or Name of secgroup flow log"`
+}
+
+func (opts *SecgroupFlowLogIdOptions) GetId() string {
+	return opts.ID
+}
+
+func (opts *SecgroupFlowLogIdOptions) Params() (jsonutils.JSONObject, error) {
+	return nil, nil
+}
+
+type SecgroupFlowLogCreateOptions struct {
+	BaseCreateOptions
+
+	Vpc         string `help:"Log traffic of all networks in the vpc" json:"vpc_id"`
+	Network     string `help:"Log traffic of the network only" json:"network_id"`
+	TrafficType string `help:"Traffic to log" choices:"all|allow|deny"`
+	SampleRate  int    `help:"Max packets logged per second on each host"`
+	Sink        string `help:"Where flow logs are shipped to" choices:"logger|bucket"`
+	Bucket      string `help:"Bucket to ship flow logs to when sink is bucket" json:"bucket_id"`
+	Prefix      string `help:"Object key prefix in the bucket"`
+}
+
+func (opts *SecgroupFlowLogCreateOptions) Params() (jsonutils.JSONObject, error) {
+	return jsonutils.Marshal(opts), nil
+}
+
+type SecgroupFlowLogUpdateOptions struct {
+	BaseUpdateOptions
+
+	TrafficType string  `help:"Traffic to log" choices:"all|allow|deny"`
+	SampleRate  *int    `help:"Max packets logged per second on each host"`
+	Sink        string  `help:"Where flow logs are shipped to" choices:"logger|bucket"`
+	Bucket      string  `help:"Bucket to ship flow logs to when sink is bucket" json:"bucket_id"`
+	Prefix      *string `help:"Object key prefix in the bucket"`
+}
+
+func (opts *SecgroupFlowLogUpdateOptions) Params() (jsonutils.JSONObject, error) {
+	params, err := opts.BaseUpdateOptions.Params()
+	if err != nil {
+		return nil, err
+	}
+	dict := params.(*jsonutils.JSONDict)
+	dict.Update(jsonutils.Marshal(opts))
+	dict.Remove("id")
+	return dict, nil
+}
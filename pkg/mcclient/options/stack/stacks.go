@@ -0,0 +1,127 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/mcclient/options"
+)
+
+type StackListOptions struct {
+	options.BaseListOptions
+
+	DriftStatus []string `help:"filter by drift status" choices:"in_sync|drifted|deleted|unknown"`
+}
+
+func (opts *StackListOptions) Params() (jsonutils.JSONObject, error) {
+	return options.ListStructToParams(opts)
+}
+
+type StackIdOptions struct {
+	ID string `help:"ID or name of stack" json:"-"`
+}
+
+func (opts *StackIdOptions) GetId() string {
+	return opts.ID
+}
+
+func (opts *StackIdOptions) Params() (jsonutils.JSONObject, error) {
+	return nil, nil
+}
+
+type StackTemplateOptions struct {
+	Template string   `help:"path to template file in YAML or JSON format" json:"-"`
+	Param    []string `help:"template parameter in format of <name>=<value>" json:"-"`
+}
+
+func (opts *StackTemplateOptions) params() (*jsonutils.JSONDict, error) {
+	params := jsonutils.NewDict()
+	if len(opts.Template) > 0 {
+		content, err := ioutil.ReadFile(opts.Template)
+		if err != nil {
+			return nil, fmt.Errorf("read template %s: %s", opts.Template, err)
+		}
+		params.Set("template", jsonutils.NewString(string(content)))
+	}
+	if len(opts.Param) > 0 {
+		values := jsonutils.NewDict()
+		for _, param := range opts.Param {
+			pos := strings.Index(param, "=")
+			if pos <= 0 {
+				return nil, fmt.Errorf("invalid parameter %s, expect <name>=<value>", param)
+			}
+			values.Set(param[:pos], jsonutils.NewString(param[pos+1:]))
+		}
+		params.Set("parameters", values)
+	}
+	return params, nil
+}
+
+type StackCreateOptions struct {
+	options.BaseCreateOptions
+	StackTemplateOptions
+
+	Region string `help:"region of the resources in stack"`
+}
+
+func (opts *StackCreateOptions) Params() (jsonutils.JSONObject, error) {
+	if len(opts.Template) == 0 {
+		return nil, fmt.Errorf("template is required")
+	}
+	params, err := opts.StackTemplateOptions.params()
+	if err != nil {
+		return nil, err
+	}
+	params.Update(jsonutils.Marshal(opts))
+	return params, nil
+}
+
+type StackChangeOptions struct {
+	StackIdOptions
+	StackTemplateOptions
+}
+
+func (opts *StackChangeOptions) Params() (jsonutils.JSONObject, error) {
+	return opts.StackTemplateOptions.params()
+}
+
+type StackResourceListOptions struct {
+	options.BaseListOptions
+
+	Stack        string   `help:"ID or name of stack"`
+	ResourceType []string `help:"filter by resource type, e.g. servers"`
+	DriftStatus  []string `help:"filter by drift status" choices:"in_sync|drifted|deleted|unknown"`
+}
+
+func (opts *StackResourceListOptions) Params() (jsonutils.JSONObject, error) {
+	return options.ListStructToParams(opts)
+}
+
+type StackResourceIdOptions struct {
+	ID string `help:"ID of stack resource" json:"-"`
+}
+
+func (opts *StackResourceIdOptions) GetId() string {
+	return opts.ID
+}
+
+func (opts *StackResourceIdOptions) Params() (jsonutils.JSONObject, error) {
+	return nil, nil
+}
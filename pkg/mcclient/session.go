@@ -255,7 +255,7 @@ func (this *ClientSession) JSONVersionRequest(
 	if this.ctx == nil {
 		ctx = context.Background()
 	}
-	return this.client.jsonRequest(ctx, baseUrl,
+	return this.client.retryJsonRequest(ctx, baseUrl,
 		this.token.GetTokenString(),
 		method, url, tmpHeader, body)
 }
@@ -320,6 +320,16 @@ func (this *ClientSession) RemoveTaskNotifyUrl() {
 	this.Header.Del(TASK_NOTIFY_URL)
 }
 
+// SetIfMatch makes the following update and perform requests of this session
+// conditional on the resource still matching etag
+func (this *ClientSession) SetIfMatch(etag string) {
+	this.Header.Set(httputils.IF_MATCH_HEADER, etag)
+}
+
+func (this *ClientSession) RemoveIfMatch() {
+	this.Header.Del(httputils.IF_MATCH_HEADER)
+}
+
 func (this *ClientSession) SetServiceUrl(service, url string) {
 	this.customizeServiceUrl[service] = url
 }
@@ -199,6 +199,7 @@ func (sm *STopicManager) InitializeData() error {
 				notify.TOPIC_RESOURCE_LOADBALANCER,
 				notify.TOPIC_RESOURCE_DBINSTANCE,
 				notify.TOPIC_RESOURCE_ELASTICCACHE,
+				notify.TOPIC_RESOURCE_LOADBALANCERCERTIFICATE,
 			)
 			t.addAction(notify.ActionExpiredRelease)
 			t.Type = notify.TOPIC_TYPE_RESOURCE
@@ -211,6 +212,7 @@ func (sm *STopicManager) InitializeData() error {
 				notify.TOPIC_RESOURCE_LOADBALANCER,
 				notify.TOPIC_RESOURCE_DBINSTANCE,
 				notify.TOPIC_RESOURCE_ELASTICCACHE,
+				notify.TOPIC_RESOURCE_LOADBALANCERCERTIFICATE,
 			)
 			t.addAction(notify.ActionExpiredRelease)
 			t.Type = notify.TOPIC_TYPE_RESOURCE
@@ -221,6 +223,7 @@ func (sm *STopicManager) InitializeData() error {
 				notify.TOPIC_RESOURCE_LOADBALANCER,
 				notify.TOPIC_RESOURCE_DBINSTANCE,
 				notify.TOPIC_RESOURCE_ELASTICCACHE,
+				notify.TOPIC_RESOURCE_LOADBALANCERCERTIFICATE,
 			)
 			t.addAction(notify.ActionExpiredRelease)
 			t.Type = notify.TOPIC_TYPE_RESOURCE
@@ -244,9 +247,11 @@ func (sm *STopicManager) InitializeData() error {
 				notify.TOPIC_RESOURCE_LOADBALANCER,
 				notify.TOPIC_RESOURCE_DBINSTANCE,
 				notify.TOPIC_RESOURCE_ELASTICCACHE,
+				notify.TOPIC_RESOURCE_LOADBALANCERCERTIFICATE,
 			)
 			t.addAction(
 				notify.ActionCreate,
+				notify.ActionUpdate,
 				notify.ActionSyncStatus,
 				notify.ActionRebuildRoot,
 				notify.ActionChangeConfig,
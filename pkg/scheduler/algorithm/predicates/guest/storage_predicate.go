@@ -21,6 +21,7 @@ import (
 	"yunion.io/x/pkg/tristate"
 	"yunion.io/x/pkg/utils"
 
+	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/scheduler/algorithm/predicates"
 	"yunion.io/x/onecloud/pkg/scheduler/core"
 )
@@ -125,6 +126,18 @@ func (p *StoragePredicate) Execute(u *core.Unit, c core.Candidater) (bool, []cor
 		return strings.Join(ss, " + ")
 	}
 
+	// disk on spdk storage is a logical volume of one lvstore, so it could not span storages
+	getMaxStorageFree := func(backend string) int64 {
+		maxFree := int64(0)
+		for _, s := range getter.Storages() {
+			if s.StorageType == backend {
+				free := utils.Min(s.GetFreeCapacity(), s.Capacity-s.ActualCapacityUsed)
+				maxFree = utils.Max(maxFree, free)
+			}
+		}
+		return maxFree
+	}
+
 	sizeRequest := make(map[string]map[string]int64, 0)
 	storeRequest := make(map[string]int64, 0)
 	for _, disk := range d.Disks {
@@ -175,6 +188,12 @@ func (p *StoragePredicate) Execute(u *core.Unit, c core.Candidater) (bool, []cor
 		} else if actualCapacity.capacity <= 0 {
 			appendFailMsg(be, req, useRsvd, actualCapacity)
 		}
+		if be == computeapi.STORAGE_SPDK && tmpCap > 0 {
+			if maxFree := getMaxStorageFree(be); maxFree < req["max"] {
+				h.AppendPredicateFailMsg(fmt.Sprintf("no %q storage could hold disk of size %d, max_free=%d", be, req["max"], maxFree))
+				tmpCap = 0
+			}
+		}
 		minCapacity = utils.Min(minCapacity, tmpCap)
 	}
 
@@ -17,6 +17,8 @@ package predicates
 import (
 	"fmt"
 
+	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/scheduler/api"
 	"yunion.io/x/onecloud/pkg/scheduler/core"
 )
 
@@ -36,12 +38,23 @@ func (f *IsolatedDevicePredicate) Clone() core.FitPredicate {
 
 func (f *IsolatedDevicePredicate) PreExecute(u *core.Unit, cs []core.Candidater) (bool, error) {
 	data := u.SchedData()
-	if len(data.IsolatedDevices) == 0 {
+	if len(data.IsolatedDevices) == 0 && getSRIOVNicRequestCount(data) == 0 {
 		return false, nil
 	}
 	return true, nil
 }
 
+// getSRIOVNicRequestCount returns the count of networks backed by SR-IOV virtual functions
+func getSRIOVNicRequestCount(data *api.SchedInfo) int {
+	count := 0
+	for _, net := range data.Networks {
+		if net.Driver == computeapi.NETWORK_DRIVER_VFIO {
+			count += 1
+		}
+	}
+	return count
+}
+
 func (f *IsolatedDevicePredicate) Execute(u *core.Unit, c core.Candidater) (bool, []core.PredicateFailureReason, error) {
 	h := NewPredicateHelper(f, u, c)
 	reqIsoDevs := u.SchedData().IsolatedDevices
@@ -65,7 +78,10 @@ func (f *IsolatedDevicePredicate) Execute(u *core.Unit, c core.Candidater) (bool
 		minCapacity = 1
 	}
 
-	reqCount := len(reqIsoDevs)
+	// virtual functions requested by networks
+	sriovNicReqCount := getSRIOVNicRequestCount(u.SchedData())
+
+	reqCount := len(reqIsoDevs) + sriovNicReqCount
 	freeCount := len(getter.UnusedIsolatedDevices()) - getter.GetPendingUsage().IsolatedDevice
 	totalCount := len(getter.GetIsolatedDevices())
 
@@ -85,6 +101,9 @@ func (f *IsolatedDevicePredicate) Execute(u *core.Unit, c core.Candidater) (bool
 			devTypeRequest[dev.DevType] += 1
 		}
 	}
+	if sriovNicReqCount > 0 {
+		devTypeRequest[computeapi.NIC_TYPE] += sriovNicReqCount
+	}
 	for devType, reqCount := range devTypeRequest {
 		freeCount := len(getter.UnusedIsolatedDevicesByType(devType))
 		if freeCount < reqCount {
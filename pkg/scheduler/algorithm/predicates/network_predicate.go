@@ -239,6 +239,21 @@ func IsNetworkAvailable(
 
 	}
 
+	if req.Driver == computeapi.NETWORK_DRIVER_VFIO {
+		if n.Provider == computeapi.CLOUD_PROVIDER_ONECLOUD || n.Provider == computeapi.CLOUD_PROVIDER_CLOUDPODS {
+			return FailReason{
+				Reason: fmt.Sprintf("Network %s of vpc not support SR-IOV virtual function", n.Name),
+				Type:   NetworkTypeMatch,
+			}
+		}
+		if freeVfs := getUnusedSRIOVNicCountOfWire(c, n.WireId); freeVfs <= 0 {
+			return FailReason{
+				Reason: fmt.Sprintf("Network %s wire %s has no free SR-IOV virtual function", n.Name, n.WireId),
+				Type:   NetworkWire,
+			}
+		}
+	}
+
 	if req.Network == "" && n.IsAutoAlloc.IsFalse() {
 		return FailReason{Reason: fmt.Sprintf("Network %s is not auto alloc", n.Name), Type: NetworkPrivate}
 	}
@@ -264,6 +279,16 @@ func IsNetworkAvailable(
 	return nil
 }
 
+func getUnusedSRIOVNicCountOfWire(c core.Candidater, wireId string) int {
+	count := 0
+	for _, dev := range c.Getter().UnusedIsolatedDevicesByType(computeapi.NIC_TYPE) {
+		if dev.WireId == wireId {
+			count += 1
+		}
+	}
+	return count
+}
+
 func (p *NetworkPredicate) GetNetworkTypes(u *core.Unit, specifyType string) []string {
 	netTypes := p.GetHypervisorDriver(u).GetRandomNetworkTypes()
 	if len(specifyType) > 0 {
@@ -426,7 +426,7 @@ func (h *BaseHostDesc) UnusedIsolatedDevicesByVendorModel(vendorModel string) []
 	ret := make([]*core.IsolatedDeviceDesc, 0)
 	vm := core.NewVendorModelByStr(vendorModel)
 	for _, dev := range h.UnusedIsolatedDevices() {
-		if dev.GetVendorModel().IsMatch(vm) {
+		if dev.GetVendorModel().IsMatch(vm) || dev.IsMdevTypeMatch(vm) {
 			ret = append(ret, dev)
 		}
 	}
@@ -482,6 +482,8 @@ func (h *BaseHostDesc) fillIsolatedDevices(b *baseBuilder, host *computemodels.S
 			Model:          devModel.Model,
 			Addr:           devModel.Addr,
 			VendorDeviceID: devModel.VendorDeviceId,
+			WireId:         devModel.WireId,
+			MdevType:       devModel.MdevType,
 		}
 		devs[index] = dev
 	}
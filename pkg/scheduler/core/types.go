@@ -241,6 +241,8 @@ type IsolatedDeviceDesc struct {
 	Model          string
 	Addr           string
 	VendorDeviceID string
+	WireId         string
+	MdevType       string
 }
 
 func (i *IsolatedDeviceDesc) VendorID() string {
@@ -254,6 +256,14 @@ func (i *IsolatedDeviceDesc) GetVendorModel() *VendorModel {
 	}
 }
 
+// IsMdevTypeMatch check if mediated device is requested by mdev type, e.g. nvidia-63
+func (i *IsolatedDeviceDesc) IsMdevTypeMatch(target *VendorModel) bool {
+	if len(i.MdevType) == 0 || i.MdevType != target.Model {
+		return false
+	}
+	return (&VendorModel{Vendor: i.VendorID(), Model: i.MdevType}).IsMatch(target)
+}
+
 type VendorModel struct {
 	Vendor string
 	Model  string
@@ -0,0 +1,269 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/stack"
+)
+
+const (
+	ErrRollbackFailed = errors.Error("RollbackFailed")
+
+	DEFAULT_WAIT_TIMEOUT = 30 * time.Minute
+)
+
+type SApplier struct {
+	Client IResourceClient
+	// WaitTimeout limits the time waiting for a single resource
+	WaitTimeout time.Duration
+}
+
+type SApplyResult struct {
+	// State holds the resources existing after apply, which is valid even
+	// if apply failed
+	State   map[string]*SResourceState
+	Outputs *jsonutils.JSONDict
+}
+
+type sJournalEntry struct {
+	action string
+	state  *SResourceState
+	// replaced is the resource of the same name replaced by the created one
+	replaced *SResourceState
+	// previous holds the properties before update
+	previous *jsonutils.JSONDict
+	changed  []string
+}
+
+func NewApplier(client IResourceClient) *SApplier {
+	return &SApplier{
+		Client:      client,
+		WaitTimeout: DEFAULT_WAIT_TIMEOUT,
+	}
+}
+
+func (a *SApplier) wait(ctx context.Context, resType string, id string, waitStatus []string) (jsonutils.JSONObject, error) {
+	status, ok := defaultWaitStatus[resType]
+	if len(waitStatus) > 0 {
+		status.expect = waitStatus
+	} else if !ok {
+		return a.Client.Get(ctx, resType, id)
+	}
+	obj, err := a.Client.WaitStatus(ctx, resType, id, status.expect, status.failed, a.WaitTimeout)
+	if err != nil {
+		return nil, errors.Wrapf(err, "wait %s %s status %v", resType, id, status.expect)
+	}
+	return obj, nil
+}
+
+func (a *SApplier) delete(ctx context.Context, st *SResourceState) error {
+	err := a.Client.Delete(ctx, st.Type, st.ExternalId)
+	if err != nil {
+		return errors.Wrapf(err, "delete %s %s(%s)", st.Type, st.Name, st.ExternalId)
+	}
+	return a.Client.WaitDeleted(ctx, st.Type, st.ExternalId, a.WaitTimeout)
+}
+
+func (a *SApplier) applyResource(ctx context.Context, scope *sScope, name string, res api.StackTemplateResource, result *SApplyResult, journal *[]sJournalEntry) error {
+	props, computed, err := scope.resolveProperties(res.Properties)
+	if err != nil {
+		return err
+	}
+	if len(computed) > 0 {
+		return errors.Errorf("unresolved properties %v", computed)
+	}
+	deps := ResourceDependencies(res)
+	st := result.State[name]
+	if st == nil || st.Type != res.Type {
+		obj, err := a.Client.Create(ctx, res.Type, props)
+		if err != nil {
+			return errors.Wrapf(err, "create %s", res.Type)
+		}
+		id, _ := obj.GetString("id")
+		created := &SResourceState{
+			Name:       name,
+			Type:       res.Type,
+			ExternalId: id,
+			Properties: props,
+			DependsOn:  deps,
+			Attributes: obj,
+		}
+		*journal = append(*journal, sJournalEntry{action: api.STACK_CHANGE_ACTION_CREATE, state: created, replaced: st})
+		result.State[name] = created
+		obj, err = a.wait(ctx, res.Type, id, res.WaitStatus)
+		if err != nil {
+			return err
+		}
+		created.Attributes = obj
+		return nil
+	}
+	changes := diffProperties(st.Properties, props, nil)
+	if len(changes) == 0 {
+		obj, err := a.Client.Get(ctx, st.Type, st.ExternalId)
+		if err != nil {
+			return errors.Wrapf(err, "get %s %s", st.Type, st.ExternalId)
+		}
+		st.DependsOn = deps
+		st.Attributes = obj
+		return nil
+	}
+	update := jsonutils.NewDict()
+	changed := make([]string, len(changes))
+	for i := range changes {
+		changed[i] = changes[i].Key
+		update.Set(changes[i].Key, changes[i].After)
+	}
+	_, err = a.Client.Update(ctx, st.Type, st.ExternalId, update)
+	if err != nil {
+		return errors.Wrapf(err, "update %s %s", st.Type, st.ExternalId)
+	}
+	*journal = append(*journal, sJournalEntry{action: api.STACK_CHANGE_ACTION_UPDATE, state: st, previous: st.Properties, changed: changed})
+	st.Properties = props
+	st.DependsOn = deps
+	obj, err := a.wait(ctx, st.Type, st.ExternalId, res.WaitStatus)
+	if err != nil {
+		return err
+	}
+	st.Attributes = obj
+	return nil
+}
+
+// rollback undoes the journal in reverse order, created resources are
+// deleted and updated properties are restored
+func (a *SApplier) rollback(ctx context.Context, result *SApplyResult, journal []sJournalEntry) error {
+	errs := []error{}
+	for i := len(journal) - 1; i >= 0; i-- {
+		entry := journal[i]
+		st := entry.state
+		switch entry.action {
+		case api.STACK_CHANGE_ACTION_CREATE:
+			err := a.delete(ctx, st)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if entry.replaced != nil {
+				result.State[st.Name] = entry.replaced
+			} else {
+				delete(result.State, st.Name)
+			}
+		case api.STACK_CHANGE_ACTION_UPDATE:
+			restore := jsonutils.NewDict()
+			props := st.Properties.Copy()
+			for _, key := range entry.changed {
+				if val, _ := entry.previous.Get(key); val != nil {
+					restore.Set(key, val)
+					props.Set(key, val)
+				}
+			}
+			if restore.Length() == 0 {
+				continue
+			}
+			_, err := a.Client.Update(ctx, st.Type, st.ExternalId, restore)
+			if err != nil {
+				errs = append(errs, errors.Wrapf(err, "restore %s %s(%s)", st.Type, st.Name, st.ExternalId))
+				continue
+			}
+			st.Properties = props
+		}
+	}
+	return errors.NewAggregate(errs)
+}
+
+// Apply creates, updates and deletes resources to make the state match the
+// template. Creations and updates are done in dependency order and rolled
+// back once any of them fails, resources removed from template are deleted
+// at last in reverse dependency order
+func (a *SApplier) Apply(ctx context.Context, tmpl *api.StackTemplate, params *jsonutils.JSONDict, state map[string]*SResourceState) (*SApplyResult, error) {
+	result := &SApplyResult{State: map[string]*SResourceState{}}
+	for name, st := range state {
+		result.State[name] = st.copy()
+	}
+	order, err := SortResources(tmpl)
+	if err != nil {
+		return result, err
+	}
+	stale := map[string]*SResourceState{}
+	for name, st := range result.State {
+		if res, ok := tmpl.Resources[name]; !ok || res.Type != st.Type {
+			stale[name] = st
+		}
+	}
+
+	scope := newScope(params)
+	journal := []sJournalEntry{}
+	for _, name := range order {
+		err = a.applyResource(ctx, scope, name, tmpl.Resources[name], result, &journal)
+		if err != nil {
+			err = errors.Wrapf(err, "apply resource %s", name)
+			break
+		}
+		scope.attrs[name] = result.State[name].Attributes
+	}
+	if err != nil {
+		log.Errorf("stack apply failed, rollback %d changes: %v", len(journal), err)
+		rollbackErr := a.rollback(ctx, result, journal)
+		if rollbackErr != nil {
+			return result, errors.Wrap(ErrRollbackFailed, fmt.Sprintf("%v, rollback error: %v", err, rollbackErr))
+		}
+		return result, err
+	}
+
+	for _, name := range deleteOrder(stale) {
+		st := stale[name]
+		err = a.delete(ctx, st)
+		if err != nil {
+			// keep the stale resource so that it can be deleted next time
+			if _, ok := tmpl.Resources[name]; ok {
+				result.State[name+"#"+st.ExternalId] = st
+			}
+			return result, err
+		}
+		if cur, ok := result.State[name]; ok && cur == st {
+			delete(result.State, name)
+		}
+	}
+
+	result.Outputs, err = ResolveOutputs(tmpl, params, result.State)
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// Destroy deletes all resources in reverse dependency order and returns the
+// resources remaining
+func (a *SApplier) Destroy(ctx context.Context, state map[string]*SResourceState) (map[string]*SResourceState, error) {
+	remain := map[string]*SResourceState{}
+	for name, st := range state {
+		remain[name] = st
+	}
+	for _, name := range deleteOrder(state) {
+		err := a.delete(ctx, state[name])
+		if err != nil {
+			return remain, err
+		}
+		delete(remain, name)
+	}
+	return remain, nil
+}
@@ -0,0 +1,177 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/modulebase"
+	"yunion.io/x/onecloud/pkg/util/httputils"
+)
+
+// IResourceClient manipulates the resources of a stack, resType is the
+// plural keyword of the resource module, e.g. servers
+type IResourceClient interface {
+	Create(ctx context.Context, resType string, params *jsonutils.JSONDict) (jsonutils.JSONObject, error)
+	Update(ctx context.Context, resType string, id string, params *jsonutils.JSONDict) (jsonutils.JSONObject, error)
+	Delete(ctx context.Context, resType string, id string) error
+	Get(ctx context.Context, resType string, id string) (jsonutils.JSONObject, error)
+	WaitStatus(ctx context.Context, resType string, id string, expect []string, failed []string, timeout time.Duration) (jsonutils.JSONObject, error)
+	WaitDeleted(ctx context.Context, resType string, id string, timeout time.Duration) error
+}
+
+type sWaitStatus struct {
+	expect []string
+	failed []string
+}
+
+// defaultWaitStatus is used for resources without wait_status in template
+var defaultWaitStatus = map[string]sWaitStatus{
+	"servers": {
+		expect: []string{compute.VM_RUNNING},
+		failed: []string{
+			compute.VM_SCHEDULE_FAILED,
+			compute.VM_NETWORK_FAILED,
+			compute.VM_CREATE_FAILED,
+			compute.VM_DISK_FAILED,
+			compute.VM_DEPLOY_FAILED,
+			compute.VM_START_FAILED,
+		},
+	},
+	"vpcs": {
+		expect: []string{compute.VPC_STATUS_AVAILABLE},
+		failed: []string{compute.VPC_STATUS_FAILED},
+	},
+	"networks": {
+		expect: []string{compute.NETWORK_STATUS_AVAILABLE},
+		failed: []string{compute.NETWORK_STATUS_FAILED},
+	},
+	"secgroups": {
+		expect: []string{compute.SECGROUP_STATUS_READY},
+	},
+	"eips": {
+		expect: []string{compute.EIP_STATUS_READY},
+		failed: []string{compute.EIP_STATUS_ALLOCATE_FAIL},
+	},
+	"disks": {
+		expect: []string{compute.DISK_READY},
+		failed: []string{compute.DISK_ALLOC_FAILED},
+	},
+	"loadbalancers": {
+		expect: []string{compute.LB_STATUS_ENABLED},
+		failed: []string{compute.LB_CREATE_FAILED},
+	},
+}
+
+func IsNotFound(err error) bool {
+	return httputils.ErrorCode(err) == 404 || errors.Cause(err) == httperrors.ErrResourceNotFound
+}
+
+type iWaitStatusManager interface {
+	WaitStatus(session *mcclient.ClientSession, id string, expect []string, failed []string, timeout time.Duration) (jsonutils.JSONObject, error)
+}
+
+type sSessionClient struct {
+	session *mcclient.ClientSession
+}
+
+// NewSessionClient returns a resource client calling the service APIs
+// through the modules registered in mcclient
+func NewSessionClient(session *mcclient.ClientSession) IResourceClient {
+	return &sSessionClient{session: session}
+}
+
+func (c *sSessionClient) getModule(resType string) (modulebase.Manager, error) {
+	module, err := modulebase.GetModule(c.session, resType)
+	if err != nil {
+		return nil, httperrors.NewInputParameterError("unsupported resource type %s: %v", resType, err)
+	}
+	return module, nil
+}
+
+func (c *sSessionClient) Create(ctx context.Context, resType string, params *jsonutils.JSONDict) (jsonutils.JSONObject, error) {
+	module, err := c.getModule(resType)
+	if err != nil {
+		return nil, err
+	}
+	return module.Create(c.session, params)
+}
+
+func (c *sSessionClient) Update(ctx context.Context, resType string, id string, params *jsonutils.JSONDict) (jsonutils.JSONObject, error) {
+	module, err := c.getModule(resType)
+	if err != nil {
+		return nil, err
+	}
+	return module.Update(c.session, id, params)
+}
+
+func (c *sSessionClient) Delete(ctx context.Context, resType string, id string) error {
+	module, err := c.getModule(resType)
+	if err != nil {
+		return err
+	}
+	// stack resources are removed immediately rather than kept in recycle bin
+	query := jsonutils.NewDict()
+	query.Set("override_pending_delete", jsonutils.JSONTrue)
+	_, err = module.DeleteWithParam(c.session, id, query, nil)
+	if err != nil && !IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (c *sSessionClient) Get(ctx context.Context, resType string, id string) (jsonutils.JSONObject, error) {
+	module, err := c.getModule(resType)
+	if err != nil {
+		return nil, err
+	}
+	return module.GetById(c.session, id, nil)
+}
+
+func (c *sSessionClient) WaitStatus(ctx context.Context, resType string, id string, expect []string, failed []string, timeout time.Duration) (jsonutils.JSONObject, error) {
+	module, err := c.getModule(resType)
+	if err != nil {
+		return nil, err
+	}
+	wm, ok := module.(iWaitStatusManager)
+	if !ok {
+		return nil, httperrors.NewNotSupportedError("resource type %s does not support waiting status", resType)
+	}
+	return wm.WaitStatus(c.session, id, expect, failed, timeout)
+}
+
+func (c *sSessionClient) WaitDeleted(ctx context.Context, resType string, id string, timeout time.Duration) error {
+	module, err := c.getModule(resType)
+	if err != nil {
+		return err
+	}
+	wm, ok := module.(iWaitStatusManager)
+	if !ok {
+		return httperrors.NewNotSupportedError("resource type %s does not support waiting status", resType)
+	}
+	// no status is expected, it returns only when the resource is gone
+	_, err = wm.WaitStatus(c.session, id, nil, nil, timeout)
+	if err != nil && !IsNotFound(err) {
+		return errors.Wrapf(err, "wait %s %s deleted", resType, id)
+	}
+	return nil
+}
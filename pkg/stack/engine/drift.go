@@ -0,0 +1,122 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"sort"
+
+	"yunion.io/x/jsonutils"
+
+	api "yunion.io/x/onecloud/pkg/apis/stack"
+)
+
+// liveValues returns the values of the live resource that may correspond to
+// the property, a reference property like vpc is usually shown as both vpc
+// (name) and vpc_id in details
+func liveValues(live jsonutils.JSONObject, key string) []jsonutils.JSONObject {
+	ret := []jsonutils.JSONObject{}
+	for _, k := range []string{key, key + "_id"} {
+		if val, _ := live.Get(k); val != nil {
+			ret = append(ret, val)
+		}
+	}
+	return ret
+}
+
+func isScalar(val jsonutils.JSONObject) bool {
+	switch val.(type) {
+	case *jsonutils.JSONDict, *jsonutils.JSONArray:
+		return false
+	}
+	return true
+}
+
+// compareLive compares the applied scalar properties with the live details.
+// Properties absent in details, e.g. password, and structured ones, which are
+// usually shown differently than created, are not compared
+func compareLive(st *SResourceState, live jsonutils.JSONObject) []api.StackPropertyChange {
+	diffs := []api.StackPropertyChange{}
+	if st.Properties == nil {
+		return diffs
+	}
+	for _, key := range st.Properties.SortedKeys() {
+		want, _ := st.Properties.Get(key)
+		if !isScalar(want) {
+			continue
+		}
+		vals := liveValues(live, key)
+		if len(vals) == 0 {
+			continue
+		}
+		matched := false
+		for _, val := range vals {
+			if equals(want, val) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			diffs = append(diffs, api.StackPropertyChange{Key: key, Before: want, After: vals[0]})
+		}
+	}
+	return diffs
+}
+
+// DetectDrift compares the state with the live resources and returns the
+// drift status of the stack as well as each resource
+func DetectDrift(ctx context.Context, client IResourceClient, state map[string]*SResourceState) (string, []api.StackResourceDrift) {
+	names := make([]string, 0, len(state))
+	for name := range state {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	drifts := make([]api.StackResourceDrift, 0, len(names))
+	status := api.STACK_DRIFT_STATUS_IN_SYNC
+	for _, name := range names {
+		st := state[name]
+		drift := api.StackResourceDrift{
+			Name:        name,
+			Type:        st.Type,
+			ExternalId:  st.ExternalId,
+			Differences: []api.StackPropertyChange{},
+		}
+		live, err := client.Get(ctx, st.Type, st.ExternalId)
+		switch {
+		case err != nil && IsNotFound(err):
+			drift.DriftStatus = api.STACK_DRIFT_STATUS_DELETED
+		case err != nil:
+			drift.DriftStatus = api.STACK_DRIFT_STATUS_UNKNOWN
+			drift.Reason = err.Error()
+		default:
+			drift.Differences = compareLive(st, live)
+			if len(drift.Differences) > 0 {
+				drift.DriftStatus = api.STACK_DRIFT_STATUS_DRIFTED
+			} else {
+				drift.DriftStatus = api.STACK_DRIFT_STATUS_IN_SYNC
+			}
+		}
+		switch drift.DriftStatus {
+		case api.STACK_DRIFT_STATUS_DRIFTED, api.STACK_DRIFT_STATUS_DELETED:
+			status = api.STACK_DRIFT_STATUS_DRIFTED
+		case api.STACK_DRIFT_STATUS_UNKNOWN:
+			if status == api.STACK_DRIFT_STATUS_IN_SYNC {
+				status = api.STACK_DRIFT_STATUS_UNKNOWN
+			}
+		}
+		drifts = append(drifts, drift)
+	}
+	return status, drifts
+}
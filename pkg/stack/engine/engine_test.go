@@ -0,0 +1,339 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+
+	api "yunion.io/x/onecloud/pkg/apis/stack"
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+type fakeClient struct {
+	objs       map[string]*jsonutils.JSONDict
+	seq        int
+	failCreate string
+	calls      []string
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{objs: map[string]*jsonutils.JSONDict{}}
+}
+
+func (c *fakeClient) Create(ctx context.Context, resType string, params *jsonutils.JSONDict) (jsonutils.JSONObject, error) {
+	c.calls = append(c.calls, "create "+resType)
+	if resType == c.failCreate {
+		return nil, httperrors.NewInsufficientResourceError("no capacity for %s", resType)
+	}
+	c.seq++
+	id := fmt.Sprintf("%s-%d", resType, c.seq)
+	obj := params.Copy()
+	obj.Set("id", jsonutils.NewString(id))
+	obj.Set("status", jsonutils.NewString("ready"))
+	if status, ok := defaultWaitStatus[resType]; ok {
+		obj.Set("status", jsonutils.NewString(status.expect[0]))
+	}
+	c.objs[id] = obj
+	return obj.Copy(), nil
+}
+
+func (c *fakeClient) Update(ctx context.Context, resType string, id string, params *jsonutils.JSONDict) (jsonutils.JSONObject, error) {
+	c.calls = append(c.calls, "update "+id)
+	obj, ok := c.objs[id]
+	if !ok {
+		return nil, httperrors.NewResourceNotFoundError2(resType, id)
+	}
+	obj.Update(params)
+	return obj.Copy(), nil
+}
+
+func (c *fakeClient) Delete(ctx context.Context, resType string, id string) error {
+	c.calls = append(c.calls, "delete "+id)
+	delete(c.objs, id)
+	return nil
+}
+
+func (c *fakeClient) Get(ctx context.Context, resType string, id string) (jsonutils.JSONObject, error) {
+	obj, ok := c.objs[id]
+	if !ok {
+		return nil, httperrors.NewResourceNotFoundError2(resType, id)
+	}
+	return obj.Copy(), nil
+}
+
+func (c *fakeClient) WaitStatus(ctx context.Context, resType string, id string, expect []string, failed []string, timeout time.Duration) (jsonutils.JSONObject, error) {
+	return c.Get(ctx, resType, id)
+}
+
+func (c *fakeClient) WaitDeleted(ctx context.Context, resType string, id string, timeout time.Duration) error {
+	return nil
+}
+
+const testTemplate = `
+parameters:
+  prefix:
+    type: string
+    default: demo
+  cpu:
+    type: number
+resources:
+  server:
+    type: servers
+    properties:
+      name: ${param.prefix}-web
+      vcpu_count: ${param.cpu}
+      nets:
+      - network: ${res.network.id}
+  network:
+    type: networks
+    properties:
+      name: ${param.prefix}-net
+      vpc: ${res.vpc.id}
+      guest_ip_start: 10.0.0.2
+  vpc:
+    type: vpcs
+    properties:
+      name: ${param.prefix}-vpc
+      cidr_block: 10.0.0.0/16
+outputs:
+  server_id: ${res.server.id}
+`
+
+func parseTestTemplate(t *testing.T, content string, params map[string]string) (*api.StackTemplate, *jsonutils.JSONDict) {
+	tmpl, err := ParseTemplate(content)
+	if err != nil {
+		t.Fatalf("ParseTemplate: %v", err)
+	}
+	resolved, err := ResolveParameters(tmpl, jsonutils.Marshal(params).(*jsonutils.JSONDict))
+	if err != nil {
+		t.Fatalf("ResolveParameters: %v", err)
+	}
+	return tmpl, resolved
+}
+
+func TestParseTemplate(t *testing.T) {
+	tmpl, params := parseTestTemplate(t, testTemplate, map[string]string{"cpu": "2"})
+	order, err := SortResources(tmpl)
+	if err != nil {
+		t.Fatalf("SortResources: %v", err)
+	}
+	if fmt.Sprintf("%v", order) != "[vpc network server]" {
+		t.Errorf("unexpected order %v", order)
+	}
+	if cpu, _ := params.Int("cpu"); cpu != 2 {
+		t.Errorf("parameter cpu should be converted to number, got %s", params)
+	}
+
+	cases := []struct {
+		name    string
+		content string
+	}{
+		{
+			name:    "cycle",
+			content: `{"resources": {"a": {"type": "vpcs", "depends_on": ["b"]}, "b": {"type": "vpcs", "properties": {"name": "${res.a.name}"}}}}`,
+		},
+		{
+			name:    "undefined parameter",
+			content: `{"resources": {"a": {"type": "vpcs", "properties": {"name": "${param.name}"}}}}`,
+		},
+		{
+			name:    "undefined resource",
+			content: `{"resources": {"a": {"type": "vpcs", "depends_on": ["b"]}}}`,
+		},
+	}
+	for _, c := range cases {
+		if _, err := ParseTemplate(c.content); err == nil {
+			t.Errorf("%s: template should be rejected", c.name)
+		}
+	}
+	if _, err := ResolveParameters(tmpl, nil); err == nil {
+		t.Errorf("missing parameter without default should be rejected")
+	}
+}
+
+func changeActions(changeSet *api.StackChangeSet) string {
+	actions := ""
+	for _, change := range changeSet.Changes {
+		actions += fmt.Sprintf("%s:%s ", change.Name, change.Action)
+	}
+	return actions
+}
+
+func TestPlanAndApply(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+	applier := NewApplier(client)
+
+	tmpl, params := parseTestTemplate(t, testTemplate, map[string]string{"cpu": "2"})
+	changeSet, err := Plan(tmpl, params, nil)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if actions := changeActions(changeSet); actions != "vpc:create network:create server:create " {
+		t.Errorf("unexpected change set %s", actions)
+	}
+	for _, change := range changeSet.Changes[1].Changes {
+		if change.Key == "vpc" && !change.Computed {
+			t.Errorf("vpc of network should be known after apply")
+		}
+	}
+
+	result, err := applier.Apply(ctx, tmpl, params, nil)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if len(result.State) != 3 || len(client.objs) != 3 {
+		t.Fatalf("expect 3 resources, got %d", len(result.State))
+	}
+	network, _ := client.Get(ctx, "networks", result.State["network"].ExternalId)
+	if vpc, _ := network.GetString("vpc"); vpc != result.State["vpc"].ExternalId {
+		t.Errorf("network should reference vpc %s, got %s", result.State["vpc"].ExternalId, vpc)
+	}
+	if id, _ := result.Outputs.GetString("server_id"); id != result.State["server"].ExternalId {
+		t.Errorf("unexpected outputs %s", result.Outputs)
+	}
+
+	// update server and remove network
+	updated := `
+parameters:
+  cpu:
+    type: number
+resources:
+  vpc:
+    type: vpcs
+    properties:
+      name: demo-vpc
+      cidr_block: 10.0.0.0/16
+  server:
+    type: servers
+    properties:
+      name: demo-web
+      vcpu_count: ${param.cpu}
+      vpc: ${res.vpc.id}
+`
+	tmpl, params = parseTestTemplate(t, updated, map[string]string{"cpu": "4"})
+	changeSet, err = Plan(tmpl, params, result.State)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if actions := changeActions(changeSet); actions != "vpc:noop server:update network:delete " {
+		t.Errorf("unexpected change set %s", actions)
+	}
+	result, err = applier.Apply(ctx, tmpl, params, result.State)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if _, ok := result.State["network"]; ok || len(client.objs) != 2 {
+		t.Errorf("network should be deleted")
+	}
+	server, _ := client.Get(ctx, "servers", result.State["server"].ExternalId)
+	if cpu, _ := server.Int("vcpu_count"); cpu != 4 {
+		t.Errorf("server should be updated, got %s", server)
+	}
+
+	remain, err := applier.Destroy(ctx, result.State)
+	if err != nil || len(remain) > 0 || len(client.objs) > 0 {
+		t.Errorf("Destroy: %v, remain %d", err, len(remain))
+	}
+}
+
+func TestApplyRollback(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+	applier := NewApplier(client)
+
+	tmpl, params := parseTestTemplate(t, testTemplate, map[string]string{"cpu": "2"})
+	client.failCreate = "servers"
+	result, err := applier.Apply(ctx, tmpl, params, nil)
+	if err == nil {
+		t.Fatalf("Apply should fail")
+	}
+	if len(result.State) > 0 || len(client.objs) > 0 {
+		t.Errorf("created resources should be rolled back, state %d objects %d", len(result.State), len(client.objs))
+	}
+
+	client.failCreate = ""
+	result, err = applier.Apply(ctx, tmpl, params, nil)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	// updating vpc succeeds while creating the new eip fails
+	updated := strings.Replace(testTemplate, "outputs:", `  eip:
+    type: eips
+    depends_on: [vpc]
+    properties:
+      bandwidth: 10
+outputs:`, 1)
+	updated = strings.Replace(updated, "name: ${param.prefix}-vpc", "name: ${param.prefix}-vpc2", 1)
+	tmpl, params = parseTestTemplate(t, updated, map[string]string{"cpu": "2"})
+	client.failCreate = "eips"
+	state := result.State
+	result, err = applier.Apply(ctx, tmpl, params, state)
+	if err == nil {
+		t.Fatalf("Apply should fail")
+	}
+	vpc, _ := client.Get(ctx, "vpcs", state["vpc"].ExternalId)
+	if name, _ := vpc.GetString("name"); name != "demo-vpc" {
+		t.Errorf("vpc name should be restored, got %s", name)
+	}
+	if name, _ := result.State["vpc"].Properties.GetString("name"); name != "demo-vpc" {
+		t.Errorf("vpc state should be restored, got %s", name)
+	}
+}
+
+func TestDetectDrift(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+	applier := NewApplier(client)
+
+	tmpl, params := parseTestTemplate(t, testTemplate, map[string]string{"cpu": "2"})
+	result, err := applier.Apply(ctx, tmpl, params, nil)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	status, _ := DetectDrift(ctx, client, result.State)
+	if status != api.STACK_DRIFT_STATUS_IN_SYNC {
+		t.Errorf("expect in sync, got %s", status)
+	}
+
+	client.objs[result.State["server"].ExternalId].Set("vcpu_count", jsonutils.NewInt(8))
+	delete(client.objs, result.State["vpc"].ExternalId)
+	status, drifts := DetectDrift(ctx, client, result.State)
+	if status != api.STACK_DRIFT_STATUS_DRIFTED {
+		t.Errorf("expect drifted, got %s", status)
+	}
+	for _, drift := range drifts {
+		switch drift.Name {
+		case "server":
+			if drift.DriftStatus != api.STACK_DRIFT_STATUS_DRIFTED || len(drift.Differences) != 1 || drift.Differences[0].Key != "vcpu_count" {
+				t.Errorf("unexpected server drift %s", jsonutils.Marshal(drift))
+			}
+		case "vpc":
+			if drift.DriftStatus != api.STACK_DRIFT_STATUS_DELETED {
+				t.Errorf("vpc should be deleted, got %s", drift.DriftStatus)
+			}
+		case "network":
+			if drift.DriftStatus != api.STACK_DRIFT_STATUS_IN_SYNC {
+				t.Errorf("network should be in sync, got %s", drift.DriftStatus)
+			}
+		}
+	}
+}
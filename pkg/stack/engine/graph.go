@@ -0,0 +1,105 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"sort"
+	"strings"
+
+	api "yunion.io/x/onecloud/pkg/apis/stack"
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+// topoSort orders the nodes so that every node follows its dependencies,
+// dependencies outside of nodes are ignored. Nodes without order constraint
+// are sorted by name to keep the result stable
+func topoSort(nodes map[string][]string) ([]string, error) {
+	indegree := map[string]int{}
+	dependents := map[string][]string{}
+	for name, deps := range nodes {
+		indegree[name] += 0
+		for _, dep := range deps {
+			if _, ok := nodes[dep]; !ok || dep == name {
+				continue
+			}
+			indegree[name]++
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+	ready := []string{}
+	for name, cnt := range indegree {
+		if cnt == 0 {
+			ready = append(ready, name)
+		}
+	}
+	sort.Strings(ready)
+	order := make([]string, 0, len(nodes))
+	for len(ready) > 0 {
+		name := ready[0]
+		ready = ready[1:]
+		order = append(order, name)
+		next := []string{}
+		for _, dependent := range dependents[name] {
+			indegree[dependent]--
+			if indegree[dependent] == 0 {
+				next = append(next, dependent)
+			}
+		}
+		ready = append(ready, next...)
+		sort.Strings(ready)
+	}
+	if len(order) < len(nodes) {
+		cycle := []string{}
+		for name, cnt := range indegree {
+			if cnt > 0 {
+				cycle = append(cycle, name)
+			}
+		}
+		sort.Strings(cycle)
+		return nil, httperrors.NewInputParameterError("dependency cycle among resources %s", strings.Join(cycle, ","))
+	}
+	return order, nil
+}
+
+// SortResources returns the resource names of the template in creation order
+func SortResources(tmpl *api.StackTemplate) ([]string, error) {
+	nodes := map[string][]string{}
+	for name, res := range tmpl.Resources {
+		nodes[name] = ResourceDependencies(res)
+	}
+	return topoSort(nodes)
+}
+
+// deleteOrder returns the names of the given resources in deletion order,
+// i.e. dependents before their dependencies
+func deleteOrder(states map[string]*SResourceState) []string {
+	nodes := map[string][]string{}
+	for name, st := range states {
+		nodes[name] = st.DependsOn
+	}
+	order, err := topoSort(nodes)
+	if err != nil {
+		// the stored dependencies should never loop, fallback to name order
+		order = make([]string, 0, len(states))
+		for name := range states {
+			order = append(order, name)
+		}
+		sort.Strings(order)
+	}
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+	return order
+}
@@ -0,0 +1,155 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/stack"
+)
+
+// SResourceState records a resource created by a stack
+type SResourceState struct {
+	// Name of the resource in template
+	Name       string
+	Type       string
+	ExternalId string
+	// Properties are the resolved properties applied last time
+	Properties *jsonutils.JSONDict
+	DependsOn  []string
+	// Attributes are the details of the resource, which are referenced by
+	// the other resources
+	Attributes jsonutils.JSONObject
+}
+
+func (st *SResourceState) copy() *SResourceState {
+	ret := *st
+	return &ret
+}
+
+func equals(a, b jsonutils.JSONObject) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return stringValue(a) == stringValue(b)
+}
+
+// diffProperties compares the applied properties with the desired ones,
+// properties removed from template are not unset and thus ignored
+func diffProperties(before, after *jsonutils.JSONDict, computed []string) []api.StackPropertyChange {
+	changes := []api.StackPropertyChange{}
+	get := func(key string) jsonutils.JSONObject {
+		if before == nil {
+			return nil
+		}
+		val, _ := before.Get(key)
+		return val
+	}
+	keys := after.SortedKeys()
+	for _, key := range keys {
+		val, _ := after.Get(key)
+		prev := get(key)
+		if !equals(prev, val) {
+			changes = append(changes, api.StackPropertyChange{Key: key, Before: prev, After: val})
+		}
+	}
+	for _, key := range computed {
+		changes = append(changes, api.StackPropertyChange{Key: key, Before: get(key), Computed: true})
+	}
+	return changes
+}
+
+// Plan computes the change set of applying the template to the resources
+// in state without touching any of them
+func Plan(tmpl *api.StackTemplate, params *jsonutils.JSONDict, state map[string]*SResourceState) (*api.StackChangeSet, error) {
+	order, err := SortResources(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	scope := newScope(params)
+	changeSet := &api.StackChangeSet{Changes: []api.StackChange{}}
+	for _, name := range order {
+		res := tmpl.Resources[name]
+		props, computed, err := scope.resolveProperties(res.Properties)
+		if err != nil {
+			return nil, errors.Wrapf(err, "resource %s", name)
+		}
+		change := api.StackChange{
+			Name: name,
+			Type: res.Type,
+		}
+		st := state[name]
+		switch {
+		case st == nil:
+			change.Action = api.STACK_CHANGE_ACTION_CREATE
+			change.Changes = diffProperties(nil, props, computed)
+		case st.Type != res.Type:
+			change.Action = api.STACK_CHANGE_ACTION_REPLACE
+			change.ExternalId = st.ExternalId
+			change.Changes = diffProperties(nil, props, computed)
+		default:
+			change.ExternalId = st.ExternalId
+			change.Changes = diffProperties(st.Properties, props, computed)
+			if len(change.Changes) > 0 {
+				change.Action = api.STACK_CHANGE_ACTION_UPDATE
+			} else {
+				change.Action = api.STACK_CHANGE_ACTION_NOOP
+			}
+			scope.attrs[name] = st.Attributes
+		}
+		changeSet.Changes = append(changeSet.Changes, change)
+	}
+	stale := map[string]*SResourceState{}
+	for name, st := range state {
+		if res, ok := tmpl.Resources[name]; !ok || res.Type != st.Type {
+			stale[name] = st
+		}
+	}
+	for _, name := range deleteOrder(stale) {
+		if _, ok := tmpl.Resources[name]; ok {
+			// replaced, already listed
+			continue
+		}
+		st := stale[name]
+		changeSet.Changes = append(changeSet.Changes, api.StackChange{
+			Name:       name,
+			Type:       st.Type,
+			Action:     api.STACK_CHANGE_ACTION_DELETE,
+			ExternalId: st.ExternalId,
+		})
+	}
+	return changeSet, nil
+}
+
+// ResolveOutputs evaluates the template outputs against the applied state
+func ResolveOutputs(tmpl *api.StackTemplate, params *jsonutils.JSONDict, state map[string]*SResourceState) (*jsonutils.JSONDict, error) {
+	scope := newScope(params)
+	for name, st := range state {
+		scope.attrs[name] = st.Attributes
+	}
+	outputs := jsonutils.NewDict()
+	for name, output := range tmpl.Outputs {
+		val, computed, err := scope.resolve(output)
+		if err != nil {
+			return nil, errors.Wrapf(err, "output %s", name)
+		}
+		if computed {
+			return nil, errors.Errorf("output %s references resources not created", name)
+		}
+		outputs.Set(name, val)
+	}
+	return outputs, nil
+}
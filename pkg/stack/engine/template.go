@@ -0,0 +1,396 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/gotypes"
+
+	api "yunion.io/x/onecloud/pkg/apis/stack"
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+// refPattern matches ${param.<name>} and ${res.<name>.<attribute>}
+var refPattern = regexp.MustCompile(`\$\{\s*(param|res)\.([a-zA-Z0-9_\-]+)((?:\.[a-zA-Z0-9_\-]+)*)\s*\}`)
+
+type sRef struct {
+	kind string
+	name string
+	path []string
+}
+
+func parseRefs(str string) []sRef {
+	refs := []sRef{}
+	for _, m := range refPattern.FindAllStringSubmatch(str, -1) {
+		ref := sRef{kind: m[1], name: m[2]}
+		if len(m[3]) > 0 {
+			ref.path = strings.Split(strings.TrimPrefix(m[3], "."), ".")
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+func walkStrings(obj jsonutils.JSONObject, f func(str string)) {
+	if gotypes.IsNil(obj) {
+		return
+	}
+	switch v := obj.(type) {
+	case *jsonutils.JSONString:
+		str, _ := v.GetString()
+		f(str)
+	case *jsonutils.JSONDict:
+		m, _ := v.GetMap()
+		for _, val := range m {
+			walkStrings(val, f)
+		}
+	case *jsonutils.JSONArray:
+		arr, _ := v.GetArray()
+		for _, val := range arr {
+			walkStrings(val, f)
+		}
+	}
+}
+
+func collectRefs(obj jsonutils.JSONObject) []sRef {
+	refs := []sRef{}
+	walkStrings(obj, func(str string) {
+		refs = append(refs, parseRefs(str)...)
+	})
+	return refs
+}
+
+// ResourceDependencies returns the sorted names of resources the template
+// resource depends on, either referenced in properties or listed in depends_on
+func ResourceDependencies(res api.StackTemplateResource) []string {
+	deps := map[string]bool{}
+	for _, ref := range collectRefs(res.Properties) {
+		if ref.kind == api.STACK_REF_RESOURCE {
+			deps[ref.name] = true
+		}
+	}
+	for _, name := range res.DependsOn {
+		deps[name] = true
+	}
+	ret := make([]string, 0, len(deps))
+	for name := range deps {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// ParseTemplate parses a YAML or JSON stack template and validates its
+// parameters, references and dependencies
+func ParseTemplate(content string) (*api.StackTemplate, error) {
+	if len(strings.TrimSpace(content)) == 0 {
+		return nil, httperrors.NewMissingParameterError("template")
+	}
+	var obj jsonutils.JSONObject
+	var err error
+	if strings.HasPrefix(strings.TrimSpace(content), "{") {
+		obj, err = jsonutils.ParseString(content)
+	} else {
+		obj, err = jsonutils.ParseYAML(content)
+	}
+	if err != nil {
+		return nil, httperrors.NewInputParameterError("invalid template: %v", err)
+	}
+	tmpl := &api.StackTemplate{}
+	err = obj.Unmarshal(tmpl)
+	if err != nil {
+		return nil, httperrors.NewInputParameterError("invalid template: %v", err)
+	}
+	err = ValidateTemplate(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+func ValidateTemplate(tmpl *api.StackTemplate) error {
+	if len(tmpl.Resources) == 0 {
+		return httperrors.NewInputParameterError("template contains no resources")
+	}
+	checkRefs := func(where string, obj jsonutils.JSONObject) error {
+		for _, ref := range collectRefs(obj) {
+			switch ref.kind {
+			case api.STACK_REF_PARAM:
+				if _, ok := tmpl.Parameters[ref.name]; !ok {
+					return httperrors.NewInputParameterError("%s references undefined parameter %s", where, ref.name)
+				}
+			case api.STACK_REF_RESOURCE:
+				if _, ok := tmpl.Resources[ref.name]; !ok {
+					return httperrors.NewInputParameterError("%s references undefined resource %s", where, ref.name)
+				}
+				if len(ref.path) == 0 {
+					return httperrors.NewInputParameterError("%s references resource %s without attribute", where, ref.name)
+				}
+			}
+		}
+		return nil
+	}
+	for name, res := range tmpl.Resources {
+		if len(res.Type) == 0 {
+			return httperrors.NewInputParameterError("resource %s missing type", name)
+		}
+		for _, dep := range res.DependsOn {
+			if _, ok := tmpl.Resources[dep]; !ok {
+				return httperrors.NewInputParameterError("resource %s depends on undefined resource %s", name, dep)
+			}
+		}
+		err := checkRefs(fmt.Sprintf("resource %s", name), res.Properties)
+		if err != nil {
+			return err
+		}
+	}
+	for name, output := range tmpl.Outputs {
+		err := checkRefs(fmt.Sprintf("output %s", name), output)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := SortResources(tmpl)
+	return err
+}
+
+func parseParameterValue(name string, param api.StackTemplateParameter, val jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	str, isStr := val.(*jsonutils.JSONString)
+	switch param.Type {
+	case "", "json":
+		if isStr && param.Type == "json" {
+			s, _ := str.GetString()
+			obj, err := jsonutils.ParseString(s)
+			if err != nil {
+				return nil, httperrors.NewInputParameterError("parameter %s is not valid json: %v", name, err)
+			}
+			return obj, nil
+		}
+	case "string":
+		if !isStr {
+			return jsonutils.NewString(val.String()), nil
+		}
+	case "number":
+		switch val.(type) {
+		case *jsonutils.JSONInt, *jsonutils.JSONFloat:
+		case *jsonutils.JSONString:
+			s, _ := str.GetString()
+			if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return jsonutils.NewInt(i), nil
+			}
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, httperrors.NewInputParameterError("parameter %s is not a number: %s", name, s)
+			}
+			return jsonutils.NewFloat64(f), nil
+		default:
+			return nil, httperrors.NewInputParameterError("parameter %s is not a number", name)
+		}
+	case "boolean":
+		switch val.(type) {
+		case *jsonutils.JSONBool:
+		case *jsonutils.JSONString:
+			s, _ := str.GetString()
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, httperrors.NewInputParameterError("parameter %s is not a boolean: %s", name, s)
+			}
+			return jsonutils.NewBool(b), nil
+		default:
+			return nil, httperrors.NewInputParameterError("parameter %s is not a boolean", name)
+		}
+	default:
+		return nil, httperrors.NewInputParameterError("parameter %s has unsupported type %s", name, param.Type)
+	}
+	return val, nil
+}
+
+// ResolveParameters merges the input values with the defaults of the
+// template parameters and converts them to the declared types
+func ResolveParameters(tmpl *api.StackTemplate, input *jsonutils.JSONDict) (*jsonutils.JSONDict, error) {
+	if input == nil {
+		input = jsonutils.NewDict()
+	}
+	for _, key := range input.SortedKeys() {
+		if _, ok := tmpl.Parameters[key]; !ok {
+			return nil, httperrors.NewInputParameterError("unknown parameter %s", key)
+		}
+	}
+	params := jsonutils.NewDict()
+	for name, param := range tmpl.Parameters {
+		val, _ := input.Get(name)
+		if val == nil {
+			val = param.Default
+		}
+		if val == nil || val == jsonutils.JSONNull {
+			return nil, httperrors.NewMissingParameterError(name)
+		}
+		val, err := parseParameterValue(name, param, val)
+		if err != nil {
+			return nil, err
+		}
+		if len(param.AllowedValues) > 0 {
+			s := stringValue(val)
+			found := false
+			for _, v := range param.AllowedValues {
+				if v == s {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, httperrors.NewInputParameterError("parameter %s value %s not in %v", name, s, param.AllowedValues)
+			}
+		}
+		params.Set(name, val)
+	}
+	return params, nil
+}
+
+func stringValue(val jsonutils.JSONObject) string {
+	if str, ok := val.(*jsonutils.JSONString); ok {
+		s, _ := str.GetString()
+		return s
+	}
+	return val.String()
+}
+
+// sScope resolves references against parameters and the attributes of the
+// resources which are already known
+type sScope struct {
+	params *jsonutils.JSONDict
+	attrs  map[string]jsonutils.JSONObject
+}
+
+func newScope(params *jsonutils.JSONDict) *sScope {
+	return &sScope{
+		params: params,
+		attrs:  map[string]jsonutils.JSONObject{},
+	}
+}
+
+// lookup returns nil without error if the value is only known after apply
+func (s *sScope) lookup(ref sRef) (jsonutils.JSONObject, error) {
+	switch ref.kind {
+	case api.STACK_REF_PARAM:
+		val, err := s.params.Get(ref.name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parameter %s", ref.name)
+		}
+		return val, nil
+	default:
+		attrs, ok := s.attrs[ref.name]
+		if !ok || attrs == nil {
+			return nil, nil
+		}
+		val, err := attrs.Get(ref.path...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "resource %s attribute %s", ref.name, strings.Join(ref.path, "."))
+		}
+		return val, nil
+	}
+}
+
+func (s *sScope) resolveString(str string) (jsonutils.JSONObject, bool, error) {
+	matches := refPattern.FindAllStringSubmatchIndex(str, -1)
+	if len(matches) == 0 {
+		return jsonutils.NewString(str), false, nil
+	}
+	refs := parseRefs(str)
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(str) {
+		// the whole string is a reference, keep the type of referenced value
+		val, err := s.lookup(refs[0])
+		return val, val == nil && err == nil, err
+	}
+	var buf strings.Builder
+	last := 0
+	for i, m := range matches {
+		val, err := s.lookup(refs[i])
+		if err != nil {
+			return nil, false, err
+		}
+		if val == nil {
+			return nil, true, nil
+		}
+		buf.WriteString(str[last:m[0]])
+		buf.WriteString(stringValue(val))
+		last = m[1]
+	}
+	buf.WriteString(str[last:])
+	return jsonutils.NewString(buf.String()), false, nil
+}
+
+// resolve substitutes all references in obj, the returned flag is true if
+// obj references attributes of resources not created yet
+func (s *sScope) resolve(obj jsonutils.JSONObject) (jsonutils.JSONObject, bool, error) {
+	switch v := obj.(type) {
+	case *jsonutils.JSONString:
+		str, _ := v.GetString()
+		return s.resolveString(str)
+	case *jsonutils.JSONDict:
+		ret := jsonutils.NewDict()
+		m, _ := v.GetMap()
+		for key, val := range m {
+			nv, computed, err := s.resolve(val)
+			if err != nil || computed {
+				return nil, computed, err
+			}
+			ret.Set(key, nv)
+		}
+		return ret, false, nil
+	case *jsonutils.JSONArray:
+		ret := jsonutils.NewArray()
+		arr, _ := v.GetArray()
+		for _, val := range arr {
+			nv, computed, err := s.resolve(val)
+			if err != nil || computed {
+				return nil, computed, err
+			}
+			ret.Add(nv)
+		}
+		return ret, false, nil
+	}
+	return obj, false, nil
+}
+
+// resolveProperties resolves the top level properties, keys whose values
+// are only known after apply are returned separately
+func (s *sScope) resolveProperties(props *jsonutils.JSONDict) (*jsonutils.JSONDict, []string, error) {
+	ret := jsonutils.NewDict()
+	computed := []string{}
+	if props == nil {
+		return ret, computed, nil
+	}
+	for _, key := range props.SortedKeys() {
+		val, _ := props.Get(key)
+		nv, isComputed, err := s.resolve(val)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "property %s", key)
+		}
+		if isComputed {
+			computed = append(computed, key)
+			continue
+		}
+		ret.Set(key, nv)
+	}
+	return ret, computed, nil
+}
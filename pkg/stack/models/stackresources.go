@@ -0,0 +1,184 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"database/sql"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+
+	api "yunion.io/x/onecloud/pkg/apis/stack"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/stack/engine"
+	"yunion.io/x/onecloud/pkg/util/stringutils2"
+)
+
+type SStackResourceManager struct {
+	db.SVirtualResourceBaseManager
+}
+
+var StackResourceManager *SStackResourceManager
+
+func init() {
+	StackResourceManager = &SStackResourceManager{
+		SVirtualResourceBaseManager: db.NewVirtualResourceBaseManager(
+			SStackResource{},
+			"stack_resources_tbl",
+			"stackresource",
+			"stackresources",
+		),
+	}
+	StackResourceManager.SetVirtualObject(StackResourceManager)
+}
+
+// SStackResource is a resource created by stack, its name is the name of
+// the resource in template
+type SStackResource struct {
+	db.SVirtualResourceBase
+
+	StackId      string `width:"36" charset:"ascii" nullable:"false" index:"true" list:"user"`
+	ResourceType string `width:"64" charset:"ascii" nullable:"false" list:"user"`
+	ExternalId   string `width:"128" charset:"ascii" index:"true" list:"user"`
+
+	// resolved properties applied last time
+	Properties *jsonutils.JSONDict  `length:"long" charset:"utf8" get:"user"`
+	Attributes jsonutils.JSONObject `length:"long" charset:"utf8" get:"user"`
+	DependsOn  *jsonutils.JSONArray `charset:"utf8" list:"user"`
+
+	DriftStatus string               `width:"16" charset:"ascii" default:"unknown" list:"user"`
+	Drift       *jsonutils.JSONArray `length:"medium" charset:"utf8" get:"user"`
+}
+
+func (manager *SStackResourceManager) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	return nil, httperrors.NewNotSupportedError("stack resources are created by applying stack")
+}
+
+func (manager *SStackResourceManager) ListItemFilter(ctx context.Context, q *sqlchemy.SQuery, userCred mcclient.TokenCredential, input api.StackResourceListInput) (*sqlchemy.SQuery, error) {
+	q, err := manager.SVirtualResourceBaseManager.ListItemFilter(ctx, q, userCred, input.VirtualResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SVirtualResourceBaseManager.ListItemFilter")
+	}
+	if len(input.Stack) > 0 {
+		stack, err := StackManager.FetchByIdOrName(userCred, input.Stack)
+		if err != nil {
+			if errors.Cause(err) == sql.ErrNoRows {
+				return nil, httperrors.NewResourceNotFoundError2(StackManager.Keyword(), input.Stack)
+			}
+			return nil, httperrors.NewGeneralError(err)
+		}
+		q = q.Equals("stack_id", stack.GetId())
+	}
+	if len(input.ResourceType) > 0 {
+		q = q.In("resource_type", input.ResourceType)
+	}
+	if len(input.DriftStatus) > 0 {
+		q = q.In("drift_status", input.DriftStatus)
+	}
+	return q, nil
+}
+
+func (manager *SStackResourceManager) OrderByExtraFields(ctx context.Context, q *sqlchemy.SQuery, userCred mcclient.TokenCredential, input api.StackResourceListInput) (*sqlchemy.SQuery, error) {
+	return manager.SVirtualResourceBaseManager.OrderByExtraFields(ctx, q, userCred, input.VirtualResourceListInput)
+}
+
+func (manager *SStackResourceManager) FetchCustomizeColumns(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	objs []interface{},
+	fields stringutils2.SSortedStrings,
+	isList bool,
+) []api.StackResourceDetails {
+	rows := make([]api.StackResourceDetails, len(objs))
+	virtRows := manager.SVirtualResourceBaseManager.FetchCustomizeColumns(ctx, userCred, query, objs, fields, isList)
+	stackIds := make([]string, len(objs))
+	for i := range rows {
+		rows[i].VirtualResourceDetails = virtRows[i]
+		stackIds[i] = objs[i].(*SStackResource).StackId
+	}
+	stacks := make(map[string]SStack)
+	err := db.FetchStandaloneObjectsByIds(StackManager, stackIds, &stacks)
+	if err != nil {
+		return rows
+	}
+	for i := range rows {
+		if stack, ok := stacks[stackIds[i]]; ok {
+			rows[i].Stack = stack.Name
+		}
+	}
+	return rows
+}
+
+func (res *SStackResource) ValidateDeleteCondition(ctx context.Context, info jsonutils.JSONObject) error {
+	return httperrors.NewForbiddenError("stack resource can only be deleted by applying or deleting stack")
+}
+
+func (res *SStackResource) ValidateUpdateData(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	return nil, httperrors.NewNotSupportedError("stack resource can only be updated by applying stack")
+}
+
+func (res *SStackResource) RealDelete(ctx context.Context, userCred mcclient.TokenCredential) error {
+	return res.SVirtualResourceBase.Delete(ctx, userCred)
+}
+
+func (res *SStackResource) toState() *engine.SResourceState {
+	st := &engine.SResourceState{
+		Name:       res.Name,
+		Type:       res.ResourceType,
+		ExternalId: res.ExternalId,
+		Properties: res.Properties,
+		Attributes: res.Attributes,
+		DependsOn:  []string{},
+	}
+	if res.DependsOn != nil {
+		res.DependsOn.Unmarshal(&st.DependsOn)
+	}
+	return st
+}
+
+func (res *SStackResource) fromState(st *engine.SResourceState) {
+	res.Name = st.Name
+	res.ResourceType = st.Type
+	res.ExternalId = st.ExternalId
+	res.Properties = st.Properties
+	res.Attributes = st.Attributes
+	res.DependsOn = jsonutils.NewStringArray(st.DependsOn)
+	res.Status = api.STACK_RESOURCE_STATUS_READY
+}
+
+func (manager *SStackResourceManager) createFromState(ctx context.Context, userCred mcclient.TokenCredential, stack *SStack, st *engine.SResourceState) error {
+	res := &SStackResource{}
+	res.SetModelManager(manager, res)
+	res.fromState(st)
+	res.StackId = stack.Id
+	res.ProjectId = stack.ProjectId
+	res.DomainId = stack.DomainId
+	res.DriftStatus = api.STACK_DRIFT_STATUS_IN_SYNC
+	return manager.TableSpec().Insert(ctx, res)
+}
+
+func (res *SStackResource) setDrift(drift api.StackResourceDrift) error {
+	_, err := db.Update(res, func() error {
+		res.DriftStatus = drift.DriftStatus
+		res.Drift = jsonutils.Marshal(drift.Differences).(*jsonutils.JSONArray)
+		return nil
+	})
+	return err
+}
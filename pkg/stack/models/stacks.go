@@ -0,0 +1,398 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
+	"yunion.io/x/sqlchemy"
+
+	api "yunion.io/x/onecloud/pkg/apis/stack"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
+	"yunion.io/x/onecloud/pkg/stack/engine"
+	"yunion.io/x/onecloud/pkg/util/logclient"
+	"yunion.io/x/onecloud/pkg/util/stringutils2"
+)
+
+type SStackManager struct {
+	db.SVirtualResourceBaseManager
+}
+
+var StackManager *SStackManager
+
+func init() {
+	StackManager = &SStackManager{
+		SVirtualResourceBaseManager: db.NewVirtualResourceBaseManager(
+			SStack{},
+			"stacks_tbl",
+			"stack",
+			"stacks",
+		),
+	}
+	StackManager.SetVirtualObject(StackManager)
+}
+
+type SStack struct {
+	db.SVirtualResourceBase
+
+	// template applied last time
+	Template   string              `length:"medium" charset:"utf8" create:"required" get:"user"`
+	Parameters *jsonutils.JSONDict `length:"long" charset:"utf8" create:"optional" get:"user"`
+	Outputs    *jsonutils.JSONDict `length:"long" charset:"utf8" list:"user"`
+
+	Region string `width:"128" charset:"ascii" create:"optional" list:"user"`
+
+	DriftStatus    string    `width:"16" charset:"ascii" default:"unknown" list:"user"`
+	DriftCheckedAt time.Time `list:"user"`
+}
+
+func (manager *SStackManager) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, input api.StackCreateInput) (api.StackCreateInput, error) {
+	var err error
+	input.VirtualResourceCreateInput, err = manager.SVirtualResourceBaseManager.ValidateCreateData(ctx, userCred, ownerId, query, input.VirtualResourceCreateInput)
+	if err != nil {
+		return input, err
+	}
+	tmpl, err := engine.ParseTemplate(input.Template)
+	if err != nil {
+		return input, err
+	}
+	_, err = engine.ResolveParameters(tmpl, input.Parameters)
+	if err != nil {
+		return input, err
+	}
+	input.Status = api.STACK_STATUS_INIT
+	return input, nil
+}
+
+func (stack *SStack) PostCreate(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, data jsonutils.JSONObject) {
+	stack.SVirtualResourceBase.PostCreate(ctx, userCred, ownerId, query, data)
+	input := api.StackApplyInput{
+		Template:   stack.Template,
+		Parameters: stack.Parameters,
+	}
+	err := stack.StartApplyTask(ctx, userCred, input, "")
+	if err != nil {
+		stack.SetStatus(userCred, api.STACK_STATUS_APPLY_FAILED, err.Error())
+	}
+}
+
+func (manager *SStackManager) ListItemFilter(ctx context.Context, q *sqlchemy.SQuery, userCred mcclient.TokenCredential, input api.StackListInput) (*sqlchemy.SQuery, error) {
+	q, err := manager.SVirtualResourceBaseManager.ListItemFilter(ctx, q, userCred, input.VirtualResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SVirtualResourceBaseManager.ListItemFilter")
+	}
+	if len(input.DriftStatus) > 0 {
+		q = q.In("drift_status", input.DriftStatus)
+	}
+	return q, nil
+}
+
+func (manager *SStackManager) OrderByExtraFields(ctx context.Context, q *sqlchemy.SQuery, userCred mcclient.TokenCredential, input api.StackListInput) (*sqlchemy.SQuery, error) {
+	return manager.SVirtualResourceBaseManager.OrderByExtraFields(ctx, q, userCred, input.VirtualResourceListInput)
+}
+
+func (manager *SStackManager) FetchCustomizeColumns(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	objs []interface{},
+	fields stringutils2.SSortedStrings,
+	isList bool,
+) []api.StackDetails {
+	rows := make([]api.StackDetails, len(objs))
+	virtRows := manager.SVirtualResourceBaseManager.FetchCustomizeColumns(ctx, userCred, query, objs, fields, isList)
+	stackIds := make([]string, len(objs))
+	for i := range rows {
+		rows[i].VirtualResourceDetails = virtRows[i]
+		stackIds[i] = objs[i].(*SStack).Id
+	}
+	counts := struct {
+		StackId string
+		Count   int
+	}{}
+	q := StackResourceManager.Query().In("stack_id", stackIds)
+	q = q.AppendField(q.Field("stack_id"), sqlchemy.COUNT("count"))
+	q = q.GroupBy(q.Field("stack_id"))
+	rs, err := q.Rows()
+	if err != nil {
+		log.Errorf("query stack resource count: %v", err)
+		return rows
+	}
+	defer rs.Close()
+	resourceCount := map[string]int{}
+	for rs.Next() {
+		err = q.Row2Struct(rs, &counts)
+		if err != nil {
+			log.Errorf("fetch stack resource count: %v", err)
+			break
+		}
+		resourceCount[counts.StackId] = counts.Count
+	}
+	for i := range rows {
+		rows[i].ResourceCount = resourceCount[stackIds[i]]
+	}
+	return rows
+}
+
+func (stack *SStack) GetResources() ([]SStackResource, error) {
+	q := StackResourceManager.Query().Equals("stack_id", stack.Id)
+	resources := []SStackResource{}
+	err := db.FetchModelObjects(StackResourceManager, q, &resources)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchModelObjects")
+	}
+	return resources, nil
+}
+
+func (stack *SStack) GetState() (map[string]*engine.SResourceState, error) {
+	resources, err := stack.GetResources()
+	if err != nil {
+		return nil, err
+	}
+	state := map[string]*engine.SResourceState{}
+	for i := range resources {
+		state[resources[i].Name] = resources[i].toState()
+	}
+	return state, nil
+}
+
+// SyncState saves the resources existing after apply, resources no longer
+// existing are removed
+func (stack *SStack) SyncState(ctx context.Context, userCred mcclient.TokenCredential, state map[string]*engine.SResourceState) error {
+	resources, err := stack.GetResources()
+	if err != nil {
+		return err
+	}
+	existing := map[string]*SStackResource{}
+	for i := range resources {
+		res := &resources[i]
+		st, ok := state[res.Name]
+		if !ok || st.ExternalId != res.ExternalId {
+			err = res.RealDelete(ctx, userCred)
+			if err != nil {
+				return errors.Wrapf(err, "delete stack resource %s", res.Name)
+			}
+			continue
+		}
+		existing[res.Name] = res
+	}
+	for name, st := range state {
+		if res, ok := existing[name]; ok {
+			_, err = db.Update(res, func() error {
+				res.fromState(st)
+				return nil
+			})
+			if err != nil {
+				return errors.Wrapf(err, "update stack resource %s", name)
+			}
+			continue
+		}
+		err = StackResourceManager.createFromState(ctx, userCred, stack, st)
+		if err != nil {
+			return errors.Wrapf(err, "create stack resource %s", name)
+		}
+	}
+	return nil
+}
+
+func (stack *SStack) GetSession(ctx context.Context, userCred mcclient.TokenCredential) *mcclient.ClientSession {
+	return auth.GetSession(ctx, userCred, stack.Region, "")
+}
+
+// parseTemplateInput falls back to the current template and parameters if
+// the input does not specify them
+func (stack *SStack) parseTemplateInput(input api.StackPreviewInput) (*api.StackTemplate, *jsonutils.JSONDict, error) {
+	content := input.Template
+	if len(content) == 0 {
+		content = stack.Template
+	}
+	params := input.Parameters
+	if params == nil {
+		params = stack.Parameters
+	}
+	tmpl, err := engine.ParseTemplate(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	resolved, err := engine.ResolveParameters(tmpl, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tmpl, resolved, nil
+}
+
+// 预览变更
+func (stack *SStack) PerformPreview(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.StackPreviewInput) (*api.StackChangeSet, error) {
+	tmpl, params, err := stack.parseTemplateInput(input)
+	if err != nil {
+		return nil, err
+	}
+	state, err := stack.GetState()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetState")
+	}
+	return engine.Plan(tmpl, params, state)
+}
+
+// 应用模板
+func (stack *SStack) PerformApply(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.StackApplyInput) (jsonutils.JSONObject, error) {
+	if !utils.IsInStringArray(stack.Status, []string{api.STACK_STATUS_READY, api.STACK_STATUS_APPLY_FAILED, api.STACK_STATUS_ROLLBACK_FAILED}) {
+		return nil, httperrors.NewInvalidStatusError("cannot apply stack in status %s", stack.Status)
+	}
+	if len(input.Template) == 0 {
+		input.Template = stack.Template
+	}
+	if input.Parameters == nil {
+		input.Parameters = stack.Parameters
+	}
+	_, _, err := stack.parseTemplateInput(api.StackPreviewInput(input))
+	if err != nil {
+		return nil, err
+	}
+	return nil, stack.StartApplyTask(ctx, userCred, input, "")
+}
+
+func (stack *SStack) StartApplyTask(ctx context.Context, userCred mcclient.TokenCredential, input api.StackApplyInput, parentTaskId string) error {
+	stack.SetStatus(userCred, api.STACK_STATUS_APPLYING, "")
+	task, err := taskman.TaskManager.NewTask(ctx, "StackApplyTask", stack, userCred, jsonutils.Marshal(input).(*jsonutils.JSONDict), parentTaskId, "")
+	if err != nil {
+		return errors.Wrap(err, "NewTask")
+	}
+	task.ScheduleRun(nil)
+	return nil
+}
+
+// Apply runs the template against the resources of the stack and saves the
+// resulting state, rollback is done by the engine on failure and the stack
+// is marked rollback_failed if the rollback fails too
+func (stack *SStack) Apply(ctx context.Context, userCred mcclient.TokenCredential, input api.StackApplyInput) error {
+	tmpl, params, err := stack.parseTemplateInput(api.StackPreviewInput(input))
+	if err != nil {
+		return err
+	}
+	state, err := stack.GetState()
+	if err != nil {
+		return errors.Wrap(err, "GetState")
+	}
+	applier := engine.NewApplier(engine.NewSessionClient(stack.GetSession(ctx, userCred)))
+	result, applyErr := applier.Apply(ctx, tmpl, params, state)
+	err = stack.SyncState(ctx, userCred, result.State)
+	if err != nil {
+		return errors.Wrapf(err, "SyncState, apply error: %v", applyErr)
+	}
+	if applyErr != nil {
+		if errors.Cause(applyErr) == engine.ErrRollbackFailed {
+			stack.SetStatus(userCred, api.STACK_STATUS_ROLLBACK_FAILED, applyErr.Error())
+		}
+		return applyErr
+	}
+	diff, err := db.Update(stack, func() error {
+		stack.Template = input.Template
+		stack.Parameters = input.Parameters
+		stack.Outputs = result.Outputs
+		stack.DriftStatus = api.STACK_DRIFT_STATUS_IN_SYNC
+		stack.DriftCheckedAt = time.Now().UTC()
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "update stack")
+	}
+	db.OpsLog.LogEvent(stack, db.ACT_UPDATE, diff, userCred)
+	return nil
+}
+
+// 检测资源栈中资源实际状态与模板的差异
+func (stack *SStack) PerformDetectDrift(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.StackDetectDriftInput) (*api.StackDriftOutput, error) {
+	resources, err := stack.GetResources()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetResources")
+	}
+	state := map[string]*engine.SResourceState{}
+	for i := range resources {
+		state[resources[i].Name] = resources[i].toState()
+	}
+	client := engine.NewSessionClient(stack.GetSession(ctx, userCred))
+	status, drifts := engine.DetectDrift(ctx, client, state)
+	for i := range drifts {
+		for j := range resources {
+			if resources[j].Name == drifts[i].Name {
+				err = resources[j].setDrift(drifts[i])
+				if err != nil {
+					return nil, errors.Wrapf(err, "update drift of %s", drifts[i].Name)
+				}
+				break
+			}
+		}
+	}
+	_, err = db.Update(stack, func() error {
+		stack.DriftStatus = status
+		stack.DriftCheckedAt = time.Now().UTC()
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "update stack drift status")
+	}
+	logclient.AddSimpleActionLog(stack, logclient.ACT_SYNC_STATUS, status, userCred, true)
+	return &api.StackDriftOutput{DriftStatus: status, Resources: drifts}, nil
+}
+
+func (stack *SStack) ValidateDeleteCondition(ctx context.Context, info jsonutils.JSONObject) error {
+	if utils.IsInStringArray(stack.Status, []string{api.STACK_STATUS_APPLYING, api.STACK_STATUS_DELETING}) {
+		return httperrors.NewInvalidStatusError("cannot delete stack in status %s", stack.Status)
+	}
+	return stack.SVirtualResourceBase.ValidateDeleteCondition(ctx, info)
+}
+
+func (stack *SStack) Delete(ctx context.Context, userCred mcclient.TokenCredential) error {
+	return nil
+}
+
+func (stack *SStack) RealDelete(ctx context.Context, userCred mcclient.TokenCredential) error {
+	return stack.SVirtualResourceBase.Delete(ctx, userCred)
+}
+
+func (stack *SStack) CustomizeDelete(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) error {
+	stack.SetStatus(userCred, api.STACK_STATUS_DELETING, "")
+	task, err := taskman.TaskManager.NewTask(ctx, "StackDeleteTask", stack, userCred, nil, "", "")
+	if err != nil {
+		return errors.Wrap(err, "NewTask")
+	}
+	task.ScheduleRun(nil)
+	return nil
+}
+
+// Destroy deletes all resources of the stack in reverse dependency order
+func (stack *SStack) Destroy(ctx context.Context, userCred mcclient.TokenCredential) error {
+	state, err := stack.GetState()
+	if err != nil {
+		return errors.Wrap(err, "GetState")
+	}
+	applier := engine.NewApplier(engine.NewSessionClient(stack.GetSession(ctx, userCred)))
+	remain, destroyErr := applier.Destroy(ctx, state)
+	err = stack.SyncState(ctx, userCred, remain)
+	if err != nil {
+		return errors.Wrapf(err, "SyncState, destroy error: %v", destroyErr)
+	}
+	return destroyErr
+}
@@ -0,0 +1,61 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"os"
+
+	"yunion.io/x/log"
+	_ "yunion.io/x/sqlchemy/backends"
+
+	api "yunion.io/x/onecloud/pkg/apis/stack"
+	"yunion.io/x/onecloud/pkg/cloudcommon"
+	"yunion.io/x/onecloud/pkg/cloudcommon/app"
+	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
+	"yunion.io/x/onecloud/pkg/stack/options"
+	"yunion.io/x/onecloud/pkg/stack/tasks"
+)
+
+func StartService() {
+	opts := &options.Options
+	commonOpts := &options.Options.CommonOptions
+	dbOpts := &options.Options.DBOptions
+	baseOpts := &options.Options.BaseOptions
+	common_options.ParseOptions(opts, os.Args, "stack.conf", api.SERVICE_TYPE)
+
+	app.InitAuth(commonOpts, func() {
+		log.Infof("Auth complete!")
+	})
+
+	application := app.InitApp(baseOpts, true)
+
+	cloudcommon.InitDB(dbOpts)
+
+	InitHandlers(application)
+	tasks.InitStackWorkers(opts.StackWorkerCount)
+
+	db.EnsureAppSyncDB(application, dbOpts, nil)
+	defer cloudcommon.CloseDB()
+
+	cron := cronman.InitCronJobManager(true, opts.CronJobWorkerCount)
+	taskman.AddTaskCronJobs(cron, dbOpts)
+	cron.Start()
+	defer cron.Stop()
+
+	app.ServeForever(application, baseOpts)
+}
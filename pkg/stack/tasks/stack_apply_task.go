@@ -0,0 +1,79 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/stack"
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/stack/models"
+	"yunion.io/x/onecloud/pkg/util/logclient"
+)
+
+type StackApplyTask struct {
+	taskman.STask
+}
+
+// stackLocalTaskWorkerMan runs apply and destroy of stacks, which wait for
+// every resource to be ready and may take long
+var stackLocalTaskWorkerMan *appsrv.SWorkerManager
+
+func InitStackWorkers(count int) {
+	stackLocalTaskWorkerMan = appsrv.NewWorkerManager("StackLocalTaskWorkerManager", count, 512, false)
+}
+
+func init() {
+	taskman.RegisterTask(StackApplyTask{})
+}
+
+func (self *StackApplyTask) taskFailed(ctx context.Context, stack *models.SStack, err error) {
+	// rollback failure is recorded on stack by Apply, as failure callback
+	// only gets message of the error
+	if stack.Status != api.STACK_STATUS_ROLLBACK_FAILED {
+		stack.SetStatus(self.UserCred, api.STACK_STATUS_APPLY_FAILED, err.Error())
+	}
+	logclient.AddActionLogWithStartable(self, stack, logclient.ACT_UPDATE, err, self.UserCred, false)
+	self.SetStageFailed(ctx, jsonutils.NewString(err.Error()))
+}
+
+func (self *StackApplyTask) OnInit(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
+	stack := obj.(*models.SStack)
+	input := api.StackApplyInput{}
+	err := self.GetParams().Unmarshal(&input)
+	if err != nil {
+		self.taskFailed(ctx, stack, errors.Wrap(err, "Unmarshal"))
+		return
+	}
+	self.SetStage("OnApplyComplete", nil)
+	taskman.LocalTaskRunWithWorkers(self, func() (jsonutils.JSONObject, error) {
+		return nil, stack.Apply(ctx, self.UserCred, input)
+	}, stackLocalTaskWorkerMan)
+}
+
+func (self *StackApplyTask) OnApplyComplete(ctx context.Context, stack *models.SStack, data jsonutils.JSONObject) {
+	stack.SetStatus(self.UserCred, api.STACK_STATUS_READY, "")
+	logclient.AddActionLogWithStartable(self, stack, logclient.ACT_UPDATE, nil, self.UserCred, true)
+	self.SetStageComplete(ctx, nil)
+}
+
+func (self *StackApplyTask) OnApplyCompleteFailed(ctx context.Context, stack *models.SStack, data jsonutils.JSONObject) {
+	self.taskFailed(ctx, stack, errors.Error(data.String()))
+}
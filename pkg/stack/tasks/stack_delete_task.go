@@ -0,0 +1,59 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"context"
+
+	"yunion.io/x/jsonutils"
+
+	api "yunion.io/x/onecloud/pkg/apis/stack"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/stack/models"
+	"yunion.io/x/onecloud/pkg/util/logclient"
+)
+
+type StackDeleteTask struct {
+	taskman.STask
+}
+
+func init() {
+	taskman.RegisterTask(StackDeleteTask{})
+}
+
+func (self *StackDeleteTask) OnInit(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
+	stack := obj.(*models.SStack)
+	self.SetStage("OnDestroyComplete", nil)
+	taskman.LocalTaskRunWithWorkers(self, func() (jsonutils.JSONObject, error) {
+		return nil, stack.Destroy(ctx, self.UserCred)
+	}, stackLocalTaskWorkerMan)
+}
+
+func (self *StackDeleteTask) OnDestroyComplete(ctx context.Context, stack *models.SStack, data jsonutils.JSONObject) {
+	err := stack.RealDelete(ctx, self.UserCred)
+	if err != nil {
+		self.OnDestroyCompleteFailed(ctx, stack, jsonutils.NewString(err.Error()))
+		return
+	}
+	logclient.AddActionLogWithStartable(self, stack, logclient.ACT_DELETE, nil, self.UserCred, true)
+	self.SetStageComplete(ctx, nil)
+}
+
+func (self *StackDeleteTask) OnDestroyCompleteFailed(ctx context.Context, stack *models.SStack, data jsonutils.JSONObject) {
+	stack.SetStatus(self.UserCred, api.STACK_STATUS_DELETE_FAILED, data.String())
+	logclient.AddActionLogWithStartable(self, stack, logclient.ACT_DELETE, data, self.UserCred, false)
+	self.SetStageFailed(ctx, data)
+}
@@ -0,0 +1,244 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acmeutils
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/util/httputils"
+)
+
+const (
+	LetsEncryptDirectoryUrl = "https://acme-v02.api.letsencrypt.org/directory"
+
+	CHALLENGE_HTTP01 = "http-01"
+	CHALLENGE_DNS01  = "dns-01"
+
+	// RFC 8555 section 8.4, the TXT record holding the dns-01 key authorization digest
+	DNS01RecordPrefix = "_acme-challenge"
+	// RFC 8555 section 8.3, the path serving the http-01 key authorization
+	HTTP01PathPrefix = "/.well-known/acme-challenge/"
+
+	ErrNoChallenge = errors.Error("no supported challenge offered")
+	ErrInvalidPem  = errors.Error("invalid pem block")
+)
+
+// IChallengeSolver publishes and withdraws the response to a challenge of
+// the given type, e.g. by serving it from load balancers or dns zones
+type IChallengeSolver interface {
+	ChallengeType() string
+
+	// Present makes response available for domain before the CA validates it
+	Present(ctx context.Context, domain string, token string, response string) error
+	// CleanUp withdraws the response once the authorization is finalized
+	CleanUp(ctx context.Context, domain string, token string, response string) error
+}
+
+type SAcmeClient struct {
+	client *acme.Client
+}
+
+// NewAcmeClient returns a client of the CA at directoryUrl identified by
+// the account key. insecure skips verification of the CA server
+// certificate, which is useful for local test servers like pebble
+func NewAcmeClient(directoryUrl string, key crypto.Signer, insecure bool) *SAcmeClient {
+	if len(directoryUrl) == 0 {
+		directoryUrl = LetsEncryptDirectoryUrl
+	}
+	return &SAcmeClient{
+		client: &acme.Client{
+			Key:          key,
+			DirectoryURL: directoryUrl,
+			HTTPClient:   httputils.GetClient(insecure, 30*time.Second),
+		},
+	}
+}
+
+// Register creates the account of client key, an existing account is reused
+func (cli *SAcmeClient) Register(ctx context.Context, email string) error {
+	acct := &acme.Account{}
+	if len(email) > 0 {
+		acct.Contact = []string{"mailto:" + email}
+	}
+	_, err := cli.client.Register(ctx, acct, acme.AcceptTOS)
+	if err != nil && errors.Cause(err) != acme.ErrAccountAlreadyExists {
+		return errors.Wrapf(err, "Register")
+	}
+	return nil
+}
+
+func (cli *SAcmeClient) challengeResponse(typ string, token string) (string, error) {
+	switch typ {
+	case CHALLENGE_HTTP01:
+		return cli.client.HTTP01ChallengeResponse(token)
+	case CHALLENGE_DNS01:
+		return cli.client.DNS01ChallengeRecord(token)
+	}
+	return "", errors.Wrapf(ErrNoChallenge, "%s", typ)
+}
+
+type sPresented struct {
+	domain   string
+	token    string
+	response string
+}
+
+// ObtainCertificate orders a certificate of domains, answering the
+// challenges with solver. wait is the time for presented responses to
+// propagate before the CA is asked to validate them. The PEM encoded
+// certificate chain and the newly generated private key are returned
+func (cli *SAcmeClient) ObtainCertificate(ctx context.Context, domains []string, solver IChallengeSolver, wait time.Duration) (string, string, error) {
+	order, err := cli.client.AuthorizeOrder(ctx, acme.DomainIDs(domains...))
+	if err != nil {
+		return "", "", errors.Wrapf(err, "AuthorizeOrder")
+	}
+
+	presented := []sPresented{}
+	defer func() {
+		for _, p := range presented {
+			err := solver.CleanUp(ctx, p.domain, p.token, p.response)
+			if err != nil {
+				log.Errorf("clean up %s challenge of %s error: %v", solver.ChallengeType(), p.domain, err)
+			}
+		}
+	}()
+
+	challenges := map[string]*acme.Challenge{}
+	for _, authzUrl := range order.AuthzURLs {
+		authz, err := cli.client.GetAuthorization(ctx, authzUrl)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "GetAuthorization %s", authzUrl)
+		}
+		if authz.Status == acme.StatusValid {
+			continue
+		}
+		var chal *acme.Challenge
+		for _, c := range authz.Challenges {
+			if c.Type == solver.ChallengeType() {
+				chal = c
+				break
+			}
+		}
+		if chal == nil {
+			return "", "", errors.Wrapf(ErrNoChallenge, "%s for %s", solver.ChallengeType(), authz.Identifier.Value)
+		}
+		resp, err := cli.challengeResponse(chal.Type, chal.Token)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "challengeResponse")
+		}
+		err = solver.Present(ctx, authz.Identifier.Value, chal.Token, resp)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "Present %s challenge of %s", chal.Type, authz.Identifier.Value)
+		}
+		presented = append(presented, sPresented{domain: authz.Identifier.Value, token: chal.Token, response: resp})
+		challenges[authzUrl] = chal
+	}
+
+	if len(challenges) > 0 && wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return "", "", ctx.Err()
+		}
+	}
+
+	for authzUrl, chal := range challenges {
+		_, err := cli.client.Accept(ctx, chal)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "Accept %s", chal.URI)
+		}
+		_, err = cli.client.WaitAuthorization(ctx, authzUrl)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "WaitAuthorization %s", authzUrl)
+		}
+	}
+
+	orderUri := order.URI
+	order, err = cli.client.WaitOrder(ctx, orderUri)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "WaitOrder %s", orderUri)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "GenerateKey")
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domains[0]},
+		DNSNames: domains,
+	}, key)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "CreateCertificateRequest")
+	}
+	ders, _, err := cli.client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "CreateOrderCert")
+	}
+
+	certs := strings.Builder{}
+	for _, der := range ders {
+		certs.Write(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	}
+	keyPem, err := MarshalPrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	return certs.String(), keyPem, nil
+}
+
+// DNS01RecordName returns the name of TXT record for the dns-01 challenge of domain
+func DNS01RecordName(domain string) string {
+	return fmt.Sprintf("%s.%s", DNS01RecordPrefix, strings.TrimPrefix(domain, "*."))
+}
+
+// GenerateAccountKey returns a new ECDSA P-256 account key
+func GenerateAccountKey() (*ecdsa.PrivateKey, error) {
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+func MarshalPrivateKey(key *ecdsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", errors.Wrapf(err, "MarshalECPrivateKey")
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})), nil
+}
+
+func ParsePrivateKey(keyPem string) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(keyPem))
+	if block == nil {
+		return nil, ErrInvalidPem
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ParseECPrivateKey")
+	}
+	return key, nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acmeutils
+
+import (
+	"testing"
+)
+
+func TestDNS01RecordName(t *testing.T) {
+	for domain, want := range map[string]string{
+		"example.com":     "_acme-challenge.example.com",
+		"www.example.com": "_acme-challenge.www.example.com",
+		"*.example.com":   "_acme-challenge.example.com",
+	} {
+		if got := DNS01RecordName(domain); got != want {
+			t.Errorf("DNS01RecordName(%s) = %s, want %s", domain, got, want)
+		}
+	}
+}
+
+func TestPrivateKeyMarshal(t *testing.T) {
+	key, err := GenerateAccountKey()
+	if err != nil {
+		t.Fatalf("GenerateAccountKey: %v", err)
+	}
+	keyPem, err := MarshalPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPrivateKey: %v", err)
+	}
+	parsed, err := ParsePrivateKey(keyPem)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey: %v", err)
+	}
+	if !parsed.Equal(key) {
+		t.Errorf("parsed key differs")
+	}
+	if _, err := ParsePrivateKey("garbage"); err != ErrInvalidPem {
+		t.Errorf("ParsePrivateKey garbage: want ErrInvalidPem, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//+build linux
+
+package dhcp
+
+import (
+	"net"
+
+	"github.com/mdlayher/raw"
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+
+	"yunion.io/x/pkg/errors"
+)
+
+type rawSocketConn6 struct {
+	conn *raw.Conn
+}
+
+func newRawSocketConn6(ifi *net.Interface, filter []bpf.RawInstruction) (conn6, error) {
+	conn, err := raw.ListenPacket(ifi, unix.ETH_P_IPV6, &raw.Config{
+		NoCumulativeStats: true,
+		Filter:            filter,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "listen raw packet on %s", ifi.Name)
+	}
+	return &rawSocketConn6{conn: conn}, nil
+}
+
+func (s *rawSocketConn6) Close() error {
+	return s.conn.Close()
+}
+
+func (s *rawSocketConn6) Recv(b []byte) ([]byte, error) {
+	n, _, err := s.conn.ReadFrom(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "read from")
+	}
+	return b[:n], nil
+}
+
+func (s *rawSocketConn6) Send(b []byte, destMac net.HardwareAddr) error {
+	if _, err := s.conn.WriteTo(b, &raw.Addr{HardwareAddr: destMac}); err != nil {
+		return errors.Wrap(err, "send dhcpv6 packet")
+	}
+	return nil
+}
@@ -33,6 +33,7 @@ package dhcp
 
 import (
 	"errors"
+	"net"
 
 	"golang.org/x/net/bpf"
 )
@@ -48,3 +49,7 @@ func NewSnooperConn(addr string) (*Conn, error) {
 func newRawSocketConn(iface string, filter []bpf.RawInstruction, dhcpServerPort uint16) (conn, error) {
 	return nil, errors.New("raw socket Conns not supported on this OS")
 }
+
+func newRawSocketConn6(ifi *net.Interface, filter []bpf.RawInstruction) (conn6, error) {
+	return nil, errors.New("raw socket Conns not supported on this OS")
+}
@@ -0,0 +1,294 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dhcp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/net/bpf"
+)
+
+const (
+	DHCPv6ServerPort = 547
+	DHCPv6ClientPort = 546
+
+	// RFC 3315 section 22.4
+	dhcpv6IANAHeaderLen = 12
+	// RFC 4861 section 4.6.2
+	raPrefixInfoOnLink = 0x80
+	// RFC 4861 section 4.2
+	raFlagManaged = 0x80
+	raFlagOther   = 0x40
+)
+
+var (
+	IPv6AllNodes          = net.ParseIP("ff02::1")
+	IPv6AllDHCPServers    = net.ParseIP("ff02::1:2")
+	IPv6AllNodesMulticast = net.HardwareAddr{0x33, 0x33, 0x00, 0x00, 0x00, 0x01}
+)
+
+// DHCPv6ResponseConfig is the address and options offered to a DHCPv6 client
+type DHCPv6ResponseConfig struct {
+	ClientIP   net.IP
+	DNSServers []net.IP
+	Domain     string
+
+	PreferredLifetime time.Duration
+	ValidLifetime     time.Duration
+}
+
+// RouterAdvertConfig describes the router advertisement sent in response to
+// a router solicitation
+type RouterAdvertConfig struct {
+	Prefix    net.IP
+	PrefixLen uint8
+
+	// Managed tells the client to acquire address by DHCPv6
+	Managed bool
+	// Other tells the client to acquire other configuration by DHCPv6
+	Other bool
+
+	// RouterLifetime of 0 means the sender is not a default router
+	RouterLifetime time.Duration
+	MTU            uint32
+
+	SourceMac net.HardwareAddr
+}
+
+// NewDHCPv6ServerDUID returns a DUID-LL generated from the hardware address
+func NewDHCPv6ServerDUID(mac net.HardwareAddr) *layers.DHCPv6DUID {
+	return &layers.DHCPv6DUID{
+		Type:             layers.DHCPv6DUIDTypeLL,
+		HardwareType:     []byte{0, 1},
+		LinkLayerAddress: mac,
+	}
+}
+
+func getDHCPv6Option(pkt *layers.DHCPv6, code layers.DHCPv6Opt) (layers.DHCPv6Option, bool) {
+	for _, opt := range pkt.Options {
+		if opt.Code == code {
+			return opt, true
+		}
+	}
+	return layers.DHCPv6Option{}, false
+}
+
+func dhcpv6StatusOption(code layers.DHCPv6StatusCode, msg string) layers.DHCPv6Option {
+	data := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(data, uint16(code))
+	copy(data[2:], msg)
+	return layers.NewDHCPv6Option(layers.DHCPv6OptStatusCode, data)
+}
+
+func dhcpv6DomainList(domains []string) []byte {
+	data := make([]byte, 0)
+	for _, domain := range domains {
+		for _, label := range strings.Split(strings.Trim(domain, "."), ".") {
+			if len(label) == 0 {
+				continue
+			}
+			data = append(data, byte(len(label)))
+			data = append(data, label...)
+		}
+		data = append(data, 0)
+	}
+	return data
+}
+
+func dhcpv6IANAOption(iaid []byte, conf *DHCPv6ResponseConfig) layers.DHCPv6Option {
+	var (
+		preferred = uint32(conf.PreferredLifetime / time.Second)
+		valid     = uint32(conf.ValidLifetime / time.Second)
+	)
+	addr := make([]byte, 24)
+	copy(addr, conf.ClientIP.To16())
+	binary.BigEndian.PutUint32(addr[16:20], preferred)
+	binary.BigEndian.PutUint32(addr[20:24], valid)
+	addrOpt := layers.NewDHCPv6Option(layers.DHCPv6OptIAAddr, addr)
+
+	data := make([]byte, dhcpv6IANAHeaderLen+4+len(addr))
+	copy(data[0:4], iaid)
+	// T1 and T2 as recommended by RFC 3315 section 22.4
+	binary.BigEndian.PutUint32(data[4:8], preferred/2)
+	binary.BigEndian.PutUint32(data[8:12], preferred/5*4)
+	binary.BigEndian.PutUint16(data[12:14], uint16(addrOpt.Code))
+	binary.BigEndian.PutUint16(data[14:16], addrOpt.Length)
+	copy(data[16:], addrOpt.Data)
+	return layers.NewDHCPv6Option(layers.DHCPv6OptIANA, data)
+}
+
+// MakeDHCPv6ReplyPacket builds the Advertise or Reply message for a DHCPv6
+// request. A nil packet is returned when the request should be ignored
+func MakeDHCPv6ReplyPacket(req *layers.DHCPv6, serverDUID *layers.DHCPv6DUID, conf *DHCPv6ResponseConfig) (*layers.DHCPv6, error) {
+	clientId, ok := getDHCPv6Option(req, layers.DHCPv6OptClientID)
+	if !ok {
+		return nil, fmt.Errorf("dhcpv6 %s without client id", req.MsgType)
+	}
+	serverIdData := serverDUID.Encode()
+	if serverId, ok := getDHCPv6Option(req, layers.DHCPv6OptServerID); ok {
+		if string(serverId.Data) != string(serverIdData) {
+			// destined to other server
+			return nil, nil
+		}
+	}
+
+	resp := &layers.DHCPv6{
+		MsgType:       layers.DHCPv6MsgTypeReply,
+		TransactionID: req.TransactionID,
+	}
+	resp.Options = append(resp.Options,
+		layers.NewDHCPv6Option(layers.DHCPv6OptClientID, clientId.Data),
+		layers.NewDHCPv6Option(layers.DHCPv6OptServerID, serverIdData),
+	)
+
+	withAddr := false
+	switch req.MsgType {
+	case layers.DHCPv6MsgTypeSolicit:
+		if _, ok := getDHCPv6Option(req, layers.DHCPv6OptRapidCommit); ok {
+			resp.Options = append(resp.Options, layers.NewDHCPv6Option(layers.DHCPv6OptRapidCommit, nil))
+		} else {
+			resp.MsgType = layers.DHCPv6MsgTypeAdverstise
+		}
+		withAddr = true
+	case layers.DHCPv6MsgTypeRequest, layers.DHCPv6MsgTypeRenew, layers.DHCPv6MsgTypeRebind:
+		withAddr = true
+	case layers.DHCPv6MsgTypeConfirm, layers.DHCPv6MsgTypeRelease, layers.DHCPv6MsgTypeDecline:
+		resp.Options = append(resp.Options, dhcpv6StatusOption(0, "success"))
+		return resp, nil
+	case layers.DHCPv6MsgTypeInformationRequest:
+	default:
+		return nil, nil
+	}
+
+	if withAddr {
+		iana, ok := getDHCPv6Option(req, layers.DHCPv6OptIANA)
+		if !ok || len(iana.Data) < dhcpv6IANAHeaderLen {
+			// only stateful address assignment is supported
+			resp.Options = append(resp.Options, dhcpv6StatusOption(2, "no address available"))
+			return resp, nil
+		}
+		if conf.ClientIP.To16() == nil {
+			return nil, fmt.Errorf("invalid client ipv6 address %s", conf.ClientIP)
+		}
+		resp.Options = append(resp.Options, dhcpv6IANAOption(iana.Data[0:4], conf))
+	}
+
+	if len(conf.DNSServers) > 0 {
+		data := make([]byte, 0, 16*len(conf.DNSServers))
+		for _, dns := range conf.DNSServers {
+			data = append(data, dns.To16()...)
+		}
+		resp.Options = append(resp.Options, layers.NewDHCPv6Option(layers.DHCPv6OptDNSServers, data))
+	}
+	if len(conf.Domain) > 0 {
+		resp.Options = append(resp.Options, layers.NewDHCPv6Option(layers.DHCPv6OptDomainList, dhcpv6DomainList([]string{conf.Domain})))
+	}
+	return resp, nil
+}
+
+// MakeRouterAdvertisement builds the ICMPv6 layers of a router advertisement
+func MakeRouterAdvertisement(conf *RouterAdvertConfig) (*layers.ICMPv6, *layers.ICMPv6RouterAdvertisement) {
+	ra := &layers.ICMPv6RouterAdvertisement{
+		HopLimit:       64,
+		RouterLifetime: uint16(conf.RouterLifetime / time.Second),
+	}
+	if conf.Managed {
+		ra.Flags |= raFlagManaged
+	}
+	if conf.Other {
+		ra.Flags |= raFlagOther
+	}
+	if conf.Prefix != nil {
+		data := make([]byte, 30)
+		data[0] = conf.PrefixLen
+		data[1] = raPrefixInfoOnLink
+		// valid and preferred lifetime of infinity
+		binary.BigEndian.PutUint32(data[2:6], 0xffffffff)
+		binary.BigEndian.PutUint32(data[6:10], 0xffffffff)
+		copy(data[14:30], conf.Prefix.Mask(net.CIDRMask(int(conf.PrefixLen), 128)).To16())
+		ra.Options = append(ra.Options, layers.ICMPv6Option{Type: layers.ICMPv6OptPrefixInfo, Data: data})
+	}
+	if conf.MTU > 0 {
+		data := make([]byte, 6)
+		binary.BigEndian.PutUint32(data[2:6], conf.MTU)
+		ra.Options = append(ra.Options, layers.ICMPv6Option{Type: layers.ICMPv6OptMTU, Data: data})
+	}
+	if len(conf.SourceMac) > 0 {
+		ra.Options = append(ra.Options, layers.ICMPv6Option{Type: layers.ICMPv6OptSourceAddress, Data: conf.SourceMac})
+	}
+	icmp := &layers.ICMPv6{
+		TypeCode: layers.CreateICMPv6TypeCode(layers.ICMPv6TypeRouterAdvertisement, 0),
+	}
+	return icmp, ra
+}
+
+// serializeIPv6Frame serializes an ethernet frame carrying an ipv6 packet
+func serializeIPv6Frame(srcMac, dstMac net.HardwareAddr, srcIP, dstIP net.IP, hopLimit uint8, payload ...gopacket.SerializableLayer) ([]byte, error) {
+	eth := &layers.Ethernet{
+		EthernetType: layers.EthernetTypeIPv6,
+		SrcMAC:       srcMac,
+		DstMAC:       dstMac,
+	}
+	ip := &layers.IPv6{
+		Version:  6,
+		HopLimit: hopLimit,
+		SrcIP:    srcIP,
+		DstIP:    dstIP,
+	}
+	switch l := payload[0].(type) {
+	case *layers.UDP:
+		ip.NextHeader = layers.IPProtocolUDP
+		l.SetNetworkLayerForChecksum(ip)
+	case *layers.ICMPv6:
+		ip.NextHeader = layers.IPProtocolICMPv6
+		l.SetNetworkLayerForChecksum(ip)
+	}
+	var (
+		buf  = gopacket.NewSerializeBuffer()
+		opts = gopacket.SerializeOptions{ComputeChecksums: true, FixLengths: true}
+	)
+	if err := gopacket.SerializeLayers(buf, opts, append([]gopacket.SerializableLayer{eth, ip}, payload...)...); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// dhcpv6Filter accepts ipv6 packets destined to udp port 547 and
+// icmpv6 router solicitations
+func dhcpv6Filter() ([]bpf.RawInstruction, error) {
+	return bpf.Assemble([]bpf.Instruction{
+		// ethertype ipv6
+		bpf.LoadAbsolute{Off: 12, Size: 2},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x86dd, SkipFalse: 8},
+		// next header
+		bpf.LoadAbsolute{Off: 20, Size: 1},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: uint32(layers.IPProtocolUDP), SkipFalse: 2},
+		// udp dport
+		bpf.LoadAbsolute{Off: 56, Size: 2},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: DHCPv6ServerPort, SkipTrue: 3, SkipFalse: 4},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: uint32(layers.IPProtocolICMPv6), SkipFalse: 3},
+		// icmpv6 type
+		bpf.LoadAbsolute{Off: 54, Size: 1},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: layers.ICMPv6TypeRouterSolicitation, SkipFalse: 1},
+		bpf.RetConstant{Val: 0x40000},
+		bpf.RetConstant{Val: 0},
+	})
+}
@@ -0,0 +1,165 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dhcp
+
+import (
+	"net"
+	"runtime/debug"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+)
+
+type conn6 interface {
+	Close() error
+	Recv(b []byte) ([]byte, error)
+	Send(b []byte, destMac net.HardwareAddr) error
+}
+
+// DHCP6Handler resolves the configuration for the client of the given mac
+// address. Returning nil config means the request is ignored
+type DHCP6Handler interface {
+	ServeDHCPv6(pkt *layers.DHCPv6, srcMac net.HardwareAddr) (*DHCPv6ResponseConfig, error)
+	ServeRouterSolicitation(srcMac net.HardwareAddr) (*RouterAdvertConfig, error)
+}
+
+// DHCP6Server answers DHCPv6 and router solicitation on an interface with
+// raw socket, so that it can coexist with the DHCPv4 server
+type DHCP6Server struct {
+	iface *net.Interface
+	ip    net.IP
+	duid  *layers.DHCPv6DUID
+	conn  conn6
+}
+
+func NewDHCP6Server(iface string) (*DHCP6Server, error) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, errors.Wrapf(err, "interface by name %s", iface)
+	}
+	filter, err := dhcpv6Filter()
+	if err != nil {
+		return nil, errors.Wrap(err, "assemble dhcpv6 filter")
+	}
+	conn, err := newRawSocketConn6(ifi, filter)
+	if err != nil {
+		return nil, errors.Wrap(err, "newRawSocketConn6")
+	}
+	return &DHCP6Server{
+		iface: ifi,
+		ip:    interfaceToIPv6LinkLocal(ifi),
+		duid:  NewDHCPv6ServerDUID(ifi.HardwareAddr),
+		conn:  conn,
+	}, nil
+}
+
+func (s *DHCP6Server) ListenAndServe(handler DHCP6Handler) error {
+	defer s.conn.Close()
+	buf := make([]byte, 1500)
+	for {
+		b, err := s.conn.Recv(buf)
+		if err != nil {
+			log.Errorf("Receiving DHCPv6 packet: %s", err)
+			continue
+		}
+		pkt := gopacket.NewPacket(append([]byte{}, b...), layers.LayerTypeEthernet, gopacket.Default)
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Errorf("Serve panic error: %v", r)
+					debug.PrintStack()
+				}
+			}()
+			if err := s.serve(pkt, handler); err != nil {
+				log.Warningf("[DHCPv6] handler serve error: %v", err)
+			}
+		}()
+	}
+}
+
+func (s *DHCP6Server) serve(pkt gopacket.Packet, handler DHCP6Handler) error {
+	ethLayer, ipLayer := pkt.Layer(layers.LayerTypeEthernet), pkt.Layer(layers.LayerTypeIPv6)
+	if ethLayer == nil || ipLayer == nil {
+		return nil
+	}
+	var (
+		eth   = ethLayer.(*layers.Ethernet)
+		ip6   = ipLayer.(*layers.IPv6)
+		dstIP = ip6.SrcIP
+	)
+	if dstIP.IsUnspecified() {
+		dstIP = IPv6AllNodes
+	}
+
+	if l := pkt.Layer(layers.LayerTypeDHCPv6); l != nil {
+		req := l.(*layers.DHCPv6)
+		conf, err := handler.ServeDHCPv6(req, eth.SrcMAC)
+		if err != nil || conf == nil {
+			return err
+		}
+		resp, err := MakeDHCPv6ReplyPacket(req, s.duid, conf)
+		if err != nil || resp == nil {
+			return err
+		}
+		udp := &layers.UDP{
+			SrcPort: DHCPv6ServerPort,
+			DstPort: DHCPv6ClientPort,
+		}
+		b, err := serializeIPv6Frame(s.iface.HardwareAddr, eth.SrcMAC, s.ip, dstIP, 64, udp, resp)
+		if err != nil {
+			return errors.Wrap(err, "serialize dhcpv6 reply")
+		}
+		log.Infof("Make DHCPv6 %s %s TO %s", resp.MsgType, conf.ClientIP, eth.SrcMAC)
+		return s.conn.Send(b, eth.SrcMAC)
+	}
+
+	if pkt.Layer(layers.LayerTypeICMPv6RouterSolicitation) != nil {
+		conf, err := handler.ServeRouterSolicitation(eth.SrcMAC)
+		if err != nil || conf == nil {
+			return err
+		}
+		if len(conf.SourceMac) == 0 {
+			conf.SourceMac = s.iface.HardwareAddr
+		}
+		icmp, ra := MakeRouterAdvertisement(conf)
+		// neighbor discovery messages must have hop limit of 255
+		b, err := serializeIPv6Frame(s.iface.HardwareAddr, eth.SrcMAC, s.ip, dstIP, 255, icmp, ra)
+		if err != nil {
+			return errors.Wrap(err, "serialize router advertisement")
+		}
+		return s.conn.Send(b, eth.SrcMAC)
+	}
+	return nil
+}
+
+func interfaceToIPv6LinkLocal(ifi *net.Interface) net.IP {
+	if addrs, err := ifi.Addrs(); err == nil {
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() == nil && ipnet.IP.IsLinkLocalUnicast() {
+				return ipnet.IP
+			}
+		}
+	}
+	// derive from hardware address by modified EUI-64
+	mac := ifi.HardwareAddr
+	ip := net.ParseIP("fe80::")
+	if len(mac) == 6 {
+		copy(ip[8:], []byte{mac[0] ^ 0x02, mac[1], mac[2], 0xff, 0xfe, mac[3], mac[4], mac[5]})
+	}
+	return ip
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dhcp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/net/bpf"
+)
+
+func TestMakeDHCPv6ReplyPacket(t *testing.T) {
+	clientMac, _ := net.ParseMAC("00:22:0d:00:11:22")
+	serverMac, _ := net.ParseMAC("00:22:0d:00:00:01")
+	clientId := NewDHCPv6ServerDUID(clientMac).Encode()
+	conf := &DHCPv6ResponseConfig{
+		ClientIP:          net.ParseIP("fd00::10"),
+		DNSServers:        []net.IP{net.ParseIP("fd00::53")},
+		Domain:            "cloud.local",
+		PreferredLifetime: time.Hour,
+		ValidLifetime:     2 * time.Hour,
+	}
+	req := &layers.DHCPv6{
+		MsgType:       layers.DHCPv6MsgTypeSolicit,
+		TransactionID: []byte{1, 2, 3},
+		Options: layers.DHCPv6Options{
+			layers.NewDHCPv6Option(layers.DHCPv6OptClientID, clientId),
+			layers.NewDHCPv6Option(layers.DHCPv6OptIANA, []byte{0, 0, 0, 9, 0, 0, 0, 0, 0, 0, 0, 0}),
+		},
+	}
+	serverDUID := NewDHCPv6ServerDUID(serverMac)
+	resp, err := MakeDHCPv6ReplyPacket(req, serverDUID, conf)
+	if err != nil {
+		t.Fatalf("MakeDHCPv6ReplyPacket: %v", err)
+	}
+	if resp.MsgType != layers.DHCPv6MsgTypeAdverstise {
+		t.Errorf("want advertise, got %s", resp.MsgType)
+	}
+
+	udp := &layers.UDP{SrcPort: DHCPv6ServerPort, DstPort: DHCPv6ClientPort}
+	b, err := serializeIPv6Frame(serverMac, clientMac, net.ParseIP("fe80::1"), net.ParseIP("fe80::2"), 64, udp, resp)
+	if err != nil {
+		t.Fatalf("serialize: %v", err)
+	}
+	pkt := gopacket.NewPacket(b, layers.LayerTypeEthernet, gopacket.Default)
+	l := pkt.Layer(layers.LayerTypeDHCPv6)
+	if l == nil {
+		t.Fatalf("no dhcpv6 layer decoded")
+	}
+	decoded := l.(*layers.DHCPv6)
+	iana, ok := getDHCPv6Option(decoded, layers.DHCPv6OptIANA)
+	if !ok {
+		t.Fatalf("no IA_NA in reply")
+	}
+	if iana.Data[3] != 9 {
+		t.Errorf("IAID not echoed: %v", iana.Data[0:4])
+	}
+	if ip := net.IP(iana.Data[16:32]); !ip.Equal(conf.ClientIP) {
+		t.Errorf("want address %s, got %s", conf.ClientIP, ip)
+	}
+	if domains, _ := getDHCPv6Option(decoded, layers.DHCPv6OptDomainList); string(domains.Data) != "\x05cloud\x05local\x00" {
+		t.Errorf("bad domain list %q", domains.Data)
+	}
+
+	// request to another server is ignored
+	req.MsgType = layers.DHCPv6MsgTypeRequest
+	req.Options = append(req.Options, layers.NewDHCPv6Option(layers.DHCPv6OptServerID, clientId))
+	resp, err = MakeDHCPv6ReplyPacket(req, serverDUID, conf)
+	if err != nil || resp != nil {
+		t.Errorf("request to other server should be ignored, got %v %v", resp, err)
+	}
+}
+
+func TestDHCPv6Filter(t *testing.T) {
+	filter, err := dhcpv6Filter()
+	if err != nil {
+		t.Fatalf("assemble: %v", err)
+	}
+	insts := make([]bpf.Instruction, len(filter))
+	for i := range filter {
+		insts[i] = filter[i].Disassemble()
+	}
+	vm, err := bpf.NewVM(insts)
+	if err != nil {
+		t.Fatalf("new vm: %v", err)
+	}
+	mac, _ := net.ParseMAC("00:22:0d:00:11:22")
+	src, dst := net.ParseIP("fe80::2"), IPv6AllDHCPServers
+	solicit := &layers.DHCPv6{MsgType: layers.DHCPv6MsgTypeSolicit, TransactionID: []byte{1, 2, 3}}
+	rs := &layers.ICMPv6RouterSolicitation{}
+	ra := &RouterAdvertConfig{Managed: true, Prefix: net.ParseIP("fd00::"), PrefixLen: 64}
+	raIcmp, raMsg := MakeRouterAdvertisement(ra)
+
+	cases := []struct {
+		name    string
+		payload []gopacket.SerializableLayer
+		accept  bool
+	}{
+		{"dhcpv6 to server", []gopacket.SerializableLayer{&layers.UDP{SrcPort: 546, DstPort: 547}, solicit}, true},
+		{"dhcpv6 to client", []gopacket.SerializableLayer{&layers.UDP{SrcPort: 547, DstPort: 546}, solicit}, false},
+		{"router solicitation", []gopacket.SerializableLayer{&layers.ICMPv6{TypeCode: layers.CreateICMPv6TypeCode(layers.ICMPv6TypeRouterSolicitation, 0)}, rs}, true},
+		{"router advertisement", []gopacket.SerializableLayer{raIcmp, raMsg}, false},
+	}
+	for _, c := range cases {
+		b, err := serializeIPv6Frame(mac, IPv6AllNodesMulticast, src, dst, 255, c.payload...)
+		if err != nil {
+			t.Fatalf("%s: serialize: %v", c.name, err)
+		}
+		n, err := vm.Run(b)
+		if err != nil {
+			t.Fatalf("%s: run: %v", c.name, err)
+		}
+		if (n > 0) != c.accept {
+			t.Errorf("%s: want accept %v, got %d", c.name, c.accept, n)
+		}
+	}
+}
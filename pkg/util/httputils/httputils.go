@@ -43,6 +43,7 @@ import (
 	"yunion.io/x/pkg/utils"
 
 	"yunion.io/x/onecloud/pkg/appctx"
+	"yunion.io/x/onecloud/pkg/util/tracing"
 )
 
 type THttpMethod string
@@ -50,6 +51,10 @@ type THttpMethod string
 const (
 	USER_AGENT = "yunioncloud-go/201708"
 
+	IDEMPOTENCY_KEY_HEADER = "Idempotency-Key"
+	ETAG_HEADER            = "ETag"
+	IF_MATCH_HEADER        = "If-Match"
+
 	GET    = THttpMethod("GET")
 	HEAD   = THttpMethod("HEAD")
 	POST   = THttpMethod("POST")
@@ -485,13 +490,29 @@ func Request(client sClient, ctx context.Context, method THttpMethod, urlStr str
 	return resp, nil
 }
 
-func requestInternal(client sClient, ctx context.Context, method THttpMethod, urlStr string, header http.Header, body io.Reader, debug bool) (*http.Request, *http.Response, error) {
+func requestInternal(client sClient, ctx context.Context, method THttpMethod, urlStr string, header http.Header, body io.Reader, debug bool) (req *http.Request, resp *http.Response, err error) {
 	if client == nil {
 		client = defaultHttpClient
 	}
 	if header == nil {
 		header = http.Header{}
 	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, span := tracing.StartSpan(ctx, string(method), tracing.SPAN_KIND_CLIENT)
+	span.SetAttribute("http.method", string(method))
+	span.SetAttribute("http.url", urlStr)
+	defer func() {
+		if err != nil {
+			span.SetStatus(tracing.STATUS_ERROR, err.Error())
+		} else if resp != nil {
+			span.SetHttpStatus(resp.StatusCode)
+		}
+		span.End()
+	}()
+	tracing.Inject(ctx, header)
+
 	ctxData := appctx.FetchAppContextData(ctx)
 	var clientTrace *trace.STrace
 	if len(ctxData.ServiceName) > 0 {
@@ -509,7 +530,7 @@ func requestInternal(client sClient, ctx context.Context, method THttpMethod, ur
 	if len(ctxData.RequestId) > 0 {
 		header.Set("X-Request-Id", ctxData.RequestId)
 	}
-	req, err := http.NewRequest(string(method), urlStr, body)
+	req, err = http.NewRequest(string(method), urlStr, body)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -547,7 +568,7 @@ func requestInternal(client sClient, ctx context.Context, method THttpMethod, ur
 			cyan("CURL:", curlCmd, "\n")
 		}
 	}
-	resp, err := client.Do(req)
+	resp, err = client.Do(req)
 	if err != nil {
 		red(err.Error())
 		return req, nil, err
@@ -58,10 +58,12 @@ func NewEthernetConfigMatchMac(macAddr string) *EthernetConfigMatch {
 
 type EthernetConfig struct {
 	DHCP4       bool                 `json:"dhcp4"`
+	DHCP6       bool                 `json:"dhcp6,omitfalse"`
 	Addresses   []string             `json:"addresses"`
 	Match       *EthernetConfigMatch `json:"match"`
 	MacAddress  string               `json:"macaddress"`
 	Gateway4    string               `json:"gateway4"`
+	Gateway6    string               `json:"gateway6"`
 	Routes      []*Route             `json:"routes"`
 	Nameservers *Nameservers         `json:"nameservers"`
 	Mtu         int                  `json:"mtu,omitzero"`
@@ -0,0 +1,129 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netutils2
+
+import (
+	"crypto/rand"
+	"math/big"
+	"net"
+
+	"yunion.io/x/pkg/errors"
+)
+
+const ErrInvalidIPv6Addr = errors.Error("InvalidIPv6Address")
+
+// SIPv6Addr 是以大整数表示的IPv6地址，便于地址范围内的步进和随机选取
+type SIPv6Addr struct {
+	v *big.Int
+}
+
+func NewIPv6Addr(ipstr string) (SIPv6Addr, error) {
+	ip := net.ParseIP(ipstr)
+	if ip == nil || ip.To4() != nil {
+		return SIPv6Addr{}, errors.Wrap(ErrInvalidIPv6Addr, ipstr)
+	}
+	return SIPv6Addr{v: new(big.Int).SetBytes(ip.To16())}, nil
+}
+
+func (addr SIPv6Addr) IP() net.IP {
+	buf := addr.v.Bytes()
+	ip := make(net.IP, net.IPv6len)
+	copy(ip[net.IPv6len-len(buf):], buf)
+	return ip
+}
+
+func (addr SIPv6Addr) String() string {
+	return addr.IP().String()
+}
+
+func (addr SIPv6Addr) Cmp(o SIPv6Addr) int {
+	return addr.v.Cmp(o.v)
+}
+
+func (addr SIPv6Addr) StepUp() SIPv6Addr {
+	return SIPv6Addr{v: new(big.Int).Add(addr.v, big.NewInt(1))}
+}
+
+func (addr SIPv6Addr) StepDown() SIPv6Addr {
+	return SIPv6Addr{v: new(big.Int).Sub(addr.v, big.NewInt(1))}
+}
+
+// NetAddr 返回地址在给定前缀长度下的网络地址
+func (addr SIPv6Addr) NetAddr(masklen int8) SIPv6Addr {
+	mask := net.CIDRMask(int(masklen), 128)
+	return SIPv6Addr{v: new(big.Int).SetBytes(addr.IP().Mask(mask))}
+}
+
+type SIPv6AddrRange struct {
+	start SIPv6Addr
+	end   SIPv6Addr
+}
+
+func NewIPv6AddrRange(start, end SIPv6Addr) SIPv6AddrRange {
+	if start.Cmp(end) > 0 {
+		start, end = end, start
+	}
+	return SIPv6AddrRange{start: start, end: end}
+}
+
+func (r SIPv6AddrRange) StartIp() SIPv6Addr {
+	return r.start
+}
+
+func (r SIPv6AddrRange) EndIp() SIPv6Addr {
+	return r.end
+}
+
+func (r SIPv6AddrRange) Contains(addr SIPv6Addr) bool {
+	return r.start.Cmp(addr) <= 0 && addr.Cmp(r.end) <= 0
+}
+
+// Random 在地址范围内均匀随机选取一个地址
+func (r SIPv6AddrRange) Random() SIPv6Addr {
+	size := new(big.Int).Sub(r.end.v, r.start.v)
+	size.Add(size, big.NewInt(1))
+	n, err := rand.Int(rand.Reader, size)
+	if err != nil {
+		return r.start
+	}
+	return SIPv6Addr{v: n.Add(n, r.start.v)}
+}
+
+// MacToEUI64 按照RFC 4291从MAC地址和/64前缀生成接口地址
+func MacToEUI64(prefix string, mac string) (string, error) {
+	hw, err := ParseMac(mac)
+	if err != nil {
+		return "", err
+	}
+	pref, err := NewIPv6Addr(prefix)
+	if err != nil {
+		return "", err
+	}
+	ip := pref.NetAddr(64).IP()
+	ip[8] = hw[0] ^ 0x02
+	ip[9] = hw[1]
+	ip[10] = hw[2]
+	ip[11] = 0xff
+	ip[12] = 0xfe
+	ip[13] = hw[3]
+	ip[14] = hw[4]
+	ip[15] = hw[5]
+	return ip.String(), nil
+}
+
+// IPv6LinkLocal 返回MAC地址对应的fe80::/64链路本地地址
+func IPv6LinkLocal(mac string) (string, error) {
+	return MacToEUI64("fe80::", mac)
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netutils2
+
+import (
+	"testing"
+)
+
+func TestIPv6AddrRange(t *testing.T) {
+	start, err := NewIPv6Addr("fd00::10")
+	if err != nil {
+		t.Fatalf("parse start: %v", err)
+	}
+	end, _ := NewIPv6Addr("fd00::1:0")
+	r := NewIPv6AddrRange(end, start)
+	if r.StartIp().String() != "fd00::10" {
+		t.Errorf("start want fd00::10 got %s", r.StartIp())
+	}
+	if got := start.StepUp().String(); got != "fd00::11" {
+		t.Errorf("stepup want fd00::11 got %s", got)
+	}
+	if got := end.StepDown().String(); got != "fd00::ffff" {
+		t.Errorf("stepdown want fd00::ffff got %s", got)
+	}
+	if r.Contains(start.StepDown()) || !r.Contains(end) {
+		t.Errorf("contains mismatch")
+	}
+	for i := 0; i < 10; i++ {
+		if ip := r.Random(); !r.Contains(ip) {
+			t.Errorf("random %s out of range", ip)
+		}
+	}
+	if _, err := NewIPv6Addr("10.0.0.1"); err == nil {
+		t.Errorf("ipv4 address should be rejected")
+	}
+}
+
+func TestMacToEUI64(t *testing.T) {
+	cases := []struct {
+		Prefix string
+		Mac    string
+		Want   string
+	}{
+		{"fd00:1:2:3::", "00:22:0d:00:11:22", "fd00:1:2:3:222:dff:fe00:1122"},
+		{"fd00:1:2:3:4::1", "02:22:0d:00:11:22", "fd00:1:2:3:22:dff:fe00:1122"},
+	}
+	for _, c := range cases {
+		got, err := MacToEUI64(c.Prefix, c.Mac)
+		if err != nil {
+			t.Fatalf("MacToEUI64 %s %s: %v", c.Prefix, c.Mac, err)
+		}
+		if got != c.Want {
+			t.Errorf("MacToEUI64 %s %s want %s got %s", c.Prefix, c.Mac, c.Want, got)
+		}
+	}
+	ll, _ := IPv6LinkLocal("00:22:0d:00:11:22")
+	if ll != "fe80::222:dff:fe00:1122" {
+		t.Errorf("link local got %s", ll)
+	}
+}
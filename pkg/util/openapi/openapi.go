@@ -0,0 +1,175 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openapi
+
+const (
+	OPENAPI_VERSION = "3.0.3"
+
+	CONTENT_TYPE_JSON = "application/json"
+)
+
+// SDocument is the subset of the OpenAPI 3 document object that is needed to
+// describe the REST APIs of the services
+type SDocument struct {
+	Openapi    string                `json:"openapi"`
+	Info       SInfo                 `json:"info"`
+	Paths      map[string]*SPathItem `json:"paths"`
+	Components SComponents           `json:"components"`
+}
+
+type SInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+type SComponents struct {
+	Schemas map[string]*SSchema `json:"schemas"`
+}
+
+type SPathItem struct {
+	Get    *SOperation `json:"get"`
+	Put    *SOperation `json:"put"`
+	Post   *SOperation `json:"post"`
+	Delete *SOperation `json:"delete"`
+	Patch  *SOperation `json:"patch"`
+
+	Parameters []*SParameter `json:"parameters"`
+}
+
+type SOperation struct {
+	OperationId string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags"`
+	Parameters  []*SParameter         `json:"parameters"`
+	RequestBody *SRequestBody         `json:"requestBody"`
+	Responses   map[string]*SResponse `json:"responses"`
+}
+
+type SParameter struct {
+	Name     string   `json:"name"`
+	In       string   `json:"in"`
+	Required bool     `json:"required,omitfalse"`
+	Style    string   `json:"style"`
+	Explode  bool     `json:"explode,omitfalse"`
+	Schema   *SSchema `json:"schema"`
+}
+
+type SRequestBody struct {
+	Required bool                   `json:"required,omitfalse"`
+	Content  map[string]*SMediaType `json:"content"`
+}
+
+type SResponse struct {
+	Description string                 `json:"description"`
+	Content     map[string]*SMediaType `json:"content"`
+}
+
+type SMediaType struct {
+	Schema *SSchema `json:"schema"`
+}
+
+type SSchema struct {
+	Ref         string `json:"$ref"`
+	Type        string `json:"type"`
+	Format      string `json:"format"`
+	Description string `json:"description"`
+
+	Items                *SSchema            `json:"items"`
+	Properties           map[string]*SSchema `json:"properties"`
+	AdditionalProperties *SSchema            `json:"additionalProperties"`
+	Enum                 []string            `json:"enum"`
+}
+
+func NewDocument(title, version string) *SDocument {
+	return &SDocument{
+		Openapi: OPENAPI_VERSION,
+		Info: SInfo{
+			Title:   title,
+			Version: version,
+		},
+		Paths: make(map[string]*SPathItem),
+		Components: SComponents{
+			Schemas: make(map[string]*SSchema),
+		},
+	}
+}
+
+// AddPaths merges paths into the document, operations already defined for
+// a path are kept
+func (doc *SDocument) AddPaths(paths map[string]*SPathItem) {
+	for path, item := range paths {
+		exist, ok := doc.Paths[path]
+		if !ok {
+			doc.Paths[path] = item
+			continue
+		}
+		if exist.Get == nil {
+			exist.Get = item.Get
+		}
+		if exist.Put == nil {
+			exist.Put = item.Put
+		}
+		if exist.Post == nil {
+			exist.Post = item.Post
+		}
+		if exist.Delete == nil {
+			exist.Delete = item.Delete
+		}
+		if exist.Patch == nil {
+			exist.Patch = item.Patch
+		}
+	}
+}
+
+// JSONContent returns the content of a request or response body in json
+func JSONContent(schema *SSchema) map[string]*SMediaType {
+	return map[string]*SMediaType{
+		CONTENT_TYPE_JSON: {Schema: schema},
+	}
+}
+
+// PathParameter returns a required parameter of a templated path segment
+func PathParameter(name string) *SParameter {
+	return &SParameter{
+		Name:     name,
+		In:       "path",
+		Required: true,
+		Schema:   &SSchema{Type: "string"},
+	}
+}
+
+// QueryParameters returns a form style parameter expanding the properties
+// of schema into query parameters
+func QueryParameters(name string, schema *SSchema) *SParameter {
+	return &SParameter{
+		Name:    name,
+		In:      "query",
+		Style:   "form",
+		Explode: true,
+		Schema:  schema,
+	}
+}
+
+// WrapSchema returns an object schema with schema as its only property key,
+// as the APIs wrap request and response bodies with the resource keyword
+func WrapSchema(key string, schema *SSchema) *SSchema {
+	return &SSchema{
+		Type: "object",
+		Properties: map[string]*SSchema{
+			key: schema,
+		},
+	}
+}
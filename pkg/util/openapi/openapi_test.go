@@ -0,0 +1,123 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openapi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/tristate"
+)
+
+type STestBase struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type sTestNode struct {
+	STestBase
+
+	Name      string               `json:"name" help:"name of the node"`
+	CpuCount  int                  `json:"cpu_count"`
+	MemSize   int64                `json:"mem_size"`
+	Enabled   *bool                `json:"enabled"`
+	Public    tristate.TriState    `json:"public"`
+	CreatedAt time.Time            `json:"created_at"`
+	Tags      map[string]string    `json:"tags"`
+	Data      []byte               `json:"data"`
+	Extra     jsonutils.JSONObject `json:"extra"`
+	Children  []*sTestNode         `json:"children"`
+	Secret    string               `json:"-"`
+	UserData  string
+}
+
+func TestSchemaOf(t *testing.T) {
+	gen := NewSchemaGenerator()
+	ref := gen.SchemaOf(reflect.TypeOf(&sTestNode{}))
+	if ref.Ref != "#/components/schemas/openapi.sTestNode" {
+		t.Fatalf("unexpected ref %q", ref.Ref)
+	}
+	schema := gen.Schemas()["openapi.sTestNode"]
+	if schema == nil {
+		t.Fatalf("schema not registered")
+	}
+	cases := map[string]string{
+		"id":         "string",
+		"name":       "string",
+		"cpu_count":  "integer",
+		"mem_size":   "integer",
+		"enabled":    "boolean",
+		"public":     "boolean",
+		"created_at": "string",
+		"tags":       "object",
+		"data":       "string",
+		"extra":      "",
+		"children":   "array",
+		"user_data":  "string",
+	}
+	for name, typ := range cases {
+		prop, ok := schema.Properties[name]
+		if !ok {
+			t.Errorf("missing property %s", name)
+			continue
+		}
+		if prop.Type != typ {
+			t.Errorf("property %s: want type %q, got %q", name, typ, prop.Type)
+		}
+	}
+	if len(schema.Properties) != len(cases) {
+		t.Errorf("want %d properties, got %d", len(cases), len(schema.Properties))
+	}
+	if schema.Properties["name"].Description != "name of the node" {
+		t.Errorf("embedded field must not shadow the outer one")
+	}
+	if schema.Properties["children"].Items.Ref != ref.Ref {
+		t.Errorf("recursive type must be referenced, got %#v", schema.Properties["children"].Items)
+	}
+	if schema.Properties["mem_size"].Format != "int64" {
+		t.Errorf("unexpected format of mem_size %q", schema.Properties["mem_size"].Format)
+	}
+}
+
+func TestDocumentMarshal(t *testing.T) {
+	doc := NewDocument("test", "v1")
+	doc.AddPaths(map[string]*SPathItem{
+		"/nodes/{id}": {
+			Parameters: []*SParameter{PathParameter("id")},
+			Get: &SOperation{
+				OperationId: "nodes_get",
+				Responses: map[string]*SResponse{
+					"200": {Description: "OK", Content: JSONContent(WrapSchema("node", &SSchema{Type: "object"}))},
+				},
+			},
+		},
+	})
+	json := jsonutils.Marshal(doc)
+	if v, _ := json.GetString("openapi"); v != OPENAPI_VERSION {
+		t.Errorf("unexpected openapi version %q", v)
+	}
+	params, _ := json.GetArray("paths", "/nodes/{id}", "parameters")
+	if len(params) != 1 {
+		t.Fatalf("unexpected parameters %s", json)
+	}
+	if required, _ := params[0].Bool("required"); !required {
+		t.Errorf("path parameter must be required: %s", params[0])
+	}
+	if _, err := json.Get("paths", "/nodes/{id}", "post"); err == nil {
+		t.Errorf("undefined operation must be omitted")
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openapi
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"sync"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/pkg/tristate"
+	"yunion.io/x/pkg/util/reflectutils"
+)
+
+var (
+	jsonDictType  = reflect.TypeOf(jsonutils.JSONDict{})
+	jsonArrayType = reflect.TypeOf(jsonutils.JSONArray{})
+	triStateType  = reflect.TypeOf(tristate.TriState(""))
+
+	invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+)
+
+// SSchemaGenerator derives schemas of go types as they are marshalled by
+// jsonutils. Named struct types are registered as components and referenced
+type SSchemaGenerator struct {
+	lock    sync.Mutex
+	schemas map[string]*SSchema
+	names   map[reflect.Type]string
+}
+
+func NewSchemaGenerator() *SSchemaGenerator {
+	return &SSchemaGenerator{
+		schemas: make(map[string]*SSchema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// Schemas returns the component schemas registered so far
+func (g *SSchemaGenerator) Schemas() map[string]*SSchema {
+	return g.schemas
+}
+
+// SchemaOf returns the schema of t, nil for types that carry no data
+func (g *SSchemaGenerator) SchemaOf(t reflect.Type) *SSchema {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	return g.schemaOf(t)
+}
+
+func (g *SSchemaGenerator) schemaOf(t reflect.Type) *SSchema {
+	if t == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case gotypes.TimeType:
+		return &SSchema{Type: "string", Format: "date-time"}
+	case triStateType:
+		return &SSchema{Type: "boolean"}
+	case jsonDictType:
+		return &SSchema{Type: "object"}
+	case jsonArrayType:
+		return &SSchema{Type: "array", Items: &SSchema{}}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &SSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &SSchema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &SSchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &SSchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &SSchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &SSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &SSchema{Type: "string", Format: "byte"}
+		}
+		return &SSchema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &SSchema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Interface:
+		// jsonutils.JSONObject and other interfaces may hold any json value
+		return &SSchema{}
+	case reflect.Struct:
+		if len(t.Name()) == 0 {
+			return g.structSchema(t)
+		}
+		return &SSchema{Ref: "#/components/schemas/" + g.register(t)}
+	}
+	return nil
+}
+
+func (g *SSchemaGenerator) register(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	base := invalidNameChars.ReplaceAllString(fmt.Sprintf("%s.%s", path.Base(t.PkgPath()), t.Name()), "_")
+	name := base
+	for i := 2; ; i++ {
+		if _, ok := g.schemas[name]; !ok {
+			break
+		}
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	g.names[t] = name
+	// placeholder for recursive types
+	g.schemas[name] = &SSchema{Type: "object"}
+	*g.schemas[name] = *g.structSchema(t)
+	return name
+}
+
+func (g *SSchemaGenerator) structSchema(t reflect.Type) *SSchema {
+	schema := &SSchema{
+		Type:       "object",
+		Properties: make(map[string]*SSchema),
+	}
+	g.collectFields(t, schema, 0, make(map[string]int))
+	return schema
+}
+
+// collectFields flattens embedded structs as jsonutils does, a field shadows
+// fields of the same name that are embedded deeper
+func (g *SSchemaGenerator) collectFields(t reflect.Type, schema *SSchema, depth int, depths map[string]int) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !gotypes.IsFieldExportable(sf.Name) {
+			continue
+		}
+		info := reflectutils.ParseStructFieldJsonInfo(sf)
+		if info.Ignore {
+			continue
+		}
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && ft.Kind() == reflect.Struct && ft != gotypes.TimeType {
+			g.collectFields(ft, schema, depth+1, depths)
+			continue
+		}
+		name := info.MarshalName()
+		if d, ok := depths[name]; ok && d <= depth {
+			continue
+		}
+		var prop *SSchema
+		if info.ForceString {
+			prop = &SSchema{Type: "string"}
+		} else {
+			prop = g.schemaOf(sf.Type)
+		}
+		if prop == nil {
+			continue
+		}
+		if help, ok := info.Tags["help"]; ok && len(prop.Ref) == 0 {
+			prop.Description = help
+		}
+		depths[name] = depth
+		schema.Properties[name] = prop
+	}
+}
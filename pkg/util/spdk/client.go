@@ -0,0 +1,238 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+)
+
+const (
+	DEFAULT_RPC_TIMEOUT = 60 * time.Second
+
+	// errno returned by spdk when the bdev, lvstore or controller does not exist
+	errCodeNoDevice = -19
+	errCodeNoEntry  = -2
+)
+
+type sRequest struct {
+	Version string      `json:"jsonrpc"`
+	Id      int64       `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type sResponse struct {
+	Version string          `json:"jsonrpc"`
+	Id      int64           `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *RPCError       `json:"error"`
+}
+
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("spdk rpc error %d: %s", e.Code, e.Message)
+}
+
+// SClient talks to spdk target through its JSON-RPC 2.0 unix socket
+type SClient struct {
+	socket  string
+	timeout time.Duration
+	id      int64
+}
+
+func NewClient(socket string) *SClient {
+	return &SClient{
+		socket:  socket,
+		timeout: DEFAULT_RPC_TIMEOUT,
+	}
+}
+
+func (cli *SClient) SetTimeout(timeout time.Duration) *SClient {
+	cli.timeout = timeout
+	return cli
+}
+
+func (cli *SClient) call(method string, params interface{}, result interface{}) error {
+	conn, err := net.DialTimeout("unix", cli.socket, cli.timeout)
+	if err != nil {
+		return errors.Wrapf(err, "dial %s", cli.socket)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(cli.timeout))
+
+	req := sRequest{
+		Version: "2.0",
+		Id:      atomic.AddInt64(&cli.id, 1),
+		Method:  method,
+		Params:  params,
+	}
+	if err := json.NewEncoder(conn).Encode(&req); err != nil {
+		return errors.Wrapf(err, "send %s", method)
+	}
+	resp := sResponse{}
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return errors.Wrapf(err, "receive %s", method)
+	}
+	if resp.Id != req.Id {
+		return errors.Errorf("%s response id %d mismatch request id %d", method, resp.Id, req.Id)
+	}
+	if resp.Error != nil {
+		if resp.Error.Code == errCodeNoDevice || resp.Error.Code == errCodeNoEntry {
+			return errors.Wrapf(errors.ErrNotFound, "%s: %s", method, resp.Error.Message)
+		}
+		return errors.Wrap(resp.Error, method)
+	}
+	log.Debugf("spdk rpc %s %s: %s", method, jsonString(params), resp.Result)
+	if result != nil {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return errors.Wrapf(err, "unmarshal %s result %s", method, resp.Result)
+		}
+	}
+	return nil
+}
+
+func jsonString(obj interface{}) string {
+	data, _ := json.Marshal(obj)
+	return string(data)
+}
+
+// LvolName returns the bdev alias of logical volume
+func LvolName(lvstore, lvol string) string {
+	return fmt.Sprintf("%s/%s", lvstore, lvol)
+}
+
+type SLvstore struct {
+	Uuid              string `json:"uuid"`
+	Name              string `json:"name"`
+	BaseBdev          string `json:"base_bdev"`
+	TotalDataClusters int64  `json:"total_data_clusters"`
+	FreeClusters      int64  `json:"free_clusters"`
+	BlockSize         int64  `json:"block_size"`
+	ClusterSize       int64  `json:"cluster_size"`
+}
+
+func (lvs *SLvstore) TotalSizeMb() int64 {
+	return lvs.TotalDataClusters * lvs.ClusterSize / 1024 / 1024
+}
+
+func (lvs *SLvstore) FreeSizeMb() int64 {
+	return lvs.FreeClusters * lvs.ClusterSize / 1024 / 1024
+}
+
+type SBdev struct {
+	Name        string   `json:"name"`
+	Aliases     []string `json:"aliases"`
+	ProductName string   `json:"product_name"`
+	BlockSize   int64    `json:"block_size"`
+	NumBlocks   int64    `json:"num_blocks"`
+}
+
+func (bdev *SBdev) SizeMb() int64 {
+	return bdev.BlockSize * bdev.NumBlocks / 1024 / 1024
+}
+
+type SVhostController struct {
+	Ctrlr  string `json:"ctrlr"`
+	Socket string `json:"socket"`
+}
+
+func (cli *SClient) GetLvstore(name string) (*SLvstore, error) {
+	ret := []SLvstore{}
+	if err := cli.call("bdev_lvol_get_lvstores", map[string]string{"lvs_name": name}, &ret); err != nil {
+		return nil, err
+	}
+	if len(ret) == 0 {
+		return nil, errors.Wrapf(errors.ErrNotFound, "lvstore %s", name)
+	}
+	return &ret[0], nil
+}
+
+func (cli *SClient) GetBdev(name string) (*SBdev, error) {
+	ret := []SBdev{}
+	if err := cli.call("bdev_get_bdevs", map[string]string{"name": name}, &ret); err != nil {
+		return nil, err
+	}
+	if len(ret) == 0 {
+		return nil, errors.Wrapf(errors.ErrNotFound, "bdev %s", name)
+	}
+	return &ret[0], nil
+}
+
+// CreateLvol creates logical volume on lvstore and returns the uuid name of the new bdev
+func (cli *SClient) CreateLvol(lvstore, name string, sizeMb int64, thinProvision bool) (string, error) {
+	params := map[string]interface{}{
+		"lvs_name":       lvstore,
+		"lvol_name":      name,
+		"size_in_mib":    sizeMb,
+		"thin_provision": thinProvision,
+	}
+	var bdev string
+	if err := cli.call("bdev_lvol_create", params, &bdev); err != nil {
+		return "", err
+	}
+	return bdev, nil
+}
+
+func (cli *SClient) ResizeLvol(name string, sizeMb int64) error {
+	return cli.call("bdev_lvol_resize", map[string]interface{}{"name": name, "size_in_mib": sizeMb}, nil)
+}
+
+func (cli *SClient) DeleteLvol(name string) error {
+	return cli.call("bdev_lvol_delete", map[string]string{"name": name}, nil)
+}
+
+func (cli *SClient) GetVhostController(ctrlr string) (*SVhostController, error) {
+	ret := []SVhostController{}
+	if err := cli.call("vhost_get_controllers", map[string]string{"name": ctrlr}, &ret); err != nil {
+		return nil, err
+	}
+	if len(ret) == 0 {
+		return nil, errors.Wrapf(errors.ErrNotFound, "vhost controller %s", ctrlr)
+	}
+	return &ret[0], nil
+}
+
+// CreateVhostBlkController exports bdev as vhost-user-blk socket named ctrlr under the vhost socket dir of spdk target
+func (cli *SClient) CreateVhostBlkController(ctrlr, bdev string) error {
+	return cli.call("vhost_create_blk_controller", map[string]string{"ctrlr": ctrlr, "dev_name": bdev}, nil)
+}
+
+func (cli *SClient) DeleteVhostController(ctrlr string) error {
+	return cli.call("vhost_delete_controller", map[string]string{"ctrlr": ctrlr}, nil)
+}
+
+// StartNbdDisk exports bdev as a free nbd device on host and returns the device path
+func (cli *SClient) StartNbdDisk(bdev string) (string, error) {
+	var dev string
+	if err := cli.call("nbd_start_disk", map[string]string{"bdev_name": bdev}, &dev); err != nil {
+		return "", err
+	}
+	return dev, nil
+}
+
+func (cli *SClient) StopNbdDisk(dev string) error {
+	return cli.call("nbd_stop_disk", map[string]string{"nbd_device": dev}, nil)
+}
@@ -0,0 +1,208 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spdk
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"yunion.io/x/pkg/errors"
+)
+
+// fakeTarget mocks the JSON-RPC server of spdk target with one lvstore
+type fakeTarget struct {
+	lock   sync.Mutex
+	lvols  map[string]int64
+	ctrlrs map[string]string
+}
+
+func (f *fakeTarget) handle(method string, params map[string]interface{}) (interface{}, *RPCError) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	noDevice := &RPCError{Code: errCodeNoDevice, Message: "No such device"}
+	switch method {
+	case "bdev_lvol_get_lvstores":
+		if params["lvs_name"] != "lvs0" {
+			return nil, noDevice
+		}
+		used := int64(0)
+		for _, size := range f.lvols {
+			used += size
+		}
+		return []SLvstore{{Name: "lvs0", BaseBdev: "nvme0n1", TotalDataClusters: 1024, FreeClusters: 1024 - used/4, ClusterSize: 4 * 1024 * 1024}}, nil
+	case "bdev_get_bdevs":
+		name := params["name"].(string)
+		size, ok := f.lvols[name]
+		if !ok {
+			return nil, noDevice
+		}
+		return []SBdev{{Name: "uuid-" + name, Aliases: []string{name}, BlockSize: 512, NumBlocks: size * 2048}}, nil
+	case "bdev_lvol_create":
+		name := LvolName(params["lvs_name"].(string), params["lvol_name"].(string))
+		if _, ok := f.lvols[name]; ok {
+			return nil, &RPCError{Code: -17, Message: "File exists"}
+		}
+		f.lvols[name] = int64(params["size_in_mib"].(float64))
+		return "uuid-" + name, nil
+	case "bdev_lvol_resize", "bdev_lvol_delete":
+		name := params["name"].(string)
+		if _, ok := f.lvols[name]; !ok {
+			return nil, noDevice
+		}
+		if method == "bdev_lvol_delete" {
+			delete(f.lvols, name)
+		} else {
+			f.lvols[name] = int64(params["size_in_mib"].(float64))
+		}
+		return true, nil
+	case "vhost_create_blk_controller":
+		f.ctrlrs[params["ctrlr"].(string)] = params["dev_name"].(string)
+		return true, nil
+	case "vhost_get_controllers":
+		ctrlr := params["name"].(string)
+		if _, ok := f.ctrlrs[ctrlr]; !ok {
+			return nil, noDevice
+		}
+		return []SVhostController{{Ctrlr: ctrlr, Socket: "/var/tmp/" + ctrlr}}, nil
+	case "vhost_delete_controller":
+		delete(f.ctrlrs, params["ctrlr"].(string))
+		return true, nil
+	}
+	return nil, &RPCError{Code: -32601, Message: "Method not found"}
+}
+
+func (f *fakeTarget) serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			req := struct {
+				Id     int64                  `json:"id"`
+				Method string                 `json:"method"`
+				Params map[string]interface{} `json:"params"`
+			}{}
+			if err := json.NewDecoder(conn).Decode(&req); err != nil {
+				return
+			}
+			result, rpcErr := f.handle(req.Method, req.Params)
+			resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.Id}
+			if rpcErr != nil {
+				resp["error"] = rpcErr
+			} else {
+				resp["result"] = result
+			}
+			json.NewEncoder(conn).Encode(resp)
+		}()
+	}
+}
+
+func newFakeClient(t *testing.T) (*SClient, func()) {
+	dir, err := ioutil.TempDir("", "spdk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket := path.Join(dir, "spdk.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	target := &fakeTarget{lvols: map[string]int64{}, ctrlrs: map[string]string{}}
+	go target.serve(l)
+	return NewClient(socket), func() {
+		l.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestLvolLifecycle(t *testing.T) {
+	cli, cleanup := newFakeClient(t)
+	defer cleanup()
+
+	lvs, err := cli.GetLvstore("lvs0")
+	if err != nil {
+		t.Fatalf("GetLvstore: %v", err)
+	}
+	if lvs.TotalSizeMb() != 4096 || lvs.FreeSizeMb() != 4096 {
+		t.Errorf("unexpected lvstore size %d/%d", lvs.FreeSizeMb(), lvs.TotalSizeMb())
+	}
+	if _, err := cli.GetLvstore("lvs1"); errors.Cause(err) != errors.ErrNotFound {
+		t.Errorf("expect not found error of lvs1, got %v", err)
+	}
+
+	name := LvolName("lvs0", "disk1")
+	bdev, err := cli.CreateLvol("lvs0", "disk1", 1024, true)
+	if err != nil {
+		t.Fatalf("CreateLvol: %v", err)
+	}
+	if bdev != "uuid-lvs0/disk1" {
+		t.Errorf("bdev = %q, want uuid-lvs0/disk1", bdev)
+	}
+	if _, err := cli.CreateLvol("lvs0", "disk1", 1024, true); err == nil || errors.Cause(err) == errors.ErrNotFound {
+		t.Errorf("expect rpc error creating duplicate lvol, got %v", err)
+	}
+	if err := cli.ResizeLvol(name, 2048); err != nil {
+		t.Fatalf("ResizeLvol: %v", err)
+	}
+	info, err := cli.GetBdev(name)
+	if err != nil {
+		t.Fatalf("GetBdev: %v", err)
+	}
+	if info.SizeMb() != 2048 {
+		t.Errorf("bdev size = %d, want 2048", info.SizeMb())
+	}
+	if lvs, _ := cli.GetLvstore("lvs0"); lvs.FreeSizeMb() != 2048 {
+		t.Errorf("lvstore free size = %d, want 2048", lvs.FreeSizeMb())
+	}
+	if err := cli.DeleteLvol(name); err != nil {
+		t.Fatalf("DeleteLvol: %v", err)
+	}
+	if _, err := cli.GetBdev(name); errors.Cause(err) != errors.ErrNotFound {
+		t.Errorf("expect not found error after delete, got %v", err)
+	}
+}
+
+func TestVhostController(t *testing.T) {
+	cli, cleanup := newFakeClient(t)
+	defer cleanup()
+
+	if _, err := cli.GetVhostController("vhost-blk-disk1"); errors.Cause(err) != errors.ErrNotFound {
+		t.Errorf("expect not found error, got %v", err)
+	}
+	if err := cli.CreateVhostBlkController("vhost-blk-disk1", "lvs0/disk1"); err != nil {
+		t.Fatalf("CreateVhostBlkController: %v", err)
+	}
+	ctrlr, err := cli.GetVhostController("vhost-blk-disk1")
+	if err != nil {
+		t.Fatalf("GetVhostController: %v", err)
+	}
+	if ctrlr.Socket != "/var/tmp/vhost-blk-disk1" {
+		t.Errorf("socket = %q, want /var/tmp/vhost-blk-disk1", ctrlr.Socket)
+	}
+	if err := cli.DeleteVhostController("vhost-blk-disk1"); err != nil {
+		t.Fatalf("DeleteVhostController: %v", err)
+	}
+	if err := cli.call("unknown_method", nil, nil); err == nil {
+		t.Errorf("expect error calling unknown method")
+	}
+}
@@ -0,0 +1,396 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+)
+
+const (
+	instrumentationScope = "yunion.io/x/onecloud/pkg/util/tracing"
+
+	defaultBatchSize     = 256
+	defaultQueueSize     = 4096
+	defaultFlushInterval = 5 * time.Second
+	defaultExportTimeout = 10 * time.Second
+)
+
+type IExporter interface {
+	Export(ctx context.Context, serviceName string, spans []*SSpan) error
+	Shutdown() error
+}
+
+type SOptions struct {
+	ServiceName string
+	// OTLP/HTTP traces endpoint, e.g. http://otel-collector:4318/v1/traces
+	OtlpEndpoint string
+	// file receiving one OTLP/JSON export request per line
+	FilePath string
+}
+
+type sTracer struct {
+	serviceName string
+	exporters   []IExporter
+
+	queue chan *SSpan
+	flush chan chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+var (
+	tracerLock sync.RWMutex
+	tracer     *sTracer
+)
+
+func getTracer() *sTracer {
+	tracerLock.RLock()
+	defer tracerLock.RUnlock()
+
+	return tracer
+}
+
+// IsEnabled reports whether any exporter is configured, new root spans
+// are only sampled when finished spans have somewhere to go
+func IsEnabled() bool {
+	return getTracer() != nil
+}
+
+// Init configures the span exporters of this process, trace context
+// propagation works regardless of whether any exporter is configured
+func Init(opts SOptions) error {
+	exporters := []IExporter{}
+	if len(opts.OtlpEndpoint) > 0 {
+		exporters = append(exporters, NewOtlpHttpExporter(opts.OtlpEndpoint))
+	}
+	if len(opts.FilePath) > 0 {
+		exp, err := NewFileExporter(opts.FilePath)
+		if err != nil {
+			return errors.Wrapf(err, "NewFileExporter %s", opts.FilePath)
+		}
+		exporters = append(exporters, exp)
+	}
+	Shutdown()
+	if len(exporters) == 0 {
+		return nil
+	}
+	t := &sTracer{
+		serviceName: opts.ServiceName,
+		exporters:   exporters,
+		queue:       make(chan *SSpan, defaultQueueSize),
+		flush:       make(chan chan struct{}),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go t.run()
+
+	tracerLock.Lock()
+	tracer = t
+	tracerLock.Unlock()
+	log.Infof("tracing enabled for %s, otlp endpoint %q, file %q", opts.ServiceName, opts.OtlpEndpoint, opts.FilePath)
+	return nil
+}
+
+// Flush exports all finished spans queued so far
+func Flush() {
+	t := getTracer()
+	if t == nil {
+		return
+	}
+	ch := make(chan struct{})
+	select {
+	case t.flush <- ch:
+		<-ch
+	case <-t.done:
+	}
+}
+
+// Shutdown flushes queued spans and stops the exporters
+func Shutdown() {
+	tracerLock.Lock()
+	t := tracer
+	tracer = nil
+	tracerLock.Unlock()
+
+	if t == nil {
+		return
+	}
+	close(t.stop)
+	<-t.done
+	for _, exp := range t.exporters {
+		if err := exp.Shutdown(); err != nil {
+			log.Warningf("shutdown trace exporter: %v", err)
+		}
+	}
+}
+
+func (t *sTracer) enqueue(span *SSpan) {
+	if t == nil {
+		return
+	}
+	select {
+	case t.queue <- span:
+	default:
+		log.Debugf("trace queue full, drop span %s", span.Name)
+	}
+}
+
+func (t *sTracer) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(defaultFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*SSpan, 0, defaultBatchSize)
+	drain := func() {
+		for {
+			select {
+			case span := <-t.queue:
+				batch = append(batch, span)
+			default:
+				return
+			}
+		}
+	}
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), defaultExportTimeout)
+		defer cancel()
+		for _, exp := range t.exporters {
+			if err := exp.Export(ctx, t.serviceName, batch); err != nil {
+				log.Warningf("export %d spans: %v", len(batch), err)
+			}
+		}
+		batch = make([]*SSpan, 0, defaultBatchSize)
+	}
+	for {
+		select {
+		case span := <-t.queue:
+			batch = append(batch, span)
+			if len(batch) >= defaultBatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case ch := <-t.flush:
+			drain()
+			export()
+			close(ch)
+		case <-t.stop:
+			drain()
+			export()
+			return
+		}
+	}
+}
+
+// OTLP/JSON encoding, see opentelemetry-proto trace/v1/trace.proto
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    TStatusCode `json:"code,omitempty"`
+	Message string      `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceId           string         `json:"traceId"`
+	SpanId            string         `json:"spanId"`
+	ParentSpanId      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              TSpanKind      `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func toOtlpValue(val interface{}) otlpAnyValue {
+	switch v := val.(type) {
+	case string:
+		return otlpAnyValue{StringValue: &v}
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case int:
+		s := strconv.FormatInt(int64(v), 10)
+		return otlpAnyValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return otlpAnyValue{IntValue: &s}
+	case float64:
+		return otlpAnyValue{DoubleValue: &v}
+	default:
+		s := fmt.Sprintf("%v", v)
+		return otlpAnyValue{StringValue: &s}
+	}
+}
+
+func toOtlpAttributes(attrs []SAttribute) []otlpKeyValue {
+	ret := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		ret = append(ret, otlpKeyValue{Key: attr.Key, Value: toOtlpValue(attr.Value)})
+	}
+	return ret
+}
+
+func newOtlpExportRequest(serviceName string, spans []*SSpan) *otlpExportRequest {
+	scopeSpans := otlpScopeSpans{
+		Scope: otlpScope{Name: instrumentationScope},
+		Spans: make([]otlpSpan, 0, len(spans)),
+	}
+	for _, span := range spans {
+		span.lock.Lock()
+		scopeSpans.Spans = append(scopeSpans.Spans, otlpSpan{
+			TraceId:           span.TraceId,
+			SpanId:            span.SpanId,
+			ParentSpanId:      span.ParentSpanId,
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+			Attributes:        toOtlpAttributes(span.Attributes),
+			Status:            otlpStatus{Code: span.StatusCode, Message: span.StatusMessage},
+		})
+		span.lock.Unlock()
+	}
+	return &otlpExportRequest{
+		ResourceSpans: []otlpResourceSpans{
+			{
+				Resource: otlpResource{
+					Attributes: toOtlpAttributes([]SAttribute{{Key: "service.name", Value: serviceName}}),
+				},
+				ScopeSpans: []otlpScopeSpans{scopeSpans},
+			},
+		},
+	}
+}
+
+type SOtlpHttpExporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+func NewOtlpHttpExporter(endpoint string) *SOtlpHttpExporter {
+	return &SOtlpHttpExporter{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: defaultExportTimeout},
+	}
+}
+
+func (exp *SOtlpHttpExporter) Export(ctx context.Context, serviceName string, spans []*SSpan) error {
+	body, err := json.Marshal(newOtlpExportRequest(serviceName, spans))
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, exp.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "http.NewRequest")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := exp.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "post %s", exp.endpoint)
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("post %s: unexpected status %s", exp.endpoint, resp.Status)
+	}
+	return nil
+}
+
+func (exp *SOtlpHttpExporter) Shutdown() error {
+	exp.client.CloseIdleConnections()
+	return nil
+}
+
+type SFileExporter struct {
+	lock sync.Mutex
+	file *os.File
+}
+
+func NewFileExporter(path string) (*SFileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "os.OpenFile")
+	}
+	return &SFileExporter{file: file}, nil
+}
+
+func (exp *SFileExporter) Export(ctx context.Context, serviceName string, spans []*SSpan) error {
+	line, err := json.Marshal(newOtlpExportRequest(serviceName, spans))
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+	exp.lock.Lock()
+	defer exp.lock.Unlock()
+
+	_, err = exp.file.Write(append(line, '\n'))
+	return errors.Wrap(err, "write")
+}
+
+func (exp *SFileExporter) Shutdown() error {
+	exp.lock.Lock()
+	defer exp.lock.Unlock()
+
+	return exp.file.Close()
+}
@@ -0,0 +1,270 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HEADER_TRACEPARENT is the W3C Trace Context propagation header
+	HEADER_TRACEPARENT = "traceparent"
+
+	traceparentVersion = "00"
+	flagSampled        = 0x01
+)
+
+type TSpanKind int
+
+// values follow the OTLP SpanKind enumeration
+const (
+	SPAN_KIND_INTERNAL = TSpanKind(1)
+	SPAN_KIND_SERVER   = TSpanKind(2)
+	SPAN_KIND_CLIENT   = TSpanKind(3)
+)
+
+type TStatusCode int
+
+// values follow the OTLP Status.StatusCode enumeration
+const (
+	STATUS_UNSET = TStatusCode(0)
+	STATUS_OK    = TStatusCode(1)
+	STATUS_ERROR = TStatusCode(2)
+)
+
+type SSpanContext struct {
+	TraceId string
+	SpanId  string
+	Sampled bool
+}
+
+func (sc SSpanContext) IsValid() bool {
+	return isValidHexId(sc.TraceId, 32) && isValidHexId(sc.SpanId, 16)
+}
+
+// Traceparent formats the span context as a W3C traceparent header value
+func (sc SSpanContext) Traceparent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+	flags := 0
+	if sc.Sampled {
+		flags |= flagSampled
+	}
+	return fmt.Sprintf("%s-%s-%s-%02x", traceparentVersion, sc.TraceId, sc.SpanId, flags)
+}
+
+// ParseTraceparent parses a W3C traceparent header value
+func ParseTraceparent(val string) (SSpanContext, error) {
+	sc := SSpanContext{}
+	parts := strings.Split(strings.TrimSpace(val), "-")
+	if len(parts) < 4 {
+		return sc, fmt.Errorf("invalid traceparent %q", val)
+	}
+	version := parts[0]
+	if len(version) != 2 || version == "ff" || !isHex(version) {
+		return sc, fmt.Errorf("invalid traceparent version %q", version)
+	}
+	if version == traceparentVersion && len(parts) != 4 {
+		return sc, fmt.Errorf("invalid traceparent %q", val)
+	}
+	if !isValidHexId(parts[1], 32) {
+		return sc, fmt.Errorf("invalid trace id %q", parts[1])
+	}
+	if !isValidHexId(parts[2], 16) {
+		return sc, fmt.Errorf("invalid parent id %q", parts[2])
+	}
+	if len(parts[3]) != 2 || !isHex(parts[3]) {
+		return sc, fmt.Errorf("invalid trace flags %q", parts[3])
+	}
+	flags, _ := hex.DecodeString(parts[3])
+	sc.TraceId = parts[1]
+	sc.SpanId = parts[2]
+	sc.Sampled = flags[0]&flagSampled != 0
+	return sc, nil
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')) {
+			return false
+		}
+	}
+	return true
+}
+
+// isValidHexId checks a lowercase hex id of the given length which is not all zeros
+func isValidHexId(id string, length int) bool {
+	if len(id) != length || !isHex(id) {
+		return false
+	}
+	return strings.Trim(id, "0") != ""
+}
+
+func randomHexId(size int) string {
+	buf := make([]byte, size)
+	for {
+		rand.Read(buf)
+		id := hex.EncodeToString(buf)
+		if strings.Trim(id, "0") != "" {
+			return id
+		}
+	}
+}
+
+func NewTraceId() string {
+	return randomHexId(16)
+}
+
+func NewSpanId() string {
+	return randomHexId(8)
+}
+
+type spanContextKey struct{}
+
+func ContextWithSpanContext(ctx context.Context, sc SSpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+func SpanContextFromContext(ctx context.Context) SSpanContext {
+	if ctx == nil {
+		return SSpanContext{}
+	}
+	if sc, ok := ctx.Value(spanContextKey{}).(SSpanContext); ok {
+		return sc
+	}
+	return SSpanContext{}
+}
+
+// ContextWithTraceparent restores a span context saved by Traceparent,
+// invalid values are ignored
+func ContextWithTraceparent(ctx context.Context, traceparent string) context.Context {
+	if len(traceparent) == 0 {
+		return ctx
+	}
+	sc, err := ParseTraceparent(traceparent)
+	if err != nil {
+		return ctx
+	}
+	return ContextWithSpanContext(ctx, sc)
+}
+
+// Extract returns the span context propagated in the http request header
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return ContextWithTraceparent(ctx, header.Get(HEADER_TRACEPARENT))
+}
+
+// Inject writes the span context of ctx into the http request header
+func Inject(ctx context.Context, header http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if sc.IsValid() {
+		header.Set(HEADER_TRACEPARENT, sc.Traceparent())
+	}
+}
+
+type SAttribute struct {
+	Key   string
+	Value interface{}
+}
+
+type SSpan struct {
+	SSpanContext
+
+	ParentSpanId  string
+	Name          string
+	Kind          TSpanKind
+	StartTime     time.Time
+	EndTime       time.Time
+	Attributes    []SAttribute
+	StatusCode    TStatusCode
+	StatusMessage string
+
+	lock  sync.Mutex
+	ended bool
+}
+
+// StartSpan starts a span as the child of the span context carried by ctx,
+// or as the root of a new trace, and returns the context carrying the new span
+func StartSpan(ctx context.Context, name string, kind TSpanKind) (context.Context, *SSpan) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	parent := SpanContextFromContext(ctx)
+	span := &SSpan{
+		Name:      name,
+		Kind:      kind,
+		StartTime: time.Now(),
+	}
+	span.SpanId = NewSpanId()
+	if parent.IsValid() {
+		span.TraceId = parent.TraceId
+		span.ParentSpanId = parent.SpanId
+		span.Sampled = parent.Sampled
+	} else {
+		span.TraceId = NewTraceId()
+		span.Sampled = IsEnabled()
+	}
+	return ContextWithSpanContext(ctx, span.SSpanContext), span
+}
+
+func (span *SSpan) SetAttribute(key string, val interface{}) {
+	span.lock.Lock()
+	defer span.lock.Unlock()
+
+	span.Attributes = append(span.Attributes, SAttribute{Key: key, Value: val})
+}
+
+func (span *SSpan) SetStatus(code TStatusCode, msg string) {
+	span.lock.Lock()
+	defer span.lock.Unlock()
+
+	span.StatusCode = code
+	span.StatusMessage = msg
+}
+
+// SetHttpStatus records the http status code and flags 5xx responses as errors
+func (span *SSpan) SetHttpStatus(status int) {
+	span.SetAttribute("http.status_code", status)
+	if status >= 500 {
+		span.SetStatus(STATUS_ERROR, http.StatusText(status))
+	}
+}
+
+// End finishes the span and hands it to the exporter if it is sampled,
+// calling End more than once has no effect
+func (span *SSpan) End() {
+	span.lock.Lock()
+	if span.ended {
+		span.lock.Unlock()
+		return
+	}
+	span.ended = true
+	span.EndTime = time.Now()
+	span.lock.Unlock()
+
+	if span.Sampled {
+		getTracer().enqueue(span)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	cases := []struct {
+		in      string
+		wantErr bool
+		sampled bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", false, false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", false, true},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", true, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", true, false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", true, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, false},
+		{"garbage", true, false},
+	}
+	for _, c := range cases {
+		sc, err := ParseTraceparent(c.in)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%s: expect error", c.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.in, err)
+			continue
+		}
+		if sc.Sampled != c.sampled {
+			t.Errorf("%s: sampled %v != %v", c.in, sc.Sampled, c.sampled)
+		}
+	}
+
+	in := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, _ := ParseTraceparent(in)
+	if got := sc.Traceparent(); got != in {
+		t.Errorf("Traceparent %s != %s", got, in)
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	header := http.Header{}
+	header.Set(HEADER_TRACEPARENT, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := Extract(context.Background(), header)
+
+	ctx, span := StartSpan(ctx, "server", SPAN_KIND_SERVER)
+	if span.TraceId != "4bf92f3577b34da6a3ce929d0e0e4736" || span.ParentSpanId != "00f067aa0ba902b7" {
+		t.Fatalf("span does not continue the incoming trace: %#v", span.SSpanContext)
+	}
+	out := http.Header{}
+	Inject(ctx, out)
+	sc, err := ParseTraceparent(out.Get(HEADER_TRACEPARENT))
+	if err != nil {
+		t.Fatalf("parse injected header: %v", err)
+	}
+	if sc.TraceId != span.TraceId || sc.SpanId != span.SpanId {
+		t.Errorf("injected %#v, want span %#v", sc, span.SSpanContext)
+	}
+	restored := ContextWithTraceparent(context.Background(), sc.Traceparent())
+	if SpanContextFromContext(restored) != sc {
+		t.Errorf("restored span context mismatch")
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "spans.json")
+
+	if err := Init(SOptions{ServiceName: "test", FilePath: path}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer Shutdown()
+
+	ctx, parent := StartSpan(context.Background(), "parent", SPAN_KIND_SERVER)
+	_, child := StartSpan(ctx, "child", SPAN_KIND_CLIENT)
+	child.SetHttpStatus(503)
+	child.End()
+	parent.End()
+	Flush()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	spans := []otlpSpan{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		req := otlpExportRequest{}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		for _, rs := range req.ResourceSpans {
+			if v := rs.Resource.Attributes[0].Value.StringValue; v == nil || *v != "test" {
+				t.Errorf("unexpected service.name")
+			}
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}
+	if len(spans) != 2 {
+		t.Fatalf("expect 2 spans, got %d", len(spans))
+	}
+	if spans[0].Name != "child" || spans[0].ParentSpanId != spans[1].SpanId || spans[0].TraceId != spans[1].TraceId {
+		t.Errorf("unexpected span relation: %#v", spans)
+	}
+	if spans[0].Status.Code != STATUS_ERROR {
+		t.Errorf("expect child span error status")
+	}
+}
+
+func TestOtlpHttpExporter(t *testing.T) {
+	received := make(chan otlpExportRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := otlpExportRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- req
+	}))
+	defer srv.Close()
+
+	_, span := StartSpan(context.Background(), "op", SPAN_KIND_INTERNAL)
+	span.End()
+	exp := NewOtlpHttpExporter(srv.URL + "/v1/traces")
+	if err := exp.Export(context.Background(), "test", []*SSpan{span}); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	req := <-received
+	if len(req.ResourceSpans) != 1 || req.ResourceSpans[0].ScopeSpans[0].Spans[0].SpanId != span.SpanId {
+		t.Errorf("unexpected export request %#v", req)
+	}
+}
@@ -27,8 +27,9 @@ type Vpc struct {
 
 	RouteTable *RouteTable `json:"-"`
 
-	Wire     *Wire    `json:"-"`
-	Networks Networks `json:"-"`
+	Wire             *Wire            `json:"-"`
+	Networks         Networks         `json:"-"`
+	SecgroupFlowLogs SecgroupFlowLogs `json:"-"`
 }
 
 func (el *Vpc) Copy() *Vpc {
@@ -64,11 +65,12 @@ func (el *Wire) Copy() *Wire {
 type Network struct {
 	compute_models.SNetwork
 
-	Vpc           *Vpc          `json:"-"`
-	Wire          *Wire         `json:"-"`
-	Guestnetworks Guestnetworks `json:"-"`
-	Groupnetworks Groupnetworks `json:"-"`
-	Elasticips    Elasticips    `json:"-"`
+	Vpc              *Vpc             `json:"-"`
+	Wire             *Wire            `json:"-"`
+	Guestnetworks    Guestnetworks    `json:"-"`
+	Groupnetworks    Groupnetworks    `json:"-"`
+	Elasticips       Elasticips       `json:"-"`
+	SecgroupFlowLogs SecgroupFlowLogs `json:"-"`
 }
 
 func (el *Network) Copy() *Network {
@@ -208,6 +210,19 @@ func (el *DnsRecord) Copy() *DnsRecord {
 	}
 }
 
+type SecgroupFlowLog struct {
+	compute_models.SSecgroupFlowLog
+
+	Vpc     *Vpc     `json:"-"`
+	Network *Network `json:"-"`
+}
+
+func (el *SecgroupFlowLog) Copy() *SecgroupFlowLog {
+	return &SecgroupFlowLog{
+		SSecgroupFlowLog: el.SSecgroupFlowLog,
+	}
+}
+
 type Groupguest struct {
 	compute_models.SGroupguest
 
@@ -42,6 +42,8 @@ type (
 
 	DnsRecords map[string]*DnsRecord
 
+	SecgroupFlowLogs map[string]*SecgroupFlowLog
+
 	RouteTables map[string]*RouteTable
 
 	Groupguests   map[string]*Groupguest
@@ -692,6 +694,60 @@ func (set DnsRecords) Copy() apihelper.IModelSet {
 	return setCopy
 }
 
+func (set SecgroupFlowLogs) ModelManager() mcclient_modulebase.IBaseManager {
+	return &mcclient_modules.SecgroupFlowLogs
+}
+
+func (set SecgroupFlowLogs) NewModel() db.IModel {
+	return &SecgroupFlowLog{}
+}
+
+func (set SecgroupFlowLogs) AddModel(i db.IModel) {
+	m := i.(*SecgroupFlowLog)
+	set[m.Id] = m
+}
+
+func (set SecgroupFlowLogs) Copy() apihelper.IModelSet {
+	setCopy := SecgroupFlowLogs{}
+	for id, el := range set {
+		setCopy[id] = el.Copy()
+	}
+	return setCopy
+}
+
+// join attaches flow logs to their vpc, and to their network when the flow
+// log is for a single network
+func (set SecgroupFlowLogs) join(vpcs Vpcs, networks Networks) bool {
+	for _, vpc := range vpcs {
+		vpc.SecgroupFlowLogs = SecgroupFlowLogs{}
+	}
+	for _, network := range networks {
+		network.SecgroupFlowLogs = SecgroupFlowLogs{}
+	}
+	for id, el := range set {
+		vpc, ok := vpcs[el.VpcId]
+		if !ok {
+			log.Warningf("secgroup flow log %s(%s): vpc id %s not found",
+				el.Name, el.Id, el.VpcId)
+			continue
+		}
+		el.Vpc = vpc
+		if el.NetworkId == "" {
+			vpc.SecgroupFlowLogs[id] = el
+			continue
+		}
+		network, ok := networks[el.NetworkId]
+		if !ok {
+			log.Warningf("secgroup flow log %s(%s): network id %s not found",
+				el.Name, el.Id, el.NetworkId)
+			continue
+		}
+		el.Network = network
+		network.SecgroupFlowLogs[id] = el
+	}
+	return true
+}
+
 func (set RouteTables) ModelManager() mcclient_modulebase.IBaseManager {
 	return &mcclient_modules.RouteTables
 }
@@ -35,6 +35,8 @@ type ModelSetsMaxUpdatedAt struct {
 
 	DnsRecords time.Time
 
+	SecgroupFlowLogs time.Time
+
 	RouteTables time.Time
 
 	Groupguests   time.Time
@@ -57,6 +59,8 @@ func NewModelSetsMaxUpdatedAt() *ModelSetsMaxUpdatedAt {
 
 		DnsRecords: apihelper.PseudoZeroTime,
 
+		SecgroupFlowLogs: apihelper.PseudoZeroTime,
+
 		RouteTables: apihelper.PseudoZeroTime,
 
 		Groupguests:   apihelper.PseudoZeroTime,
@@ -79,6 +83,8 @@ type ModelSets struct {
 
 	DnsRecords DnsRecords
 
+	SecgroupFlowLogs SecgroupFlowLogs
+
 	RouteTables RouteTables
 
 	Groupguests   Groupguests
@@ -102,6 +108,8 @@ func NewModelSets() *ModelSets {
 
 		DnsRecords: DnsRecords{},
 
+		SecgroupFlowLogs: SecgroupFlowLogs{},
+
 		RouteTables: RouteTables{},
 
 		Groupguests:   Groupguests{},
@@ -127,6 +135,8 @@ func (mss *ModelSets) ModelSetList() []apihelper.IModelSet {
 
 		mss.DnsRecords,
 
+		mss.SecgroupFlowLogs,
+
 		mss.RouteTables,
 
 		mss.Groupguests,
@@ -154,6 +164,8 @@ func (mss *ModelSets) copy_() *ModelSets {
 
 		DnsRecords: mss.DnsRecords.Copy().(DnsRecords),
 
+		SecgroupFlowLogs: mss.SecgroupFlowLogs.Copy().(SecgroupFlowLogs),
+
 		RouteTables: mss.RouteTables.Copy().(RouteTables),
 
 		Groupguests:   mss.Groupguests.Copy().(Groupguests),
@@ -213,6 +225,7 @@ func (mss *ModelSets) join() bool {
 	p = append(p, mss.Guestnetworks.joinNetworkAddresses(mss.NetworkAddresses))
 	p = append(p, mss.Groups.joinGroupnetworks(mss.Groupnetworks, mss.Networks))
 	p = append(p, mss.Groupnetworks.joinElasticips(mss.Elasticips))
+	p = append(p, mss.SecgroupFlowLogs.join(mss.Vpcs, mss.Networks))
 	for _, b := range p {
 		if !b {
 			return false
@@ -0,0 +1,108 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn
+
+import (
+	"context"
+	"fmt"
+
+	"yunion.io/x/ovsdb/schema/ovn_nb"
+
+	compute_apis "yunion.io/x/onecloud/pkg/apis/compute"
+	agentmodels "yunion.io/x/onecloud/pkg/vpcagent/models"
+)
+
+const (
+	externalKeyOcFlowLog = "oc-flow-log"
+
+	aclLogSeverity = "info"
+)
+
+// flowLogMatchAction returns whether flow log records traffic hit by acl of
+// the specified secgroup rule action
+func flowLogMatchAction(flowLog *agentmodels.SecgroupFlowLog, action string) bool {
+	switch flowLog.TrafficType {
+	case compute_apis.SECGROUP_FLOW_LOG_TRAFFIC_ALL:
+		return true
+	case compute_apis.SECGROUP_FLOW_LOG_TRAFFIC_ALLOW:
+		return action == "allow"
+	case compute_apis.SECGROUP_FLOW_LOG_TRAFFIC_DENY:
+		return action == "deny"
+	}
+	return false
+}
+
+// guestnetworkFlowLog picks the enabled flow log applying to acls of
+// guestnetwork with the specified secgroup rule action.  Flow logs of the
+// network and those of the whole vpc are both considered.  When multiple
+// apply, the one with the highest sample rate wins
+func guestnetworkFlowLog(guestnetwork *agentmodels.Guestnetwork, action string) *agentmodels.SecgroupFlowLog {
+	var (
+		network = guestnetwork.Network
+		vpc     = network.Vpc
+		r       *agentmodels.SecgroupFlowLog
+	)
+	for _, flowLogs := range []agentmodels.SecgroupFlowLogs{network.SecgroupFlowLogs, vpc.SecgroupFlowLogs} {
+		for _, flowLog := range flowLogs {
+			if !flowLog.Enabled.Bool() || !flowLogMatchAction(flowLog, action) {
+				continue
+			}
+			if r == nil ||
+				flowLog.SampleRate > r.SampleRate ||
+				(flowLog.SampleRate == r.SampleRate && flowLog.Id < r.Id) {
+				r = flowLog
+			}
+		}
+	}
+	return r
+}
+
+// ClaimSecgroupFlowLog makes the meter for rate limiting acl logs of the
+// flow log.  The sample rate is part of oc-ref so that rate change will
+// cause the meter to be recreated
+func (keeper *OVNNorthboundKeeper) ClaimSecgroupFlowLog(ctx context.Context, flowLog *agentmodels.SecgroupFlowLog) error {
+	var (
+		ocVersion = fmt.Sprintf("%s.%d", flowLog.UpdatedAt, flowLog.UpdateVersion)
+		ocRef     = fmt.Sprintf("flowlog/%s/%d", flowLog.Id, flowLog.SampleRate)
+	)
+	meter := &ovn_nb.Meter{
+		Name: flowLogMeterName(flowLog.Id),
+		Unit: "pktps",
+		ExternalIds: map[string]string{
+			externalKeyOcRef: ocRef,
+		},
+	}
+	allFound, args := cmp(&keeper.DB, ocVersion, meter)
+	if allFound {
+		return nil
+	}
+	band := &ovn_nb.MeterBand{
+		Action: "drop",
+		Rate:   int64(flowLog.SampleRate),
+	}
+	args = append(args, ovnCreateArgs(band, "band")...)
+	args = append(args, ovnCreateArgs(meter, "meter")...)
+	args = append(args, "bands=@band")
+	return keeper.cli.Must(ctx, "ClaimSecgroupFlowLog", args)
+}
+
+// aclSetFlowLog enables logging of acl with rate limited by meter of the
+// flow log
+func aclSetFlowLog(acl *ovn_nb.ACL, flowLog *agentmodels.SecgroupFlowLog) {
+	acl.Log = true
+	acl.Severity = ptr(aclLogSeverity)
+	acl.Meter = ptr(flowLogMeterName(flowLog.Id))
+	acl.ExternalIds[externalKeyOcFlowLog] = flowLog.Id
+}
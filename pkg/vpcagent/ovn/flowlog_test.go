@@ -0,0 +1,77 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ovn
+
+import (
+	"testing"
+
+	"yunion.io/x/pkg/tristate"
+
+	compute_apis "yunion.io/x/onecloud/pkg/apis/compute"
+	agentmodels "yunion.io/x/onecloud/pkg/vpcagent/models"
+)
+
+func TestGuestnetworkFlowLog(t *testing.T) {
+	newFlowLog := func(id, networkId, trafficType string, rate int, enabled bool) *agentmodels.SecgroupFlowLog {
+		flowLog := &agentmodels.SecgroupFlowLog{}
+		flowLog.Id = id
+		flowLog.VpcId = "vpc0"
+		flowLog.NetworkId = networkId
+		flowLog.TrafficType = trafficType
+		flowLog.SampleRate = rate
+		flowLog.Enabled = tristate.NewFromBool(enabled)
+		return flowLog
+	}
+	vpc := &agentmodels.Vpc{
+		SecgroupFlowLogs: agentmodels.SecgroupFlowLogs{
+			"vpc-all":     newFlowLog("vpc-all", "", compute_apis.SECGROUP_FLOW_LOG_TRAFFIC_ALL, 100, true),
+			"vpc-off":     newFlowLog("vpc-off", "", compute_apis.SECGROUP_FLOW_LOG_TRAFFIC_ALL, 1000, false),
+			"vpc-deny-hi": newFlowLog("vpc-deny-hi", "", compute_apis.SECGROUP_FLOW_LOG_TRAFFIC_DENY, 500, true),
+		},
+	}
+	network := &agentmodels.Network{
+		Vpc: vpc,
+		SecgroupFlowLogs: agentmodels.SecgroupFlowLogs{
+			"net-allow": newFlowLog("net-allow", "net0", compute_apis.SECGROUP_FLOW_LOG_TRAFFIC_ALLOW, 100, true),
+		},
+	}
+	guestnetwork := &agentmodels.Guestnetwork{
+		Network: network,
+	}
+	cases := []struct {
+		action string
+		want   string
+	}{
+		// tie on sample rate is broken by id
+		{action: "allow", want: "net-allow"},
+		{action: "deny", want: "vpc-deny-hi"},
+	}
+	for _, c := range cases {
+		got := guestnetworkFlowLog(guestnetwork, c.action)
+		if got == nil {
+			t.Errorf("action %s: want %s, got nil", c.action, c.want)
+			continue
+		}
+		if got.Id != c.want {
+			t.Errorf("action %s: want %s, got %s", c.action, c.want, got.Id)
+		}
+	}
+
+	network.SecgroupFlowLogs = agentmodels.SecgroupFlowLogs{}
+	vpc.SecgroupFlowLogs = agentmodels.SecgroupFlowLogs{}
+	if got := guestnetworkFlowLog(guestnetwork, "allow"); got != nil {
+		t.Errorf("want nil, got %s", got.Id)
+	}
+}
@@ -17,6 +17,7 @@ package ovn
 import (
 	"context"
 	"fmt"
+	"net"
 	"sort"
 	"strings"
 
@@ -56,6 +57,7 @@ func DumpOVNNorthbound(ctx context.Context, cli *ovnutil.OvnNbCtl) (*OVNNorthbou
 		&db.DHCPOptions,
 		&db.QoS,
 		&db.DNS,
+		&db.Meter,
 	}
 	args := []string{"--format=json", "list", "<tbl>"}
 	for _, itbl := range itbls {
@@ -357,17 +359,50 @@ func (keeper *OVNNorthboundKeeper) ClaimNetwork(ctx context.Context, network *ag
 		}
 	}
 
+	var dhcp6opts *ovn_nb.DHCPOptions
+	if network.IsSupportIPv6() {
+		_, prefix6, err := net.ParseCIDR(fmt.Sprintf("%s/%d", network.GuestGateway6, network.GuestIp6Mask))
+		if err != nil {
+			return errors.Wrapf(err, "parse ipv6 prefix of network %s", network.Id)
+		}
+		netRnp.Networks = append(netRnp.Networks, fmt.Sprintf("%s/%d", network.GuestGateway6, network.GuestIp6Mask))
+		netRnp.Ipv6RaConfigs = map[string]string{
+			"address_mode":  "dhcpv6_stateful",
+			"send_periodic": "true",
+			"mtu":           fmt.Sprintf("%d", mtu),
+		}
+		dhcp6opts = &ovn_nb.DHCPOptions{
+			Cidr: prefix6.String(),
+			Options: map[string]string{
+				"server_id": dhcpMac,
+			},
+			ExternalIds: map[string]string{
+				externalKeyOcRef: netDhcp6Ref(network.Id),
+			},
+		}
+		if network.GuestDns6 != "" {
+			dhcp6opts.Options["dns_server"] = "{" + network.GuestDns6 + "}"
+		}
+		if network.GuestDomain != "" {
+			dhcp6opts.Options["domain_search"] = fmt.Sprintf("%q", network.GuestDomain)
+		}
+	}
+
 	var (
 		args      []string
 		ocVersion = fmt.Sprintf("%s.%d", network.UpdatedAt, network.UpdateVersion)
 	)
-	allFound, args := cmp(&keeper.DB, ocVersion,
+	irows := []types.IRow{
 		netLs,
 		netRnp,
 		netNrp,
 		netMdp,
 		dhcpopts,
-	)
+	}
+	if dhcp6opts != nil {
+		irows = append(irows, dhcp6opts)
+	}
+	allFound, args := cmp(&keeper.DB, ocVersion, irows...)
 	if allFound {
 		return nil
 	}
@@ -376,6 +411,9 @@ func (keeper *OVNNorthboundKeeper) ClaimNetwork(ctx context.Context, network *ag
 	args = append(args, ovnCreateArgs(netNrp, netNrp.Name)...)
 	args = append(args, ovnCreateArgs(netMdp, netMdp.Name)...)
 	args = append(args, ovnCreateArgs(dhcpopts, "dhcpopts")...)
+	if dhcp6opts != nil {
+		args = append(args, ovnCreateArgs(dhcp6opts, "dhcp6opts")...)
+	}
 	args = append(args, "--", "add", "Logical_Switch", netLs.Name, "ports", "@"+netNrp.Name, "@"+netMdp.Name)
 	args = append(args, "--", "add", "Logical_Router", vpcLrName(network.Vpc.Id), "ports", "@"+netRnp.Name)
 	return keeper.cli.Must(ctx, "ClaimNetwork", args)
@@ -438,6 +476,24 @@ func (keeper *OVNNorthboundKeeper) ClaimVpcEipgw(ctx context.Context, vpc *agent
 	return keeper.cli.Must(ctx, "ClaimVpcEipgw", args)
 }
 
+// findDhcpOpt returns uuid of DHCP_Options row with the oc-ref
+func (keeper *OVNNorthboundKeeper) findDhcpOpt(ctx context.Context, ocRef string) string {
+	dhcpOptQuery := &ovn_nb.DHCPOptions{
+		ExternalIds: map[string]string{
+			externalKeyOcRef: ocRef,
+		},
+	}
+	if m := keeper.DB.DHCPOptions.FindOneMatchNonZeros(dhcpOptQuery); m != nil {
+		return m.OvsdbUuid()
+	}
+	args := []string{
+		"--bare", "--columns=_uuid", "find", "DHCP_Options",
+		fmt.Sprintf("external_ids:%s=%q", externalKeyOcRef, ocRef),
+	}
+	res := keeper.cli.Must(ctx, "find dhcpopt", args)
+	return strings.TrimSpace(res.Output)
+}
+
 func (keeper *OVNNorthboundKeeper) ClaimGuestnetwork(ctx context.Context, guestnetwork *agentmodels.Guestnetwork) error {
 	var (
 		// Callers assure that guestnetwork.Guest is not nil
@@ -454,26 +510,18 @@ func (keeper *OVNNorthboundKeeper) ClaimGuestnetwork(ctx context.Context, guestn
 		ocQosRef        = fmt.Sprintf("qos/%s/%s/%s", network.Id, guestnetwork.GuestId, guestnetwork.Ifname)
 		ocQosEipRef     = fmt.Sprintf("qos-eip/%s/%s/%s/v2", vpc.Id, guestnetwork.GuestId, guestnetwork.Ifname)
 		dhcpOpt         string
+		dhcp6Opt        string
 	)
 
-	{
-		dhcpOptQuery := &ovn_nb.DHCPOptions{
-			ExternalIds: map[string]string{
-				externalKeyOcRef: guestnetwork.NetworkId,
-			},
-		}
-		if m := keeper.DB.DHCPOptions.FindOneMatchNonZeros(dhcpOptQuery); m != nil {
-			dhcpOpt = m.OvsdbUuid()
-		} else {
-			args := []string{
-				"--bare", "--columns=_uuid", "find", "DHCP_Options",
-				fmt.Sprintf("external_ids:%s=%q", externalKeyOcRef, guestnetwork.NetworkId),
-			}
-			res := keeper.cli.Must(ctx, "find dhcpopt", args)
-			dhcpOpt = strings.TrimSpace(res.Output)
-		}
-		if dhcpOpt == "" {
-			return fmt.Errorf("cannot find dhcpopt for subnet %s", guestnetwork.NetworkId)
+	dhcpOpt = keeper.findDhcpOpt(ctx, guestnetwork.NetworkId)
+	if dhcpOpt == "" {
+		return fmt.Errorf("cannot find dhcpopt for subnet %s", guestnetwork.NetworkId)
+	}
+	hasIPv6 := guestnetwork.Ip6Addr != "" && network.IsSupportIPv6()
+	if hasIPv6 {
+		dhcp6Opt = keeper.findDhcpOpt(ctx, netDhcp6Ref(guestnetwork.NetworkId))
+		if dhcp6Opt == "" {
+			return fmt.Errorf("cannot find dhcp6opt for subnet %s", guestnetwork.NetworkId)
 		}
 	}
 
@@ -488,12 +536,19 @@ func (keeper *OVNNorthboundKeeper) ClaimGuestnetwork(ctx context.Context, guestn
 	subIPms = append(subIPms, guestnetwork.Guest.GetVips()...)
 	sort.Strings(subIPs[1:])
 	sort.Strings(subIPms[1:])
+	if hasIPv6 {
+		subIPs = append(subIPs, guestnetwork.Ip6Addr)
+		subIPms = append(subIPms, fmt.Sprintf("%s/%d", guestnetwork.Ip6Addr, network.GuestIp6Mask))
+	}
 	gnp := &ovn_nb.LogicalSwitchPort{
 		Name:          lportName,
 		Addresses:     []string{fmt.Sprintf("%s %s", guestnetwork.MacAddr, strings.Join(subIPs, " "))},
 		Dhcpv4Options: &dhcpOpt,
 		Options:       map[string]string{},
 	}
+	if hasIPv6 {
+		gnp.Dhcpv6Options = &dhcp6Opt
+	}
 	if guest.SrcMacCheck.IsFalse() {
 		gnp.Addresses = append(gnp.Addresses, "unknown")
 		// empty, not nil, as match condition
@@ -617,15 +672,21 @@ func (keeper *OVNNorthboundKeeper) ClaimGuestnetwork(ctx context.Context, guestn
 			if len(sgr.PeerSecgroupId) > 0 {
 				continue
 			}
-			acl, err := ruleToAcl(lportName, sgr)
+			sgrAcls, err := ruleToAcls(lportName, sgr, hasIPv6)
 			if err != nil {
 				log.Errorf("converting security group rule to acl: %v", err)
 				break
 			}
-			acl.ExternalIds = map[string]string{
-				externalKeyOcRef: ocAclRef,
+			for _, acl := range sgrAcls {
+				acl.Name = ptr(sgr.Id)
+				acl.ExternalIds = map[string]string{
+					externalKeyOcRef: ocAclRef,
+				}
+				if flowLog := guestnetworkFlowLog(guestnetwork, sgr.Action); flowLog != nil {
+					aclSetFlowLog(acl, flowLog)
+				}
+				acls = append(acls, acl)
 			}
-			acls = append(acls, acl)
 		}
 	}
 
@@ -951,6 +1012,7 @@ func (keeper *OVNNorthboundKeeper) Mark(ctx context.Context) {
 		&db.DHCPOptions,
 		&db.QoS,
 		&db.DNS,
+		&db.Meter,
 	}
 	for _, itbl := range itbls {
 		for _, irow := range itbl.Rows() {
@@ -969,6 +1031,7 @@ func (keeper *OVNNorthboundKeeper) Sweep(ctx context.Context) error {
 		&db.LogicalRouter,
 		&db.DHCPOptions,
 		&db.DNS,
+		&db.Meter,
 	}
 	var irows []types.IRow
 	for _, itbl := range itbls {
@@ -97,6 +97,11 @@ func netMdpName(netId string) string {
 	return fmt.Sprintf("subnet-md/%s", netId)
 }
 
+// netDhcp6Ref returns external_ids oc-ref of subnet DHCPv6 options
+func netDhcp6Ref(netId string) string {
+	return fmt.Sprintf("dhcp6/%s", netId)
+}
+
 // gnpName returns Logical_Switch_Port name for guestnetwork
 //
 // The name must match what's going to be set on each chassis
@@ -107,3 +112,9 @@ func gnpName(netId string, ifname string) string {
 func vipName(netId string, groupId string, ipaddr string) string {
 	return fmt.Sprintf("vip-%s-%s-%s", netId, groupId, ipaddr)
 }
+
+// flowLogMeterName returns Meter name for rate limiting acl logs of
+// secgroup flow log
+func flowLogMeterName(flowLogId string) string {
+	return fmt.Sprintf("flowlog-%s", flowLogId)
+}
@@ -35,7 +35,43 @@ const (
 	aclDirFromLport = "from-lport"
 )
 
+const (
+	aclFamilyIPv4 = "ip4"
+	aclFamilyIPv6 = "ip6"
+)
+
+func isAnyCIDR(cidr string) bool {
+	return cidr == "" || cidr == "0.0.0.0/0" || cidr == "::/0"
+}
+
+// ruleToAcls converts rule to acls of port. Rules of any address, which is
+// stored as empty or 0.0.0.0/0 cidr, apply to both address families when
+// the port has ipv6 address
+func ruleToAcls(lport string, rule *agentmodels.SecurityGroupRule, hasIPv6 bool) ([]*ovn_nb.ACL, error) {
+	acl, err := ruleToAcl(lport, rule)
+	if err != nil {
+		return nil, err
+	}
+	acls := []*ovn_nb.ACL{acl}
+	if cidr := strings.TrimSpace(rule.CIDR); hasIPv6 && (cidr == "" || cidr == "0.0.0.0/0") {
+		acl6, err := ruleToAclFamily(lport, rule, aclFamilyIPv6)
+		if err != nil {
+			return nil, err
+		}
+		acls = append(acls, acl6)
+	}
+	return acls, nil
+}
+
 func ruleToAcl(lport string, rule *agentmodels.SecurityGroupRule) (*ovn_nb.ACL, error) {
+	family := aclFamilyIPv4
+	if strings.Contains(rule.CIDR, ":") {
+		family = aclFamilyIPv6
+	}
+	return ruleToAclFamily(lport, rule, family)
+}
+
+func ruleToAclFamily(lport string, rule *agentmodels.SecurityGroupRule, family string) (*ovn_nb.ACL, error) {
 	var (
 		dir    string
 		action string
@@ -72,9 +108,9 @@ func ruleToAcl(lport string, rule *agentmodels.SecurityGroupRule) (*ovn_nb.ACL,
 	}
 
 	addL3Match := func() {
-		matches = append(matches, "ip4")
-		if cidr := strings.TrimSpace(rule.CIDR); cidr != "" && cidr != "0.0.0.0/0" {
-			matches = append(matches, fmt.Sprintf("ip4.%s == %s", l3subfn, cidr))
+		matches = append(matches, family)
+		if cidr := strings.TrimSpace(rule.CIDR); !isAnyCIDR(cidr) {
+			matches = append(matches, fmt.Sprintf("%s.%s == %s", family, l3subfn, cidr))
 		}
 	}
 	addL4Match := func(l4proto string) {
@@ -153,7 +189,11 @@ func ruleToAcl(lport string, rule *agentmodels.SecurityGroupRule) (*ovn_nb.ACL,
 		addL4Match("udp")
 	case secrules.PROTO_ICMP:
 		addL3Match()
-		matches = append(matches, "icmp4")
+		if family == aclFamilyIPv6 {
+			matches = append(matches, "icmp6")
+		} else {
+			matches = append(matches, "icmp4")
+		}
 	default:
 		return nil, errors.Wrapf(errBadSecgroupRule, "unknown protocol %q", rule.Protocol)
 	}
@@ -106,6 +106,42 @@ func TestRuleToACL(t *testing.T) {
 				Priority:  100,
 			},
 		},
+		{
+			// ingress allow icmpv6 from prefix
+			rule: &agentmodels.SecurityGroupRule{
+				SSecurityGroupRule: models.SSecurityGroupRule{
+					Direction: string(secrules.SecurityRuleIngress),
+					CIDR:      "fd00:10::/64",
+					Action:    string(secrules.SecurityRuleAllow),
+					Protocol:  secrules.PROTO_ICMP,
+					Priority:  100,
+				},
+			},
+			acl: &ovn_nb.ACL{
+				Direction: aclDirToLport,
+				Action:    "allow-related",
+				Match:     fmt.Sprintf("outport == %q && ip6 && ip6.src == fd00:10::/64 && icmp6", lport),
+				Priority:  100,
+			},
+		},
+		{
+			// egress deny any ipv6
+			rule: &agentmodels.SecurityGroupRule{
+				SSecurityGroupRule: models.SSecurityGroupRule{
+					Direction: string(secrules.SecurityRuleEgress),
+					CIDR:      "::/0",
+					Action:    string(secrules.SecurityRuleDeny),
+					Protocol:  secrules.PROTO_ANY,
+					Priority:  1,
+				},
+			},
+			acl: &ovn_nb.ACL{
+				Direction: aclDirFromLport,
+				Action:    "drop",
+				Match:     fmt.Sprintf("inport == %q && ip6", lport),
+				Priority:  1,
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -119,3 +155,50 @@ func TestRuleToACL(t *testing.T) {
 		}
 	}
 }
+
+func TestRuleToAcls(t *testing.T) {
+	lport := "local-port120"
+	rule := &agentmodels.SecurityGroupRule{
+		SSecurityGroupRule: models.SSecurityGroupRule{
+			Direction: string(secrules.SecurityRuleIngress),
+			Action:    string(secrules.SecurityRuleAllow),
+			Protocol:  secrules.PROTO_TCP,
+			Ports:     "22",
+			Priority:  100,
+		},
+	}
+	want := []string{
+		fmt.Sprintf("outport == %q && ip4 && tcp && tcp.dst == 22", lport),
+		fmt.Sprintf("outport == %q && ip6 && tcp && tcp.dst == 22", lport),
+	}
+	for _, hasIPv6 := range []bool{false, true} {
+		acls, err := ruleToAcls(lport, rule, hasIPv6)
+		if err != nil {
+			t.Fatalf("ruleToAcls: %v", err)
+		}
+		n := 1
+		if hasIPv6 {
+			n = 2
+		}
+		if len(acls) != n {
+			t.Fatalf("hasIPv6 %v: want %d acls, got %d", hasIPv6, n, len(acls))
+		}
+		for i, acl := range acls {
+			if acl.Match != want[i] {
+				t.Errorf("want %s, got %s", want[i], acl.Match)
+			}
+		}
+	}
+
+	rule.CIDR = "0.0.0.0/0"
+	acls, _ := ruleToAcls(lport, rule, true)
+	if len(acls) != 2 {
+		t.Errorf("rule with any cidr should produce both ip4 and ip6 acl, got %d", len(acls))
+	}
+
+	rule.CIDR = "10.0.0.0/8"
+	acls, _ = ruleToAcls(lport, rule, true)
+	if len(acls) != 1 {
+		t.Errorf("rule with ipv4 cidr should only produce ip4 acl, got %d", len(acls))
+	}
+}
@@ -126,6 +126,11 @@ func (w *Worker) run(ctx context.Context, mss *agentmodels.ModelSets) (err error
 		if vpcHasEipgw(vpc) {
 			ovndb.ClaimVpcEipgw(ctx, vpc)
 		}
+		for _, flowLog := range mss.SecgroupFlowLogs {
+			if flowLog.VpcId == vpc.Id && flowLog.Enabled.Bool() {
+				ovndb.ClaimSecgroupFlowLog(ctx, flowLog)
+			}
+		}
 		for _, network := range vpc.Networks {
 			ovndb.ClaimNetwork(ctx, network, w.opts)
 			for _, guestnetwork := range network.Guestnetworks {
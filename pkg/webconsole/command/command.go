@@ -31,6 +31,8 @@ type ICommand interface {
 	Cleanup() error
 	Reconnect()
 	IsNeedShowInfo() bool
+	// IsReadOnly means user input is not passed to the command
+	IsReadOnly() bool
 	ShowInfo() string
 	Scan(d byte, send func(msg string))
 }
@@ -66,6 +68,10 @@ func (c BaseCommand) IsNeedShowInfo() bool {
 	return false
 }
 
+func (c BaseCommand) IsReadOnly() bool {
+	return false
+}
+
 func (c BaseCommand) ShowInfo() string {
 	return ""
 }
@@ -0,0 +1,74 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	o "yunion.io/x/onecloud/pkg/webconsole/options"
+)
+
+type ServerSerialLogInfo struct {
+	ServerId   string
+	ManagerUri string
+	Token      string
+	Tail       int64
+}
+
+// ServerSerialLog streams serial console log of kvm guest from host, user input is ignored
+type ServerSerialLog struct {
+	*BaseCommand
+	headerFile string
+}
+
+func NewServerSerialLogCommand(info *ServerSerialLogInfo) (*ServerSerialLog, error) {
+	if len(info.ManagerUri) == 0 {
+		return nil, fmt.Errorf("Empty host manager uri")
+	}
+	// pass token by file to avoid exposing it in process list
+	f, err := ioutil.TempFile("", "serial-log-header")
+	if err != nil {
+		return nil, errors.Wrap(err, "create header file")
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintf(f, "X-Auth-Token: %s\n", info.Token); err != nil {
+		os.Remove(f.Name())
+		return nil, errors.Wrap(err, "write header file")
+	}
+	url := fmt.Sprintf("%s/servers/%s/serial-log?follow=true&tail=%d", info.ManagerUri, info.ServerId, info.Tail)
+	cmd := NewBaseCommand(o.Options.CurlPath, "-sSNk", "-H", "@"+f.Name(), url)
+	return &ServerSerialLog{
+		BaseCommand: cmd,
+		headerFile:  f.Name(),
+	}, nil
+}
+
+func (c ServerSerialLog) GetProtocol() string {
+	return PROTOCOL_TTY
+}
+
+func (c ServerSerialLog) IsReadOnly() bool {
+	return true
+}
+
+func (c *ServerSerialLog) Cleanup() error {
+	log.Debugf("Remove temp serial log header file: %s", c.headerFile)
+	return os.Remove(c.headerFile)
+}
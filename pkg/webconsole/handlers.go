@@ -51,6 +51,7 @@ func InitHandlers(app *appsrv.Application) {
 	app.AddHandler("POST", ApiPathPrefix+"baremetal/<id>", auth.Authenticate(handleBaremetalShell))
 	app.AddHandler("POST", ApiPathPrefix+"ssh/<ip>", auth.Authenticate(handleSshShell))
 	app.AddHandler("POST", ApiPathPrefix+"server/<id>", auth.Authenticate(handleServerRemoteConsole))
+	app.AddHandler("POST", ApiPathPrefix+"server/<id>/serial", auth.Authenticate(handleServerSerialConsole))
 }
 
 func fetchK8sEnv(ctx context.Context, w http.ResponseWriter, r *http.Request) (*command.K8sEnv, error) {
@@ -219,6 +220,48 @@ func handleServerRemoteConsole(ctx context.Context, w http.ResponseWriter, r *ht
 	}
 }
 
+func handleServerSerialConsole(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	env, err := fetchCloudEnv(ctx, w, r)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	srv, err := modules.Servers.Get(env.ClientSessin, env.Params["<id>"], nil)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	srvId, _ := srv.GetString("id")
+	tail, _ := env.Body.Int("tail")
+	// check user is allowed to read serial log and find out the host
+	query := jsonutils.NewDict()
+	query.Add(jsonutils.NewInt(1), "tail")
+	ret, err := modules.Servers.GetSpecific(env.ClientSessin, srvId, "serial-log", query)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	hostId, _ := ret.GetString("host_id")
+	adminSession := auth.GetAdminSession(ctx, o.Options.Region, "")
+	host, err := modules.Hosts.Get(adminSession, hostId, nil)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	managerUri, _ := host.GetString("manager_uri")
+	cmd, err := command.NewServerSerialLogCommand(&command.ServerSerialLogInfo{
+		ServerId:   srvId,
+		ManagerUri: managerUri,
+		Token:      auth.AdminCredential().GetTokenString(),
+		Tail:       tail,
+	})
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	handleCommandSession(ctx, cmd, w)
+}
+
 func responsePublicCloudConsole(ctx context.Context, info *session.RemoteConsoleInfo, w http.ResponseWriter) {
 	params, err := info.GetConnectParams()
 	if err != nil {
@@ -29,6 +29,7 @@ type WebConsoleOptions struct {
 	IpmitoolPath      string `help:"ipmitool binary path used to connect baremetal sol" default:"/usr/bin/ipmitool"`
 	SshToolPath       string `help:"sshtool binary path used to connect server sol" default:"/usr/bin/ssh"`
 	SshpassToolPath   string `help:"sshpass tool binary path used to connect server sol" default:"/usr/bin/sshpass"`
+	CurlPath          string `help:"curl binary path used to fetch server serial log" default:"/usr/bin/curl"`
 	EnableAutoLogin   bool   `help:"allow webconsole to log in directly with the cloudroot public key" default:"false"`
 	ApsaraConsoleAddr string `help:"Apsara console addr" default:"https://xxxx.com.cn/module/ecs/vnc/index.html"`
 	AliyunVncVersion  string `help:"Aliyun vnc version" default:"0.0.8"`
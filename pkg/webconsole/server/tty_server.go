@@ -118,7 +118,7 @@ func initSocketHandler(so socketio.Socket, p *session.Pty) {
 					p.Resize(p.OriginSize)
 				}
 			}
-		} else {
+		} else if !p.Session.IsReadOnly() {
 			p.Pty.Write([]byte(data))
 		}
 	})
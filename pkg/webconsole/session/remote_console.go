@@ -96,6 +96,11 @@ func (info *RemoteConsoleInfo) IsNeedShowInfo() bool {
 	return false
 }
 
+// IsReadOnly implements ISessionData interface
+func (info *RemoteConsoleInfo) IsReadOnly() bool {
+	return false
+}
+
 // Reconnect implements ISessionData interface
 func (info *RemoteConsoleInfo) Reconnect() {
 	return